@@ -0,0 +1,218 @@
+// Command cobblepod holds operational subcommands run alongside the server and
+// worker binaries. Besides validate-config they work on a single Google account
+// given by a refresh token, without Redis, the server or a worker:
+//
+//	cobblepod run-once          process the newest playlist or backup in Drive
+//	cobblepod process FILE      process an M3U8 playlist or backup on the local disk
+//	cobblepod rebuild-feed      regenerate the feed from what is in storage
+//	cobblepod gc                delete episodes the feed no longer references
+//	cobblepod validate-config   check the settings and their dependencies
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"cobblepod/internal/auth"
+	"cobblepod/internal/config"
+	"cobblepod/internal/metadata"
+	"cobblepod/internal/podcast"
+	"cobblepod/internal/processor"
+	"cobblepod/internal/queue"
+	"cobblepod/internal/storage"
+	"cobblepod/internal/transcribe"
+	"cobblepod/internal/tts"
+
+	"github.com/spf13/cobra"
+)
+
+// localUserID identifies the account in logs and job IDs; the refresh token
+// decides which account it is
+const localUserID = "local"
+
+var (
+	// configPath is the YAML config file given by --config
+	configPath string
+	// refreshToken is the Google refresh token given by --refresh-token
+	refreshToken string
+)
+
+func main() {
+	root := &cobra.Command{
+		Use:           "cobblepod",
+		Short:         "One-off cobblepod operations",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	root.PersistentFlags().StringVar(&configPath, "config", os.Getenv(config.FileEnv), "YAML config file")
+	root.PersistentFlags().StringVar(&refreshToken, "refresh-token", os.Getenv("GOOGLE_REFRESH_TOKEN"), "Google refresh token of the account to work on")
+	root.AddCommand(
+		newRunOnceCommand(),
+		newProcessCommand(),
+		newRebuildFeedCommand(),
+		newGCCommand(),
+		newValidateConfigCommand(),
+	)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	err := root.ExecuteContext(ctx)
+	stop()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func newRunOnceCommand() *cobra.Command {
+	settings := &queue.UserSettings{}
+	cmd := &cobra.Command{
+		Use:   "run-once",
+		Short: "Process the newest M3U8 playlist or Podcast Addict backup in Drive",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			proc, err := newProcessor(settings)
+			if err != nil {
+				return err
+			}
+			return proc.Run(cmd.Context(), newJob())
+		},
+	}
+	addSettingsFlags(cmd, settings)
+	return cmd
+}
+
+func newProcessCommand() *cobra.Command {
+	settings := &queue.UserSettings{}
+	cmd := &cobra.Command{
+		Use:     "process <file>",
+		Aliases: []string{"process-backup"},
+		Short:   "Process a local M3U8 playlist or Podcast Addict backup into the feed",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			proc, err := newProcessor(settings)
+			if err != nil {
+				return err
+			}
+			job := newJob()
+			job.LocalPath = args[0]
+			job.Filename = filepath.Base(args[0])
+			return proc.Run(cmd.Context(), job)
+		},
+	}
+	addSettingsFlags(cmd, settings)
+	return cmd
+}
+
+func newRebuildFeedCommand() *cobra.Command {
+	settings := &queue.UserSettings{}
+	cmd := &cobra.Command{
+		Use:   "rebuild-feed",
+		Short: "Regenerate the feed with the given settings, dropping episodes whose audio is gone",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			proc, err := newProcessor(settings)
+			if err != nil {
+				return err
+			}
+			feedURL, dropped, err := proc.RebuildFeed(cmd.Context(), localUserID)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Rebuilt %s, dropped %d episodes\n", feedURL, dropped)
+			return nil
+		},
+	}
+	addSettingsFlags(cmd, settings)
+	return cmd
+}
+
+func newGCCommand() *cobra.Command {
+	var dryRun bool
+	var minAge time.Duration
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Delete episodes in the feed folder that the feed no longer references",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			proc, err := newProcessor(nil)
+			if err != nil {
+				return err
+			}
+			garbage, err := proc.CollectGarbage(cmd.Context(), localUserID, minAge, dryRun)
+			for _, file := range garbage {
+				fmt.Printf("%s\t%s\n", file.ID, file.Name)
+			}
+			if err != nil {
+				return err
+			}
+			if dryRun {
+				fmt.Printf("%d unreferenced episodes, none deleted\n", len(garbage))
+			} else {
+				fmt.Printf("Deleted %d unreferenced episodes\n", len(garbage))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "list unreferenced episodes without deleting them")
+	cmd.Flags().DurationVar(&minAge, "min-age", 24*time.Hour, "leave files younger than this, which a running job may still publish")
+	return cmd
+}
+
+// addSettingsFlags exposes the user settings that shape processing and the feed
+func addSettingsFlags(cmd *cobra.Command, settings *queue.UserSettings) {
+	flags := cmd.Flags()
+	flags.Float64Var(&settings.Speed, "speed", 0, fmt.Sprintf("playback speed (default %g)", config.DefaultSpeed))
+	flags.StringVar(&settings.OutputFormat, "format", "", "output format extension (default mp3)")
+	flags.BoolVar(&settings.TrimSilence, "trim-silence", false, "remove long silences")
+	flags.IntVar(&settings.BitrateKbps, "bitrate", 0, "audio bitrate in kbps (default: the encoder's)")
+	flags.BoolVar(&settings.Mono, "mono", false, "downmix to a single channel")
+	flags.BoolVar(&settings.SpokenPreamble, "spoken-preamble", false, "announce each episode with text-to-speech (needs TTS_COMMAND)")
+	flags.BoolVar(&settings.Transcripts, "transcripts", false, "publish a transcript with every episode (needs TRANSCRIBE_COMMAND or TRANSCRIBE_URL)")
+	flags.IntVar(&settings.RetentionDays, "retention-days", 0, "keep episodes that left the playlist for this many days")
+	flags.StringVar(&settings.FeedTitle, "feed-title", "", "feed channel title")
+	flags.StringVar(&settings.TimeZone, "time-zone", "", "IANA time zone feed dates are shown in (default UTC)")
+}
+
+// newProcessor returns a processor that works on the refresh token's account
+// with settings, keeping progress in memory. The environment is enough to run
+// it; a config file given with --config must hold a complete deployment config.
+func newProcessor(settings *queue.UserSettings) (*processor.Processor, error) {
+	cfg, err := config.FromEnv()
+	if configPath != "" {
+		cfg, err = config.Load(configPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if settings != nil {
+		if err := settings.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	provider, err := auth.NewRefreshTokenProvider(cfg.Auth, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	proc := processor.NewProcessorWithDependencies(nil, provider, storage.NewServiceWithTokenSource, processor.NewLocalStore(settings), podcast.NewFolders(cfg.Storage.DriveFolder))
+	proc.SetWorkerConfig(cfg.Worker)
+	proc.SetMetadataProvider(metadata.NewRSSProvider(nil))
+	proc.SetSynthesizer(tts.Configured(cfg.Worker))
+	proc.SetTranscriber(transcribe.Configured(cfg.Worker))
+	return proc, nil
+}
+
+// newJob returns the job a one-off run reports its progress under
+func newJob() *queue.Job {
+	return &queue.Job{
+		ID:     fmt.Sprintf("%s-%d", localUserID, time.Now().Unix()),
+		UserID: localUserID,
+		Status: queue.JobStatusRunning,
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"cobblepod/internal/audio"
+	"cobblepod/internal/auth"
+	"cobblepod/internal/config"
+	"cobblepod/internal/health"
+	"cobblepod/internal/queue"
+
+	"github.com/spf13/cobra"
+)
+
+// checkTimeout bounds each dependency check
+const checkTimeout = 10 * time.Second
+
+// dependencyCheck is a named connectivity check run by validate-config
+type dependencyCheck struct {
+	name string
+	run  health.CheckFunc
+}
+
+func newValidateConfigCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate-config",
+		Short: "Check the settings and that every dependency they point at is reachable",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !validateConfig(configPath) {
+				return errors.New("configuration check failed")
+			}
+			return nil
+		},
+	}
+}
+
+// validateConfig checks the settings, then that every dependency they point at
+// is usable, printing a line per check. It returns whether all passed.
+func validateConfig(path string) bool {
+	cfg, err := config.Load(path)
+	if err != nil {
+		fmt.Printf("FAIL config\n%v\n", err)
+		return false
+	}
+	fmt.Println("ok   config")
+
+	ctx := context.Background()
+	client := &http.Client{Timeout: checkTimeout}
+	var jobQueue *queue.Queue
+	checks := []dependencyCheck{
+		{"redis", func(ctx context.Context) error {
+			q, err := queue.NewQueue(ctx, cfg)
+			jobQueue = q
+			return err
+		}},
+		{"storage", health.Reachable(client, cfg.Storage.HealthURL)},
+		{"auth", func(ctx context.Context) error {
+			var store auth.GoogleTokenStore
+			if jobQueue != nil {
+				store = jobQueue
+			}
+			provider, err := auth.NewProvider(cfg.Auth, store)
+			if err != nil {
+				return err
+			}
+			return health.Reachable(client, provider.Issuer().JoinPath(".well-known", "openid-configuration").String())(ctx)
+		}},
+	}
+	if !cfg.Worker.FakeAudio {
+		checks = append(checks, dependencyCheck{"ffmpeg", audio.NewFFmpeg(cfg.Worker).Check})
+	}
+
+	passed := true
+	for _, check := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		err := check.run(checkCtx)
+		cancel()
+		if err != nil {
+			passed = false
+			fmt.Printf("FAIL %s: %v\n", check.name, err)
+			continue
+		}
+		fmt.Printf("ok   %s\n", check.name)
+	}
+	if jobQueue != nil {
+		jobQueue.Close()
+	}
+	return passed
+}
@@ -9,7 +9,10 @@ import (
 	"syscall"
 	"time"
 
+	"cobblepod/internal/config"
+	"cobblepod/internal/logging"
 	"cobblepod/internal/server"
+	"cobblepod/internal/tracing"
 )
 
 // @title           Cobblepod API
@@ -22,16 +25,25 @@ func main() {
 	jsonHandler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
 	})
-	slog.SetDefault(slog.New(jsonHandler))
+	// Lines logged with a job's context carry its IDs, see logging.With
+	slog.SetDefault(slog.New(logging.NewHandler(jsonHandler)))
 
-	// Get port from environment or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Settings come from the environment and the optional CONFIG_FILE
+	cfg, err := config.Load(os.Getenv(config.FileEnv))
+	if err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// Export traces when a collector is configured
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, "cobblepod-server")
+	if err != nil {
+		slog.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
 	}
 
 	// Create HTTP server
-	srv, err := server.NewServer(port)
+	srv, err := server.NewServer(cfg)
 	if err != nil {
 		slog.Error("Failed to create server", "error", err)
 		os.Exit(1)
@@ -53,7 +65,7 @@ func main() {
 		}
 	}()
 
-	slog.Info("Cobblepod HTTP server started", "port", port)
+	slog.Info("Cobblepod HTTP server started", "port", cfg.Server.Port)
 
 	// Wait for shutdown signal
 	select {
@@ -72,4 +84,7 @@ func main() {
 	} else {
 		slog.Info("Server exited gracefully")
 	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
 }
@@ -2,74 +2,423 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"time"
 
+	"cobblepod/internal/audio"
+	"cobblepod/internal/config"
+	"cobblepod/internal/cron"
+	"cobblepod/internal/health"
+	"cobblepod/internal/logging"
+	"cobblepod/internal/notify"
 	"cobblepod/internal/processor"
 	"cobblepod/internal/queue"
+	"cobblepod/internal/storage"
+	"cobblepod/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// runJob runs a job, converting a panic into a *processor.PanicError so one bad
+// job doesn't take the worker down with it. The job's span continues the trace
+// of the request that created it.
+func runJob(ctx context.Context, proc *processor.Processor, job *queue.Job) (err error) {
+	ctx, span := tracing.Start(tracing.WithTraceParent(ctx, job.TraceParent), "job.run",
+		attribute.String("job.id", job.ID),
+		attribute.String("user.id", job.UserID),
+		attribute.Int("job.attempts", job.Attempts),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	defer processor.Recover(&err)
+	return proc.Run(ctx, job)
+}
+
+const (
+	// cleanupInterval is how often expired jobs are removed and stalled ones recovered
+	cleanupInterval = time.Hour
+	// permissionInterval is how often published feeds' permissions are checked
+	permissionInterval = 24 * time.Hour
+	// archivePurgeInterval is how often expired archived episodes are deleted
+	archivePurgeInterval = 24 * time.Hour
+	// playlistPollInterval is how often users' poll schedules are checked for a
+	// playlist URL that is due, the resolution of cron expressions
+	playlistPollInterval = time.Minute
+	// maxPollCatchUp caps how many skipped minutes a late poll makes up for
+	maxPollCatchUp = 60
+	// maxTaskBackoffPeriods caps how many of its periods a periodic task that
+	// can't reach Redis is skipped for
+	maxTaskBackoffPeriods = 4
 )
 
+// taskBackoff skips the ticks of a periodic task while Redis keeps failing it,
+// doubling the wait from the task's period with every failure in a row. Each
+// wait is jittered so replicas don't retry in lockstep.
+type taskBackoff struct {
+	failures int
+	retryAt  time.Time
+}
+
+// ready reports whether the task may run at now
+func (b *taskBackoff) ready(now time.Time) bool {
+	return !now.Before(b.retryAt)
+}
+
+// fail records a failed run at now, returning the wait before the next one
+func (b *taskBackoff) fail(now time.Time, period time.Duration) time.Duration {
+	b.failures++
+	maxWait := period * maxTaskBackoffPeriods
+	wait := period
+	for i := 1; i < b.failures && wait < maxWait; i++ {
+		wait *= 2
+	}
+	wait = queue.Jitter(min(wait, maxWait))
+	b.retryAt = now.Add(wait)
+	return wait
+}
+
+// succeed records a run that reached Redis
+func (b *taskBackoff) succeed() {
+	b.failures = 0
+	b.retryAt = time.Time{}
+}
+
+// requeueJob hands a dequeued job that couldn't start back to the queue for a
+// later attempt. If even that fails the job is left claimed, and stall
+// recovery requeues it once its heartbeat expires; it is never failed for it.
+func requeueJob(ctx context.Context, jobQueue *queue.Queue, job *queue.Job) {
+	if err := jobQueue.DeferJob(ctx, job, queue.UserBusyDelay); err != nil {
+		slog.Error("Failed to requeue job, leaving it to stall recovery", "error", err, "job_id", job.ID)
+	}
+}
+
+// claimTask reports whether this replica runs a periodic task this period.
+// While claims keep failing the task is skipped for longer and longer, see
+// taskBackoff.
+func claimTask(ctx context.Context, jobQueue *queue.Queue, task string, period time.Duration, backoff *taskBackoff) bool {
+	if !backoff.ready(time.Now()) {
+		return false
+	}
+	claimed, err := jobQueue.ClaimPeriodicTask(ctx, task, period)
+	if err != nil {
+		wait := backoff.fail(time.Now(), period)
+		slog.Error("Failed to claim periodic task, backing off", "error", err, "task", task, "failures", backoff.failures, "backoff", wait)
+		return false
+	}
+	backoff.succeed()
+	if !claimed {
+		slog.Debug("Periodic task claimed by another replica", "task", task)
+	}
+	return claimed
+}
+
+// pollPlaylists enqueues the playlist URLs whose poll schedule is due, every
+// minute until ctx is cancelled. schedule applies to users without their own.
+// A tick that comes late also polls the minutes it skipped, up to
+// maxPollCatchUp of them.
+func pollPlaylists(ctx context.Context, jobQueue *queue.Queue, schedule *cron.Schedule) {
+	ticker := time.NewTicker(playlistPollInterval)
+	defer ticker.Stop()
+
+	var backoff taskBackoff
+	var last time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case tick := <-ticker.C:
+			now := tick.Truncate(playlistPollInterval)
+			for _, minute := range pollMinutes(last, now) {
+				last = minute
+				// Claimed per minute, so each minute's schedules are polled once
+				if !claimTask(ctx, jobQueue, "playlist-poll:"+minute.UTC().Format("200601021504"), playlistPollInterval, &backoff) {
+					continue
+				}
+				enqueued := processor.PollPlaylistURLs(ctx, jobQueue, nil, minute, schedule)
+				slog.Info("Playlist URL poll finished", "minute", minute, "enqueued", enqueued)
+			}
+		}
+	}
+}
+
+// pollMinutes returns the minutes after last up to and including now that are
+// due a poll, at most the maxPollCatchUp latest. With no last poll it's just now.
+func pollMinutes(last, now time.Time) []time.Time {
+	first := now
+	if !last.IsZero() {
+		first = last.Add(playlistPollInterval)
+		if earliest := now.Add(-(maxPollCatchUp - 1) * playlistPollInterval); first.Before(earliest) {
+			first = earliest
+		}
+	}
+	var minutes []time.Time
+	for minute := first; !minute.After(now); minute = minute.Add(playlistPollInterval) {
+		minutes = append(minutes, minute)
+	}
+	return minutes
+}
+
+// repairFeedPermissions restores public permissions on every published feed and
+// its enclosures, which Drive occasionally drops after sharing policy changes
+func repairFeedPermissions(ctx context.Context, jobQueue *queue.Queue, proc *processor.Processor) {
+	owners, err := jobQueue.GetFeedOwners(ctx)
+	if err != nil {
+		slog.Error("Failed to get feed owners", "error", err)
+		return
+	}
+
+	total := 0
+	for _, userID := range owners {
+		repaired, err := proc.RepairFeedPermissions(ctx, userID)
+		if err != nil {
+			slog.Error("Failed to repair feed permissions", "error", err, "user_id", userID)
+		}
+		total += repaired
+	}
+	slog.Info("Feed permission check finished", "users", len(owners), "repaired", total)
+}
+
+// purgeArchives deletes the archived episodes of every feed that outlived
+// retention
+func purgeArchives(ctx context.Context, jobQueue *queue.Queue, proc *processor.Processor, retention time.Duration) {
+	owners, err := jobQueue.GetFeedOwners(ctx)
+	if err != nil {
+		slog.Error("Failed to get feed owners", "error", err)
+		return
+	}
+
+	olderThan := time.Now().Add(-retention)
+	total := 0
+	for _, userID := range owners {
+		deleted, err := proc.PurgeArchive(ctx, userID, olderThan)
+		if err != nil {
+			slog.Error("Failed to purge archived episodes", "error", err, "user_id", userID)
+		}
+		total += deleted
+	}
+	slog.Info("Archive purge finished", "users", len(owners), "deleted", total)
+}
+
+// notifyJobFinished sends a finished job's notifications over the channels in
+// the user's settings
+func notifyJobFinished(ctx context.Context, jobQueue *queue.Queue, notifier *notify.Dispatcher, userID, jobID string) {
+	settings, err := jobQueue.GetUserSettings(ctx, userID)
+	if err != nil {
+		slog.Error("Failed to get user settings", "error", err, "user_id", userID)
+		return
+	}
+	if len(notifier.Channels(settings)) == 0 {
+		return
+	}
+	job, err := jobQueue.GetJob(ctx, jobID)
+	if err != nil || job == nil {
+		slog.Error("Failed to get finished job", "error", err, "job_id", jobID)
+		return
+	}
+	if err := notifier.JobFinished(ctx, settings, job); err != nil {
+		slog.Error("Failed to send job notification", "error", err, "job_id", jobID)
+	}
+}
+
+// startHealthServer serves the checker's probes on port in the background
+func startHealthServer(checker *health.Checker, port int) *http.Server {
+	mux := http.NewServeMux()
+	checker.Register(mux)
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("Health server failed", "error", err)
+		}
+	}()
+	slog.Info("Health server started", "port", port)
+	return srv
+}
+
+// drainOnSignal stops dequeuing on the first signal and cancels running work
+// once timeout passes or a second signal arrives
+func drainOnSignal(sigChan <-chan os.Signal, stopDequeue, cancel context.CancelFunc, timeout time.Duration) {
+	sig := <-sigChan
+	slog.Info("Received signal, draining", "signal", sig, "timeout", timeout)
+	stopDequeue()
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	select {
+	case <-deadline.C:
+		slog.Warn("Drain deadline passed, interrupting running job")
+	case sig := <-sigChan:
+		slog.Warn("Received second signal, interrupting running job", "signal", sig)
+	}
+	cancel()
+}
+
 func main() {
 	// Initialize structured logging with JSON handler
 	jsonHandler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
 	})
-	slog.SetDefault(slog.New(jsonHandler))
+	// Lines logged with a job's context carry its IDs, see logging.With
+	slog.SetDefault(slog.New(logging.NewHandler(jsonHandler)))
+
+	// Settings come from the environment and the optional CONFIG_FILE
+	cfg, err := config.Load(os.Getenv(config.FileEnv))
+	if err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	// Validated by config.Load, parsed once here for the playlist URL polls
+	pollSchedule, err := cron.Parse(cfg.Worker.PollSchedule)
+	if err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
 
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Export traces when a collector is configured
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing, "cobblepod-worker")
+	if err != nil {
+		slog.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+	defer func() {
+		flushCtx, flushCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer flushCancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			slog.Error("Failed to flush traces", "error", err)
+		}
+	}()
+
 	// Set up signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	// Initialize job queue
-	jobQueue, err := queue.NewQueue(ctx)
+	jobQueue, err := queue.NewQueue(ctx, cfg)
 	if err != nil {
 		slog.Error("Failed to connect to job queue", "error", err)
 		os.Exit(1)
 	}
+	// Keep each job's lines in its log for GET /jobs/:id/logs
+	captureHandler := logging.NewHandler(jsonHandler).CaptureJobs(jobQueue)
+	slog.SetDefault(slog.New(captureHandler))
 	defer jobQueue.Close()
+	// Store the lines still waiting before the queue closes
+	defer func() {
+		flushCtx, flushCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer flushCancel()
+		if err := captureHandler.Close(flushCtx); err != nil {
+			slog.Error("Failed to store captured job logs", "error", err)
+		}
+	}()
+
+	// Serve Kubernetes probes
+	checker := health.NewChecker()
+	// Fake audio is for running without FFmpeg
+	if !cfg.Worker.FakeAudio {
+		checker.AddLiveness("ffmpeg", audio.NewFFmpeg(cfg.Worker).Check)
+	}
+	checker.AddReadiness("redis", jobQueue.Ping)
+	checker.AddReadiness("storage", health.Reachable(&http.Client{Timeout: health.CheckTimeout}, cfg.Storage.HealthURL))
+	healthServer := startHealthServer(checker, cfg.Worker.HealthPort)
+	defer healthServer.Close()
 
 	// Initialize processor
-	proc, err := processor.NewProcessor(ctx, jobQueue)
+	proc, err := processor.NewProcessor(ctx, jobQueue, cfg)
 	if err != nil {
 		slog.Error("Failed to create processor", "error", err)
 		os.Exit(1)
 	}
 
+	// Notify users over email (when SMTP is configured) and their other channels
+	notifier := notify.NewDispatcher(notify.NewMailer(cfg.Notifications), cfg.Notifications)
+
+	// Move scheduled jobs into the waiting queue once they are due
+	go jobQueue.RunScheduler(ctx, queue.ScheduleInterval)
+
 	// Start cleanup ticker (every hour)
-	cleanupTicker := time.NewTicker(1 * time.Hour)
+	cleanupTicker := time.NewTicker(cleanupInterval)
 	defer cleanupTicker.Stop()
 
+	// Start feed permission check ticker (every day)
+	permissionTicker := time.NewTicker(permissionInterval)
+	defer permissionTicker.Stop()
+
+	// Start archive purge ticker (every day)
+	archiveTicker := time.NewTicker(archivePurgeInterval)
+	defer archiveTicker.Stop()
+
+	// Poll playlist URLs every minute, away from the job loop so a running job
+	// doesn't make the worker miss minutes of users' schedules
+	go pollPlaylists(ctx, jobQueue, pollSchedule)
+
+	// Each task backs off on its own while Redis is unavailable
+	var cleanupBackoff, permissionBackoff, archiveBackoff taskBackoff
+	// No jobs are taken until then while storage's circuit is open
+	var pausedUntil time.Time
+
+	// The first signal drains the worker: it stops taking jobs and gives the
+	// running one until the drain deadline. A second signal stops it at once.
+	dequeueCtx, stopDequeue := context.WithCancel(ctx)
+	defer stopDequeue()
+	go drainOnSignal(sigChan, stopDequeue, cancel, cfg.Worker.DrainTimeout())
+
 	slog.Info("Worker started, waiting for jobs...")
 
 	// Main worker loop
 	for {
 		select {
-		case <-ctx.Done():
-			slog.Info("Context cancelled, shutting down")
-			return
-		case sig := <-sigChan:
-			slog.Info("Received signal, shutting down gracefully", "signal", sig)
-			cancel()
+		case <-dequeueCtx.Done():
+			slog.Info("Worker drained, shutting down")
 			return
 		case <-cleanupTicker.C:
+			// Every replica ticks; one of them runs each period's cleanup
+			if !claimTask(ctx, jobQueue, "cleanup", cleanupInterval, &cleanupBackoff) {
+				continue
+			}
 			slog.Info("Running scheduled cleanup")
 			if err := jobQueue.CleanupExpiredJobs(ctx); err != nil {
 				slog.Error("Failed to cleanup expired jobs", "error", err)
 			}
+		case <-permissionTicker.C:
+			if !claimTask(ctx, jobQueue, "feed-permissions", permissionInterval, &permissionBackoff) {
+				continue
+			}
+			slog.Info("Checking feed permissions")
+			repairFeedPermissions(ctx, jobQueue, proc)
+		case <-archiveTicker.C:
+			if !claimTask(ctx, jobQueue, "archive-purge", archivePurgeInterval, &archiveBackoff) {
+				continue
+			}
+			slog.Info("Purging expired archived episodes")
+			purgeArchives(ctx, jobQueue, proc, cfg.Worker.ArchiveRetention())
 		default:
+			if wait := time.Until(pausedUntil); wait > 0 {
+				select {
+				case <-dequeueCtx.Done():
+				case <-time.After(wait):
+				}
+				continue
+			}
+
 			// Dequeue job (blocks until job available or timeout)
-			job, err := jobQueue.Dequeue(ctx)
+			job, err := jobQueue.Dequeue(dequeueCtx)
 			if err != nil {
-				if err == context.Canceled {
-					return
+				if dequeueCtx.Err() != nil {
+					continue // draining
 				}
 				slog.Error("Failed to dequeue job", "error", err)
 				continue
@@ -83,38 +432,79 @@ func main() {
 			// Try to mark user as running
 			started, err := jobQueue.StartJob(ctx, job.UserID, job.ID)
 			if err != nil {
-				slog.Error("Failed to mark job as started", "error", err, "job_id", job.ID)
-				// Fail the job due to system error (don't hold lock)
-				jobQueue.FailJob(ctx, job, "Failed to acquire user lock")
+				// Likely a Redis hiccup, not a problem with the job; another
+				// replica (or this one) can start it once Redis is back
+				slog.Error("Failed to mark job as started, requeueing it", "error", err, "job_id", job.ID)
+				requeueJob(ctx, jobQueue, job)
 				continue
 			}
 
 			if !started {
-				// User already has a running job - fail this one (don't hold lock)
-				slog.Warn("User already has running job, failing new job",
-					"user_id", job.UserID, "job_id", job.ID)
-				jobQueue.FailJob(ctx, job, "User already has a job being processed")
+				// All of the user's running slots are taken, possibly by jobs on
+				// other replicas; try again once one frees up
+				requeueJob(ctx, jobQueue, job)
 				continue
 			}
 
-			// Process the job - use a function to ensure defer runs
-			func() {
-				// Always release the user lock when done
-				defer func() {
-					if err := jobQueue.CompleteJob(ctx, job.UserID, job.ID); err != nil {
-						slog.Error("Failed to release user lock", "error", err, "user_id", job.UserID)
-					}
-				}()
-
-				slog.Info("Processing job", "job_id", job.ID, "user_id", job.UserID, "file_id", job.FileID)
-
-				if err := proc.Run(ctx, job); err != nil {
-					slog.Error("Job processing failed", "error", err, "job_id", job.ID)
-					jobQueue.FailJob(ctx, job, err.Error())
-				} else {
-					slog.Info("Job completed successfully", "job_id", job.ID)
+			// Process the job; every outcome releases its running slot. Lines
+			// logged with jobCtx are kept in the job's log, see logging.With.
+			jobCtx := logging.With(ctx, "job_id", job.ID, "user_id", job.UserID)
+			slog.InfoContext(jobCtx, "Processing job", "file_id", job.FileID)
+
+			stopHeartbeat := jobQueue.StartHeartbeat(ctx, job.ID)
+			err = runJob(jobCtx, proc, job)
+			stopHeartbeat()
+
+			var partial *processor.PartialFailureError
+			var panicked *processor.PanicError
+			released := err != nil && (ctx.Err() != nil || errors.Is(err, storage.ErrCircuitOpen))
+			if err != nil && ctx.Err() != nil {
+				// Interrupted by the drain deadline; let another worker finish it
+				slog.WarnContext(jobCtx, "Job interrupted by shutdown, releasing it")
+				releaseCtx, releaseCancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+				if err := jobQueue.ReleaseJob(releaseCtx, job); err != nil {
+					slog.ErrorContext(jobCtx, "Failed to release job", "error", err)
+				}
+				releaseCancel()
+			} else if errors.Is(err, storage.ErrCircuitOpen) {
+				// Storage is down, not the job; hand it back without counting the
+				// attempt and take no jobs until the circuit may close
+				pausedUntil = time.Now().Add(cfg.Storage.BreakerCooldown())
+				slog.WarnContext(jobCtx, "Storage unavailable, releasing job and pausing", "error", err, "until", pausedUntil)
+				if err := jobQueue.ReleaseJob(ctx, job); err != nil {
+					slog.ErrorContext(jobCtx, "Failed to release job", "error", err)
+				}
+			} else if err == nil {
+				slog.InfoContext(jobCtx, "Job completed successfully")
+				if err := jobQueue.CompleteJob(ctx, job.UserID, job.ID); err != nil {
+					slog.ErrorContext(jobCtx, "Failed to complete job", "error", err)
 				}
-			}()
+				if err := jobQueue.AddFeedOwner(ctx, job.UserID); err != nil {
+					slog.ErrorContext(jobCtx, "Failed to record feed owner", "error", err)
+				}
+			} else if errors.As(err, &partial) {
+				slog.WarnContext(jobCtx, "Job completed with errors", "failed_items", partial.Failed, "total_items", partial.Total)
+				if err := jobQueue.CompleteJobWithErrors(ctx, job.UserID, job.ID, partial.Failed); err != nil {
+					slog.ErrorContext(jobCtx, "Failed to complete job", "error", err)
+				}
+				if err := jobQueue.AddFeedOwner(ctx, job.UserID); err != nil {
+					slog.ErrorContext(jobCtx, "Failed to record feed owner", "error", err)
+				}
+			} else if errors.As(err, &panicked) {
+				slog.ErrorContext(jobCtx, "Job processing panicked", "error", err, "stack", string(panicked.Stack))
+				if err := jobQueue.DeadLetterJob(ctx, job, panicked.FailReason()); err != nil {
+					slog.ErrorContext(jobCtx, "Failed to mark job as failed", "error", err)
+				}
+			} else {
+				slog.ErrorContext(jobCtx, "Job processing failed", "error", err)
+				if err := jobQueue.FailJob(ctx, job, err.Error()); err != nil {
+					slog.ErrorContext(jobCtx, "Failed to mark job as failed", "error", err)
+				}
+			}
+			// A released job isn't finished; whichever worker finishes it notifies
+			if !released {
+				go notifyJobFinished(context.WithoutCancel(ctx), jobQueue, notifier, job.UserID, job.ID)
+			}
 		}
 	}
 }
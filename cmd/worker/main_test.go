@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTaskBackoff(t *testing.T) {
+	now := time.Now()
+	for _, period := range []time.Duration{playlistPollInterval, cleanupInterval, permissionInterval} {
+		var backoff taskBackoff
+		if !backoff.ready(now) {
+			t.Fatalf("period %v: a task that never failed should be ready", period)
+		}
+
+		// The wait doubles from the period up to its cap, jittered down to half
+		maxWait := period * maxTaskBackoffPeriods
+		want := period
+		for failures := 1; failures <= 6; failures++ {
+			wait := backoff.fail(now, period)
+			if wait < want/2 || wait > want {
+				t.Errorf("period %v, failure %d: wait %v, want between %v and %v", period, failures, wait, want/2, want)
+			}
+			if backoff.ready(now.Add(wait - time.Nanosecond)) {
+				t.Errorf("period %v, failure %d: ready before the wait is over", period, failures)
+			}
+			if !backoff.ready(now.Add(wait)) {
+				t.Errorf("period %v, failure %d: not ready once the wait is over", period, failures)
+			}
+			want = min(want*2, maxWait)
+		}
+
+		backoff.succeed()
+		if !backoff.ready(now) {
+			t.Errorf("period %v: not ready after a success", period)
+		}
+		if wait := backoff.fail(now, period); wait > period {
+			t.Errorf("period %v: wait %v after a success, want the backoff to start over", period, wait)
+		}
+	}
+}
+
+func TestPollMinutes(t *testing.T) {
+	now := time.Date(2026, 3, 2, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		last  time.Time
+		first time.Time
+		count int
+	}{
+		{"first poll", time.Time{}, now, 1},
+		{"next minute", now.Add(-time.Minute), now, 1},
+		{"skipped minutes", now.Add(-4 * time.Minute), now.Add(-3 * time.Minute), 4},
+		{"already polled", now, time.Time{}, 0},
+		{"long pause", now.Add(-3 * time.Hour), now.Add(-(maxPollCatchUp - 1) * time.Minute), maxPollCatchUp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minutes := pollMinutes(tt.last, now)
+			if len(minutes) != tt.count {
+				t.Fatalf("got %d minutes, want %d", len(minutes), tt.count)
+			}
+			if tt.count == 0 {
+				return
+			}
+			if !minutes[0].Equal(tt.first) {
+				t.Errorf("first minute %v, want %v", minutes[0], tt.first)
+			}
+			if !minutes[len(minutes)-1].Equal(now) {
+				t.Errorf("last minute %v, want %v", minutes[len(minutes)-1], now)
+			}
+		})
+	}
+}
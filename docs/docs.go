@@ -15,67 +15,77 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/backup/upload": {
-            "post": {
-                "description": "Uploads a backup file to be processed",
-                "consumes": [
-                    "multipart/form-data"
-                ],
+        "/admin/jobs/running": {
+            "get": {
+                "description": "List the jobs currently running across all users (admin only)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "backup"
-                ],
-                "summary": "Upload backup file",
-                "parameters": [
-                    {
-                        "type": "file",
-                        "description": "Backup file",
-                        "name": "file",
-                        "in": "formData",
-                        "required": true
-                    }
+                    "admin"
                 ],
+                "summary": "List running jobs",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/endpoints.BackupUploadResponse"
+                            "$ref": "#/definitions/endpoints.GetRunningJobsResponse"
                         }
                     },
                     "401": {
                         "description": "Unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/endpoints.BackupUploadResponse"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
                 }
             }
         },
-        "/jobs": {
-            "get": {
-                "description": "Get a list of jobs for the authenticated user, optionally filtered by status",
+        "/admin/jobs/{id}/retry": {
+            "post": {
+                "description": "Move a job that failed for a system-side reason (lock acquisition, panic) back into the waiting queue (admin only)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "jobs"
+                    "admin"
                 ],
-                "summary": "Get jobs",
+                "summary": "Retry dead-lettered job",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Job status filter",
-                        "name": "status",
-                        "in": "query"
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/endpoints.GetJobsResponse"
+                            "$ref": "#/definitions/endpoints.JobResponse"
                         }
                     },
                     "401": {
@@ -87,6 +97,24 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -98,134 +126,3197 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "endpoints.BackupUploadResponse": {
-            "type": "object",
-            "properties": {
-                "error": {
-                    "type": "string"
-                },
-                "file_id": {
-                    "type": "string"
-                },
-                "job_id": {
-                    "type": "string"
-                },
-                "message": {
-                    "type": "string"
-                },
-                "success": {
-                    "type": "boolean"
-                }
-            }
         },
-        "endpoints.GetJobsResponse": {
-            "type": "object",
-            "properties": {
-                "jobs": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/queue.Job"
+        "/admin/locks": {
+            "get": {
+                "description": "List users holding running slots or a feed lock, stuck ones first. A lock is stuck when the slot count doesn't match the user's running jobs, or the feed is locked with no job running (admin only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List user locks",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.GetUserLocksResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     }
                 }
             }
         },
-        "queue.Job": {
-            "type": "object",
-            "properties": {
-                "created_at": {
-                    "type": "string"
-                },
-                "fail_reason": {
-                    "description": "Set when job fails",
-                    "type": "string"
-                },
-                "file_id": {
-                    "type": "string"
-                },
-                "filename": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "string"
-                },
-                "items": {
-                    "description": "Items are stored in a separate hash",
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/queue.JobItem"
+        "/admin/locks/{userID}": {
+            "delete": {
+                "description": "Release a user's feed lock and reset their running slot count to the jobs actually running (admin only)",
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Release user lock",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     }
-                },
-                "status": {
-                    "description": "queued, running, completed, failed",
-                    "type": "string"
-                },
-                "user_id": {
-                    "type": "string"
                 }
             }
         },
-        "queue.JobItem": {
-            "type": "object",
-            "properties": {
-                "duration": {
-                    "type": "integer"
-                },
-                "error": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "string"
-                },
-                "offset": {
-                    "type": "integer"
-                },
-                "source_url": {
-                    "type": "string"
-                },
-                "status": {
-                    "$ref": "#/definitions/queue.JobItemStatus"
-                },
-                "title": {
-                    "type": "string"
+        "/admin/queue": {
+            "get": {
+                "description": "Count jobs waiting, scheduled, running and finished across all users (admin only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get queue stats",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/queue.QueueStats"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
                 }
             }
         },
-        "queue.JobItemStatus": {
-            "type": "string",
-            "enum": [
-                "pending",
-                "downloading",
-                "processing",
-                "uploading",
-                "completed",
-                "skipped",
-                "failed"
-            ],
-            "x-enum-comments": {
-                "StatusProcessing": "ffmpeg",
-                "StatusSkipped": "reused"
-            },
-            "x-enum-descriptions": [
-                "",
-                "",
-                "ffmpeg",
-                "",
-                "",
-                "reused",
-                ""
-            ],
-            "x-enum-varnames": [
-                "StatusPending",
-                "StatusDownloading",
-                "StatusProcessing",
-                "StatusUploading",
-                "StatusCompleted",
-                "StatusSkipped",
-                "StatusFailed"
-            ]
+        "/admin/users/{userID}/weight": {
+            "put": {
+                "description": "Set a user's share of the workers relative to other users. Waiting jobs are served in fair share order, and a user with weight 2 is served about twice as often as one with the default of 1 (admin only)",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Set user weight",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New weight",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.SetUserWeightRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/announcements": {
+            "get": {
+                "description": "List unexpired deployment-wide announcements, oldest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "announcements"
+                ],
+                "summary": "Get announcements",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.GetAnnouncementsResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Publish a deployment-wide announcement (admin only). severity is one of info, warning or critical",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "announcements"
+                ],
+                "summary": "Create announcement",
+                "parameters": [
+                    {
+                        "description": "Announcement",
+                        "name": "announcement",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.CreateAnnouncementRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/queue.Announcement"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/announcements/{id}": {
+            "delete": {
+                "description": "Remove a deployment-wide announcement before it expires (admin only)",
+                "tags": [
+                    "announcements"
+                ],
+                "summary": "Delete announcement",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Announcement ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/archive": {
+            "get": {
+                "description": "List the episodes that dropped out of the authenticated user's feed and were moved to the archive folder instead of deleted, most recently archived first. They are deleted for good once ARCHIVE_RETENTION_DAYS pass",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "archive"
+                ],
+                "summary": "Get archived episodes",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.GetArchiveResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/archive/{fileID}/restore": {
+            "post": {
+                "description": "Enqueue a job that moves an archived episode back and republishes it in the feed as it was, without processing it again. Like any episode outside the playlist, the next run drops it again unless it is back in the playlist or retained",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "archive"
+                ],
+                "summary": "Restore archived episode",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File ID of the archived episode",
+                        "name": "fileID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.RestoreEpisodeResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/google": {
+            "post": {
+                "description": "Exchange a Google authorization code for an ID token to use as the bearer token, and store the user's Google tokens so jobs can run on their behalf. Only available when AUTH_PROVIDER is google",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Sign in with Google",
+                "parameters": [
+                    {
+                        "description": "Authorization code",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.GoogleLoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.GoogleLoginResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/backup/register": {
+            "post": {
+                "description": "Queues a job for a backup the browser uploaded through an upload session. Files over the upload limit are rejected with 413, and files that aren't a ZIP holding a Podcast Addict SQLite database with 422. Registering the same file again returns the job it already created",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "backup"
+                ],
+                "summary": "Register uploaded backup",
+                "parameters": [
+                    {
+                        "description": "Uploaded backup",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.RegisterBackupRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.BackupUploadResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.BackupUploadResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.BackupUploadResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.BackupUploadResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.BackupUploadResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.BackupUploadResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.BackupUploadResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.BackupUploadResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/backup/session": {
+            "post": {
+                "description": "Creates a Google Drive resumable upload session in the backup folder with the user's token. The browser PUTs the file to upload_url itself, then registers the file ID Drive returns with POST /backup/register",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "backup"
+                ],
+                "summary": "Start direct backup upload",
+                "parameters": [
+                    {
+                        "description": "Backup to upload",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.CreateUploadSessionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.CreateUploadSessionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/backup/upload": {
+            "post": {
+                "description": "Uploads a backup file to be processed. Files that aren't a ZIP holding a Podcast Addict SQLite database are rejected with 422. Repeat submissions of the same Idempotency-Key, or of the same file when no key is given, within 24 hours return the job the first one created instead of queueing another, unless that job failed",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "backup"
+                ],
+                "summary": "Upload backup file",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Backup file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Note shown with the job in listings",
+                        "name": "label",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Client chosen key identifying this submission across retries",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.BackupUploadResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.BackupUploadResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.BackupUploadResponse"
+                        }
+                    },
+                    "408": {
+                        "description": "Request Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.BackupUploadResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.BackupUploadResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.BackupUploadResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.BackupUploadResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/capabilities": {
+            "get": {
+                "description": "List the features enabled for this deployment and the authenticated user so clients can render the right options",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "capabilities"
+                ],
+                "summary": "Get capabilities",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.CapabilitiesResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/episodes/{id}/audio": {
+            "get": {
+                "description": "Redirects an episode link published in a feed to where storage serves the file. Links are signed, so only episodes cobblepod published are redirected",
+                "tags": [
+                    "feed"
+                ],
+                "summary": "Get episode audio",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Episode file ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Link signature",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "Found"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/events": {
+            "get": {
+                "description": "Streams job created, started and finished, item updated and feed updated events for the authenticated user",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "events"
+                ],
+                "summary": "Stream events",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/queue.Event"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/feed": {
+            "get": {
+                "description": "Returns the secret URL podcast apps subscribe to, creating it the first time",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "feed"
+                ],
+                "summary": "Get feed URL",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.FeedLinkResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/feed/rotate": {
+            "post": {
+                "description": "Replaces the secret feed URL, for when it leaked. Podcast apps subscribed to the old URL must subscribe again",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "feed"
+                ],
+                "summary": "Rotate feed URL",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.FeedLinkResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/feed/stats": {
+            "get": {
+                "description": "Lists the episodes in the user's feed with how often podcast apps downloaded them, most downloaded first. Only downloads through episode links served by cobblepod are counted, see EPISODE_BASE_URL",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "feed"
+                ],
+                "summary": "Feed stats",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.FeedStatsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/feed/{slug}": {
+            "get": {
+                "description": "Serves the latest RSS feed of the user the slug belongs to. The slug is the only credential, see GET /feed. Supports conditional requests with ETag and Last-Modified",
+                "produces": [
+                    "application/rss+xml"
+                ],
+                "tags": [
+                    "feed"
+                ],
+                "summary": "Get feed",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Feed slug",
+                        "name": "slug",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/history": {
+            "delete": {
+                "description": "Deletes every job the user has, with its items and timeline, then the episodes and feed stored for them. Files stored before uploads were tagged aren't found. Settings, clips, backups and API keys are kept. Fails while a job is running",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "history"
+                ],
+                "summary": "Delete history",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.DeleteHistoryResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs": {
+            "get": {
+                "description": "Get a page of jobs for the authenticated user, newest first, optionally filtered by status, label and creation date",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Get jobs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job status filter: active (default), all, completed, completed_with_errors or failed",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only jobs whose label contains this text (case-insensitive)",
+                        "name": "label",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only jobs created at or after this RFC 3339 time",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only jobs created at or before this RFC 3339 time",
+                        "name": "until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort by creation time: desc (default) or asc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 50, max 200)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Jobs to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.GetJobsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/search": {
+            "get": {
+                "description": "Find the authenticated user's jobs, newest first, whose filename or episode titles contain every word of q, such as the job that processed an episode. A word may also match the start of a job's status, e.g. \"failed\". Searches all jobs unless status is given; the other filters and paging work as for GET /jobs",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Search jobs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Words to search for (case-insensitive)",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Job status filter: all (default), active, completed, completed_with_errors or failed",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only jobs whose label contains this text (case-insensitive)",
+                        "name": "label",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only jobs created at or after this RFC 3339 time",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only jobs created at or before this RFC 3339 time",
+                        "name": "until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort by creation time: desc (default) or asc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 50, max 200)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Jobs to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.GetJobsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/{id}/events": {
+            "get": {
+                "description": "With history=true, returns every event recorded for the job, oldest first: when it was enqueued, started and finished, each item status change with the error of failed items, and feed uploads. Otherwise streams the job's events as they happen over Server-Sent Events",
+                "produces": [
+                    "application/json",
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Job events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return the recorded timeline instead of streaming",
+                        "name": "history",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.JobTimelineResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/{id}/items/{itemID}/retry": {
+            "post": {
+                "description": "Enqueue a job that processes only this failed item. Items the original job already published are kept in the feed without being processed again",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Retry failed item",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Item ID",
+                        "name": "itemID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.RetryJobItemResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/{id}/logs": {
+            "get": {
+                "description": "Returns the info, warning and error lines the worker logged while running the job, oldest first, up to the most recent 1000. The log is kept as long as the job",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Job log",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.JobLogsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/settings": {
+            "get": {
+                "description": "Get the authenticated user's settings",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "settings"
+                ],
+                "summary": "Get settings",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/queue.UserSettings"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace the authenticated user's settings. time_zone must be an IANA zone name such as America/Toronto, speed must be within the range from /capabilities and output_format one of its output_formats. speed_profiles process podcasts, matched by the show name starting their episode titles or by feed URL, at their own speed and silence trimming, and skip_rules cut the start, end or named chapters of their episodes. Zero values use the deployment defaults",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "settings"
+                ],
+                "summary": "Update settings",
+                "parameters": [
+                    {
+                        "description": "User settings",
+                        "name": "settings",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/queue.UserSettings"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/queue.UserSettings"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/settings/api-keys": {
+            "get": {
+                "description": "List the authenticated user's API keys, oldest first. Keys themselves are never returned, only a hint of their first characters",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "settings"
+                ],
+                "summary": "List API keys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.GetAPIKeysResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Issue an API key for scripts to upload backups and query jobs with. Send it as a bearer token; it is only shown in this response",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "settings"
+                ],
+                "summary": "Create API key",
+                "parameters": [
+                    {
+                        "description": "Key name",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.CreateAPIKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.CreateAPIKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/settings/api-keys/{id}": {
+            "delete": {
+                "description": "Revoke an API key; requests using it are rejected from then on",
+                "tags": [
+                    "settings"
+                ],
+                "summary": "Revoke API key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API key ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/settings/clips/{clip}": {
+            "put": {
+                "description": "Stores a short recording that later jobs join before (intro) or after (outro) every episode of the feed, replacing any earlier one. Episodes are re-processed with it on the next job. Clips longer than a minute or that aren't audio are rejected with 422",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "settings"
+                ],
+                "summary": "Upload intro or outro",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "intro or outro",
+                        "name": "clip",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "Audio clip",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.ClipResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Removes the clip; episodes are re-processed without it on the next job",
+                "tags": [
+                    "settings"
+                ],
+                "summary": "Delete intro or outro",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "intro or outro",
+                        "name": "clip",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/settings/playlist-rules": {
+            "get": {
+                "description": "Get the rules that select the authenticated user's episodes from their backup's subscriptions. 404 means episodes come from the backup's playlist",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "settings"
+                ],
+                "summary": "Get playlist rules",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/queue.PlaylistRules"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Select the authenticated user's episodes from the shows subscribed to in their backup instead of its playlist, from the next backup processed. episodes_per_show takes the newest episodes of each show; order is one of newest, oldest, shortest and longest; max_minutes limits the total remaining audio",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "settings"
+                ],
+                "summary": "Update playlist rules",
+                "parameters": [
+                    {
+                        "description": "Playlist rules",
+                        "name": "rules",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/queue.PlaylistRules"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/queue.PlaylistRules"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Go back to taking the authenticated user's episodes from their backup's playlist",
+                "tags": [
+                    "settings"
+                ],
+                "summary": "Delete playlist rules",
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/settings/playlist-url": {
+            "get": {
+                "description": "Get the M3U8 playlist URL the authenticated user's episodes are polled from. 404 means no playlist URL is registered",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "settings"
+                ],
+                "summary": "Get playlist URL",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/queue.PlaylistURL"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Register an M3U8 playlist published over HTTP on a public host, e.g. exported by another app. The worker polls it with conditional requests and processes it whenever it changes, starting with the next poll",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "settings"
+                ],
+                "summary": "Update playlist URL",
+                "parameters": [
+                    {
+                        "description": "Playlist URL",
+                        "name": "playlist",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/queue.PlaylistURL"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/queue.PlaylistURL"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Stop polling the authenticated user's playlist URL",
+                "tags": [
+                    "settings"
+                ],
+                "summary": "Delete playlist URL",
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/usage": {
+            "get": {
+                "description": "Adds up the files cobblepod stored for the user, found by the tags they were uploaded with. Files stored before uploads were tagged aren't counted until a job replaces them",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "usage"
+                ],
+                "summary": "Storage usage",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.UsageResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/drive": {
+            "post": {
+                "description": "Ask Google Drive to push change notifications for the authenticated user so new backups are processed immediately. Drive channels expire, so clients should re-register before the returned expiration",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Register Drive webhook",
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.RegisterDriveWebhookResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/ws": {
+            "get": {
+                "description": "Upgrades to a WebSocket that receives a queue.Event message for every job created, started and finished, item status change and feed update. Browsers that can't set headers on WebSocket requests may pass the bearer token as the access_token query parameter.",
+                "tags": [
+                    "events"
+                ],
+                "summary": "Live job updates",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer token, for clients that can't set the Authorization header",
+                        "name": "access_token",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "Switching Protocols",
+                        "schema": {
+                            "$ref": "#/definitions/queue.Event"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "endpoints.BackupUploadResponse": {
+            "type": "object",
+            "properties": {
+                "duplicate": {
+                    "description": "Duplicate is set when the upload repeats an earlier submission and JobID is the earlier job",
+                    "type": "boolean"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "file_id": {
+                    "type": "string"
+                },
+                "job_id": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "endpoints.CapabilitiesResponse": {
+            "type": "object",
+            "properties": {
+                "admin": {
+                    "type": "boolean"
+                },
+                "drive_webhooks": {
+                    "type": "boolean"
+                },
+                "normalization": {
+                    "type": "boolean"
+                },
+                "output_formats": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "speed": {
+                    "$ref": "#/definitions/endpoints.SpeedRange"
+                },
+                "storage_backends": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "transcription": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "endpoints.ClipResponse": {
+            "type": "object",
+            "properties": {
+                "clip": {
+                    "type": "string"
+                },
+                "duration_seconds": {
+                    "type": "number"
+                },
+                "file_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "endpoints.CreateAPIKeyRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "endpoints.CreateAPIKeyResponse": {
+            "type": "object",
+            "properties": {
+                "api_key": {
+                    "$ref": "#/definitions/queue.APIKey"
+                },
+                "key": {
+                    "type": "string"
+                }
+            }
+        },
+        "endpoints.CreateAnnouncementRequest": {
+            "type": "object",
+            "required": [
+                "expires_at",
+                "message",
+                "severity"
+            ],
+            "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "severity": {
+                    "$ref": "#/definitions/queue.AnnouncementSeverity"
+                }
+            }
+        },
+        "endpoints.CreateUploadSessionRequest": {
+            "type": "object",
+            "required": [
+                "filename"
+            ],
+            "properties": {
+                "filename": {
+                    "type": "string"
+                },
+                "size": {
+                    "description": "Bytes; lets Drive reject an incomplete upload",
+                    "type": "integer"
+                }
+            }
+        },
+        "endpoints.CreateUploadSessionResponse": {
+            "type": "object",
+            "properties": {
+                "upload_url": {
+                    "type": "string"
+                }
+            }
+        },
+        "endpoints.CutResponse": {
+            "type": "object",
+            "properties": {
+                "end": {
+                    "description": "Nanoseconds into the source",
+                    "type": "integer"
+                },
+                "reason": {
+                    "description": "\"start\", \"end\" or the title of the chapter",
+                    "type": "string"
+                },
+                "start": {
+                    "description": "Nanoseconds into the source",
+                    "type": "integer"
+                }
+            }
+        },
+        "endpoints.DeleteHistoryResponse": {
+            "type": "object",
+            "properties": {
+                "files_deleted": {
+                    "type": "integer"
+                },
+                "jobs_deleted": {
+                    "type": "integer"
+                }
+            }
+        },
+        "endpoints.EpisodeStats": {
+            "type": "object",
+            "properties": {
+                "downloads": {
+                    "type": "integer"
+                },
+                "file_id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "user_agents": {
+                    "description": "Downloads by client app",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                }
+            }
+        },
+        "endpoints.FeedLinkResponse": {
+            "type": "object",
+            "properties": {
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "endpoints.FeedStatsResponse": {
+            "type": "object",
+            "properties": {
+                "episodes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/endpoints.EpisodeStats"
+                    }
+                }
+            }
+        },
+        "endpoints.GetAPIKeysResponse": {
+            "type": "object",
+            "properties": {
+                "api_keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/queue.APIKey"
+                    }
+                }
+            }
+        },
+        "endpoints.GetAnnouncementsResponse": {
+            "type": "object",
+            "properties": {
+                "announcements": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/queue.Announcement"
+                    }
+                }
+            }
+        },
+        "endpoints.GetArchiveResponse": {
+            "type": "object",
+            "properties": {
+                "episodes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/queue.ArchivedEpisode"
+                    }
+                }
+            }
+        },
+        "endpoints.GetJobsResponse": {
+            "type": "object",
+            "properties": {
+                "jobs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/endpoints.JobResponse"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "description": "Jobs matching the filters across all pages",
+                    "type": "integer"
+                }
+            }
+        },
+        "endpoints.GetRunningJobsResponse": {
+            "type": "object",
+            "properties": {
+                "jobs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/endpoints.JobResponse"
+                    }
+                }
+            }
+        },
+        "endpoints.GetUserLocksResponse": {
+            "type": "object",
+            "properties": {
+                "locks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/queue.UserLock"
+                    }
+                }
+            }
+        },
+        "endpoints.GoogleLoginRequest": {
+            "type": "object",
+            "required": [
+                "code"
+            ],
+            "properties": {
+                "code": {
+                    "type": "string"
+                }
+            }
+        },
+        "endpoints.GoogleLoginResponse": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "id_token": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "endpoints.JobItemResponse": {
+            "type": "object",
+            "properties": {
+                "content_type": {
+                    "type": "string"
+                },
+                "cuts": {
+                    "description": "Parts of the source the user's skip rules left out",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/endpoints.CutResponse"
+                    }
+                },
+                "drive_file_id": {
+                    "description": "Storage key of the uploaded episode",
+                    "type": "string"
+                },
+                "duration": {
+                    "description": "Nanoseconds",
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "feed_url": {
+                    "description": "RSS feed of the show the episode came from, when known",
+                    "type": "string"
+                },
+                "guid": {
+                    "description": "Episode GUID in its show feed, when known",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "offset": {
+                    "description": "Nanoseconds",
+                    "type": "integer"
+                },
+                "position": {
+                    "description": "1-based place in the source playlist, when known",
+                    "type": "integer"
+                },
+                "progress": {
+                    "description": "Upload progress percentage while uploading",
+                    "type": "integer"
+                },
+                "source_url": {
+                    "type": "string"
+                },
+                "speed": {
+                    "description": "Playback speed the episode was encoded at",
+                    "type": "number"
+                },
+                "status": {
+                    "description": "pending, downloading, processing, uploading, completed, skipped, failed",
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "endpoints.JobLogsResponse": {
+            "type": "object",
+            "properties": {
+                "job_id": {
+                    "type": "string"
+                },
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/logging.Line"
+                    }
+                }
+            }
+        },
+        "endpoints.JobResponse": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "description": "Times a worker has picked up the job",
+                    "type": "integer"
+                },
+                "completed": {
+                    "description": "Items processed and uploaded",
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "estimated_completion": {
+                    "description": "EstimatedCompletion is when the job should finish, from the jobs ahead of\nit and how fast recent runs went; absent when there's no history to go on",
+                    "type": "string"
+                },
+                "fail_reason": {
+                    "description": "Set when job fails",
+                    "type": "string"
+                },
+                "failed": {
+                    "description": "Items that failed",
+                    "type": "integer"
+                },
+                "failed_items": {
+                    "description": "Set when job completes with errors",
+                    "type": "integer"
+                },
+                "feed_url": {
+                    "description": "Subscription URL of the feed the job published",
+                    "type": "string"
+                },
+                "file_id": {
+                    "type": "string"
+                },
+                "filename": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "in_progress": {
+                    "description": "Items being downloaded, processed or uploaded",
+                    "type": "integer"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/endpoints.JobItemResponse"
+                    }
+                },
+                "label": {
+                    "description": "User supplied note shown in listings",
+                    "type": "string"
+                },
+                "priority": {
+                    "description": "interactive jobs are dequeued first",
+                    "type": "string"
+                },
+                "retry_of": {
+                    "description": "Job whose failed items this job retries",
+                    "type": "string"
+                },
+                "run_at": {
+                    "description": "When a scheduled job becomes due",
+                    "type": "string"
+                },
+                "skipped": {
+                    "description": "Items reused from the existing feed",
+                    "type": "integer"
+                },
+                "status": {
+                    "description": "scheduled, queued, running, completed, completed_with_errors, failed",
+                    "type": "string"
+                },
+                "total_items": {
+                    "description": "Number of items in the job",
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "endpoints.JobTimelineResponse": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/queue.Event"
+                    }
+                },
+                "job_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "endpoints.RegisterBackupRequest": {
+            "type": "object",
+            "required": [
+                "file_id"
+            ],
+            "properties": {
+                "file_id": {
+                    "type": "string"
+                },
+                "label": {
+                    "type": "string"
+                }
+            }
+        },
+        "endpoints.RegisterDriveWebhookResponse": {
+            "type": "object",
+            "properties": {
+                "channel_id": {
+                    "type": "string"
+                },
+                "expiration": {
+                    "type": "string"
+                }
+            }
+        },
+        "endpoints.RestoreEpisodeResponse": {
+            "type": "object",
+            "properties": {
+                "job_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "endpoints.RetryJobItemResponse": {
+            "type": "object",
+            "properties": {
+                "job_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "endpoints.SetUserWeightRequest": {
+            "type": "object",
+            "required": [
+                "weight"
+            ],
+            "properties": {
+                "weight": {
+                    "description": "Share of the workers relative to the default of 1",
+                    "type": "number"
+                }
+            }
+        },
+        "endpoints.SpeedRange": {
+            "type": "object",
+            "properties": {
+                "default": {
+                    "type": "number"
+                },
+                "max": {
+                    "type": "number"
+                },
+                "min": {
+                    "type": "number"
+                }
+            }
+        },
+        "endpoints.UsageResponse": {
+            "type": "object",
+            "properties": {
+                "bytes": {
+                    "description": "Bytes and Files count everything stored for the user: episodes, the feed, clips and backups",
+                    "type": "integer"
+                },
+                "episodes": {
+                    "description": "Episodes is the number of processed episodes stored for the feed",
+                    "type": "integer"
+                },
+                "files": {
+                    "type": "integer"
+                },
+                "last_feed_update": {
+                    "description": "LastFeedUpdate is when the feed was last written; omitted before the first job",
+                    "type": "string"
+                }
+            }
+        },
+        "logging.Line": {
+            "type": "object",
+            "properties": {
+                "attrs": {
+                    "description": "Attrs are the line's attributes meant for users, see userAttrs",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "level": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "queue.APIKey": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "hint": {
+                    "description": "The first characters of the key",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "queue.Announcement": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "severity": {
+                    "$ref": "#/definitions/queue.AnnouncementSeverity"
+                }
+            }
+        },
+        "queue.AnnouncementSeverity": {
+            "type": "string",
+            "enum": [
+                "info",
+                "warning",
+                "critical"
+            ],
+            "x-enum-varnames": [
+                "SeverityInfo",
+                "SeverityWarning",
+                "SeverityCritical"
+            ]
+        },
+        "queue.ArchivedEpisode": {
+            "type": "object",
+            "properties": {
+                "archived_at": {
+                    "type": "string"
+                },
+                "episode": {
+                    "description": "Episode is the feed episode as the processor recorded it, so restoring\nrepublishes it unchanged",
+                    "type": "object"
+                },
+                "file_id": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "queue.Event": {
+            "type": "object",
+            "properties": {
+                "item_id": {
+                    "type": "string"
+                },
+                "job_id": {
+                    "type": "string"
+                },
+                "message": {
+                    "description": "Why an item or job failed",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "type": {
+                    "$ref": "#/definitions/queue.EventType"
+                }
+            }
+        },
+        "queue.EventType": {
+            "type": "string",
+            "enum": [
+                "job.created",
+                "job.started",
+                "job.finished",
+                "item.updated",
+                "feed.updated"
+            ],
+            "x-enum-varnames": [
+                "EventJobCreated",
+                "EventJobStarted",
+                "EventJobFinished",
+                "EventItemUpdated",
+                "EventFeedUpdated"
+            ]
+        },
+        "queue.PlaylistOrder": {
+            "type": "string",
+            "enum": [
+                "newest",
+                "oldest",
+                "shortest",
+                "longest"
+            ],
+            "x-enum-varnames": [
+                "OrderNewest",
+                "OrderOldest",
+                "OrderShortest",
+                "OrderLongest"
+            ]
+        },
+        "queue.PlaylistRules": {
+            "type": "object",
+            "properties": {
+                "episodes_per_show": {
+                    "description": "EpisodesPerShow takes this many of the newest episodes of each show",
+                    "type": "integer"
+                },
+                "max_minutes": {
+                    "description": "MaxMinutes stops adding episodes once their remaining audio would exceed\nthis many minutes; zero means no limit",
+                    "type": "integer"
+                },
+                "order": {
+                    "description": "Order is how the selected episodes are ordered; empty means newest first",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/queue.PlaylistOrder"
+                        }
+                    ]
+                },
+                "shows": {
+                    "description": "Shows limits the selection to these shows, by name; empty means every subscribed show",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "unplayed_only": {
+                    "description": "UnplayedOnly leaves out episodes marked as played",
+                    "type": "boolean"
+                }
+            }
+        },
+        "queue.PlaylistURL": {
+            "type": "object",
+            "properties": {
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "queue.QueueStats": {
+            "type": "object",
+            "properties": {
+                "dead_lettered": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "priority": {
+                    "type": "integer"
+                },
+                "running": {
+                    "type": "integer"
+                },
+                "running_users": {
+                    "type": "integer"
+                },
+                "scheduled": {
+                    "type": "integer"
+                },
+                "succeeded": {
+                    "type": "integer"
+                },
+                "waiting": {
+                    "type": "integer"
+                }
+            }
+        },
+        "queue.SkipRule": {
+            "type": "object",
+            "properties": {
+                "chapters": {
+                    "description": "Chapters cuts the chapters with these titles, ignoring case, e.g. \"Ads\"",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "podcast": {
+                    "description": "Podcast is the show's name or feed URL, as for SpeedProfile",
+                    "type": "string"
+                },
+                "skip_end_seconds": {
+                    "type": "integer"
+                },
+                "skip_start_seconds": {
+                    "description": "SkipStartSeconds and SkipEndSeconds cut this much from the start and end\nof every episode, e.g. a recurring intro or sponsor read",
+                    "type": "integer"
+                }
+            }
+        },
+        "queue.SpeedProfile": {
+            "type": "object",
+            "properties": {
+                "podcast": {
+                    "description": "Podcast is the show's name, as it starts episode titles (\"\u003cshow\u003e - \u003cepisode\u003e\"),\nor its feed URL",
+                    "type": "string"
+                },
+                "speed": {
+                    "description": "Speed is the podcast's speed; zero means the user's Speed",
+                    "type": "number"
+                },
+                "trim_silence": {
+                    "description": "TrimSilence, when set, replaces the user's TrimSilence for the podcast",
+                    "type": "boolean"
+                }
+            }
+        },
+        "queue.UserLock": {
+            "type": "object",
+            "properties": {
+                "feed_lock_expiry": {
+                    "description": "Seconds until the feed lock expires",
+                    "type": "integer"
+                },
+                "feed_locked": {
+                    "type": "boolean"
+                },
+                "running_jobs": {
+                    "type": "integer"
+                },
+                "running_slots": {
+                    "type": "integer"
+                },
+                "stuck": {
+                    "type": "boolean"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "queue.UserSettings": {
+            "type": "object",
+            "properties": {
+                "bitrate_kbps": {
+                    "description": "BitrateKbps is the audio bitrate episodes are encoded at; zero leaves it to the encoder",
+                    "type": "integer"
+                },
+                "feed_title": {
+                    "description": "FeedTitle replaces the default channel title of the feed",
+                    "type": "string"
+                },
+                "job_retention_days": {
+                    "description": "JobRetentionDays keeps finished jobs in the history for this many days;\nzero means the deployment's job retention",
+                    "type": "integer"
+                },
+                "mono": {
+                    "description": "Mono downmixes episodes to a single channel, which suits speech",
+                    "type": "boolean"
+                },
+                "notification_email": {
+                    "description": "NotificationEmail receives a summary when a job finishes; empty means the\ndeployment's notification email",
+                    "type": "string"
+                },
+                "notify_events": {
+                    "description": "NotifyEvents lists the events to notify on, comma separated; empty means\njob.completed and job.failed",
+                    "type": "string"
+                },
+                "ntfy_topic": {
+                    "description": "NtfyTopic is the topic on the deployment's ntfy server notifications are published to",
+                    "type": "string"
+                },
+                "output_format": {
+                    "description": "OutputFormat is the extension episodes are encoded to (e.g. \"m4a\"); empty means mp3",
+                    "type": "string"
+                },
+                "poll_schedule": {
+                    "description": "PollSchedule is a cron expression of when the user's playlist URL is\npolled and their storage changes are picked up, in their time zone (e.g.\n\"*/15 6-23 * * *\"); empty means the deployment's poll schedule for the\nplaylist URL and storage changes as they come",
+                    "type": "string"
+                },
+                "retention_days": {
+                    "description": "RetentionDays keeps episodes that left the playlist in the feed for this many\ndays; zero removes them on the next run",
+                    "type": "integer"
+                },
+                "skip_rules": {
+                    "description": "SkipRules cut parts of some podcasts' episodes, such as recurring intros\nor ad chapters; stored as JSON in the skip_rules field",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/queue.SkipRule"
+                    }
+                },
+                "speed": {
+                    "description": "Speed is the playback speed episodes are processed at; zero means config.DefaultSpeed",
+                    "type": "number"
+                },
+                "speed_profiles": {
+                    "description": "SpeedProfiles process some podcasts at their own speed and silence\ntrimming instead of Speed and TrimSilence; stored as JSON in the\nspeed_profiles field",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/queue.SpeedProfile"
+                    }
+                },
+                "spoken_preamble": {
+                    "description": "SpokenPreamble starts every episode with a synthesized announcement of its\nshow, title and publish date, when the deployment has TTS configured",
+                    "type": "boolean"
+                },
+                "telegram_chat_id": {
+                    "description": "TelegramChatID is the chat the deployment's Telegram bot messages",
+                    "type": "string"
+                },
+                "time_zone": {
+                    "description": "TimeZone is an IANA zone name (e.g. \"America/Toronto\"); empty means UTC",
+                    "type": "string"
+                },
+                "transcripts": {
+                    "description": "Transcripts publishes a transcript with every processed episode, when the\ndeployment has transcription configured",
+                    "type": "boolean"
+                },
+                "trim_silence": {
+                    "description": "TrimSilence removes long silences while processing",
+                    "type": "boolean"
+                },
+                "webhook_url": {
+                    "description": "WebhookURL is an https URL receiving a JSON POST for every notification",
+                    "type": "string"
+                }
+            }
         }
     }
 }`
@@ -0,0 +1,149 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"time"
+)
+
+// ErrSimulated is returned by the calls a Fake fails on purpose
+var ErrSimulated = errors.New("simulated failure")
+
+// FakeEncoding tags the encoding recorded with episodes a Fake made, so their
+// placeholders are never reused in place of real audio
+const FakeEncoding = "fake"
+
+// Fake is a Processor that simulates downloading and encoding without the
+// network or FFmpeg, for tests, load tests and dry runs. It writes small
+// placeholder files in its output format. Which calls fail depends only on the
+// seed and what each call is given, not on the order of the calls, so
+// concurrent runs fail the same episodes every time.
+type Fake struct {
+	// DownloadLatency and EncodeLatency are how long each call takes
+	DownloadLatency time.Duration
+	EncodeLatency   time.Duration
+	// DownloadFailureRate and EncodeFailureRate are the fractions of calls,
+	// from 0 to 1, that fail with ErrSimulated
+	DownloadFailureRate float64
+	EncodeFailureRate   float64
+
+	seed   int64
+	format Format
+}
+
+var _ Processor = (*Fake)(nil)
+
+// NewFake returns a fake processor that encodes to MP3 and never fails
+func NewFake(seed int64) *Fake {
+	return &Fake{seed: seed, format: FormatMP3}
+}
+
+// SetOutputFormat sets the extension of the placeholders ProcessAudio writes
+func (f *Fake) SetOutputFormat(format Format) {
+	f.format = format
+}
+
+// WithTrimSilence returns f; trimming doesn't change the placeholders
+func (f *Fake) WithTrimSilence(trim bool) Processor {
+	return f
+}
+
+// SetBitrate is accepted but doesn't change the placeholders
+func (f *Fake) SetBitrate(kbps int) {}
+
+// SetMono is accepted but doesn't change the placeholders
+func (f *Fake) SetMono(mono bool) {}
+
+// SetClips is accepted but doesn't change the placeholders
+func (f *Fake) SetClips(intro, outro string) {}
+
+// ProbeSource reports nothing about the source, so nothing is copied through
+func (f *Fake) ProbeSource(ctx context.Context, url string) (*SourceProbe, error) {
+	return &SourceProbe{}, nil
+}
+
+// ProbeFile reports nothing about a placeholder, so the playlist's durations are used
+func (f *Fake) ProbeFile(path string) (*FileProbe, error) {
+	return &FileProbe{}, nil
+}
+
+// DownloadFile writes a placeholder naming url to a temp file
+func (f *Fake) DownloadFile(ctx context.Context, url string) (string, error) {
+	if err := sleep(ctx, f.DownloadLatency); err != nil {
+		return "", err
+	}
+	if f.fails("download", url, f.DownloadFailureRate) {
+		return "", fmt.Errorf("%w: downloading %s", ErrSimulated, url)
+	}
+	return writePlaceholder("cobblepod_fake_*.mp3", "source "+url+"\n")
+}
+
+// ProcessAudio writes a placeholder recording the input and how it was encoded
+func (f *Fake) ProcessAudio(ctx context.Context, inputPath, preamble string, speed float64, offset time.Duration, cuts []Segment) (string, error) {
+	input, err := os.ReadFile(inputPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+	if err := sleep(ctx, f.EncodeLatency); err != nil {
+		return "", err
+	}
+	if f.fails("encode", string(input), f.EncodeFailureRate) {
+		return "", fmt.Errorf("%w: encoding %s", ErrSimulated, inputPath)
+	}
+	encoded := fmt.Sprintf("%sspeed %g offset %s cuts %d\n", input, speed, offset, len(cuts))
+	return writePlaceholder("cobblepod_fake_processed_*."+f.format.Extension, encoded)
+}
+
+// TrimAudio writes a placeholder recording the input and the trim
+func (f *Fake) TrimAudio(ctx context.Context, inputPath string, start time.Duration) (string, error) {
+	input, err := os.ReadFile(inputPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+	if err := sleep(ctx, f.EncodeLatency); err != nil {
+		return "", err
+	}
+	if f.fails("trim", string(input), f.EncodeFailureRate) {
+		return "", fmt.Errorf("%w: trimming %s", ErrSimulated, inputPath)
+	}
+	return writePlaceholder("cobblepod_fake_trimmed_*."+f.format.Extension, fmt.Sprintf("%strim %s\n", input, start))
+}
+
+// sleep waits for d, or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fails reports whether the call for key is one of the rate that fail
+func (f *Fake) fails(call, key string, rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d\x00%s\x00%s", f.seed, call, key)
+	return float64(h.Sum64()>>11)/(1<<53) < rate
+}
+
+// writePlaceholder writes content to a new temp file named after pattern
+func writePlaceholder(pattern, content string) (string, error) {
+	file, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	return file.Name(), nil
+}
@@ -0,0 +1,99 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFakeProcessesPlaceholders(t *testing.T) {
+	m4a, _ := LookupFormat("m4a")
+	fake := NewFake(1)
+	fake.SetOutputFormat(m4a)
+
+	downloaded, err := fake.DownloadFile(context.Background(), "https://example.com/episode.mp3")
+	if err != nil {
+		t.Fatalf("Unexpected download error: %v", err)
+	}
+	defer os.Remove(downloaded)
+
+	processed, err := fake.ProcessAudio(context.Background(), downloaded, "", 1.5, time.Minute, nil)
+	if err != nil {
+		t.Fatalf("Unexpected encode error: %v", err)
+	}
+	defer os.Remove(processed)
+	if FormatForPath(processed) != m4a {
+		t.Errorf("Expected an m4a placeholder, got %s", processed)
+	}
+	content, err := os.ReadFile(processed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "https://example.com/episode.mp3") || !strings.Contains(string(content), "speed 1.5") {
+		t.Errorf("Expected the placeholder to record its source and encoding, got %q", content)
+	}
+
+	trimmed, err := fake.TrimAudio(context.Background(), processed, 30*time.Second)
+	if err != nil {
+		t.Fatalf("Unexpected trim error: %v", err)
+	}
+	os.Remove(trimmed)
+}
+
+func TestFakeFailsDeterministically(t *testing.T) {
+	failures := func(seed int64) []string {
+		fake := NewFake(seed)
+		fake.DownloadFailureRate = 0.5
+		var failed []string
+		for i := range 100 {
+			url := fmt.Sprintf("https://example.com/%d.mp3", i)
+			path, err := fake.DownloadFile(context.Background(), url)
+			if errors.Is(err, ErrSimulated) {
+				failed = append(failed, url)
+				continue
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			os.Remove(path)
+		}
+		return failed
+	}
+
+	first := failures(7)
+	if len(first) < 30 || len(first) > 70 {
+		t.Errorf("Expected about half the downloads to fail, got %d", len(first))
+	}
+	if second := failures(7); strings.Join(first, " ") != strings.Join(second, " ") {
+		t.Error("Expected the same seed to fail the same downloads")
+	}
+	if other := failures(8); strings.Join(first, " ") == strings.Join(other, " ") {
+		t.Error("Expected another seed to fail other downloads")
+	}
+
+	fake := NewFake(7)
+	fake.EncodeFailureRate = 1
+	downloaded, err := fake.DownloadFile(context.Background(), "https://example.com/episode.mp3")
+	if err != nil {
+		t.Fatalf("Unexpected download error: %v", err)
+	}
+	defer os.Remove(downloaded)
+	if _, err := fake.ProcessAudio(context.Background(), downloaded, "", 1, 0, nil); !errors.Is(err, ErrSimulated) {
+		t.Errorf("Expected a simulated encode failure, got %v", err)
+	}
+}
+
+func TestFakeHonorsContext(t *testing.T) {
+	fake := NewFake(1)
+	fake.DownloadLatency = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := fake.DownloadFile(ctx, "https://example.com/episode.mp3"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the canceled download to stop waiting, got %v", err)
+	}
+}
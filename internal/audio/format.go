@@ -0,0 +1,57 @@
+package audio
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Format describes an encoded audio container: the filename extension it is
+// stored under and the content type players need to play it back
+type Format struct {
+	Extension   string
+	ContentType string
+}
+
+// FormatMP3 is the format FFmpeg produces today and the fallback for unknown files
+var FormatMP3 = Format{Extension: "mp3", ContentType: "audio/mpeg"}
+
+// knownFormats maps output extensions to their formats
+var knownFormats = map[string]Format{
+	"mp3":  FormatMP3,
+	"m4a":  {Extension: "m4a", ContentType: "audio/mp4"},
+	"aac":  {Extension: "aac", ContentType: "audio/aac"},
+	"ogg":  {Extension: "ogg", ContentType: "audio/ogg"},
+	"opus": {Extension: "opus", ContentType: "audio/ogg"},
+}
+
+// FormatForPath returns the format of an encoded file based on its extension,
+// falling back to FormatMP3 when the extension is missing or unrecognized
+func FormatForPath(path string) Format {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if format, ok := knownFormats[ext]; ok {
+		return format
+	}
+	return FormatMP3
+}
+
+// LookupFormat returns the output format for an extension such as "m4a"
+func LookupFormat(extension string) (Format, bool) {
+	format, ok := knownFormats[strings.ToLower(extension)]
+	return format, ok
+}
+
+// OutputFormats lists the extensions FFmpeg can encode to, sorted
+func OutputFormats() []string {
+	extensions := make([]string, 0, len(knownFormats))
+	for ext := range knownFormats {
+		extensions = append(extensions, ext)
+	}
+	sort.Strings(extensions)
+	return extensions
+}
+
+// Filename returns the upload filename for an episode title in this format
+func (f Format) Filename(title string) string {
+	return title + "." + f.Extension
+}
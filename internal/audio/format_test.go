@@ -0,0 +1,41 @@
+package audio
+
+import "testing"
+
+func TestFormatForPath(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want Format
+	}{
+		{name: "mp3", path: "/tmp/cobblepod_processed_1.mp3", want: FormatMP3},
+		{name: "uppercase extension", path: "/tmp/episode.M4A", want: Format{Extension: "m4a", ContentType: "audio/mp4"}},
+		{name: "opus", path: "episode.opus", want: Format{Extension: "opus", ContentType: "audio/ogg"}},
+		{name: "unknown extension", path: "episode.bin", want: FormatMP3},
+		{name: "no extension", path: "episode", want: FormatMP3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatForPath(tt.path); got != tt.want {
+				t.Errorf("FormatForPath(%q) = %+v, want %+v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatFilename(t *testing.T) {
+	got := Format{Extension: "m4a", ContentType: "audio/mp4"}.Filename("Episode 1")
+	if got != "Episode 1.m4a" {
+		t.Errorf("Filename() = %q, want %q", got, "Episode 1.m4a")
+	}
+}
+
+func TestLookupFormat(t *testing.T) {
+	if format, ok := LookupFormat("OPUS"); !ok || format.Extension != "opus" {
+		t.Errorf("LookupFormat(OPUS) = %+v, %v", format, ok)
+	}
+	if _, ok := LookupFormat("wav"); ok {
+		t.Error("LookupFormat(wav) should not find a format")
+	}
+}
@@ -0,0 +1,183 @@
+package audio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// probeTimeout bounds a source probe; it only transfers headers and a single byte
+const probeTimeout = 30 * time.Second
+
+// SourceProbe describes a remote audio file without downloading it
+type SourceProbe struct {
+	Size        int64  // Bytes, 0 when the server doesn't say
+	ContentType string // As reported by the server
+	RangeOK     bool   // The server honoured a byte range request
+}
+
+// Kbps estimates the average bitrate of the source given its duration, or 0
+// when either is unknown
+func (s *SourceProbe) Kbps(duration time.Duration) int {
+	if s.Size <= 0 || duration <= 0 {
+		return 0
+	}
+	return int(float64(s.Size*8) / duration.Seconds() / 1000)
+}
+
+// Format returns the format of the source, preferring the server's content type
+// over the URL's extension
+func (s *SourceProbe) Format(url string) Format {
+	contentType := strings.TrimSpace(strings.Split(s.ContentType, ";")[0])
+	for _, format := range knownFormats {
+		if strings.EqualFold(format.ContentType, contentType) {
+			return format
+		}
+	}
+	return FormatForPath(strings.Split(url, "?")[0])
+}
+
+// ProbeSource asks for the first byte of a source file to learn its size and
+// type. Servers that support ranges report the full size in Content-Range;
+// others fall back to Content-Length and the body is left unread.
+func (p *FFmpeg) ProbeSource(ctx context.Context, url string) (*SourceProbe, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Range", "bytes=0-0")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe source: %w", err)
+	}
+	defer resp.Body.Close()
+
+	probe := &SourceProbe{ContentType: resp.Header.Get("Content-Type")}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		probe.RangeOK = true
+		probe.Size = contentRangeSize(resp.Header.Get("Content-Range"))
+	case http.StatusOK:
+		probe.Size = resp.ContentLength
+	default:
+		return nil, fmt.Errorf("failed to probe source: HTTP %d", resp.StatusCode)
+	}
+	if probe.Size < 0 {
+		probe.Size = 0
+	}
+	return probe, nil
+}
+
+// contentRangeSize returns the complete length from a "bytes 0-0/12345" header
+func contentRangeSize(header string) int64 {
+	_, total, found := strings.Cut(header, "/")
+	if !found {
+		return 0
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(total), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// FileProbe describes a local audio file as ffprobe reads it
+type FileProbe struct {
+	Duration time.Duration
+	Kbps     int    // Average bitrate, 0 when ffprobe doesn't say
+	Codec    string // Codec of the first audio stream
+	Chapters []Chapter
+}
+
+// Chapter is a titled part of a file, as podcasts mark segments such as ads
+type Chapter struct {
+	Title      string
+	Start, End time.Duration
+}
+
+// Probe reads the actual duration, bitrate, codec and chapters of a downloaded file.
+// Playlists often list durations that are wrong or zero. ffprobe is the binary
+// to run.
+func Probe(ffprobe, path string) (*FileProbe, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, ffprobe,
+		"-v", "error",
+		"-select_streams", "a:0",
+		"-show_entries", "format=duration,bit_rate:stream=codec_name",
+		"-show_chapters",
+		"-of", "json",
+		path,
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe error: %w", err)
+	}
+	return parseProbe(output)
+}
+
+// ProbeFile reads a downloaded file with ffprobe, see Probe
+func (p *FFmpeg) ProbeFile(path string) (*FileProbe, error) {
+	return Probe(p.ffprobePath, path)
+}
+
+// parseProbe reads ffprobe's JSON output
+func parseProbe(output []byte) (*FileProbe, error) {
+	var parsed struct {
+		Streams []struct {
+			CodecName string `json:"codec_name"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+			BitRate  string `json:"bit_rate"`
+		} `json:"format"`
+		Chapters []struct {
+			StartTime string `json:"start_time"`
+			EndTime   string `json:"end_time"`
+			Tags      struct {
+				Title string `json:"title"`
+			} `json:"tags"`
+		} `json:"chapters"`
+	}
+	if err := json.Unmarshal(output, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+	if len(parsed.Streams) == 0 {
+		return nil, fmt.Errorf("no audio stream found")
+	}
+
+	seconds, err := strconv.ParseFloat(parsed.Format.Duration, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid duration %q: %w", parsed.Format.Duration, err)
+	}
+	probe := &FileProbe{
+		Duration: time.Duration(seconds * float64(time.Second)),
+		Codec:    parsed.Streams[0].CodecName,
+	}
+	if bitRate, err := strconv.ParseInt(parsed.Format.BitRate, 10, 64); err == nil {
+		probe.Kbps = int(bitRate / 1000)
+	}
+	for _, chapter := range parsed.Chapters {
+		start, startErr := strconv.ParseFloat(chapter.StartTime, 64)
+		end, endErr := strconv.ParseFloat(chapter.EndTime, 64)
+		if startErr != nil || endErr != nil || end <= start {
+			continue
+		}
+		probe.Chapters = append(probe.Chapters, Chapter{
+			Title: strings.TrimSpace(chapter.Tags.Title),
+			Start: time.Duration(start * float64(time.Second)),
+			End:   time.Duration(end * float64(time.Second)),
+		})
+	}
+	return probe, nil
+}
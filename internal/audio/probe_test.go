@@ -0,0 +1,145 @@
+package audio
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"cobblepod/internal/config"
+)
+
+func TestProbeSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ranged.mp3":
+			if r.Header.Get("Range") != "bytes=0-0" {
+				t.Errorf("Expected a single byte range request, got %q", r.Header.Get("Range"))
+			}
+			w.Header().Set("Content-Type", "audio/mpeg")
+			w.Header().Set("Content-Range", "bytes 0-0/2400000")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte{0})
+		case "/plain.m4a":
+			w.Header().Set("Content-Length", "1200000")
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := NewFFmpeg(config.Defaults().Worker)
+	ctx := context.Background()
+
+	probe, err := p.ProbeSource(ctx, server.URL+"/ranged.mp3")
+	if err != nil {
+		t.Fatalf("ProbeSource() unexpected error: %v", err)
+	}
+	if !probe.RangeOK || probe.Size != 2400000 || probe.ContentType != "audio/mpeg" {
+		t.Errorf("Unexpected probe for ranged source: %+v", probe)
+	}
+
+	probe, err = p.ProbeSource(ctx, server.URL+"/plain.m4a")
+	if err != nil {
+		t.Fatalf("ProbeSource() unexpected error: %v", err)
+	}
+	if probe.RangeOK || probe.Size != 1200000 {
+		t.Errorf("Unexpected probe for source without ranges: %+v", probe)
+	}
+
+	if _, err := p.ProbeSource(ctx, server.URL+"/missing.mp3"); err == nil {
+		t.Error("Expected an error for a missing source")
+	}
+}
+
+func TestSourceProbeKbps(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int64
+		duration time.Duration
+		expected int
+	}{
+		{name: "64kbps for five minutes", size: 2400000, duration: 5 * time.Minute, expected: 64},
+		{name: "unknown size", size: 0, duration: 5 * time.Minute, expected: 0},
+		{name: "unknown duration", size: 2400000, duration: 0, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probe := &SourceProbe{Size: tt.size}
+			if got := probe.Kbps(tt.duration); got != tt.expected {
+				t.Errorf("Kbps() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSourceProbeFormat(t *testing.T) {
+	tests := []struct {
+		contentType string
+		url         string
+		expected    string
+	}{
+		{contentType: "audio/mp4; charset=binary", url: "https://example.com/ep", expected: "audio/mp4"},
+		{contentType: "application/octet-stream", url: "https://example.com/ep.m4a?token=1", expected: "audio/mp4"},
+		{contentType: "", url: "https://example.com/ep", expected: "audio/mpeg"},
+	}
+
+	for _, tt := range tests {
+		probe := &SourceProbe{ContentType: tt.contentType}
+		if got := probe.Format(tt.url).ContentType; got != tt.expected {
+			t.Errorf("Format(%q) with %q = %q, want %q", tt.url, tt.contentType, got, tt.expected)
+		}
+	}
+}
+
+func TestParseProbe(t *testing.T) {
+	probe, err := parseProbe([]byte(`{
+		"programs": [],
+		"streams": [{"codec_name": "mp3"}],
+		"format": {"duration": "3605.472000", "bit_rate": "128012"}
+	}`))
+	if err != nil {
+		t.Fatalf("parseProbe() unexpected error: %v", err)
+	}
+	if probe.Duration != 3605472*time.Millisecond || probe.Kbps != 128 || probe.Codec != "mp3" {
+		t.Errorf("Unexpected probe: %+v", probe)
+	}
+
+	// Some containers don't report a bitrate
+	probe, err = parseProbe([]byte(`{"streams": [{"codec_name": "opus"}], "format": {"duration": "60.0"}}`))
+	if err != nil || probe.Kbps != 0 || probe.Duration != time.Minute {
+		t.Errorf("Unexpected probe without bitrate: %+v, %v", probe, err)
+	}
+
+	// Chapters keep their titles; ones without a valid range are left out
+	probe, err = parseProbe([]byte(`{
+		"streams": [{"codec_name": "mp3"}],
+		"format": {"duration": "600.0"},
+		"chapters": [
+			{"start_time": "0.000000", "end_time": "90.500000", "tags": {"title": "Ads"}},
+			{"start_time": "90.500000", "end_time": "600.000000", "tags": {"title": " Interview "}},
+			{"start_time": "600.000000", "end_time": "600.000000"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("parseProbe() unexpected error: %v", err)
+	}
+	want := []Chapter{
+		{Title: "Ads", Start: 0, End: 90500 * time.Millisecond},
+		{Title: "Interview", Start: 90500 * time.Millisecond, End: 10 * time.Minute},
+	}
+	if !slices.Equal(probe.Chapters, want) {
+		t.Errorf("Chapters = %+v, want %+v", probe.Chapters, want)
+	}
+
+	if _, err := parseProbe([]byte(`{"streams": [], "format": {"duration": "60.0"}}`)); err == nil {
+		t.Error("Expected an error for a file without audio")
+	}
+	if _, err := parseProbe([]byte(`{"streams": [{"codec_name": "mp3"}], "format": {"duration": "N/A"}}`)); err == nil {
+		t.Error("Expected an error for an unknown duration")
+	}
+}
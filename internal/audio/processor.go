@@ -8,42 +8,198 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
+
+	"cobblepod/internal/config"
 )
 
-// ProcessingJob represents a single audio processing job
-type ProcessingJob struct {
-	ID             string                   `json:"id"`
-	Status         string                   `json:"status"`
-	M3U8FileID     string                   `json:"m3u8_file_id"`
-	M3U8FileName   string                   `json:"m3u8_file_name"`
-	Speed          float64                  `json:"speed"`
-	CreatedAt      time.Time                `json:"created_at"`
-	CompletedAt    *time.Time               `json:"completed_at,omitempty"`
-	Error          string                   `json:"error,omitempty"`
-	ProcessedFiles []map[string]interface{} `json:"processed_files"`
+// silenceFilter drops stretches of at least a second quieter than -50dB
+const silenceFilter = "silenceremove=start_periods=1:stop_periods=-1:stop_duration=1:stop_threshold=-50dB"
+
+// Segment is a stretch of an episode's audio, measured from where processing
+// starts (after any offset)
+type Segment struct {
+	Start, End time.Duration
+}
+
+// Processor downloads and encodes episodes. Job and item progress is tracked
+// by the queue (see processor.ProgressTracker), not here.
+type Processor interface {
+	// ProbeSource inspects a source file without downloading it
+	ProbeSource(ctx context.Context, url string) (*SourceProbe, error)
+	// SetOutputFormat sets the format ProcessAudio encodes to
+	SetOutputFormat(format Format)
+	// WithTrimSilence returns a processor like this one that removes long
+	// silences while processing, or doesn't, for episodes whose podcast
+	// chooses differently than the feed
+	WithTrimSilence(trim bool) Processor
+	// SetBitrate sets the bitrate ProcessAudio encodes at; zero leaves it to the encoder
+	SetBitrate(kbps int)
+	// SetMono enables downmixing to a single channel while processing
+	SetMono(mono bool)
+	// SetClips sets recordings to play before and after every processed episode
+	SetClips(intro, outro string)
+	// ProbeFile reads a downloaded file, see Probe
+	ProbeFile(path string) (*FileProbe, error)
+	// DownloadFile downloads a file from URL and returns the temp file path.
+	// Like ProcessAudio and TrimAudio, it logs with ctx, see logging.With, and
+	// finishes even if ctx is cancelled.
+	DownloadFile(ctx context.Context, url string) (string, error)
+	// ProcessAudio encodes a downloaded episode and returns the output path
+	ProcessAudio(ctx context.Context, inputPath, preamble string, speed float64, offset time.Duration, cuts []Segment) (string, error)
+	// TrimAudio cuts start from the beginning of an already processed file
+	TrimAudio(ctx context.Context, inputPath string, start time.Duration) (string, error)
+}
+
+// Configured returns the processor runs use: a Fake when cfg.FakeAudio is set,
+// FFmpeg otherwise
+func Configured(cfg config.WorkerConfig) Processor {
+	if cfg.FakeAudio {
+		fake := NewFake(0)
+		fake.DownloadLatency = cfg.FakeAudioLatency()
+		fake.EncodeLatency = cfg.FakeAudioLatency()
+		fake.DownloadFailureRate = cfg.FakeAudioFailureRate
+		fake.EncodeFailureRate = cfg.FakeAudioFailureRate
+		return fake
+	}
+	return NewFFmpeg(cfg)
+}
+
+// FFmpeg is the Processor that downloads over HTTP and encodes with FFmpeg
+type FFmpeg struct {
+	// ffmpegPath and ffprobePath are the binaries audio is encoded and probed
+	// with. inputArgs are added before the episode's input, such as hardware
+	// acceleration flags, and outputArgs before the output of encodes, such as
+	// a -threads limit; commands that copy the stream don't take them, as
+	// encoder options would conflict with -c copy.
+	ffmpegPath  string
+	ffprobePath string
+	inputArgs   []string
+	outputArgs  []string
+
+	format      Format
+	trimSilence bool
+	bitrateKbps int
+	mono        bool
+	// intro and outro are local recordings joined around every processed episode
+	intro, outro string
+}
+
+var _ Processor = (*FFmpeg)(nil)
+
+// NewFFmpeg creates a new audio processor that encodes to MP3 with the
+// binaries and arguments in cfg
+func NewFFmpeg(cfg config.WorkerConfig) *FFmpeg {
+	return &FFmpeg{
+		ffmpegPath:  cfg.FFmpegPath,
+		ffprobePath: cfg.FFprobePath,
+		inputArgs:   strings.Fields(cfg.FFmpegInputArgs),
+		outputArgs:  strings.Fields(cfg.FFmpegArgs),
+		format:      FormatMP3,
+	}
+}
+
+// SetOutputFormat sets the format ProcessAudio encodes to
+func (p *FFmpeg) SetOutputFormat(format Format) {
+	p.format = format
+}
+
+// SetTrimSilence enables removing long silences while processing. Durations
+// reported for trimmed episodes are estimates from the source and speed.
+func (p *FFmpeg) SetTrimSilence(trim bool) {
+	p.trimSilence = trim
 }
 
-// Processor handles audio processing operations
-type Processor struct {
-	jobs           map[string]*ProcessingJob
-	processedFiles map[string]bool
-	mutex          sync.RWMutex
+// WithTrimSilence returns a copy of p that removes long silences, or doesn't
+func (p *FFmpeg) WithTrimSilence(trim bool) Processor {
+	trimmed := *p
+	trimmed.trimSilence = trim
+	return &trimmed
+}
+
+// SetBitrate sets the bitrate ProcessAudio encodes at; zero leaves it to the encoder
+func (p *FFmpeg) SetBitrate(kbps int) {
+	p.bitrateKbps = kbps
+}
+
+// SetMono enables downmixing to a single channel while processing
+func (p *FFmpeg) SetMono(mono bool) {
+	p.mono = mono
+}
+
+// SetClips sets recordings to play before and after every processed episode;
+// an empty path leaves that clip out
+func (p *FFmpeg) SetClips(intro, outro string) {
+	p.intro = intro
+	p.outro = outro
+}
+
+// clipLayout converts a stream to the one sample format and layout concat requires
+const clipLayout = "aformat=sample_fmts=fltp:sample_rates=44100:channel_layouts=stereo"
+
+// clipFilter returns the filter graph that speeds up the episode and joins the
+// clips around it, for inputs ordered intro, preamble, episode, outro
+func (p *FFmpeg) clipFilter(speed float64, cuts []Segment, preamble string) string {
+	var graph []string
+	var streams string
+	input := 0
+	clip := func(path, label string) {
+		if path == "" {
+			return
+		}
+		graph = append(graph, fmt.Sprintf("[%d:a]%s[%s]", input, clipLayout, label))
+		streams += "[" + label + "]"
+		input++
+	}
+	clip(p.intro, "intro")
+	clip(preamble, "preamble")
+	graph = append(graph, fmt.Sprintf("[%d:a]%s,%s[episode]", input, p.audioFilter(speed, cuts), clipLayout))
+	streams += "[episode]"
+	input++
+	clip(p.outro, "outro")
+	graph = append(graph, fmt.Sprintf("%sconcat=n=%d:v=0:a=1[out]", streams, input))
+	return strings.Join(graph, ";")
+}
+
+// encodeArgs returns the FFmpeg output options for the bitrate and channels
+func (p *FFmpeg) encodeArgs() []string {
+	var args []string
+	if p.bitrateKbps > 0 {
+		args = append(args, "-b:a", strconv.Itoa(p.bitrateKbps)+"k")
+	}
+	if p.mono {
+		args = append(args, "-ac", "1")
+	}
+	return args
+}
+
+// audioFilter returns the FFmpeg audio filter chain for a speed, leaving out cuts
+func (p *FFmpeg) audioFilter(speed float64, cuts []Segment) string {
+	var filters []string
+	if len(cuts) > 0 {
+		filters = append(filters, cutFilter(cuts))
+	}
+	if p.trimSilence {
+		filters = append(filters, silenceFilter)
+	}
+	filters = append(filters, "atempo="+strconv.FormatFloat(speed, 'f', -1, 64))
+	return strings.Join(filters, ",")
 }
 
-// NewProcessor creates a new audio processor
-func NewProcessor() *Processor {
-	return &Processor{
-		jobs:           make(map[string]*ProcessingJob),
-		processedFiles: make(map[string]bool),
+// cutFilter drops the samples inside cuts and closes the gaps they leave
+func cutFilter(cuts []Segment) string {
+	ranges := make([]string, len(cuts))
+	for i, cut := range cuts {
+		ranges[i] = fmt.Sprintf("between(t,%s,%s)", strconv.FormatFloat(cut.Start.Seconds(), 'f', 3, 64), strconv.FormatFloat(cut.End.Seconds(), 'f', 3, 64))
 	}
+	return "aselect='not(" + strings.Join(ranges, "+") + ")',asetpts=N/SR/TB"
 }
 
 // downloadAudioFile downloads an audio file from URL to local path
-func (p *Processor) downloadAudioFile(ctx context.Context, url, outputPath string) error {
-	slog.Info("Downloading audio", "url", url)
+func (p *FFmpeg) downloadAudioFile(ctx context.Context, url, outputPath string) error {
+	slog.InfoContext(ctx, "Downloading audio", "url", url)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -78,9 +234,27 @@ func (p *Processor) downloadAudioFile(ctx context.Context, url, outputPath strin
 	return nil
 }
 
-// processAudioWithFFmpeg processes audio with FFmpeg
-func (p *Processor) processAudioWithFFmpeg(ctx context.Context, inputPath, outputPath string, speed float64, offset time.Duration) error {
-	args := []string{"ffmpeg"}
+// processAudioWithFFmpeg processes audio with FFmpeg. When tolerant is set, FFmpeg is
+// told to ignore decode errors and discard corrupt packets instead of aborting.
+func (p *FFmpeg) processAudioWithFFmpeg(ctx context.Context, inputPath, preamble, outputPath string, speed float64, offset time.Duration, cuts []Segment, tolerant bool) error {
+	return runFFmpeg(ctx, p.processArgs(inputPath, preamble, outputPath, speed, offset, cuts, tolerant), outputPath)
+}
+
+// processArgs builds the FFmpeg command line processAudioWithFFmpeg runs
+func (p *FFmpeg) processArgs(inputPath, preamble, outputPath string, speed float64, offset time.Duration, cuts []Segment, tolerant bool) []string {
+	args := []string{p.ffmpegPath}
+	if p.intro != "" {
+		args = append(args, "-i", p.intro)
+	}
+	if preamble != "" {
+		args = append(args, "-i", preamble)
+	}
+
+	// Input options apply to the episode, which follows
+	if tolerant {
+		args = append(args, "-err_detect", "ignore_err", "-fflags", "+discardcorrupt")
+	}
+	args = append(args, p.inputArgs...)
 
 	// Add seek offset if non-zero
 	if offset > 0 {
@@ -92,27 +266,91 @@ func (p *Processor) processAudioWithFFmpeg(ctx context.Context, inputPath, outpu
 	}
 
 	// Add remaining arguments
-	args = append(args,
-		"-i", inputPath,
-		"-filter:a", fmt.Sprintf("atempo=%.1f", speed),
-		"-y",
-		outputPath,
-	)
-
-	slog.Info("Executing FFmpeg command", "command", strings.Join(args, " "))
+	args = append(args, "-i", inputPath)
+	if p.outro != "" {
+		args = append(args, "-i", p.outro)
+	}
+	if p.intro != "" || preamble != "" || p.outro != "" {
+		args = append(args, "-filter_complex", p.clipFilter(speed, cuts, preamble), "-map", "[out]")
+	} else {
+		args = append(args, "-filter:a", p.audioFilter(speed, cuts))
+	}
+	args = append(args, p.encodeArgs()...)
+	args = append(args, p.outputArgs...)
+	args = append(args, "-y", outputPath)
+	return args
+}
+
+// remuxWithFFmpeg copies the audio stream into a fresh container without re-encoding,
+// dropping corrupt packets along the way. This repairs most broken headers and frames.
+func (p *FFmpeg) remuxWithFFmpeg(ctx context.Context, inputPath, outputPath string) error {
+	return runFFmpeg(ctx, p.remuxArgs(inputPath, outputPath), outputPath)
+}
+
+// remuxArgs builds the FFmpeg command line remuxWithFFmpeg runs
+func (p *FFmpeg) remuxArgs(inputPath, outputPath string) []string {
+	args := []string{p.ffmpegPath, "-err_detect", "ignore_err", "-fflags", "+discardcorrupt"}
+	args = append(args, p.inputArgs...)
+	args = append(args, "-i", inputPath, "-map", "0:a", "-c", "copy")
+	return append(args, "-y", outputPath)
+}
+
+// trimArgs builds the FFmpeg command line that cuts start from the beginning
+// of inputPath, copying the audio stream as is
+func (p *FFmpeg) trimArgs(inputPath, outputPath string, start time.Duration) []string {
+	args := []string{p.ffmpegPath, "-ss", strconv.FormatFloat(start.Seconds(), 'f', 3, 64)}
+	args = append(args, p.inputArgs...)
+	args = append(args, "-i", inputPath, "-map", "0:a", "-c", "copy")
+	return append(args, "-y", outputPath)
+}
+
+// Check verifies that the configured FFmpeg is installed and runs
+func (p *FFmpeg) Check(ctx context.Context) error {
+	if err := exec.CommandContext(ctx, p.ffmpegPath, "-version").Run(); err != nil {
+		return fmt.Errorf("ffmpeg unavailable: %w", err)
+	}
+	return nil
+}
+
+// runFFmpeg executes an FFmpeg command line and wraps any failure with its output
+func runFFmpeg(ctx context.Context, args []string, outputPath string) error {
+	slog.InfoContext(ctx, "Executing FFmpeg command", "command", strings.Join(args, " "))
 	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("FFmpeg error: %w, output: %s", err, string(output))
 	}
-	slog.Info("FFmpeg processing completed", "output_path", outputPath)
+	slog.InfoContext(ctx, "FFmpeg processing completed", "output_path", outputPath)
 
 	return nil
 }
 
+// processAudioTolerant is the fallback used when the regular FFmpeg pass fails.
+// It re-muxes the input first and runs the tempo pass on the repaired copy; if the
+// re-mux itself fails, the tolerant tempo pass is attempted on the original input.
+func (p *FFmpeg) processAudioTolerant(ctx context.Context, inputPath, preamble, outputPath string, speed float64, offset time.Duration, cuts []Segment) error {
+	// Matroska holds any audio codec, so AAC and Opus sources can be copied
+	// into it as well as MP3
+	remuxFile, err := os.CreateTemp("", "cobblepod_remux_*.mka")
+	if err != nil {
+		return fmt.Errorf("failed to create remux temp file: %w", err)
+	}
+	remuxPath := remuxFile.Name()
+	remuxFile.Close()
+	defer os.Remove(remuxPath)
+
+	source := remuxPath
+	if err := p.remuxWithFFmpeg(ctx, inputPath, remuxPath); err != nil {
+		slog.WarnContext(ctx, "Re-mux failed, retrying on original input", "input_path", inputPath, "error", err)
+		source = inputPath
+	}
+
+	return p.processAudioWithFFmpeg(ctx, source, preamble, outputPath, speed, offset, cuts, true)
+}
+
 // DownloadFile downloads a file from URL and returns the temp file path
-func (p *Processor) DownloadFile(url string) (string, error) {
+func (p *FFmpeg) DownloadFile(ctx context.Context, url string) (string, error) {
 	// Create temp file
 	tempFile, err := os.CreateTemp("", "cobblepod_*.mp3")
 	if err != nil {
@@ -122,7 +360,7 @@ func (p *Processor) DownloadFile(url string) (string, error) {
 	tempFile.Close() // Close it so we can write to it
 
 	// Download to temp file
-	err = p.downloadAudioFile(context.Background(), url, tempPath)
+	err = p.downloadAudioFile(context.WithoutCancel(ctx), url, tempPath)
 	if err != nil {
 		os.Remove(tempPath) // Clean up on error
 		return "", err
@@ -131,41 +369,55 @@ func (p *Processor) DownloadFile(url string) (string, error) {
 	return tempPath, nil
 }
 
-// ProcessAudio processes audio file with FFmpeg and returns output path
-func (p *Processor) ProcessAudio(inputPath string, speed float64, offset time.Duration) (string, error) {
+// ProcessAudio processes audio file with FFmpeg and returns output path. The
+// output's extension identifies its format (see FormatForPath). A non-empty
+// preamble is a recording played, at its own pace, right before the episode.
+// Cuts are left out of the episode, such as ads skipped by the user's rules.
+func (p *FFmpeg) ProcessAudio(ctx context.Context, inputPath, preamble string, speed float64, offset time.Duration, cuts []Segment) (string, error) {
 	// Create temp output file
-	outputFile, err := os.CreateTemp("", "cobblepod_processed_*.mp3")
+	outputFile, err := os.CreateTemp("", "cobblepod_processed_*."+p.format.Extension)
 	if err != nil {
 		return "", fmt.Errorf("failed to create output temp file: %w", err)
 	}
 	outputPath := outputFile.Name()
 	outputFile.Close() // Close it so FFmpeg can write to it
 
-	// Process with FFmpeg
-	err = p.processAudioWithFFmpeg(context.Background(), inputPath, outputPath, speed, offset)
+	// Process with FFmpeg, retrying once with error-tolerant settings since many
+	// source files contain corrupt frames that a more forgiving pass survives
+	ctx = context.WithoutCancel(ctx)
+	err = p.processAudioWithFFmpeg(ctx, inputPath, preamble, outputPath, speed, offset, cuts, false)
 	if err != nil {
-		os.Remove(outputPath) // Clean up on error
-		return "", err
+		slog.WarnContext(ctx, "FFmpeg failed, retrying with tolerant settings", "input_path", inputPath, "error", err)
+		if retryErr := p.processAudioTolerant(ctx, inputPath, preamble, outputPath, speed, offset, cuts); retryErr != nil {
+			os.Remove(outputPath) // Clean up on error
+			return "", fmt.Errorf("%w (tolerant retry: %v)", err, retryErr)
+		}
 	}
 
 	return outputPath, nil
 }
 
-// GetJobStatus returns the status of a specific job
-func (p *Processor) GetJobStatus(jobID string) *ProcessingJob {
-	p.mutex.RLock()
-	defer p.mutex.RUnlock()
-	return p.jobs[jobID]
-}
+// trimTimeout bounds a TrimAudio run. Copying the stream takes seconds, so a
+// run still going after this is hung rather than slow.
+const trimTimeout = 5 * time.Minute
 
-// ListJobs returns all jobs
-func (p *Processor) ListJobs() []*ProcessingJob {
-	p.mutex.RLock()
-	defer p.mutex.RUnlock()
+// TrimAudio cuts start from the beginning of an already processed file without
+// re-encoding it, for episodes whose listening offset moved forward. The input
+// must already be in the processor's output format. Like ProcessAudio it
+// outlives ctx's cancellation, but never runs longer than trimTimeout.
+func (p *FFmpeg) TrimAudio(ctx context.Context, inputPath string, start time.Duration) (string, error) {
+	outputFile, err := os.CreateTemp("", "cobblepod_trimmed_*."+p.format.Extension)
+	if err != nil {
+		return "", fmt.Errorf("failed to create output temp file: %w", err)
+	}
+	outputPath := outputFile.Name()
+	outputFile.Close()
 
-	jobs := make([]*ProcessingJob, 0, len(p.jobs))
-	for _, job := range p.jobs {
-		jobs = append(jobs, job)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), trimTimeout)
+	defer cancel()
+	if err := runFFmpeg(ctx, p.trimArgs(inputPath, outputPath, start), outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", err
 	}
-	return jobs
+	return outputPath, nil
 }
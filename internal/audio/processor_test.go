@@ -0,0 +1,172 @@
+package audio
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cobblepod/internal/config"
+)
+
+func TestAudioFilter(t *testing.T) {
+	p := NewFFmpeg(config.Defaults().Worker)
+	if got := p.audioFilter(1.25, nil); got != "atempo=1.25" {
+		t.Errorf("audioFilter(1.25) = %q", got)
+	}
+
+	p.SetTrimSilence(true)
+	if got, want := p.audioFilter(1.5, nil), silenceFilter+",atempo=1.5"; got != want {
+		t.Errorf("audioFilter(1.5) = %q, want %q", got, want)
+	}
+
+	// Cuts are left out before silences are measured and the tempo changes
+	cuts := []Segment{{Start: 0, End: 90 * time.Second}, {Start: 10 * time.Minute, End: 12*time.Minute + 500*time.Millisecond}}
+	want := "aselect='not(between(t,0.000,90.000)+between(t,600.000,720.500))',asetpts=N/SR/TB," + silenceFilter + ",atempo=1.5"
+	if got := p.audioFilter(1.5, cuts); got != want {
+		t.Errorf("audioFilter(1.5) with cuts = %q, want %q", got, want)
+	}
+}
+
+func TestEncodeArgs(t *testing.T) {
+	p := NewFFmpeg(config.Defaults().Worker)
+	if args := p.encodeArgs(); len(args) != 0 {
+		t.Errorf("encodeArgs() = %q, want the encoder defaults", args)
+	}
+
+	p.SetBitrate(64)
+	p.SetMono(true)
+	if got, want := strings.Join(p.encodeArgs(), " "), "-b:a 64k -ac 1"; got != want {
+		t.Errorf("encodeArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestProcessArgs(t *testing.T) {
+	p := NewFFmpeg(config.Defaults().Worker)
+	args := strings.Join(p.processArgs("in.mp3", "", "out.mp3", 1.5, 90*time.Second, nil, false), " ")
+	if want := "ffmpeg -ss 00:01:30 -i in.mp3 -filter:a atempo=1.5 -y out.mp3"; args != want {
+		t.Errorf("processArgs() = %q, want %q", args, want)
+	}
+
+	p.SetClips("intro.mp3", "outro.mp3")
+	args = strings.Join(p.processArgs("in.mp3", "", "out.mp3", 1.5, 90*time.Second, nil, true), " ")
+	want := "ffmpeg -i intro.mp3 -err_detect ignore_err -fflags +discardcorrupt -ss 00:01:30 -i in.mp3 -i outro.mp3 " +
+		"-filter_complex [0:a]" + clipLayout + "[intro];[1:a]atempo=1.5," + clipLayout + "[episode];[2:a]" + clipLayout + "[outro];" +
+		"[intro][episode][outro]concat=n=3:v=0:a=1[out] -map [out] -y out.mp3"
+	if args != want {
+		t.Errorf("processArgs() = %q, want %q", args, want)
+	}
+
+	// The preamble plays between the intro and the episode
+	args = strings.Join(p.processArgs("in.mp3", "preamble.wav", "out.mp3", 1.5, 0, nil, false), " ")
+	want = "ffmpeg -i intro.mp3 -i preamble.wav -i in.mp3 -i outro.mp3 " +
+		"-filter_complex [0:a]" + clipLayout + "[intro];[1:a]" + clipLayout + "[preamble];[2:a]atempo=1.5," + clipLayout + "[episode];" +
+		"[3:a]" + clipLayout + "[outro];[intro][preamble][episode][outro]concat=n=4:v=0:a=1[out] -map [out] -y out.mp3"
+	if args != want {
+		t.Errorf("processArgs() = %q, want %q", args, want)
+	}
+
+	// An outro alone follows the episode
+	p.SetClips("", "outro.mp3")
+	if got, want := p.clipFilter(2, nil, ""), "[0:a]atempo=2,"+clipLayout+"[episode];[1:a]"+clipLayout+"[outro];[episode][outro]concat=n=2:v=0:a=1[out]"; got != want {
+		t.Errorf("clipFilter() = %q, want %q", got, want)
+	}
+}
+
+func TestTrimArgs(t *testing.T) {
+	args := NewFFmpeg(config.Defaults().Worker).trimArgs("in.mp3", "out.mp3", 10*time.Minute+1500*time.Millisecond)
+	if got, want := strings.Join(args, " "), "ffmpeg -ss 601.500 -i in.mp3 -map 0:a -c copy -y out.mp3"; got != want {
+		t.Errorf("trimArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestRemuxArgs(t *testing.T) {
+	args := NewFFmpeg(config.Defaults().Worker).remuxArgs("in.m4a", "out.mka")
+	if got, want := strings.Join(args, " "), "ffmpeg -err_detect ignore_err -fflags +discardcorrupt -i in.m4a -map 0:a -c copy -y out.mka"; got != want {
+		t.Errorf("remuxArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestProcessAudioTolerantRetry(t *testing.T) {
+	// A stand-in FFmpeg that fails unless told to tolerate errors, recording
+	// each command line and creating its output
+	dir := t.TempDir()
+	log := filepath.Join(dir, "calls.log")
+	script := filepath.Join(dir, "ffmpeg")
+	body := "#!/bin/sh\necho \"$@\" >> " + log + "\n" +
+		"case \"$*\" in *-err_detect*) ;; *) exit 1 ;; esac\n" +
+		"for arg; do out=$arg; done\n: > \"$out\"\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Defaults().Worker
+	cfg.FFmpegPath = script
+
+	outputPath, err := NewFFmpeg(cfg).ProcessAudio(context.Background(), "in.m4a", "", 1.5, 0, nil)
+	if err != nil {
+		t.Fatalf("ProcessAudio failed: %v", err)
+	}
+	defer os.Remove(outputPath)
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(calls) != 3 {
+		t.Fatalf("Expected the encode, a re-mux and the tolerant encode, got %q", calls)
+	}
+	// The re-mux copies the stream into a container any codec fits, and the
+	// tolerant pass encodes the copy
+	remux := strings.Fields(calls[1])
+	remuxPath := remux[len(remux)-1]
+	if !strings.Contains(calls[1], "-i in.m4a -map 0:a -c copy") || filepath.Ext(remuxPath) != ".mka" {
+		t.Errorf("Unexpected re-mux %q", calls[1])
+	}
+	if !strings.Contains(calls[2], "-err_detect ignore_err") || !strings.Contains(calls[2], "-i "+remuxPath) {
+		t.Errorf("Expected the tolerant pass to encode %s, got %q", remuxPath, calls[2])
+	}
+}
+
+func TestConfiguredFFmpegArgs(t *testing.T) {
+	cfg := config.Defaults().Worker
+	cfg.FFmpegPath = "/opt/ffmpeg/bin/ffmpeg"
+	cfg.FFmpegInputArgs = "-hwaccel auto"
+	cfg.FFmpegArgs = "-threads 2"
+	p := NewFFmpeg(cfg)
+
+	args := strings.Join(p.processArgs("in.mp3", "", "out.mp3", 1, 0, nil, false), " ")
+	if want := "/opt/ffmpeg/bin/ffmpeg -hwaccel auto -i in.mp3 -filter:a atempo=1 -threads 2 -y out.mp3"; args != want {
+		t.Errorf("processArgs() = %q, want %q", args, want)
+	}
+	args = strings.Join(p.trimArgs("in.mp3", "out.mp3", time.Second), " ")
+	// Stream copies take no encoder options
+	if want := "/opt/ffmpeg/bin/ffmpeg -ss 1.000 -hwaccel auto -i in.mp3 -map 0:a -c copy -y out.mp3"; args != want {
+		t.Errorf("trimArgs() = %q, want %q", args, want)
+	}
+	args = strings.Join(p.remuxArgs("in.mp3", "out.mp3"), " ")
+	if want := "/opt/ffmpeg/bin/ffmpeg -err_detect ignore_err -fflags +discardcorrupt -hwaccel auto -i in.mp3 -map 0:a -c copy -y out.mp3"; args != want {
+		t.Errorf("remuxArgs() = %q, want %q", args, want)
+	}
+}
+
+func TestFFmpegWithTrimSilence(t *testing.T) {
+	p := NewFFmpeg(config.Defaults().Worker)
+	p.SetBitrate(64)
+
+	trimmed, ok := p.WithTrimSilence(true).(*FFmpeg)
+	if !ok {
+		t.Fatal("Expected an *FFmpeg")
+	}
+	if got, want := trimmed.audioFilter(1.5, nil), silenceFilter+",atempo=1.5"; got != want {
+		t.Errorf("audioFilter(1.5) = %q, want %q", got, want)
+	}
+	if trimmed.bitrateKbps != 64 {
+		t.Errorf("Expected the copy to keep the bitrate, got %d", trimmed.bitrateKbps)
+	}
+	if got := p.audioFilter(1.5, nil); got != "atempo=1.5" {
+		t.Errorf("Expected the original to keep silences, got %q", got)
+	}
+}
@@ -6,10 +6,11 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
-	"os"
 	"strings"
 	"sync"
 	"time"
+
+	"cobblepod/internal/config"
 )
 
 // Auth0Config holds Auth0 configuration
@@ -29,13 +30,13 @@ type ManagementTokenCache struct {
 
 var mgmtTokenCache = &ManagementTokenCache{}
 
-// GetAuth0Config returns Auth0 configuration from environment
-func GetAuth0Config() *Auth0Config {
+// NewAuth0Config returns the Auth0 part of cfg
+func NewAuth0Config(cfg config.AuthConfig) *Auth0Config {
 	return &Auth0Config{
-		Domain:       os.Getenv("AUTH0_DOMAIN"),
-		Audience:     os.Getenv("AUTH0_AUDIENCE"),
-		ClientID:     os.Getenv("AUTH0_CLIENT_ID"),
-		ClientSecret: os.Getenv("AUTH0_CLIENT_SECRET"),
+		Domain:       cfg.Auth0Domain,
+		Audience:     cfg.Auth0Audience,
+		ClientID:     cfg.Auth0ClientID,
+		ClientSecret: cfg.Auth0ClientSecret,
 	}
 }
 
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"cobblepod/internal/config"
+
+	"golang.org/x/oauth2"
+)
+
+// googleUserIDPrefix matches the user IDs Auth0 gives Google accounts, so data
+// carries over when a deployment switches providers
+const googleUserIDPrefix = "google-oauth2|"
+
+// googlePopupRedirect is the redirect URI for codes obtained with the Google
+// Identity Services popup flow
+const googlePopupRedirect = "postmessage"
+
+// ErrNoGoogleToken is returned when a user hasn't signed in with the google provider yet
+var ErrNoGoogleToken = errors.New("no google token stored for user")
+
+// GoogleTokenStore persists the Google tokens of users who signed in directly
+type GoogleTokenStore interface {
+	GetGoogleToken(ctx context.Context, userID string) (*oauth2.Token, error)
+	SaveGoogleToken(ctx context.Context, userID string, token *oauth2.Token) error
+}
+
+// GoogleProvider authenticates with Google ID tokens, for deployments without
+// Auth0. Clients exchange an authorization code at sign in (see Exchange); the
+// resulting refresh token is stored so the worker can act for the user later.
+type GoogleProvider struct {
+	config *oauth2.Config
+	store  GoogleTokenStore
+}
+
+var _ Provider = (*GoogleProvider)(nil)
+
+// NewGoogleProvider creates a provider for cfg's Google OAuth client
+func NewGoogleProvider(cfg config.AuthConfig, store GoogleTokenStore) (*GoogleProvider, error) {
+	oauthConfig := GoogleOAuthConfig(cfg)
+	if oauthConfig == nil {
+		return nil, fmt.Errorf("google auth provider requires GOOGLE_CLIENT_ID and GOOGLE_CLIENT_SECRET")
+	}
+	if store == nil {
+		return nil, fmt.Errorf("google auth provider requires a token store")
+	}
+	oauthConfig.RedirectURL = googlePopupRedirect
+	return &GoogleProvider{config: oauthConfig, store: store}, nil
+}
+
+func (p *GoogleProvider) Issuer() *url.URL {
+	return &url.URL{Scheme: "https", Host: "accounts.google.com"}
+}
+
+// Audience is the OAuth client ID, which Google ID tokens are issued for
+func (p *GoogleProvider) Audience() string {
+	return p.config.ClientID
+}
+
+func (p *GoogleProvider) UserID(subject string) string {
+	return googleUserIDPrefix + subject
+}
+
+// Exchange trades an authorization code for the user's tokens. The returned
+// ID token is what the client sends as its bearer token.
+func (p *GoogleProvider) Exchange(ctx context.Context, code string) (*oauth2.Token, string, error) {
+	token, err := p.config.Exchange(ctx, code)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+	idToken, _ := token.Extra("id_token").(string)
+	if idToken == "" {
+		return nil, "", fmt.Errorf("google did not return an ID token")
+	}
+	return token, idToken, nil
+}
+
+// SaveToken stores a user's tokens. Google only sends a refresh token the first
+// time a user consents, so a stored one is kept when the new token lacks it.
+func (p *GoogleProvider) SaveToken(ctx context.Context, userID string, token *oauth2.Token) error {
+	if token.RefreshToken == "" {
+		stored, err := p.store.GetGoogleToken(ctx, userID)
+		if err != nil && !errors.Is(err, ErrNoGoogleToken) {
+			return err
+		}
+		if stored != nil {
+			token.RefreshToken = stored.RefreshToken
+		}
+	}
+	return p.store.SaveGoogleToken(ctx, userID, token)
+}
+
+func (p *GoogleProvider) GetGoogleAccessToken(ctx context.Context, userID string) (string, error) {
+	source, err := p.GoogleTokenSource(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	token, err := source.Token()
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh Google token: %w", err)
+	}
+	return token.AccessToken, nil
+}
+
+func (p *GoogleProvider) GoogleTokenSource(ctx context.Context, userID string) (oauth2.TokenSource, error) {
+	token, err := p.store.GetGoogleToken(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return p.config.TokenSource(ctx, token), nil
+}
@@ -8,89 +8,115 @@ import (
 	"log/slog"
 	"net/http"
 	"strings"
+
+	"golang.org/x/oauth2"
 )
 
 // TokenProvider interface for dependency injection
 type TokenProvider interface {
 	GetGoogleAccessToken(ctx context.Context, userID string) (string, error)
+	// GoogleTokenSource returns a source that keeps yielding a valid token for
+	// the user, for work that outlives a single access token
+	GoogleTokenSource(ctx context.Context, userID string) (oauth2.TokenSource, error)
 }
 
-// DefaultTokenProvider implementation
-type DefaultTokenProvider struct{}
+// DefaultTokenProvider reads users' Google tokens from their Auth0 identity.
+// When google is set, tokens are refreshed with that OAuth client instead.
+type DefaultTokenProvider struct {
+	auth0  *Auth0Config
+	google *oauth2.Config
+}
 
+// GetGoogleAccessToken exchanges Auth0 token for Google access token using the user ID from context
 func (p *DefaultTokenProvider) GetGoogleAccessToken(ctx context.Context, userID string) (string, error) {
-	return GetGoogleAccessToken(ctx, userID)
+	identity, err := p.fetchGoogleIdentity(userID)
+	if err != nil {
+		return "", err
+	}
+	return identity.AccessToken, nil
 }
 
-// GetGoogleAccessToken exchanges Auth0 token for Google access token using the user ID from context
-func GetGoogleAccessToken(ctx context.Context, userID string) (string, error) {
-	config := GetAuth0Config()
+func (p *DefaultTokenProvider) GoogleTokenSource(ctx context.Context, userID string) (oauth2.TokenSource, error) {
+	return p.NewGoogleTokenSource(ctx, userID)
+}
+
+// fetchGoogleIdentity reads the user's Google identity from the Auth0 Management API
+func (p *DefaultTokenProvider) fetchGoogleIdentity(userID string) (*googleIdentity, error) {
+	config := p.auth0
 
 	// Get cached or new management token
 	mgmtToken, err := GetCachedManagementToken(config)
 	if err != nil {
-		return "", fmt.Errorf("failed to get management token: %w", err)
+		return nil, fmt.Errorf("failed to get management token: %w", err)
 	}
 
 	slog.Info("Fetching Google access token for user", "sub", userID)
 
 	// Use management token to fetch user's identity provider tokens
-	googleToken, err := getUserGoogleToken(userID, mgmtToken, config)
+	identity, err := getUserGoogleToken(userID, mgmtToken, config)
 	if err != nil {
-		return "", fmt.Errorf("failed to get Google token: %w", err)
+		return nil, fmt.Errorf("failed to get Google token: %w", err)
 	}
 
-	return googleToken, nil
+	return identity, nil
+}
+
+// googleIdentity holds the Google tokens Auth0 stored for a user. The refresh
+// token is only present when the connection requests offline access.
+type googleIdentity struct {
+	AccessToken  string
+	RefreshToken string
 }
 
-// getUserGoogleToken fetches the Google access token for a user
-func getUserGoogleToken(userID, mgmtToken string, config *Auth0Config) (string, error) {
+// getUserGoogleToken fetches the Google tokens for a user
+func getUserGoogleToken(userID, mgmtToken string, config *Auth0Config) (*googleIdentity, error) {
 	// Extract the connection from user ID (e.g., "google-oauth2|123456")
 	parts := strings.Split(userID, "|")
 	if len(parts) < 2 {
-		return "", fmt.Errorf("invalid user ID format: %s", userID)
+		return nil, fmt.Errorf("invalid user ID format: %s", userID)
 	}
 
 	url := fmt.Sprintf("https://%s/api/v2/users/%s", config.Domain, userID)
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", mgmtToken))
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("failed to get user info, status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("failed to get user info, status %d: %s", resp.StatusCode, string(body))
 	}
 
 	var user struct {
 		Identities []struct {
-			Provider    string `json:"provider"`
-			AccessToken string `json:"access_token"`
+			Provider     string `json:"provider"`
+			AccessToken  string `json:"access_token"`
+			RefreshToken string `json:"refresh_token"`
 		} `json:"identities"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// Find Google identity
 	for _, identity := range user.Identities {
 		if identity.Provider == "google-oauth2" {
 			if identity.AccessToken == "" {
-				return "", fmt.Errorf("google access token not available for user")
+				return nil, fmt.Errorf("google access token not available for user")
 			}
-			return identity.AccessToken, nil
+			return &googleIdentity{AccessToken: identity.AccessToken, RefreshToken: identity.RefreshToken}, nil
 		}
 	}
 
-	return "", fmt.Errorf("no google identity found for user")
+	return nil, fmt.Errorf("no google identity found for user")
 }
@@ -1,6 +1,10 @@
 package auth
 
-import "context"
+import (
+	"context"
+
+	"golang.org/x/oauth2"
+)
 
 // MockTokenProvider is a mock implementation of a token provider for testing
 type MockTokenProvider struct {
@@ -11,3 +15,10 @@ type MockTokenProvider struct {
 func (m *MockTokenProvider) GetGoogleAccessToken(ctx context.Context, userID string) (string, error) {
 	return m.Token, m.Err
 }
+
+func (m *MockTokenProvider) GoogleTokenSource(ctx context.Context, userID string) (oauth2.TokenSource, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	return oauth2.StaticTokenSource(&oauth2.Token{AccessToken: m.Token}), nil
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"fmt"
+	"net/url"
+
+	"cobblepod/internal/config"
+)
+
+// Provider names selectable with AUTH_PROVIDER
+const (
+	ProviderAuth0  = "auth0"
+	ProviderGoogle = "google"
+)
+
+// Provider authenticates API users and supplies their Google tokens. API bearer
+// tokens are OpenID Connect JWTs signed by the provider's issuer.
+type Provider interface {
+	TokenProvider
+	// Issuer is the OpenID Connect issuer whose keys sign bearer tokens
+	Issuer() *url.URL
+	// Audience is the audience bearer tokens must be issued for
+	Audience() string
+	// UserID maps a validated token's subject to a cobblepod user ID
+	UserID(subject string) string
+}
+
+// NewProvider creates the provider cfg names. Google tokens for the google
+// provider are kept in store, since there's no Auth0 account holding them.
+func NewProvider(cfg config.AuthConfig, store GoogleTokenStore) (Provider, error) {
+	switch cfg.Provider {
+	case "", ProviderAuth0:
+		return NewAuth0Provider(cfg), nil
+	case ProviderGoogle:
+		return NewGoogleProvider(cfg, store)
+	}
+	return nil, fmt.Errorf("unknown auth provider %q", cfg.Provider)
+}
+
+// Auth0Provider authenticates with Auth0 access tokens and reads Google tokens
+// from the user's Auth0 identity through the Management API
+type Auth0Provider struct {
+	DefaultTokenProvider
+	config *Auth0Config
+}
+
+var _ Provider = (*Auth0Provider)(nil)
+
+// NewAuth0Provider creates a provider for cfg's Auth0 tenant. Google tokens
+// are refreshed with cfg's Google OAuth client when one is set.
+func NewAuth0Provider(cfg config.AuthConfig) *Auth0Provider {
+	auth0 := NewAuth0Config(cfg)
+	return &Auth0Provider{
+		DefaultTokenProvider: DefaultTokenProvider{auth0: auth0, google: GoogleOAuthConfig(cfg)},
+		config:               auth0,
+	}
+}
+
+func (p *Auth0Provider) Issuer() *url.URL {
+	return &url.URL{Scheme: "https", Host: p.config.Domain, Path: "/"}
+}
+
+func (p *Auth0Provider) Audience() string {
+	return p.config.Audience
+}
+
+// UserID returns the subject as is; Auth0 subjects are already prefixed with
+// their connection, e.g. "google-oauth2|123456"
+func (p *Auth0Provider) UserID(subject string) string {
+	return subject
+}
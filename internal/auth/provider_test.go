@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"cobblepod/internal/config"
+
+	"golang.org/x/oauth2"
+)
+
+// memoryTokenStore is a GoogleTokenStore backed by a map
+type memoryTokenStore map[string]*oauth2.Token
+
+func (m memoryTokenStore) GetGoogleToken(ctx context.Context, userID string) (*oauth2.Token, error) {
+	token, ok := m[userID]
+	if !ok {
+		return nil, ErrNoGoogleToken
+	}
+	return token, nil
+}
+
+func (m memoryTokenStore) SaveGoogleToken(ctx context.Context, userID string, token *oauth2.Token) error {
+	m[userID] = token
+	return nil
+}
+
+func TestNewProvider(t *testing.T) {
+	cfg := config.AuthConfig{Auth0Domain: "tenant.auth0.com", GoogleClientID: "client-id", GoogleClientSecret: "client-secret"}
+
+	provider, err := NewProvider(cfg, nil)
+	if err != nil {
+		t.Fatalf("NewProvider() error: %v", err)
+	}
+	if got := provider.Issuer().String(); got != "https://tenant.auth0.com/" {
+		t.Errorf("Expected Auth0 issuer, got %s", got)
+	}
+
+	cfg.Provider = ProviderGoogle
+	provider, err = NewProvider(cfg, memoryTokenStore{})
+	if err != nil {
+		t.Fatalf("NewProvider(google) error: %v", err)
+	}
+	if provider.Issuer().String() != "https://accounts.google.com" || provider.Audience() != "client-id" {
+		t.Errorf("Unexpected Google issuer %s or audience %s", provider.Issuer(), provider.Audience())
+	}
+	if got := provider.UserID("123"); got != "google-oauth2|123" {
+		t.Errorf("Expected Auth0 style user ID, got %s", got)
+	}
+
+	if _, err := NewProvider(cfg, nil); err == nil {
+		t.Error("Expected google provider without a token store to fail")
+	}
+	cfg.Provider = "okta"
+	if _, err := NewProvider(cfg, nil); err == nil {
+		t.Error("Expected unknown provider to fail")
+	}
+}
+
+func TestGoogleProviderSaveTokenKeepsRefreshToken(t *testing.T) {
+	cfg := config.AuthConfig{GoogleClientID: "client-id", GoogleClientSecret: "client-secret"}
+
+	store := memoryTokenStore{"google-oauth2|1": {AccessToken: "old", RefreshToken: "refresh"}}
+	provider, err := NewGoogleProvider(cfg, store)
+	if err != nil {
+		t.Fatalf("NewGoogleProvider() error: %v", err)
+	}
+
+	if err := provider.SaveToken(context.Background(), "google-oauth2|1", &oauth2.Token{AccessToken: "new"}); err != nil {
+		t.Fatalf("SaveToken() error: %v", err)
+	}
+	if got := store["google-oauth2|1"]; got.AccessToken != "new" || got.RefreshToken != "refresh" {
+		t.Errorf("Expected new access token with the stored refresh token, got %+v", got)
+	}
+}
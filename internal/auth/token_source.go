@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cobblepod/internal/config"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// identityRefetchInterval is how long a token read from Auth0 is trusted before
+// the identity is read again. Auth0 doesn't say when Google issued the token, so
+// this stays well inside Google's one hour token lifetime.
+const identityRefetchInterval = 10 * time.Minute
+
+// GoogleOAuthConfig returns the OAuth client used to refresh Google tokens, or
+// nil when cfg has no Google client ID and secret
+func GoogleOAuthConfig(cfg config.AuthConfig) *oauth2.Config {
+	if cfg.GoogleClientID == "" || cfg.GoogleClientSecret == "" {
+		return nil
+	}
+	return &oauth2.Config{
+		ClientID:     cfg.GoogleClientID,
+		ClientSecret: cfg.GoogleClientSecret,
+		Endpoint:     google.Endpoint,
+	}
+}
+
+// NewGoogleTokenSource returns a token source for the user's Google account.
+// When Auth0 holds a refresh token for the identity and a Google OAuth client is
+// configured, tokens are refreshed with Google directly. Otherwise the identity
+// is re-read from the Management API to pick up the latest token Auth0 holds.
+func (p *DefaultTokenProvider) NewGoogleTokenSource(ctx context.Context, userID string) (oauth2.TokenSource, error) {
+	identity, err := p.fetchGoogleIdentity(userID)
+	if err != nil {
+		return nil, err
+	}
+	return newGoogleTokenSource(ctx, userID, identity, p.google, p.fetchGoogleIdentity), nil
+}
+
+func newGoogleTokenSource(ctx context.Context, userID string, identity *googleIdentity, conf *oauth2.Config, fetch func(string) (*googleIdentity, error)) oauth2.TokenSource {
+	if conf != nil && identity.RefreshToken != "" {
+		// The access token's age is unknown, so mark it expired and let the first
+		// use refresh it; Google then reports the real expiry
+		return conf.TokenSource(ctx, &oauth2.Token{
+			AccessToken:  identity.AccessToken,
+			RefreshToken: identity.RefreshToken,
+			Expiry:       time.Now(),
+		})
+	}
+
+	source := &identityTokenSource{userID: userID, fetch: fetch}
+	return oauth2.ReuseTokenSource(source.token(identity), source)
+}
+
+// identityTokenSource re-reads the user's Google identity from Auth0
+type identityTokenSource struct {
+	userID string
+	fetch  func(userID string) (*googleIdentity, error)
+}
+
+func (s *identityTokenSource) Token() (*oauth2.Token, error) {
+	identity, err := s.fetch(s.userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.token(identity), nil
+}
+
+func (s *identityTokenSource) token(identity *googleIdentity) *oauth2.Token {
+	return &oauth2.Token{
+		AccessToken: identity.AccessToken,
+		TokenType:   "Bearer",
+		Expiry:      time.Now().Add(identityRefetchInterval),
+	}
+}
+
+// RefreshTokenProvider serves every user from a single Google refresh token,
+// for command line runs against one account without Auth0 or Redis
+type RefreshTokenProvider struct {
+	Config       *oauth2.Config
+	RefreshToken string
+}
+
+// NewRefreshTokenProvider returns a provider for refreshToken using cfg's
+// Google OAuth client, see GoogleOAuthConfig
+func NewRefreshTokenProvider(cfg config.AuthConfig, refreshToken string) (*RefreshTokenProvider, error) {
+	conf := GoogleOAuthConfig(cfg)
+	if conf == nil {
+		return nil, errors.New("GOOGLE_CLIENT_ID and GOOGLE_CLIENT_SECRET are required")
+	}
+	if refreshToken == "" {
+		return nil, errors.New("a Google refresh token is required")
+	}
+	return &RefreshTokenProvider{Config: conf, RefreshToken: refreshToken}, nil
+}
+
+func (p *RefreshTokenProvider) GetGoogleAccessToken(ctx context.Context, userID string) (string, error) {
+	source, err := p.GoogleTokenSource(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	token, err := source.Token()
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh Google token: %w", err)
+	}
+	return token.AccessToken, nil
+}
+
+func (p *RefreshTokenProvider) GoogleTokenSource(ctx context.Context, userID string) (oauth2.TokenSource, error) {
+	return p.Config.TokenSource(ctx, &oauth2.Token{RefreshToken: p.RefreshToken}), nil
+}
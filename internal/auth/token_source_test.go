@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cobblepod/internal/config"
+
+	"golang.org/x/oauth2"
+)
+
+func TestGoogleTokenSourceRefreshes(t *testing.T) {
+	refreshes := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refreshes++
+		if r.FormValue("refresh_token") != "refresh-token" {
+			t.Errorf("Expected refresh token in request, got %q", r.FormValue("refresh_token"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"fresh-token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	conf := &oauth2.Config{ClientID: "id", ClientSecret: "secret", Endpoint: oauth2.Endpoint{TokenURL: server.URL}}
+	identity := &googleIdentity{AccessToken: "stale-token", RefreshToken: "refresh-token"}
+	source := newGoogleTokenSource(context.Background(), "google-oauth2|1", identity, conf, nil)
+
+	for i := 0; i < 2; i++ {
+		token, err := source.Token()
+		if err != nil {
+			t.Fatalf("Token() error: %v", err)
+		}
+		if token.AccessToken != "fresh-token" {
+			t.Errorf("Expected refreshed token, got %q", token.AccessToken)
+		}
+	}
+	if refreshes != 1 {
+		t.Errorf("Expected one refresh, got %d", refreshes)
+	}
+}
+
+func TestGoogleTokenSourceWithoutRefreshToken(t *testing.T) {
+	fetches := 0
+	fetch := func(userID string) (*googleIdentity, error) {
+		fetches++
+		return &googleIdentity{AccessToken: "refetched-token"}, nil
+	}
+	conf := &oauth2.Config{ClientID: "id", ClientSecret: "secret"}
+	source := newGoogleTokenSource(context.Background(), "google-oauth2|1", &googleIdentity{AccessToken: "initial-token"}, conf, fetch)
+
+	token, err := source.Token()
+	if err != nil {
+		t.Fatalf("Token() error: %v", err)
+	}
+	if token.AccessToken != "initial-token" || fetches != 0 {
+		t.Errorf("Expected the initial token to be used until it is due, got %q after %d fetches", token.AccessToken, fetches)
+	}
+
+	token, err = (&identityTokenSource{userID: "google-oauth2|1", fetch: fetch}).Token()
+	if err != nil {
+		t.Fatalf("Token() error: %v", err)
+	}
+	if token.AccessToken != "refetched-token" || !token.Valid() {
+		t.Errorf("Expected a valid refetched token, got %+v", token)
+	}
+}
+
+func TestRefreshTokenProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("refresh_token") != "refresh-token" {
+			t.Errorf("Expected refresh token in request, got %q", r.FormValue("refresh_token"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"fresh-token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	cfg := config.AuthConfig{GoogleClientID: "id", GoogleClientSecret: "secret"}
+	if _, err := NewRefreshTokenProvider(cfg, ""); err == nil {
+		t.Error("Expected an error without a refresh token")
+	}
+	provider, err := NewRefreshTokenProvider(cfg, "refresh-token")
+	if err != nil {
+		t.Fatalf("NewRefreshTokenProvider() error: %v", err)
+	}
+	provider.Config.Endpoint = oauth2.Endpoint{TokenURL: server.URL}
+
+	token, err := provider.GetGoogleAccessToken(context.Background(), "any-user")
+	if err != nil {
+		t.Fatalf("GetGoogleAccessToken() error: %v", err)
+	}
+	if token != "fresh-token" {
+		t.Errorf("Expected refreshed token, got %q", token)
+	}
+}
@@ -1,41 +1,24 @@
+// Package config holds the deployment's settings. They are read from built-in
+// defaults, then an optional YAML file, then environment variables (see Load),
+// and handed to the services that use them. The constants below are fixed
+// limits rather than settings.
 package config
 
-import (
-	"os"
-	"strconv"
-)
-
-var (
-	// Google Drive and Cloud settings (legacy)
-	Scopes = []string{"https://www.googleapis.com/auth/drive"}
-
-	// Audio processing settings
-	DefaultSpeed     = 1.5
+// Google Drive and Cloud settings (legacy)
+var Scopes = []string{"https://www.googleapis.com/auth/drive"}
+
+// Audio processing limits. They aren't settings: the speeds are what a single
+// FFmpeg atempo filter accepts, and users pick theirs within them; the worker
+// counts keep a job's encodes and uploads within one worker's CPU and Drive's
+// per-user rate limit.
+const (
+	// DefaultSpeed is the playback speed used when a user hasn't picked one
+	DefaultSpeed = 1.5
+	// MaxFFMPEGWorkers is how many episodes a job encodes at once
 	MaxFFMPEGWorkers = 4
-
-	// State
-	ValkeyHost = getEnvWithDefault("VALKEY_HOST", "localhost")
-	ValkeyPort = getEnvInt("VALKEY_PORT", 6379)
+	// MaxUploadWorkers is how many encoded episodes are uploaded at once
+	MaxUploadWorkers = 2
+	// MinSpeed and MaxSpeed bound the playback speed a single FFmpeg atempo filter accepts
+	MinSpeed = 0.5
+	MaxSpeed = 2.0
 )
-
-func getEnvWithDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
-
-func getEnvInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if parsed, err := strconv.Atoi(value); err == nil {
-			return parsed
-		}
-	}
-	return defaultValue
-}
-
-// M3UQuery is the query used to search for M3U files in Google Drive
-const M3UQuery = "name contains '.m3u' and trashed=false"
-
-// RSSQuery is the query used to search for RSS files in Google Drive
-const RSSQuery = "name = 'playrun_addict.xml' and trashed=false"
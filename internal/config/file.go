@@ -0,0 +1,404 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"cobblepod/internal/cron"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the environment variable holding the config file path
+const FileEnv = "CONFIG_FILE"
+
+// DefaultDriveFolder is the top-level storage folder unless storage.drive_folder says otherwise
+const DefaultDriveFolder = "cobblepod"
+
+// Config is the typed form of every setting. Each field names its YAML key and
+// the environment variable that overrides it.
+type Config struct {
+	Server        ServerConfig       `yaml:"server"`
+	Worker        WorkerConfig       `yaml:"worker"`
+	Storage       StorageConfig      `yaml:"storage"`
+	Auth          AuthConfig         `yaml:"auth"`
+	Valkey        ValkeyConfig       `yaml:"valkey"`
+	Tracing       TracingConfig      `yaml:"tracing"`
+	Notifications NotificationConfig `yaml:"notifications"`
+}
+
+// ServerConfig configures the API server
+type ServerConfig struct {
+	Port int `yaml:"port" env:"PORT"`
+	// AdminPort serves probes and metrics, and shouldn't be exposed publicly
+	AdminPort         int    `yaml:"admin_port" env:"ADMIN_PORT"`
+	MaxBackupUploadMB int    `yaml:"max_backup_upload_mb" env:"MAX_BACKUP_UPLOAD_MB"`
+	WebhookBaseURL    string `yaml:"webhook_base_url" env:"WEBHOOK_BASE_URL"`
+	EpisodeBaseURL    string `yaml:"episode_base_url" env:"EPISODE_BASE_URL"`
+	EpisodeSecret     string `yaml:"episode_secret" env:"EPISODE_SECRET"`
+	// Requests to the sampled routes that succeed are logged at the sample rate
+	LogSampledRoutes []string `yaml:"log_sampled_routes" env:"LOG_SAMPLED_ROUTES"`
+	LogSampleRate    float64  `yaml:"log_sample_rate" env:"LOG_SAMPLE_RATE"`
+}
+
+// WorkerConfig configures job processing
+type WorkerConfig struct {
+	MaxJobsPerUser        int     `yaml:"max_jobs_per_user" env:"MAX_JOBS_PER_USER"`
+	DrainTimeoutSeconds   int     `yaml:"drain_timeout_seconds" env:"DRAIN_TIMEOUT_SECONDS"`
+	HealthPort            int     `yaml:"health_port" env:"HEALTH_PORT"`
+	MinFreeStorageMB      int     `yaml:"min_free_storage_mb" env:"MIN_FREE_STORAGE_MB"`
+	CopyThroughMaxSeconds int     `yaml:"copy_through_max_seconds" env:"COPY_THROUGH_MAX_SECONDS"`
+	CopyThroughMaxKbps    int     `yaml:"copy_through_max_kbps" env:"COPY_THROUGH_MAX_KBPS"`
+	MaxDownloadsAhead     int     `yaml:"max_downloads_ahead" env:"MAX_DOWNLOADS_AHEAD"`
+	FFmpegPath            string  `yaml:"ffmpeg_path" env:"FFMPEG_PATH"`
+	FFprobePath           string  `yaml:"ffprobe_path" env:"FFPROBE_PATH"`
+	FFmpegInputArgs       string  `yaml:"ffmpeg_input_args" env:"FFMPEG_INPUT_ARGS"`
+	FFmpegArgs            string  `yaml:"ffmpeg_args" env:"FFMPEG_ARGS"`
+	TTSCommand            string  `yaml:"tts_command" env:"TTS_COMMAND"`
+	TranscribeCommand     string  `yaml:"transcribe_command" env:"TRANSCRIBE_COMMAND"`
+	TranscribeURL         string  `yaml:"transcribe_url" env:"TRANSCRIBE_URL"`
+	TranscribeAPIKey      string  `yaml:"transcribe_api_key" env:"TRANSCRIBE_API_KEY"`
+	TranscribeModel       string  `yaml:"transcribe_model" env:"TRANSCRIBE_MODEL"`
+	FakeAudio             bool    `yaml:"fake_audio" env:"FAKE_AUDIO"`
+	FakeAudioLatencyMS    int     `yaml:"fake_audio_latency_ms" env:"FAKE_AUDIO_LATENCY_MS"`
+	FakeAudioFailureRate  float64 `yaml:"fake_audio_failure_rate" env:"FAKE_AUDIO_FAILURE_RATE"`
+	MaxJobsPerDay         int     `yaml:"max_jobs_per_day" env:"MAX_JOBS_PER_DAY"`
+	MaxEpisodesPerJob     int     `yaml:"max_episodes_per_job" env:"MAX_EPISODES_PER_JOB"`
+	MaxMinutesPerDay      int     `yaml:"max_minutes_per_day" env:"MAX_MINUTES_PER_DAY"`
+	JobRetentionDays      int     `yaml:"job_retention_days" env:"JOB_RETENTION_DAYS"`
+	ArchiveRetentionDays  int     `yaml:"archive_retention_days" env:"ARCHIVE_RETENTION_DAYS"`
+	PollSchedule          string  `yaml:"poll_schedule" env:"POLL_SCHEDULE"`
+}
+
+// StorageConfig configures the storage backend
+type StorageConfig struct {
+	DriveFolder            string `yaml:"drive_folder" env:"DRIVE_FOLDER"`
+	HealthURL              string `yaml:"health_url" env:"STORAGE_HEALTH_URL"`
+	BreakerFailures        int    `yaml:"breaker_failures" env:"STORAGE_BREAKER_FAILURES"`
+	BreakerCooldownSeconds int    `yaml:"breaker_cooldown_seconds" env:"STORAGE_BREAKER_COOLDOWN_SECONDS"`
+}
+
+// AuthConfig configures sign in and admin access
+type AuthConfig struct {
+	Provider           string   `yaml:"provider" env:"AUTH_PROVIDER"`
+	Auth0Domain        string   `yaml:"auth0_domain" env:"AUTH0_DOMAIN"`
+	Auth0Audience      string   `yaml:"auth0_audience" env:"AUTH0_AUDIENCE"`
+	Auth0ClientID      string   `yaml:"auth0_client_id" env:"AUTH0_CLIENT_ID"`
+	Auth0ClientSecret  string   `yaml:"auth0_client_secret" env:"AUTH0_CLIENT_SECRET"`
+	GoogleClientID     string   `yaml:"google_client_id" env:"GOOGLE_CLIENT_ID"`
+	GoogleClientSecret string   `yaml:"google_client_secret" env:"GOOGLE_CLIENT_SECRET"`
+	AdminUserIDs       []string `yaml:"admin_user_ids" env:"ADMIN_USER_IDS"`
+	AdminRoleClaim     string   `yaml:"admin_role_claim" env:"ADMIN_ROLE_CLAIM"`
+	AdminRole          string   `yaml:"admin_role" env:"ADMIN_ROLE"`
+}
+
+// ValkeyConfig configures the connection to Valkey (or Redis)
+type ValkeyConfig struct {
+	Host        string   `yaml:"host" env:"VALKEY_HOST"`
+	Port        int      `yaml:"port" env:"VALKEY_PORT"`
+	Addrs       []string `yaml:"addrs" env:"VALKEY_ADDRS"`
+	MasterName  string   `yaml:"master_name" env:"VALKEY_MASTER_NAME"`
+	ClusterMode bool     `yaml:"cluster_mode" env:"VALKEY_CLUSTER_MODE"`
+	Password    string   `yaml:"password" env:"VALKEY_PASSWORD"`
+}
+
+// TracingConfig configures OpenTelemetry export
+type TracingConfig struct {
+	Endpoint    string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"OTEL_TRACES_SAMPLE_RATIO"`
+}
+
+// NotificationConfig configures the channels job notifications are sent over
+type NotificationConfig struct {
+	SMTPHost         string `yaml:"smtp_host" env:"SMTP_HOST"`
+	SMTPPort         int    `yaml:"smtp_port" env:"SMTP_PORT"`
+	SMTPUsername     string `yaml:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword     string `yaml:"smtp_password" env:"SMTP_PASSWORD"`
+	SMTPFrom         string `yaml:"smtp_from" env:"SMTP_FROM"`
+	Email            string `yaml:"email" env:"NOTIFICATION_EMAIL"`
+	TelegramBotToken string `yaml:"telegram_bot_token" env:"TELEGRAM_BOT_TOKEN"`
+	NtfyServer       string `yaml:"ntfy_server" env:"NTFY_SERVER"`
+}
+
+// Defaults returns the built-in settings
+func Defaults() Config {
+	return Config{
+		Server: ServerConfig{
+			Port:              8080,
+			AdminPort:         8082,
+			MaxBackupUploadMB: 100,
+			// Podcast apps poll feeds and fetch episodes, and the web app polls jobs
+			LogSampledRoutes: []string{"/api/feed/:slug", "/api/episodes/:id/audio", "/api/jobs"},
+			LogSampleRate:    0.1,
+		},
+		Worker: WorkerConfig{
+			MaxJobsPerUser:       2,
+			DrainTimeoutSeconds:  300,
+			HealthPort:           8081,
+			MinFreeStorageMB:     500,
+			CopyThroughMaxKbps:   64,
+			MaxDownloadsAhead:    2,
+			JobRetentionDays:     7,
+			ArchiveRetentionDays: 30,
+			PollSchedule:         "*/15 * * * *",
+			TranscribeModel:      "whisper-1",
+			FFmpegPath:           "ffmpeg",
+			FFprobePath:          "ffprobe",
+		},
+		Storage: StorageConfig{
+			DriveFolder: DefaultDriveFolder,
+			HealthURL:   "https://www.googleapis.com/drive/v3/about",
+			// Stop calling Drive for a minute after 5 failures in a row
+			BreakerFailures:        5,
+			BreakerCooldownSeconds: 60,
+		},
+		Auth: AuthConfig{
+			Provider:       "auth0",
+			AdminRoleClaim: "https://cobblepod/roles",
+			AdminRole:      "admin",
+		},
+		Valkey:        ValkeyConfig{Host: "localhost", Port: 6379},
+		Tracing:       TracingConfig{SampleRatio: 1},
+		Notifications: NotificationConfig{SMTPPort: 587, SMTPFrom: "cobblepod@localhost", NtfyServer: "https://ntfy.sh"},
+	}
+}
+
+// Load reads the settings from the YAML file at path, if any, with environment
+// variables taking precedence, and validates them. Unknown keys in the file
+// and malformed environment values are errors.
+func Load(path string) (*Config, error) {
+	cfg := Defaults()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// FromEnv returns the defaults overridden by the environment, without checking
+// that they make up a complete deployment, for command line runs that only
+// need some of the settings
+func FromEnv() (*Config, error) {
+	cfg := Defaults()
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// applyEnv overrides every field whose environment variable is set. Every
+// malformed value is reported; those fields keep their current value.
+func (c *Config) applyEnv() error {
+	var errs []error
+	sections := reflect.ValueOf(c).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Field(i)
+		for j := 0; j < section.NumField(); j++ {
+			field := section.Field(j)
+			key := section.Type().Field(j).Tag.Get("env")
+			value := os.Getenv(key)
+			if key == "" || value == "" {
+				continue
+			}
+			if err := setField(field, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// setField parses value into field according to its type
+func setField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		field.SetInt(int64(parsed))
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		field.SetBool(parsed)
+	case reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		field.SetFloat(parsed)
+	case reflect.Slice:
+		var values []string
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		field.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+// Validate checks that the settings are usable, reporting every problem
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	port := func(key string, value int) {
+		check(value > 0 && value < 65536, "%s must be between 1 and 65535", key)
+	}
+	optionalURL := func(key, value string) {
+		if value != "" {
+			u, err := url.Parse(value)
+			check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "%s must be an http or https URL", key)
+		}
+	}
+
+	port("server.port", c.Server.Port)
+	port("server.admin_port", c.Server.AdminPort)
+	check(c.Server.AdminPort != c.Server.Port, "server.admin_port must differ from server.port")
+	check(c.Server.MaxBackupUploadMB > 0, "server.max_backup_upload_mb must be positive")
+	optionalURL("server.webhook_base_url", c.Server.WebhookBaseURL)
+	optionalURL("server.episode_base_url", c.Server.EpisodeBaseURL)
+	check(c.Server.EpisodeBaseURL == "" || len(c.Server.EpisodeSecret) >= 16, "server.episode_secret must be at least 16 characters to link episodes through server.episode_base_url")
+	for _, route := range c.Server.LogSampledRoutes {
+		check(strings.HasPrefix(route, "/"), "server.log_sampled_routes must be route paths such as /api/jobs, got %q", route)
+	}
+	check(c.Server.LogSampleRate >= 0 && c.Server.LogSampleRate <= 1, "server.log_sample_rate must be between 0 and 1")
+
+	check(c.Worker.MaxJobsPerUser > 0, "worker.max_jobs_per_user must be positive")
+	check(c.Worker.DrainTimeoutSeconds >= 0, "worker.drain_timeout_seconds must not be negative")
+	port("worker.health_port", c.Worker.HealthPort)
+	check(c.Worker.MinFreeStorageMB >= 0, "worker.min_free_storage_mb must not be negative")
+	check(c.Worker.CopyThroughMaxSeconds >= 0, "worker.copy_through_max_seconds must not be negative")
+	check(c.Worker.CopyThroughMaxKbps > 0, "worker.copy_through_max_kbps must be positive")
+	check(c.Worker.MaxDownloadsAhead >= 0, "worker.max_downloads_ahead must not be negative")
+	check(c.Worker.FFmpegPath != "" && c.Worker.FFprobePath != "", "worker.ffmpeg_path and worker.ffprobe_path are required")
+	check(!strings.ContainsAny(c.Worker.FFmpegInputArgs+c.Worker.FFmpegArgs, `"'`), "worker.ffmpeg_input_args and worker.ffmpeg_args are split on spaces and can't be quoted")
+	check(c.Worker.TTSCommand == "" || strings.Contains(c.Worker.TTSCommand, "{output}"), "worker.tts_command must write to {output}")
+	check(c.Worker.TranscribeCommand == "" || (strings.Contains(c.Worker.TranscribeCommand, "{input}") && strings.Contains(c.Worker.TranscribeCommand, "{output}")), "worker.transcribe_command must read {input} and write to {output}")
+	optionalURL("worker.transcribe_url", c.Worker.TranscribeURL)
+	check(c.Worker.TranscribeCommand == "" || c.Worker.TranscribeURL == "", "worker.transcribe_command and worker.transcribe_url can't both be set")
+	check(c.Worker.TranscribeURL == "" || c.Worker.TranscribeModel != "", "worker.transcribe_model is required with worker.transcribe_url")
+	check(c.Worker.FakeAudioLatencyMS >= 0, "worker.fake_audio_latency_ms must not be negative")
+	check(c.Worker.FakeAudioFailureRate >= 0 && c.Worker.FakeAudioFailureRate <= 1, "worker.fake_audio_failure_rate must be between 0 and 1")
+	check(c.Worker.MaxJobsPerDay >= 0, "worker.max_jobs_per_day must not be negative")
+	check(c.Worker.MaxEpisodesPerJob >= 0, "worker.max_episodes_per_job must not be negative")
+	check(c.Worker.MaxMinutesPerDay >= 0, "worker.max_minutes_per_day must not be negative")
+	check(c.Worker.JobRetentionDays > 0, "worker.job_retention_days must be positive")
+	check(c.Worker.ArchiveRetentionDays >= 0, "worker.archive_retention_days must not be negative")
+	if _, err := cron.Parse(c.Worker.PollSchedule); err != nil {
+		errs = append(errs, fmt.Errorf("worker.poll_schedule: %w", err))
+	}
+
+	check(c.Storage.DriveFolder != "", "storage.drive_folder is required")
+	optionalURL("storage.health_url", c.Storage.HealthURL)
+	check(c.Storage.BreakerFailures >= 0, "storage.breaker_failures must not be negative")
+	check(c.Storage.BreakerFailures == 0 || c.Storage.BreakerCooldownSeconds > 0, "storage.breaker_cooldown_seconds must be positive")
+
+	switch c.Auth.Provider {
+	case "auth0":
+		check(c.Auth.Auth0Domain != "", "auth.auth0_domain is required for the auth0 provider")
+	case "google":
+		check(c.Auth.GoogleClientID != "" && c.Auth.GoogleClientSecret != "", "auth.google_client_id and auth.google_client_secret are required for the google provider")
+	default:
+		errs = append(errs, fmt.Errorf("auth.provider must be auth0 or google, got %q", c.Auth.Provider))
+	}
+	check(c.Auth.AdminRoleClaim != "" && c.Auth.AdminRole != "", "auth.admin_role_claim and auth.admin_role are required")
+
+	if len(c.Valkey.Addrs) == 0 {
+		check(c.Valkey.Host != "", "valkey.host is required unless valkey.addrs is set")
+		port("valkey.port", c.Valkey.Port)
+	}
+	check(c.Valkey.MasterName == "" || !c.Valkey.ClusterMode, "valkey.master_name and valkey.cluster_mode can't both be set")
+
+	optionalURL("tracing.endpoint", c.Tracing.Endpoint)
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1")
+
+	if c.Notifications.SMTPHost != "" {
+		port("notifications.smtp_port", c.Notifications.SMTPPort)
+		check(c.Notifications.SMTPFrom != "", "notifications.smtp_from is required with notifications.smtp_host")
+	}
+	optionalURL("notifications.ntfy_server", c.Notifications.NtfyServer)
+
+	return errors.Join(errs...)
+}
+
+// MaxBackupUploadBytes caps the size of an uploaded backup file
+func (c ServerConfig) MaxBackupUploadBytes() int64 {
+	return int64(c.MaxBackupUploadMB) * 1024 * 1024
+}
+
+// DrainTimeout is how long a stopping worker lets its running job finish
+// before handing it back to the queue
+func (c WorkerConfig) DrainTimeout() time.Duration {
+	return time.Duration(c.DrainTimeoutSeconds) * time.Second
+}
+
+// MinFreeStorageBytes is the free space required in the storage backend before a job starts
+func (c WorkerConfig) MinFreeStorageBytes() int64 {
+	return int64(c.MinFreeStorageMB) * 1024 * 1024
+}
+
+// CopyThroughMaxDuration enables copy-through for source episodes no longer
+// than this: instead of being downloaded and re-encoded they are published as
+// is. Zero disables copy-through.
+func (c WorkerConfig) CopyThroughMaxDuration() time.Duration {
+	return time.Duration(c.CopyThroughMaxSeconds) * time.Second
+}
+
+// FakeAudioLatency is how long every simulated download and encode takes
+func (c WorkerConfig) FakeAudioLatency() time.Duration {
+	return time.Duration(c.FakeAudioLatencyMS) * time.Millisecond
+}
+
+// MaxEncodedPerDay is the audio a user's jobs may process per UTC day; zero means unlimited
+func (c WorkerConfig) MaxEncodedPerDay() time.Duration {
+	return time.Duration(c.MaxMinutesPerDay) * time.Minute
+}
+
+// JobRetention is how long finished jobs are kept, unless a user chose otherwise
+func (c WorkerConfig) JobRetention() time.Duration {
+	return time.Duration(c.JobRetentionDays) * 24 * time.Hour
+}
+
+// ArchiveRetention is how long episodes that dropped out of a feed are kept in
+// the archive folder before they're deleted; zero deletes them at once
+func (c WorkerConfig) ArchiveRetention() time.Duration {
+	return time.Duration(c.ArchiveRetentionDays) * 24 * time.Hour
+}
+
+// BreakerCooldown is how long storage calls stop once BreakerFailures in a row failed
+func (c StorageConfig) BreakerCooldown() time.Duration {
+	return time.Duration(c.BreakerCooldownSeconds) * time.Second
+}
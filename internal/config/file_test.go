@@ -0,0 +1,154 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeConfig writes a config file to a temporary directory
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "cobblepod.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `
+server:
+  port: 9090
+worker:
+  max_jobs_per_user: 4
+auth:
+  auth0_domain: tenant.auth0.com
+  admin_user_ids: [auth0|1, auth0|2]
+valkey:
+  host: valkey
+`)
+	// The environment overrides the file
+	t.Setenv("VALKEY_HOST", "valkey.internal")
+	t.Setenv("ADMIN_ROLE", "operator")
+
+	cfg, err := Load(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 9090, cfg.Server.Port)
+	assert.Equal(t, 4, cfg.Worker.MaxJobsPerUser)
+	assert.Equal(t, []string{"auth0|1", "auth0|2"}, cfg.Auth.AdminUserIDs)
+	assert.Equal(t, "valkey.internal", cfg.Valkey.Host)
+	assert.Equal(t, "operator", cfg.Auth.AdminRole)
+	// Unset keys keep their defaults
+	assert.Equal(t, 300, cfg.Worker.DrainTimeoutSeconds)
+	assert.Equal(t, 6379, cfg.Valkey.Port)
+}
+
+func TestLoadWithoutFile(t *testing.T) {
+	t.Setenv("AUTH0_DOMAIN", "tenant.auth0.com")
+	t.Setenv("ADMIN_USER_IDS", "auth0|1, auth0|2")
+
+	cfg, err := Load("")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"auth0|1", "auth0|2"}, cfg.Auth.AdminUserIDs)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Setenv("AUTH0_DOMAIN", "tenant.auth0.com")
+
+	_, err := Load(writeConfig(t, "worker:\n  max_jobs_per_usr: 4\n"))
+	assert.ErrorContains(t, err, "max_jobs_per_usr")
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+
+	t.Setenv("VALKEY_PORT", "six")
+	_, err = Load("")
+	assert.ErrorContains(t, err, `VALKEY_PORT: "six" is not an integer`)
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Defaults()
+	cfg.Auth.Auth0Domain = "tenant.auth0.com"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Server.Port = 0
+	cfg.Tracing.SampleRatio = 2
+	cfg.Notifications.NtfyServer = "ntfy.sh"
+	cfg.Worker.TTSCommand = "espeak-ng --stdin"
+	cfg.Worker.TranscribeCommand = "whisper-cli -f {input}"
+	cfg.Worker.MaxMinutesPerDay = -1
+	cfg.Worker.JobRetentionDays = 0
+	cfg.Worker.ArchiveRetentionDays = -1
+	cfg.Worker.FakeAudioFailureRate = 1.5
+	cfg.Worker.FFmpegPath = ""
+	cfg.Worker.PollSchedule = "*/15 6-23 * *"
+	cfg.Storage.BreakerFailures = -1
+	cfg.Server.EpisodeBaseURL = "https://cobblepod.example.com"
+	cfg.Server.LogSampleRate = -0.5
+	err := cfg.Validate()
+	assert.ErrorContains(t, err, "server.port")
+	assert.ErrorContains(t, err, "tracing.sample_ratio")
+	assert.ErrorContains(t, err, "notifications.ntfy_server")
+	assert.ErrorContains(t, err, "worker.tts_command")
+	assert.ErrorContains(t, err, "worker.transcribe_command")
+	assert.ErrorContains(t, err, "worker.max_minutes_per_day")
+	assert.ErrorContains(t, err, "worker.job_retention_days")
+	assert.ErrorContains(t, err, "worker.archive_retention_days")
+	assert.ErrorContains(t, err, "worker.fake_audio_failure_rate")
+	assert.ErrorContains(t, err, "worker.ffmpeg_path")
+	assert.ErrorContains(t, err, "worker.poll_schedule")
+	assert.ErrorContains(t, err, "storage.breaker_failures")
+	assert.ErrorContains(t, err, "server.episode_secret")
+	assert.ErrorContains(t, err, "server.log_sample_rate")
+
+	cfg = Defaults()
+	cfg.Auth.Provider = "google"
+	assert.ErrorContains(t, cfg.Validate(), "auth.google_client_id")
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("FFMPEG_PATH", "/opt/ffmpeg/bin/ffmpeg")
+
+	// Incomplete settings are fine, malformed ones aren't
+	cfg, err := FromEnv()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "/opt/ffmpeg/bin/ffmpeg", cfg.Worker.FFmpegPath)
+		assert.Equal(t, "", cfg.Auth.Auth0Domain)
+	}
+
+	t.Setenv("VALKEY_PORT", "six")
+	_, err = FromEnv()
+	assert.ErrorContains(t, err, "VALKEY_PORT")
+}
+
+func TestDurations(t *testing.T) {
+	cfg := Defaults()
+	cfg.Worker.DrainTimeoutSeconds = 60
+	cfg.Worker.FakeAudioLatencyMS = 250
+	cfg.Server.MaxBackupUploadMB = 10
+
+	assert.Equal(t, time.Minute, cfg.Worker.DrainTimeout())
+	assert.Equal(t, 250*time.Millisecond, cfg.Worker.FakeAudioLatency())
+	assert.Equal(t, 7*24*time.Hour, cfg.Worker.JobRetention())
+	assert.Equal(t, int64(10*1024*1024), cfg.Server.MaxBackupUploadBytes())
+}
+
+func TestExampleConfig(t *testing.T) {
+	t.Setenv("AUTH0_DOMAIN", "tenant.auth0.com")
+
+	// The example lists every key with its default
+	cfg, err := Load("../../cobblepod.example.yaml")
+	if assert.NoError(t, err) {
+		expected := Defaults()
+		expected.Auth.Auth0Domain = "tenant.auth0.com"
+		expected.Auth.AdminUserIDs = []string{}
+		expected.Valkey.Addrs = []string{}
+		assert.Equal(t, expected, *cfg)
+	}
+}
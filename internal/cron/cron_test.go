@@ -0,0 +1,93 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr  string
+		valid bool
+	}{
+		{expr: "*/15 * * * *", valid: true},
+		{expr: "*/15 6-23 * * *", valid: true},
+		{expr: "0,30 7 1-15 1-12/2 1-5", valid: true},
+		{expr: "5/20 * * * 7", valid: true},
+		{expr: "* * * *"},
+		{expr: "60 * * * *"},
+		{expr: "* 23-6 * * *"},
+		{expr: "*/0 * * * *"},
+		{expr: "* * 0 * *"},
+		{expr: "@hourly"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			if tt.valid && err != nil {
+				t.Errorf("Parse(%q) failed: %v", tt.expr, err)
+			}
+			if !tt.valid && err == nil {
+				t.Errorf("Parse(%q) succeeded, want an error", tt.expr)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	// Wednesday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2025, time.January, day, hour, minute, 30, 0, time.UTC)
+	}
+	tests := []struct {
+		expr     string
+		t        time.Time
+		expected bool
+	}{
+		{expr: "*/15 6-23 * * *", t: at(1, 6, 45), expected: true},
+		{expr: "*/15 6-23 * * *", t: at(1, 6, 46), expected: false},
+		{expr: "*/15 6-23 * * *", t: at(1, 3, 0), expected: false},
+		{expr: "5/20 * * * *", t: at(1, 3, 45), expected: true},
+		{expr: "5/20 * * * *", t: at(1, 3, 0), expected: false},
+		{expr: "0 8 * * 1-5", t: at(1, 8, 0), expected: true},
+		{expr: "0 8 * * 1-5", t: at(4, 8, 0), expected: false},
+		{expr: "0 8 * * 7", t: at(5, 8, 0), expected: true},
+		// Restricting both days matches either
+		{expr: "0 8 15 * 3", t: at(1, 8, 0), expected: true},
+		{expr: "0 8 15 * 3", t: at(15, 8, 0), expected: true},
+		{expr: "0 8 15 * 3", t: at(16, 8, 0), expected: false},
+		{expr: "0 8 * 2 *", t: at(1, 8, 0), expected: false},
+	}
+	for _, tt := range tests {
+		schedule, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", tt.expr, err)
+		}
+		if got := schedule.Matches(tt.t); got != tt.expected {
+			t.Errorf("%q Matches(%s) = %v, want %v", tt.expr, tt.t.Format(time.RFC1123), got, tt.expected)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	schedule, err := Parse("*/15 6-23 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2025, time.January, 1, 5, 50, 30, 0, time.UTC)
+	if got, want := schedule.Next(at), time.Date(2025, time.January, 1, 6, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next() = %v, want %v", got, want)
+	}
+	// The minute t falls in counts
+	if got, want := schedule.Next(at.Add(25*time.Minute)), time.Date(2025, time.January, 1, 6, 0, 0, 0, time.UTC).Add(15*time.Minute); !got.Equal(want) {
+		t.Errorf("Next() = %v, want %v", got, want)
+	}
+
+	never, err := Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := never.Next(at); !got.IsZero() {
+		t.Errorf("Next() = %v, want zero for a date that never comes", got)
+	}
+}
@@ -0,0 +1,206 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeadLetterQueue defines the interface for re-driving dead-lettered jobs
+type DeadLetterQueue interface {
+	RequeueFailed(ctx context.Context, jobID string) (*queue.Job, error)
+}
+
+// QueueInspector defines the interface for looking into the queue across all users
+type QueueInspector interface {
+	GetQueueStats(ctx context.Context) (*queue.QueueStats, error)
+	GetAllRunningJobs(ctx context.Context) ([]*queue.Job, error)
+	GetUserLocks(ctx context.Context) ([]*queue.UserLock, error)
+	ReleaseUserLock(ctx context.Context, userID string) error
+}
+
+// FairShareAdmin defines the interface for changing users' share of the workers
+type FairShareAdmin interface {
+	SetUserWeight(ctx context.Context, userID string, weight float64) error
+}
+
+// SetUserWeightRequest represents the request body for setting a user's weight
+type SetUserWeightRequest struct {
+	Weight float64 `json:"weight" binding:"required"` // Share of the workers relative to the default of 1
+}
+
+// GetRunningJobsResponse represents the response for listing running jobs
+type GetRunningJobsResponse struct {
+	Jobs []JobResponse `json:"jobs"`
+}
+
+// GetUserLocksResponse represents the response for listing user locks
+type GetUserLocksResponse struct {
+	Locks []*queue.UserLock `json:"locks"`
+}
+
+// HandleRetryJob returns a handler that requeues a dead-lettered job
+// @Summary      Retry dead-lettered job
+// @Description  Move a job that failed for a system-side reason (lock acquisition, panic) back into the waiting queue (admin only)
+// @Tags         admin
+// @Produce      json
+// @Param        id path string true "Job ID"
+// @Success      200  {object}  JobResponse
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/jobs/{id}/retry [post]
+func HandleRetryJob(jobQueue DeadLetterQueue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jobID := c.Param("id")
+
+		job, err := jobQueue.RequeueFailed(c.Request.Context(), jobID)
+		if errors.Is(err, queue.ErrJobNotDeadLettered) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job is not in the dead-letter queue"})
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to requeue job", "error", err, "job_id", jobID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to requeue job"})
+			return
+		}
+
+		slog.Info("Job requeued by admin", "job_id", jobID, "admin_id", c.GetString("user_id"))
+		c.JSON(http.StatusOK, newJobResponse(job))
+	}
+}
+
+// HandleGetQueueStats returns a handler that reports global queue depth
+// @Summary      Get queue stats
+// @Description  Count jobs waiting, scheduled, running and finished across all users (admin only)
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  queue.QueueStats
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/queue [get]
+func HandleGetQueueStats(inspector QueueInspector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stats, err := inspector.GetQueueStats(c.Request.Context())
+		if err != nil {
+			slog.Error("Failed to get queue stats", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get queue stats"})
+			return
+		}
+		c.JSON(http.StatusOK, stats)
+	}
+}
+
+// HandleGetRunningJobs returns a handler that lists running jobs of every user
+// @Summary      List running jobs
+// @Description  List the jobs currently running across all users (admin only)
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  GetRunningJobsResponse
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/jobs/running [get]
+func HandleGetRunningJobs(inspector QueueInspector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jobs, err := inspector.GetAllRunningJobs(c.Request.Context())
+		if err != nil {
+			slog.Error("Failed to get running jobs", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get running jobs"})
+			return
+		}
+		c.JSON(http.StatusOK, GetRunningJobsResponse{Jobs: newJobResponses(jobs)})
+	}
+}
+
+// HandleGetUserLocks returns a handler that lists users' running slots and feed locks
+// @Summary      List user locks
+// @Description  List users holding running slots or a feed lock, stuck ones first. A lock is stuck when the slot count doesn't match the user's running jobs, or the feed is locked with no job running (admin only)
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  GetUserLocksResponse
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/locks [get]
+func HandleGetUserLocks(inspector QueueInspector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		locks, err := inspector.GetUserLocks(c.Request.Context())
+		if err != nil {
+			slog.Error("Failed to get user locks", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user locks"})
+			return
+		}
+		c.JSON(http.StatusOK, GetUserLocksResponse{Locks: locks})
+	}
+}
+
+// HandleReleaseUserLock returns a handler that force-releases a user's locks
+// @Summary      Release user lock
+// @Description  Release a user's feed lock and reset their running slot count to the jobs actually running (admin only)
+// @Tags         admin
+// @Param        userID path string true "User ID"
+// @Success      204
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/locks/{userID} [delete]
+func HandleReleaseUserLock(inspector QueueInspector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Param("userID")
+
+		if err := inspector.ReleaseUserLock(c.Request.Context(), userID); err != nil {
+			slog.Error("Failed to release user lock", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release user lock"})
+			return
+		}
+
+		slog.Warn("User lock released by admin", "user_id", userID, "admin_id", c.GetString("user_id"))
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// HandleSetUserWeight returns a handler that sets a user's fair share weight
+// @Summary      Set user weight
+// @Description  Set a user's share of the workers relative to other users. Waiting jobs are served in fair share order, and a user with weight 2 is served about twice as often as one with the default of 1 (admin only)
+// @Tags         admin
+// @Accept       json
+// @Param        userID path string true "User ID"
+// @Param        request body SetUserWeightRequest true "New weight"
+// @Success      204
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/users/{userID}/weight [put]
+func HandleSetUserWeight(store FairShareAdmin) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Param("userID")
+
+		var req SetUserWeightRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "weight is required"})
+			return
+		}
+
+		if err := store.SetUserWeight(c.Request.Context(), userID, req.Weight); err != nil {
+			if errors.Is(err, queue.ErrInvalidWeight) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			slog.Error("Failed to set user weight", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set user weight"})
+			return
+		}
+
+		slog.Info("User weight set by admin", "user_id", userID, "weight", req.Weight, "admin_id", c.GetString("user_id"))
+		c.Status(http.StatusNoContent)
+	}
+}
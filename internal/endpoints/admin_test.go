@@ -0,0 +1,221 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockDeadLetterQueue is a mock implementation of DeadLetterQueue
+type MockDeadLetterQueue struct {
+	mock.Mock
+}
+
+func (m *MockDeadLetterQueue) RequeueFailed(ctx context.Context, jobID string) (*queue.Job, error) {
+	args := m.Called(ctx, jobID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*queue.Job), args.Error(1)
+}
+
+func TestHandleRetryJob(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		job          *queue.Job
+		err          error
+		expectedCode int
+	}{
+		{name: "Success", job: &queue.Job{ID: "job-1", Status: queue.JobStatusQueued}, expectedCode: http.StatusOK},
+		{name: "Not dead-lettered", err: queue.ErrJobNotDeadLettered, expectedCode: http.StatusNotFound},
+		{name: "Queue error", err: errors.New("redis down"), expectedCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockQueue := new(MockDeadLetterQueue)
+			mockQueue.On("RequeueFailed", mock.Anything, "job-1").Return(tt.job, tt.err)
+			router := gin.New()
+			router.POST("/admin/jobs/:id/retry", HandleRetryJob(mockQueue))
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/admin/jobs/job-1/retry", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.job != nil {
+				var job queue.Job
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+				assert.Equal(t, queue.JobStatusQueued, job.Status)
+			}
+			mockQueue.AssertExpectations(t)
+		})
+	}
+}
+
+// MockQueueInspector is a mock implementation of QueueInspector
+type MockQueueInspector struct {
+	mock.Mock
+}
+
+func (m *MockQueueInspector) GetQueueStats(ctx context.Context) (*queue.QueueStats, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*queue.QueueStats), args.Error(1)
+}
+
+func (m *MockQueueInspector) GetAllRunningJobs(ctx context.Context) ([]*queue.Job, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*queue.Job), args.Error(1)
+}
+
+func (m *MockQueueInspector) GetUserLocks(ctx context.Context) ([]*queue.UserLock, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*queue.UserLock), args.Error(1)
+}
+
+func (m *MockQueueInspector) ReleaseUserLock(ctx context.Context, userID string) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func newInspectorRouter(inspector QueueInspector) *gin.Engine {
+	router := gin.New()
+	router.GET("/admin/queue", HandleGetQueueStats(inspector))
+	router.GET("/admin/jobs/running", HandleGetRunningJobs(inspector))
+	router.GET("/admin/locks", HandleGetUserLocks(inspector))
+	router.DELETE("/admin/locks/:userID", HandleReleaseUserLock(inspector))
+	return router
+}
+
+func TestHandleGetQueueStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	inspector := new(MockQueueInspector)
+	inspector.On("GetQueueStats", mock.Anything).Return(&queue.QueueStats{Waiting: 3, Running: 2, RunningUsers: 1}, nil).Once()
+	inspector.On("GetQueueStats", mock.Anything).Return(nil, errors.New("redis down")).Once()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/admin/queue", nil)
+	newInspectorRouter(inspector).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var stats queue.QueueStats
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, int64(3), stats.Waiting)
+	assert.Equal(t, int64(2), stats.Running)
+
+	w = httptest.NewRecorder()
+	newInspectorRouter(inspector).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	inspector.AssertExpectations(t)
+}
+
+func TestHandleGetRunningJobs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	inspector := new(MockQueueInspector)
+	inspector.On("GetAllRunningJobs", mock.Anything).Return(nil, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/admin/jobs/running", nil)
+	newInspectorRouter(inspector).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"jobs":[]}`, w.Body.String())
+}
+
+func TestHandleGetUserLocks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	inspector := new(MockQueueInspector)
+	inspector.On("GetUserLocks", mock.Anything).Return([]*queue.UserLock{
+		{UserID: "user-1", RunningSlots: 2, RunningJobs: 0, Stuck: true},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/admin/locks", nil)
+	newInspectorRouter(inspector).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response GetUserLocksResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(t, response.Locks, 1) {
+		assert.Equal(t, "user-1", response.Locks[0].UserID)
+		assert.True(t, response.Locks[0].Stuck)
+	}
+}
+
+func TestHandleReleaseUserLock(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	inspector := new(MockQueueInspector)
+	inspector.On("ReleaseUserLock", mock.Anything, "user-1").Return(nil)
+	inspector.On("ReleaseUserLock", mock.Anything, "user-2").Return(errors.New("redis down"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/admin/locks/user-1", nil)
+	newInspectorRouter(inspector).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/admin/locks/user-2", nil)
+	newInspectorRouter(inspector).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	inspector.AssertExpectations(t)
+}
+
+// MockFairShareAdmin is a mock implementation of FairShareAdmin
+type MockFairShareAdmin struct {
+	mock.Mock
+}
+
+func (m *MockFairShareAdmin) SetUserWeight(ctx context.Context, userID string, weight float64) error {
+	args := m.Called(ctx, userID, weight)
+	return args.Error(0)
+}
+
+func TestHandleSetUserWeight(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := new(MockFairShareAdmin)
+	store.On("SetUserWeight", mock.Anything, "user-1", 2.0).Return(nil)
+	store.On("SetUserWeight", mock.Anything, "user-1", -1.0).Return(queue.ErrInvalidWeight)
+	router := gin.New()
+	router.PUT("/admin/users/:userID/weight", HandleSetUserWeight(store))
+
+	for _, tt := range []struct {
+		body string
+		code int
+	}{
+		{`{"weight": 2}`, http.StatusNoContent},
+		{`{"weight": -1}`, http.StatusBadRequest},
+		{`{}`, http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/admin/users/user-1/weight", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tt.code, w.Code, tt.body)
+	}
+	store.AssertExpectations(t)
+}
@@ -0,0 +1,133 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AnnouncementStore defines the interface for managing deployment-wide announcements
+type AnnouncementStore interface {
+	GetAnnouncements(ctx context.Context) ([]*queue.Announcement, error)
+	SaveAnnouncement(ctx context.Context, announcement *queue.Announcement) error
+	DeleteAnnouncement(ctx context.Context, id string) (bool, error)
+}
+
+// GetAnnouncementsResponse represents the response for the announcements endpoint
+type GetAnnouncementsResponse struct {
+	Announcements []*queue.Announcement `json:"announcements"`
+}
+
+// CreateAnnouncementRequest represents the body for creating an announcement
+type CreateAnnouncementRequest struct {
+	Message   string                     `json:"message" binding:"required"`
+	Severity  queue.AnnouncementSeverity `json:"severity" binding:"required"`
+	ExpiresAt time.Time                  `json:"expires_at" binding:"required"`
+}
+
+// HandleGetAnnouncements returns a handler that lists active announcements
+// @Summary      Get announcements
+// @Description  List unexpired deployment-wide announcements, oldest first
+// @Tags         announcements
+// @Produce      json
+// @Success      200  {object}  GetAnnouncementsResponse
+// @Failure      500  {object}  map[string]string
+// @Router       /announcements [get]
+func HandleGetAnnouncements(store AnnouncementStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		announcements, err := store.GetAnnouncements(c.Request.Context())
+		if err != nil {
+			slog.Error("Failed to fetch announcements", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch announcements"})
+			return
+		}
+
+		c.JSON(http.StatusOK, GetAnnouncementsResponse{Announcements: announcements})
+	}
+}
+
+// HandleCreateAnnouncement returns a handler that publishes a new announcement
+// @Summary      Create announcement
+// @Description  Publish a deployment-wide announcement (admin only). severity is one of info, warning or critical
+// @Tags         announcements
+// @Accept       json
+// @Produce      json
+// @Param        announcement body CreateAnnouncementRequest true "Announcement"
+// @Success      201  {object}  queue.Announcement
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /announcements [post]
+func HandleCreateAnnouncement(store AnnouncementStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateAnnouncementRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid announcement"})
+			return
+		}
+
+		announcement := &queue.Announcement{
+			ID:        uuid.New().String(),
+			Message:   req.Message,
+			Severity:  req.Severity,
+			CreatedAt: time.Now(),
+			ExpiresAt: req.ExpiresAt,
+		}
+		if err := announcement.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !announcement.ExpiresAt.After(announcement.CreatedAt) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+			return
+		}
+
+		if err := store.SaveAnnouncement(c.Request.Context(), announcement); err != nil {
+			if errors.Is(err, queue.ErrInvalidAnnouncement) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			slog.Error("Failed to save announcement", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save announcement"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, announcement)
+	}
+}
+
+// HandleDeleteAnnouncement returns a handler that removes an announcement
+// @Summary      Delete announcement
+// @Description  Remove a deployment-wide announcement before it expires (admin only)
+// @Tags         announcements
+// @Param        id path string true "Announcement ID"
+// @Success      204
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /announcements/{id} [delete]
+func HandleDeleteAnnouncement(store AnnouncementStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		removed, err := store.DeleteAnnouncement(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			slog.Error("Failed to delete announcement", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete announcement"})
+			return
+		}
+		if !removed {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Announcement not found"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,201 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cobblepod/internal/config"
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockAnnouncementStore is a mock implementation of AnnouncementStore
+type MockAnnouncementStore struct {
+	mock.Mock
+}
+
+func (m *MockAnnouncementStore) GetAnnouncements(ctx context.Context) ([]*queue.Announcement, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*queue.Announcement), args.Error(1)
+}
+
+func (m *MockAnnouncementStore) SaveAnnouncement(ctx context.Context, announcement *queue.Announcement) error {
+	args := m.Called(ctx, announcement)
+	return args.Error(0)
+}
+
+func (m *MockAnnouncementStore) DeleteAnnouncement(ctx context.Context, id string) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestHandleGetAnnouncements(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Success", func(t *testing.T) {
+		store := new(MockAnnouncementStore)
+		store.On("GetAnnouncements", mock.Anything).Return([]*queue.Announcement{
+			{ID: "a1", Message: "Maintenance tonight", Severity: queue.SeverityWarning},
+		}, nil)
+		router := gin.New()
+		router.GET("/announcements", HandleGetAnnouncements(store))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/announcements", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response GetAnnouncementsResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response.Announcements, 1)
+		assert.Equal(t, "Maintenance tonight", response.Announcements[0].Message)
+	})
+
+	t.Run("Store error", func(t *testing.T) {
+		store := new(MockAnnouncementStore)
+		store.On("GetAnnouncements", mock.Anything).Return([]*queue.Announcement(nil), errors.New("redis down"))
+		router := gin.New()
+		router.GET("/announcements", HandleGetAnnouncements(store))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/announcements", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestHandleCreateAnnouncement(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Success", func(t *testing.T) {
+		store := new(MockAnnouncementStore)
+		store.On("SaveAnnouncement", mock.Anything, mock.MatchedBy(func(a *queue.Announcement) bool {
+			return a.ID != "" && a.Message == "Drive quota issues" && a.Severity == queue.SeverityCritical
+		})).Return(nil)
+		router := gin.New()
+		router.POST("/announcements", HandleCreateAnnouncement(store))
+
+		body := fmt.Sprintf(`{"message":"Drive quota issues","severity":"critical","expires_at":%q}`,
+			time.Now().Add(time.Hour).Format(time.RFC3339))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/announcements", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Unknown severity", func(t *testing.T) {
+		store := new(MockAnnouncementStore)
+		router := gin.New()
+		router.POST("/announcements", HandleCreateAnnouncement(store))
+
+		body := fmt.Sprintf(`{"message":"hi","severity":"urgent","expires_at":%q}`,
+			time.Now().Add(time.Hour).Format(time.RFC3339))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/announcements", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		store.AssertNotCalled(t, "SaveAnnouncement", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Already expired", func(t *testing.T) {
+		store := new(MockAnnouncementStore)
+		router := gin.New()
+		router.POST("/announcements", HandleCreateAnnouncement(store))
+
+		body := fmt.Sprintf(`{"message":"hi","severity":"info","expires_at":%q}`,
+			time.Now().Add(-time.Hour).Format(time.RFC3339))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/announcements", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandleDeleteAnnouncement(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		removed  bool
+		err      error
+		expected int
+	}{
+		{name: "Deleted", removed: true, expected: http.StatusNoContent},
+		{name: "Not found", removed: false, expected: http.StatusNotFound},
+		{name: "Store error", err: errors.New("redis down"), expected: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := new(MockAnnouncementStore)
+			store.On("DeleteAnnouncement", mock.Anything, "a1").Return(tt.removed, tt.err)
+			router := gin.New()
+			router.DELETE("/announcements/:id", HandleDeleteAnnouncement(store))
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("DELETE", "/announcements/a1", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+}
+
+func TestAdminMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := config.Defaults().Auth
+	cfg.AdminUserIDs = []string{"admin-user"}
+
+	tests := []struct {
+		name     string
+		userID   string
+		roles    []string
+		expected int
+	}{
+		{name: "Admin", userID: "admin-user", expected: http.StatusOK},
+		{name: "Admin role", userID: "regular-user", roles: []string{"editor", "admin"}, expected: http.StatusOK},
+		{name: "Not admin", userID: "regular-user", roles: []string{"editor"}, expected: http.StatusForbidden},
+		{name: "Unauthenticated", userID: "", expected: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				if tt.userID != "" {
+					c.Set("user_id", tt.userID)
+					c.Set("roles", tt.roles)
+				}
+				c.Next()
+			})
+			router.GET("/admin", AdminMiddleware(cfg), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/admin", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyStore defines the interface for managing a user's API keys
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, userID string, name string) (*queue.APIKey, string, error)
+	ListAPIKeys(ctx context.Context, userID string) ([]*queue.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID string, keyID string) (bool, error)
+}
+
+// APIKeyVerifier resolves an API key to the user it belongs to
+type APIKeyVerifier interface {
+	LookupAPIKey(ctx context.Context, secret string) (string, error)
+}
+
+// CreateAPIKeyRequest represents the body for creating an API key
+type CreateAPIKeyRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// CreateAPIKeyResponse returns a new key. The key itself is not shown again.
+type CreateAPIKeyResponse struct {
+	Key    string        `json:"key"`
+	APIKey *queue.APIKey `json:"api_key"`
+}
+
+// GetAPIKeysResponse represents the response for listing API keys
+type GetAPIKeysResponse struct {
+	APIKeys []*queue.APIKey `json:"api_keys"`
+}
+
+// WithAPIKeys accepts API keys as bearer tokens, passing anything else to next
+func WithAPIKeys(verifier APIKeyVerifier, next TokenValidator) TokenValidator {
+	return func(ctx context.Context, token string) (Identity, error) {
+		if !strings.HasPrefix(token, queue.APIKeyPrefix) {
+			return next(ctx, token)
+		}
+		userID, err := verifier.LookupAPIKey(ctx, token)
+		if err != nil {
+			return Identity{}, err
+		}
+		if userID == "" {
+			return Identity{}, errors.New("unknown API key")
+		}
+		// API keys never carry roles
+		return Identity{UserID: userID}, nil
+	}
+}
+
+// HandleGetAPIKeys returns a handler that lists the user's API keys
+// @Summary      List API keys
+// @Description  List the authenticated user's API keys, oldest first. Keys themselves are never returned, only a hint of their first characters
+// @Tags         settings
+// @Produce      json
+// @Success      200  {object}  GetAPIKeysResponse
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /settings/api-keys [get]
+func HandleGetAPIKeys(store APIKeyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		keys, err := store.ListAPIKeys(c.Request.Context(), userID)
+		if err != nil {
+			slog.Error("Failed to fetch API keys", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API keys"})
+			return
+		}
+
+		c.JSON(http.StatusOK, GetAPIKeysResponse{APIKeys: keys})
+	}
+}
+
+// HandleCreateAPIKey returns a handler that issues a new API key
+// @Summary      Create API key
+// @Description  Issue an API key for scripts to upload backups and query jobs with. Send it as a bearer token; it is only shown in this response
+// @Tags         settings
+// @Accept       json
+// @Produce      json
+// @Param        request body CreateAPIKeyRequest true "Key name"
+// @Success      201  {object}  CreateAPIKeyResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /settings/api-keys [post]
+func HandleCreateAPIKey(store APIKeyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var req CreateAPIKeyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+			return
+		}
+
+		key, secret, err := store.CreateAPIKey(c.Request.Context(), userID, req.Name)
+		if err != nil {
+			switch {
+			case errors.Is(err, queue.ErrInvalidAPIKeyName):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			case errors.Is(err, queue.ErrTooManyAPIKeys):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			default:
+				slog.Error("Failed to create API key", "error", err, "user_id", userID)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+			}
+			return
+		}
+
+		slog.Info("API key created", "user_id", userID, "key_id", key.ID)
+		c.JSON(http.StatusCreated, CreateAPIKeyResponse{Key: secret, APIKey: key})
+	}
+}
+
+// HandleRevokeAPIKey returns a handler that revokes one of the user's API keys
+// @Summary      Revoke API key
+// @Description  Revoke an API key; requests using it are rejected from then on
+// @Tags         settings
+// @Param        id path string true "API key ID"
+// @Success      204
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /settings/api-keys/{id} [delete]
+func HandleRevokeAPIKey(store APIKeyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		removed, err := store.RevokeAPIKey(c.Request.Context(), userID, c.Param("id"))
+		if err != nil {
+			slog.Error("Failed to revoke API key", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+			return
+		}
+		if !removed {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+
+		slog.Info("API key revoked", "user_id", userID, "key_id", c.Param("id"))
+		c.Status(http.StatusNoContent)
+	}
+}
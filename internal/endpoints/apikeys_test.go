@@ -0,0 +1,165 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockAPIKeyStore is a mock implementation of APIKeyStore and APIKeyVerifier
+type MockAPIKeyStore struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyStore) CreateAPIKey(ctx context.Context, userID string, name string) (*queue.APIKey, string, error) {
+	args := m.Called(ctx, userID, name)
+	if args.Get(0) == nil {
+		return nil, "", args.Error(2)
+	}
+	return args.Get(0).(*queue.APIKey), args.String(1), args.Error(2)
+}
+
+func (m *MockAPIKeyStore) ListAPIKeys(ctx context.Context, userID string) ([]*queue.APIKey, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*queue.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyStore) RevokeAPIKey(ctx context.Context, userID string, keyID string) (bool, error) {
+	args := m.Called(ctx, userID, keyID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockAPIKeyStore) LookupAPIKey(ctx context.Context, secret string) (string, error) {
+	args := m.Called(ctx, secret)
+	return args.String(0), args.Error(1)
+}
+
+func newAPIKeyRouter(store APIKeyStore) *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "test-user")
+		c.Next()
+	})
+	router.GET("/settings/api-keys", HandleGetAPIKeys(store))
+	router.POST("/settings/api-keys", HandleCreateAPIKey(store))
+	router.DELETE("/settings/api-keys/:id", HandleRevokeAPIKey(store))
+	return router
+}
+
+func TestHandleGetAPIKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := new(MockAPIKeyStore)
+	keys := []*queue.APIKey{{ID: "key-1", Name: "cron", Hint: "cpk_abcdef", CreatedAt: time.Now()}}
+	store.On("ListAPIKeys", mock.Anything, "test-user").Return(keys, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/settings/api-keys", nil)
+	newAPIKeyRouter(store).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response GetAPIKeysResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.APIKeys, 1)
+	assert.Equal(t, "cpk_abcdef", response.APIKeys[0].Hint)
+	assert.NotContains(t, w.Body.String(), "hash")
+}
+
+func TestHandleCreateAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	post := func(store APIKeyStore, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/settings/api-keys", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		newAPIKeyRouter(store).ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		store := new(MockAPIKeyStore)
+		key := &queue.APIKey{ID: "key-1", Name: "cron", Hint: "cpk_abcdef"}
+		store.On("CreateAPIKey", mock.Anything, "test-user", "cron").Return(key, "cpk_abcdefsecret", nil)
+
+		w := post(store, `{"name":"cron"}`)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var response CreateAPIKeyResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "cpk_abcdefsecret", response.Key)
+		assert.Equal(t, "key-1", response.APIKey.ID)
+	})
+
+	t.Run("Missing name", func(t *testing.T) {
+		w := post(new(MockAPIKeyStore), `{}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Too many keys", func(t *testing.T) {
+		store := new(MockAPIKeyStore)
+		store.On("CreateAPIKey", mock.Anything, "test-user", "cron").Return(nil, "", fmt.Errorf("%w: at most 10", queue.ErrTooManyAPIKeys))
+
+		w := post(store, `{"name":"cron"}`)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
+
+func TestHandleRevokeAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := new(MockAPIKeyStore)
+	store.On("RevokeAPIKey", mock.Anything, "test-user", "key-1").Return(true, nil)
+	store.On("RevokeAPIKey", mock.Anything, "test-user", "missing").Return(false, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/settings/api-keys/key-1", nil)
+	newAPIKeyRouter(store).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/settings/api-keys/missing", nil)
+	newAPIKeyRouter(store).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestWithAPIKeys(t *testing.T) {
+	store := new(MockAPIKeyStore)
+	store.On("LookupAPIKey", mock.Anything, "cpk_valid").Return("key-user", nil)
+	store.On("LookupAPIKey", mock.Anything, "cpk_revoked").Return("", nil)
+	jwt := func(ctx context.Context, token string) (Identity, error) {
+		if token == "jwt" {
+			return Identity{UserID: "jwt-user", Roles: []string{"admin"}}, nil
+		}
+		return Identity{}, errors.New("malformed token")
+	}
+	validate := WithAPIKeys(store, jwt)
+
+	identity, err := validate(context.Background(), "cpk_valid")
+	assert.NoError(t, err)
+	assert.Equal(t, Identity{UserID: "key-user"}, identity)
+
+	_, err = validate(context.Background(), "cpk_revoked")
+	assert.Error(t, err)
+
+	identity, err = validate(context.Background(), "jwt")
+	assert.NoError(t, err)
+	assert.Equal(t, "jwt-user", identity.UserID)
+	assert.Equal(t, []string{"admin"}, identity.Roles)
+	store.AssertNotCalled(t, "LookupAPIKey", mock.Anything, "jwt")
+}
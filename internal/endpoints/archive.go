@@ -0,0 +1,115 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ArchiveStore defines the queue operations for a user's archived episodes
+type ArchiveStore interface {
+	ListArchivedEpisodes(ctx context.Context, userID string) ([]*queue.ArchivedEpisode, error)
+	GetArchivedEpisode(ctx context.Context, userID string, fileID string) (*queue.ArchivedEpisode, error)
+	Enqueue(ctx context.Context, job *queue.Job) error
+}
+
+// GetArchiveResponse represents the response for the archive endpoint
+type GetArchiveResponse struct {
+	Episodes []*queue.ArchivedEpisode `json:"episodes"`
+}
+
+// RestoreEpisodeResponse represents the response for restoring an archived episode
+type RestoreEpisodeResponse struct {
+	JobID string `json:"job_id"`
+}
+
+// HandleGetArchive returns a handler that lists the user's archived episodes
+// @Summary      Get archived episodes
+// @Description  List the episodes that dropped out of the authenticated user's feed and were moved to the archive folder instead of deleted, most recently archived first. They are deleted for good once ARCHIVE_RETENTION_DAYS pass
+// @Tags         archive
+// @Produce      json
+// @Success      200  {object}  GetArchiveResponse
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /archive [get]
+func HandleGetArchive(store ArchiveStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		episodes, err := store.ListArchivedEpisodes(c.Request.Context(), userID)
+		if err != nil {
+			slog.Error("Failed to list archived episodes", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list archived episodes"})
+			return
+		}
+
+		c.JSON(http.StatusOK, GetArchiveResponse{Episodes: episodes})
+	}
+}
+
+// HandleRestoreEpisode returns a handler that restores an archived episode
+// @Summary      Restore archived episode
+// @Description  Enqueue a job that moves an archived episode back and republishes it in the feed as it was, without processing it again. Like any episode outside the playlist, the next run drops it again unless it is back in the playlist or retained
+// @Tags         archive
+// @Produce      json
+// @Param        fileID path string true "File ID of the archived episode"
+// @Success      202  {object}  RestoreEpisodeResponse
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      429  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /archive/{fileID}/restore [post]
+func HandleRestoreEpisode(store ArchiveStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		episode, err := store.GetArchivedEpisode(ctx, userID, c.Param("fileID"))
+		if err != nil {
+			slog.Error("Failed to fetch archived episode", "error", err, "file_id", c.Param("fileID"))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch archived episode"})
+			return
+		}
+		if episode == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Archived episode not found"})
+			return
+		}
+
+		job := &queue.Job{
+			ID:            uuid.New().String(),
+			UserID:        userID,
+			Filename:      episode.Title,
+			CreatedAt:     time.Now(),
+			Priority:      queue.PriorityInteractive,
+			RestoreFileID: episode.FileID,
+		}
+		if err := store.Enqueue(ctx, job); err != nil {
+			if errors.Is(err, queue.ErrQuotaExceeded) {
+				c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+				return
+			}
+			slog.Error("Failed to enqueue restore job", "error", err, "file_id", episode.FileID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enqueue restore"})
+			return
+		}
+
+		slog.Info("Episode restore enqueued", "job_id", job.ID, "file_id", episode.FileID)
+		c.JSON(http.StatusAccepted, RestoreEpisodeResponse{JobID: job.ID})
+	}
+}
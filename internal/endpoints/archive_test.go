@@ -0,0 +1,134 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockArchiveStore is a mock implementation of ArchiveStore
+type MockArchiveStore struct {
+	mock.Mock
+}
+
+func (m *MockArchiveStore) ListArchivedEpisodes(ctx context.Context, userID string) ([]*queue.ArchivedEpisode, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*queue.ArchivedEpisode), args.Error(1)
+}
+
+func (m *MockArchiveStore) GetArchivedEpisode(ctx context.Context, userID string, fileID string) (*queue.ArchivedEpisode, error) {
+	args := m.Called(ctx, userID, fileID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*queue.ArchivedEpisode), args.Error(1)
+}
+
+func (m *MockArchiveStore) Enqueue(ctx context.Context, job *queue.Job) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func newArchiveRouter(store *MockArchiveStore) *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "test-user")
+		c.Next()
+	})
+	router.GET("/archive", HandleGetArchive(store))
+	router.POST("/archive/:fileID/restore", HandleRestoreEpisode(store))
+	return router
+}
+
+func TestHandleGetArchive(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Success", func(t *testing.T) {
+		store := new(MockArchiveStore)
+		store.On("ListArchivedEpisodes", mock.Anything, "test-user").Return([]*queue.ArchivedEpisode{
+			{FileID: "file-1", Title: "Dropped", ArchivedAt: time.Now(), Episode: json.RawMessage(`{"title":"Dropped"}`)},
+		}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/archive", nil)
+		newArchiveRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response GetArchiveResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response.Episodes, 1)
+		assert.Equal(t, "file-1", response.Episodes[0].FileID)
+	})
+
+	t.Run("Store error", func(t *testing.T) {
+		store := new(MockArchiveStore)
+		store.On("ListArchivedEpisodes", mock.Anything, "test-user").Return(nil, errors.New("connection refused"))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/archive", nil)
+		newArchiveRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestHandleRestoreEpisode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	archived := &queue.ArchivedEpisode{FileID: "file-1", Title: "Dropped", ArchivedAt: time.Now()}
+
+	t.Run("Success", func(t *testing.T) {
+		store := new(MockArchiveStore)
+		store.On("GetArchivedEpisode", mock.Anything, "test-user", "file-1").Return(archived, nil)
+		store.On("Enqueue", mock.Anything, mock.MatchedBy(func(job *queue.Job) bool {
+			return job.RestoreFileID == "file-1" && job.UserID == "test-user" && job.Filename == "Dropped"
+		})).Return(nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/archive/file-1/restore", nil)
+		newArchiveRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		var response RestoreEpisodeResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.NotEmpty(t, response.JobID)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Not archived", func(t *testing.T) {
+		store := new(MockArchiveStore)
+		store.On("GetArchivedEpisode", mock.Anything, "test-user", "missing").Return(nil, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/archive/missing/restore", nil)
+		newArchiveRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		store.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Over quota", func(t *testing.T) {
+		store := new(MockArchiveStore)
+		store.On("GetArchivedEpisode", mock.Anything, "test-user", "file-1").Return(archived, nil)
+		store.On("Enqueue", mock.Anything, mock.Anything).Return(queue.ErrQuotaExceeded)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/archive/file-1/restore", nil)
+		newArchiveRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	})
+}
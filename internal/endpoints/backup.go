@@ -1,6 +1,10 @@
 package endpoints
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -12,10 +16,13 @@ import (
 
 	"cobblepod/internal/auth"
 	"cobblepod/internal/queue"
+	"cobblepod/internal/sources"
 	"cobblepod/internal/storage"
+	"cobblepod/internal/tracing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // BackupUploadRequest represents the file upload request
@@ -28,23 +35,32 @@ type BackupUploadResponse struct {
 	Success bool   `json:"success"`
 	FileID  string `json:"file_id,omitempty"`
 	JobID   string `json:"job_id,omitempty"`
-	Message string `json:"message,omitempty"`
-	Error   string `json:"error,omitempty"`
+	// Duplicate is set when the upload repeats an earlier submission and JobID is the earlier job
+	Duplicate bool   `json:"duplicate,omitempty"`
+	Message   string `json:"message,omitempty"`
+	Error     string `json:"error,omitempty"`
 }
 
-// HandleBackupUpload processes backup file upload
+// HandleBackupUpload processes backup file upload, storing it in backupFolder
 // @Summary      Upload backup file
-// @Description  Uploads a backup file to be processed
+// @Description  Uploads a backup file to be processed. Files that aren't a ZIP holding a Podcast Addict SQLite database are rejected with 422. Repeat submissions of the same Idempotency-Key, or of the same file when no key is given, within 24 hours return the job the first one created instead of queueing another, unless that job failed
 // @Tags         backup
 // @Accept       multipart/form-data
 // @Produce      json
 // @Param        file formData file true "Backup file"
+// @Param        label formData string false "Note shown with the job in listings"
+// @Param        Idempotency-Key header string false "Client chosen key identifying this submission across retries"
 // @Success      200  {object}  BackupUploadResponse
+// @Failure      400  {object}  BackupUploadResponse
 // @Failure      401  {object}  BackupUploadResponse
+// @Failure      408  {object}  BackupUploadResponse
+// @Failure      413  {object}  BackupUploadResponse
+// @Failure      422  {object}  BackupUploadResponse
+// @Failure      429  {object}  BackupUploadResponse
 // @Router       /backup/upload [post]
-func HandleBackupUpload(jobQueue *queue.Queue) gin.HandlerFunc {
+func HandleBackupUpload(jobQueue BackupJobQueue, tokenProvider auth.TokenProvider, storageFactory StorageFactory, backupFolder string, maxBytes int64) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get user ID from context (set by Auth0Middleware)
+		// Get user ID from context (set by AuthMiddleware)
 		userID, err := GetUserID(c)
 		if err != nil {
 			slog.Error("Failed to get user ID from context", "error", err)
@@ -55,101 +71,167 @@ func HandleBackupUpload(jobQueue *queue.Queue) gin.HandlerFunc {
 			return
 		}
 
-		// Check if user already has a running job (fail fast before expensive operations)
-		isRunning, err := jobQueue.IsUserRunning(c.Request.Context(), userID)
+		// Exchange Auth0 token for Google access token
+		googleToken, err := tokenProvider.GetGoogleAccessToken(c.Request.Context(), userID)
 		if err != nil {
-			slog.Error("Failed to check if user has running job", "error", err, "user_id", userID)
-			c.JSON(http.StatusInternalServerError, BackupUploadResponse{
+			slog.Error("Failed to get Google access token", "error", err, "user_id", userID)
+			c.JSON(http.StatusUnauthorized, BackupUploadResponse{
 				Success: false,
-				Error:   "Failed to check job status",
+				Error:   fmt.Sprintf("Failed to authenticate with Google: %v", err),
 			})
 			return
 		}
 
-		if isRunning {
-			slog.Warn("User already has a running job", "user_id", userID)
-			c.JSON(http.StatusConflict, BackupUploadResponse{
+		slog.Info("Successfully exchanged Auth0 token for Google token", "user_id", userID)
+
+		idempotencyKey := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+		if len(idempotencyKey) > queue.MaxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, BackupUploadResponse{
 				Success: false,
-				Error:   "You already have a job being processed. Please wait for it to complete.",
+				Error:   fmt.Sprintf("Idempotency-Key must be at most %d characters", queue.MaxIdempotencyKeyLength),
 			})
 			return
 		}
 
-		// Exchange Auth0 token for Google access token
-		googleToken, err := auth.GetGoogleAccessToken(c.Request.Context(), userID)
+		// The server's read timeout would otherwise cut off uploads over slow
+		// connections, and its write timeout the response after them
+		deadline := time.Now().Add(backupUploadTimeout)
+		rc := http.NewResponseController(c.Writer)
+		if err := rc.SetReadDeadline(deadline); err != nil {
+			slog.Debug("Unable to extend read deadline for backup upload", "error", err)
+		}
+		if err := rc.SetWriteDeadline(deadline.Add(backupResponseTimeout)); err != nil {
+			slog.Debug("Unable to extend write deadline for backup upload", "error", err)
+		}
+
+		// Stream the multipart body to disk rather than letting it be buffered
+		upload, err := receiveBackupUpload(c.Writer, c.Request, maxBytes)
 		if err != nil {
-			slog.Error("Failed to get Google access token", "error", err, "user_id", userID)
-			c.JSON(http.StatusUnauthorized, BackupUploadResponse{
-				Success: false,
-				Error:   fmt.Sprintf("Failed to authenticate with Google: %v", err),
-			})
+			var tooLarge *http.MaxBytesError
+			switch {
+			case errors.Is(err, os.ErrDeadlineExceeded):
+				slog.Warn("Backup upload timed out", "user_id", userID, "timeout", backupUploadTimeout)
+				c.JSON(http.StatusRequestTimeout, BackupUploadResponse{
+					Success: false,
+					Error:   backupTimeoutMessage,
+				})
+			case errors.As(err, &tooLarge):
+				slog.Warn("Rejected oversized backup upload", "user_id", userID, "limit", maxBytes)
+				c.JSON(http.StatusRequestEntityTooLarge, BackupUploadResponse{
+					Success: false,
+					Error:   backupTooLargeMessage(maxBytes),
+				})
+			case errors.Is(err, errBackupExtension):
+				c.JSON(http.StatusBadRequest, BackupUploadResponse{
+					Success: false,
+					Error:   "File must have .backup extension",
+				})
+			case errors.Is(err, errSaveBackup):
+				slog.Error("Failed to save backup upload", "error", err)
+				c.JSON(http.StatusInternalServerError, BackupUploadResponse{
+					Success: false,
+					Error:   "Failed to save file",
+				})
+			default:
+				slog.Error("Failed to parse backup upload", "error", err)
+				c.JSON(http.StatusBadRequest, BackupUploadResponse{
+					Success: false,
+					Error:   "Failed to parse file upload",
+				})
+			}
 			return
 		}
+		defer os.Remove(upload.Path) // Clean up temp file after upload
 
-		slog.Info("Successfully exchanged Auth0 token for Google token", "user_id", userID)
-
-		// Parse multipart form
-		file, header, err := c.Request.FormFile("file")
+		label, err := queue.NormalizeLabel(upload.Label)
 		if err != nil {
-			slog.Error("Failed to get file from form", "error", err)
 			c.JSON(http.StatusBadRequest, BackupUploadResponse{
 				Success: false,
-				Error:   "Failed to parse file upload",
+				Error:   fmt.Sprintf("Label must be at most %d characters", queue.MaxLabelLength),
 			})
 			return
 		}
-		defer file.Close()
 
-		// Validate file extension
-		if !strings.HasSuffix(strings.ToLower(header.Filename), ".backup") {
-			slog.Warn("Invalid file extension", "filename", header.Filename)
-			c.JSON(http.StatusBadRequest, BackupUploadResponse{
+		if err := sources.ValidateBackup(upload.Path); err != nil {
+			slog.Warn("Rejected invalid backup upload", "error", err, "filename", upload.Filename, "user_id", userID)
+			message := "Failed to validate backup file"
+			status := http.StatusInternalServerError
+			if errors.Is(err, sources.ErrInvalidBackup) {
+				message = err.Error()
+				status = http.StatusUnprocessableEntity
+			}
+			c.JSON(status, BackupUploadResponse{
 				Success: false,
-				Error:   "File must have .backup extension",
+				Error:   message,
 			})
 			return
 		}
 
-		// Create temporary file
-		tmpFile, err := os.CreateTemp("", "backup-*.backup")
+		if idempotencyKey == "" {
+			idempotencyKey = "sha256:" + upload.SHA256
+		} else {
+			idempotencyKey = "key:" + idempotencyKey
+		}
+
+		// Claim the submission before uploading so a retry doesn't upload the file twice
+		jobID := uuid.New().String()
+		existingJobID, err := jobQueue.ClaimIdempotencyKey(c.Request.Context(), userID, idempotencyKey, jobID)
 		if err != nil {
-			slog.Error("Failed to create temporary file", "error", err)
+			slog.Error("Failed to claim idempotency key", "error", err, "user_id", userID)
 			c.JSON(http.StatusInternalServerError, BackupUploadResponse{
 				Success: false,
-				Error:   "Failed to create temporary file",
+				Error:   "Failed to queue job for processing",
 			})
 			return
 		}
-		defer os.Remove(tmpFile.Name()) // Clean up temp file after upload
+		if existingJobID != "" {
+			slog.Info("Returning existing job for repeated backup upload", "job_id", existingJobID, "user_id", userID)
+			c.JSON(http.StatusOK, BackupUploadResponse{
+				Success:   true,
+				JobID:     existingJobID,
+				Duplicate: true,
+				Message:   fmt.Sprintf("File %s was already submitted", upload.Filename),
+			})
+			return
+		}
+		// Until the job is queued, a failure should let the client try again
+		queued := false
+		defer func() {
+			if queued {
+				return
+			}
+			if err := jobQueue.ReleaseIdempotencyKey(context.WithoutCancel(c.Request.Context()), userID, idempotencyKey, jobID); err != nil {
+				slog.Error("Failed to release idempotency key", "error", err, "user_id", userID)
+			}
+		}()
 
-		// Copy uploaded file to temp file
-		_, err = io.Copy(tmpFile, file)
+		// Create Google Drive service with user's Google access token
+		driveService, err := storageFactory(c.Request.Context(), googleToken)
 		if err != nil {
-			slog.Error("Failed to copy file content", "error", err)
-			tmpFile.Close()
+			slog.Error("Failed to create Drive service", "error", err)
 			c.JSON(http.StatusInternalServerError, BackupUploadResponse{
 				Success: false,
-				Error:   "Failed to save file",
+				Error:   "Failed to initialize storage service",
 			})
 			return
 		}
-		tmpFile.Close()
 
-		// Create Google Drive service with user's Google access token
-		driveService, err := storage.NewServiceWithToken(c.Request.Context(), googleToken)
-		if err != nil {
-			slog.Error("Failed to create Drive service", "error", err)
+		if err := driveService.UseFolder(backupFolder); err != nil {
+			slog.Error("Failed to prepare backup folder", "error", err)
 			c.JSON(http.StatusInternalServerError, BackupUploadResponse{
 				Success: false,
 				Error:   "Failed to initialize storage service",
 			})
 			return
 		}
+		driveService.SetTags(map[string]string{storage.TagUser: userID})
 
 		// Upload file to Google Drive
-		fileID, err := driveService.UploadFile(tmpFile.Name(), filepath.Base(header.Filename), "application/octet-stream")
+		_, span := tracing.Start(c.Request.Context(), "backup.upload", attribute.String("job.id", jobID), attribute.String("backup.filename", upload.Filename))
+		fileID, err := driveService.UploadFile(upload.Path, upload.Filename, backupMIMEType)
+		tracing.End(span, err)
 		if err != nil {
-			slog.Error("Failed to upload file to Drive", "error", err, "filename", header.Filename)
+			slog.Error("Failed to upload file to Drive", "error", err, "filename", upload.Filename)
 			c.JSON(http.StatusInternalServerError, BackupUploadResponse{
 				Success: false,
 				Error:   "Failed to upload file to storage",
@@ -157,20 +239,29 @@ func HandleBackupUpload(jobQueue *queue.Queue) gin.HandlerFunc {
 			return
 		}
 
-		slog.Info("File uploaded successfully", "file_id", fileID, "filename", header.Filename)
+		slog.Info("File uploaded successfully", "file_id", fileID, "filename", upload.Filename)
 
-		// Create job with unique ID
-		jobID := uuid.New().String()
 		job := &queue.Job{
 			ID:        jobID,
 			FileID:    fileID,
 			UserID:    userID,
-			Filename:  header.Filename,
+			Filename:  upload.Filename,
+			Label:     label,
 			CreatedAt: time.Now(),
+			Priority:  queue.PriorityInteractive,
+			// A failed job frees the key so the backup can be submitted again
+			IdempotencyKey: idempotencyKey,
 		}
 
 		// Enqueue job to Redis
 		if err := jobQueue.Enqueue(c.Request.Context(), job); err != nil {
+			if errors.Is(err, queue.ErrQuotaExceeded) {
+				c.JSON(http.StatusTooManyRequests, BackupUploadResponse{
+					Success: false,
+					Error:   err.Error(),
+				})
+				return
+			}
 			slog.Error("Failed to enqueue job", "error", err, "job_id", jobID)
 			c.JSON(http.StatusInternalServerError, BackupUploadResponse{
 				Success: false,
@@ -178,12 +269,126 @@ func HandleBackupUpload(jobQueue *queue.Queue) gin.HandlerFunc {
 			})
 			return
 		}
+		queued = true
 
 		c.JSON(http.StatusOK, BackupUploadResponse{
 			Success: true,
 			FileID:  fileID,
 			JobID:   jobID,
-			Message: fmt.Sprintf("File %s uploaded and queued for processing", header.Filename),
+			Message: fmt.Sprintf("File %s uploaded and queued for processing", upload.Filename),
 		})
 	}
 }
+
+const (
+	// backupUploadTimeout is how long a backup upload may take to arrive,
+	// replacing the server's read timeout for this route
+	backupUploadTimeout = 10 * time.Minute
+	// backupResponseTimeout is how long handling a received upload may take,
+	// storing it in Drive and queueing its job
+	backupResponseTimeout = time.Minute
+)
+
+// backupTimeoutMessage explains an upload that hit backupUploadTimeout to the user
+var backupTimeoutMessage = fmt.Sprintf("The backup didn't finish uploading within %d minutes. "+
+	"Try again on a faster connection.", int(backupUploadTimeout.Minutes()))
+
+var (
+	// errBackupExtension rejects uploads whose file isn't named *.backup
+	errBackupExtension = errors.New("file must have .backup extension")
+	// errSaveBackup marks failures writing the upload to disk, as opposed to reading the request
+	errSaveBackup = errors.New("failed to save backup")
+)
+
+// backupUpload is a backup streamed from a multipart request to a temp file
+type backupUpload struct {
+	Filename string
+	Label    string
+	Path     string // Temp file the caller removes
+	SHA256   string // Hex digest of the file content
+}
+
+// receiveBackupUpload streams the "file" part of a multipart request to a temp
+// file without buffering it, reading at most limit bytes of request body. A
+// larger body fails with *http.MaxBytesError.
+func receiveBackupUpload(w http.ResponseWriter, r *http.Request, limit int64) (*backupUpload, error) {
+	if r.ContentLength > limit {
+		return nil, &http.MaxBytesError{Limit: limit}
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	upload := &backupUpload{}
+	cleanup := func() {
+		if upload.Path != "" {
+			os.Remove(upload.Path)
+		}
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+
+		switch part.FormName() {
+		case "label":
+			// Labels are short; anything longer than this fails NormalizeLabel anyway
+			label, err := io.ReadAll(io.LimitReader(part, int64(queue.MaxLabelLength*4+1)))
+			if err != nil {
+				cleanup()
+				return nil, err
+			}
+			upload.Label = string(label)
+		case "file":
+			if upload.Path != "" {
+				continue // only the first file is used
+			}
+			upload.Filename = filepath.Base(part.FileName())
+			if !strings.HasSuffix(strings.ToLower(upload.Filename), ".backup") {
+				slog.Warn("Invalid file extension", "filename", upload.Filename)
+				return nil, errBackupExtension
+			}
+			if err := saveBackupPart(upload, part); err != nil {
+				cleanup()
+				return nil, err
+			}
+		}
+	}
+
+	if upload.Path == "" {
+		return nil, errors.New("no file in upload")
+	}
+	return upload, nil
+}
+
+// saveBackupPart copies a file part to a new temp file, hashing it on the way
+func saveBackupPart(upload *backupUpload, part io.Reader) error {
+	tmpFile, err := os.CreateTemp("", "backup-*.backup")
+	if err != nil {
+		return fmt.Errorf("%w: %v", errSaveBackup, err)
+	}
+	defer tmpFile.Close()
+	upload.Path = tmpFile.Name()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmpFile, hash), part); err != nil {
+		return err
+	}
+	upload.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return nil
+}
+
+// backupTooLargeMessage explains the upload limit of maxBytes to the user
+func backupTooLargeMessage(maxBytes int64) string {
+	return fmt.Sprintf("Backup files must be at most %d MB. Podcast Addict backups are usually much smaller; "+
+		"check that you selected the .backup file rather than an export that includes downloaded episodes",
+		maxBytes/(1024*1024))
+}
@@ -1,192 +1,301 @@
 package endpoints
 
 import (
-	"context"
+	"archive/zip"
+	"bytes"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
-	queuemock "cobblepod/internal/queue/mock"
+	"cobblepod/internal/auth"
+	"cobblepod/internal/config"
+	"cobblepod/internal/podcast"
+	"cobblepod/internal/queue"
+	"cobblepod/internal/sources"
+	storagemock "cobblepod/internal/storage/mock"
 
 	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
-// QueueInterface defines the methods we need from a queue
-type QueueInterface interface {
-	IsUserRunning(ctx context.Context, userID string) (bool, error)
+// multipartBody builds a backup upload form
+func multipartBody(t *testing.T, filename string, content []byte, label string) (*bytes.Buffer, string) {
+	t.Helper()
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	if label != "" {
+		w.WriteField("label", label)
+	}
+	part, err := w.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write(content)
+	w.Close()
+	return body, w.FormDataContentType()
 }
 
-// mockBackupHandler creates a simplified version of HandleBackupUpload that skips auth/storage
-func mockBackupHandler(jobQueue QueueInterface) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Get user ID from context (set by test middleware)
-		userID, exists := c.Get("user_id")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, BackupUploadResponse{
-				Success: false,
-				Error:   "Unauthorized",
-			})
-			return
-		}
+func TestReceiveBackupUpload(t *testing.T) {
+	t.Run("Streams file to disk", func(t *testing.T) {
+		body, contentType := multipartBody(t, "PodcastAddict.backup", []byte("backup content"), "weekly")
+		req := httptest.NewRequest("POST", "/backup/upload", body)
+		req.Header.Set("Content-Type", contentType)
 
-		// Check if user already has a running job (fail fast before expensive operations)
-		isRunning, err := jobQueue.IsUserRunning(c.Request.Context(), userID.(string))
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, BackupUploadResponse{
-				Success: false,
-				Error:   "Failed to check job status",
-			})
+		upload, err := receiveBackupUpload(httptest.NewRecorder(), req, 1<<20)
+		if !assert.NoError(t, err) {
 			return
 		}
+		defer os.Remove(upload.Path)
 
-		if isRunning {
-			c.JSON(http.StatusConflict, BackupUploadResponse{
-				Success: false,
-				Error:   "You already have a job being processed. Please wait for it to complete.",
-			})
-			return
-		}
+		content, _ := os.ReadFile(upload.Path)
+		assert.Equal(t, "backup content", string(content))
+		assert.Equal(t, "PodcastAddict.backup", upload.Filename)
+		assert.Equal(t, "weekly", upload.Label)
+		assert.Len(t, upload.SHA256, 64)
+	})
 
-		// For this test, we're only validating the concurrency check
-		// Skip the actual auth, file upload, and storage operations
-		c.JSON(http.StatusOK, BackupUploadResponse{
-			Success: true,
-			Message: "Request would be accepted",
-		})
-	}
-}
+	t.Run("Declared size over the limit", func(t *testing.T) {
+		body, contentType := multipartBody(t, "PodcastAddict.backup", bytes.Repeat([]byte("x"), 2048), "")
+		req := httptest.NewRequest("POST", "/backup/upload", body)
+		req.Header.Set("Content-Type", contentType)
 
-func TestHandleBackupUpload_RejectsWhenUserHasRunningJob(t *testing.T) {
-	gin.SetMode(gin.TestMode)
+		_, err := receiveBackupUpload(httptest.NewRecorder(), req, 1024)
+		var tooLarge *http.MaxBytesError
+		assert.True(t, errors.As(err, &tooLarge))
+	})
 
-	testUserID := "test-user-123"
+	t.Run("Streamed size over the limit", func(t *testing.T) {
+		body, contentType := multipartBody(t, "PodcastAddict.backup", bytes.Repeat([]byte("x"), 2048), "")
+		// Hide the length, as a chunked request would
+		req := httptest.NewRequest("POST", "/backup/upload", io.MultiReader(body))
+		req.ContentLength = -1
+		req.Header.Set("Content-Type", contentType)
 
-	// Create mock queue and set user as running
-	mockQueue := queuemock.NewMockQueue()
-	mockQueue.SetUserRunning(testUserID, true)
+		_, err := receiveBackupUpload(httptest.NewRecorder(), req, 1024)
+		var tooLarge *http.MaxBytesError
+		assert.True(t, errors.As(err, &tooLarge))
+	})
 
-	// Create test router with mock handler
-	router := gin.New()
+	t.Run("Wrong extension", func(t *testing.T) {
+		body, contentType := multipartBody(t, "notes.txt", []byte("hello"), "")
+		req := httptest.NewRequest("POST", "/backup/upload", body)
+		req.Header.Set("Content-Type", contentType)
 
-	// Mock auth middleware that sets user_id in context
-	router.Use(func(c *gin.Context) {
-		c.Set("user_id", testUserID)
-		c.Next()
+		_, err := receiveBackupUpload(httptest.NewRecorder(), req, 1<<20)
+		assert.ErrorIs(t, err, errBackupExtension)
 	})
 
-	router.POST("/api/backup", mockBackupHandler(mockQueue))
-
-	// Create a simple request (no actual file needed for this test)
-	req := httptest.NewRequest(http.MethodPost, "/api/backup", nil)
+	t.Run("Not multipart", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/backup/upload", strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
 
-	// Record response
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
+		_, err := receiveBackupUpload(httptest.NewRecorder(), req, 1<<20)
+		assert.Error(t, err)
+	})
+}
 
-	// Assert response
-	if w.Code != http.StatusConflict {
-		t.Errorf("Expected status %d (Conflict), got %d", http.StatusConflict, w.Code)
+// validBackup returns a zipped Podcast Addict database that passes sources.ValidateBackup
+func validBackup(t *testing.T) []byte {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "podcastAddict.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
 	}
-
-	var response BackupUploadResponse
-	err := json.Unmarshal(w.Body.Bytes(), &response)
+	for _, table := range []string{"podcasts", "episodes", "ordered_list"} {
+		if _, err := db.Exec("CREATE TABLE " + table + " (_id INTEGER PRIMARY KEY)"); err != nil {
+			t.Fatalf("Failed to create %s: %v", table, err)
+		}
+	}
+	db.Close()
+	content, err := os.ReadFile(dbPath)
 	if err != nil {
-		t.Fatalf("Failed to unmarshal response: %v", err)
+		t.Fatalf("Failed to read database: %v", err)
 	}
 
-	if response.Success {
-		t.Error("Expected Success to be false, got true")
+	backup := &bytes.Buffer{}
+	w := zip.NewWriter(backup)
+	entry, err := w.Create("podcastAddict.db")
+	if err != nil {
+		t.Fatalf("Failed to add database: %v", err)
 	}
-	if !strings.Contains(response.Error, "already have a job") {
-		t.Errorf("Expected error message to contain 'already have a job', got '%s'", response.Error)
+	entry.Write(content)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to write backup: %v", err)
 	}
+	return backup.Bytes()
 }
 
-func TestHandleBackupUpload_AllowsWhenNoRunningJob(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-
-	testUserID := "test-user-456"
+// testMaxBackupBytes is the default backup upload limit
+var testMaxBackupBytes = config.Defaults().Server.MaxBackupUploadBytes()
 
-	// Create mock queue with no running users
-	mockQueue := queuemock.NewMockQueue()
+// testFolders and testBackupFolder are the default deployment's storage folders
+var (
+	testFolders      = podcast.NewFolders(config.DefaultDriveFolder)
+	testBackupFolder = sources.BackupFolder(config.DefaultDriveFolder)
+)
 
-	// Create test router with mock handler
+// newBackupUploadRouter serves HandleBackupUpload for test-user-123
+func newBackupUploadRouter(jobQueue BackupJobQueue, drive *storagemock.MockStorage) *gin.Engine {
 	router := gin.New()
-
-	// Mock auth middleware
 	router.Use(func(c *gin.Context) {
-		c.Set("user_id", testUserID)
+		c.Set("user_id", "test-user-123")
 		c.Next()
 	})
+	router.POST("/api/backup", HandleBackupUpload(jobQueue, &auth.MockTokenProvider{Token: "google-token"}, storagemock.NewMockStorageCreator(drive, nil), testBackupFolder, testMaxBackupBytes))
+	return router
+}
+
+func newBackupUploadRequest(t *testing.T) *http.Request {
+	body, contentType := multipartBody(t, "PodcastAddict.backup", validBackup(t), "")
+	req := httptest.NewRequest(http.MethodPost, "/api/backup", body)
+	req.Header.Set("Content-Type", contentType)
+	return req
+}
 
-	router.POST("/api/backup", mockBackupHandler(mockQueue))
+func TestHandleBackupUpload_QueuesWhenUserHasRunningJob(t *testing.T) {
+	gin.SetMode(gin.TestMode)
 
-	// Create a simple request
-	req := httptest.NewRequest(http.MethodPost, "/api/backup", nil)
+	// The queue runs a user's jobs one at a time, so a running job no longer
+	// turns the upload away
+	mockQueue := new(MockBackupJobQueue)
+	mockQueue.On("ClaimIdempotencyKey", mock.Anything, "test-user-123", mock.Anything, mock.Anything).Return("", nil)
+	mockQueue.On("Enqueue", mock.Anything, mock.MatchedBy(func(job *queue.Job) bool {
+		return job.UserID == "test-user-123" && job.FileID == "backup-file" && job.Priority == queue.PriorityInteractive
+	})).Return(nil)
+	drive := storagemock.NewMockStorage()
+	drive.UploadFileID = "backup-file"
 
-	// Record response
 	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
-
-	// Should succeed (200) since user has no running job
-	if w.Code != http.StatusOK {
-		t.Errorf("Expected status %d (OK), got %d", http.StatusOK, w.Code)
-	}
+	newBackupUploadRouter(mockQueue, drive).ServeHTTP(w, newBackupUploadRequest(t))
 
+	assert.Equal(t, http.StatusOK, w.Code)
 	var response BackupUploadResponse
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	if err != nil {
-		t.Fatalf("Failed to unmarshal response: %v", err)
-	}
-
-	if !response.Success {
-		t.Error("Expected Success to be true, got false")
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response)) {
+		assert.True(t, response.Success)
+		assert.Equal(t, "backup-file", response.FileID)
+		assert.NotEmpty(t, response.JobID)
 	}
+	mockQueue.AssertExpectations(t)
+	mockQueue.AssertNotCalled(t, "ReleaseIdempotencyKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
-func TestHandleBackupUpload_HandlesQueueError(t *testing.T) {
+func TestHandleBackupUpload_ReturnsExistingJobForRepeatedUpload(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
-	testUserID := "test-user-789"
+	mockQueue := new(MockBackupJobQueue)
+	mockQueue.On("ClaimIdempotencyKey", mock.Anything, "test-user-123", mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "sha256:")
+	}), mock.Anything).Return("earlier-job", nil)
+	drive := storagemock.NewMockStorage()
 
-	// Create mock queue that returns errors
-	mockQueue := queuemock.NewMockQueueWithErrors(queuemock.ErrorOnIsUserRunning)
-
-	// Create test router with mock handler
-	router := gin.New()
+	w := httptest.NewRecorder()
+	newBackupUploadRouter(mockQueue, drive).ServeHTTP(w, newBackupUploadRequest(t))
 
-	// Mock auth middleware
-	router.Use(func(c *gin.Context) {
-		c.Set("user_id", testUserID)
-		c.Next()
-	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response BackupUploadResponse
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response)) {
+		assert.True(t, response.Duplicate)
+		assert.Equal(t, "earlier-job", response.JobID)
+	}
+	assert.Empty(t, drive.UploadFileCalls)
+	mockQueue.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything)
+}
 
-	router.POST("/api/backup", mockBackupHandler(mockQueue))
+func TestHandleBackupUpload_HandlesQueueError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
 
-	// Create a simple request
-	req := httptest.NewRequest(http.MethodPost, "/api/backup", nil)
+	mockQueue := new(MockBackupJobQueue)
+	mockQueue.On("ClaimIdempotencyKey", mock.Anything, "test-user-123", mock.Anything, mock.Anything).Return("", nil)
+	mockQueue.On("Enqueue", mock.Anything, mock.Anything).Return(errors.New("connection refused"))
+	mockQueue.On("ReleaseIdempotencyKey", mock.Anything, "test-user-123", mock.Anything, mock.Anything).Return(nil)
+	drive := storagemock.NewMockStorage()
+	drive.UploadFileID = "backup-file"
 
-	// Record response
 	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
+	newBackupUploadRouter(mockQueue, drive).ServeHTTP(w, newBackupUploadRequest(t))
 
-	// Should return 500 when queue check fails
-	if w.Code != http.StatusInternalServerError {
-		t.Errorf("Expected status %d (Internal Server Error), got %d", http.StatusInternalServerError, w.Code)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	var response BackupUploadResponse
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response)) {
+		assert.False(t, response.Success)
+		assert.Equal(t, "Failed to queue job for processing", response.Error)
 	}
+	// The key is released so the client can try again
+	mockQueue.AssertExpectations(t)
+}
 
-	var response BackupUploadResponse
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	if err != nil {
-		t.Fatalf("Failed to unmarshal response: %v", err)
+func TestHandleBackupUpload_ReadsSlowBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockQueue := new(MockBackupJobQueue)
+	mockQueue.On("ClaimIdempotencyKey", mock.Anything, "test-user-123", mock.Anything, mock.Anything).Return("", nil)
+	mockQueue.On("Enqueue", mock.Anything, mock.Anything).Return(nil)
+	drive := storagemock.NewMockStorage()
+	drive.UploadFileID = "backup-file"
+
+	// The body arrives after the server's timeouts, which the handler replaces
+	server := httptest.NewUnstartedServer(newBackupUploadRouter(mockQueue, drive))
+	server.Config.ReadTimeout = 100 * time.Millisecond
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	body, contentType := multipartBody(t, "PodcastAddict.backup", validBackup(t), "")
+	content := body.Bytes()
+	reader, writer := io.Pipe()
+	go func() {
+		writer.Write(content[:len(content)/2])
+		time.Sleep(300 * time.Millisecond)
+		writer.Write(content[len(content)/2:])
+		writer.Close()
+	}()
+
+	resp, err := http.Post(server.URL+"/api/backup", contentType, reader)
+	if !assert.NoError(t, err) {
+		return
 	}
+	defer resp.Body.Close()
 
-	if response.Success {
-		t.Error("Expected Success to be false, got true")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var response BackupUploadResponse
+	if assert.NoError(t, json.NewDecoder(resp.Body).Decode(&response)) {
+		assert.True(t, response.Success)
+		assert.Equal(t, "backup-file", response.FileID)
 	}
-	if !strings.Contains(response.Error, "Failed to check job status") {
-		t.Errorf("Expected error message to contain 'Failed to check job status', got '%s'", response.Error)
+	mockQueue.AssertExpectations(t)
+}
+
+func TestHandleBackupUpload_ReportsTimedOutUpload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	body, contentType := multipartBody(t, "PodcastAddict.backup", validBackup(t), "")
+	partial := bytes.NewReader(body.Bytes()[:body.Len()/2])
+	req := httptest.NewRequest(http.MethodPost, "/api/backup", io.MultiReader(partial, iotest.ErrReader(os.ErrDeadlineExceeded)))
+	req.Header.Set("Content-Type", contentType)
+	mockQueue := new(MockBackupJobQueue)
+	drive := storagemock.NewMockStorage()
+
+	w := httptest.NewRecorder()
+	newBackupUploadRouter(mockQueue, drive).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	var response BackupUploadResponse
+	if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response)) {
+		assert.False(t, response.Success)
+		assert.Equal(t, backupTimeoutMessage, response.Error)
 	}
+	mockQueue.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything)
+	assert.Empty(t, drive.UploadFileCalls)
 }
@@ -0,0 +1,61 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"cobblepod/internal/audio"
+	"cobblepod/internal/config"
+	"cobblepod/internal/transcribe"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SpeedRange describes the playback speeds a job can be processed at
+type SpeedRange struct {
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	Default float64 `json:"default"`
+}
+
+// CapabilitiesResponse lists the features available to the authenticated user
+type CapabilitiesResponse struct {
+	StorageBackends []string   `json:"storage_backends"`
+	OutputFormats   []string   `json:"output_formats"`
+	Speed           SpeedRange `json:"speed"`
+	Normalization   bool       `json:"normalization"`
+	Transcription   bool       `json:"transcription"`
+	DriveWebhooks   bool       `json:"drive_webhooks"`
+	Admin           bool       `json:"admin"`
+}
+
+// HandleGetCapabilities returns a handler that describes what this deployment supports
+// @Summary      Get capabilities
+// @Description  List the features enabled for this deployment and the authenticated user so clients can render the right options
+// @Tags         capabilities
+// @Produce      json
+// @Success      200  {object}  CapabilitiesResponse
+// @Failure      401  {object}  map[string]string
+// @Router       /capabilities [get]
+func HandleGetCapabilities(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		c.JSON(http.StatusOK, CapabilitiesResponse{
+			StorageBackends: []string{"gdrive"},
+			OutputFormats:   audio.OutputFormats(),
+			Speed: SpeedRange{
+				Min:     config.MinSpeed,
+				Max:     config.MaxSpeed,
+				Default: config.DefaultSpeed,
+			},
+			Normalization: false,
+			Transcription: transcribe.Enabled(cfg.Worker),
+			DriveWebhooks: cfg.Server.WebhookBaseURL != "",
+			Admin:         isAdmin(c, cfg.Auth, userID),
+		})
+	}
+}
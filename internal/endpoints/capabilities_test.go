@@ -0,0 +1,65 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cobblepod/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleGetCapabilities(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := config.Defaults()
+	cfg.Auth.AdminUserIDs = []string{"admin-user"}
+	cfg.Server.WebhookBaseURL = ""
+
+	newRouter := func(userID string) *gin.Engine {
+		router := gin.New()
+		if userID != "" {
+			router.Use(func(c *gin.Context) {
+				c.Set("user_id", userID)
+				c.Next()
+			})
+		}
+		router.GET("/capabilities", HandleGetCapabilities(&cfg))
+		return router
+	}
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/capabilities", nil)
+		newRouter("").ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Regular user", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/capabilities", nil)
+		newRouter("test-user").ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response CapabilitiesResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, []string{"gdrive"}, response.StorageBackends)
+		assert.Equal(t, config.DefaultSpeed, response.Speed.Default)
+		assert.False(t, response.DriveWebhooks)
+		assert.False(t, response.Admin)
+	})
+
+	t.Run("Admin user", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/capabilities", nil)
+		newRouter("admin-user").ServeHTTP(w, req)
+
+		var response CapabilitiesResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.True(t, response.Admin)
+	})
+}
@@ -0,0 +1,198 @@
+package endpoints
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"cobblepod/internal/audio"
+	"cobblepod/internal/auth"
+	"cobblepod/internal/podcast"
+	"cobblepod/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxClipUploadBytes caps the size of an uploaded intro or outro clip
+const maxClipUploadBytes = 10 * 1024 * 1024
+
+// ClipProber reads an uploaded clip; FFmpeg.ProbeFile in production
+type ClipProber func(path string) (*audio.FileProbe, error)
+
+// ClipResponse describes a stored intro or outro clip
+type ClipResponse struct {
+	Clip            string  `json:"clip"`
+	FileID          string  `json:"file_id"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// HandleUploadClip returns a handler that stores the user's intro or outro clip
+// in the feed folder of folders
+// @Summary      Upload intro or outro
+// @Description  Stores a short recording that later jobs join before (intro) or after (outro) every episode of the feed, replacing any earlier one. Episodes are re-processed with it on the next job. Clips longer than a minute or that aren't audio are rejected with 422
+// @Tags         settings
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        clip path string true "intro or outro"
+// @Param        file formData file true "Audio clip"
+// @Success      200  {object}  ClipResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      413  {object}  map[string]string
+// @Failure      422  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /settings/clips/{clip} [put]
+func HandleUploadClip(tokenProvider auth.TokenProvider, storageFactory StorageFactory, folders podcast.Folders, probe ClipProber) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		clip, ok := podcast.ParseClip(c.Param("clip"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown clip, expected intro or outro"})
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxClipUploadBytes)
+		header, err := c.FormFile("file")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Clips must be at most %d MB", maxClipUploadBytes/(1024*1024))})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse file upload"})
+			return
+		}
+
+		tmpFile, err := os.CreateTemp("", "clip-*"+filepath.Ext(header.Filename))
+		if err != nil {
+			slog.Error("Failed to create clip temp file", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+			return
+		}
+		tmpFile.Close()
+		defer os.Remove(tmpFile.Name())
+		if err := c.SaveUploadedFile(header, tmpFile.Name()); err != nil {
+			slog.Error("Failed to save clip upload", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+			return
+		}
+
+		probed, err := probe(tmpFile.Name())
+		if err != nil {
+			slog.Warn("Rejected unreadable clip", "error", err, "filename", header.Filename, "user_id", userID)
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "File is not a readable audio clip"})
+			return
+		}
+		if probed.Duration > podcast.MaxClipDuration {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("Clips must be at most %s long", podcast.MaxClipDuration)})
+			return
+		}
+
+		ctx := c.Request.Context()
+		googleToken, err := tokenProvider.GetGoogleAccessToken(ctx, userID)
+		if err != nil {
+			slog.Error("Failed to get Google access token", "error", err, "user_id", userID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to authenticate with Google"})
+			return
+		}
+		driveService, err := storageFactory(ctx, googleToken)
+		if err != nil {
+			slog.Error("Failed to create Drive service", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to initialize storage service"})
+			return
+		}
+		if err := driveService.UseFolder(folders.Feed); err != nil {
+			slog.Error("Failed to prepare feed folder", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to initialize storage service"})
+			return
+		}
+		driveService.SetTags(map[string]string{storage.TagUser: userID})
+
+		previous, err := driveService.GetFiles(clip.Query(folders))
+		if err != nil {
+			slog.Error("Failed to look up clip", "error", err, "clip", clip, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload clip"})
+			return
+		}
+		fileID, err := driveService.UploadFile(tmpFile.Name(), clip.Filename(), audio.FormatForPath(header.Filename).ContentType)
+		if err != nil {
+			slog.Error("Failed to upload clip", "error", err, "clip", clip, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload clip"})
+			return
+		}
+		// Jobs use the newest clip, so a leftover old one is only clutter
+		for _, file := range previous {
+			if err := driveService.DeleteFile(file.ID); err != nil {
+				slog.Warn("Failed to delete replaced clip", "error", err, "file_id", file.ID)
+			}
+		}
+
+		slog.Info("Stored clip", "clip", clip, "file_id", fileID, "user_id", userID)
+		c.JSON(http.StatusOK, ClipResponse{Clip: string(clip), FileID: fileID, DurationSeconds: probed.Duration.Seconds()})
+	}
+}
+
+// HandleDeleteClip returns a handler that removes the user's intro or outro clip
+// from the feed folder of folders
+// @Summary      Delete intro or outro
+// @Description  Removes the clip; episodes are re-processed without it on the next job
+// @Tags         settings
+// @Param        clip path string true "intro or outro"
+// @Success      204
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /settings/clips/{clip} [delete]
+func HandleDeleteClip(tokenProvider auth.TokenProvider, storageFactory StorageFactory, folders podcast.Folders) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		clip, ok := podcast.ParseClip(c.Param("clip"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown clip, expected intro or outro"})
+			return
+		}
+
+		ctx := c.Request.Context()
+		googleToken, err := tokenProvider.GetGoogleAccessToken(ctx, userID)
+		if err != nil {
+			slog.Error("Failed to get Google access token", "error", err, "user_id", userID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to authenticate with Google"})
+			return
+		}
+		driveService, err := storageFactory(ctx, googleToken)
+		if err != nil {
+			slog.Error("Failed to create Drive service", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to initialize storage service"})
+			return
+		}
+
+		files, err := driveService.GetFiles(clip.Query(folders))
+		if err != nil {
+			slog.Error("Failed to look up clip", "error", err, "clip", clip, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete clip"})
+			return
+		}
+		for _, file := range files {
+			if err := driveService.DeleteFile(file.ID); err != nil {
+				slog.Error("Failed to delete clip", "error", err, "file_id", file.ID)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete clip"})
+				return
+			}
+		}
+
+		slog.Info("Deleted clip", "clip", clip, "user_id", userID)
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,126 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cobblepod/internal/audio"
+	"cobblepod/internal/auth"
+	"cobblepod/internal/podcast"
+	"cobblepod/internal/storage"
+	storagemock "cobblepod/internal/storage/mock"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// probeLasting returns a ClipProber that reports every file lasting duration
+func probeLasting(duration time.Duration) ClipProber {
+	return func(path string) (*audio.FileProbe, error) {
+		return &audio.FileProbe{Duration: duration, Codec: "mp3"}, nil
+	}
+}
+
+func newClipRouter(drive storage.Storage, probe ClipProber) *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "test-user")
+		c.Next()
+	})
+	tokens := &auth.MockTokenProvider{Token: "google-token"}
+	router.PUT("/settings/clips/:clip", HandleUploadClip(tokens, storagemock.NewMockStorageCreator(drive, nil), testFolders, probe))
+	router.DELETE("/settings/clips/:clip", HandleDeleteClip(tokens, storagemock.NewMockStorageCreator(drive, nil), testFolders))
+	return router
+}
+
+func TestHandleUploadClip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Success", func(t *testing.T) {
+		drive := storagemock.NewMockStorage()
+		drive.GetFilesFiles = []*storage.FileMeta{{ID: "old-intro"}}
+		drive.UploadFileID = "new-intro"
+
+		body, contentType := multipartBody(t, "jingle.mp3", []byte("audio"), "")
+		req, _ := http.NewRequest("PUT", "/settings/clips/intro", body)
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		newClipRouter(drive, probeLasting(5*time.Second)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response ClipResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, ClipResponse{Clip: "intro", FileID: "new-intro", DurationSeconds: 5}, response)
+		assert.Equal(t, []string{testFolders.Feed}, drive.UseFolderCalls)
+		if assert.Len(t, drive.UploadFileCalls, 1) {
+			assert.Equal(t, podcast.ClipIntro.Filename(), drive.UploadFileCalls[0].Filename)
+			assert.Equal(t, "audio/mpeg", drive.UploadFileCalls[0].MimeType)
+		}
+		// The replaced clip is removed
+		assert.Equal(t, []string{"old-intro"}, drive.DeleteFileCalls)
+	})
+
+	t.Run("Unknown clip", func(t *testing.T) {
+		body, contentType := multipartBody(t, "jingle.mp3", []byte("audio"), "")
+		req, _ := http.NewRequest("PUT", "/settings/clips/midroll", body)
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		newClipRouter(storagemock.NewMockStorage(), probeLasting(5*time.Second)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Too long", func(t *testing.T) {
+		drive := storagemock.NewMockStorage()
+		body, contentType := multipartBody(t, "jingle.mp3", []byte("audio"), "")
+		req, _ := http.NewRequest("PUT", "/settings/clips/outro", body)
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		newClipRouter(drive, probeLasting(2*time.Minute)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Empty(t, drive.UploadFileCalls)
+	})
+
+	t.Run("Not audio", func(t *testing.T) {
+		drive := storagemock.NewMockStorage()
+		body, contentType := multipartBody(t, "notes.txt", []byte("hello"), "")
+		req, _ := http.NewRequest("PUT", "/settings/clips/outro", body)
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		unreadable := func(path string) (*audio.FileProbe, error) { return nil, errors.New("invalid data") }
+		newClipRouter(drive, unreadable).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Empty(t, drive.UploadFileCalls)
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		req, _ := http.NewRequest("PUT", "/settings/clips/intro", nil)
+		w := httptest.NewRecorder()
+		newClipRouter(storagemock.NewMockStorage(), probeLasting(5*time.Second)).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandleDeleteClip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	drive := storagemock.NewMockStorage()
+	drive.GetFilesFiles = []*storage.FileMeta{{ID: "outro-1"}, {ID: "outro-2"}}
+
+	req, _ := http.NewRequest("DELETE", "/settings/clips/outro", nil)
+	w := httptest.NewRecorder()
+	newClipRouter(drive, probeLasting(0)).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, []string{"outro-1", "outro-2"}, drive.DeleteFileCalls)
+	if assert.Len(t, drive.GetFilesCalls, 1) {
+		assert.Equal(t, podcast.ClipOutro.Query(testFolders), drive.GetFilesCalls[0].Query)
+	}
+}
@@ -0,0 +1,52 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cobblepod/internal/storage"
+)
+
+// fileETag returns an entity tag for a stored file from its metadata alone, so
+// a conditional request can be answered without downloading the file. The
+// backend's checksum makes a strong tag; without one the modification time and
+// size make a weak one.
+func fileETag(file *storage.FileMeta) string {
+	if file.MD5 != "" {
+		return `"` + file.MD5 + `"`
+	}
+	return fmt.Sprintf(`W/"%x-%x"`, file.ModifiedTime.UnixNano(), file.Size)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, comparing
+// weakly as RFC 9110 requires for If-None-Match
+func etagMatches(header string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified reports whether a GET or HEAD request's preconditions show the
+// client already holds the current representation. If-None-Match takes
+// precedence; If-Modified-Since is only consulted when it is absent.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		return etag != "" && etagMatches(header, etag)
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modified.IsZero() {
+		return false
+	}
+	// HTTP dates have whole seconds
+	return !modified.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,56 @@
+package endpoints
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"cobblepod/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileETag(t *testing.T) {
+	modified := time.Date(2025, 6, 3, 9, 0, 0, 0, time.UTC)
+	assert.Equal(t, `"abc123"`, fileETag(&storage.FileMeta{MD5: "abc123", ModifiedTime: modified}))
+
+	weak := fileETag(&storage.FileMeta{ModifiedTime: modified, Size: 100})
+	assert.Equal(t, weak, fileETag(&storage.FileMeta{ModifiedTime: modified, Size: 100}))
+	assert.NotEqual(t, weak, fileETag(&storage.FileMeta{ModifiedTime: modified, Size: 101}))
+	assert.NotEqual(t, weak, fileETag(&storage.FileMeta{ModifiedTime: modified.Add(time.Second), Size: 100}))
+}
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2025, 6, 3, 9, 0, 0, 500, time.UTC)
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		want    bool
+	}{
+		{name: "unconditional", headers: map[string]string{}},
+		{name: "matching etag", headers: map[string]string{"If-None-Match": `"abc"`}, want: true},
+		{name: "one of several etags", headers: map[string]string{"If-None-Match": `"old", W/"abc"`}, want: true},
+		{name: "any etag", headers: map[string]string{"If-None-Match": `*`}, want: true},
+		{name: "stale etag", headers: map[string]string{"If-None-Match": `"old"`}},
+		{name: "etag wins over date", headers: map[string]string{"If-None-Match": `"old"`, "If-Modified-Since": modified.Add(time.Hour).Format(http.TimeFormat)}},
+		{name: "modified at the same second", headers: map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, want: true},
+		{name: "modified since", headers: map[string]string{"If-Modified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)}},
+		{name: "unparseable date", headers: map[string]string{"If-Modified-Since": "yesterday"}},
+		{name: "not a read", method: http.MethodPost, headers: map[string]string{"If-None-Match": `"abc"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req, _ := http.NewRequest(method, "/feed/secret", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			assert.Equal(t, tt.want, notModified(req, `"abc"`, modified))
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cobblepod/internal/auth"
+	"cobblepod/internal/queue"
+	"cobblepod/internal/sources"
+	"cobblepod/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// backupMIMEType is the content type backups are stored with in Drive
+const backupMIMEType = "application/octet-stream"
+
+// BackupJobQueue defines the queue operations needed to register an uploaded backup
+type BackupJobQueue interface {
+	ClaimIdempotencyKey(ctx context.Context, userID string, key string, jobID string) (string, error)
+	ReleaseIdempotencyKey(ctx context.Context, userID string, key string, jobID string) error
+	Enqueue(ctx context.Context, job *queue.Job) error
+}
+
+// CreateUploadSessionRequest describes the backup the browser is about to upload
+type CreateUploadSessionRequest struct {
+	Filename string `json:"filename" binding:"required"`
+	Size     int64  `json:"size"` // Bytes; lets Drive reject an incomplete upload
+}
+
+// CreateUploadSessionResponse carries the Drive resumable upload session URI
+type CreateUploadSessionResponse struct {
+	UploadURL string `json:"upload_url"`
+}
+
+// RegisterBackupRequest identifies a backup the browser uploaded to Drive
+type RegisterBackupRequest struct {
+	FileID string `json:"file_id" binding:"required"`
+	Label  string `json:"label"`
+}
+
+// HandleCreateUploadSession returns a handler that starts a direct-to-Drive backup upload
+// into backupFolder
+// @Summary      Start direct backup upload
+// @Description  Creates a Google Drive resumable upload session in the backup folder with the user's token. The browser PUTs the file to upload_url itself, then registers the file ID Drive returns with POST /backup/register
+// @Tags         backup
+// @Accept       json
+// @Produce      json
+// @Param        request body CreateUploadSessionRequest true "Backup to upload"
+// @Success      201  {object}  CreateUploadSessionResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      413  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /backup/session [post]
+func HandleCreateUploadSession(tokenProvider auth.TokenProvider, storageFactory StorageFactory, backupFolder string, maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var req CreateUploadSessionRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.Size < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid upload request"})
+			return
+		}
+		if req.Size > maxBytes {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": backupTooLargeMessage(maxBytes)})
+			return
+		}
+		filename := filepath.Base(req.Filename)
+		if !strings.HasSuffix(strings.ToLower(filename), ".backup") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File must have .backup extension"})
+			return
+		}
+
+		ctx := c.Request.Context()
+		googleToken, err := tokenProvider.GetGoogleAccessToken(ctx, userID)
+		if err != nil {
+			slog.Error("Failed to get Google access token", "error", err, "user_id", userID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to authenticate with Google"})
+			return
+		}
+
+		driveService, err := storageFactory(ctx, googleToken)
+		if err != nil {
+			slog.Error("Failed to create Drive service", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to initialize storage service"})
+			return
+		}
+		if err := driveService.UseFolder(backupFolder); err != nil {
+			slog.Error("Failed to prepare backup folder", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to initialize storage service"})
+			return
+		}
+		driveService.SetTags(map[string]string{storage.TagUser: userID})
+
+		uploadURL, err := driveService.CreateUploadSession(filename, backupMIMEType, req.Size, c.GetHeader("Origin"))
+		if err != nil {
+			slog.Error("Failed to create upload session", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload session"})
+			return
+		}
+
+		slog.Info("Created direct upload session", "user_id", userID, "filename", filename, "size", req.Size)
+		c.JSON(http.StatusCreated, CreateUploadSessionResponse{UploadURL: uploadURL})
+	}
+}
+
+// HandleRegisterBackup returns a handler that queues a job for a backup uploaded directly to Drive
+// @Summary      Register uploaded backup
+// @Description  Queues a job for a backup the browser uploaded through an upload session. Files over the upload limit are rejected with 413, and files that aren't a ZIP holding a Podcast Addict SQLite database with 422. Registering the same file again returns the job it already created
+// @Tags         backup
+// @Accept       json
+// @Produce      json
+// @Param        request body RegisterBackupRequest true "Uploaded backup"
+// @Success      200  {object}  BackupUploadResponse
+// @Failure      400  {object}  BackupUploadResponse
+// @Failure      401  {object}  BackupUploadResponse
+// @Failure      404  {object}  BackupUploadResponse
+// @Failure      413  {object}  BackupUploadResponse
+// @Failure      422  {object}  BackupUploadResponse
+// @Failure      429  {object}  BackupUploadResponse
+// @Failure      500  {object}  BackupUploadResponse
+// @Router       /backup/register [post]
+func HandleRegisterBackup(jobQueue BackupJobQueue, tokenProvider auth.TokenProvider, storageFactory StorageFactory, maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		fail := func(status int, message string) {
+			c.JSON(status, BackupUploadResponse{Success: false, Error: message})
+		}
+
+		userID, err := GetUserID(c)
+		if err != nil {
+			fail(http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		var req RegisterBackupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			fail(http.StatusBadRequest, "Invalid backup registration")
+			return
+		}
+		label, err := queue.NormalizeLabel(req.Label)
+		if err != nil {
+			fail(http.StatusBadRequest, fmt.Sprintf("Label must be at most %d characters", queue.MaxLabelLength))
+			return
+		}
+
+		ctx := c.Request.Context()
+		googleToken, err := tokenProvider.GetGoogleAccessToken(ctx, userID)
+		if err != nil {
+			slog.Error("Failed to get Google access token", "error", err, "user_id", userID)
+			fail(http.StatusUnauthorized, "Failed to authenticate with Google")
+			return
+		}
+
+		driveService, err := storageFactory(ctx, googleToken)
+		if err != nil {
+			slog.Error("Failed to create Drive service", "error", err)
+			fail(http.StatusInternalServerError, "Failed to initialize storage service")
+			return
+		}
+
+		// Looking the file up with the user's token also proves they can read it
+		meta, err := driveService.GetFileMeta(req.FileID)
+		if err != nil {
+			slog.Error("Failed to get uploaded backup", "error", err, "file_id", req.FileID)
+			fail(http.StatusInternalServerError, "Failed to check uploaded file")
+			return
+		}
+		if meta == nil {
+			fail(http.StatusNotFound, "Uploaded file not found")
+			return
+		}
+		if !strings.HasSuffix(strings.ToLower(meta.Name), ".backup") {
+			fail(http.StatusBadRequest, "File must have .backup extension")
+			return
+		}
+		// The upload session only saw the size the browser declared
+		if meta.Size > maxBytes {
+			slog.Warn("Rejected oversized backup registration", "user_id", userID, "size", meta.Size, "limit", maxBytes)
+			fail(http.StatusRequestEntityTooLarge, backupTooLargeMessage(maxBytes))
+			return
+		}
+		if status, message := validateUploadedBackup(driveService, meta); status != http.StatusOK {
+			fail(status, message)
+			return
+		}
+
+		jobID := uuid.New().String()
+		idempotencyKey := "file:" + meta.ID
+		existingJobID, err := jobQueue.ClaimIdempotencyKey(ctx, userID, idempotencyKey, jobID)
+		if err != nil {
+			slog.Error("Failed to claim idempotency key", "error", err, "user_id", userID)
+			fail(http.StatusInternalServerError, "Failed to queue job for processing")
+			return
+		}
+		if existingJobID != "" {
+			c.JSON(http.StatusOK, BackupUploadResponse{
+				Success:   true,
+				FileID:    meta.ID,
+				JobID:     existingJobID,
+				Duplicate: true,
+				Message:   fmt.Sprintf("File %s was already submitted", meta.Name),
+			})
+			return
+		}
+
+		job := &queue.Job{
+			ID:        jobID,
+			FileID:    meta.ID,
+			UserID:    userID,
+			Filename:  meta.Name,
+			Label:     label,
+			CreatedAt: time.Now(),
+			Priority:  queue.PriorityInteractive,
+			// A failed job frees the key so the backup can be submitted again
+			IdempotencyKey: idempotencyKey,
+		}
+		if err := jobQueue.Enqueue(ctx, job); err != nil {
+			if err := jobQueue.ReleaseIdempotencyKey(context.WithoutCancel(ctx), userID, idempotencyKey, jobID); err != nil {
+				slog.Error("Failed to release idempotency key", "error", err, "user_id", userID)
+			}
+			if errors.Is(err, queue.ErrQuotaExceeded) {
+				fail(http.StatusTooManyRequests, err.Error())
+				return
+			}
+			slog.Error("Failed to enqueue job", "error", err, "job_id", jobID)
+			fail(http.StatusInternalServerError, "Failed to queue job for processing")
+			return
+		}
+
+		c.JSON(http.StatusOK, BackupUploadResponse{
+			Success: true,
+			FileID:  meta.ID,
+			JobID:   jobID,
+			Message: fmt.Sprintf("File %s queued for processing", meta.Name),
+		})
+	}
+}
+
+// validateUploadedBackup downloads a registered backup and checks it like
+// HandleBackupUpload checks uploads, returning http.StatusOK if it's valid and
+// otherwise the status and message to fail the request with
+func validateUploadedBackup(driveService storage.Storage, meta *storage.FileMeta) (int, string) {
+	path, err := driveService.DownloadFileToTemp(meta.ID)
+	if err != nil {
+		slog.Error("Failed to download uploaded backup", "error", err, "file_id", meta.ID)
+		return http.StatusInternalServerError, "Failed to check uploaded file"
+	}
+	defer os.Remove(path)
+
+	if err := sources.ValidateBackup(path); err != nil {
+		slog.Warn("Rejected invalid backup registration", "error", err, "file_id", meta.ID, "filename", meta.Name)
+		if errors.Is(err, sources.ErrInvalidBackup) {
+			return http.StatusUnprocessableEntity, err.Error()
+		}
+		return http.StatusInternalServerError, "Failed to validate backup file"
+	}
+	return http.StatusOK, ""
+}
@@ -0,0 +1,216 @@
+package endpoints
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cobblepod/internal/auth"
+	"cobblepod/internal/queue"
+	"cobblepod/internal/storage"
+	storagemock "cobblepod/internal/storage/mock"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockBackupJobQueue is a mock implementation of BackupJobQueue
+type MockBackupJobQueue struct {
+	mock.Mock
+}
+
+func (m *MockBackupJobQueue) ClaimIdempotencyKey(ctx context.Context, userID string, key string, jobID string) (string, error) {
+	args := m.Called(ctx, userID, key, jobID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockBackupJobQueue) ReleaseIdempotencyKey(ctx context.Context, userID string, key string, jobID string) error {
+	args := m.Called(ctx, userID, key, jobID)
+	return args.Error(0)
+}
+
+func (m *MockBackupJobQueue) Enqueue(ctx context.Context, job *queue.Job) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func jsonRequest(t *testing.T, path string, body any) *http.Request {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+	req, _ := http.NewRequest("POST", path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestHandleCreateUploadSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(drive storage.Storage) *gin.Engine {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", "test-user")
+			c.Next()
+		})
+		router.POST("/backup/session", HandleCreateUploadSession(&auth.MockTokenProvider{Token: "google-token"}, storagemock.NewMockStorageCreator(drive, nil), testBackupFolder, testMaxBackupBytes))
+		return router
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		drive := storagemock.NewMockStorage()
+		drive.CreateUploadSessionURI = "https://upload.example.com/session-1"
+
+		w := httptest.NewRecorder()
+		req := jsonRequest(t, "/backup/session", CreateUploadSessionRequest{Filename: "podcast.backup", Size: 2048})
+		req.Header.Set("Origin", "https://app.example.com")
+		newRouter(drive).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var response CreateUploadSessionResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "https://upload.example.com/session-1", response.UploadURL)
+		assert.Equal(t, []string{testBackupFolder}, drive.UseFolderCalls)
+		if assert.Len(t, drive.CreateUploadSessionCalls, 1) {
+			call := drive.CreateUploadSessionCalls[0]
+			assert.Equal(t, "podcast.backup", call.Filename)
+			assert.Equal(t, int64(2048), call.Size)
+			assert.Equal(t, "https://app.example.com", call.Origin)
+		}
+	})
+
+	t.Run("Wrong extension", func(t *testing.T) {
+		drive := storagemock.NewMockStorage()
+
+		w := httptest.NewRecorder()
+		newRouter(drive).ServeHTTP(w, jsonRequest(t, "/backup/session", CreateUploadSessionRequest{Filename: "notes.txt"}))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Empty(t, drive.CreateUploadSessionCalls)
+	})
+
+	t.Run("Drive error", func(t *testing.T) {
+		drive := storagemock.NewMockStorage()
+		drive.CreateUploadSessionError = errors.New("quota exceeded")
+
+		w := httptest.NewRecorder()
+		newRouter(drive).ServeHTTP(w, jsonRequest(t, "/backup/session", CreateUploadSessionRequest{Filename: "podcast.backup"}))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestHandleRegisterBackup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(mockQueue *MockBackupJobQueue, drive storage.Storage) *gin.Engine {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", "test-user")
+			c.Next()
+		})
+		router.POST("/backup/register", HandleRegisterBackup(mockQueue, &auth.MockTokenProvider{Token: "google-token"}, storagemock.NewMockStorageCreator(drive, nil), testMaxBackupBytes))
+		return router
+	}
+	uploaded := &storage.FileMeta{ID: "file-1", Name: "podcast.backup", Size: 2048}
+	// newDrive serves uploaded, downloading as content
+	newDrive := func(t *testing.T, content []byte) *storagemock.MockStorage {
+		drive := storagemock.NewMockStorage()
+		drive.GetFileMetaResult = uploaded
+		drive.DownloadFileToTempPath = filepath.Join(t.TempDir(), "download")
+		if err := os.WriteFile(drive.DownloadFileToTempPath, content, 0o600); err != nil {
+			t.Fatalf("Failed to write download: %v", err)
+		}
+		return drive
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		drive := newDrive(t, validBackup(t))
+		mockQueue := new(MockBackupJobQueue)
+		mockQueue.On("ClaimIdempotencyKey", mock.Anything, "test-user", "file:file-1", mock.Anything).Return("", nil)
+		mockQueue.On("Enqueue", mock.Anything, mock.MatchedBy(func(job *queue.Job) bool {
+			return job.FileID == "file-1" && job.UserID == "test-user" && job.Filename == "podcast.backup" && job.Label == "weekly"
+		})).Return(nil)
+
+		w := httptest.NewRecorder()
+		newRouter(mockQueue, drive).ServeHTTP(w, jsonRequest(t, "/backup/register", RegisterBackupRequest{FileID: "file-1", Label: "weekly"}))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response BackupUploadResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.True(t, response.Success)
+		assert.NotEmpty(t, response.JobID)
+		mockQueue.AssertExpectations(t)
+	})
+
+	t.Run("Already registered", func(t *testing.T) {
+		drive := newDrive(t, validBackup(t))
+		mockQueue := new(MockBackupJobQueue)
+		mockQueue.On("ClaimIdempotencyKey", mock.Anything, "test-user", "file:file-1", mock.Anything).Return("job-1", nil)
+
+		w := httptest.NewRecorder()
+		newRouter(mockQueue, drive).ServeHTTP(w, jsonRequest(t, "/backup/register", RegisterBackupRequest{FileID: "file-1"}))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response BackupUploadResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "job-1", response.JobID)
+		assert.True(t, response.Duplicate)
+		mockQueue.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Too large", func(t *testing.T) {
+		drive := newDrive(t, validBackup(t))
+		drive.GetFileMetaResult = &storage.FileMeta{ID: "file-1", Name: "podcast.backup", Size: testMaxBackupBytes + 1}
+		mockQueue := new(MockBackupJobQueue)
+
+		w := httptest.NewRecorder()
+		newRouter(mockQueue, drive).ServeHTTP(w, jsonRequest(t, "/backup/register", RegisterBackupRequest{FileID: "file-1"}))
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Empty(t, drive.DownloadFileToTempCalls)
+		mockQueue.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Invalid backup", func(t *testing.T) {
+		drive := newDrive(t, []byte("definitely not a zip"))
+		mockQueue := new(MockBackupJobQueue)
+
+		w := httptest.NewRecorder()
+		newRouter(mockQueue, drive).ServeHTTP(w, jsonRequest(t, "/backup/register", RegisterBackupRequest{FileID: "file-1"}))
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		mockQueue.AssertNotCalled(t, "ClaimIdempotencyKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("File not found", func(t *testing.T) {
+		drive := storagemock.NewMockStorage()
+		mockQueue := new(MockBackupJobQueue)
+
+		w := httptest.NewRecorder()
+		newRouter(mockQueue, drive).ServeHTTP(w, jsonRequest(t, "/backup/register", RegisterBackupRequest{FileID: "missing"}))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Enqueue failure releases the key", func(t *testing.T) {
+		drive := newDrive(t, validBackup(t))
+		mockQueue := new(MockBackupJobQueue)
+		mockQueue.On("ClaimIdempotencyKey", mock.Anything, "test-user", "file:file-1", mock.Anything).Return("", nil)
+		mockQueue.On("Enqueue", mock.Anything, mock.Anything).Return(errors.New("redis down"))
+		mockQueue.On("ReleaseIdempotencyKey", mock.Anything, "test-user", "file:file-1", mock.Anything).Return(nil)
+
+		w := httptest.NewRecorder()
+		newRouter(mockQueue, drive).ServeHTTP(w, jsonRequest(t, "/backup/register", RegisterBackupRequest{FileID: "file-1"}))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		mockQueue.AssertExpectations(t)
+	})
+}
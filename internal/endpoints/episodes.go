@@ -0,0 +1,152 @@
+package endpoints
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"cobblepod/internal/auth"
+	"cobblepod/internal/podcast"
+	"cobblepod/internal/queue"
+	"cobblepod/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EpisodeResolver returns the URL storage currently serves a file from
+type EpisodeResolver func(fileID string) string
+
+// DownloadRecorder defines the queue operation that counts episode downloads
+type DownloadRecorder interface {
+	RecordEpisodeDownload(ctx context.Context, fileID string, userAgent string) error
+}
+
+// DownloadStats defines the queue operation that reads episode download counts
+type DownloadStats interface {
+	GetEpisodeDownloads(ctx context.Context, fileIDs []string) (map[string]*queue.EpisodeDownloads, error)
+}
+
+// EpisodeStats reports how often an episode in the user's feed was downloaded
+type EpisodeStats struct {
+	FileID     string           `json:"file_id"`
+	Name       string           `json:"name"`
+	Downloads  int64            `json:"downloads"`
+	UserAgents map[string]int64 `json:"user_agents"` // Downloads by client app
+}
+
+// FeedStatsResponse lists the episodes in the user's feed, most downloaded first
+type FeedStatsResponse struct {
+	Episodes []EpisodeStats `json:"episodes"`
+}
+
+// startsDownload reports whether a request fetches an episode from its start.
+// Apps fetch an episode in several ranges, and only the first counts.
+func startsDownload(r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+	rangeHeader := r.Header.Get("Range")
+	return rangeHeader == "" || strings.HasPrefix(rangeHeader, "bytes=0-")
+}
+
+// HandleGetEpisodeAudio returns a handler that redirects episode links in feeds
+// to storage, counting each download. links is nil when episodes are linked
+// straight to storage.
+// @Summary      Get episode audio
+// @Description  Redirects an episode link published in a feed to where storage serves the file. Links are signed, so only episodes cobblepod published are redirected
+// @Tags         feed
+// @Param        id path string true "Episode file ID"
+// @Param        token query string true "Link signature"
+// @Success      302
+// @Failure      404  {string}  string
+// @Router       /episodes/{id}/audio [get]
+func HandleGetEpisodeAudio(links *storage.EpisodeLinks, resolve EpisodeResolver, recorder DownloadRecorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		fileID := c.Param("id")
+		if links == nil || !links.Verify(fileID, c.Query("token")) {
+			c.String(http.StatusNotFound, "Episode not found")
+			return
+		}
+		if startsDownload(c.Request) {
+			if err := recorder.RecordEpisodeDownload(c.Request.Context(), fileID, c.Request.UserAgent()); err != nil {
+				slog.Warn("Failed to record episode download", "error", err, "file_id", fileID)
+			}
+		}
+		// Storage URLs may change, so clients shouldn't remember where this led
+		c.Header("Cache-Control", "no-cache")
+		c.Redirect(http.StatusFound, resolve(fileID))
+	}
+}
+
+// HandleGetFeedStats returns a handler that reports downloads of the user's episodes
+// in the feed folder of folders
+// @Summary      Feed stats
+// @Description  Lists the episodes in the user's feed with how often podcast apps downloaded them, most downloaded first. Only downloads through episode links served by cobblepod are counted, see EPISODE_BASE_URL
+// @Tags         feed
+// @Produce      json
+// @Success      200  {object}  FeedStatsResponse
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /feed/stats [get]
+func HandleGetFeedStats(stats DownloadStats, tokenProvider auth.TokenProvider, storageFactory StorageFactory, folders podcast.Folders) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		ctx := c.Request.Context()
+		googleToken, err := tokenProvider.GetGoogleAccessToken(ctx, userID)
+		if err != nil {
+			slog.Error("Failed to get Google access token", "error", err, "user_id", userID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to authenticate with Google"})
+			return
+		}
+		driveService, err := storageFactory(ctx, googleToken)
+		if err != nil {
+			slog.Error("Failed to create Drive service", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to initialize storage service"})
+			return
+		}
+
+		files, err := driveService.GetFiles(storage.Query{Tags: map[string]string{storage.TagUser: userID, storage.TagFeed: folders.Feed}})
+		if err != nil {
+			slog.Error("Failed to list stored episodes", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get feed stats"})
+			return
+		}
+		var episodes []*storage.FileMeta
+		for _, file := range files {
+			if strings.HasPrefix(file.MIME, "audio/") {
+				episodes = append(episodes, file)
+			}
+		}
+		fileIDs := make([]string, len(episodes))
+		for i, episode := range episodes {
+			fileIDs[i] = episode.ID
+		}
+
+		downloads, err := stats.GetEpisodeDownloads(ctx, fileIDs)
+		if err != nil {
+			slog.Error("Failed to get episode downloads", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get feed stats"})
+			return
+		}
+
+		response := FeedStatsResponse{Episodes: make([]EpisodeStats, len(episodes))}
+		for i, episode := range episodes {
+			counts := downloads[episode.ID]
+			if counts == nil {
+				counts = &queue.EpisodeDownloads{UserAgents: map[string]int64{}}
+			}
+			response.Episodes[i] = EpisodeStats{FileID: episode.ID, Name: episode.Name, Downloads: counts.Total, UserAgents: counts.UserAgents}
+		}
+		sort.SliceStable(response.Episodes, func(i, j int) bool {
+			return response.Episodes[i].Downloads > response.Episodes[j].Downloads
+		})
+		c.JSON(http.StatusOK, response)
+	}
+}
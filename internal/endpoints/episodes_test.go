@@ -0,0 +1,144 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cobblepod/internal/auth"
+	"cobblepod/internal/podcast"
+	"cobblepod/internal/queue"
+	"cobblepod/internal/storage"
+	storagemock "cobblepod/internal/storage/mock"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockDownloadStore is a mock implementation of DownloadRecorder and DownloadStats
+type MockDownloadStore struct {
+	mock.Mock
+}
+
+func (m *MockDownloadStore) RecordEpisodeDownload(ctx context.Context, fileID string, userAgent string) error {
+	args := m.Called(ctx, fileID, userAgent)
+	return args.Error(0)
+}
+
+func (m *MockDownloadStore) GetEpisodeDownloads(ctx context.Context, fileIDs []string) (map[string]*queue.EpisodeDownloads, error) {
+	args := m.Called(ctx, fileIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]*queue.EpisodeDownloads), args.Error(1)
+}
+
+func TestHandleGetEpisodeAudio(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	links := storage.NewEpisodeLinks("https://cobblepod.example.com", "0123456789abcdef")
+	resolve := func(fileID string) string { return "https://storage.example.com/" + fileID }
+
+	tests := []struct {
+		name      string
+		links     *storage.EpisodeLinks
+		method    string
+		url       string
+		rangeSpec string
+		code      int
+		counted   bool
+	}{
+		{name: "signed link", links: links, url: "/episodes/file-1/audio?token=" + links.Token("file-1"), code: http.StatusFound, counted: true},
+		{name: "first range", links: links, url: "/episodes/file-1/audio?token=" + links.Token("file-1"), rangeSpec: "bytes=0-", code: http.StatusFound, counted: true},
+		{name: "later range", links: links, url: "/episodes/file-1/audio?token=" + links.Token("file-1"), rangeSpec: "bytes=1024-", code: http.StatusFound},
+		{name: "head", links: links, method: "HEAD", url: "/episodes/file-1/audio?token=" + links.Token("file-1"), code: http.StatusFound},
+		{name: "token for another file", links: links, url: "/episodes/file-2/audio?token=" + links.Token("file-1"), code: http.StatusNotFound},
+		{name: "no token", links: links, url: "/episodes/file-1/audio", code: http.StatusNotFound},
+		{name: "links not configured", url: "/episodes/file-1/audio?token=" + links.Token("file-1"), code: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := new(MockDownloadStore)
+			recorder.On("RecordEpisodeDownload", mock.Anything, "file-1", "AntennaPod/3.4.0").Return(nil)
+			router := gin.New()
+			handler := HandleGetEpisodeAudio(tt.links, resolve, recorder)
+			router.GET("/episodes/:id/audio", handler)
+			router.HEAD("/episodes/:id/audio", handler)
+
+			method := tt.method
+			if method == "" {
+				method = "GET"
+			}
+			req, _ := http.NewRequest(method, tt.url, nil)
+			req.Header.Set("User-Agent", "AntennaPod/3.4.0")
+			if tt.rangeSpec != "" {
+				req.Header.Set("Range", tt.rangeSpec)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == http.StatusFound {
+				assert.Equal(t, "https://storage.example.com/file-1", w.Header().Get("Location"))
+			}
+			if tt.counted {
+				recorder.AssertNumberOfCalls(t, "RecordEpisodeDownload", 1)
+			} else {
+				recorder.AssertNotCalled(t, "RecordEpisodeDownload", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+
+	t.Run("redirects when recording fails", func(t *testing.T) {
+		recorder := new(MockDownloadStore)
+		recorder.On("RecordEpisodeDownload", mock.Anything, "file-1", mock.Anything).Return(errors.New("redis down"))
+		router := gin.New()
+		router.GET("/episodes/:id/audio", HandleGetEpisodeAudio(links, resolve, recorder))
+
+		req, _ := http.NewRequest("GET", "/episodes/file-1/audio?token="+links.Token("file-1"), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusFound, w.Code)
+	})
+}
+
+func TestHandleGetFeedStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	drive := storagemock.NewMockStorage()
+	drive.GetFilesFiles = []*storage.FileMeta{
+		{ID: "ep-1", Name: "Episode 1.m4a", MIME: "audio/mp4"},
+		{ID: "rss-1", Name: podcast.RSSFilename, MIME: "application/rss+xml"},
+		{ID: "ep-2", Name: "Episode 2.mp3", MIME: "audio/mpeg"},
+	}
+	stats := new(MockDownloadStore)
+	stats.On("GetEpisodeDownloads", mock.Anything, []string{"ep-1", "ep-2"}).Return(map[string]*queue.EpisodeDownloads{
+		"ep-1": {UserAgents: map[string]int64{}},
+		"ep-2": {Total: 3, UserAgents: map[string]int64{"AntennaPod": 2, "Overcast": 1}},
+	}, nil)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "test-user")
+		c.Next()
+	})
+	router.GET("/api/feed/stats", HandleGetFeedStats(stats, &auth.MockTokenProvider{Token: "google-token"}, storagemock.NewMockStorageCreator(drive, nil), testFolders))
+
+	req, _ := http.NewRequest("GET", "/api/feed/stats", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"episodes":[
+		{"file_id":"ep-2","name":"Episode 2.mp3","downloads":3,"user_agents":{"AntennaPod":2,"Overcast":1}},
+		{"file_id":"ep-1","name":"Episode 1.m4a","downloads":0,"user_agents":{}}
+	]}`, w.Body.String())
+	if assert.Len(t, drive.GetFilesCalls, 1) {
+		assert.Equal(t, map[string]string{storage.TagUser: "test-user", storage.TagFeed: testFolders.Feed}, drive.GetFilesCalls[0].Query.Tags)
+	}
+	stats.AssertExpectations(t)
+}
@@ -0,0 +1,90 @@
+package endpoints
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventsKeepAlive is how often a comment is sent to keep idle SSE connections open
+const eventsKeepAlive = 30 * time.Second
+
+// EventSubscriber defines the interface for subscribing to user events
+type EventSubscriber interface {
+	SubscribeEvents(ctx context.Context, userID string) (<-chan queue.Event, error)
+}
+
+// HandleEvents returns a handler that streams user-scoped events over Server-Sent Events
+// @Summary      Stream events
+// @Description  Streams job created, started and finished, item updated and feed updated events for the authenticated user
+// @Tags         events
+// @Produce      text/event-stream
+// @Success      200  {object}  queue.Event
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /events [get]
+func HandleEvents(subscriber EventSubscriber) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		ctx := c.Request.Context()
+		events, err := subscriber.SubscribeEvents(ctx, userID)
+		if err != nil {
+			slog.Error("Failed to subscribe to events", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to subscribe to events"})
+			return
+		}
+
+		streamEvents(c, events, nil)
+	}
+}
+
+// streamEvents writes events to the response as Server-Sent Events until the
+// client goes away or the subscription ends. A nil keep function sends every
+// event.
+func streamEvents(c *gin.Context, events <-chan queue.Event, keep func(queue.Event) bool) {
+	ctx := c.Request.Context()
+
+	// The server's write timeout would otherwise cut long-lived streams
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		slog.Debug("Unable to clear write deadline for event stream", "error", err)
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			if keep == nil || keep(event) {
+				c.SSEvent(string(event.Type), event)
+			}
+			return true
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return false
+			}
+			return true
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeEventSubscriber delivers a fixed set of events and then closes the stream
+type fakeEventSubscriber struct {
+	events []queue.Event
+	err    error
+	userID string
+}
+
+func (f *fakeEventSubscriber) SubscribeEvents(ctx context.Context, userID string) (<-chan queue.Event, error) {
+	f.userID = userID
+	if f.err != nil {
+		return nil, f.err
+	}
+	ch := make(chan queue.Event, len(f.events))
+	for _, event := range f.events {
+		ch <- event
+	}
+	close(ch)
+	return ch, nil
+}
+
+// streamRecorder is a ResponseRecorder gin can stream to, as c.Stream needs
+// the writer to be an http.CloseNotifier
+type streamRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func newStreamRecorder() *streamRecorder {
+	return &streamRecorder{ResponseRecorder: httptest.NewRecorder()}
+}
+
+func (r *streamRecorder) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
+
+func TestHandleEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		router := gin.New()
+		router.GET("/events", HandleEvents(&fakeEventSubscriber{}))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/events", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Streams events", func(t *testing.T) {
+		subscriber := &fakeEventSubscriber{events: []queue.Event{
+			{Type: queue.EventJobCreated, JobID: "job-1", Status: "queued"},
+			{Type: queue.EventFeedUpdated, JobID: "job-1"},
+		}}
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", "test-user")
+			c.Next()
+		})
+		router.GET("/events", HandleEvents(subscriber))
+
+		w := newStreamRecorder()
+		req, _ := http.NewRequest("GET", "/events", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "test-user", subscriber.userID)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/event-stream")
+		assert.Contains(t, w.Body.String(), "event:job.created")
+		assert.Contains(t, w.Body.String(), "event:feed.updated")
+		assert.Contains(t, w.Body.String(), `"job_id":"job-1"`)
+	})
+
+	t.Run("Subscribe error", func(t *testing.T) {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", "test-user")
+			c.Next()
+		})
+		router.GET("/events", HandleEvents(&fakeEventSubscriber{err: errors.New("redis down")}))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/events", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
@@ -0,0 +1,172 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"cobblepod/internal/auth"
+	"cobblepod/internal/podcast"
+
+	"github.com/gin-gonic/gin"
+)
+
+// feedCacheControl lets podcast apps reuse a fetched feed for a few minutes.
+// The URL is a secret, so shared caches must not keep it.
+const feedCacheControl = "private, max-age=300"
+
+// FeedStore defines the queue operations needed to serve feeds by slug
+type FeedStore interface {
+	GetFeedSlug(ctx context.Context, userID string) (string, error)
+	RotateFeedSlug(ctx context.Context, userID string) (string, error)
+	LookupFeedSlug(ctx context.Context, slug string) (string, error)
+}
+
+// FeedLinkResponse carries the URL podcast apps subscribe to
+type FeedLinkResponse struct {
+	URL string `json:"url"`
+}
+
+// feedLink returns the absolute URL of the feed with the given slug, as seen by
+// the client that made the request
+func feedLink(c *gin.Context, slug string) FeedLinkResponse {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return FeedLinkResponse{URL: fmt.Sprintf("%s://%s/api/feed/%s", scheme, c.Request.Host, slug)}
+}
+
+// HandleGetFeedLink returns a handler that reports the user's feed URL
+// @Summary      Get feed URL
+// @Description  Returns the secret URL podcast apps subscribe to, creating it the first time
+// @Tags         feed
+// @Produce      json
+// @Success      200  {object}  FeedLinkResponse
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /feed [get]
+func HandleGetFeedLink(store FeedStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		slug, err := store.GetFeedSlug(c.Request.Context(), userID)
+		if err != nil {
+			slog.Error("Failed to get feed slug", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get feed URL"})
+			return
+		}
+		c.JSON(http.StatusOK, feedLink(c, slug))
+	}
+}
+
+// HandleRotateFeedLink returns a handler that replaces the user's feed URL
+// @Summary      Rotate feed URL
+// @Description  Replaces the secret feed URL, for when it leaked. Podcast apps subscribed to the old URL must subscribe again
+// @Tags         feed
+// @Produce      json
+// @Success      200  {object}  FeedLinkResponse
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /feed/rotate [post]
+func HandleRotateFeedLink(store FeedStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		slug, err := store.RotateFeedSlug(c.Request.Context(), userID)
+		if err != nil {
+			slog.Error("Failed to rotate feed slug", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate feed URL"})
+			return
+		}
+		slog.Info("Rotated feed URL", "user_id", userID)
+		c.JSON(http.StatusOK, feedLink(c, slug))
+	}
+}
+
+// HandleGetFeed returns a handler that serves a user's RSS feed to podcast apps
+// from the feed folder of folders
+// @Summary      Get feed
+// @Description  Serves the latest RSS feed of the user the slug belongs to. The slug is the only credential, see GET /feed. Supports conditional requests with ETag and Last-Modified
+// @Tags         feed
+// @Produce      application/rss+xml
+// @Param        slug path string true "Feed slug"
+// @Success      200  {string}  string
+// @Success      304  {string}  string
+// @Failure      404  {string}  string
+// @Failure      500  {string}  string
+// @Router       /feed/{slug} [get]
+func HandleGetFeed(store FeedStore, tokenProvider auth.TokenProvider, storageFactory StorageFactory, folders podcast.Folders) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		userID, err := store.LookupFeedSlug(ctx, c.Param("slug"))
+		if err != nil {
+			slog.Error("Failed to look up feed slug", "error", err)
+			c.String(http.StatusInternalServerError, "Failed to get feed")
+			return
+		}
+		if userID == "" {
+			c.String(http.StatusNotFound, "Feed not found")
+			return
+		}
+
+		googleToken, err := tokenProvider.GetGoogleAccessToken(ctx, userID)
+		if err != nil {
+			slog.Error("Failed to get Google access token", "error", err, "user_id", userID)
+			c.String(http.StatusInternalServerError, "Failed to get feed")
+			return
+		}
+		driveService, err := storageFactory(ctx, googleToken)
+		if err != nil {
+			slog.Error("Failed to create Drive service", "error", err)
+			c.String(http.StatusInternalServerError, "Failed to get feed")
+			return
+		}
+
+		files, err := driveService.GetFiles(folders.RSSQuery().MostRecent())
+		if err != nil {
+			slog.Error("Failed to find feed", "error", err, "user_id", userID)
+			c.String(http.StatusInternalServerError, "Failed to get feed")
+			return
+		}
+		if len(files) == 0 {
+			c.String(http.StatusNotFound, "Feed not found")
+			return
+		}
+		feed := files[0]
+
+		// Answer an unchanged feed from its metadata, without downloading it
+		etag := fileETag(feed)
+		c.Header("ETag", etag)
+		c.Header("Cache-Control", feedCacheControl)
+		if !feed.ModifiedTime.IsZero() {
+			c.Header("Last-Modified", feed.ModifiedTime.UTC().Format(http.TimeFormat))
+		}
+		if notModified(c.Request, etag, feed.ModifiedTime) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+
+		content, err := driveService.DownloadFile(feed.ID)
+		if err != nil {
+			slog.Error("Failed to download feed", "error", err, "user_id", userID)
+			c.String(http.StatusInternalServerError, "Failed to get feed")
+			return
+		}
+
+		c.Header("Content-Type", "application/rss+xml; charset=utf-8")
+		// ServeContent also answers HEAD and Range requests
+		http.ServeContent(c.Writer, c.Request, feed.Name, feed.ModifiedTime, strings.NewReader(content))
+	}
+}
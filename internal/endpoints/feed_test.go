@@ -0,0 +1,147 @@
+package endpoints
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cobblepod/internal/auth"
+	"cobblepod/internal/podcast"
+	"cobblepod/internal/storage"
+	storagemock "cobblepod/internal/storage/mock"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockFeedStore is a mock implementation of FeedStore
+type MockFeedStore struct {
+	mock.Mock
+}
+
+func (m *MockFeedStore) GetFeedSlug(ctx context.Context, userID string) (string, error) {
+	args := m.Called(ctx, userID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockFeedStore) RotateFeedSlug(ctx context.Context, userID string) (string, error) {
+	args := m.Called(ctx, userID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockFeedStore) LookupFeedSlug(ctx context.Context, slug string) (string, error) {
+	args := m.Called(ctx, slug)
+	return args.String(0), args.Error(1)
+}
+
+func TestHandleGetFeedLink(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := new(MockFeedStore)
+	store.On("GetFeedSlug", mock.Anything, "test-user").Return("secret", nil)
+	store.On("RotateFeedSlug", mock.Anything, "test-user").Return("fresh", nil)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "test-user")
+		c.Next()
+	})
+	router.GET("/api/feed", HandleGetFeedLink(store))
+	router.POST("/api/feed/rotate", HandleRotateFeedLink(store))
+
+	req, _ := http.NewRequest("GET", "/api/feed", nil)
+	req.Host = "cobblepod.example.com"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"url":"https://cobblepod.example.com/api/feed/secret"}`, w.Body.String())
+
+	req, _ = http.NewRequest("POST", "/api/feed/rotate", nil)
+	req.Host = "localhost:8080"
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"url":"http://localhost:8080/api/feed/fresh"}`, w.Body.String())
+	store.AssertExpectations(t)
+}
+
+func TestHandleGetFeed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	modified := time.Date(2025, 6, 3, 9, 0, 0, 0, time.UTC)
+	const rss = `<?xml version="1.0"?><rss version="2.0"></rss>`
+
+	newRouter := func(drive storage.Storage) *gin.Engine {
+		store := new(MockFeedStore)
+		store.On("LookupFeedSlug", mock.Anything, "secret").Return("test-user", nil)
+		store.On("LookupFeedSlug", mock.Anything, "unknown").Return("", nil)
+		router := gin.New()
+		router.GET("/feed/:slug", HandleGetFeed(store, &auth.MockTokenProvider{Token: "google-token"}, storagemock.NewMockStorageCreator(drive, nil), testFolders))
+		return router
+	}
+	newDrive := func(md5 string) *storagemock.MockStorage {
+		drive := storagemock.NewMockStorage()
+		drive.GetFilesFiles = []*storage.FileMeta{{ID: "rss-1", Name: podcast.RSSFilename, ModifiedTime: modified, MD5: md5}}
+		drive.DownloadFileContent = rss
+		return drive
+	}
+
+	t.Run("Serves feed", func(t *testing.T) {
+		drive := newDrive("abc123")
+		req, _ := http.NewRequest("GET", "/feed/secret", nil)
+		w := httptest.NewRecorder()
+		newRouter(drive).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, rss, w.Body.String())
+		assert.Equal(t, `"abc123"`, w.Header().Get("ETag"))
+		assert.Equal(t, feedCacheControl, w.Header().Get("Cache-Control"))
+		assert.Equal(t, "application/rss+xml; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, modified.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+	})
+
+	t.Run("Unchanged feed isn't downloaded", func(t *testing.T) {
+		drive := newDrive("abc123")
+		req, _ := http.NewRequest("GET", "/feed/secret", nil)
+		req.Header.Set("If-None-Match", `W/"other", "abc123"`)
+		w := httptest.NewRecorder()
+		newRouter(drive).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.Empty(t, drive.DownloadFileCalls)
+	})
+
+	t.Run("Not modified since", func(t *testing.T) {
+		drive := newDrive("")
+		req, _ := http.NewRequest("GET", "/feed/secret", nil)
+		req.Header.Set("If-Modified-Since", modified.Add(time.Hour).Format(http.TimeFormat))
+		w := httptest.NewRecorder()
+		newRouter(drive).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.True(t, strings.HasPrefix(w.Header().Get("ETag"), `W/"`))
+		assert.Empty(t, drive.DownloadFileCalls)
+	})
+
+	t.Run("Unknown slug", func(t *testing.T) {
+		drive := newDrive("abc123")
+		req, _ := http.NewRequest("GET", "/feed/unknown", nil)
+		w := httptest.NewRecorder()
+		newRouter(drive).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Empty(t, drive.GetFilesCalls)
+	})
+
+	t.Run("No feed yet", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/feed/secret", nil)
+		w := httptest.NewRecorder()
+		newRouter(storagemock.NewMockStorage()).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,77 @@
+package endpoints
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+)
+
+// GoogleLogin is the part of auth.GoogleProvider used to sign users in
+type GoogleLogin interface {
+	Exchange(ctx context.Context, code string) (*oauth2.Token, string, error)
+	SaveToken(ctx context.Context, userID string, token *oauth2.Token) error
+}
+
+// GoogleLoginRequest carries an authorization code from the Google Identity Services popup flow
+type GoogleLoginRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// GoogleLoginResponse returns the ID token to send as the bearer token
+type GoogleLoginResponse struct {
+	IDToken   string    `json:"id_token"`
+	UserID    string    `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// HandleGoogleLogin returns a handler that signs a user in with Google when the
+// deployment uses the google auth provider
+// @Summary      Sign in with Google
+// @Description  Exchange a Google authorization code for an ID token to use as the bearer token, and store the user's Google tokens so jobs can run on their behalf. Only available when AUTH_PROVIDER is google
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body GoogleLoginRequest true "Authorization code"
+// @Success      200  {object}  GoogleLoginResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /auth/google [post]
+func HandleGoogleLogin(login GoogleLogin, validate TokenValidator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		var req GoogleLoginRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Authorization code is required"})
+			return
+		}
+
+		token, idToken, err := login.Exchange(ctx, req.Code)
+		if err != nil {
+			slog.Warn("Failed to exchange Google authorization code", "error", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization code"})
+			return
+		}
+
+		identity, err := validate(ctx, idToken)
+		if err != nil {
+			slog.Warn("Google returned an invalid ID token", "error", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid ID token"})
+			return
+		}
+		userID := identity.UserID
+
+		if err := login.SaveToken(ctx, userID, token); err != nil {
+			slog.Error("Failed to save Google token", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
+			return
+		}
+
+		c.JSON(http.StatusOK, GoogleLoginResponse{IDToken: idToken, UserID: userID, ExpiresAt: token.Expiry})
+	}
+}
@@ -0,0 +1,99 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/oauth2"
+)
+
+// MockGoogleLogin is a mock implementation of GoogleLogin
+type MockGoogleLogin struct {
+	mock.Mock
+}
+
+func (m *MockGoogleLogin) Exchange(ctx context.Context, code string) (*oauth2.Token, string, error) {
+	args := m.Called(ctx, code)
+	if args.Get(0) == nil {
+		return nil, "", args.Error(2)
+	}
+	return args.Get(0).(*oauth2.Token), args.String(1), args.Error(2)
+}
+
+func (m *MockGoogleLogin) SaveToken(ctx context.Context, userID string, token *oauth2.Token) error {
+	args := m.Called(ctx, userID, token)
+	return args.Error(0)
+}
+
+func TestHandleGoogleLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	validate := func(ctx context.Context, token string) (Identity, error) {
+		if token != "id-token" {
+			return Identity{}, errors.New("bad signature")
+		}
+		return Identity{UserID: "google-oauth2|123"}, nil
+	}
+	newRouter := func(login GoogleLogin) *gin.Engine {
+		router := gin.New()
+		router.POST("/auth/google", HandleGoogleLogin(login, validate))
+		return router
+	}
+	post := func(router *gin.Engine, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/auth/google", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		token := &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)}
+		login := new(MockGoogleLogin)
+		login.On("Exchange", mock.Anything, "auth-code").Return(token, "id-token", nil)
+		login.On("SaveToken", mock.Anything, "google-oauth2|123", token).Return(nil)
+
+		w := post(newRouter(login), `{"code":"auth-code"}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response GoogleLoginResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "id-token", response.IDToken)
+		assert.Equal(t, "google-oauth2|123", response.UserID)
+		login.AssertExpectations(t)
+	})
+
+	t.Run("Missing code", func(t *testing.T) {
+		w := post(newRouter(new(MockGoogleLogin)), `{}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Exchange fails", func(t *testing.T) {
+		login := new(MockGoogleLogin)
+		login.On("Exchange", mock.Anything, "expired").Return(nil, "", errors.New("invalid_grant"))
+
+		w := post(newRouter(login), `{"code":"expired"}`)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		login.AssertNotCalled(t, "SaveToken", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Invalid ID token", func(t *testing.T) {
+		login := new(MockGoogleLogin)
+		login.On("Exchange", mock.Anything, "auth-code").Return(&oauth2.Token{}, "forged", nil)
+
+		w := post(newRouter(login), `{"code":"auth-code"}`)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		login.AssertNotCalled(t, "SaveToken", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,91 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"cobblepod/internal/auth"
+	"cobblepod/internal/podcast"
+	"cobblepod/internal/queue"
+	"cobblepod/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HistoryPurger defines the queue operation needed to delete a user's history
+type HistoryPurger interface {
+	PurgeUserHistory(ctx context.Context, userID string) (int, error)
+}
+
+// DeleteHistoryResponse reports what deleting the user's history removed
+type DeleteHistoryResponse struct {
+	JobsDeleted  int `json:"jobs_deleted"`
+	FilesDeleted int `json:"files_deleted"`
+}
+
+// HandleDeleteHistory returns a handler that deletes the user's jobs and the feed
+// stored in the feed folder of folders
+// @Summary      Delete history
+// @Description  Deletes every job the user has, with its items and timeline, then the episodes and feed stored for them. Files stored before uploads were tagged aren't found. Settings, clips, backups and API keys are kept. Fails while a job is running
+// @Tags         history
+// @Produce      json
+// @Success      200  {object}  DeleteHistoryResponse
+// @Failure      401  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /history [delete]
+func HandleDeleteHistory(purger HistoryPurger, tokenProvider auth.TokenProvider, storageFactory StorageFactory, folders podcast.Folders) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		ctx := c.Request.Context()
+		googleToken, err := tokenProvider.GetGoogleAccessToken(ctx, userID)
+		if err != nil {
+			slog.Error("Failed to get Google access token", "error", err, "user_id", userID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to authenticate with Google"})
+			return
+		}
+		driveService, err := storageFactory(ctx, googleToken)
+		if err != nil {
+			slog.Error("Failed to create Drive service", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to initialize storage service"})
+			return
+		}
+
+		// Jobs go first, so a running job stops the purge before any of its
+		// episodes are deleted
+		jobs, err := purger.PurgeUserHistory(ctx, userID)
+		if errors.Is(err, queue.ErrJobsRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Wait for running jobs to finish before deleting your history"})
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to purge job history", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete history"})
+			return
+		}
+
+		files, err := driveService.GetFiles(storage.Query{Tags: map[string]string{storage.TagUser: userID, storage.TagFeed: folders.Feed}})
+		if err != nil {
+			slog.Error("Failed to list stored episodes", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete stored episodes"})
+			return
+		}
+		for _, file := range files {
+			if err := driveService.DeleteFile(file.ID); err != nil {
+				slog.Error("Failed to delete stored episode", "error", err, "file_id", file.ID)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete stored episodes"})
+				return
+			}
+		}
+
+		slog.Info("Deleted user history", "user_id", userID, "jobs", jobs, "files", len(files))
+		c.JSON(http.StatusOK, DeleteHistoryResponse{JobsDeleted: jobs, FilesDeleted: len(files)})
+	}
+}
@@ -0,0 +1,89 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cobblepod/internal/auth"
+	"cobblepod/internal/queue"
+	"cobblepod/internal/storage"
+	storagemock "cobblepod/internal/storage/mock"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockHistoryPurger is a mock implementation of HistoryPurger
+type MockHistoryPurger struct {
+	mock.Mock
+}
+
+func (m *MockHistoryPurger) PurgeUserHistory(ctx context.Context, userID string) (int, error) {
+	args := m.Called(ctx, userID)
+	return args.Int(0), args.Error(1)
+}
+
+func newHistoryRouter(purger HistoryPurger, drive storage.Storage) *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "test-user")
+		c.Next()
+	})
+	router.DELETE("/history", HandleDeleteHistory(purger, &auth.MockTokenProvider{Token: "google-token"}, storagemock.NewMockStorageCreator(drive, nil), testFolders))
+	return router
+}
+
+func TestHandleDeleteHistory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Success", func(t *testing.T) {
+		purger := new(MockHistoryPurger)
+		purger.On("PurgeUserHistory", mock.Anything, "test-user").Return(3, nil)
+		drive := storagemock.NewMockStorage()
+		drive.GetFilesFiles = []*storage.FileMeta{{ID: "episode-1"}, {ID: "feed"}}
+
+		req, _ := http.NewRequest("DELETE", "/history", nil)
+		w := httptest.NewRecorder()
+		newHistoryRouter(purger, drive).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"jobs_deleted":3,"files_deleted":2}`, w.Body.String())
+		assert.Equal(t, []string{"episode-1", "feed"}, drive.DeleteFileCalls)
+		if assert.Len(t, drive.GetFilesCalls, 1) {
+			assert.Equal(t, map[string]string{storage.TagUser: "test-user", storage.TagFeed: testFolders.Feed}, drive.GetFilesCalls[0].Query.Tags)
+		}
+		purger.AssertExpectations(t)
+	})
+
+	t.Run("JobsRunning", func(t *testing.T) {
+		purger := new(MockHistoryPurger)
+		purger.On("PurgeUserHistory", mock.Anything, "test-user").Return(0, queue.ErrJobsRunning)
+		drive := storagemock.NewMockStorage()
+
+		req, _ := http.NewRequest("DELETE", "/history", nil)
+		w := httptest.NewRecorder()
+		newHistoryRouter(purger, drive).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Empty(t, drive.GetFilesCalls)
+		assert.Empty(t, drive.DeleteFileCalls)
+	})
+
+	t.Run("DeleteFailure", func(t *testing.T) {
+		purger := new(MockHistoryPurger)
+		purger.On("PurgeUserHistory", mock.Anything, "test-user").Return(1, nil)
+		drive := storagemock.NewMockStorage()
+		drive.GetFilesFiles = []*storage.FileMeta{{ID: "episode-1"}}
+		drive.DeleteFileError = errors.New("drive down")
+
+		req, _ := http.NewRequest("DELETE", "/history", nil)
+		w := httptest.NewRecorder()
+		newHistoryRouter(purger, drive).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
@@ -0,0 +1,123 @@
+package endpoints
+
+import (
+	"time"
+
+	"cobblepod/internal/queue"
+)
+
+// JobResponse is a job as the API returns it. It copies queue.Job field by
+// field so the queue can change how it stores jobs without changing what
+// clients see.
+type JobResponse struct {
+	ID          string            `json:"id"`
+	FileID      string            `json:"file_id"`
+	UserID      string            `json:"user_id,omitempty"`
+	Filename    string            `json:"filename,omitempty"`
+	Label       string            `json:"label,omitempty"` // User supplied note shown in listings
+	CreatedAt   time.Time         `json:"created_at"`
+	FailReason  string            `json:"fail_reason,omitempty"`  // Set when job fails
+	FailedItems int               `json:"failed_items,omitempty"` // Set when job completes with errors
+	Attempts    int               `json:"attempts,omitempty"`     // Times a worker has picked up the job
+	RetryOf     string            `json:"retry_of,omitempty"`     // Job whose failed items this job retries
+	Status      string            `json:"status"`                 // scheduled, queued, running, completed, completed_with_errors, failed
+	RunAt       time.Time         `json:"run_at,omitzero"`        // When a scheduled job becomes due
+	Priority    string            `json:"priority,omitempty"`     // interactive jobs are dequeued first
+	FeedURL     string            `json:"feed_url,omitempty"`     // Subscription URL of the feed the job published
+	Items       []JobItemResponse `json:"items"`
+	TotalItems  int               `json:"total_items"` // Number of items in the job
+	Completed   int               `json:"completed"`   // Items processed and uploaded
+	Failed      int               `json:"failed"`      // Items that failed
+	Skipped     int               `json:"skipped"`     // Items reused from the existing feed
+	InProgress  int               `json:"in_progress"` // Items being downloaded, processed or uploaded
+	// EstimatedCompletion is when the job should finish, from the jobs ahead of
+	// it and how fast recent runs went; absent when there's no history to go on
+	EstimatedCompletion time.Time `json:"estimated_completion,omitzero"`
+}
+
+// JobItemResponse is an episode of a job as the API returns it
+type JobItemResponse struct {
+	ID          string        `json:"id"`
+	Title       string        `json:"title"`
+	Status      string        `json:"status"` // pending, downloading, processing, uploading, completed, skipped, failed
+	SourceURL   string        `json:"source_url"`
+	Error       string        `json:"error,omitempty"`
+	Duration    time.Duration `json:"duration" swaggertype:"integer"`         // Nanoseconds
+	Offset      time.Duration `json:"offset,omitempty" swaggertype:"integer"` // Nanoseconds
+	Progress    int           `json:"progress,omitempty"`                     // Upload progress percentage while uploading
+	DriveFileID string        `json:"drive_file_id,omitempty"`                // Storage key of the uploaded episode
+	Speed       float64       `json:"speed,omitempty"`                        // Playback speed the episode was encoded at
+	ContentType string        `json:"content_type,omitempty"`
+	GUID        string        `json:"guid,omitempty"`     // Episode GUID in its show feed, when known
+	FeedURL     string        `json:"feed_url,omitempty"` // RSS feed of the show the episode came from, when known
+	Position    int           `json:"position,omitempty"` // 1-based place in the source playlist, when known
+	Cuts        []CutResponse `json:"cuts,omitempty"`     // Parts of the source the user's skip rules left out
+}
+
+// CutResponse is a part of an episode's source left out while processing it
+type CutResponse struct {
+	Start  time.Duration `json:"start" swaggertype:"integer"` // Nanoseconds into the source
+	End    time.Duration `json:"end" swaggertype:"integer"`   // Nanoseconds into the source
+	Reason string        `json:"reason"`                      // "start", "end" or the title of the chapter
+}
+
+// newJobResponse copies a job into the shape the API returns
+func newJobResponse(job *queue.Job) JobResponse {
+	items := make([]JobItemResponse, len(job.Items))
+	for i, item := range job.Items {
+		var cuts []CutResponse
+		for _, cut := range item.Cuts {
+			cuts = append(cuts, CutResponse{Start: cut.Start, End: cut.End, Reason: cut.Reason})
+		}
+		items[i] = JobItemResponse{
+			ID:          item.ID,
+			Title:       item.Title,
+			Status:      string(item.Status),
+			SourceURL:   item.SourceURL,
+			Error:       item.Error,
+			Duration:    item.Duration,
+			Offset:      item.Offset,
+			Progress:    item.Progress,
+			DriveFileID: item.DriveFileID,
+			Speed:       item.Speed,
+			ContentType: item.ContentType,
+			GUID:        item.GUID,
+			FeedURL:     item.FeedURL,
+			Position:    item.Position,
+			Cuts:        cuts,
+		}
+	}
+	return JobResponse{
+		ID:          job.ID,
+		FileID:      job.FileID,
+		UserID:      job.UserID,
+		Filename:    job.Filename,
+		Label:       job.Label,
+		CreatedAt:   job.CreatedAt,
+		FailReason:  job.FailReason,
+		FailedItems: job.FailedItems,
+		Attempts:    job.Attempts,
+		RetryOf:     job.RetryOf,
+		Status:      job.Status,
+		RunAt:       job.RunAt,
+		Priority:    job.Priority,
+		FeedURL:     job.FeedURL,
+		Items:       items,
+		TotalItems:  job.TotalItems,
+		Completed:   job.Completed,
+		Failed:      job.Failed,
+		Skipped:     job.Skipped,
+		InProgress:  job.InProgress,
+		// Kept after the job finishes, for comparing against when it did
+		EstimatedCompletion: job.EstimatedCompletion,
+	}
+}
+
+// newJobResponses copies a list of jobs into the shape the API returns
+func newJobResponses(jobs []*queue.Job) []JobResponse {
+	responses := make([]JobResponse, len(jobs))
+	for i, job := range jobs {
+		responses[i] = newJobResponse(job)
+	}
+	return responses
+}
@@ -0,0 +1,68 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"cobblepod/internal/queue"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNewJobResponseKeepsJSONShape checks the response carries every field the
+// API returned when it served queue.Job directly
+func TestNewJobResponseKeepsJSONShape(t *testing.T) {
+	job := &queue.Job{
+		ID:          "job-1",
+		FileID:      "file-1",
+		UserID:      "user-1",
+		Filename:    "podcast.backup",
+		Label:       "weekly",
+		CreatedAt:   time.Date(2025, 6, 3, 9, 0, 0, 0, time.UTC),
+		FailReason:  "boom",
+		FailedItems: 1,
+		Attempts:    2,
+		RetryOf:     "job-0",
+		Status:      queue.JobStatusCompletedWithErrors,
+		RunAt:       time.Date(2025, 6, 3, 10, 0, 0, 0, time.UTC),
+		Priority:    queue.PriorityInteractive,
+		TraceParent: "00-trace",
+		FeedURL:     "https://example.com/feed.xml",
+		FairScore:   42,
+		Items: []queue.JobItem{{
+			ID:          "item-1",
+			Title:       "Episode 1",
+			Status:      queue.StatusFailed,
+			SourceURL:   "https://example.com/1.mp3",
+			Error:       "download failed",
+			Duration:    time.Minute,
+			Offset:      time.Second,
+			Progress:    50,
+			DriveFileID: "drive-1",
+			Speed:       1.5,
+			ContentType: "audio/mpeg",
+			GUID:        "guid-1",
+			FeedURL:     "https://example.com/show.xml",
+			Position:    3,
+			Cuts:        []queue.Cut{{Start: 0, End: 90 * time.Second, Reason: "start"}},
+		}},
+		TotalItems: 1,
+		Completed:  0,
+		Failed:     1,
+		Skipped:    0,
+		InProgress: 0,
+	}
+
+	want, err := json.Marshal(job)
+	assert.NoError(t, err)
+	got, err := json.Marshal(newJobResponse(job))
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(want), string(got))
+}
+
+func TestNewJobResponsesWithoutJobs(t *testing.T) {
+	got, err := json.Marshal(GetRunningJobsResponse{Jobs: newJobResponses(nil)})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"jobs":[]}`, string(got))
+}
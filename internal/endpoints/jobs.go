@@ -2,7 +2,12 @@ package endpoints
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"cobblepod/internal/queue"
 
@@ -11,31 +16,48 @@ import (
 
 // JobQueue defines the interface for job queue operations
 type JobQueue interface {
-	GetWaitingJobs(ctx context.Context, userID string) ([]*queue.Job, error)
-	GetRunningJobs(ctx context.Context, userID string) ([]*queue.Job, error)
-	GetFailedJobs(ctx context.Context, userID string) ([]*queue.Job, error)
-	GetCompletedJobs(ctx context.Context, userID string) ([]*queue.Job, error)
+	ListUserJobs(ctx context.Context, userID string, opts queue.JobListOptions) ([]*queue.Job, int, error)
 }
 
 // GetJobsResponse represents the response for the jobs endpoint
 type GetJobsResponse struct {
-	Jobs []*queue.Job `json:"jobs"`
+	Jobs   []JobResponse `json:"jobs"`
+	Total  int           `json:"total"` // Jobs matching the filters across all pages
+	Limit  int           `json:"limit"`
+	Offset int           `json:"offset"`
+}
+
+// jobStates maps the status query parameter to the job state it lists. No
+// status lists active jobs, as it always has.
+var jobStates = map[string]string{
+	"":          queue.JobStateActive,
+	"active":    queue.JobStateActive,
+	"all":       queue.JobStateAll,
+	"completed": queue.JobStateCompleted,
+	"failed":    queue.JobStateFailed,
+
+	"completed_with_errors": queue.JobStatePartial,
 }
 
 // HandleGetJobs returns a handler that retrieves jobs based on status
 // @Summary      Get jobs
-// @Description  Get a list of jobs for the authenticated user, optionally filtered by status
+// @Description  Get a page of jobs for the authenticated user, newest first, optionally filtered by status, label and creation date
 // @Tags         jobs
 // @Produce      json
-// @Param        status query string false "Job status filter"
+// @Param        status query string false "Job status filter: active (default), all, completed, completed_with_errors or failed"
+// @Param        label query string false "Only jobs whose label contains this text (case-insensitive)"
+// @Param        since query string false "Only jobs created at or after this RFC 3339 time"
+// @Param        until query string false "Only jobs created at or before this RFC 3339 time"
+// @Param        order query string false "Sort by creation time: desc (default) or asc"
+// @Param        limit query int false "Page size (default 50, max 200)"
+// @Param        offset query int false "Jobs to skip"
 // @Success      200  {object}  GetJobsResponse
+// @Failure      400  {object}  map[string]string
 // @Failure      401  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /jobs [get]
 func HandleGetJobs(jobQueue JobQueue) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		status := c.Query("status")
-		var jobs []*queue.Job
 		ctx := c.Request.Context()
 
 		userID, err := GetUserID(c)
@@ -44,52 +66,121 @@ func HandleGetJobs(jobQueue JobQueue) gin.HandlerFunc {
 			return
 		}
 
-		if status == "" {
-			waiting, err := jobQueue.GetWaitingJobs(ctx, userID)
-			if err != nil {
-				if err == queue.ErrUserIDRequired {
-					c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-					return
-				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch waiting jobs"})
-				return
-			}
-			jobs = append(jobs, waiting...)
+		opts, err := parseJobListOptions(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-			running, err := jobQueue.GetRunningJobs(ctx, userID)
-			if err != nil {
-				if err == queue.ErrUserIDRequired {
-					c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-					return
-				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch running jobs"})
-				return
-			}
-			jobs = append(jobs, running...)
-		} else if status == "failed" {
-			failed, err := jobQueue.GetFailedJobs(ctx, userID)
-			if err != nil {
-				if err == queue.ErrUserIDRequired {
-					c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-					return
-				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch failed jobs"})
+		jobs, total, err := jobQueue.ListUserJobs(ctx, userID, opts)
+		if err != nil {
+			if err == queue.ErrUserIDRequired {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 				return
 			}
-			jobs = append(jobs, failed...)
-		} else if status == "completed" {
-			completed, err := jobQueue.GetCompletedJobs(ctx, userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch jobs"})
+			return
+		}
+
+		c.JSON(http.StatusOK, GetJobsResponse{Jobs: newJobResponses(jobs), Total: total, Limit: opts.Limit, Offset: opts.Offset})
+	}
+}
+
+// HandleSearchJobs returns a handler that searches the user's job history
+// @Summary      Search jobs
+// @Description  Find the authenticated user's jobs, newest first, whose filename or episode titles contain every word of q, such as the job that processed an episode. A word may also match the start of a job's status, e.g. "failed". Searches all jobs unless status is given; the other filters and paging work as for GET /jobs
+// @Tags         jobs
+// @Produce      json
+// @Param        q query string true "Words to search for (case-insensitive)"
+// @Param        status query string false "Job status filter: all (default), active, completed, completed_with_errors or failed"
+// @Param        label query string false "Only jobs whose label contains this text (case-insensitive)"
+// @Param        since query string false "Only jobs created at or after this RFC 3339 time"
+// @Param        until query string false "Only jobs created at or before this RFC 3339 time"
+// @Param        order query string false "Sort by creation time: desc (default) or asc"
+// @Param        limit query int false "Page size (default 50, max 200)"
+// @Param        offset query int false "Jobs to skip"
+// @Success      200  {object}  GetJobsResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /jobs/search [get]
+func HandleSearchJobs(jobQueue JobQueue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		query := strings.TrimSpace(c.Query("q"))
+		if query == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+			return
+		}
+		opts, err := parseJobListOptions(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		opts.Query = query
+		if c.Query("status") == "" {
+			opts.State = queue.JobStateAll
+		}
+
+		jobs, total, err := jobQueue.ListUserJobs(ctx, userID, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search jobs"})
+			return
+		}
+
+		c.JSON(http.StatusOK, GetJobsResponse{Jobs: newJobResponses(jobs), Total: total, Limit: opts.Limit, Offset: opts.Offset})
+	}
+}
+
+// parseJobListOptions reads the filter, sort and paging query parameters
+func parseJobListOptions(c *gin.Context) (queue.JobListOptions, error) {
+	opts := queue.JobListOptions{Label: c.Query("label"), Limit: queue.DefaultJobPageSize}
+
+	state, ok := jobStates[c.Query("status")]
+	if !ok {
+		return opts, errors.New("status must be active, all, completed, completed_with_errors or failed")
+	}
+	opts.State = state
+
+	for name, target := range map[string]*time.Time{"since": &opts.Since, "until": &opts.Until} {
+		if value := c.Query(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
 			if err != nil {
-				if err == queue.ErrUserIDRequired {
-					c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-					return
-				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch completed jobs"})
-				return
+				return opts, fmt.Errorf("%s must be an RFC 3339 time", name)
 			}
-			jobs = append(jobs, completed...)
+			*target = t
 		}
+	}
+
+	switch c.Query("order") {
+	case "", "desc":
+	case "asc":
+		opts.Ascending = true
+	default:
+		return opts, errors.New("order must be asc or desc")
+	}
 
-		c.JSON(http.StatusOK, GetJobsResponse{Jobs: jobs})
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return opts, errors.New("limit must be a positive number")
+		}
+		opts.Limit = min(limit, queue.MaxJobPageSize)
 	}
+	if value := c.Query("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return opts, errors.New("offset must not be negative")
+		}
+		opts.Offset = offset
+	}
+
+	return opts, nil
 }
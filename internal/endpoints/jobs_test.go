@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"cobblepod/internal/queue"
 
@@ -20,29 +21,25 @@ type MockJobQueue struct {
 	mock.Mock
 }
 
-func (m *MockJobQueue) GetWaitingJobs(ctx context.Context, userID string) ([]*queue.Job, error) {
-	args := m.Called(ctx, userID)
-	return args.Get(0).([]*queue.Job), args.Error(1)
-}
-
-func (m *MockJobQueue) GetRunningJobs(ctx context.Context, userID string) ([]*queue.Job, error) {
-	args := m.Called(ctx, userID)
-	return args.Get(0).([]*queue.Job), args.Error(1)
-}
-
-func (m *MockJobQueue) GetFailedJobs(ctx context.Context, userID string) ([]*queue.Job, error) {
-	args := m.Called(ctx, userID)
-	return args.Get(0).([]*queue.Job), args.Error(1)
-}
-
-func (m *MockJobQueue) GetCompletedJobs(ctx context.Context, userID string) ([]*queue.Job, error) {
-	args := m.Called(ctx, userID)
-	return args.Get(0).([]*queue.Job), args.Error(1)
+func (m *MockJobQueue) ListUserJobs(ctx context.Context, userID string, opts queue.JobListOptions) ([]*queue.Job, int, error) {
+	args := m.Called(ctx, userID, opts)
+	return args.Get(0).([]*queue.Job), args.Int(1), args.Error(2)
 }
 
 func TestHandleGetJobs(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
+	newRouter := func(mockQueue *MockJobQueue) *gin.Engine {
+		router := gin.New()
+		// Mock middleware to set user_id
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", "test-user")
+			c.Next()
+		})
+		router.GET("/jobs", HandleGetJobs(mockQueue))
+		return router
+	}
+
 	t.Run("Unauthorized", func(t *testing.T) {
 		mockQueue := new(MockJobQueue)
 		router := gin.New()
@@ -55,25 +52,17 @@ func TestHandleGetJobs(t *testing.T) {
 		assert.Equal(t, http.StatusUnauthorized, w.Code)
 	})
 
-	t.Run("Success - All Jobs", func(t *testing.T) {
+	t.Run("Success - Active Jobs By Default", func(t *testing.T) {
 		mockQueue := new(MockJobQueue)
-		router := gin.New()
-		// Mock middleware to set user_id
-		router.Use(func(c *gin.Context) {
-			c.Set("user_id", "test-user")
-			c.Next()
-		})
-		router.GET("/jobs", HandleGetJobs(mockQueue))
-
-		waitingJobs := []*queue.Job{{ID: "1", Status: "waiting"}}
-		runningJobs := []*queue.Job{{ID: "2", Status: "running"}}
-
-		mockQueue.On("GetWaitingJobs", mock.Anything, "test-user").Return(waitingJobs, nil)
-		mockQueue.On("GetRunningJobs", mock.Anything, "test-user").Return(runningJobs, nil)
+		jobs := []*queue.Job{{ID: "2", Status: "running"}, {ID: "1", Status: "queued"}}
+		mockQueue.On("ListUserJobs", mock.Anything, "test-user", queue.JobListOptions{
+			State: queue.JobStateActive,
+			Limit: queue.DefaultJobPageSize,
+		}).Return(jobs, 2, nil)
 
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("GET", "/jobs", nil)
-		router.ServeHTTP(w, req)
+		newRouter(mockQueue).ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
 
@@ -81,25 +70,28 @@ func TestHandleGetJobs(t *testing.T) {
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
 		assert.Len(t, response.Jobs, 2)
+		assert.Equal(t, 2, response.Total)
+		assert.Equal(t, queue.DefaultJobPageSize, response.Limit)
 		mockQueue.AssertExpectations(t)
 	})
 
-	t.Run("Success - Failed Jobs", func(t *testing.T) {
+	t.Run("Success - Filters And Paging", func(t *testing.T) {
 		mockQueue := new(MockJobQueue)
-		router := gin.New()
-		router.Use(func(c *gin.Context) {
-			c.Set("user_id", "test-user")
-			c.Next()
-		})
-		router.GET("/jobs", HandleGetJobs(mockQueue))
-
-		failedJobs := []*queue.Job{{ID: "3", Status: "failed"}}
-
-		mockQueue.On("GetFailedJobs", mock.Anything, "test-user").Return(failedJobs, nil)
+		since := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+		until := time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)
+		mockQueue.On("ListUserJobs", mock.Anything, "test-user", queue.JobListOptions{
+			State:     queue.JobStateCompleted,
+			Label:     "marathon",
+			Since:     since,
+			Until:     until,
+			Ascending: true,
+			Limit:     10,
+			Offset:    20,
+		}).Return([]*queue.Job{{ID: "5", Status: "completed", Label: "Pre-marathon playlist"}}, 21, nil)
 
 		w := httptest.NewRecorder()
-		req, _ := http.NewRequest("GET", "/jobs?status=failed", nil)
-		router.ServeHTTP(w, req)
+		req, _ := http.NewRequest("GET", "/jobs?status=completed&label=marathon&since=2025-06-01T00:00:00Z&until=2025-06-30T00:00:00Z&order=asc&limit=10&offset=20", nil)
+		newRouter(mockQueue).ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
 
@@ -107,53 +99,133 @@ func TestHandleGetJobs(t *testing.T) {
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
 		assert.Len(t, response.Jobs, 1)
-		assert.Equal(t, "failed", response.Jobs[0].Status)
+		assert.Equal(t, 21, response.Total)
+		assert.Equal(t, 10, response.Limit)
+		assert.Equal(t, 20, response.Offset)
 		mockQueue.AssertExpectations(t)
 	})
 
-	t.Run("Success - Completed Jobs", func(t *testing.T) {
+	t.Run("Limit Is Capped", func(t *testing.T) {
 		mockQueue := new(MockJobQueue)
-		router := gin.New()
-		router.Use(func(c *gin.Context) {
-			c.Set("user_id", "test-user")
-			c.Next()
-		})
-		router.GET("/jobs", HandleGetJobs(mockQueue))
+		mockQueue.On("ListUserJobs", mock.Anything, "test-user", queue.JobListOptions{
+			State: queue.JobStateFailed,
+			Limit: queue.MaxJobPageSize,
+		}).Return([]*queue.Job{}, 0, nil)
 
-		completedJobs := []*queue.Job{{ID: "4", Status: "completed"}}
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/jobs?status=failed&limit=100000", nil)
+		newRouter(mockQueue).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockQueue.AssertExpectations(t)
+	})
 
-		mockQueue.On("GetCompletedJobs", mock.Anything, "test-user").Return(completedJobs, nil)
+	t.Run("Completed With Errors", func(t *testing.T) {
+		mockQueue := new(MockJobQueue)
+		mockQueue.On("ListUserJobs", mock.Anything, "test-user", queue.JobListOptions{
+			State: queue.JobStatePartial,
+			Limit: queue.DefaultJobPageSize,
+		}).Return([]*queue.Job{}, 0, nil)
 
 		w := httptest.NewRecorder()
-		req, _ := http.NewRequest("GET", "/jobs?status=completed", nil)
-		router.ServeHTTP(w, req)
+		req, _ := http.NewRequest("GET", "/jobs?status=completed_with_errors", nil)
+		newRouter(mockQueue).ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
-
-		var response GetJobsResponse
-		err := json.Unmarshal(w.Body.Bytes(), &response)
-		assert.NoError(t, err)
-		assert.Len(t, response.Jobs, 1)
-		assert.Equal(t, "completed", response.Jobs[0].Status)
 		mockQueue.AssertExpectations(t)
 	})
 
-	t.Run("Error - GetWaitingJobs", func(t *testing.T) {
+	t.Run("Invalid Parameters", func(t *testing.T) {
+		for _, query := range []string{
+			"status=bogus",
+			"since=yesterday",
+			"order=sideways",
+			"limit=0",
+			"offset=-1",
+		} {
+			mockQueue := new(MockJobQueue)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/jobs?"+query, nil)
+			newRouter(mockQueue).ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			mockQueue.AssertNotCalled(t, "ListUserJobs", mock.Anything, mock.Anything, mock.Anything)
+		}
+	})
+
+	t.Run("Error - ListUserJobs", func(t *testing.T) {
 		mockQueue := new(MockJobQueue)
+		mockQueue.On("ListUserJobs", mock.Anything, "test-user", mock.Anything).Return([]*queue.Job{}, 0, errors.New("db error"))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/jobs", nil)
+		newRouter(mockQueue).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		mockQueue.AssertExpectations(t)
+	})
+}
+
+func TestHandleSearchJobs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(mockQueue *MockJobQueue) *gin.Engine {
 		router := gin.New()
 		router.Use(func(c *gin.Context) {
 			c.Set("user_id", "test-user")
 			c.Next()
 		})
-		router.GET("/jobs", HandleGetJobs(mockQueue))
+		router.GET("/jobs/search", HandleSearchJobs(mockQueue))
+		return router
+	}
 
-		mockQueue.On("GetWaitingJobs", mock.Anything, "test-user").Return([]*queue.Job{}, errors.New("db error"))
+	t.Run("Searches All Jobs By Default", func(t *testing.T) {
+		mockQueue := new(MockJobQueue)
+		mockQueue.On("ListUserJobs", mock.Anything, "test-user", queue.JobListOptions{
+			State: queue.JobStateAll,
+			Query: "hard fork",
+			Limit: queue.DefaultJobPageSize,
+		}).Return([]*queue.Job{{ID: "3", Status: "completed"}}, 1, nil)
 
 		w := httptest.NewRecorder()
-		req, _ := http.NewRequest("GET", "/jobs", nil)
-		router.ServeHTTP(w, req)
+		req, _ := http.NewRequest("GET", "/jobs/search?q=+hard+fork+", nil)
+		newRouter(mockQueue).ServeHTTP(w, req)
 
-		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response GetJobsResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response.Jobs, 1)
+		assert.Equal(t, 1, response.Total)
 		mockQueue.AssertExpectations(t)
 	})
+
+	t.Run("Status Filter", func(t *testing.T) {
+		mockQueue := new(MockJobQueue)
+		mockQueue.On("ListUserJobs", mock.Anything, "test-user", queue.JobListOptions{
+			State: queue.JobStateFailed,
+			Query: "daily",
+			Limit: queue.DefaultJobPageSize,
+		}).Return([]*queue.Job{}, 0, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/jobs/search?q=daily&status=failed", nil)
+		newRouter(mockQueue).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockQueue.AssertExpectations(t)
+	})
+
+	t.Run("Query Required", func(t *testing.T) {
+		for _, query := range []string{"", "q=+", "q=daily&status=bogus"} {
+			mockQueue := new(MockJobQueue)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/jobs/search?"+query, nil)
+			newRouter(mockQueue).ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			mockQueue.AssertNotCalled(t, "ListUserJobs", mock.Anything, mock.Anything, mock.Anything)
+		}
+	})
 }
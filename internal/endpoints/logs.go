@@ -0,0 +1,68 @@
+package endpoints
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"cobblepod/internal/logging"
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobLogsQueue defines the queue operations needed to show a job's log
+type JobLogsQueue interface {
+	GetJob(ctx context.Context, jobID string) (*queue.Job, error)
+	JobLogs(ctx context.Context, jobID string) ([]logging.Line, error)
+}
+
+// JobLogsResponse represents the log lines kept for a job
+type JobLogsResponse struct {
+	JobID string         `json:"job_id"`
+	Lines []logging.Line `json:"lines"`
+}
+
+// HandleGetJobLogs returns a handler that shows the log lines a job wrote
+// @Summary      Job log
+// @Description  Returns the info, warning and error lines the worker logged while running the job, oldest first, up to the most recent 1000. The log is kept as long as the job
+// @Tags         jobs
+// @Produce      json
+// @Param        id path string true "Job ID"
+// @Success      200  {object}  JobLogsResponse
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /jobs/{id}/logs [get]
+func HandleGetJobLogs(jobQueue JobLogsQueue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		jobID := c.Param("id")
+		job, err := jobQueue.GetJob(ctx, jobID)
+		if err != nil {
+			slog.Error("Failed to fetch job", "error", err, "job_id", jobID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch job"})
+			return
+		}
+		// Other users' jobs are reported as missing rather than forbidden
+		if job == nil || job.UserID != userID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+
+		lines, err := jobQueue.JobLogs(ctx, jobID)
+		if err != nil {
+			slog.Error("Failed to fetch job logs", "error", err, "job_id", jobID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch job logs"})
+			return
+		}
+		c.JSON(http.StatusOK, JobLogsResponse{JobID: jobID, Lines: lines})
+	}
+}
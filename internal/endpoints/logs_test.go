@@ -0,0 +1,101 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cobblepod/internal/logging"
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockJobLogsQueue is a mock implementation of JobLogsQueue
+type MockJobLogsQueue struct {
+	mock.Mock
+}
+
+func (m *MockJobLogsQueue) GetJob(ctx context.Context, jobID string) (*queue.Job, error) {
+	args := m.Called(ctx, jobID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*queue.Job), args.Error(1)
+}
+
+func (m *MockJobLogsQueue) JobLogs(ctx context.Context, jobID string) ([]logging.Line, error) {
+	args := m.Called(ctx, jobID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]logging.Line), args.Error(1)
+}
+
+func TestHandleGetJobLogs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	job := &queue.Job{ID: "job-1", UserID: "test-user"}
+
+	newRouter := func(mockQueue *MockJobLogsQueue) *gin.Engine {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", "test-user")
+			c.Next()
+		})
+		router.GET("/jobs/:id/logs", HandleGetJobLogs(mockQueue))
+		return router
+	}
+
+	t.Run("Lines", func(t *testing.T) {
+		mockQueue := new(MockJobLogsQueue)
+		mockQueue.On("GetJob", mock.Anything, "job-1").Return(job, nil)
+		mockQueue.On("JobLogs", mock.Anything, "job-1").Return([]logging.Line{
+			{Time: time.Now(), Level: "INFO", Message: "Processing job"},
+			{Time: time.Now(), Level: "ERROR", Message: "Failed to process item", Attrs: map[string]string{"item_id": "item-1"}},
+		}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/jobs/job-1/logs", nil)
+		newRouter(mockQueue).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response JobLogsResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "job-1", response.JobID)
+		if assert.Len(t, response.Lines, 2) {
+			assert.Equal(t, "item-1", response.Lines[1].Attrs["item_id"])
+		}
+		mockQueue.AssertExpectations(t)
+	})
+
+	t.Run("Other user's job", func(t *testing.T) {
+		mockQueue := new(MockJobLogsQueue)
+		mockQueue.On("GetJob", mock.Anything, "job-2").Return(&queue.Job{ID: "job-2", UserID: "someone-else"}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/jobs/job-2/logs", nil)
+		newRouter(mockQueue).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		mockQueue.AssertNotCalled(t, "JobLogs", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Logs error", func(t *testing.T) {
+		mockQueue := new(MockJobLogsQueue)
+		mockQueue.On("GetJob", mock.Anything, "job-1").Return(job, nil)
+		mockQueue.On("JobLogs", mock.Anything, "job-1").Return(nil, errors.New("redis down"))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/jobs/job-1/logs", nil)
+		newRouter(mockQueue).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
@@ -2,42 +2,116 @@ package endpoints
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
-	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"cobblepod/internal/auth"
+	"cobblepod/internal/config"
+	"cobblepod/internal/metrics"
+	"cobblepod/internal/tracing"
 
 	"github.com/auth0/go-jwt-middleware/v2/jwks"
 	"github.com/auth0/go-jwt-middleware/v2/validator"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// Auth0Middleware validates Auth0 JWT tokens using the official Auth0 middleware
-func Auth0Middleware() gin.HandlerFunc {
-	config := auth.GetAuth0Config()
+// Identity is who a bearer token was issued to
+type Identity struct {
+	UserID string
+	Roles  []string // From the AuthConfig.AdminRoleClaim claim, if the token has one
+}
+
+// TokenValidator checks a bearer token and returns the identity it was issued to
+type TokenValidator func(ctx context.Context, token string) (Identity, error)
+
+// roleClaims reads the roles claim named by claim, which may hold a single
+// role or a list of them
+type roleClaims struct {
+	claim string
+	Roles []string
+}
+
+func (r *roleClaims) UnmarshalJSON(data []byte) error {
+	var claims map[string]json.RawMessage
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return err
+	}
+	raw, ok := claims[r.claim]
+	if r.claim == "" || !ok {
+		return nil
+	}
+	if err := json.Unmarshal(raw, &r.Roles); err == nil {
+		return nil
+	}
+	var role string
+	if err := json.Unmarshal(raw, &role); err != nil {
+		return fmt.Errorf("invalid %s claim: %w", r.claim, err)
+	}
+	r.Roles = []string{role}
+	return nil
+}
+
+// Validate satisfies validator.CustomClaims; roles need no checks of their own
+func (r *roleClaims) Validate(context.Context) error {
+	return nil
+}
 
+// NewTokenValidator validates bearer tokens as JWTs signed by the provider's
+// issuer, reading the user's roles from roleClaim
+func NewTokenValidator(provider auth.Provider, roleClaim string) TokenValidator {
 	// Create JWKS provider with caching
-	issuerURL, _ := url.Parse(fmt.Sprintf("https://%s/", config.Domain))
-	provider := jwks.NewCachingProvider(issuerURL, 24*time.Hour)
+	issuerURL := provider.Issuer()
+	keys := jwks.NewCachingProvider(issuerURL, 24*time.Hour)
 
 	// Create JWT validator
 	jwtValidator, err := validator.New(
-		provider.KeyFunc,
+		keys.KeyFunc,
 		validator.RS256,
 		issuerURL.String(),
-		[]string{config.Audience},
+		[]string{provider.Audience()},
+		validator.WithCustomClaims(func() validator.CustomClaims { return &roleClaims{claim: roleClaim} }),
 	)
 	if err != nil {
 		// This should only happen during initialization with invalid config
 		panic(fmt.Sprintf("Failed to create JWT validator: %v", err))
 	}
 
+	return func(ctx context.Context, tokenString string) (Identity, error) {
+		token, err := jwtValidator.ValidateToken(ctx, tokenString)
+		if err != nil {
+			return Identity{}, err
+		}
+
+		claims, ok := token.(*validator.ValidatedClaims)
+		if !ok {
+			return Identity{}, fmt.Errorf("invalid token claims")
+		}
+		identity := Identity{UserID: provider.UserID(claims.RegisteredClaims.Subject)}
+		if roles, ok := claims.CustomClaims.(*roleClaims); ok {
+			identity.Roles = roles.Roles
+		}
+		return identity, nil
+	}
+}
+
+// AuthMiddleware authenticates requests with a bearer token checked by validate
+func AuthMiddleware(validate TokenValidator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
+		// Browsers can't set headers on WebSocket handshakes
+		if token := c.Query("access_token"); authHeader == "" && token != "" && isWebSocketUpgrade(c.Request) {
+			authHeader = "Bearer " + token
+		}
 		if authHeader == "" {
 			slog.Warn("Missing authorization header",
 				"path", c.Request.URL.Path,
@@ -56,30 +130,54 @@ func Auth0Middleware() gin.HandlerFunc {
 		}
 
 		// Validate the token
-		token, err := jwtValidator.ValidateToken(context.Background(), tokenString)
+		identity, err := validate(c.Request.Context(), tokenString)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("Invalid token: %v", err)})
 			c.Abort()
 			return
 		}
 
-		// Extract claims
-		claims, ok := token.(*validator.ValidatedClaims)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		// Store user ID and roles in context
+		c.Set("user_id", identity.UserID)
+		c.Set("roles", identity.Roles)
+
+		c.Next()
+	}
+}
+
+// AdminMiddleware restricts a route to users whose token carries cfg.AdminRole
+// or who are listed in cfg.AdminUserIDs (use after AuthMiddleware)
+func AdminMiddleware(cfg config.AuthConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			c.Abort()
 			return
 		}
 
-		// Store user ID and claims in context
-		c.Set("user_id", claims.RegisteredClaims.Subject)
-		c.Set("claims", claims)
+		if isAdmin(c, cfg, userID) {
+			c.Next()
+			return
+		}
 
-		c.Next()
+		slog.Warn("Non-admin user attempted admin action", "user_id", userID, "path", c.Request.URL.Path)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		c.Abort()
 	}
 }
 
-// GetUserID is a helper to get user ID from context (use after Auth0Middleware)
+// isAdmin reports whether the request's token carries cfg.AdminRole or the
+// user is listed in cfg.AdminUserIDs
+func isAdmin(c *gin.Context, cfg config.AuthConfig, userID string) bool {
+	roles, _ := c.Get("roles")
+	if list, ok := roles.([]string); ok && cfg.AdminRole != "" && slices.Contains(list, cfg.AdminRole) {
+		return true
+	}
+	return slices.Contains(cfg.AdminUserIDs, userID)
+}
+
+// GetUserID is a helper to get user ID from context (use after AuthMiddleware)
 func GetUserID(c *gin.Context) (string, error) {
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -93,3 +191,84 @@ func GetUserID(c *gin.Context) (string, error) {
 
 	return userIDStr, nil
 }
+
+// TracingMiddleware starts a span for each request, continuing any trace the
+// caller started, so jobs created by the request join its trace
+func TracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx, span := tracing.Start(tracing.FromRequest(c.Request), c.Request.Method+" "+route,
+			attribute.String("http.request.method", c.Request.Method),
+			attribute.String("http.route", route),
+		)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		var err error
+		if status >= http.StatusInternalServerError {
+			err = fmt.Errorf("HTTP %d", status)
+		}
+		tracing.End(span, err)
+	}
+}
+
+// requestLatency is how long the server takes to answer each route
+var requestLatency = promauto.With(metrics.Registry).NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "cobblepod_http_request_duration_seconds",
+	Help:    "Time taken to answer HTTP requests.",
+	Buckets: metrics.LatencyBuckets,
+}, []string{"method", "route", "status"})
+
+// RequestLogger logs each request once it's answered and records its latency.
+// Lines carry the route rather than the path, which may hold feed slugs and
+// other secrets. Successful requests to cfg.LogSampledRoutes are only logged
+// at cfg.LogSampleRate, noting the rate so counts can be scaled back up;
+// their latencies are always recorded.
+func RequestLogger(cfg config.ServerConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := c.Writer.Status()
+		requestLatency.WithLabelValues(c.Request.Method, route, strconv.Itoa(status)).Observe(latency.Seconds())
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", route),
+			slog.Int("status", status),
+			slog.Duration("latency", latency),
+			slog.Int64("request_bytes", max(c.Request.ContentLength, 0)),
+			slog.Int("response_bytes", max(c.Writer.Size(), 0)),
+		}
+		if status < http.StatusBadRequest && slices.Contains(cfg.LogSampledRoutes, route) {
+			if rand.Float64() >= cfg.LogSampleRate {
+				return
+			}
+			attrs = append(attrs, slog.Float64("sample_rate", cfg.LogSampleRate))
+		}
+		if userID, err := GetUserID(c); err == nil {
+			attrs = append(attrs, slog.String("user_id", userID))
+		}
+		slog.LogAttrs(c.Request.Context(), level, "HTTP request", attrs...)
+	}
+}
+
+// isWebSocketUpgrade reports whether the request is a WebSocket handshake
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
@@ -0,0 +1,157 @@
+package endpoints
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cobblepod/internal/config"
+	"cobblepod/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	validate := func(ctx context.Context, token string) (Identity, error) {
+		if token != "good" {
+			return Identity{}, errors.New("expired")
+		}
+		return Identity{UserID: "user-1"}, nil
+	}
+	router := gin.New()
+	router.GET("/me", AuthMiddleware(validate), func(c *gin.Context) {
+		userID, _ := GetUserID(c)
+		c.String(http.StatusOK, userID)
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{name: "valid token", header: "Bearer good", status: http.StatusOK},
+		{name: "missing header", header: "", status: http.StatusUnauthorized},
+		{name: "not a bearer token", header: "Basic good", status: http.StatusUnauthorized},
+		{name: "invalid token", header: "Bearer bad", status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/me", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusOK {
+				assert.Equal(t, "user-1", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRoleClaims(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		roles   []string
+	}{
+		{name: "list", payload: `{"sub":"user-1","https://cobblepod/roles":["admin","editor"]}`, roles: []string{"admin", "editor"}},
+		{name: "single role", payload: `{"https://cobblepod/roles":"admin"}`, roles: []string{"admin"}},
+		{name: "no claim", payload: `{"sub":"user-1","roles":["admin"]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := roleClaims{claim: "https://cobblepod/roles"}
+			assert.NoError(t, json.Unmarshal([]byte(tt.payload), &claims))
+			assert.Equal(t, tt.roles, claims.Roles)
+		})
+	}
+
+	claims := roleClaims{claim: "https://cobblepod/roles"}
+	assert.Error(t, json.Unmarshal([]byte(`{"https://cobblepod/roles":42}`), &claims))
+}
+
+func TestRequestLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var out bytes.Buffer
+	original := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&out, nil)))
+	defer slog.SetDefault(original)
+
+	newRouter := func(sampleRate float64) *gin.Engine {
+		router := gin.New()
+		router.Use(RequestLogger(config.ServerConfig{LogSampledRoutes: []string{"/feed/:slug"}, LogSampleRate: sampleRate}))
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", "user-1")
+			c.Next()
+		})
+		router.GET("/feed/:slug", func(c *gin.Context) {
+			if c.Param("slug") == "missing" {
+				c.Status(http.StatusNotFound)
+				return
+			}
+			c.String(http.StatusOK, "<rss/>")
+		})
+		router.POST("/jobs", func(c *gin.Context) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create job"})
+		})
+		return router
+	}
+	router := newRouter(0)
+
+	request := func(method, path string) map[string]any {
+		out.Reset()
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, path, strings.NewReader("{}")))
+		if out.Len() == 0 {
+			return nil
+		}
+		var line map[string]any
+		if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+			t.Fatalf("Failed to parse %q: %v", out.String(), err)
+		}
+		return line
+	}
+
+	// The slug is a secret, so only the route is logged
+	assert.Nil(t, request("GET", "/feed/secret-slug"), "sampled out")
+	line := request("GET", "/feed/missing")
+	if assert.NotNil(t, line, "failed requests are always logged") {
+		assert.Equal(t, "/feed/:slug", line["route"])
+		assert.EqualValues(t, 404, line["status"])
+		assert.NotContains(t, out.String(), "secret-slug")
+	}
+
+	line = request("POST", "/jobs")
+	if assert.NotNil(t, line) {
+		assert.Equal(t, "ERROR", line["level"])
+		assert.Equal(t, "POST", line["method"])
+		assert.Equal(t, "user-1", line["user_id"])
+		assert.EqualValues(t, 2, line["request_bytes"])
+		assert.Contains(t, line, "latency")
+		assert.NotContains(t, line, "sample_rate")
+	}
+
+	router = newRouter(1)
+	line = request("GET", "/feed/secret-slug")
+	if assert.NotNil(t, line) {
+		assert.EqualValues(t, 1, line["sample_rate"])
+		assert.EqualValues(t, len("<rss/>"), line["response_bytes"])
+	}
+
+	// Sampled out requests still count towards the latency histogram
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, w.Body.String(), `cobblepod_http_request_duration_seconds_count{method="GET",route="/feed/:slug",status="200"} 2`)
+}
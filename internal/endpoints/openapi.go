@@ -0,0 +1,21 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SpecReader renders the API description generated from the handler comments,
+// see docs.SwaggerInfo and `make swagger`
+type SpecReader interface {
+	ReadDoc() string
+}
+
+// HandleOpenAPISpec returns a handler that serves the API description, so
+// clients can generate typed bindings from the running server
+func HandleOpenAPISpec(spec SpecReader) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(spec.ReadDoc()))
+	}
+}
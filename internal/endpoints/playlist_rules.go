@@ -0,0 +1,122 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PlaylistRulesStore defines the queue operations for a user's playlist rules
+type PlaylistRulesStore interface {
+	GetPlaylistRules(ctx context.Context, userID string) (*queue.PlaylistRules, error)
+	SavePlaylistRules(ctx context.Context, userID string, rules *queue.PlaylistRules) error
+	DeletePlaylistRules(ctx context.Context, userID string) error
+}
+
+// HandleGetPlaylistRules returns a handler that retrieves the user's playlist rules
+// @Summary      Get playlist rules
+// @Description  Get the rules that select the authenticated user's episodes from their backup's subscriptions. 404 means episodes come from the backup's playlist
+// @Tags         settings
+// @Produce      json
+// @Success      200  {object}  queue.PlaylistRules
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /settings/playlist-rules [get]
+func HandleGetPlaylistRules(store PlaylistRulesStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		rules, err := store.GetPlaylistRules(c.Request.Context(), userID)
+		if err != nil {
+			slog.Error("Failed to fetch playlist rules", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlist rules"})
+			return
+		}
+		if rules == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No playlist rules"})
+			return
+		}
+
+		c.JSON(http.StatusOK, rules)
+	}
+}
+
+// HandleUpdatePlaylistRules returns a handler that replaces the user's playlist rules
+// @Summary      Update playlist rules
+// @Description  Select the authenticated user's episodes from the shows subscribed to in their backup instead of its playlist, from the next backup processed. episodes_per_show takes the newest episodes of each show; order is one of newest, oldest, shortest and longest; max_minutes limits the total remaining audio
+// @Tags         settings
+// @Accept       json
+// @Produce      json
+// @Param        rules body queue.PlaylistRules true "Playlist rules"
+// @Success      200  {object}  queue.PlaylistRules
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /settings/playlist-rules [put]
+func HandleUpdatePlaylistRules(store PlaylistRulesStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var rules queue.PlaylistRules
+		if err := c.ShouldBindJSON(&rules); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid playlist rules"})
+			return
+		}
+		if err := rules.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := store.SavePlaylistRules(c.Request.Context(), userID, &rules); err != nil {
+			if errors.Is(err, queue.ErrInvalidSettings) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			slog.Error("Failed to save playlist rules", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save playlist rules"})
+			return
+		}
+
+		c.JSON(http.StatusOK, rules)
+	}
+}
+
+// HandleDeletePlaylistRules returns a handler that removes the user's playlist rules
+// @Summary      Delete playlist rules
+// @Description  Go back to taking the authenticated user's episodes from their backup's playlist
+// @Tags         settings
+// @Success      204
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /settings/playlist-rules [delete]
+func HandleDeletePlaylistRules(store PlaylistRulesStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		if err := store.DeletePlaylistRules(c.Request.Context(), userID); err != nil {
+			slog.Error("Failed to delete playlist rules", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete playlist rules"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
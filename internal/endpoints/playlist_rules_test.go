@@ -0,0 +1,119 @@
+package endpoints
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPlaylistRulesStore is a mock implementation of PlaylistRulesStore
+type MockPlaylistRulesStore struct {
+	mock.Mock
+}
+
+func (m *MockPlaylistRulesStore) GetPlaylistRules(ctx context.Context, userID string) (*queue.PlaylistRules, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*queue.PlaylistRules), args.Error(1)
+}
+
+func (m *MockPlaylistRulesStore) SavePlaylistRules(ctx context.Context, userID string, rules *queue.PlaylistRules) error {
+	args := m.Called(ctx, userID, rules)
+	return args.Error(0)
+}
+
+func (m *MockPlaylistRulesStore) DeletePlaylistRules(ctx context.Context, userID string) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func newPlaylistRulesRouter(store PlaylistRulesStore) *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "test-user")
+		c.Next()
+	})
+	router.GET("/settings/playlist-rules", HandleGetPlaylistRules(store))
+	router.PUT("/settings/playlist-rules", HandleUpdatePlaylistRules(store))
+	router.DELETE("/settings/playlist-rules", HandleDeletePlaylistRules(store))
+	return router
+}
+
+func TestHandleGetPlaylistRules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Rules", func(t *testing.T) {
+		store := new(MockPlaylistRulesStore)
+		store.On("GetPlaylistRules", mock.Anything, "test-user").Return(&queue.PlaylistRules{EpisodesPerShow: 3, Order: queue.OrderShortest}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/settings/playlist-rules", nil)
+		newPlaylistRulesRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"episodes_per_show":3,"unplayed_only":false,"order":"shortest"}`, w.Body.String())
+	})
+
+	t.Run("No rules", func(t *testing.T) {
+		store := new(MockPlaylistRulesStore)
+		store.On("GetPlaylistRules", mock.Anything, "test-user").Return(nil, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/settings/playlist-rules", nil)
+		newPlaylistRulesRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestHandleUpdatePlaylistRules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Saves rules", func(t *testing.T) {
+		store := new(MockPlaylistRulesStore)
+		store.On("SavePlaylistRules", mock.Anything, "test-user", &queue.PlaylistRules{EpisodesPerShow: 3, UnplayedOnly: true, MaxMinutes: 240}).Return(nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/settings/playlist-rules", strings.NewReader(`{"episodes_per_show":3,"unplayed_only":true,"max_minutes":240}`))
+		newPlaylistRulesRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Invalid rules", func(t *testing.T) {
+		store := new(MockPlaylistRulesStore)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/settings/playlist-rules", strings.NewReader(`{"episodes_per_show":3,"order":"random"}`))
+		newPlaylistRulesRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "unknown order")
+		store.AssertNotCalled(t, "SavePlaylistRules", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestHandleDeletePlaylistRules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := new(MockPlaylistRulesStore)
+	store.On("DeletePlaylistRules", mock.Anything, "test-user").Return(nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/settings/playlist-rules", nil)
+	newPlaylistRulesRouter(store).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	store.AssertExpectations(t)
+}
@@ -0,0 +1,122 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PlaylistURLStore defines the queue operations for a user's playlist URL
+type PlaylistURLStore interface {
+	GetPlaylistURL(ctx context.Context, userID string) (*queue.PlaylistURL, error)
+	SavePlaylistURL(ctx context.Context, userID string, rawURL string) error
+	DeletePlaylistURL(ctx context.Context, userID string) error
+}
+
+// HandleGetPlaylistURL returns a handler that retrieves the user's playlist URL
+// @Summary      Get playlist URL
+// @Description  Get the M3U8 playlist URL the authenticated user's episodes are polled from. 404 means no playlist URL is registered
+// @Tags         settings
+// @Produce      json
+// @Success      200  {object}  queue.PlaylistURL
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /settings/playlist-url [get]
+func HandleGetPlaylistURL(store PlaylistURLStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		playlist, err := store.GetPlaylistURL(c.Request.Context(), userID)
+		if err != nil {
+			slog.Error("Failed to fetch playlist URL", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlist URL"})
+			return
+		}
+		if playlist == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No playlist URL"})
+			return
+		}
+
+		c.JSON(http.StatusOK, playlist)
+	}
+}
+
+// HandleUpdatePlaylistURL returns a handler that registers the user's playlist URL
+// @Summary      Update playlist URL
+// @Description  Register an M3U8 playlist published over HTTP on a public host, e.g. exported by another app. The worker polls it with conditional requests and processes it whenever it changes, starting with the next poll
+// @Tags         settings
+// @Accept       json
+// @Produce      json
+// @Param        playlist body queue.PlaylistURL true "Playlist URL"
+// @Success      200  {object}  queue.PlaylistURL
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /settings/playlist-url [put]
+func HandleUpdatePlaylistURL(store PlaylistURLStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var playlist queue.PlaylistURL
+		if err := c.ShouldBindJSON(&playlist); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid playlist URL"})
+			return
+		}
+		if err := playlist.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := store.SavePlaylistURL(c.Request.Context(), userID, playlist.URL); err != nil {
+			if errors.Is(err, queue.ErrInvalidSettings) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			slog.Error("Failed to save playlist URL", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save playlist URL"})
+			return
+		}
+
+		c.JSON(http.StatusOK, playlist)
+	}
+}
+
+// HandleDeletePlaylistURL returns a handler that removes the user's playlist URL
+// @Summary      Delete playlist URL
+// @Description  Stop polling the authenticated user's playlist URL
+// @Tags         settings
+// @Success      204
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /settings/playlist-url [delete]
+func HandleDeletePlaylistURL(store PlaylistURLStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		if err := store.DeletePlaylistURL(c.Request.Context(), userID); err != nil {
+			slog.Error("Failed to delete playlist URL", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete playlist URL"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
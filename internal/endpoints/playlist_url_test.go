@@ -0,0 +1,119 @@
+package endpoints
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPlaylistURLStore is a mock implementation of PlaylistURLStore
+type MockPlaylistURLStore struct {
+	mock.Mock
+}
+
+func (m *MockPlaylistURLStore) GetPlaylistURL(ctx context.Context, userID string) (*queue.PlaylistURL, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*queue.PlaylistURL), args.Error(1)
+}
+
+func (m *MockPlaylistURLStore) SavePlaylistURL(ctx context.Context, userID string, rawURL string) error {
+	args := m.Called(ctx, userID, rawURL)
+	return args.Error(0)
+}
+
+func (m *MockPlaylistURLStore) DeletePlaylistURL(ctx context.Context, userID string) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func newPlaylistURLRouter(store PlaylistURLStore) *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "test-user")
+		c.Next()
+	})
+	router.GET("/settings/playlist-url", HandleGetPlaylistURL(store))
+	router.PUT("/settings/playlist-url", HandleUpdatePlaylistURL(store))
+	router.DELETE("/settings/playlist-url", HandleDeletePlaylistURL(store))
+	return router
+}
+
+func TestHandleGetPlaylistURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Playlist URL", func(t *testing.T) {
+		store := new(MockPlaylistURLStore)
+		store.On("GetPlaylistURL", mock.Anything, "test-user").Return(&queue.PlaylistURL{URL: "https://example.com/commute.m3u8", ETag: `"v1"`}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/settings/playlist-url", nil)
+		newPlaylistURLRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"url":"https://example.com/commute.m3u8"}`, w.Body.String())
+	})
+
+	t.Run("No playlist URL", func(t *testing.T) {
+		store := new(MockPlaylistURLStore)
+		store.On("GetPlaylistURL", mock.Anything, "test-user").Return(nil, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/settings/playlist-url", nil)
+		newPlaylistURLRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestHandleUpdatePlaylistURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Saves playlist URL", func(t *testing.T) {
+		store := new(MockPlaylistURLStore)
+		store.On("SavePlaylistURL", mock.Anything, "test-user", "https://example.com/commute.m3u8").Return(nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/settings/playlist-url", strings.NewReader(`{"url":"https://example.com/commute.m3u8"}`))
+		newPlaylistURLRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Invalid playlist URL", func(t *testing.T) {
+		store := new(MockPlaylistURLStore)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/settings/playlist-url", strings.NewReader(`{"url":"file:///home/me/commute.m3u8"}`))
+		newPlaylistURLRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "http or https")
+		store.AssertNotCalled(t, "SavePlaylistURL", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestHandleDeletePlaylistURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := new(MockPlaylistURLStore)
+	store.On("DeletePlaylistURL", mock.Anything, "test-user").Return(nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/settings/playlist-url", nil)
+	newPlaylistURLRouter(store).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	store.AssertExpectations(t)
+}
@@ -0,0 +1,126 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ItemRetryQueue defines the queue operations needed to retry a job item
+type ItemRetryQueue interface {
+	GetJob(ctx context.Context, jobID string) (*queue.Job, error)
+	Enqueue(ctx context.Context, job *queue.Job) error
+}
+
+// RetryJobItemResponse represents the response for retrying a job item
+type RetryJobItemResponse struct {
+	JobID string `json:"job_id"`
+}
+
+// HandleRetryJobItem returns a handler that retries a single failed item of a job
+// @Summary      Retry failed item
+// @Description  Enqueue a job that processes only this failed item. Items the original job already published are kept in the feed without being processed again
+// @Tags         jobs
+// @Produce      json
+// @Param        id path string true "Job ID"
+// @Param        itemID path string true "Item ID"
+// @Success      202  {object}  RetryJobItemResponse
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      429  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /jobs/{id}/items/{itemID}/retry [post]
+func HandleRetryJobItem(jobQueue ItemRetryQueue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		job, err := jobQueue.GetJob(ctx, c.Param("id"))
+		if err != nil {
+			slog.Error("Failed to fetch job", "error", err, "job_id", c.Param("id"))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch job"})
+			return
+		}
+		// Other users' jobs are reported as missing rather than forbidden
+		if job == nil || job.UserID != userID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+
+		items, found := retryItems(job.Items, c.Param("itemID"))
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+			return
+		}
+		if items == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Only failed items can be retried"})
+			return
+		}
+
+		retry := &queue.Job{
+			ID:        uuid.New().String(),
+			FileID:    job.FileID,
+			UserID:    userID,
+			Filename:  job.Filename,
+			Label:     job.Label,
+			CreatedAt: time.Now(),
+			Priority:  queue.PriorityInteractive,
+			RetryOf:   job.ID,
+			Items:     items,
+		}
+		if err := jobQueue.Enqueue(ctx, retry); err != nil {
+			if errors.Is(err, queue.ErrQuotaExceeded) {
+				c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+				return
+			}
+			slog.Error("Failed to enqueue retry job", "error", err, "job_id", job.ID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enqueue retry"})
+			return
+		}
+
+		slog.Info("Item retry enqueued", "job_id", retry.ID, "retry_of", job.ID, "item_id", c.Param("itemID"))
+		c.JSON(http.StatusAccepted, RetryJobItemResponse{JobID: retry.ID})
+	}
+}
+
+// retryItems builds the items of a retry job: everything the original job
+// published, plus the failed item reset to pending. Other failed items are left
+// out. found is false if the item doesn't exist; items is nil if it hasn't failed.
+func retryItems(jobItems []queue.JobItem, itemID string) (items []queue.JobItem, found bool) {
+	var target *queue.JobItem
+	var kept []queue.JobItem
+	for _, item := range jobItems {
+		switch {
+		case item.ID == itemID:
+			item := item
+			target = &item
+		case item.Status == queue.StatusCompleted || item.Status == queue.StatusSkipped:
+			kept = append(kept, item)
+		}
+	}
+	if target == nil {
+		return nil, false
+	}
+	if target.Status != queue.StatusFailed {
+		return nil, true
+	}
+
+	target.Status = queue.StatusPending
+	target.Error = ""
+	target.Progress = 0
+	target.DriveFileID = ""
+	return append(kept, *target), true
+}
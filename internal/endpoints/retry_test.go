@@ -0,0 +1,115 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockItemRetryQueue is a mock implementation of ItemRetryQueue
+type MockItemRetryQueue struct {
+	mock.Mock
+}
+
+func (m *MockItemRetryQueue) GetJob(ctx context.Context, jobID string) (*queue.Job, error) {
+	args := m.Called(ctx, jobID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*queue.Job), args.Error(1)
+}
+
+func (m *MockItemRetryQueue) Enqueue(ctx context.Context, job *queue.Job) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func TestHandleRetryJobItem(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	job := &queue.Job{
+		ID:     "job-1",
+		UserID: "test-user",
+		Status: queue.JobStatusCompletedWithErrors,
+		Items: []queue.JobItem{
+			{ID: "done", Title: "Done", Status: queue.StatusCompleted, DriveFileID: "file-1"},
+			{ID: "reused", Title: "Reused", Status: queue.StatusSkipped},
+			{ID: "broken", Title: "Broken", Status: queue.StatusFailed, Error: "FFmpeg error"},
+			{ID: "other", Title: "Other", Status: queue.StatusFailed, Error: "upload failed"},
+		},
+	}
+
+	newRouter := func(mockQueue *MockItemRetryQueue) *gin.Engine {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", "test-user")
+			c.Next()
+		})
+		router.POST("/jobs/:id/items/:itemID/retry", HandleRetryJobItem(mockQueue))
+		return router
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		mockQueue := new(MockItemRetryQueue)
+		mockQueue.On("GetJob", mock.Anything, "job-1").Return(job, nil)
+		mockQueue.On("Enqueue", mock.Anything, mock.MatchedBy(func(retry *queue.Job) bool {
+			if retry.RetryOf != "job-1" || retry.UserID != "test-user" || len(retry.Items) != 3 {
+				return false
+			}
+			target := retry.Items[2]
+			return target.ID == "broken" && target.Status == queue.StatusPending && target.Error == ""
+		})).Return(nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/jobs/job-1/items/broken/retry", nil)
+		newRouter(mockQueue).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		var response RetryJobItemResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.NotEmpty(t, response.JobID)
+		mockQueue.AssertExpectations(t)
+	})
+
+	t.Run("Other user's job", func(t *testing.T) {
+		mockQueue := new(MockItemRetryQueue)
+		mockQueue.On("GetJob", mock.Anything, "job-2").Return(&queue.Job{ID: "job-2", UserID: "someone-else"}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/jobs/job-2/items/broken/retry", nil)
+		newRouter(mockQueue).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Unknown item", func(t *testing.T) {
+		mockQueue := new(MockItemRetryQueue)
+		mockQueue.On("GetJob", mock.Anything, "job-1").Return(job, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/jobs/job-1/items/missing/retry", nil)
+		newRouter(mockQueue).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Item did not fail", func(t *testing.T) {
+		mockQueue := new(MockItemRetryQueue)
+		mockQueue.On("GetJob", mock.Anything, "job-1").Return(job, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/jobs/job-1/items/done/retry", nil)
+		newRouter(mockQueue).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		mockQueue.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything)
+	})
+}
@@ -1,22 +1,42 @@
 package endpoints
 
 import (
+	"net/http"
+
+	"cobblepod/internal/audio"
+	"cobblepod/internal/auth"
+	"cobblepod/internal/config"
+	"cobblepod/internal/podcast"
 	"cobblepod/internal/queue"
+	"cobblepod/internal/sources"
+	"cobblepod/internal/storage"
 
-	_ "cobblepod/docs"
+	"cobblepod/docs"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-// SetupRoutes configures all API routes
-func SetupRoutes(r *gin.Engine, jobQueue *queue.Queue) {
+// SetupRoutes configures all API routes, authenticating users with provider
+func SetupRoutes(r *gin.Engine, cfg *config.Config, jobQueue *queue.Queue, provider auth.Provider, sourceChecker SourceChecker) {
+	validate := NewTokenValidator(provider, cfg.Auth.AdminRoleClaim)
+	maxBackupBytes := cfg.Server.MaxBackupUploadBytes()
+	folders := podcast.NewFolders(cfg.Storage.DriveFolder)
+	backupFolder := sources.BackupFolder(cfg.Storage.DriveFolder)
+	requireAuth := AuthMiddleware(validate)
+	// Routes scripts need also accept API keys; managing keys still needs a user token
+	requireAuthOrKey := AuthMiddleware(WithAPIKeys(jobQueue, validate))
+
 	// API group with common middleware
 	api := r.Group("/api")
 	{
 		// Swagger endpoint
 		api.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+		api.GET("/openapi.json", HandleOpenAPISpec(docs.SwaggerInfo))
+		api.GET("/docs", func(c *gin.Context) {
+			c.Redirect(http.StatusFound, "swagger/index.html")
+		})
 
 		// Health check endpoint
 		api.GET("/health", func(c *gin.Context) {
@@ -26,18 +46,110 @@ func SetupRoutes(r *gin.Engine, jobQueue *queue.Queue) {
 			})
 		})
 
+		// Sign in for deployments that use Google directly instead of Auth0
+		if google, ok := provider.(*auth.GoogleProvider); ok {
+			api.POST("/auth/google", HandleGoogleLogin(google, validate))
+		}
+
+		// Feature discovery (protected, includes per-user capabilities)
+		api.GET("/capabilities", requireAuth, HandleGetCapabilities(cfg))
+
 		// Backup routes (protected)
 		backup := api.Group("/backup")
-		backup.Use(Auth0Middleware()) // Require authentication
+		backup.Use(requireAuthOrKey) // Require authentication
 		{
-			backup.POST("/upload", HandleBackupUpload(jobQueue))
+			backup.POST("/upload", HandleBackupUpload(jobQueue, provider, storage.NewServiceWithToken, backupFolder, maxBackupBytes))
+			backup.POST("/session", HandleCreateUploadSession(provider, storage.NewServiceWithToken, backupFolder, maxBackupBytes))
+			backup.POST("/register", HandleRegisterBackup(jobQueue, provider, storage.NewServiceWithToken, maxBackupBytes))
 		}
 
 		// Job routes (protected)
 		jobs := api.Group("/jobs")
-		jobs.Use(Auth0Middleware())
+		jobs.Use(requireAuthOrKey)
 		{
 			jobs.GET("", HandleGetJobs(jobQueue))
+			jobs.GET("/search", HandleSearchJobs(jobQueue))
+			jobs.GET("/:id/events", HandleGetJobEvents(jobQueue))
+			jobs.GET("/:id/logs", HandleGetJobLogs(jobQueue))
+			jobs.POST("/:id/items/:itemID/retry", HandleRetryJobItem(jobQueue))
+		}
+
+		// Archived episode routes (protected)
+		archive := api.Group("/archive")
+		archive.Use(requireAuthOrKey)
+		{
+			archive.GET("", HandleGetArchive(jobQueue))
+			archive.POST("/:fileID/restore", HandleRestoreEpisode(jobQueue))
+		}
+
+		// Settings routes (protected)
+		settings := api.Group("/settings")
+		settings.Use(requireAuth)
+		{
+			settings.GET("", HandleGetSettings(jobQueue))
+			settings.PUT("", HandleUpdateSettings(jobQueue))
+			settings.GET("/playlist-rules", HandleGetPlaylistRules(jobQueue))
+			settings.PUT("/playlist-rules", HandleUpdatePlaylistRules(jobQueue))
+			settings.DELETE("/playlist-rules", HandleDeletePlaylistRules(jobQueue))
+			settings.GET("/playlist-url", HandleGetPlaylistURL(jobQueue))
+			settings.PUT("/playlist-url", HandleUpdatePlaylistURL(jobQueue))
+			settings.DELETE("/playlist-url", HandleDeletePlaylistURL(jobQueue))
+			settings.GET("/api-keys", HandleGetAPIKeys(jobQueue))
+			settings.POST("/api-keys", HandleCreateAPIKey(jobQueue))
+			settings.DELETE("/api-keys/:id", HandleRevokeAPIKey(jobQueue))
+			settings.PUT("/clips/:clip", HandleUploadClip(provider, storage.NewServiceWithToken, folders, audio.NewFFmpeg(cfg.Worker).ProbeFile))
+			settings.DELETE("/clips/:clip", HandleDeleteClip(provider, storage.NewServiceWithToken, folders))
+		}
+
+		// Announcement routes (public read, admin write)
+		announcements := api.Group("/announcements")
+		{
+			announcements.GET("", HandleGetAnnouncements(jobQueue))
+			announcements.POST("", requireAuth, AdminMiddleware(cfg.Auth), HandleCreateAnnouncement(jobQueue))
+			announcements.DELETE("/:id", requireAuth, AdminMiddleware(cfg.Auth), HandleDeleteAnnouncement(jobQueue))
 		}
+
+		// Admin routes
+		admin := api.Group("/admin")
+		admin.Use(requireAuth, AdminMiddleware(cfg.Auth))
+		{
+			admin.POST("/jobs/:id/retry", HandleRetryJob(jobQueue))
+			admin.GET("/jobs/running", HandleGetRunningJobs(jobQueue))
+			admin.GET("/queue", HandleGetQueueStats(jobQueue))
+			admin.GET("/locks", HandleGetUserLocks(jobQueue))
+			admin.DELETE("/locks/:userID", HandleReleaseUserLock(jobQueue))
+			admin.PUT("/users/:userID/weight", HandleSetUserWeight(jobQueue))
+		}
+
+		// Storage usage (protected)
+		api.GET("/usage", requireAuthOrKey, HandleGetUsage(provider, storage.NewServiceWithToken))
+
+		// Feed served to podcast apps, authenticated by the secret slug in its URL
+		api.GET("/feed", requireAuthOrKey, HandleGetFeedLink(jobQueue))
+		api.GET("/feed/stats", requireAuthOrKey, HandleGetFeedStats(jobQueue, provider, storage.NewServiceWithToken, folders))
+		api.POST("/feed/rotate", requireAuth, HandleRotateFeedLink(jobQueue))
+		api.GET("/feed/:slug", HandleGetFeed(jobQueue, provider, storage.NewServiceWithToken, folders))
+		api.HEAD("/feed/:slug", HandleGetFeed(jobQueue, provider, storage.NewServiceWithToken, folders))
+
+		// Episode links in feeds (storage.EpisodePath), authenticated by their signature
+		var episodeLinks *storage.EpisodeLinks
+		if cfg.Server.EpisodeBaseURL != "" {
+			episodeLinks = storage.NewEpisodeLinks(cfg.Server.EpisodeBaseURL, cfg.Server.EpisodeSecret)
+		}
+		api.GET("/episodes/:id/audio", HandleGetEpisodeAudio(episodeLinks, storage.DriveDownloadURL, jobQueue))
+		api.HEAD("/episodes/:id/audio", HandleGetEpisodeAudio(episodeLinks, storage.DriveDownloadURL, jobQueue))
+
+		// Delete the user's jobs and episodes (protected)
+		api.DELETE("/history", requireAuth, HandleDeleteHistory(jobQueue, provider, storage.NewServiceWithToken, folders))
+
+		// Event stream (protected)
+		api.GET("/events", requireAuthOrKey, HandleEvents(jobQueue))
+		api.GET("/ws", requireAuthOrKey, HandleJobUpdates(jobQueue))
+
+		// Push notification registration (protected)
+		api.POST("/webhooks/drive", requireAuth, HandleRegisterDriveWebhook(jobQueue, provider, storage.NewServiceWithToken, cfg.Server.WebhookBaseURL))
 	}
+
+	// Drive push notifications, authenticated by channel token rather than a user token
+	r.POST(DriveWebhookPath, HandleDriveWebhook(jobQueue, sourceChecker))
 }
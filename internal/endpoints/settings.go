@@ -0,0 +1,90 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SettingsStore defines the interface for reading and writing user settings
+type SettingsStore interface {
+	GetUserSettings(ctx context.Context, userID string) (*queue.UserSettings, error)
+	SaveUserSettings(ctx context.Context, userID string, settings *queue.UserSettings) error
+}
+
+// HandleGetSettings returns a handler that retrieves the user's settings
+// @Summary      Get settings
+// @Description  Get the authenticated user's settings
+// @Tags         settings
+// @Produce      json
+// @Success      200  {object}  queue.UserSettings
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /settings [get]
+func HandleGetSettings(store SettingsStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		settings, err := store.GetUserSettings(c.Request.Context(), userID)
+		if err != nil {
+			slog.Error("Failed to fetch settings", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch settings"})
+			return
+		}
+
+		c.JSON(http.StatusOK, settings)
+	}
+}
+
+// HandleUpdateSettings returns a handler that replaces the user's settings
+// @Summary      Update settings
+// @Description  Replace the authenticated user's settings. time_zone must be an IANA zone name such as America/Toronto, speed must be within the range from /capabilities and output_format one of its output_formats. speed_profiles process podcasts, matched by the show name starting their episode titles or by feed URL, at their own speed, and skip_rules cut the start, end or named chapters of their episodes. Zero values use the deployment defaults
+// @Tags         settings
+// @Accept       json
+// @Produce      json
+// @Param        settings body queue.UserSettings true "User settings"
+// @Success      200  {object}  queue.UserSettings
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /settings [put]
+func HandleUpdateSettings(store SettingsStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var settings queue.UserSettings
+		if err := c.ShouldBindJSON(&settings); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid settings"})
+			return
+		}
+		if err := settings.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := store.SaveUserSettings(c.Request.Context(), userID, &settings); err != nil {
+			if errors.Is(err, queue.ErrInvalidTimeZone) || errors.Is(err, queue.ErrInvalidSettings) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			slog.Error("Failed to save settings", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save settings"})
+			return
+		}
+
+		c.JSON(http.StatusOK, settings)
+	}
+}
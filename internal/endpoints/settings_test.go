@@ -0,0 +1,183 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockSettingsStore is a mock implementation of SettingsStore
+type MockSettingsStore struct {
+	mock.Mock
+}
+
+func (m *MockSettingsStore) GetUserSettings(ctx context.Context, userID string) (*queue.UserSettings, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*queue.UserSettings), args.Error(1)
+}
+
+func (m *MockSettingsStore) SaveUserSettings(ctx context.Context, userID string, settings *queue.UserSettings) error {
+	args := m.Called(ctx, userID, settings)
+	return args.Error(0)
+}
+
+func newSettingsRouter(store SettingsStore) *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "test-user")
+		c.Next()
+	})
+	router.GET("/settings", HandleGetSettings(store))
+	router.PUT("/settings", HandleUpdateSettings(store))
+	return router
+}
+
+func TestHandleGetSettings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		router := gin.New()
+		router.GET("/settings", HandleGetSettings(new(MockSettingsStore)))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/settings", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		store := new(MockSettingsStore)
+		store.On("GetUserSettings", mock.Anything, "test-user").Return(&queue.UserSettings{TimeZone: "America/Toronto"}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/settings", nil)
+		newSettingsRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response queue.UserSettings
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "America/Toronto", response.TimeZone)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Store error", func(t *testing.T) {
+		store := new(MockSettingsStore)
+		store.On("GetUserSettings", mock.Anything, "test-user").Return(nil, errors.New("redis down"))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/settings", nil)
+		newSettingsRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestHandleUpdateSettings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Success", func(t *testing.T) {
+		store := new(MockSettingsStore)
+		store.On("SaveUserSettings", mock.Anything, "test-user", &queue.UserSettings{TimeZone: "Europe/Berlin"}).Return(nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/settings", strings.NewReader(`{"time_zone":"Europe/Berlin"}`))
+		req.Header.Set("Content-Type", "application/json")
+		newSettingsRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Invalid time zone", func(t *testing.T) {
+		store := new(MockSettingsStore)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/settings", strings.NewReader(`{"time_zone":"Mars/Olympus"}`))
+		req.Header.Set("Content-Type", "application/json")
+		newSettingsRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		store.AssertNotCalled(t, "SaveUserSettings", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Processing options", func(t *testing.T) {
+		store := new(MockSettingsStore)
+		expected := &queue.UserSettings{Speed: 1.25, TrimSilence: true, OutputFormat: "m4a", RetentionDays: 7, FeedTitle: "Long runs"}
+		store.On("SaveUserSettings", mock.Anything, "test-user", expected).Return(nil)
+
+		w := httptest.NewRecorder()
+		body := `{"speed":1.25,"trim_silence":true,"output_format":"m4a","retention_days":7,"feed_title":"Long runs"}`
+		req, _ := http.NewRequest("PUT", "/settings", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		newSettingsRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Speed profiles", func(t *testing.T) {
+		store := new(MockSettingsStore)
+		expected := &queue.UserSettings{Speed: 1.5, SpeedProfiles: []queue.SpeedProfile{{Podcast: "The Daily", Speed: 2}, {Podcast: "https://example.com/fiction.xml", Speed: 1}}}
+		store.On("SaveUserSettings", mock.Anything, "test-user", expected).Return(nil)
+
+		w := httptest.NewRecorder()
+		body := `{"speed":1.5,"speed_profiles":[{"podcast":"The Daily","speed":2},{"podcast":"https://example.com/fiction.xml","speed":1}]}`
+		req, _ := http.NewRequest("PUT", "/settings", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		newSettingsRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Skip rule skipping nothing", func(t *testing.T) {
+		store := new(MockSettingsStore)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/settings", strings.NewReader(`{"skip_rules":[{"podcast":"The Daily"}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		newSettingsRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "The Daily")
+		store.AssertNotCalled(t, "SaveUserSettings", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Speed out of range", func(t *testing.T) {
+		store := new(MockSettingsStore)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/settings", strings.NewReader(`{"speed":4}`))
+		req.Header.Set("Content-Type", "application/json")
+		newSettingsRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "speed")
+		store.AssertNotCalled(t, "SaveUserSettings", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Malformed body", func(t *testing.T) {
+		store := new(MockSettingsStore)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/settings", strings.NewReader(`{`))
+		req.Header.Set("Content-Type", "application/json")
+		newSettingsRouter(store).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,83 @@
+package endpoints
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobEventsQueue defines the queue operations needed to show a job's events
+type JobEventsQueue interface {
+	GetJob(ctx context.Context, jobID string) (*queue.Job, error)
+	JobTimeline(ctx context.Context, jobID string) ([]queue.Event, error)
+	EventSubscriber
+}
+
+// JobTimelineResponse represents the recorded events of a job
+type JobTimelineResponse struct {
+	JobID  string        `json:"job_id"`
+	Events []queue.Event `json:"events"`
+}
+
+// HandleGetJobEvents returns a handler that shows what happened to a job
+// @Summary      Job events
+// @Description  With history=true, returns every event recorded for the job, oldest first: when it was enqueued, started and finished, each item status change with the error of failed items, and feed uploads. Otherwise streams the job's events as they happen over Server-Sent Events
+// @Tags         jobs
+// @Produce      json
+// @Produce      text/event-stream
+// @Param        id path string true "Job ID"
+// @Param        history query bool false "Return the recorded timeline instead of streaming"
+// @Success      200  {object}  JobTimelineResponse
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /jobs/{id}/events [get]
+func HandleGetJobEvents(jobQueue JobEventsQueue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		jobID := c.Param("id")
+		job, err := jobQueue.GetJob(ctx, jobID)
+		if err != nil {
+			slog.Error("Failed to fetch job", "error", err, "job_id", jobID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch job"})
+			return
+		}
+		// Other users' jobs are reported as missing rather than forbidden
+		if job == nil || job.UserID != userID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+
+		if c.Query("history") == "true" {
+			events, err := jobQueue.JobTimeline(ctx, jobID)
+			if err != nil {
+				slog.Error("Failed to fetch job timeline", "error", err, "job_id", jobID)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch job events"})
+				return
+			}
+			c.JSON(http.StatusOK, JobTimelineResponse{JobID: jobID, Events: events})
+			return
+		}
+
+		events, err := jobQueue.SubscribeEvents(ctx, userID)
+		if err != nil {
+			slog.Error("Failed to subscribe to events", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to subscribe to events"})
+			return
+		}
+		streamEvents(c, events, func(event queue.Event) bool {
+			return event.JobID == jobID
+		})
+	}
+}
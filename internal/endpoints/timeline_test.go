@@ -0,0 +1,116 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockJobEventsQueue is a mock implementation of JobEventsQueue
+type MockJobEventsQueue struct {
+	mock.Mock
+	fakeEventSubscriber
+}
+
+func (m *MockJobEventsQueue) GetJob(ctx context.Context, jobID string) (*queue.Job, error) {
+	args := m.Called(ctx, jobID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*queue.Job), args.Error(1)
+}
+
+func (m *MockJobEventsQueue) JobTimeline(ctx context.Context, jobID string) ([]queue.Event, error) {
+	args := m.Called(ctx, jobID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]queue.Event), args.Error(1)
+}
+
+func TestHandleGetJobEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	job := &queue.Job{ID: "job-1", UserID: "test-user"}
+
+	newRouter := func(mockQueue *MockJobEventsQueue) *gin.Engine {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", "test-user")
+			c.Next()
+		})
+		router.GET("/jobs/:id/events", HandleGetJobEvents(mockQueue))
+		return router
+	}
+
+	t.Run("History", func(t *testing.T) {
+		mockQueue := new(MockJobEventsQueue)
+		mockQueue.On("GetJob", mock.Anything, "job-1").Return(job, nil)
+		mockQueue.On("JobTimeline", mock.Anything, "job-1").Return([]queue.Event{
+			{Type: queue.EventJobCreated, JobID: "job-1", Status: queue.JobStatusQueued},
+			{Type: queue.EventItemUpdated, JobID: "job-1", ItemID: "item-1", Status: string(queue.StatusFailed), Message: "FFmpeg error"},
+		}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/jobs/job-1/events?history=true", nil)
+		newRouter(mockQueue).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response JobTimelineResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "job-1", response.JobID)
+		if assert.Len(t, response.Events, 2) {
+			assert.Equal(t, "FFmpeg error", response.Events[1].Message)
+		}
+		mockQueue.AssertExpectations(t)
+	})
+
+	t.Run("Streams only this job", func(t *testing.T) {
+		mockQueue := new(MockJobEventsQueue)
+		mockQueue.events = []queue.Event{
+			{Type: queue.EventJobStarted, JobID: "job-1"},
+			{Type: queue.EventJobStarted, JobID: "job-2"},
+		}
+		mockQueue.On("GetJob", mock.Anything, "job-1").Return(job, nil)
+
+		w := newStreamRecorder()
+		req, _ := http.NewRequest("GET", "/jobs/job-1/events", nil)
+		newRouter(mockQueue).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"job_id":"job-1"`)
+		assert.NotContains(t, w.Body.String(), `"job_id":"job-2"`)
+	})
+
+	t.Run("Other user's job", func(t *testing.T) {
+		mockQueue := new(MockJobEventsQueue)
+		mockQueue.On("GetJob", mock.Anything, "job-2").Return(&queue.Job{ID: "job-2", UserID: "someone-else"}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/jobs/job-2/events?history=true", nil)
+		newRouter(mockQueue).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Timeline error", func(t *testing.T) {
+		mockQueue := new(MockJobEventsQueue)
+		mockQueue.On("GetJob", mock.Anything, "job-1").Return(job, nil)
+		mockQueue.On("JobTimeline", mock.Anything, "job-1").Return(nil, errors.New("redis down"))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/jobs/job-1/events?history=true", nil)
+		newRouter(mockQueue).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
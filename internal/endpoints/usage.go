@@ -0,0 +1,87 @@
+package endpoints
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"cobblepod/internal/auth"
+	"cobblepod/internal/podcast"
+	"cobblepod/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UsageResponse reports how much of the user's storage cobblepod takes up
+type UsageResponse struct {
+	// Bytes and Files count everything stored for the user: episodes, the feed, clips and backups
+	Bytes int64 `json:"bytes"`
+	Files int   `json:"files"`
+	// Episodes is the number of processed episodes stored for the feed
+	Episodes int `json:"episodes"`
+	// LastFeedUpdate is when the feed was last written; omitted before the first job
+	LastFeedUpdate *time.Time `json:"last_feed_update,omitempty"`
+}
+
+// summarizeUsage adds up the files tagged for a user
+func summarizeUsage(files []*storage.FileMeta) UsageResponse {
+	var usage UsageResponse
+	for _, file := range files {
+		usage.Bytes += file.Size
+		usage.Files++
+		if file.Tags[storage.TagFeed] == "" {
+			continue
+		}
+		if strings.HasPrefix(file.MIME, "audio/") {
+			usage.Episodes++
+		}
+		if file.Name == podcast.RSSFilename && (usage.LastFeedUpdate == nil || file.ModifiedTime.After(*usage.LastFeedUpdate)) {
+			modified := file.ModifiedTime
+			usage.LastFeedUpdate = &modified
+		}
+	}
+	return usage
+}
+
+// HandleGetUsage returns a handler that reports the user's storage usage
+// @Summary      Storage usage
+// @Description  Adds up the files cobblepod stored for the user, found by the tags they were uploaded with. Files stored before uploads were tagged aren't counted until a job replaces them
+// @Tags         usage
+// @Produce      json
+// @Success      200  {object}  UsageResponse
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /usage [get]
+func HandleGetUsage(tokenProvider auth.TokenProvider, storageFactory StorageFactory) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		ctx := c.Request.Context()
+		googleToken, err := tokenProvider.GetGoogleAccessToken(ctx, userID)
+		if err != nil {
+			slog.Error("Failed to get Google access token", "error", err, "user_id", userID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to authenticate with Google"})
+			return
+		}
+		driveService, err := storageFactory(ctx, googleToken)
+		if err != nil {
+			slog.Error("Failed to create Drive service", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to initialize storage service"})
+			return
+		}
+
+		files, err := driveService.GetFiles(storage.Query{Tags: map[string]string{storage.TagUser: userID}})
+		if err != nil {
+			slog.Error("Failed to list stored files", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute usage"})
+			return
+		}
+
+		c.JSON(http.StatusOK, summarizeUsage(files))
+	}
+}
@@ -0,0 +1,81 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cobblepod/internal/auth"
+	"cobblepod/internal/podcast"
+	"cobblepod/internal/storage"
+	storagemock "cobblepod/internal/storage/mock"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newUsageRouter(drive storage.Storage) *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "test-user")
+		c.Next()
+	})
+	router.GET("/usage", HandleGetUsage(&auth.MockTokenProvider{Token: "google-token"}, storagemock.NewMockStorageCreator(drive, nil)))
+	return router
+}
+
+func TestHandleGetUsage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	feedTags := map[string]string{storage.TagUser: "test-user", storage.TagFeed: testFolders.Feed}
+	updated := time.Date(2025, 6, 3, 9, 0, 0, 0, time.UTC)
+
+	t.Run("Success", func(t *testing.T) {
+		drive := storagemock.NewMockStorage()
+		drive.GetFilesFiles = []*storage.FileMeta{
+			{Name: "Episode 1.mp3", MIME: "audio/mpeg", Size: 1000, Tags: feedTags},
+			{Name: "Episode 2.m4a", MIME: "audio/mp4", Size: 2000, Tags: feedTags},
+			{Name: podcast.RSSFilename, MIME: "application/rss+xml", Size: 50, ModifiedTime: updated, Tags: feedTags},
+			{Name: podcast.ClipIntro.Filename(), MIME: "audio/mpeg", Size: 300, Tags: map[string]string{storage.TagUser: "test-user"}},
+		}
+
+		req, _ := http.NewRequest("GET", "/usage", nil)
+		w := httptest.NewRecorder()
+		newUsageRouter(drive).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response UsageResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, int64(3350), response.Bytes)
+		assert.Equal(t, 4, response.Files)
+		assert.Equal(t, 2, response.Episodes)
+		if assert.NotNil(t, response.LastFeedUpdate) {
+			assert.True(t, updated.Equal(*response.LastFeedUpdate))
+		}
+		if assert.Len(t, drive.GetFilesCalls, 1) {
+			assert.Equal(t, map[string]string{storage.TagUser: "test-user"}, drive.GetFilesCalls[0].Query.Tags)
+		}
+	})
+
+	t.Run("NothingStored", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/usage", nil)
+		w := httptest.NewRecorder()
+		newUsageRouter(storagemock.NewMockStorage()).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"bytes":0,"files":0,"episodes":0}`, w.Body.String())
+	})
+
+	t.Run("ListFailure", func(t *testing.T) {
+		drive := storagemock.NewMockStorage()
+		drive.GetFilesError = errors.New("drive down")
+
+		req, _ := http.NewRequest("GET", "/usage", nil)
+		w := httptest.NewRecorder()
+		newUsageRouter(drive).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
@@ -0,0 +1,250 @@
+package endpoints
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"cobblepod/internal/auth"
+	"cobblepod/internal/queue"
+	"cobblepod/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// DriveWebhookPath is where Drive push notifications are delivered, outside the /api group
+const DriveWebhookPath = "/webhooks/drive"
+
+// StorageFactory creates a storage backend authorized with a user's access token
+type StorageFactory func(ctx context.Context, accessToken string) (storage.Storage, error)
+
+// WatchChannelStore defines the interface for persisting push notification channels
+type WatchChannelStore interface {
+	SaveWatchChannel(ctx context.Context, channel *queue.WatchChannel) error
+}
+
+// DriveWebhookQueue defines the queue operations the Drive webhook receiver needs
+type DriveWebhookQueue interface {
+	GetWatchChannel(ctx context.Context, channelID string) (*queue.WatchChannel, error)
+	GetWaitingJobs(ctx context.Context, userID string) ([]*queue.Job, error)
+	GetUserSettings(ctx context.Context, userID string) (*queue.UserSettings, error)
+	ClaimChangeJob(ctx context.Context, userID string, jobID string, runAt time.Time) (bool, error)
+	ReleaseChangeJob(ctx context.Context, userID string, jobID string) error
+	Enqueue(ctx context.Context, job *queue.Job) error
+	EnqueueAt(ctx context.Context, job *queue.Job, runAt time.Time) error
+}
+
+// SourceChecker reports whether a user's storage holds source files a job
+// would process, see sourcecheck.Checker
+type SourceChecker interface {
+	SourcesChanged(ctx context.Context, userID string) (bool, error)
+}
+
+// RegisterDriveWebhookResponse represents the response for registering a Drive webhook
+type RegisterDriveWebhookResponse struct {
+	ChannelID  string    `json:"channel_id"`
+	Expiration time.Time `json:"expiration"`
+}
+
+// HandleRegisterDriveWebhook returns a handler that subscribes the user's Drive to push notifications
+// @Summary      Register Drive webhook
+// @Description  Ask Google Drive to push change notifications for the authenticated user so new backups are processed immediately. Drive channels expire, so clients should re-register before the returned expiration
+// @Tags         webhooks
+// @Produce      json
+// @Success      201  {object}  RegisterDriveWebhookResponse
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Failure      503  {object}  map[string]string
+// @Router       /webhooks/drive [post]
+func HandleRegisterDriveWebhook(store WatchChannelStore, tokenProvider auth.TokenProvider, storageFactory StorageFactory, baseURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		if baseURL == "" {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Push notifications are not configured"})
+			return
+		}
+
+		ctx := c.Request.Context()
+		googleToken, err := tokenProvider.GetGoogleAccessToken(ctx, userID)
+		if err != nil {
+			slog.Error("Failed to get Google access token", "error", err, "user_id", userID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to authenticate with Google"})
+			return
+		}
+
+		userStorage, err := storageFactory(ctx, googleToken)
+		if err != nil {
+			slog.Error("Failed to create Drive service", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to initialize storage service"})
+			return
+		}
+
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			slog.Error("Failed to generate webhook token", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register webhook"})
+			return
+		}
+
+		channel := &queue.WatchChannel{
+			ID:     uuid.New().String(),
+			UserID: userID,
+			Token:  hex.EncodeToString(secret),
+		}
+		address := strings.TrimSuffix(baseURL, "/") + DriveWebhookPath
+		info, err := userStorage.WatchChanges(channel.ID, address, channel.Token)
+		if err != nil {
+			slog.Error("Failed to watch Drive changes", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register webhook"})
+			return
+		}
+		channel.ResourceID = info.ResourceID
+		channel.Expiration = info.Expiration
+
+		if err := store.SaveWatchChannel(ctx, channel); err != nil {
+			slog.Error("Failed to save watch channel", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register webhook"})
+			return
+		}
+
+		slog.Info("Registered Drive webhook", "user_id", userID, "channel_id", channel.ID, "expiration", channel.Expiration)
+		c.JSON(http.StatusCreated, RegisterDriveWebhookResponse{ChannelID: channel.ID, Expiration: channel.Expiration})
+	}
+}
+
+// HandleDriveWebhook returns a handler that receives Google Drive push notifications.
+// Notifications are authenticated by the channel token issued at registration, and
+// a change enqueues a processing job unless one is already waiting to start or
+// checker finds no new source files. A change while the user's job runs
+// enqueues a single follow-up, since the running job has already listed its
+// sources. A nil checker, or one that fails, lets every change through. Users
+// with a poll schedule of their own have the job wait for its next minute.
+func HandleDriveWebhook(jobQueue DriveWebhookQueue, checker SourceChecker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		channelID := c.GetHeader("X-Goog-Channel-ID")
+		resourceState := c.GetHeader("X-Goog-Resource-State")
+
+		channel, err := jobQueue.GetWatchChannel(ctx, channelID)
+		if err != nil {
+			slog.Error("Failed to look up watch channel", "error", err, "channel_id", channelID)
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		if channel == nil {
+			// Unknown or expired channel; Drive stops retrying on 4xx
+			c.Status(http.StatusNotFound)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Goog-Channel-Token")), []byte(channel.Token)) != 1 {
+			slog.Warn("Drive webhook token mismatch", "channel_id", channelID)
+			c.Status(http.StatusForbidden)
+			return
+		}
+
+		// The first message on a channel only confirms it is working
+		if resourceState == "sync" {
+			c.Status(http.StatusOK)
+			return
+		}
+
+		// A job that hasn't started yet will see this change too
+		waiting, err := jobQueue.GetWaitingJobs(ctx, channel.UserID)
+		if err != nil {
+			slog.Error("Failed to fetch waiting jobs", "error", err, "user_id", channel.UserID)
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		if hasQueuedJob(waiting) {
+			slog.Debug("Ignoring Drive change, job already pending", "user_id", channel.UserID)
+			c.Status(http.StatusOK)
+			return
+		}
+
+		// Most changes are episodes and feeds, so check for new sources before
+		// creating a job that would find nothing to do
+		if checker != nil {
+			changed, err := checker.SourcesChanged(ctx, channel.UserID)
+			if err != nil {
+				slog.Warn("Failed to check for new source files, enqueueing anyway", "error", err, "user_id", channel.UserID)
+			} else if !changed {
+				slog.Debug("Ignoring Drive change, no new source files", "user_id", channel.UserID)
+				c.Status(http.StatusOK)
+				return
+			}
+		}
+
+		// Changes are picked up on the user's poll schedule, if they set one
+		now := time.Now()
+		runAt := now
+		settings, err := jobQueue.GetUserSettings(ctx, channel.UserID)
+		if err != nil {
+			slog.Warn("Failed to get user settings, enqueueing now", "error", err, "user_id", channel.UserID)
+		} else {
+			runAt = settings.NextPoll(now)
+		}
+
+		job := &queue.Job{
+			ID:        uuid.New().String(),
+			UserID:    channel.UserID,
+			CreatedAt: now,
+		}
+		// Drive sends notifications in bursts, so only one of them may enqueue
+		claimed, err := jobQueue.ClaimChangeJob(ctx, channel.UserID, job.ID, runAt)
+		if err != nil {
+			slog.Error("Failed to claim change job", "error", err, "user_id", channel.UserID)
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		if !claimed {
+			slog.Debug("Ignoring Drive change, job already pending", "user_id", channel.UserID)
+			c.Status(http.StatusOK)
+			return
+		}
+		if runAt.After(now) {
+			err = jobQueue.EnqueueAt(ctx, job, runAt)
+		} else {
+			err = jobQueue.Enqueue(ctx, job)
+		}
+		if err != nil {
+			if releaseErr := jobQueue.ReleaseChangeJob(ctx, channel.UserID, job.ID); releaseErr != nil {
+				slog.Error("Failed to release change job", "error", releaseErr, "user_id", channel.UserID)
+			}
+			if errors.Is(err, queue.ErrQuotaExceeded) {
+				// Retrying the notification won't help until the quota frees up
+				slog.Warn("Ignoring Drive change, user is over quota", "error", err, "user_id", channel.UserID)
+				c.Status(http.StatusOK)
+				return
+			}
+			slog.Error("Failed to enqueue job", "error", err, "user_id", channel.UserID)
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info("Enqueued job from Drive change notification", "job_id", job.ID, "user_id", channel.UserID, "resource_state", resourceState, "run_at", runAt)
+		c.Status(http.StatusOK)
+	}
+}
+
+// hasQueuedJob reports whether any of the waiting jobs will run without waiting
+// for a scheduled time
+func hasQueuedJob(jobs []*queue.Job) bool {
+	for _, job := range jobs {
+		if job.Status != queue.JobStatusScheduled {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,303 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cobblepod/internal/auth"
+	"cobblepod/internal/queue"
+	"cobblepod/internal/storage"
+	storagemock "cobblepod/internal/storage/mock"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockDriveWebhookQueue is a mock implementation of DriveWebhookQueue and WatchChannelStore
+type MockDriveWebhookQueue struct {
+	mock.Mock
+}
+
+func (m *MockDriveWebhookQueue) GetWatchChannel(ctx context.Context, channelID string) (*queue.WatchChannel, error) {
+	args := m.Called(ctx, channelID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*queue.WatchChannel), args.Error(1)
+}
+
+func (m *MockDriveWebhookQueue) SaveWatchChannel(ctx context.Context, channel *queue.WatchChannel) error {
+	args := m.Called(ctx, channel)
+	return args.Error(0)
+}
+
+func (m *MockDriveWebhookQueue) GetUserSettings(ctx context.Context, userID string) (*queue.UserSettings, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*queue.UserSettings), args.Error(1)
+}
+
+func (m *MockDriveWebhookQueue) ClaimChangeJob(ctx context.Context, userID string, jobID string, runAt time.Time) (bool, error) {
+	args := m.Called(ctx, userID, jobID, runAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockDriveWebhookQueue) ReleaseChangeJob(ctx context.Context, userID string, jobID string) error {
+	args := m.Called(ctx, userID, jobID)
+	return args.Error(0)
+}
+
+func (m *MockDriveWebhookQueue) GetWaitingJobs(ctx context.Context, userID string) ([]*queue.Job, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*queue.Job), args.Error(1)
+}
+
+func (m *MockDriveWebhookQueue) Enqueue(ctx context.Context, job *queue.Job) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockDriveWebhookQueue) EnqueueAt(ctx context.Context, job *queue.Job, runAt time.Time) error {
+	args := m.Called(ctx, job, runAt)
+	return args.Error(0)
+}
+
+// sourceCheckerFunc adapts a function to SourceChecker
+type sourceCheckerFunc func(ctx context.Context, userID string) (bool, error)
+
+func (f sourceCheckerFunc) SourcesChanged(ctx context.Context, userID string) (bool, error) {
+	return f(ctx, userID)
+}
+
+func newDriveNotification(channelID, token, state string) *http.Request {
+	req, _ := http.NewRequest("POST", DriveWebhookPath, nil)
+	req.Header.Set("X-Goog-Channel-ID", channelID)
+	req.Header.Set("X-Goog-Channel-Token", token)
+	req.Header.Set("X-Goog-Resource-State", state)
+	return req
+}
+
+func TestHandleDriveWebhook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	channel := &queue.WatchChannel{ID: "channel-1", UserID: "test-user", Token: "secret"}
+
+	t.Run("Unknown channel", func(t *testing.T) {
+		mockQueue := new(MockDriveWebhookQueue)
+		mockQueue.On("GetWatchChannel", mock.Anything, "missing").Return(nil, nil)
+		router := gin.New()
+		router.POST(DriveWebhookPath, HandleDriveWebhook(mockQueue, nil))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newDriveNotification("missing", "secret", "change"))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Token mismatch", func(t *testing.T) {
+		mockQueue := new(MockDriveWebhookQueue)
+		mockQueue.On("GetWatchChannel", mock.Anything, "channel-1").Return(channel, nil)
+		router := gin.New()
+		router.POST(DriveWebhookPath, HandleDriveWebhook(mockQueue, nil))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newDriveNotification("channel-1", "wrong", "change"))
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		mockQueue.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Sync message", func(t *testing.T) {
+		mockQueue := new(MockDriveWebhookQueue)
+		mockQueue.On("GetWatchChannel", mock.Anything, "channel-1").Return(channel, nil)
+		router := gin.New()
+		router.POST(DriveWebhookPath, HandleDriveWebhook(mockQueue, nil))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newDriveNotification("channel-1", "secret", "sync"))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockQueue.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Change enqueues job", func(t *testing.T) {
+		mockQueue := new(MockDriveWebhookQueue)
+		mockQueue.On("GetWatchChannel", mock.Anything, "channel-1").Return(channel, nil)
+		mockQueue.On("GetWaitingJobs", mock.Anything, "test-user").Return([]*queue.Job{{ID: "tonight", Status: queue.JobStatusScheduled}}, nil)
+		mockQueue.On("GetUserSettings", mock.Anything, "test-user").Return(&queue.UserSettings{}, nil)
+		mockQueue.On("ClaimChangeJob", mock.Anything, "test-user", mock.Anything, mock.Anything).Return(true, nil)
+		mockQueue.On("Enqueue", mock.Anything, mock.MatchedBy(func(job *queue.Job) bool {
+			return job.ID != "" && job.UserID == "test-user"
+		})).Return(nil)
+		router := gin.New()
+		router.POST(DriveWebhookPath, HandleDriveWebhook(mockQueue, nil))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newDriveNotification("channel-1", "secret", "change"))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockQueue.AssertExpectations(t)
+	})
+
+	t.Run("Change waits for the user's poll schedule", func(t *testing.T) {
+		// Never due within a minute of now, whatever the time
+		settings := &queue.UserSettings{PollSchedule: "0 0 1 1 *"}
+		if settings.NextPoll(time.Now()).Before(time.Now().Add(time.Minute)) {
+			t.Skip("running during the scheduled minute")
+		}
+		mockQueue := new(MockDriveWebhookQueue)
+		mockQueue.On("GetWatchChannel", mock.Anything, "channel-1").Return(channel, nil)
+		mockQueue.On("GetWaitingJobs", mock.Anything, "test-user").Return([]*queue.Job{}, nil)
+		mockQueue.On("GetUserSettings", mock.Anything, "test-user").Return(settings, nil)
+		later := mock.MatchedBy(func(runAt time.Time) bool { return runAt.After(time.Now()) })
+		mockQueue.On("ClaimChangeJob", mock.Anything, "test-user", mock.Anything, later).Return(true, nil)
+		mockQueue.On("EnqueueAt", mock.Anything, mock.Anything, later).Return(nil)
+		router := gin.New()
+		router.POST(DriveWebhookPath, HandleDriveWebhook(mockQueue, nil))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newDriveNotification("channel-1", "secret", "change"))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockQueue.AssertExpectations(t)
+		mockQueue.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Change ignored without new sources", func(t *testing.T) {
+		mockQueue := new(MockDriveWebhookQueue)
+		mockQueue.On("GetWatchChannel", mock.Anything, "channel-1").Return(channel, nil)
+		mockQueue.On("GetWaitingJobs", mock.Anything, "test-user").Return([]*queue.Job{}, nil)
+		checker := sourceCheckerFunc(func(ctx context.Context, userID string) (bool, error) { return false, nil })
+		router := gin.New()
+		router.POST(DriveWebhookPath, HandleDriveWebhook(mockQueue, checker))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newDriveNotification("channel-1", "secret", "change"))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockQueue.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Change enqueued when the check fails", func(t *testing.T) {
+		mockQueue := new(MockDriveWebhookQueue)
+		mockQueue.On("GetWatchChannel", mock.Anything, "channel-1").Return(channel, nil)
+		mockQueue.On("GetWaitingJobs", mock.Anything, "test-user").Return([]*queue.Job{}, nil)
+		mockQueue.On("GetUserSettings", mock.Anything, "test-user").Return(&queue.UserSettings{}, nil)
+		mockQueue.On("ClaimChangeJob", mock.Anything, "test-user", mock.Anything, mock.Anything).Return(true, nil)
+		mockQueue.On("Enqueue", mock.Anything, mock.Anything).Return(nil)
+		checker := sourceCheckerFunc(func(ctx context.Context, userID string) (bool, error) { return false, errors.New("token expired") })
+		router := gin.New()
+		router.POST(DriveWebhookPath, HandleDriveWebhook(mockQueue, checker))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newDriveNotification("channel-1", "secret", "change"))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockQueue.AssertExpectations(t)
+	})
+
+	t.Run("Change ignored while job pending", func(t *testing.T) {
+		mockQueue := new(MockDriveWebhookQueue)
+		mockQueue.On("GetWatchChannel", mock.Anything, "channel-1").Return(channel, nil)
+		mockQueue.On("GetWaitingJobs", mock.Anything, "test-user").Return([]*queue.Job{{ID: "job-1", Status: queue.JobStatusQueued}}, nil)
+		router := gin.New()
+		router.POST(DriveWebhookPath, HandleDriveWebhook(mockQueue, nil))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newDriveNotification("channel-1", "secret", "change"))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockQueue.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Change ignored when another notification claimed the job", func(t *testing.T) {
+		mockQueue := new(MockDriveWebhookQueue)
+		mockQueue.On("GetWatchChannel", mock.Anything, "channel-1").Return(channel, nil)
+		mockQueue.On("GetWaitingJobs", mock.Anything, "test-user").Return([]*queue.Job{}, nil)
+		mockQueue.On("GetUserSettings", mock.Anything, "test-user").Return(&queue.UserSettings{}, nil)
+		mockQueue.On("ClaimChangeJob", mock.Anything, "test-user", mock.Anything, mock.Anything).Return(false, nil)
+		router := gin.New()
+		router.POST(DriveWebhookPath, HandleDriveWebhook(mockQueue, nil))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newDriveNotification("channel-1", "secret", "change"))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockQueue.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Claim released when enqueueing fails", func(t *testing.T) {
+		mockQueue := new(MockDriveWebhookQueue)
+		mockQueue.On("GetWatchChannel", mock.Anything, "channel-1").Return(channel, nil)
+		mockQueue.On("GetWaitingJobs", mock.Anything, "test-user").Return([]*queue.Job{}, nil)
+		mockQueue.On("GetUserSettings", mock.Anything, "test-user").Return(&queue.UserSettings{}, nil)
+		mockQueue.On("ClaimChangeJob", mock.Anything, "test-user", mock.Anything, mock.Anything).Return(true, nil)
+		mockQueue.On("Enqueue", mock.Anything, mock.Anything).Return(errors.New("connection refused"))
+		mockQueue.On("ReleaseChangeJob", mock.Anything, "test-user", mock.Anything).Return(nil)
+		router := gin.New()
+		router.POST(DriveWebhookPath, HandleDriveWebhook(mockQueue, nil))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newDriveNotification("channel-1", "secret", "change"))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		mockQueue.AssertExpectations(t)
+	})
+}
+
+func TestHandleRegisterDriveWebhook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(store WatchChannelStore, storageService storage.Storage, baseURL string) *gin.Engine {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", "test-user")
+			c.Next()
+		})
+		factory := func(ctx context.Context, accessToken string) (storage.Storage, error) {
+			return storageService, nil
+		}
+		router.POST("/webhooks/drive", HandleRegisterDriveWebhook(store, &auth.MockTokenProvider{Token: "google-token"}, factory, baseURL))
+		return router
+	}
+
+	t.Run("Not configured", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/webhooks/drive", nil)
+		newRouter(new(MockDriveWebhookQueue), storagemock.NewMockStorage(), "").ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		expiration := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+		storageService := storagemock.NewMockStorage()
+		storageService.WatchChangesResult = &storage.WatchInfo{ResourceID: "resource-1", Expiration: expiration}
+		store := new(MockDriveWebhookQueue)
+		store.On("SaveWatchChannel", mock.Anything, mock.MatchedBy(func(channel *queue.WatchChannel) bool {
+			return channel.UserID == "test-user" && channel.Token != "" && channel.ResourceID == "resource-1"
+		})).Return(nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/webhooks/drive", nil)
+		newRouter(store, storageService, "https://cobblepod.example.com/").ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		store.AssertExpectations(t)
+		if assert.Len(t, storageService.WatchChangesCalls, 1) {
+			assert.Equal(t, "https://cobblepod.example.com/webhooks/drive", storageService.WatchChangesCalls[0].Address)
+		}
+
+		var response RegisterDriveWebhookResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.NotEmpty(t, response.ChannelID)
+		assert.True(t, expiration.Equal(response.Expiration))
+	})
+}
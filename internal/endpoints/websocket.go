@@ -0,0 +1,95 @@
+package endpoints
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// keepAliveMessage is sent on idle sockets so proxies don't close them
+var keepAliveMessage = gin.H{"type": "keep-alive"}
+
+// HandleJobUpdates returns a handler that upgrades to a WebSocket and pushes the
+// authenticated user's job and item state transitions as JSON messages
+// @Summary      Live job updates
+// @Description  Upgrades to a WebSocket that receives a queue.Event message for every job created, started and finished, item status change and feed update. Browsers that can't set headers on WebSocket requests may pass the bearer token as the access_token query parameter.
+// @Tags         events
+// @Param        access_token  query  string  false  "Bearer token, for clients that can't set the Authorization header"
+// @Success      101  {object}  queue.Event
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /ws [get]
+func HandleJobUpdates(subscriber EventSubscriber) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		// Subscribe before upgrading so failures can still be reported as JSON
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		defer cancel()
+		events, err := subscriber.SubscribeEvents(ctx, userID)
+		if err != nil {
+			slog.Error("Failed to subscribe to events", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to subscribe to events"})
+			return
+		}
+
+		// Tokens travel in the header or query string rather than cookies, so
+		// there is no cross-site risk in accepting any origin
+		server := websocket.Server{Handler: func(ws *websocket.Conn) {
+			// The server's timeouts would otherwise cut long-lived sockets
+			if err := ws.SetDeadline(time.Time{}); err != nil {
+				slog.Debug("Unable to clear deadline for job updates socket", "error", err)
+			}
+			go discardIncoming(ws, cancel)
+			pushEvents(ctx, ws, events)
+		}}
+		server.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// discardIncoming reads until the client goes away, then cancels the
+// subscription. Clients aren't expected to send anything.
+func discardIncoming(ws *websocket.Conn, cancel context.CancelFunc) {
+	defer cancel()
+	var message string
+	for {
+		if err := websocket.Message.Receive(ws, &message); err != nil {
+			return
+		}
+	}
+}
+
+// pushEvents writes events to the socket until either side is done
+func pushEvents(ctx context.Context, ws *websocket.Conn, events <-chan queue.Event) {
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		var message interface{}
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			message = event
+		case <-keepAlive.C:
+			message = keepAliveMessage
+		}
+		if err := websocket.JSON.Send(ws, message); err != nil {
+			slog.Debug("Failed to send job update", "error", err)
+			return
+		}
+	}
+}
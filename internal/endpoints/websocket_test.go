@@ -0,0 +1,74 @@
+package endpoints
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cobblepod/internal/queue"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+func TestHandleJobUpdates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	withUser := func(c *gin.Context) {
+		c.Set("user_id", "test-user")
+		c.Next()
+	}
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		router := gin.New()
+		router.GET("/ws", HandleJobUpdates(&fakeEventSubscriber{}))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/ws", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Pushes events", func(t *testing.T) {
+		subscriber := &fakeEventSubscriber{events: []queue.Event{
+			{Type: queue.EventJobStarted, JobID: "job-1", Status: "running"},
+			{Type: queue.EventItemUpdated, JobID: "job-1", ItemID: "item-1", Status: "downloading"},
+		}}
+		router := gin.New()
+		router.Use(withUser)
+		router.GET("/ws", HandleJobUpdates(subscriber))
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", "", server.URL)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer ws.Close()
+
+		var first, second queue.Event
+		assert.NoError(t, websocket.JSON.Receive(ws, &first))
+		assert.NoError(t, websocket.JSON.Receive(ws, &second))
+
+		assert.Equal(t, "test-user", subscriber.userID)
+		assert.Equal(t, queue.EventJobStarted, first.Type)
+		assert.Equal(t, queue.EventItemUpdated, second.Type)
+		assert.Equal(t, "item-1", second.ItemID)
+	})
+
+	t.Run("Subscribe error", func(t *testing.T) {
+		router := gin.New()
+		router.Use(withUser)
+		router.GET("/ws", HandleJobUpdates(&fakeEventSubscriber{err: errors.New("redis down")}))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/ws", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
@@ -0,0 +1,131 @@
+// Package health serves the /healthz and /readyz probes Kubernetes uses to
+// restart a broken pod or stop sending it work.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CheckTimeout bounds a single check so a hung dependency fails the probe
+// instead of stalling it past the kubelet's own timeout
+const CheckTimeout = 3 * time.Second
+
+// CheckFunc reports whether a dependency is usable
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	run  CheckFunc
+}
+
+// Checker runs named checks for the liveness and readiness probes. Liveness
+// checks should only fail when restarting the process would help; readiness
+// runs every check, including dependencies shared by all pods.
+type Checker struct {
+	live  []check
+	ready []check
+}
+
+// Response is the body of a probe: "ok" or the error of each check
+type Response struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// NewChecker creates a checker with no checks; both probes pass until some are added
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// AddLiveness adds a check to both probes
+func (c *Checker) AddLiveness(name string, run CheckFunc) {
+	c.live = append(c.live, check{name: name, run: run})
+	c.ready = append(c.ready, check{name: name, run: run})
+}
+
+// AddReadiness adds a check to the readiness probe only
+func (c *Checker) AddReadiness(name string, run CheckFunc) {
+	c.ready = append(c.ready, check{name: name, run: run})
+}
+
+// Live serves the liveness probe
+func (c *Checker) Live(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, c.live)
+}
+
+// Ready serves the readiness probe
+func (c *Checker) Ready(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, c.ready)
+}
+
+// Register adds /healthz and /readyz to mux
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", c.Live)
+	mux.HandleFunc("GET /readyz", c.Ready)
+}
+
+// serve runs checks concurrently and answers 503 if any of them failed
+func serve(w http.ResponseWriter, r *http.Request, checks []check) {
+	results := make(map[string]string, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, chk := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(r.Context(), CheckTimeout)
+			defer cancel()
+			result := "ok"
+			if err := chk.run(ctx); err != nil {
+				result = err.Error()
+			}
+			mu.Lock()
+			results[chk.name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	response := Response{Status: "ok", Checks: results}
+	status := http.StatusOK
+	for _, result := range results {
+		if result != "ok" {
+			response.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Reachable checks that url answers at all. Any response short of a server
+// error counts, so an API that wants credentials still passes.
+func Reachable(client *http.Client, url string) CheckFunc {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("unreachable: %w", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("unhealthy: HTTP %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
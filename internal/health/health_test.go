@@ -0,0 +1,68 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func probe(t *testing.T, checker *Checker, path string) (int, Response) {
+	mux := http.NewServeMux()
+	checker.Register(mux)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	mux.ServeHTTP(w, req)
+
+	var response Response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return w.Code, response
+}
+
+func TestChecker(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	down := func(ctx context.Context) error { return errors.New("connection refused") }
+
+	checker := NewChecker()
+	checker.AddLiveness("ffmpeg", ok)
+	checker.AddReadiness("redis", down)
+
+	// Liveness ignores dependencies only readiness cares about
+	code, response := probe(t, checker, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", response.Status)
+	assert.Equal(t, map[string]string{"ffmpeg": "ok"}, response.Checks)
+
+	code, response = probe(t, checker, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unavailable", response.Status)
+	assert.Equal(t, map[string]string{"ffmpeg": "ok", "redis": "connection refused"}, response.Checks)
+}
+
+func TestCheckerWithoutChecks(t *testing.T) {
+	code, response := probe(t, NewChecker(), "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", response.Status)
+}
+
+func TestReachable(t *testing.T) {
+	status := http.StatusUnauthorized
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	// An API refusing anonymous requests is still reachable
+	assert.NoError(t, Reachable(server.Client(), server.URL)(context.Background()))
+
+	status = http.StatusBadGateway
+	assert.Error(t, Reachable(server.Client(), server.URL)(context.Background()))
+
+	server.Close()
+	assert.Error(t, Reachable(server.Client(), server.URL)(context.Background()))
+}
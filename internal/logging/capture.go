@@ -0,0 +1,170 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// captureBuffer is how many captured lines may wait to be stored; lines
+	// logged while it's full are dropped rather than slow the job down
+	captureBuffer = 1000
+	// captureTimeout bounds storing one line
+	captureTimeout = 5 * time.Second
+)
+
+// userAttrs are the attributes kept with captured lines, which their users
+// read. Others, such as stack traces, FFmpeg commands and temp file paths, are
+// only for operators.
+var userAttrs = map[string]bool{
+	"error":           true,
+	"title":           true,
+	"item_id":         true,
+	"file_id":         true,
+	"name":            true,
+	"filename":        true,
+	"url":             true,
+	"feed_url":        true,
+	"download_url":    true,
+	"clip":            true,
+	"modified":        true,
+	"speed":           true,
+	"offset":          true,
+	"trim":            true,
+	"cut":             true,
+	"kbps":            true,
+	"attempt":         true,
+	"attempts":        true,
+	"reason":          true,
+	"retry_of":        true,
+	"run_at":          true,
+	"count":           true,
+	"items":           true,
+	"files":           true,
+	"processed_files": true,
+	"changed":         true,
+	"unchanged":       true,
+	"listed":          true,
+	"deleted":         true,
+	"failed_items":    true,
+	"total_items":     true,
+	"required_bytes":  true,
+	"available_bytes": true,
+}
+
+// Line is a log line captured for a job, see Handler.CaptureJobs
+type Line struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+	// Attrs are the line's attributes meant for users, see userAttrs
+	Attrs map[string]string `json:"attrs,omitempty"`
+}
+
+// Sink stores the lines captured for jobs
+type Sink interface {
+	AppendJobLog(ctx context.Context, jobID string, line Line) error
+}
+
+// capture hands lines to a Sink in the background, in the order they were
+// logged
+type capture struct {
+	sink  Sink
+	lines chan capturedLine
+	done  chan struct{}
+
+	// mu keeps lines from being added once Close closed the channel
+	mu     sync.RWMutex
+	closed bool
+}
+
+type capturedLine struct {
+	jobID string
+	line  Line
+}
+
+// CaptureJobs returns a Handler that also passes the lines logged with a
+// job's context, see With, at info level or above to sink. The lines are
+// stored in the background; a sink that fails or falls behind loses lines
+// rather than holding up the code logging them.
+func (h *Handler) CaptureJobs(sink Sink) *Handler {
+	c := &capture{sink: sink, lines: make(chan capturedLine, captureBuffer), done: make(chan struct{})}
+	go c.run()
+	return &Handler{Handler: h.Handler, capture: c}
+}
+
+// Close stops capturing lines and waits until those already captured are
+// stored or ctx is done. Handlers that don't capture have nothing to close.
+func (h *Handler) Close(ctx context.Context) error {
+	c := h.capture
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.lines)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run stores captured lines until Close
+func (c *capture) run() {
+	defer close(c.done)
+	for captured := range c.lines {
+		ctx, cancel := context.WithTimeout(context.Background(), captureTimeout)
+		// Failures aren't logged, as that would capture more lines to store
+		_ = c.sink.AppendJobLog(ctx, captured.jobID, captured.line)
+		cancel()
+	}
+}
+
+// add queues r for storing if it was logged for a job
+func (c *capture) add(ctx context.Context, r slog.Record) {
+	if c == nil || r.Level < slog.LevelInfo {
+		return
+	}
+	var jobID string
+	attrs := make(map[string]string)
+	collect := func(a slog.Attr) bool {
+		switch a.Key {
+		case "job_id":
+			jobID = a.Value.String()
+		default:
+			if userAttrs[a.Key] {
+				attrs[a.Key] = a.Value.String()
+			}
+		}
+		return true
+	}
+	for _, a := range Attrs(ctx) {
+		collect(a)
+	}
+	r.Attrs(collect)
+	if jobID == "" {
+		return
+	}
+	if len(attrs) == 0 {
+		attrs = nil
+	}
+
+	line := Line{Time: r.Time, Level: r.Level.String(), Message: r.Message, Attrs: attrs}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.lines <- capturedLine{jobID: jobID, line: line}:
+	default:
+	}
+}
@@ -0,0 +1,80 @@
+// Package logging attributes log lines to the work they're written for. With
+// attaches identifiers such as job_id, user_id and item_id to a context, and
+// Handler adds them to every record logged with that context, e.g. by
+// slog.InfoContext, so lines from concurrent jobs can be told apart.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// attrsKey is the context key of the attributes With attaches
+type attrsKey struct{}
+
+// With returns a copy of ctx whose log lines carry args, alternating keys and
+// values or slog.Attrs as for slog.Logger.With, after those ctx already carries.
+// An attribute replaces one ctx carries under the same key.
+func With(ctx context.Context, args ...any) context.Context {
+	record := slog.NewRecord(time.Time{}, 0, "", 0)
+	record.Add(args...)
+	attrs := slices.Clone(Attrs(ctx))
+	record.Attrs(func(a slog.Attr) bool {
+		attrs = slices.DeleteFunc(attrs, func(b slog.Attr) bool { return b.Key == a.Key })
+		attrs = append(attrs, a)
+		return true
+	})
+	return context.WithValue(ctx, attrsKey{}, attrs)
+}
+
+// Attrs returns the attributes ctx's log lines carry
+func Attrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	return attrs
+}
+
+// Handler adds the attributes of a record's context, see With, that the
+// record doesn't set itself
+type Handler struct {
+	slog.Handler
+	capture *capture
+}
+
+// NewHandler returns h adding records' context attributes
+func NewHandler(h slog.Handler) *Handler {
+	return &Handler{Handler: h}
+}
+
+// Handle adds ctx's attributes to r and passes it on
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs := Attrs(ctx); len(attrs) > 0 {
+		r = r.Clone()
+		set := make(map[string]bool, r.NumAttrs())
+		r.Attrs(func(a slog.Attr) bool {
+			set[a.Key] = true
+			return true
+		})
+		for _, a := range attrs {
+			if !set[a.Key] {
+				r.AddAttrs(a)
+			}
+		}
+	}
+	h.capture.add(ctx, r)
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a Handler whose handler has attrs
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{Handler: h.Handler.WithAttrs(attrs), capture: h.capture}
+}
+
+// WithGroup returns a Handler whose handler opens group
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{Handler: h.Handler.WithGroup(name), capture: h.capture}
+}
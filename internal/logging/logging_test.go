@@ -0,0 +1,121 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(NewHandler(slog.NewJSONHandler(&out, nil)))
+
+	ctx := With(context.Background(), "job_id", "job-1", "user_id", "user-1")
+	ctx = With(ctx, slog.String("item_id", "item-1"), "job_id", "job-2")
+	logger.InfoContext(ctx, "Processing audio", "user_id", "explicit")
+
+	var line map[string]any
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("Failed to parse %q: %v", out.String(), err)
+	}
+	want := map[string]any{"job_id": "job-2", "user_id": "explicit", "item_id": "item-1"}
+	for key, value := range want {
+		if line[key] != value {
+			t.Errorf("%s = %v, want %v", key, line[key], value)
+		}
+	}
+	if n := bytes.Count(out.Bytes(), []byte(`"user_id"`)); n != 1 {
+		t.Errorf("Expected user_id once, got %d times in %s", n, out.String())
+	}
+
+	out.Reset()
+	logger.Info("No context")
+	if bytes.Contains(out.Bytes(), []byte("job_id")) {
+		t.Errorf("Expected no context attributes, got %s", out.String())
+	}
+}
+
+// sinkFunc adapts a function to Sink
+type sinkFunc func(jobID string, line Line)
+
+func (f sinkFunc) AppendJobLog(_ context.Context, jobID string, line Line) error {
+	f(jobID, line)
+	return nil
+}
+
+func TestCaptureJobs(t *testing.T) {
+	captured := make(chan Line, 10)
+	sink := sinkFunc(func(jobID string, line Line) {
+		if jobID != "job-1" {
+			t.Errorf("Captured a line for %q, want job-1", jobID)
+		}
+		captured <- line
+	})
+	handler := NewHandler(slog.NewJSONHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	logger := slog.New(handler.CaptureJobs(sink)).With("component", "ffmpeg")
+
+	ctx := With(context.Background(), "job_id", "job-1", "user_id", "user-1")
+	logger.DebugContext(ctx, "Too detailed")
+	logger.Info("No job")
+	logger.WarnContext(With(ctx, "item_id", "item-1"), "Retrying download", "attempt", 2, "path", "/tmp/episode.mp3")
+
+	select {
+	case line := <-captured:
+		if line.Level != "WARN" || line.Message != "Retrying download" {
+			t.Errorf("Captured %+v, want the warning", line)
+		}
+		want := map[string]string{"item_id": "item-1", "attempt": "2"}
+		for key, value := range want {
+			if line.Attrs[key] != value {
+				t.Errorf("%s = %q, want %q", key, line.Attrs[key], value)
+			}
+		}
+		if _, ok := line.Attrs["user_id"]; ok {
+			t.Errorf("Expected the IDs to be left out of the attributes, got %v", line.Attrs)
+		}
+		if _, ok := line.Attrs["path"]; ok {
+			t.Errorf("Expected operator details to be left out of the attributes, got %v", line.Attrs)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the warning to be captured")
+	}
+	select {
+	case line := <-captured:
+		t.Errorf("Captured an unexpected line %+v", line)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestCaptureClose(t *testing.T) {
+	var stored []string
+	sink := sinkFunc(func(jobID string, line Line) {
+		time.Sleep(time.Millisecond)
+		stored = append(stored, line.Message)
+	})
+	handler := NewHandler(slog.NewJSONHandler(io.Discard, nil)).CaptureJobs(sink)
+	logger := slog.New(handler)
+
+	ctx := With(context.Background(), "job_id", "job-1")
+	for range 10 {
+		logger.InfoContext(ctx, "Processing")
+	}
+	if err := handler.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if len(stored) != 10 {
+		t.Errorf("Expected Close to wait for all 10 lines, %d were stored", len(stored))
+	}
+
+	// Lines logged after Close are dropped, not sent on the closed channel
+	logger.InfoContext(ctx, "Too late")
+	if err := handler.Close(context.Background()); err != nil {
+		t.Errorf("Expected a second Close to succeed, got %v", err)
+	}
+	if len(stored) != 10 {
+		t.Errorf("Expected no lines stored after Close, got %v", stored)
+	}
+}
@@ -0,0 +1,35 @@
+// Package metadata looks up canonical episode metadata from the show an episode
+// came from, to enrich feed items when the playlist alone lacks context.
+package metadata
+
+import (
+	"context"
+	"time"
+)
+
+// Query identifies an episode of a show. GUID is preferred; SourceURL (the
+// episode's audio URL) is used when the GUID is unknown or doesn't match, and
+// Title only when neither does.
+type Query struct {
+	FeedURL   string // The show's RSS feed
+	GUID      string
+	SourceURL string
+	Title     string // The episode's own title, without the show's
+}
+
+// Episode is the canonical metadata of an episode as published by its show
+type Episode struct {
+	GUID        string
+	Title       string
+	Show        string // The show's title
+	PubDate     time.Time
+	Description string
+	Image       string // Episode artwork, falling back to the show's
+	AudioURL    string // The episode's enclosure
+}
+
+// Provider looks up episode metadata. Lookup returns nil without an error when
+// the episode can't be found, so callers can carry on with what they have.
+type Provider interface {
+	Lookup(ctx context.Context, query Query) (*Episode, error)
+}
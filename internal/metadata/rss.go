@@ -0,0 +1,258 @@
+package metadata
+
+import (
+	"container/list"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// FeedCacheTTL is how long a fetched show feed is reused for lookups
+	FeedCacheTTL = time.Hour
+	// MaxCachedFeeds caps how many show feeds are cached; the least recently
+	// used is evicted first
+	MaxCachedFeeds = 64
+	// fetchTimeout bounds a single show feed download
+	fetchTimeout = 30 * time.Second
+	// maxFeedBytes caps how much of a show feed is read
+	maxFeedBytes = 20 << 20
+)
+
+// pubDateLayouts are the date formats seen in the wild for RSS pubDate
+var pubDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC3339,
+}
+
+// rssImage is an <itunes:image href="..."/> element
+type rssImage struct {
+	Href string `xml:"href,attr"`
+}
+
+// rssItem holds the parts of a show's <item> used for lookups
+type rssItem struct {
+	GUID        string     `xml:"guid"`
+	Title       string     `xml:"title"`
+	PubDate     string     `xml:"pubDate"`
+	Description string     `xml:"description"`
+	Images      []rssImage `xml:"image"`
+	Enclosure   struct {
+		URL string `xml:"url,attr"`
+	} `xml:"enclosure"`
+}
+
+// rssFeed is a show's RSS document
+type rssFeed struct {
+	Channel struct {
+		Title  string     `xml:"title"`
+		Images []rssImage `xml:"image"`
+		Items  []rssItem  `xml:"item"`
+	} `xml:"channel"`
+}
+
+type cachedFeed struct {
+	url       string
+	feed      *rssFeed
+	fetchedAt time.Time
+}
+
+// RSSProvider looks episodes up in their show's RSS feed. Feeds are cached for
+// FeedCacheTTL, so a playlist with many episodes of one show fetches it once.
+// At most MaxCachedFeeds are kept.
+type RSSProvider struct {
+	client   *http.Client
+	maxFeeds int
+
+	mu    sync.Mutex
+	feeds map[string]*list.Element // Elements of recent holding *cachedFeed
+	// recent orders the cached feeds from most to least recently used
+	recent *list.List
+}
+
+var _ Provider = (*RSSProvider)(nil)
+
+// NewRSSProvider creates a provider; a nil client uses one with a short timeout
+func NewRSSProvider(client *http.Client) *RSSProvider {
+	if client == nil {
+		client = &http.Client{Timeout: fetchTimeout}
+	}
+	return &RSSProvider{
+		client:   client,
+		maxFeeds: MaxCachedFeeds,
+		feeds:    make(map[string]*list.Element),
+		recent:   list.New(),
+	}
+}
+
+// Lookup finds an episode in its show's feed by GUID, then by audio URL
+func (p *RSSProvider) Lookup(ctx context.Context, query Query) (*Episode, error) {
+	if query.FeedURL == "" {
+		return nil, nil
+	}
+
+	feed, err := p.feed(ctx, query.FeedURL)
+	if err != nil {
+		return nil, err
+	}
+
+	item := findItem(feed.Channel.Items, query)
+	if item == nil {
+		return nil, nil
+	}
+
+	episode := &Episode{
+		GUID:        strings.TrimSpace(item.GUID),
+		Title:       strings.TrimSpace(item.Title),
+		Show:        strings.TrimSpace(feed.Channel.Title),
+		PubDate:     parsePubDate(item.PubDate),
+		Description: strings.TrimSpace(item.Description),
+		Image:       imageHref(item.Images),
+		AudioURL:    strings.TrimSpace(item.Enclosure.URL),
+	}
+	if episode.Image == "" {
+		episode.Image = imageHref(feed.Channel.Images)
+	}
+	return episode, nil
+}
+
+// feed returns the parsed show feed, fetching it if it isn't cached
+func (p *RSSProvider) feed(ctx context.Context, feedURL string) (*rssFeed, error) {
+	if feed := p.cached(feedURL); feed != nil {
+		return feed, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch show feed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch show feed: HTTP %d", resp.StatusCode)
+	}
+
+	var feed rssFeed
+	decoder := xml.NewDecoder(io.LimitReader(resp.Body, maxFeedBytes))
+	// Feeds declare all sorts of legacy encodings; the fields we read are ASCII-safe enough
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+	if err := decoder.Decode(&feed); err != nil {
+		return nil, fmt.Errorf("failed to parse show feed: %w", err)
+	}
+
+	p.cache(feedURL, &feed)
+	return &feed, nil
+}
+
+// cached returns the cached feed, or nil if it isn't cached or has expired,
+// in which case it's evicted
+func (p *RSSProvider) cached(feedURL string) *rssFeed {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	element, ok := p.feeds[feedURL]
+	if !ok {
+		return nil
+	}
+	entry := element.Value.(*cachedFeed)
+	if time.Since(entry.fetchedAt) >= FeedCacheTTL {
+		p.recent.Remove(element)
+		delete(p.feeds, feedURL)
+		return nil
+	}
+	p.recent.MoveToFront(element)
+	return entry.feed
+}
+
+// cache stores a fetched feed, evicting the least recently used feeds over
+// the cap
+func (p *RSSProvider) cache(feedURL string, feed *rssFeed) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry := &cachedFeed{url: feedURL, feed: feed, fetchedAt: time.Now()}
+	if element, ok := p.feeds[feedURL]; ok {
+		element.Value = entry
+		p.recent.MoveToFront(element)
+	} else {
+		p.feeds[feedURL] = p.recent.PushFront(entry)
+	}
+	for p.recent.Len() > p.maxFeeds {
+		oldest := p.recent.Back()
+		p.recent.Remove(oldest)
+		delete(p.feeds, oldest.Value.(*cachedFeed).url)
+	}
+}
+
+// findItem matches on GUID first, then on the audio URL ignoring tracking
+// query parameters, which podcast hosts often rotate, then on the title
+func findItem(items []rssItem, query Query) *rssItem {
+	if guid := strings.TrimSpace(query.GUID); guid != "" {
+		for i := range items {
+			if strings.TrimSpace(items[i].GUID) == guid {
+				return &items[i]
+			}
+		}
+	}
+	if query.SourceURL != "" {
+		source := stripQuery(query.SourceURL)
+		for i := range items {
+			if items[i].Enclosure.URL != "" && stripQuery(items[i].Enclosure.URL) == source {
+				return &items[i]
+			}
+		}
+	}
+	if title := strings.TrimSpace(query.Title); title != "" {
+		for i := range items {
+			if strings.TrimSpace(items[i].Title) == title {
+				return &items[i]
+			}
+		}
+	}
+	return nil
+}
+
+func stripQuery(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return rawURL
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+func parsePubDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range pubDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// imageHref returns the first image with an href; plain RSS <image> elements
+// (which use <url> instead) are skipped
+func imageHref(images []rssImage) string {
+	for _, image := range images {
+		if href := strings.TrimSpace(image.Href); href != "" {
+			return href
+		}
+	}
+	return ""
+}
@@ -0,0 +1,174 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const showFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+  <channel>
+    <title>Trail Talk</title>
+    <itunes:image href="https://example.com/show.jpg"/>
+    <item>
+      <title>Episode 2</title>
+      <guid isPermaLink="false">trail-talk-2</guid>
+      <pubDate>Tue, 03 Jun 2025 09:00:00 +0000</pubDate>
+      <description>Hill repeats</description>
+      <itunes:image href="https://example.com/ep2.jpg"/>
+      <enclosure url="https://cdn.example.com/ep2.mp3?token=abc" type="audio/mpeg" length="1"/>
+    </item>
+    <item>
+      <title>Episode 1</title>
+      <guid>trail-talk-1</guid>
+      <pubDate>Tue, 27 May 2025 09:00:00 GMT</pubDate>
+      <description>Getting started</description>
+      <enclosure url="https://cdn.example.com/ep1.mp3" type="audio/mpeg" length="1"/>
+    </item>
+  </channel>
+</rss>`
+
+func TestRSSProviderLookup(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(showFeed))
+	}))
+	defer server.Close()
+
+	provider := NewRSSProvider(server.Client())
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		query     Query
+		wantTitle string
+		wantImage string
+		wantDate  time.Time
+		wantAudio string
+	}{
+		{
+			name:      "by guid",
+			query:     Query{FeedURL: server.URL, GUID: "trail-talk-2"},
+			wantTitle: "Episode 2",
+			wantImage: "https://example.com/ep2.jpg",
+			wantDate:  time.Date(2025, 6, 3, 9, 0, 0, 0, time.UTC),
+			wantAudio: "https://cdn.example.com/ep2.mp3?token=abc",
+		},
+		{
+			name:      "by source url with rotated query",
+			query:     Query{FeedURL: server.URL, SourceURL: "https://cdn.example.com/ep2.mp3?token=xyz"},
+			wantTitle: "Episode 2",
+			wantImage: "https://example.com/ep2.jpg",
+			wantDate:  time.Date(2025, 6, 3, 9, 0, 0, 0, time.UTC),
+			wantAudio: "https://cdn.example.com/ep2.mp3?token=abc",
+		},
+		{
+			name:      "unknown guid falls back to source url and show image",
+			query:     Query{FeedURL: server.URL, GUID: "missing", SourceURL: "https://cdn.example.com/ep1.mp3"},
+			wantTitle: "Episode 1",
+			wantImage: "https://example.com/show.jpg",
+			wantDate:  time.Date(2025, 5, 27, 9, 0, 0, 0, time.UTC),
+			wantAudio: "https://cdn.example.com/ep1.mp3",
+		},
+		{
+			name:      "by title",
+			query:     Query{FeedURL: server.URL, Title: "Episode 1"},
+			wantTitle: "Episode 1",
+			wantImage: "https://example.com/show.jpg",
+			wantDate:  time.Date(2025, 5, 27, 9, 0, 0, 0, time.UTC),
+			wantAudio: "https://cdn.example.com/ep1.mp3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			episode, err := provider.Lookup(ctx, tt.query)
+			if err != nil {
+				t.Fatalf("Lookup() unexpected error: %v", err)
+			}
+			if episode == nil {
+				t.Fatal("Lookup() returned no episode")
+			}
+			if episode.Title != tt.wantTitle {
+				t.Errorf("Title = %q, want %q", episode.Title, tt.wantTitle)
+			}
+			if episode.Show != "Trail Talk" {
+				t.Errorf("Show = %q, want %q", episode.Show, "Trail Talk")
+			}
+			if episode.Image != tt.wantImage {
+				t.Errorf("Image = %q, want %q", episode.Image, tt.wantImage)
+			}
+			if episode.AudioURL != tt.wantAudio {
+				t.Errorf("AudioURL = %q, want %q", episode.AudioURL, tt.wantAudio)
+			}
+			if !episode.PubDate.Equal(tt.wantDate) {
+				t.Errorf("PubDate = %v, want %v", episode.PubDate, tt.wantDate)
+			}
+		})
+	}
+
+	if requests != 1 {
+		t.Errorf("Expected the show feed to be fetched once, got %d requests", requests)
+	}
+
+	episode, err := provider.Lookup(ctx, Query{FeedURL: server.URL, GUID: "missing"})
+	if err != nil || episode != nil {
+		t.Errorf("Lookup() for unknown episode = %v, %v; want nil, nil", episode, err)
+	}
+}
+
+func TestRSSProviderLookupFeedError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	provider := NewRSSProvider(server.Client())
+	if _, err := provider.Lookup(context.Background(), Query{FeedURL: server.URL, GUID: "x"}); err == nil {
+		t.Error("Expected an error for a missing show feed")
+	}
+}
+
+func TestRSSProviderCacheEviction(t *testing.T) {
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		w.Write([]byte(showFeed))
+	}))
+	defer server.Close()
+
+	provider := NewRSSProvider(server.Client())
+	provider.maxFeeds = 2
+	ctx := context.Background()
+	lookup := func(show string) {
+		t.Helper()
+		if _, err := provider.Lookup(ctx, Query{FeedURL: server.URL + "/" + show, GUID: "trail-talk-1"}); err != nil {
+			t.Fatalf("Lookup() unexpected error: %v", err)
+		}
+	}
+
+	lookup("a")
+	lookup("b")
+	lookup("a") // b is now the least recently used
+	lookup("c")
+	lookup("a")
+	lookup("b")
+
+	if requests["/a"] != 1 || requests["/b"] != 2 || requests["/c"] != 1 {
+		t.Errorf("Expected only the least recently used feed to be evicted, got requests %v", requests)
+	}
+	if len(provider.feeds) != 2 || provider.recent.Len() != 2 {
+		t.Errorf("Expected the cache to hold 2 feeds, got %d", len(provider.feeds))
+	}
+
+	// Expired feeds are dropped when next read
+	provider.feeds[server.URL+"/a"].Value.(*cachedFeed).fetchedAt = time.Now().Add(-FeedCacheTTL)
+	lookup("a")
+	if requests["/a"] != 2 {
+		t.Errorf("Expected an expired feed to be fetched again, got %d requests", requests["/a"])
+	}
+}
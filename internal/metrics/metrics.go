@@ -0,0 +1,32 @@
+// Package metrics holds the process's own measurements, such as request
+// latencies, and serves them to Prometheus from Handler. Collectors are
+// registered with Registry once, usually as package variables:
+//
+//	var requestLatency = promauto.With(metrics.Registry).NewHistogramVec(...)
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// LatencyBuckets are upper bounds in seconds suiting request latencies, from
+// 5ms to 10s
+var LatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Registry holds the collectors Handler serves, along with the Go runtime's
+// and the process's own
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+}
+
+// Handler serves every registered collector. It belongs on an internal
+// listener, not the public API.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,151 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cobblepod/internal/queue"
+)
+
+// Notification is an event about one of a user's jobs
+type Notification struct {
+	Event queue.NotificationEvent
+	Job   *queue.Job
+}
+
+// Message returns the title and plain text body of a notification
+func (n Notification) Message() (title, body string) {
+	if n.Event == queue.NotifyFeedUpdated {
+		return "Cobblepod: feed updated", fmt.Sprintf("Your feed was updated.\n\nYour feed: %s\n", n.Job.FeedURL)
+	}
+	return Summary(n.Job)
+}
+
+// Notifier delivers notifications over one channel
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// EmailNotifier emails notifications to an address
+type EmailNotifier struct {
+	Mailer *Mailer
+	To     string
+}
+
+// Notify implements Notifier
+func (e *EmailNotifier) Notify(ctx context.Context, n Notification) error {
+	title, body := n.Message()
+	return e.Mailer.Send(e.To, title, body)
+}
+
+// WebhookPayload is the JSON body POSTed to a user's webhook
+type WebhookPayload struct {
+	Event      queue.NotificationEvent `json:"event"`
+	JobID      string                  `json:"job_id"`
+	Status     string                  `json:"status"`
+	Processed  int                     `json:"processed"`
+	Reused     int                     `json:"reused"`
+	Failed     int                     `json:"failed"`
+	FailReason string                  `json:"fail_reason,omitempty"`
+	FeedURL    string                  `json:"feed_url,omitempty"`
+	Timestamp  time.Time               `json:"timestamp"`
+}
+
+// WebhookNotifier POSTs a WebhookPayload to a URL
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// Notify implements Notifier
+func (w *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	payload, err := json.Marshal(WebhookPayload{
+		Event:      n.Event,
+		JobID:      n.Job.ID,
+		Status:     n.Job.Status,
+		Processed:  n.Job.Completed,
+		Reused:     n.Job.Skipped,
+		Failed:     n.Job.Failed,
+		FailReason: n.Job.FailReason,
+		FeedURL:    n.Job.FeedURL,
+		Timestamp:  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return post(ctx, w.Client, w.URL, "application/json", payload, nil)
+}
+
+// TelegramNotifier sends notifications to a chat through a Telegram bot.
+// APIURL is the bot's API endpoint, https://api.telegram.org/bot<token>.
+type TelegramNotifier struct {
+	APIURL string
+	ChatID string
+	Client *http.Client
+}
+
+// Notify implements Notifier
+func (t *TelegramNotifier) Notify(ctx context.Context, n Notification) error {
+	title, body := n.Message()
+	payload, err := json.Marshal(map[string]string{
+		"chat_id": t.ChatID,
+		"text":    title + "\n\n" + body,
+	})
+	if err != nil {
+		return err
+	}
+	return post(ctx, t.Client, t.APIURL+"/sendMessage", "application/json", payload, nil)
+}
+
+// NtfyNotifier publishes notifications to an ntfy topic URL
+type NtfyNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// Notify implements Notifier
+func (t *NtfyNotifier) Notify(ctx context.Context, n Notification) error {
+	title, body := n.Message()
+	headers := map[string]string{"Title": headerValue(title)}
+	if n.Event == queue.NotifyJobFailed {
+		headers["Priority"] = "high"
+	}
+	if n.Job.FeedURL != "" {
+		headers["Click"] = n.Job.FeedURL
+	}
+	return post(ctx, t.Client, t.URL, "text/plain; charset=utf-8", []byte(body), headers)
+}
+
+// post sends body to url and fails on any non-2xx response
+func post(ctx context.Context, client *http.Client, url, contentType string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "cobblepod")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// The error includes the URL, which for Telegram carries the bot token
+		return fmt.Errorf("notification request failed: %w", redact(err, url))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notification rejected: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// redact strips url from err so secrets in it don't reach the logs
+func redact(err error, url string) error {
+	return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), url, "[redacted]"))
+}
@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"cobblepod/internal/config"
+	"cobblepod/internal/queue"
+	"cobblepod/internal/safehttp"
+)
+
+// requestTimeout bounds a single webhook, Telegram or ntfy request
+const requestTimeout = 10 * time.Second
+
+// Dispatcher sends a user's notifications over every channel in their settings
+type Dispatcher struct {
+	mailer *Mailer
+	client *http.Client
+	// webhookClient posts to the URLs users set, and only reaches public addresses
+	webhookClient *http.Client
+	telegramURL   string // Bot API endpoint; Telegram is off when empty
+	ntfyServer    string
+	// email receives summaries for users who haven't set their own address
+	email string
+}
+
+// NewDispatcher creates a dispatcher for cfg's Telegram bot and ntfy server. A
+// nil mailer turns email off.
+func NewDispatcher(mailer *Mailer, cfg config.NotificationConfig) *Dispatcher {
+	d := &Dispatcher{
+		mailer:        mailer,
+		client:        &http.Client{Timeout: requestTimeout},
+		webhookClient: safehttp.NewClient(requestTimeout),
+		ntfyServer:    cfg.NtfyServer,
+		email:         cfg.Email,
+	}
+	if cfg.TelegramBotToken != "" {
+		d.telegramURL = "https://api.telegram.org/bot" + cfg.TelegramBotToken
+	}
+	return d
+}
+
+// NewDispatcherWithEndpoints creates a dispatcher with injected endpoints for testing
+func NewDispatcherWithEndpoints(mailer *Mailer, client *http.Client, telegramURL, ntfyServer string) *Dispatcher {
+	return &Dispatcher{mailer: mailer, client: client, webhookClient: client, telegramURL: telegramURL, ntfyServer: ntfyServer}
+}
+
+// Channels returns a notifier for each channel the user has set up
+func (d *Dispatcher) Channels(settings *queue.UserSettings) []Notifier {
+	var notifiers []Notifier
+	if to := settings.NotificationRecipient(d.email); to != "" && d.mailer != nil {
+		notifiers = append(notifiers, &EmailNotifier{Mailer: d.mailer, To: to})
+	}
+	if settings.WebhookURL != "" {
+		notifiers = append(notifiers, &WebhookNotifier{URL: settings.WebhookURL, Client: d.webhookClient})
+	}
+	if settings.TelegramChatID != "" && d.telegramURL != "" {
+		notifiers = append(notifiers, &TelegramNotifier{APIURL: d.telegramURL, ChatID: settings.TelegramChatID, Client: d.client})
+	}
+	if settings.NtfyTopic != "" && d.ntfyServer != "" {
+		notifiers = append(notifiers, &NtfyNotifier{URL: strings.TrimSuffix(d.ntfyServer, "/") + "/" + settings.NtfyTopic, Client: d.client})
+	}
+	return notifiers
+}
+
+// JobFinished notifies the user that job completed or failed, and that their
+// feed was updated if it published one, for the events they subscribed to.
+// Every channel is tried; the errors of those that failed are joined.
+func (d *Dispatcher) JobFinished(ctx context.Context, settings *queue.UserSettings, job *queue.Job) error {
+	notifiers := d.Channels(settings)
+	if len(notifiers) == 0 {
+		return nil
+	}
+
+	events := []queue.NotificationEvent{queue.NotifyJobCompleted}
+	if job.Status == queue.JobStatusFailed {
+		events[0] = queue.NotifyJobFailed
+	}
+	if job.FeedURL != "" {
+		events = append(events, queue.NotifyFeedUpdated)
+	}
+
+	var errs []error
+	for _, event := range events {
+		if !settings.Notifies(event) {
+			continue
+		}
+		for _, notifier := range notifiers {
+			if err := notifier.Notify(ctx, Notification{Event: event, Job: job}); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"sync"
+	"testing"
+
+	"cobblepod/internal/queue"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// request is an HTTP request received by the fake channel server
+type request struct {
+	path    string
+	headers http.Header
+	body    string
+}
+
+// channelServer records every request made to it
+func channelServer(t *testing.T, status int) (*httptest.Server, func() []request) {
+	var mu sync.Mutex
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, request{path: r.URL.Path, headers: r.Header, body: string(body)})
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []request {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func TestDispatcherJobFinished(t *testing.T) {
+	server, requests := channelServer(t, http.StatusOK)
+	var emails []string
+	mailer := NewMailerWithSender("smtp.example.com:587", "cobblepod@example.com", nil, func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		emails = append(emails, to[0])
+		return nil
+	})
+	dispatcher := NewDispatcherWithEndpoints(mailer, server.Client(), server.URL+"/bot-token", server.URL)
+
+	settings := &queue.UserSettings{
+		NotificationEmail: "runner@example.com",
+		WebhookURL:        server.URL + "/hook",
+		TelegramChatID:    "12345",
+		NtfyTopic:         "cobblepod-runner",
+		NotifyEvents:      "job.completed,feed.updated",
+	}
+	job := &queue.Job{ID: "job-1", Status: queue.JobStatusCompleted, Completed: 2, Skipped: 1, FeedURL: "https://example.com/feed.xml"}
+	assert.NoError(t, dispatcher.JobFinished(context.Background(), settings, job))
+
+	// job.completed and feed.updated over three HTTP channels, plus two emails
+	assert.Equal(t, []string{"runner@example.com", "runner@example.com"}, emails)
+	received := requests()
+	if !assert.Len(t, received, 6) {
+		return
+	}
+	// The first of each channel's two requests is the job.completed one
+	first := map[string]request{}
+	for _, r := range received {
+		if _, ok := first[r.path]; !ok {
+			first[r.path] = r
+		}
+	}
+	assert.Len(t, first, 3)
+
+	var payload WebhookPayload
+	assert.NoError(t, json.Unmarshal([]byte(first["/hook"].body), &payload))
+	assert.Equal(t, queue.NotifyJobCompleted, payload.Event)
+	assert.Equal(t, 2, payload.Processed)
+	assert.Equal(t, 1, payload.Reused)
+	assert.Equal(t, "https://example.com/feed.xml", payload.FeedURL)
+
+	var message map[string]string
+	assert.NoError(t, json.Unmarshal([]byte(first["/bot-token/sendMessage"].body), &message))
+	assert.Equal(t, "12345", message["chat_id"])
+
+	assert.Equal(t, "Cobblepod: job-1 finished", first["/cobblepod-runner"].headers.Get("Title"))
+	assert.Equal(t, "https://example.com/feed.xml", first["/cobblepod-runner"].headers.Get("Click"))
+}
+
+func TestDispatcherDefaultEvents(t *testing.T) {
+	server, requests := channelServer(t, http.StatusOK)
+	dispatcher := NewDispatcherWithEndpoints(nil, server.Client(), "", server.URL)
+	settings := &queue.UserSettings{NtfyTopic: "topic", TelegramChatID: "12345"}
+
+	// Feed updates are opt-in, and Telegram is off without a bot token
+	job := &queue.Job{ID: "job-1", Status: queue.JobStatusCompleted, FeedURL: "https://example.com/feed.xml"}
+	assert.NoError(t, dispatcher.JobFinished(context.Background(), settings, job))
+	assert.Len(t, requests(), 1)
+
+	job = &queue.Job{ID: "job-2", Status: queue.JobStatusFailed, FailReason: "insufficient storage space"}
+	assert.NoError(t, dispatcher.JobFinished(context.Background(), settings, job))
+	if received := requests(); assert.Len(t, received, 2) {
+		assert.Equal(t, "high", received[1].headers.Get("Priority"))
+		assert.Contains(t, received[1].body, "insufficient storage space")
+	}
+}
+
+func TestDispatcherChannelFailure(t *testing.T) {
+	server, requests := channelServer(t, http.StatusBadGateway)
+	dispatcher := NewDispatcherWithEndpoints(nil, server.Client(), server.URL+"/bot-secret", server.URL)
+	settings := &queue.UserSettings{WebhookURL: server.URL + "/hook", TelegramChatID: "12345"}
+
+	// A failing channel doesn't stop the others
+	err := dispatcher.JobFinished(context.Background(), settings, &queue.Job{ID: "job-1", Status: queue.JobStatusCompleted})
+	assert.ErrorContains(t, err, "HTTP 502")
+	assert.Len(t, requests(), 2)
+
+	server.Close()
+	err = dispatcher.JobFinished(context.Background(), settings, &queue.Job{ID: "job-1", Status: queue.JobStatusCompleted})
+	if assert.Error(t, err) {
+		assert.NotContains(t, err.Error(), "bot-secret")
+	}
+}
+
+func TestDispatcherWithoutChannels(t *testing.T) {
+	dispatcher := NewDispatcherWithEndpoints(nil, http.DefaultClient, "", "")
+	assert.Empty(t, dispatcher.Channels(&queue.UserSettings{NotificationEmail: "runner@example.com", NtfyTopic: "topic"}))
+}
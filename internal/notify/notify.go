@@ -0,0 +1,113 @@
+// Package notify tells users when their jobs finish and their feed changes,
+// through email or any of the channels in channels.go
+package notify
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cobblepod/internal/config"
+	"cobblepod/internal/queue"
+)
+
+// SendFunc delivers a message through an SMTP server, see smtp.SendMail
+type SendFunc func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+
+// Mailer sends job summaries through an SMTP server
+type Mailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+	send SendFunc
+}
+
+// NewMailer returns a mailer for cfg's SMTP server, or nil when its host is
+// unset and email notifications are off
+func NewMailer(cfg config.NotificationConfig) *Mailer {
+	if cfg.SMTPHost == "" {
+		return nil
+	}
+	var auth smtp.Auth
+	if cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return NewMailerWithSender(net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)), cfg.SMTPFrom, auth, smtp.SendMail)
+}
+
+// NewMailerWithSender creates a mailer with an injected sender for testing
+func NewMailerWithSender(addr, from string, auth smtp.Auth, send SendFunc) *Mailer {
+	return &Mailer{addr: addr, from: from, auth: auth, send: send}
+}
+
+// Send emails a plain text message
+func (m *Mailer) Send(to, subject, body string) error {
+	if err := m.send(m.addr, m.auth, m.from, []string{to}, message(m.from, to, subject, body)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// Summary returns the subject and plain text body describing a finished job:
+// how many episodes were processed, reused and failed, and the feed URL
+func Summary(job *queue.Job) (subject, body string) {
+	name := job.Label
+	if name == "" {
+		name = job.Filename
+	}
+	if name == "" {
+		name = job.ID
+	}
+
+	var b strings.Builder
+	switch job.Status {
+	case queue.JobStatusFailed:
+		subject = fmt.Sprintf("Cobblepod: %s failed", name)
+		fmt.Fprintf(&b, "Processing %s failed: %s\n\n", name, job.FailReason)
+	case queue.JobStatusCompletedWithErrors:
+		subject = fmt.Sprintf("Cobblepod: %s finished with %d failed episodes", name, job.Failed)
+		fmt.Fprintf(&b, "Finished processing %s, but some episodes failed.\n\n", name)
+	default:
+		subject = fmt.Sprintf("Cobblepod: %s finished", name)
+		fmt.Fprintf(&b, "Finished processing %s.\n\n", name)
+	}
+
+	fmt.Fprintf(&b, "Processed: %d\n", job.Completed)
+	fmt.Fprintf(&b, "Reused:    %d\n", job.Skipped)
+	fmt.Fprintf(&b, "Failed:    %d\n", job.Failed)
+
+	if job.Failed > 0 {
+		b.WriteString("\nFailed episodes:\n")
+		for _, item := range job.Items {
+			if item.Status == queue.StatusFailed {
+				fmt.Fprintf(&b, "- %s: %s\n", item.Title, item.Error)
+			}
+		}
+	}
+	if job.FeedURL != "" {
+		fmt.Fprintf(&b, "\nYour feed: %s\n", job.FeedURL)
+	}
+	return subject, b.String()
+}
+
+// message builds an RFC 5322 plain text email
+func message(from, to, subject, body string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", headerValue(from))
+	fmt.Fprintf(&b, "To: %s\r\n", headerValue(to))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue(subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// headerValue strips line breaks so user supplied text (e.g. job labels) can't
+// inject headers
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
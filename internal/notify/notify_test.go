@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"cobblepod/internal/queue"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummary(t *testing.T) {
+	job := &queue.Job{
+		ID:        "job-1",
+		Filename:  "podcast_addict.backup",
+		Status:    queue.JobStatusCompletedWithErrors,
+		Completed: 3,
+		Skipped:   2,
+		Failed:    1,
+		FeedURL:   "https://example.com/feed.xml",
+		Items: []queue.JobItem{
+			{Title: "Episode 1", Status: queue.StatusCompleted},
+			{Title: "Episode 2", Status: queue.StatusFailed, Error: "download failed: 404"},
+		},
+	}
+
+	subject, body := Summary(job)
+	assert.Equal(t, "Cobblepod: podcast_addict.backup finished with 1 failed episodes", subject)
+	assert.Contains(t, body, "Processed: 3\n")
+	assert.Contains(t, body, "Reused:    2\n")
+	assert.Contains(t, body, "- Episode 2: download failed: 404\n")
+	assert.NotContains(t, body, "Episode 1")
+	assert.Contains(t, body, "Your feed: https://example.com/feed.xml")
+
+	job = &queue.Job{ID: "job-2", Label: "Weekly", Status: queue.JobStatusFailed, FailReason: "insufficient storage space"}
+	subject, body = Summary(job)
+	assert.Equal(t, "Cobblepod: Weekly failed", subject)
+	assert.Contains(t, body, "Processing Weekly failed: insufficient storage space")
+	assert.NotContains(t, body, "Your feed")
+}
+
+func TestEmailNotifier(t *testing.T) {
+	var sentTo []string
+	var sent string
+	mailer := NewMailerWithSender("smtp.example.com:587", "cobblepod@example.com", nil, func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		sentTo, sent = to, string(msg)
+		return nil
+	})
+
+	job := &queue.Job{ID: "job-1", Label: "Evil\r\nBcc: victim@example.com", Status: queue.JobStatusCompleted, Completed: 1}
+	notifier := &EmailNotifier{Mailer: mailer, To: "user@example.com"}
+	assert.NoError(t, notifier.Notify(context.Background(), Notification{Event: queue.NotifyJobCompleted, Job: job}))
+	assert.Equal(t, []string{"user@example.com"}, sentTo)
+
+	headers, body, _ := strings.Cut(sent, "\r\n\r\n")
+	assert.Contains(t, headers, "To: user@example.com\r\n")
+	assert.Contains(t, headers, "Subject: Cobblepod: Evil  Bcc: victim@example.com finished\r\n")
+	assert.NotContains(t, headers, "\r\nBcc:")
+	assert.Contains(t, body, "Processed: 1\r\n")
+
+	failing := NewMailerWithSender("smtp.example.com:587", "cobblepod@example.com", nil, func(string, smtp.Auth, string, []string, []byte) error {
+		return errors.New("connection refused")
+	})
+	assert.Error(t, failing.Send("user@example.com", "subject", "body"))
+}
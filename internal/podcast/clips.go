@@ -0,0 +1,42 @@
+package podcast
+
+import (
+	"time"
+
+	"cobblepod/internal/storage"
+)
+
+// MaxClipDuration caps the length of an intro or outro clip
+const MaxClipDuration = time.Minute
+
+// Clip is a short recording played before or after every episode of a feed
+type Clip string
+
+const (
+	ClipIntro Clip = "intro"
+	ClipOutro Clip = "outro"
+)
+
+// Clips lists the clips a feed can have, in the order they play
+var Clips = []Clip{ClipIntro, ClipOutro}
+
+// ParseClip returns the clip called name
+func ParseClip(name string) (Clip, bool) {
+	for _, clip := range Clips {
+		if string(clip) == name {
+			return clip, true
+		}
+	}
+	return "", false
+}
+
+// Filename is the name the clip is stored under in the feed folder. It has no audio
+// extension, so garbage collection never mistakes it for an episode.
+func (c Clip) Filename() string {
+	return "playrun_addict_" + string(c)
+}
+
+// Query is the query used to search for the clip in the feed kept in folders
+func (c Clip) Query(folders Folders) storage.Query {
+	return storage.Query{ExactName: c.Filename(), Folder: folders.Feed}
+}
@@ -4,20 +4,54 @@ import (
 	"encoding/xml"
 	"fmt"
 	"log/slog"
+	"path"
 	"strconv"
 	"time"
 
-	"cobblepod/internal/config"
 	"cobblepod/internal/queue"
 	"cobblepod/internal/storage"
+	"cobblepod/internal/transcribe"
 )
 
+// RSSFilename is the name the generated RSS feed is stored under
+const RSSFilename = "playrun_addict.xml"
+
+// Folders are the storage folders users' feeds are kept in, under the
+// deployment's top-level folder (StorageConfig.DriveFolder in package config)
+type Folders struct {
+	// Feed holds the feed and its episodes
+	Feed string
+	// Archive holds episodes that dropped out of the feed until they're
+	// restored or purged, see WorkerConfig.ArchiveRetention in package config
+	Archive string
+}
+
+// NewFolders returns the feed folders under driveFolder
+func NewFolders(driveFolder string) Folders {
+	feed := path.Join(driveFolder, "playrun_addict")
+	return Folders{Feed: feed, Archive: path.Join(feed, "archive")}
+}
+
+// RSSQuery is the query used to search for the generated RSS feed in storage
+func (f Folders) RSSQuery() storage.Query {
+	return storage.Query{ExactName: RSSFilename, Folder: f.Feed}
+}
+
+// PlayrunNamespace is the XML namespace of the playrunaddict elements cobblepod
+// records its own episode state in
+const PlayrunNamespace = "http://playrunaddict.com/rss/1.0"
+
+// PodcastNamespace is the Podcasting 2.0 namespace, whose transcript element
+// podcast apps read episode transcripts from
+const PodcastNamespace = "https://podcastindex.org/namespace/1.0"
+
 // RSS represents the root RSS element
 type RSS struct {
 	XMLName xml.Name `xml:"rss"`
 	Version string   `xml:"version,attr"`
 	Xmlns   string   `xml:"xmlns:itunes,attr"`
 	Playrun string   `xml:"xmlns:playrunaddict,attr"`
+	Podcast string   `xml:"xmlns:podcast,attr"`
 	Channel Channel  `xml:"channel"`
 }
 
@@ -42,10 +76,49 @@ type Category struct {
 
 // Item represents an RSS item/episode
 type Item struct {
-	Title            string    `xml:"title"`
-	GUID             GUID      `xml:"guid"`
-	OriginalDuration string    `xml:"originalduration"`
-	Enclosure        Enclosure `xml:"enclosure"`
+	Title            string       `xml:"title"`
+	GUID             GUID         `xml:"guid"`
+	PubDate          string       `xml:"pubDate,omitempty"`
+	Description      string       `xml:"description,omitempty"`
+	Image            *ItunesImage `xml:"itunes:image,omitempty"`
+	OriginalDuration string       `xml:"originalduration"`
+	ListedDuration   string       `xml:"listedduration,omitempty"` // Set when the playlist listed another duration than the source has
+	DroppedAt        string       `xml:"droppedat,omitempty"`      // RFC 3339, set while a dropped episode is retained
+	Speed            string       `xml:"speed,omitempty"`          // Playback speed the audio was processed at
+	Encoding         string       `xml:"encoding,omitempty"`       // Bitrate, channels and clips the audio was encoded with
+	FileSize         string       `xml:"filesize,omitempty"`       // Bytes
+	Skipped          string       `xml:"skipped,omitempty"`        // Source audio skip rules left out
+	// SourceGUID and SourceURL identify the episode in its show's feed, see EpisodeKey
+	SourceGUID string `xml:"sourceguid,omitempty"`
+	SourceURL  string `xml:"sourceurl,omitempty"`
+	// Offset is the listening position the episode was last published from, in milliseconds
+	Offset     string      `xml:"playrunaddict:offset,omitempty"`
+	Enclosure  Enclosure   `xml:"enclosure"`
+	Transcript *Transcript `xml:"podcast:transcript,omitempty"`
+}
+
+// UnmarshalXML reads an item. The decoder resolves prefixes to namespaces, so
+// the prefixed names Offset and Transcript are written with never match when
+// reading.
+func (i *Item) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type item Item
+	var parsed struct {
+		item
+		Offset     string      `xml:"http://playrunaddict.com/rss/1.0 offset"`
+		Transcript *Transcript `xml:"https://podcastindex.org/namespace/1.0 transcript"`
+	}
+	if err := d.DecodeElement(&parsed, &start); err != nil {
+		return err
+	}
+	*i = Item(parsed.item)
+	i.Offset = parsed.Offset
+	i.Transcript = parsed.Transcript
+	return nil
+}
+
+// ItunesImage represents episode artwork
+type ItunesImage struct {
+	Href string `xml:"href,attr"`
 }
 
 // GUID represents the episode GUID
@@ -54,6 +127,12 @@ type GUID struct {
 	Value       string `xml:",chardata"`
 }
 
+// Transcript links an episode's transcript
+type Transcript struct {
+	URL  string `xml:"url,attr"`
+	Type string `xml:"type,attr"`
+}
+
 // Enclosure represents the audio enclosure
 type Enclosure struct {
 	URL    string `xml:"url,attr"`
@@ -61,63 +140,192 @@ type Enclosure struct {
 	Length string `xml:"length,attr"`
 }
 
+// defaultEnclosureType is used for episodes that don't record a content type,
+// which covers everything uploaded before output formats were configurable
+const defaultEnclosureType = "audio/mpeg"
+
 // RSSProcessor handles RSS feed generation and processing
 type RSSProcessor struct {
 	channelTitle string
 	drive        storage.Storage
+	folders      Folders
+	location     *time.Location
 }
 
-// ProcessedEpisode represents a processed audio episode
+// ProcessedEpisode represents a processed audio episode. Durations are
+// time.Durations throughout; the feed records them in milliseconds, see
+// createItemFromFile and ExtractEpisodeMapping.
 type ProcessedEpisode struct {
-	Title            string        `json:"title"`
+	Title string `json:"title"`
+	// OriginalURL and SourceGUID are the source episode's enclosure URL and GUID
 	OriginalURL      string        `json:"original_url,omitempty"`
-	OriginalDuration time.Duration `json:"original_duration"` // Duration in milliseconds
-	NewDuration      time.Duration `json:"new_duration"`      // Duration in milliseconds
-	UUID             string        `json:"uuid"`
-	Speed            float64       `json:"speed"`
-	DownloadURL      string        `json:"download_url,omitempty"`
-	OriginalGUID     string        `json:"original_guid,omitempty"`
-	TempFile         string        `json:"temp_file,omitempty"`
-	DriveFileID      string        `json:"drive_file_id,omitempty"`
+	SourceGUID       string        `json:"source_guid,omitempty"`
+	OriginalDuration time.Duration `json:"original_duration"` // Length of the source
+	NewDuration      time.Duration `json:"new_duration"`      // Length once processed, see ProcessedDuration
+	// ListedDuration is what the playlist said the source lasts, when that isn't OriginalDuration
+	ListedDuration time.Duration `json:"listed_duration,omitempty"`
+	UUID           string        `json:"uuid"`
+	Speed          float64       `json:"speed"`
+	DownloadURL    string        `json:"download_url,omitempty"`
+	OriginalGUID   string        `json:"original_guid,omitempty"`
+	TempFile       string        `json:"temp_file,omitempty"`
+	DriveFileID    string        `json:"drive_file_id,omitempty"`
+	ContentType    string        `json:"content_type,omitempty"` // Enclosure type; defaults to audio/mpeg
+	// Metadata from the show's own feed, see metadata.Provider
+	PubDate     time.Time `json:"pub_date,omitzero"`
+	Description string    `json:"description,omitempty"`
+	Image       string    `json:"image,omitempty"`
+	// DroppedAt is when the episode left the playlist, for episodes kept in the
+	// feed by the user's retention setting
+	DroppedAt time.Time `json:"dropped_at,omitzero"`
+	// Position is the episode's place in the playlist, see queue.JobItem
+	Position int `json:"position,omitempty"`
+	// Offset is the listening position the episode was processed from
+	Offset time.Duration `json:"offset,omitempty"`
+	// Encoding is the bitrate and channels the audio was encoded with, see
+	// queue.UserSettings.Encoding, followed by the intro and outro clips joined to it
+	Encoding string `json:"encoding,omitempty"`
+	// Size is the size of the audio file in bytes, 0 when unknown
+	Size int64 `json:"size,omitempty"`
+	// Skipped is how much of the source after Offset skip rules left out
+	Skipped time.Duration `json:"skipped,omitempty"`
+	// TranscriptURL links the episode's WebVTT transcript, if it has one
+	TranscriptURL string `json:"transcript_url,omitempty"`
 }
 
 // ExistingEpisode represents an episode from existing RSS feed or backup data
 type ExistingEpisode struct {
+	Title            string        `json:"title"`
+	SourceGUID       string        `json:"source_guid,omitempty"`
+	SourceURL        string        `json:"source_url,omitempty"`
 	DownloadURL      string        `json:"download_url"`
-	Duration         time.Duration `json:"length"`            // Duration accounting for speed and offset
-	OriginalDuration time.Duration `json:"original_duration"` // Unmodified duration of the existing episode
+	Duration         time.Duration `json:"length"`                    // Duration accounting for speed and offset
+	OriginalDuration time.Duration `json:"original_duration"`         // Unmodified duration of the existing episode
+	ListedDuration   time.Duration `json:"listed_duration,omitempty"` // Duration the playlist listed, when it differed
 	OriginalGUID     string        `json:"original_guid,omitempty"`
+	ContentType      string        `json:"content_type,omitempty"`
+	DroppedAt        time.Time     `json:"dropped_at,omitzero"` // Zero unless the episode is being retained
+	Speed            float64       `json:"speed,omitempty"`     // Zero for episodes published before speeds were recorded
+	Offset           time.Duration `json:"offset,omitempty"`    // Listening position the episode was processed from
+	Encoding         string        `json:"encoding,omitempty"`  // Bitrate, channels and clips, see ProcessedEpisode
+	Size             int64         `json:"size,omitempty"`      // Bytes, 0 when unknown
+	Skipped          time.Duration `json:"skipped,omitempty"`   // Source audio skip rules left out
+	TranscriptURL    string        `json:"transcript_url,omitempty"`
+}
+
+// Listed returns the duration the playlist listed the episode's source with
+func (e ExistingEpisode) Listed() time.Duration {
+	if e.ListedDuration > 0 {
+		return e.ListedDuration
+	}
+	return e.OriginalDuration
 }
 
-// NewRSSProcessor creates a new RSS processor
-func NewRSSProcessor(channelTitle string, driveService storage.Storage) *RSSProcessor {
-	return &RSSProcessor{channelTitle: channelTitle, drive: driveService}
+// EpisodeKey identifies an episode across runs by its GUID in the show's feed,
+// else its source enclosure URL, else its title. Titles alone collide between
+// shows, but are all that feeds written before sources were recorded have.
+func EpisodeKey(guid, sourceURL, title string) string {
+	switch {
+	case guid != "":
+		return "guid:" + guid
+	case sourceURL != "":
+		return "url:" + sourceURL
+	default:
+		return "title:" + title
+	}
+}
+
+// FindEpisode returns the key and episode of an episode mapping that item
+// was published as. Episodes recorded without a source only match by title.
+func FindEpisode(episodeMapping map[string]ExistingEpisode, item queue.JobItem) (string, ExistingEpisode, bool) {
+	var keys []string
+	if item.GUID != "" {
+		keys = append(keys, EpisodeKey(item.GUID, "", ""))
+	}
+	if item.SourceURL != "" {
+		keys = append(keys, EpisodeKey("", item.SourceURL, ""))
+	}
+	keys = append(keys, EpisodeKey("", "", item.Title))
+
+	for _, key := range keys {
+		if episode, ok := episodeMapping[key]; ok {
+			return key, episode, true
+		}
+	}
+	return "", ExistingEpisode{}, false
+}
+
+// NewRSSProcessor creates a new RSS processor for the feed kept in folders
+func NewRSSProcessor(channelTitle string, driveService storage.Storage, folders Folders) *RSSProcessor {
+	return &RSSProcessor{channelTitle: channelTitle, drive: driveService, folders: folders, location: time.UTC}
+}
+
+// SetLocation sets the time zone feed dates are rendered in
+func (p *RSSProcessor) SetLocation(loc *time.Location) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	p.location = loc
 }
 
 // CreateRSSXML generates RSS XML from processed files
 func (p *RSSProcessor) CreateRSSXML(processedFiles []ProcessedEpisode) string {
+	items := make([]Item, 0, len(processedFiles))
+	for _, fileData := range processedFiles {
+		items = append(items, p.createItemFromFile(fileData))
+	}
+	return p.marshalRSS(items)
+}
+
+// RebuildRSSXML regenerates an existing feed with the processor's channel
+// settings, keeping the items keep accepts as they are. It returns the new XML
+// and the number of items dropped.
+func (p *RSSProcessor) RebuildRSSXML(xmlContent string, keep func(Item) bool) (string, int, error) {
+	var rss RSS
+	if err := xml.Unmarshal([]byte(xmlContent), &rss); err != nil {
+		return "", 0, fmt.Errorf("failed to parse RSS XML: %w", err)
+	}
+
+	var items []Item
+	for _, item := range rss.Channel.Items {
+		if keep(item) {
+			items = append(items, item)
+		}
+	}
+	return p.marshalRSS(items), len(rss.Channel.Items) - len(items), nil
+}
+
+// AddToRSSXML returns an existing feed with episode appended, keeping its other
+// items as they are
+func (p *RSSProcessor) AddToRSSXML(xmlContent string, episode ProcessedEpisode) (string, error) {
+	var rss RSS
+	if err := xml.Unmarshal([]byte(xmlContent), &rss); err != nil {
+		return "", fmt.Errorf("failed to parse RSS XML: %w", err)
+	}
+	return p.marshalRSS(append(rss.Channel.Items, p.createItemFromFile(episode))), nil
+}
+
+// marshalRSS renders a feed holding the given items
+func (p *RSSProcessor) marshalRSS(items []Item) string {
 	rss := RSS{
 		Version: "2.0",
 		Xmlns:   "http://www.itunes.com/dtds/podcast-1.0.dtd",
-		Playrun: "http://playrunaddict.com/rss/1.0",
+		Playrun: PlayrunNamespace,
+		Podcast: PodcastNamespace,
 		Channel: Channel{
 			Title:         p.channelTitle,
 			Description:   "Custom podcast feed generated from processed audio files",
 			Link:          "https://example.com",
 			Language:      "en-us",
-			LastBuildDate: time.Now().UTC().Format(time.RFC1123Z),
+			LastBuildDate: time.Now().In(p.location).Format(time.RFC1123Z),
 			Author:        "Playrun Addict",
 			Summary:       "Custom podcast feed generated from processed audio files",
 			Category:      Category{Text: "Technology"},
 			Explicit:      "false",
+			Items:         items,
 		},
 	}
 
-	for _, fileData := range processedFiles {
-		item := p.createItemFromFile(fileData)
-		rss.Channel.Items = append(rss.Channel.Items, item)
-	}
-
 	xmlBytes, err := xml.MarshalIndent(rss, "", "  ")
 	if err != nil {
 		slog.Error("Error marshaling RSS XML", "error", err)
@@ -144,28 +352,87 @@ func (p *RSSProcessor) createItemFromFile(fileData ProcessedEpisode) Item {
 			downloadURL = p.drive.GenerateDownloadURL(driveFileID)
 		}
 	}
-	return Item{
+	contentType := fileData.ContentType
+	if contentType == "" {
+		contentType = defaultEnclosureType
+	}
+	item := Item{
 		Title:            title,
 		GUID:             GUID{IsPermaLink: "false", Value: guid},
+		Description:      fileData.Description,
 		OriginalDuration: strconv.FormatInt(originalDuration.Milliseconds(), 10),
-		Enclosure:        Enclosure{URL: downloadURL, Type: "audio/mpeg", Length: strconv.FormatInt(newDuration.Milliseconds(), 10)},
+		Enclosure:        Enclosure{URL: downloadURL, Type: contentType, Length: strconv.FormatInt(newDuration.Milliseconds(), 10)},
+	}
+	if !fileData.PubDate.IsZero() {
+		item.PubDate = fileData.PubDate.In(p.location).Format(time.RFC1123Z)
+	}
+	if fileData.Image != "" {
+		item.Image = &ItunesImage{Href: fileData.Image}
+	}
+	if !fileData.DroppedAt.IsZero() {
+		item.DroppedAt = fileData.DroppedAt.UTC().Format(time.RFC3339)
+	}
+	if listed := fileData.ListedDuration; listed > 0 && listed.Milliseconds() != originalDuration.Milliseconds() {
+		item.ListedDuration = strconv.FormatInt(listed.Milliseconds(), 10)
+	}
+	item.Encoding = fileData.Encoding
+	if fileData.Size > 0 {
+		item.FileSize = strconv.FormatInt(fileData.Size, 10)
 	}
+	if fileData.Offset > 0 {
+		item.Offset = strconv.FormatInt(fileData.Offset.Milliseconds(), 10)
+	}
+	if fileData.Skipped > 0 {
+		item.Skipped = strconv.FormatInt(fileData.Skipped.Milliseconds(), 10)
+	}
+	if fileData.Speed > 0 {
+		item.Speed = strconv.FormatFloat(fileData.Speed, 'f', -1, 64)
+	}
+	item.SourceGUID = fileData.SourceGUID
+	item.SourceURL = fileData.OriginalURL
+	if fileData.TranscriptURL != "" {
+		item.Transcript = &Transcript{URL: fileData.TranscriptURL, Type: transcribe.ContentType}
+	}
+	return item
 }
 
-// GetRSSFeedID gets the RSS feed file ID from Google Drive
+// GetRSSFeedID gets the RSS feed file ID from Google Drive, or "" if there is
+// no feed yet or the search failed
 func (p *RSSProcessor) GetRSSFeedID() string {
-	files, err := p.drive.GetFiles(config.RSSQuery, true)
+	feedID, err := p.FindRSSFeedID()
 	if err != nil {
 		slog.Error("Error searching for RSS feed", "error", err)
 		return ""
 	}
+	return feedID
+}
+
+// FindRSSFeedID gets the RSS feed file ID from Google Drive, or "" if there is
+// no feed yet. Unlike GetRSSFeedID it reports failed searches, which callers
+// about to create a feed must not mistake for a missing one.
+func (p *RSSProcessor) FindRSSFeedID() (string, error) {
+	query := p.folders.RSSQuery()
+	files, err := p.drive.GetFiles(query.MostRecent())
+	if err != nil {
+		return "", err
+	}
 	if len(files) == 0 {
-		return ""
+		// Feeds created before folder organization live outside the feed folder;
+		// keep updating them in place so the subscribed feed URL doesn't change
+		legacy := query
+		legacy.Folder = ""
+		files, err = p.drive.GetFiles(legacy.MostRecent())
+		if err != nil {
+			return "", err
+		}
+		if len(files) == 0 {
+			return "", nil
+		}
 	}
-	return files[0].Id
+	return files[0].ID, nil
 }
 
-// ExtractEpisodeMapping extracts episode mapping from RSS content
+// ExtractEpisodeMapping extracts episode mapping from RSS content, keyed by EpisodeKey
 func (p *RSSProcessor) ExtractEpisodeMapping(xmlContent string) (map[string]ExistingEpisode, error) {
 	var rss RSS
 	if err := xml.Unmarshal([]byte(xmlContent), &rss); err != nil {
@@ -191,29 +458,82 @@ func (p *RSSProcessor) ExtractEpisodeMapping(xmlContent string) (map[string]Exis
 		}
 
 		episode := ExistingEpisode{
+			Title:            title,
+			SourceGUID:       item.SourceGUID,
+			SourceURL:        item.SourceURL,
 			DownloadURL:      item.Enclosure.URL,
 			Duration:         time.Duration(length) * time.Millisecond,
 			OriginalDuration: time.Duration(originalDuration) * time.Millisecond,
 			OriginalGUID:     item.GUID.Value,
+			ContentType:      item.Enclosure.Type,
+			Encoding:         item.Encoding,
+		}
+		if item.Transcript != nil {
+			episode.TranscriptURL = item.Transcript.URL
+		}
+		if item.FileSize != "" {
+			if size, err := strconv.ParseInt(item.FileSize, 10, 64); err == nil {
+				episode.Size = size
+			}
+		}
+		if item.DroppedAt != "" {
+			if droppedAt, err := time.Parse(time.RFC3339, item.DroppedAt); err == nil {
+				episode.DroppedAt = droppedAt
+			}
+		}
+		if item.ListedDuration != "" {
+			if listed, err := strconv.ParseInt(item.ListedDuration, 10, 64); err == nil {
+				episode.ListedDuration = time.Duration(listed) * time.Millisecond
+			}
+		}
+		if item.Offset != "" {
+			if offset, err := strconv.ParseInt(item.Offset, 10, 64); err == nil {
+				episode.Offset = time.Duration(offset) * time.Millisecond
+			}
+		}
+		if item.Skipped != "" {
+			if skipped, err := strconv.ParseInt(item.Skipped, 10, 64); err == nil {
+				episode.Skipped = time.Duration(skipped) * time.Millisecond
+			}
+		}
+		if item.Speed != "" {
+			if speed, err := strconv.ParseFloat(item.Speed, 64); err == nil {
+				episode.Speed = speed
+			}
 		}
 
-		episodeMapping[title] = episode
+		episodeMapping[EpisodeKey(item.SourceGUID, item.SourceURL, title)] = episode
 	}
 	return episodeMapping, nil
 }
 
-func (p *RSSProcessor) CanReuseEpisode(newEp queue.JobItem, oldEp ExistingEpisode, speed float64) bool {
-	// JobItem
-	//   Duration -> original duration
-	//   Offset -> offset into the duration
-	//
-	// ExistingEpisode
-	//   OriginalDuration -> original duration
-	//   Duration -> previously proceed length (includes offset and speed)
-	//
-	// need modified duration from playlist
-	newDuration := time.Duration(float64((newEp.Duration - newEp.Offset).Nanoseconds()) / speed)
+// sameSource reports whether an existing episode was made from the same source
+// episode as newEp, as far as the source identifiers both have can tell
+func sameSource(newEp queue.JobItem, oldEp ExistingEpisode) bool {
+	if newEp.GUID != "" && oldEp.SourceGUID != "" {
+		return newEp.GUID == oldEp.SourceGUID
+	}
+	if newEp.SourceURL != "" && oldEp.SourceURL != "" {
+		return newEp.SourceURL == oldEp.SourceURL
+	}
+	return true
+}
+
+// reuseTolerance is how far an existing episode's length may be from the
+// length it would be processed to now and still be reused. Truncating the
+// durations to the feed's milliseconds, then dividing by the speed, loses a
+// millisecond or two; anything more means the episode was processed otherwise.
+const reuseTolerance = 5 * time.Millisecond
+
+// ProcessedDuration is how long an episode plays once processed at speed from
+// offset into a source lasting sourceDuration, with skipped of the rest left
+// out by skip rules
+func ProcessedDuration(sourceDuration, offset, skipped time.Duration, speed float64) time.Duration {
+	return time.Duration(float64((sourceDuration - offset - skipped).Nanoseconds()) / speed)
+}
 
+// fileExists reports whether the existing episode's audio is still in storage
+func (p *RSSProcessor) fileExists(oldEp ExistingEpisode) bool {
 	fileId := p.drive.ExtractFileIDFromURL(oldEp.DownloadURL)
 	if fileId == "" {
 		return false
@@ -222,9 +542,51 @@ func (p *RSSProcessor) CanReuseEpisode(newEp queue.JobItem, oldEp ExistingEpisod
 	if err != nil {
 		slog.Error("Error checking if file exists", "error", err)
 	}
+	return reallyExists
+}
+
+func (p *RSSProcessor) CanReuseEpisode(newEp queue.JobItem, oldEp ExistingEpisode, speed float64) bool {
+	if !sameSource(newEp, oldEp) {
+		return false
+	}
+
+	// JobItem
+	//   Duration -> original duration
+	//   Offset -> offset into the duration
+	//
+	// ExistingEpisode
+	//   OriginalDuration -> original duration, as probed when processed
+	//   ListedDuration -> original duration as the playlist listed it
+	//   Duration -> previously proceed length (includes offset, skipped parts and speed)
+	//   Skipped -> source audio the skip rule left out when processed
+	//
+	// need modified duration from playlist. The parts a skip rule left out are
+	// taken as they were; a changed rule applies once the episode is processed again.
+	newDuration := ProcessedDuration(oldEp.OriginalDuration, newEp.Offset, oldEp.Skipped, speed)
+	reallyExists := p.fileExists(oldEp)
+
+	// The feed records whole milliseconds, so the length recomputed from them may be off by one or two
+	return reallyExists && oldEp.Listed() == newEp.Duration && (oldEp.Duration-newDuration).Abs() <= reuseTolerance
+}
 
-	// for new duration, use milliseconds since thats the value all the files contain (eg: the XML RSS duration)
-	return reallyExists && oldEp.OriginalDuration == newEp.Duration && oldEp.Duration.Milliseconds() == newDuration.Milliseconds()
+// RetrimOffset reports whether newEp can be made by cutting the start off the
+// existing episode's processed audio, which holds when only the listening
+// offset moved forward since it was processed at the same speed. It returns
+// how much processed audio to cut.
+func (p *RSSProcessor) RetrimOffset(newEp queue.JobItem, oldEp ExistingEpisode, speed float64) (time.Duration, bool) {
+	// Skip rules cut relative to the offset, so an episode with skipped parts
+	// can't be cut to a later offset
+	if !sameSource(newEp, oldEp) || oldEp.Speed != speed || oldEp.Listed() != newEp.Duration || oldEp.Skipped > 0 {
+		return 0, false
+	}
+	trim := oldEp.Duration - ProcessedDuration(oldEp.OriginalDuration, newEp.Offset, 0, speed)
+	if trim.Milliseconds() <= 0 {
+		return 0, false
+	}
+	if !p.fileExists(oldEp) {
+		return 0, false
+	}
+	return trim, true
 }
 
 func hashString(s string) int {
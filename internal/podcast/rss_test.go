@@ -1,13 +1,28 @@
 package podcast
 
 import (
+	"strings"
 	"testing"
 	"time"
 
+	"cobblepod/internal/config"
 	"cobblepod/internal/queue"
 	"cobblepod/internal/storage/mock"
 )
 
+// testFolders are the feed folders of a deployment with the default drive folder
+var testFolders = NewFolders(config.DefaultDriveFolder)
+
+func TestNewFolders(t *testing.T) {
+	folders := NewFolders("staging")
+	if folders.Feed != "staging/playrun_addict" || folders.Archive != "staging/playrun_addict/archive" {
+		t.Errorf("Expected folders under staging, got %+v", folders)
+	}
+	if query := folders.RSSQuery(); query.Folder != folders.Feed || query.ExactName != RSSFilename {
+		t.Errorf("Expected the feed file in %s, got %+v", folders.Feed, query)
+	}
+}
+
 func TestCanReuseEpisode(t *testing.T) {
 	tests := []struct {
 		name                    string
@@ -117,6 +132,26 @@ func TestCanReuseEpisode(t *testing.T) {
 			expectedResult:          false,
 			description:             "Should return false when processed durations don't match even with valid file ID",
 		},
+		{
+			name: "skipped_parts_with_good_file_id",
+			newEpisode: queue.JobItem{
+				Title:    "Test Episode",
+				Duration: 60 * time.Second,
+				Offset:   10 * time.Second,
+			},
+			existingEpisode: ExistingEpisode{
+				DownloadURL:      "https://example.com/file303",
+				Duration:         20 * time.Second, // (60 - 10 offset - 20 skipped) / 1.5 speed
+				OriginalDuration: 60 * time.Second,
+				Skipped:          20 * time.Second,
+			},
+			speed:                   1.5,
+			extractFileIDResult:     "valid-file-id-303",
+			fileExistsResult:        true,
+			expectedFileExistsCalls: 1,
+			expectedResult:          true,
+			description:             "Should return true when the skip rule's cuts account for the shorter episode",
+		},
 		{
 			name: "speed_adjustment_with_good_file_id",
 			newEpisode: queue.JobItem{
@@ -153,7 +188,7 @@ func TestCanReuseEpisode(t *testing.T) {
 			mockStorage.FileExistsError = tt.fileExistsError
 
 			// Create RSS processor with mock storage
-			processor := NewRSSProcessor("Test Channel", mockStorage)
+			processor := NewRSSProcessor("Test Channel", mockStorage, testFolders)
 
 			// Test CanReuseEpisode
 			result := processor.CanReuseEpisode(tt.newEpisode, tt.existingEpisode, tt.speed)
@@ -177,3 +212,429 @@ func TestCanReuseEpisode(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateRSSXMLUsesLocation(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+
+	processor := NewRSSProcessor("Test Channel", mock.NewMockStorage(), testFolders)
+	processor.SetLocation(loc)
+
+	xmlContent := processor.CreateRSSXML(nil)
+	if !strings.Contains(xmlContent, "+0530</lastBuildDate>") {
+		t.Errorf("Expected lastBuildDate rendered in +0530, got %s", xmlContent)
+	}
+}
+
+func TestCreateRSSXMLEnclosureType(t *testing.T) {
+	processor := NewRSSProcessor("Test Channel", mock.NewMockStorage(), testFolders)
+
+	xmlContent := processor.CreateRSSXML([]ProcessedEpisode{
+		{Title: "Legacy", DownloadURL: "https://example.com/legacy"},
+		{Title: "AAC", DownloadURL: "https://example.com/aac", ContentType: "audio/mp4"},
+	})
+
+	mapping, err := processor.ExtractEpisodeMapping(xmlContent)
+	if err != nil {
+		t.Fatalf("Failed to parse generated feed: %v", err)
+	}
+	if got := mapping[EpisodeKey("", "", "Legacy")].ContentType; got != "audio/mpeg" {
+		t.Errorf("Expected legacy episode to default to audio/mpeg, got %q", got)
+	}
+	if got := mapping[EpisodeKey("", "", "AAC")].ContentType; got != "audio/mp4" {
+		t.Errorf("Expected AAC episode to keep audio/mp4, got %q", got)
+	}
+}
+
+func TestCreateRSSXMLEpisodeMetadata(t *testing.T) {
+	processor := NewRSSProcessor("Test Channel", mock.NewMockStorage(), testFolders)
+
+	xmlContent := processor.CreateRSSXML([]ProcessedEpisode{
+		{
+			Title:       "Enriched",
+			DownloadURL: "https://example.com/enriched",
+			PubDate:     time.Date(2025, 6, 3, 9, 0, 0, 0, time.UTC),
+			Description: "Hill repeats",
+			Image:       "https://example.com/ep.jpg",
+		},
+		{Title: "Plain", DownloadURL: "https://example.com/plain"},
+	})
+
+	for _, want := range []string{
+		"<pubDate>Tue, 03 Jun 2025 09:00:00 +0000</pubDate>",
+		"<description>Hill repeats</description>",
+		`<itunes:image href="https://example.com/ep.jpg"></itunes:image>`,
+	} {
+		if !strings.Contains(xmlContent, want) {
+			t.Errorf("Expected feed to contain %q", want)
+		}
+	}
+	if strings.Count(xmlContent, "<pubDate>") != 1 {
+		t.Error("Expected only the enriched episode to have a pubDate")
+	}
+}
+
+func TestCreateRSSXMLDroppedAt(t *testing.T) {
+	processor := NewRSSProcessor("Test Channel", mock.NewMockStorage(), testFolders)
+	droppedAt := time.Date(2025, 6, 3, 9, 0, 0, 0, time.UTC)
+
+	xmlContent := processor.CreateRSSXML([]ProcessedEpisode{
+		{Title: "Retained", DownloadURL: "https://example.com/retained", DroppedAt: droppedAt},
+		{Title: "Current", DownloadURL: "https://example.com/current"},
+	})
+
+	mapping, err := processor.ExtractEpisodeMapping(xmlContent)
+	if err != nil {
+		t.Fatalf("Failed to parse generated feed: %v", err)
+	}
+	if got := mapping[EpisodeKey("", "", "Retained")].DroppedAt; !got.Equal(droppedAt) {
+		t.Errorf("Expected dropped at %v, got %v", droppedAt, got)
+	}
+	if got := mapping[EpisodeKey("", "", "Current")].DroppedAt; !got.IsZero() {
+		t.Errorf("Expected current episode not to be dropped, got %v", got)
+	}
+}
+
+func TestCreateRSSXMLOffset(t *testing.T) {
+	processor := NewRSSProcessor("Test Channel", mock.NewMockStorage(), testFolders)
+
+	xmlContent := processor.CreateRSSXML([]ProcessedEpisode{
+		{Title: "Listening", DownloadURL: "https://example.com/listening", Offset: 20*time.Minute + 1500*time.Millisecond},
+		{Title: "Unstarted", DownloadURL: "https://example.com/unstarted"},
+	})
+	if !strings.Contains(xmlContent, "<playrunaddict:offset>1201500</playrunaddict:offset>") {
+		t.Errorf("Expected the offset in the playrunaddict namespace, got %s", xmlContent)
+	}
+
+	mapping, err := processor.ExtractEpisodeMapping(xmlContent)
+	if err != nil {
+		t.Fatalf("Failed to parse generated feed: %v", err)
+	}
+	if got := mapping[EpisodeKey("", "", "Listening")].Offset; got != 20*time.Minute+1500*time.Millisecond {
+		t.Errorf("Expected offset to survive the feed, got %v", got)
+	}
+	if got := mapping[EpisodeKey("", "", "Unstarted")].Offset; got != 0 {
+		t.Errorf("Expected no offset for an unstarted episode, got %v", got)
+	}
+}
+
+func TestCreateRSSXMLTranscript(t *testing.T) {
+	processor := NewRSSProcessor("Test Channel", mock.NewMockStorage(), testFolders)
+
+	xmlContent := processor.CreateRSSXML([]ProcessedEpisode{
+		{Title: "Transcribed", DownloadURL: "https://example.com/transcribed", TranscriptURL: "https://example.com/transcribed.vtt"},
+		{Title: "Untranscribed", DownloadURL: "https://example.com/untranscribed"},
+	})
+	if !strings.Contains(xmlContent, `xmlns:podcast="https://podcastindex.org/namespace/1.0"`) {
+		t.Errorf("Expected the podcast namespace to be declared, got %s", xmlContent)
+	}
+	if !strings.Contains(xmlContent, `<podcast:transcript url="https://example.com/transcribed.vtt" type="text/vtt"></podcast:transcript>`) {
+		t.Errorf("Expected a podcast:transcript element, got %s", xmlContent)
+	}
+	if strings.Count(xmlContent, "<podcast:transcript") != 1 {
+		t.Errorf("Expected only the transcribed episode to link a transcript, got %s", xmlContent)
+	}
+
+	mapping, err := processor.ExtractEpisodeMapping(xmlContent)
+	if err != nil {
+		t.Fatalf("Failed to parse generated feed: %v", err)
+	}
+	if got := mapping[EpisodeKey("", "", "Transcribed")].TranscriptURL; got != "https://example.com/transcribed.vtt" {
+		t.Errorf("Expected the transcript to survive the feed, got %q", got)
+	}
+	if got := mapping[EpisodeKey("", "", "Untranscribed")].TranscriptURL; got != "" {
+		t.Errorf("Expected no transcript, got %q", got)
+	}
+}
+
+func TestRebuildRSSXML(t *testing.T) {
+	original := NewRSSProcessor("Old Title", mock.NewMockStorage(), testFolders)
+	xmlContent := original.CreateRSSXML([]ProcessedEpisode{
+		{Title: "Kept", DownloadURL: "https://example.com/kept", Description: "Show notes"},
+		{Title: "Missing", DownloadURL: "https://example.com/missing"},
+	})
+
+	processor := NewRSSProcessor("New Title", mock.NewMockStorage(), testFolders)
+	rebuilt, dropped, err := processor.RebuildRSSXML(xmlContent, func(item Item) bool {
+		return item.Enclosure.URL != "https://example.com/missing"
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if dropped != 1 {
+		t.Errorf("Expected 1 item dropped, got %d", dropped)
+	}
+	if !strings.Contains(rebuilt, "<title>New Title</title>") {
+		t.Error("Expected the rebuilt feed to use the new channel title")
+	}
+
+	mapping, err := processor.ExtractEpisodeMapping(rebuilt)
+	if err != nil {
+		t.Fatalf("Failed to parse rebuilt feed: %v", err)
+	}
+	if _, ok := mapping[EpisodeKey("", "", "Missing")]; ok || len(mapping) != 1 {
+		t.Errorf("Expected only the kept episode, got %v", mapping)
+	}
+	if !strings.Contains(rebuilt, "<description>Show notes</description>") {
+		t.Error("Expected kept items to be unchanged")
+	}
+
+	if _, _, err := processor.RebuildRSSXML("not xml", func(Item) bool { return true }); err == nil {
+		t.Error("Expected an error for an unparseable feed")
+	}
+}
+
+func TestAddToRSSXML(t *testing.T) {
+	processor := NewRSSProcessor("Test Channel", mock.NewMockStorage(), testFolders)
+	xmlContent := processor.CreateRSSXML([]ProcessedEpisode{
+		{Title: "Existing", DownloadURL: "https://example.com/existing", Description: "Show notes"},
+	})
+
+	added, err := processor.AddToRSSXML(xmlContent, ProcessedEpisode{Title: "Restored", SourceGUID: "restored-1", DownloadURL: "https://example.com/restored"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	mapping, err := processor.ExtractEpisodeMapping(added)
+	if err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+	if len(mapping) != 2 {
+		t.Errorf("Expected 2 episodes, got %v", mapping)
+	}
+	if episode, ok := mapping[EpisodeKey("restored-1", "", "")]; !ok || episode.DownloadURL != "https://example.com/restored" {
+		t.Errorf("Expected the restored episode, got %v", mapping)
+	}
+	if !strings.Contains(added, "<description>Show notes</description>") {
+		t.Error("Expected existing items to be unchanged")
+	}
+
+	if _, err := processor.AddToRSSXML("not xml", ProcessedEpisode{Title: "Restored"}); err == nil {
+		t.Error("Expected an error for an unparseable feed")
+	}
+}
+
+func TestEpisodeMappingKeysOnSource(t *testing.T) {
+	processor := NewRSSProcessor("Test Channel", mock.NewMockStorage(), testFolders)
+	xmlContent := processor.CreateRSSXML([]ProcessedEpisode{
+		{Title: "Episode 1", SourceGUID: "show-a-1", OriginalURL: "https://a.example.com/1.mp3", DownloadURL: "https://example.com/a1"},
+		{Title: "Episode 1", OriginalURL: "https://b.example.com/1.mp3", DownloadURL: "https://example.com/b1"},
+		{Title: "Legacy", DownloadURL: "https://example.com/legacy"},
+	})
+
+	mapping, err := processor.ExtractEpisodeMapping(xmlContent)
+	if err != nil {
+		t.Fatalf("Failed to parse generated feed: %v", err)
+	}
+	if len(mapping) != 3 {
+		t.Fatalf("Expected episodes with the same title to be kept apart, got %v", mapping)
+	}
+
+	tests := []struct {
+		name        string
+		item        queue.JobItem
+		downloadURL string
+	}{
+		{name: "by GUID", item: queue.JobItem{Title: "Episode 1", GUID: "show-a-1", SourceURL: "https://cdn.example.com/moved.mp3"}, downloadURL: "https://example.com/a1"},
+		{name: "by source URL", item: queue.JobItem{Title: "Episode 1", SourceURL: "https://b.example.com/1.mp3"}, downloadURL: "https://example.com/b1"},
+		{name: "legacy by title", item: queue.JobItem{Title: "Legacy", GUID: "legacy-guid", SourceURL: "https://c.example.com/legacy.mp3"}, downloadURL: "https://example.com/legacy"},
+		{name: "same title, other show", item: queue.JobItem{Title: "Episode 1", GUID: "show-c-1", SourceURL: "https://c.example.com/1.mp3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, episode, ok := FindEpisode(mapping, tt.item)
+			if ok != (tt.downloadURL != "") || episode.DownloadURL != tt.downloadURL {
+				t.Errorf("FindEpisode() = %q, %v, want %q", episode.DownloadURL, ok, tt.downloadURL)
+			}
+		})
+	}
+}
+
+func TestCanReuseEpisodeDifferentSource(t *testing.T) {
+	mockStorage := mock.NewMockStorage()
+	mockStorage.FileExistsResult = true
+	processor := NewRSSProcessor("Test Channel", mockStorage, testFolders)
+
+	newEp := queue.JobItem{Title: "Episode 1", GUID: "show-b-1", Duration: time.Minute}
+	oldEp := ExistingEpisode{Title: "Episode 1", SourceGUID: "show-a-1", DownloadURL: "https://example.com/a1", Duration: time.Minute, OriginalDuration: time.Minute}
+	if processor.CanReuseEpisode(newEp, oldEp, 1.0) {
+		t.Error("Expected an episode of another show not to be reused")
+	}
+
+	newEp.GUID = "show-a-1"
+	if !processor.CanReuseEpisode(newEp, oldEp, 1.0) {
+		t.Error("Expected the same source episode to be reused")
+	}
+}
+
+func TestRetrimOffset(t *testing.T) {
+	mockStorage := mock.NewMockStorage()
+	mockStorage.FileExistsResult = true
+	processor := NewRSSProcessor("Test Channel", mockStorage, testFolders)
+
+	// Processed at 2x from a 10 minute offset into an hour-long episode
+	xmlContent := processor.CreateRSSXML([]ProcessedEpisode{
+		{Title: "Episode", DownloadURL: "https://example.com/file", OriginalDuration: time.Hour, NewDuration: 25 * time.Minute, Speed: 2},
+	})
+	mapping, err := processor.ExtractEpisodeMapping(xmlContent)
+	if err != nil {
+		t.Fatalf("Failed to parse generated feed: %v", err)
+	}
+	oldEp := mapping[EpisodeKey("", "", "Episode")]
+	if oldEp.Speed != 2 {
+		t.Fatalf("Expected the feed to record speed 2, got %v", oldEp.Speed)
+	}
+
+	tests := []struct {
+		name  string
+		item  queue.JobItem
+		speed float64
+		trim  time.Duration
+		ok    bool
+	}{
+		{name: "offset moved forward", item: queue.JobItem{Duration: time.Hour, Offset: 20 * time.Minute}, speed: 2, trim: 5 * time.Minute, ok: true},
+		{name: "offset unchanged", item: queue.JobItem{Duration: time.Hour, Offset: 10 * time.Minute}, speed: 2},
+		{name: "offset moved back", item: queue.JobItem{Duration: time.Hour, Offset: 5 * time.Minute}, speed: 2},
+		{name: "speed changed", item: queue.JobItem{Duration: time.Hour, Offset: 20 * time.Minute}, speed: 1.5},
+		{name: "source changed", item: queue.JobItem{Duration: 2 * time.Hour, Offset: 20 * time.Minute}, speed: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trim, ok := processor.RetrimOffset(tt.item, oldEp, tt.speed)
+			if trim != tt.trim || ok != tt.ok {
+				t.Errorf("RetrimOffset() = %v, %v, want %v, %v", trim, ok, tt.trim, tt.ok)
+			}
+		})
+	}
+
+	// Feeds written before speeds were recorded can't be re-trimmed
+	legacy := oldEp
+	legacy.Speed = 0
+	if _, ok := processor.RetrimOffset(tests[0].item, legacy, 2); ok {
+		t.Error("Expected an episode without a recorded speed not to be re-trimmed")
+	}
+
+	// Skip rules cut relative to the offset, so their episodes are processed again
+	skipped := oldEp
+	skipped.Skipped = time.Minute
+	if _, ok := processor.RetrimOffset(tests[0].item, skipped, 2); ok {
+		t.Error("Expected an episode with skipped parts not to be re-trimmed")
+	}
+}
+
+func TestProcessedDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  time.Duration
+		offset  time.Duration
+		skipped time.Duration
+		speed   float64
+		want    time.Duration
+	}{
+		{name: "whole episode", source: time.Hour, speed: 1, want: time.Hour},
+		{name: "sped up", source: time.Hour, speed: 1.5, want: 40 * time.Minute},
+		{name: "from an offset", source: time.Hour, offset: 10 * time.Minute, speed: 2, want: 25 * time.Minute},
+		{name: "with skipped parts", source: time.Hour, offset: 10 * time.Minute, skipped: 5 * time.Minute, speed: 1.5, want: 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ProcessedDuration(tt.source, tt.offset, tt.skipped, tt.speed); got != tt.want {
+				t.Errorf("ProcessedDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCanReuseEpisodeAfterFeed checks that episodes are reused once their
+// durations went through the feed's milliseconds
+func TestCanReuseEpisodeAfterFeed(t *testing.T) {
+	mockStorage := mock.NewMockStorage()
+	mockStorage.FileExistsResult = true
+	processor := NewRSSProcessor("Test Channel", mockStorage, testFolders)
+
+	item := queue.JobItem{Title: "Episode", Duration: time.Hour + 1234567*time.Microsecond, Offset: 10*time.Minute + 500*time.Millisecond}
+	tests := []struct {
+		name    string
+		speed   float64
+		skipped time.Duration
+	}{
+		{name: "uneven speed", speed: 1.7},
+		{name: "skipped parts", speed: 1.5, skipped: 97300 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xmlContent := processor.CreateRSSXML([]ProcessedEpisode{{
+				Title:            item.Title,
+				DownloadURL:      "https://example.com/file",
+				OriginalDuration: item.Duration,
+				NewDuration:      ProcessedDuration(item.Duration, item.Offset, tt.skipped, tt.speed),
+				Speed:            tt.speed,
+				Offset:           item.Offset,
+				Skipped:          tt.skipped,
+			}})
+			mapping, err := processor.ExtractEpisodeMapping(xmlContent)
+			if err != nil {
+				t.Fatalf("Failed to parse generated feed: %v", err)
+			}
+			oldEp := mapping[EpisodeKey("", "", "Episode")]
+			if oldEp.Skipped != tt.skipped.Truncate(time.Millisecond) {
+				t.Errorf("Expected skipped %v to survive the feed, got %v", tt.skipped, oldEp.Skipped)
+			}
+			// The feed keeps whole milliseconds of the source
+			listed := item
+			listed.Duration = item.Duration.Truncate(time.Millisecond)
+			if !processor.CanReuseEpisode(listed, oldEp, tt.speed) {
+				t.Errorf("Expected the episode to be reused, feed has %v of %v", oldEp.Duration, oldEp.OriginalDuration)
+			}
+			if processor.CanReuseEpisode(listed, oldEp, tt.speed+0.1) {
+				t.Error("Expected the episode not to be reused at another speed")
+			}
+		})
+	}
+}
+
+func TestCanReuseEpisodeListedDuration(t *testing.T) {
+	mockStorage := mock.NewMockStorage()
+	mockStorage.FileExistsResult = true
+	processor := NewRSSProcessor("Test Channel", mockStorage, testFolders)
+
+	// The playlist lists the episode as 50 minutes; the audio lasts an hour and was processed at 2x
+	xmlContent := processor.CreateRSSXML([]ProcessedEpisode{
+		{Title: "Misreported", DownloadURL: "https://example.com/other", OriginalDuration: time.Hour, ListedDuration: 50 * time.Minute, NewDuration: 30 * time.Minute, Speed: 2},
+	})
+	mapping, err := processor.ExtractEpisodeMapping(xmlContent)
+	if err != nil {
+		t.Fatalf("Failed to parse generated feed: %v", err)
+	}
+
+	misreported := mapping[EpisodeKey("", "", "Misreported")]
+	if misreported.ListedDuration != 50*time.Minute || misreported.OriginalDuration != time.Hour {
+		t.Fatalf("Expected both durations to survive the feed, got %+v", misreported)
+	}
+	if !processor.CanReuseEpisode(queue.JobItem{Title: "Misreported", Duration: 50 * time.Minute}, misreported, 2) {
+		t.Error("Expected the episode to be reused when the playlist lists it as before")
+	}
+	if processor.CanReuseEpisode(queue.JobItem{Title: "Misreported", Duration: time.Hour}, misreported, 2) {
+		t.Error("Expected a changed listing not to be reused")
+	}
+}
+
+func TestCreateRSSXMLEncoding(t *testing.T) {
+	processor := NewRSSProcessor("Test Channel", mock.NewMockStorage(), testFolders)
+
+	xmlContent := processor.CreateRSSXML([]ProcessedEpisode{
+		{Title: "Voice", DownloadURL: "https://example.com/voice", Encoding: "64k mono", Size: 14400000},
+		{Title: "Default", DownloadURL: "https://example.com/default"},
+	})
+	mapping, err := processor.ExtractEpisodeMapping(xmlContent)
+	if err != nil {
+		t.Fatalf("Failed to parse generated feed: %v", err)
+	}
+	if got := mapping[EpisodeKey("", "", "Voice")]; got.Encoding != "64k mono" || got.Size != 14400000 {
+		t.Errorf("Expected encoding and size to survive the feed, got %+v", got)
+	}
+	if got := mapping[EpisodeKey("", "", "Default")]; got.Encoding != "" || got.Size != 0 {
+		t.Errorf("Expected no encoding or size, got %+v", got)
+	}
+}
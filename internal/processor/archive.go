@@ -0,0 +1,249 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"cobblepod/internal/podcast"
+	"cobblepod/internal/queue"
+)
+
+// ErrAlreadyInFeed is returned when restoring an episode the feed already has
+var ErrAlreadyInFeed = errors.New("episode is already in the feed")
+
+// Archiver records the episodes moved to the archive folder, see
+// queue.ArchivedEpisode
+type Archiver interface {
+	ArchiveEpisode(ctx context.Context, userID string, episode *queue.ArchivedEpisode) error
+	GetArchivedEpisode(ctx context.Context, userID string, fileID string) (*queue.ArchivedEpisode, error)
+	ListArchivedEpisodes(ctx context.Context, userID string) ([]*queue.ArchivedEpisode, error)
+	RemoveArchivedEpisode(ctx context.Context, userID string, fileID string) error
+}
+
+// StorageArchiver interface for dependency injection
+type StorageArchiver interface {
+	StorageDeleter
+	MoveFile(fileID, folder string) error
+}
+
+// archiveUnusedEpisodes moves the episodes that are no longer in the feed to
+// the archive folder instead of deleting them, so a bad playlist can't wipe out
+// a user's processed audio. An episode whose file can't be moved is left where
+// it is.
+func (p *Processor) archiveUnusedEpisodes(ctx context.Context, userID string, storageService StorageArchiver, episodeMapping map[string]podcast.ExistingEpisode, reused map[string]podcast.ExistingEpisode) {
+	now := time.Now()
+	for key, episode := range episodeMapping {
+		if _, ok := reused[key]; ok {
+			continue
+		}
+		fileID := storageService.ExtractFileIDFromURL(episode.DownloadURL)
+		if fileID == "" {
+			slog.WarnContext(ctx, "Could not extract file ID from URL", "url", episode.DownloadURL)
+			continue
+		}
+		slog.InfoContext(ctx, "Archiving unused episode", "title", episode.Title, "file_id", fileID)
+		if err := storageService.MoveFile(fileID, p.folders.Archive); err != nil {
+			slog.ErrorContext(ctx, "Failed to archive episode", "file_id", fileID, "error", err)
+			continue
+		}
+		if episode.TranscriptURL != "" {
+			if transcriptID := storageService.ExtractFileIDFromURL(episode.TranscriptURL); transcriptID != "" {
+				if err := storageService.MoveFile(transcriptID, p.folders.Archive); err != nil {
+					slog.ErrorContext(ctx, "Failed to archive transcript", "file_id", transcriptID, "error", err)
+				}
+			}
+		}
+
+		data, err := json.Marshal(episode)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to marshal archived episode", "file_id", fileID, "error", err)
+			continue
+		}
+		record := &queue.ArchivedEpisode{FileID: fileID, Title: episode.Title, ArchivedAt: now, Episode: data}
+		if err := p.queue.ArchiveEpisode(ctx, userID, record); err != nil {
+			slog.ErrorContext(ctx, "Failed to record archived episode", "file_id", fileID, "error", err)
+		}
+	}
+}
+
+// runRestore moves the job's archived episode back to the feed folder and
+// republishes it as it was before it dropped out of the feed
+func (p *Processor) runRestore(ctx context.Context, job *queue.Job, feed *userFeed) error {
+	record, err := p.queue.GetArchivedEpisode(ctx, job.UserID, job.RestoreFileID)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return fmt.Errorf("file %s is not archived", job.RestoreFileID)
+	}
+	var episode podcast.ExistingEpisode
+	if err := json.Unmarshal(record.Episode, &episode); err != nil {
+		return fmt.Errorf("failed to read archived episode: %w", err)
+	}
+
+	item := queue.JobItem{
+		ID:          job.RestoreFileID,
+		Title:       episode.Title,
+		Status:      queue.StatusProcessing,
+		SourceURL:   episode.SourceURL,
+		GUID:        episode.SourceGUID,
+		DriveFileID: job.RestoreFileID,
+	}
+	if err := p.queue.SetJobItems(ctx, job.ID, []queue.JobItem{item}); err != nil {
+		slog.ErrorContext(ctx, "Failed to set job items", "error", err)
+	}
+
+	// A retained episode starts its retention period over
+	episode.DroppedAt = time.Time{}
+	unlock, err := p.queue.LockFeed(ctx, job.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to lock feed: %w", err)
+	}
+	feedURL, err := func() (string, error) {
+		defer unlock()
+		// Another job may have published the feed since it was opened, maybe
+		// with the episode in it
+		feedID, err := feed.podcast.FindRSSFeedID()
+		if err != nil {
+			return "", fmt.Errorf("failed to find RSS feed: %w", err)
+		}
+		var current string
+		if feedID != "" {
+			if current, err = feed.storage.DownloadFile(feedID); err != nil {
+				return "", fmt.Errorf("failed to download RSS feed: %w", err)
+			}
+			episodes, err := feed.podcast.ExtractEpisodeMapping(current)
+			if err != nil {
+				return "", fmt.Errorf("failed to read RSS feed: %w", err)
+			}
+			if _, _, ok := podcast.FindEpisode(episodes, item); ok {
+				return "", fmt.Errorf("%w: %s", ErrAlreadyInFeed, episode.Title)
+			}
+		}
+
+		var transcriptID string
+		if episode.TranscriptURL != "" {
+			transcriptID = feed.storage.ExtractFileIDFromURL(episode.TranscriptURL)
+		}
+		if err := feed.storage.MoveFile(record.FileID, p.folders.Feed); err != nil {
+			return "", fmt.Errorf("failed to restore episode: %w", err)
+		}
+		if transcriptID != "" {
+			if err := feed.storage.MoveFile(transcriptID, p.folders.Feed); err != nil {
+				slog.ErrorContext(ctx, "Failed to restore transcript", "file_id", transcriptID, "error", err)
+			}
+		}
+
+		feedURL, err := publishRestored(ctx, feed, feedID, current, episode)
+		if err != nil {
+			// Put the files back, so the episode can still be restored later
+			for _, fileID := range []string{record.FileID, transcriptID} {
+				if fileID == "" {
+					continue
+				}
+				if moveErr := feed.storage.MoveFile(fileID, p.folders.Archive); moveErr != nil {
+					slog.ErrorContext(ctx, "Failed to move file back to the archive", "file_id", fileID, "error", moveErr)
+				}
+			}
+			return "", err
+		}
+		return feedURL, nil
+	}()
+	if err != nil {
+		return err
+	}
+
+	if err := p.queue.RemoveArchivedEpisode(ctx, job.UserID, record.FileID); err != nil {
+		slog.ErrorContext(ctx, "Failed to remove archived episode record", "file_id", record.FileID, "error", err)
+	}
+	if err := p.queue.SetJobFeedURL(ctx, job.ID, feedURL); err != nil {
+		slog.ErrorContext(ctx, "Failed to record feed URL", "error", err)
+	}
+	item.Status = queue.StatusCompleted
+	if err := p.queue.UpdateJobItem(ctx, job.ID, item); err != nil {
+		slog.ErrorContext(ctx, "Failed to update job item", "error", err)
+	}
+	if err := p.queue.PublishEvent(ctx, job.UserID, queue.Event{Type: queue.EventFeedUpdated, JobID: job.ID}); err != nil {
+		slog.ErrorContext(ctx, "Failed to publish feed updated event", "error", err)
+	}
+	slog.InfoContext(ctx, "Restored archived episode", "title", episode.Title, "file_id", record.FileID)
+	return nil
+}
+
+// publishRestored adds a restored episode to the feed, current being the
+// content of the feed with ID feedID, or publishes a feed with just the episode
+// when there is none yet. The caller holds the feed lock.
+func publishRestored(ctx context.Context, feed *userFeed, feedID string, current string, episode podcast.ExistingEpisode) (string, error) {
+	if feedID == "" {
+		return updateFeed(ctx, feed.podcast, feed.storage, "", []podcast.ProcessedEpisode{publishedEpisode(episode)})
+	}
+	xmlFeed, err := feed.podcast.AddToRSSXML(current, publishedEpisode(episode))
+	if err != nil {
+		return "", err
+	}
+	rssFileID, err := feed.storage.UploadString(xmlFeed, podcast.RSSFilename, "application/rss+xml", feedID)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload RSS feed: %w", err)
+	}
+	return feed.storage.GenerateDownloadURL(rssFileID), nil
+}
+
+// PurgeArchive deletes the user's episodes that were archived before
+// olderThan, along with their transcripts, and returns how many it deleted.
+// Episodes whose files are already gone are forgotten too.
+func (p *Processor) PurgeArchive(ctx context.Context, userID string, olderThan time.Time) (int, error) {
+	archived, err := p.queue.ListArchivedEpisodes(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	var expired []*queue.ArchivedEpisode
+	for _, record := range archived {
+		if record.ArchivedAt.Before(olderThan) {
+			expired = append(expired, record)
+		}
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	tokenSource, err := p.tokenProvider.GoogleTokenSource(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get Google access token for user %s: %w", userID, err)
+	}
+	userStorage, err := p.storageCreator(ctx, tokenSource)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create storage service with user token: %w", err)
+	}
+
+	deleted := 0
+	for _, record := range expired {
+		if ctx.Err() != nil {
+			return deleted, ctx.Err()
+		}
+		var episode podcast.ExistingEpisode
+		if err := json.Unmarshal(record.Episode, &episode); err == nil && episode.TranscriptURL != "" {
+			if transcriptID := userStorage.ExtractFileIDFromURL(episode.TranscriptURL); transcriptID != "" {
+				if err := userStorage.DeleteFile(transcriptID); err != nil {
+					slog.WarnContext(ctx, "Failed to delete archived transcript", "file_id", transcriptID, "error", err)
+				}
+			}
+		}
+		if err := userStorage.DeleteFile(record.FileID); err != nil {
+			if exists, existsErr := userStorage.FileExists(record.FileID); existsErr != nil || exists {
+				slog.ErrorContext(ctx, "Failed to delete archived episode", "user_id", userID, "file_id", record.FileID, "error", err)
+				continue
+			}
+		}
+		if err := p.queue.RemoveArchivedEpisode(ctx, userID, record.FileID); err != nil {
+			slog.ErrorContext(ctx, "Failed to remove archived episode record", "file_id", record.FileID, "error", err)
+		}
+		deleted++
+	}
+
+	slog.InfoContext(ctx, "Purged archived episodes", "user_id", userID, "deleted", deleted)
+	return deleted, nil
+}
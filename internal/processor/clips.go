@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+
+	"cobblepod/internal/audio"
+	"cobblepod/internal/podcast"
+	"cobblepod/internal/storage"
+)
+
+// findClips returns the most recent file of each clip the feed has. A clip that
+// can't be looked up is left out.
+func findClips(ctx context.Context, storageService storage.Storage, folders podcast.Folders) map[podcast.Clip]*storage.FileMeta {
+	files := make(map[podcast.Clip]*storage.FileMeta)
+	for _, clip := range podcast.Clips {
+		found, err := storageService.GetFiles(clip.Query(folders).MostRecent())
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to look up clip, leaving it out", "clip", clip, "error", err)
+			continue
+		}
+		if len(found) > 0 {
+			files[clip] = found[0]
+		}
+	}
+	return files
+}
+
+// clipsTag names the contents of the given clip files, so episodes made with
+// other clips aren't reused
+func clipsTag(files map[podcast.Clip]*storage.FileMeta) string {
+	var tags []string
+	for _, clip := range podcast.Clips {
+		file, ok := files[clip]
+		if !ok {
+			continue
+		}
+		version := file.MD5
+		if version == "" {
+			version = file.ID
+		}
+		tags = append(tags, string(clip)+":"+version)
+	}
+	return strings.Join(tags, " ")
+}
+
+// loadClips downloads the feed's intro and outro clips and has audioProcessor
+// join them around every episode. It returns the clips' tag, see clipsTag, and
+// a function that removes the downloads. A clip that can't be loaded is left out.
+func loadClips(ctx context.Context, storageService storage.Storage, folders podcast.Folders, audioProcessor audio.Processor) (string, func()) {
+	paths := make(map[podcast.Clip]string)
+	loaded := make(map[podcast.Clip]*storage.FileMeta)
+	for clip, file := range findClips(ctx, storageService, folders) {
+		path, err := storageService.DownloadFileToTemp(file.ID)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to download clip, leaving it out", "clip", clip, "error", err)
+			continue
+		}
+		paths[clip] = path
+		loaded[clip] = file
+	}
+
+	audioProcessor.SetClips(paths[podcast.ClipIntro], paths[podcast.ClipOutro])
+	return clipsTag(loaded), func() {
+		for _, path := range paths {
+			if err := os.Remove(path); err != nil {
+				slog.WarnContext(ctx, "Failed to remove clip", "path", path, "error", err)
+			}
+		}
+	}
+}
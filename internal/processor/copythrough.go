@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"context"
+	"log/slog"
+
+	"cobblepod/internal/audio"
+	"cobblepod/internal/config"
+	"cobblepod/internal/podcast"
+	"cobblepod/internal/queue"
+)
+
+// SourceProber inspects a source file without downloading it
+type SourceProber interface {
+	ProbeSource(ctx context.Context, url string) (*audio.SourceProbe, error)
+}
+
+// copyThroughCandidate reports whether an item is short enough to be worth
+// probing for copy-through. Items with an offset always need trimming.
+func copyThroughCandidate(cfg config.WorkerConfig, item queue.JobItem) bool {
+	return cfg.CopyThroughMaxDuration() > 0 &&
+		item.Offset == 0 &&
+		item.Duration > 0 &&
+		item.Duration <= cfg.CopyThroughMaxDuration()
+}
+
+// copyThroughEligible reports whether a probed source is already low bitrate
+// enough that speeding it up isn't worth the encode
+func copyThroughEligible(cfg config.WorkerConfig, item queue.JobItem, probe *audio.SourceProbe) bool {
+	if probe == nil {
+		return false
+	}
+	kbps := probe.Kbps(item.Duration)
+	return kbps > 0 && kbps <= cfg.CopyThroughMaxKbps
+}
+
+// copyThrough publishes short, low bitrate sources as they are, pointing the
+// feed at the original file instead of downloading, re-encoding and uploading
+// it. It returns false when the item should be processed normally; probe
+// failures are not fatal. cfg sets which sources qualify.
+func copyThrough(ctx context.Context, cfg config.WorkerConfig, prober SourceProber, item queue.JobItem) (podcast.ProcessedEpisode, bool) {
+	if !copyThroughCandidate(cfg, item) {
+		return podcast.ProcessedEpisode{}, false
+	}
+
+	probe, err := prober.ProbeSource(ctx, item.SourceURL)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to probe source, processing normally", "title", item.Title, "error", err)
+		return podcast.ProcessedEpisode{}, false
+	}
+	if !copyThroughEligible(cfg, item, probe) {
+		return podcast.ProcessedEpisode{}, false
+	}
+
+	slog.InfoContext(ctx, "Copying source through without processing", "title", item.Title, "kbps", probe.Kbps(item.Duration))
+	return podcast.ProcessedEpisode{
+		Title:            item.Title,
+		OriginalURL:      item.SourceURL,
+		SourceGUID:       item.GUID,
+		OriginalDuration: item.Duration,
+		NewDuration:      item.Duration,
+		UUID:             item.ID,
+		Speed:            1,
+		DownloadURL:      item.SourceURL,
+		ContentType:      probe.Format(item.SourceURL).ContentType,
+		Position:         item.Position,
+		Size:             probe.Size,
+	}, true
+}
@@ -0,0 +1,56 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"cobblepod/internal/podcast"
+	"cobblepod/internal/queue"
+)
+
+// playlistFingerprint maps each entry's episode key to the listed duration and
+// offset it is processed from, so the next backup can be compared with it
+func playlistFingerprint(entries []queue.JobItem) map[string]string {
+	fingerprint := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		fingerprint[podcast.EpisodeKey(entry.GUID, entry.SourceURL, entry.Title)] = fmt.Sprintf("%d/%d", entry.Duration, entry.Offset)
+	}
+	return fingerprint
+}
+
+// playlistShrank reports whether an entry of the previous playlist is gone
+// from the current one, which changes the feed even if no entry changed
+func playlistShrank(previous, current map[string]string) bool {
+	for key := range previous {
+		if _, ok := current[key]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// diffPlaylist splits entries into those to process and those unchanged since
+// the previous playlist that the feed already publishes with the encoding this
+// run would use. Unchanged entries are carried into the feed without becoming
+// job items or being checked in storage.
+func (p *Processor) diffPlaylist(ctx context.Context, entries []queue.JobItem, previous map[string]string, feed *userFeed) (changed, unchanged []queue.JobItem) {
+	if len(previous) == 0 {
+		return entries, nil
+	}
+
+	current := playlistFingerprint(entries)
+	format := feed.settings.Format()
+	_, encoding := p.encoding(feed.settings, clipsTag(findClips(ctx, feed.storage, p.folders)), feed.audio)
+	for _, entry := range entries {
+		key := podcast.EpisodeKey(entry.GUID, entry.SourceURL, entry.Title)
+		_, oldEp, published := podcast.FindEpisode(feed.episodes, entry)
+		if previous[key] == current[key] && published && oldEp.Speed == feed.settings.SpeedFor(entry) && sameFormat(oldEp, format) && oldEp.Encoding == encoding {
+			unchanged = append(unchanged, entry)
+			continue
+		}
+		changed = append(changed, entry)
+	}
+	slog.InfoContext(ctx, "Compared backup with the previous one", "changed", len(changed), "unchanged", len(unchanged))
+	return changed, unchanged
+}
@@ -0,0 +1,51 @@
+package processor
+
+import (
+	"context"
+	"log/slog"
+
+	"cobblepod/internal/metadata"
+	"cobblepod/internal/podcast"
+	"cobblepod/internal/queue"
+)
+
+// SetMetadataProvider replaces the provider used to enrich feed items; nil
+// disables enrichment
+func (p *Processor) SetMetadataProvider(provider metadata.Provider) {
+	p.metadata = provider
+}
+
+// enrichEpisodes fills in publish date, description and artwork from each
+// episode's show feed. Lookups are best effort: an episode that can't be found
+// is published with what the playlist had.
+func (p *Processor) enrichEpisodes(ctx context.Context, items []queue.JobItem, episodes []podcast.ProcessedEpisode) {
+	if p.metadata == nil {
+		return
+	}
+
+	itemsByID := make(map[string]queue.JobItem, len(items))
+	for _, item := range items {
+		itemsByID[item.ID] = item
+	}
+
+	for i := range episodes {
+		item, ok := itemsByID[episodes[i].UUID]
+		if !ok || item.FeedURL == "" {
+			continue
+		}
+
+		episode, err := p.metadata.Lookup(ctx, metadata.Query{FeedURL: item.FeedURL, GUID: item.GUID, SourceURL: item.SourceURL})
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to look up episode metadata", "error", err, "title", item.Title, "feed_url", item.FeedURL)
+			continue
+		}
+		if episode == nil {
+			slog.DebugContext(ctx, "Episode not found in show feed", "title", item.Title, "feed_url", item.FeedURL)
+			continue
+		}
+
+		episodes[i].PubDate = episode.PubDate
+		episodes[i].Description = episode.Description
+		episodes[i].Image = episode.Image
+	}
+}
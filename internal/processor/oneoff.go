@@ -0,0 +1,217 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"cobblepod/internal/audio"
+	"cobblepod/internal/podcast"
+	"cobblepod/internal/queue"
+	"cobblepod/internal/storage"
+	"cobblepod/internal/transcribe"
+)
+
+// ErrNoFeed is returned by operations that need a published feed when the user has none
+var ErrNoFeed = errors.New("no published feed found")
+
+// LocalStore is a JobStore for one-off runs outside the worker. Progress is
+// logged instead of recorded, and every user gets the same settings.
+type LocalStore struct {
+	settings *queue.UserSettings
+
+	mu       sync.Mutex
+	statuses map[string]queue.JobItemStatus
+	feedLock sync.Mutex
+}
+
+var _ JobStore = (*LocalStore)(nil)
+
+// NewLocalStore returns a store serving settings, or the defaults when nil
+func NewLocalStore(settings *queue.UserSettings) *LocalStore {
+	if settings == nil {
+		settings = &queue.UserSettings{}
+	}
+	return &LocalStore{settings: settings, statuses: make(map[string]queue.JobItemStatus)}
+}
+
+func (s *LocalStore) SetJobItems(ctx context.Context, jobID string, items []queue.JobItem) error {
+	slog.InfoContext(ctx, "Processing items", "items", len(items))
+	return nil
+}
+
+// UpdateJobItem logs an item's status changes; upload progress isn't logged
+func (s *LocalStore) UpdateJobItem(ctx context.Context, jobID string, item queue.JobItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.statuses[item.ID] == item.Status {
+		return nil
+	}
+	s.statuses[item.ID] = item.Status
+	if item.Status == queue.StatusFailed {
+		slog.WarnContext(ctx, "Item failed", "title", item.Title, "error", item.Error)
+	} else {
+		slog.InfoContext(ctx, "Item "+string(item.Status), "title", item.Title)
+	}
+	return nil
+}
+
+func (s *LocalStore) SetJobFeedURL(ctx context.Context, jobID string, feedURL string) error {
+	slog.InfoContext(ctx, "Feed published", "url", feedURL)
+	return nil
+}
+
+func (s *LocalStore) PublishEvent(ctx context.Context, userID string, event queue.Event) error {
+	return nil
+}
+
+func (s *LocalStore) GetUserSettings(ctx context.Context, userID string) (*queue.UserSettings, error) {
+	settings := *s.settings
+	return &settings, nil
+}
+
+func (s *LocalStore) LockFeed(ctx context.Context, userID string) (func(), error) {
+	s.feedLock.Lock()
+	return s.feedLock.Unlock, nil
+}
+
+// GetPlaylistRules returns no rules; local sources are processed as they are
+func (s *LocalStore) GetPlaylistRules(ctx context.Context, userID string) (*queue.PlaylistRules, error) {
+	return nil, nil
+}
+
+// ReserveEncoding allows everything; quotas only apply to the shared queue
+func (s *LocalStore) ReserveEncoding(ctx context.Context, userID, jobID string, episodes int, audio time.Duration) error {
+	return nil
+}
+
+// RecordPlaylistFetch does nothing; local runs don't poll playlist URLs
+func (s *LocalStore) RecordPlaylistFetch(ctx context.Context, userID string, playlist *queue.PlaylistURL) error {
+	return nil
+}
+
+// ArchiveEpisode logs archived episodes; they stay in the archive folder, but
+// can't be restored or purged without the queue's record of them
+func (s *LocalStore) ArchiveEpisode(ctx context.Context, userID string, episode *queue.ArchivedEpisode) error {
+	slog.InfoContext(ctx, "Archived episode", "title", episode.Title, "file_id", episode.FileID)
+	return nil
+}
+
+func (s *LocalStore) GetArchivedEpisode(ctx context.Context, userID string, fileID string) (*queue.ArchivedEpisode, error) {
+	return nil, nil
+}
+
+func (s *LocalStore) ListArchivedEpisodes(ctx context.Context, userID string) ([]*queue.ArchivedEpisode, error) {
+	return nil, nil
+}
+
+func (s *LocalStore) RemoveArchivedEpisode(ctx context.Context, userID string, fileID string) error {
+	return nil
+}
+
+// RebuildFeed regenerates the user's published feed with their current
+// settings, dropping episodes whose audio is gone from storage. Episodes hosted
+// elsewhere, such as copy-through ones, are kept. It returns the feed URL and
+// the number of episodes dropped.
+func (p *Processor) RebuildFeed(ctx context.Context, userID string) (string, int, error) {
+	feed, err := p.openFeed(ctx, userID)
+	if err != nil {
+		return "", 0, err
+	}
+	if feed.feedID == "" {
+		return "", 0, ErrNoFeed
+	}
+
+	xmlFeed, dropped, err := feed.podcast.RebuildRSSXML(feed.feedXML, func(item podcast.Item) bool {
+		fileID := feed.storage.ExtractFileIDFromURL(item.Enclosure.URL)
+		if fileID == "" {
+			return true
+		}
+		exists, err := feed.storage.FileExists(fileID)
+		if err != nil {
+			slog.WarnContext(ctx, "Could not check episode file, keeping it", "title", item.Title, "error", err)
+			return true
+		}
+		if !exists {
+			slog.InfoContext(ctx, "Dropping episode whose file is gone", "title", item.Title, "file_id", fileID)
+		}
+		return exists
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	unlock, err := p.queue.LockFeed(ctx, userID)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to lock feed: %w", err)
+	}
+	defer unlock()
+	rssFileID, err := feed.storage.UploadString(xmlFeed, podcast.RSSFilename, "application/rss+xml", feed.feedID)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to upload RSS feed: %w", err)
+	}
+	return feed.storage.GenerateDownloadURL(rssFileID), dropped, nil
+}
+
+// CollectGarbage deletes audio files and transcripts in the user's feed folder
+// that the published feed doesn't reference, such as uploads of jobs that
+// failed before publishing. Files younger than minAge are left alone, since a
+// running job may not have published them yet. With dryRun nothing is
+// deleted. It returns the unreferenced files.
+func (p *Processor) CollectGarbage(ctx context.Context, userID string, minAge time.Duration, dryRun bool) ([]*storage.FileMeta, error) {
+	feed, err := p.openFeed(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	// Without the feed every episode would look unreferenced
+	if feed.feedID == "" {
+		return nil, ErrNoFeed
+	}
+	episodes, err := feed.podcast.ExtractEpisodeMapping(feed.feedXML)
+	if err != nil {
+		return nil, err
+	}
+
+	referenced := map[string]bool{feed.feedID: true}
+	for _, episode := range episodes {
+		for _, url := range []string{episode.DownloadURL, episode.TranscriptURL} {
+			if fileID := feed.storage.ExtractFileIDFromURL(url); fileID != "" {
+				referenced[fileID] = true
+			}
+		}
+	}
+
+	files, err := feed.storage.GetFiles(storage.Query{Folder: p.folders.Feed})
+	if err != nil {
+		return nil, err
+	}
+
+	var garbage []*storage.FileMeta
+	var errs []error
+	for _, file := range files {
+		if ctx.Err() != nil {
+			return garbage, ctx.Err()
+		}
+		if referenced[file.ID] || time.Since(file.ModifiedTime) < minAge {
+			continue
+		}
+		ext := strings.TrimPrefix(path.Ext(file.Name), ".")
+		if _, ok := audio.LookupFormat(ext); !ok && ext != transcribe.Extension {
+			continue
+		}
+		garbage = append(garbage, file)
+		if dryRun {
+			continue
+		}
+		slog.InfoContext(ctx, "Deleting unreferenced episode", "name", file.Name, "file_id", file.ID)
+		if err := feed.storage.DeleteFile(file.ID); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete %s: %w", file.Name, err))
+		}
+	}
+	return garbage, errors.Join(errs...)
+}
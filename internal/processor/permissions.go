@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"cobblepod/internal/podcast"
+)
+
+// RepairFeedPermissions checks that the user's feed and every enclosure in it are
+// still publicly readable, restoring any permission the storage backend dropped.
+// It returns the number of files repaired.
+func (p *Processor) RepairFeedPermissions(ctx context.Context, userID string) (int, error) {
+	tokenSource, err := p.tokenProvider.GoogleTokenSource(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get Google access token for user %s: %w", userID, err)
+	}
+	userStorage, err := p.storageCreator(ctx, tokenSource)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create storage service with user token: %w", err)
+	}
+	if err := userStorage.UseFolder(p.folders.Feed); err != nil {
+		return 0, fmt.Errorf("failed to prepare storage folder: %w", err)
+	}
+
+	podcastProcessor := podcast.NewRSSProcessor("", userStorage, p.folders)
+	rssFileID := podcastProcessor.GetRSSFeedID()
+	if rssFileID == "" {
+		slog.DebugContext(ctx, "No feed to check permissions for", "user_id", userID)
+		return 0, nil
+	}
+
+	rssContent, err := userStorage.DownloadFile(rssFileID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to download RSS feed: %w", err)
+	}
+	episodes, err := podcastProcessor.ExtractEpisodeMapping(rssContent)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse RSS feed: %w", err)
+	}
+
+	fileIDs := []string{rssFileID}
+	for _, episode := range episodes {
+		fileID := userStorage.ExtractFileIDFromURL(episode.DownloadURL)
+		if fileID == "" {
+			slog.WarnContext(ctx, "Could not extract file ID from URL", "title", episode.Title, "url", episode.DownloadURL)
+			continue
+		}
+		fileIDs = append(fileIDs, fileID)
+	}
+
+	repaired := 0
+	for _, fileID := range fileIDs {
+		if ctx.Err() != nil {
+			return repaired, ctx.Err()
+		}
+		fixed, err := userStorage.EnsurePublic(fileID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to check file permissions", "user_id", userID, "file_id", fileID, "error", err)
+			continue
+		}
+		if fixed {
+			slog.WarnContext(ctx, "Restored public permission", "user_id", userID, "file_id", fileID)
+			repaired++
+		}
+	}
+
+	slog.InfoContext(ctx, "Checked feed permissions", "user_id", userID, "files", len(fileIDs), "repaired", repaired)
+	return repaired, nil
+}
@@ -0,0 +1,192 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"cobblepod/internal/cron"
+	"cobblepod/internal/queue"
+	"cobblepod/internal/safehttp"
+	"cobblepod/internal/sources"
+
+	"github.com/google/uuid"
+)
+
+// playlistFetchTimeout bounds fetching a playlist URL
+const playlistFetchTimeout = 30 * time.Second
+
+// playlistClient fetches playlist URLs, which users supply, so it only
+// connects to public addresses
+var playlistClient = safehttp.NewClient(playlistFetchTimeout)
+
+// PlaylistURLStore is the slice of the queue polling playlist URLs needs
+type PlaylistURLStore interface {
+	GetPlaylistURLUsers(ctx context.Context) ([]string, error)
+	GetPlaylistURL(ctx context.Context, userID string) (*queue.PlaylistURL, error)
+	RecordPlaylistFetch(ctx context.Context, userID string, playlist *queue.PlaylistURL) error
+	SetPlaylistJob(ctx context.Context, userID string, rawURL string, jobID string) error
+	GetUserSettings(ctx context.Context, userID string) (*queue.UserSettings, error)
+	GetJob(ctx context.Context, jobID string) (*queue.Job, error)
+	Enqueue(ctx context.Context, job *queue.Job) error
+}
+
+var _ PlaylistURLStore = (*queue.Queue)(nil)
+
+// PlaylistRecorder remembers which fetch of a playlist URL a job processed
+type PlaylistRecorder interface {
+	RecordPlaylistFetch(ctx context.Context, userID string, playlist *queue.PlaylistURL) error
+}
+
+// PollPlaylistURLs fetches every registered playlist URL whose user's poll
+// schedule includes now with a conditional request, and enqueues a job for
+// each playlist that changed since it was last processed, unless the job of an
+// earlier change hasn't finished yet. Users without a
+// schedule of their own are polled on schedule. It returns the number of jobs
+// enqueued. A nil client uses one with a short timeout.
+func PollPlaylistURLs(ctx context.Context, store PlaylistURLStore, client *http.Client, now time.Time, schedule *cron.Schedule) int {
+	if client == nil {
+		client = playlistClient
+	}
+	users, err := store.GetPlaylistURLUsers(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get playlist URL users", "error", err)
+		return 0
+	}
+
+	enqueued := 0
+	for _, userID := range users {
+		if ctx.Err() != nil {
+			break
+		}
+		settings, err := store.GetUserSettings(ctx, userID)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to get user settings, polling on the default schedule", "error", err, "user_id", userID)
+			settings = &queue.UserSettings{}
+		}
+		if !settings.Polls(now, schedule) {
+			continue
+		}
+		changed, err := pollPlaylistURL(ctx, store, client, userID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to poll playlist URL", "error", err, "user_id", userID)
+		}
+		if changed {
+			enqueued++
+		}
+	}
+	return enqueued
+}
+
+// pollPlaylistURL polls one user's playlist URL, reporting whether it enqueued
+// a job for it
+func pollPlaylistURL(ctx context.Context, store PlaylistURLStore, client *http.Client, userID string) (bool, error) {
+	playlist, err := store.GetPlaylistURL(ctx, userID)
+	if err != nil || playlist == nil {
+		return false, err
+	}
+
+	remote, err := sources.FetchM3U8(ctx, client, playlist.URL, playlist.ETag, playlist.LastModified)
+	if err != nil || remote == nil {
+		return false, err
+	}
+
+	fetched := &queue.PlaylistURL{
+		URL:          playlist.URL,
+		ETag:         remote.ETag,
+		LastModified: remote.LastModified,
+		Checksum:     remote.Checksum(),
+	}
+	if fetched.Checksum == playlist.Checksum {
+		slog.DebugContext(ctx, "Playlist URL is unchanged", "user_id", userID)
+		return false, store.RecordPlaylistFetch(ctx, userID, fetched)
+	}
+	// The job records the fetch once it succeeds, see recordPlaylistFetch; a
+	// failed one leaves the playlist changed for the next poll to retry
+	if playlist.JobID != "" {
+		pending, err := store.GetJob(ctx, playlist.JobID)
+		if err != nil {
+			return false, fmt.Errorf("failed to get playlist job: %w", err)
+		}
+		if pending != nil && unfinished(pending.Status) {
+			slog.DebugContext(ctx, "Playlist URL changed, its job hasn't finished yet", "job_id", pending.ID, "user_id", userID)
+			return false, nil
+		}
+	}
+
+	job := &queue.Job{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Filename:    playlistName(playlist.URL),
+		PlaylistURL: playlist.URL,
+		CreatedAt:   time.Now(),
+	}
+	if err := store.Enqueue(ctx, job); errors.Is(err, queue.ErrQuotaExceeded) {
+		// Leave the playlist as changed, so it is processed once the quota frees up
+		slog.WarnContext(ctx, "Ignoring changed playlist URL, user is over quota", "error", err, "user_id", userID)
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to enqueue job: %w", err)
+	}
+	slog.InfoContext(ctx, "Enqueued job for changed playlist URL", "job_id", job.ID, "user_id", userID)
+	return true, store.SetPlaylistJob(ctx, userID, playlist.URL, job.ID)
+}
+
+// unfinished reports whether a job with the status is yet to run or running
+func unfinished(status string) bool {
+	switch status {
+	case queue.JobStatusScheduled, queue.JobStatusQueued, queue.JobStatusRunning:
+		return true
+	}
+	return false
+}
+
+// playlistName names a playlist URL's jobs after its file
+func playlistName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	if name := path.Base(u.Path); name != "/" && name != "." {
+		return name
+	}
+	return u.Host
+}
+
+// runPlaylistURL fetches the job's playlist URL and processes its entries.
+// Once they're processed, the fetch is recorded so polls stop seeing it as a
+// change.
+func (p *Processor) runPlaylistURL(ctx context.Context, job *queue.Job, feed *userFeed, backup *sources.PodcastAddictBackup) error {
+	slog.InfoContext(ctx, "Processing playlist URL", "url", job.PlaylistURL)
+	remote, err := sources.FetchM3U8(ctx, playlistClient, job.PlaylistURL, "", "")
+	if err != nil {
+		return fmt.Errorf("error processing playlist URL: %w", err)
+	}
+	entries, err := remote.Entries()
+	if err != nil {
+		return fmt.Errorf("error processing playlist URL: %w", err)
+	}
+	backup.AddListeningProgress(ctx, entries)
+	applyFeedOffsets(ctx, entries, feed.episodes)
+
+	err = p.processItems(ctx, job, entries, nil, feed)
+	var partial *PartialFailureError
+	if err != nil && !errors.As(err, &partial) {
+		return err
+	}
+	fetched := &queue.PlaylistURL{
+		URL:          job.PlaylistURL,
+		ETag:         remote.ETag,
+		LastModified: remote.LastModified,
+		Checksum:     remote.Checksum(),
+	}
+	if recordErr := p.queue.RecordPlaylistFetch(context.WithoutCancel(ctx), job.UserID, fetched); recordErr != nil {
+		slog.ErrorContext(ctx, "Failed to record playlist fetch", "error", recordErr)
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"cobblepod/internal/metadata"
+	"cobblepod/internal/queue"
+	"cobblepod/internal/tts"
+)
+
+// SetSynthesizer replaces the speech synthesizer used for spoken preambles; nil
+// disables them
+func (p *Processor) SetSynthesizer(synthesizer tts.Synthesizer) {
+	p.synthesizer = synthesizer
+}
+
+// preambles speaks a short announcement before each processed episode, so
+// runners know what they're hearing without looking at their phone
+type preambles struct {
+	synthesizer tts.Synthesizer
+	metadata    metadata.Provider
+	settings    *queue.UserSettings
+	location    *time.Location
+	now         time.Time
+}
+
+// preambles returns the job's preamble maker, or nil when the user hasn't asked
+// for them or the deployment can't synthesize speech
+func (p *Processor) preambles(settings *queue.UserSettings) *preambles {
+	if !settings.SpokenPreamble || p.synthesizer == nil {
+		return nil
+	}
+	return &preambles{
+		synthesizer: p.synthesizer,
+		metadata:    p.metadata,
+		settings:    settings,
+		location:    settings.Location(),
+		now:         time.Now(),
+	}
+}
+
+// create synthesizes the item's preamble and returns its path. Preambles are
+// best effort: "" means the episode is processed without one.
+func (pr *preambles) create(ctx context.Context, item queue.JobItem) string {
+	var show string
+	var published time.Time
+	if pr.metadata != nil && item.FeedURL != "" {
+		episode, err := pr.metadata.Lookup(ctx, metadata.Query{FeedURL: item.FeedURL, GUID: item.GUID, SourceURL: item.SourceURL})
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to look up episode metadata for preamble", "error", err, "title", item.Title)
+		} else if episode != nil {
+			show = episode.Show
+			published = episode.PubDate
+		}
+	}
+
+	path, err := pr.synthesizer.Synthesize(ctx, preambleText(show, item.Title, published.In(pr.location), pr.now.In(pr.location), pr.settings.SpeedFor(item)))
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to synthesize preamble, processing without it", "error", err, "title", item.Title)
+		return ""
+	}
+	return path
+}
+
+// remove deletes a preamble made by create
+func (pr *preambles) remove(ctx context.Context, path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil {
+		slog.WarnContext(ctx, "Failed to remove preamble", "path", path, "error", err)
+	}
+}
+
+// preambleText announces an episode, e.g. "The Daily: Title, originally
+// published March 3rd, at 1.6x". The show and date are left out when unknown,
+// and the year when it is the current one.
+func preambleText(show, title string, published, now time.Time, speed float64) string {
+	text := title
+	if show != "" && show != title {
+		text = show + ": " + title
+	}
+	if !published.IsZero() {
+		date := fmt.Sprintf("%s %s", published.Month(), ordinal(published.Day()))
+		if published.Year() != now.Year() {
+			date += fmt.Sprintf(", %d", published.Year())
+		}
+		text += ", originally published " + date
+	}
+	return text + ", at " + strconv.FormatFloat(speed, 'f', -1, 64) + "x"
+}
+
+// ordinal spells a day of the month the way it is read aloud, e.g. "3rd"
+func ordinal(day int) string {
+	suffix := "th"
+	if day/10 != 1 {
+		switch day % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return strconv.Itoa(day) + suffix
+}
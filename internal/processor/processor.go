@@ -2,28 +2,142 @@ package processor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"cobblepod/internal/audio"
 	"cobblepod/internal/auth"
 	"cobblepod/internal/config"
+	"cobblepod/internal/logging"
+	"cobblepod/internal/metadata"
 	"cobblepod/internal/podcast"
 	"cobblepod/internal/queue"
+	"cobblepod/internal/sourcecheck"
 	"cobblepod/internal/sources"
 	"cobblepod/internal/state"
 	"cobblepod/internal/storage"
+	"cobblepod/internal/tracing"
+	"cobblepod/internal/transcribe"
+	"cobblepod/internal/tts"
+
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/oauth2"
 )
 
+// defaultFeedTitle is the channel title used unless the user sets their own
+const defaultFeedTitle = "Playrun Addict Custom Feed"
+
+// estimatedBytesPerSecond approximates encoded output size (128 kbit/s MP3)
+const estimatedBytesPerSecond = 128 * 1000 / 8
+
+// ErrInsufficientStorage is returned when the storage backend lacks room for a job's output
+var ErrInsufficientStorage = errors.New("insufficient storage space")
+
+// PartialFailureError is returned when a job published its feed but some items failed
+type PartialFailureError struct {
+	Failed int
+	Total  int
+}
+
+func (e *PartialFailureError) Error() string {
+	return fmt.Sprintf("%d of %d items failed", e.Failed, e.Total)
+}
+
+// maxPanicStack caps the stack trace kept in a panicked job's fail reason
+const maxPanicStack = 4096
+
+// PanicError is returned when processing a job panicked, e.g. on a malformed
+// backup, so the job fails instead of the whole worker
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("job processing panicked: %v", e.Value)
+}
+
+// FailReason returns the panic with its stack trace, truncated to maxPanicStack bytes
+func (e *PanicError) FailReason() string {
+	stack := e.Stack
+	if len(stack) > maxPanicStack {
+		stack = stack[:maxPanicStack]
+	}
+	return fmt.Sprintf("%s\n%s", e.Error(), stack)
+}
+
+// Recover converts a panic into a *PanicError stored in err. Defer it directly:
+//
+//	defer processor.Recover(&err)
+func Recover(err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{Value: r, Stack: debug.Stack()}
+	}
+}
+
+// panicGuard keeps the first panic of a job's pipeline goroutines, which would
+// otherwise crash the process before the worker could recover it
+type panicGuard struct {
+	mu  sync.Mutex
+	err error
+}
+
+// recover records a panic of the calling goroutine; defer it directly
+func (g *panicGuard) recover() {
+	if r := recover(); r != nil {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.err == nil {
+			g.err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}
+}
+
+// Err returns the recorded panic, if any
+func (g *panicGuard) Err() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.err
+}
+
 // Task represents a processing task for a single episode
 type Task struct {
 	Item     queue.JobItem
 	TempPath string
 	Result   podcast.ProcessedEpisode
 	Err      error
+	// Retrim is set when the task cuts an existing episode instead of processing the source
+	Retrim *Retrim
+	// SourceDuration is the source's actual length, when known; Item.Duration is what the playlist listed
+	SourceDuration time.Duration
+	// Speed is the playback speed the item is processed at, see UserSettings.SpeedFor
+	Speed float64
+	// TrimSilence removes long silences from the item, see UserSettings.TrimSilenceFor
+	TrimSilence bool
+	// Skip is the skip rule of the item's podcast, if any, and Chapters the
+	// chapters of the downloaded source it may cut
+	Skip     *queue.SkipRule
+	Chapters []audio.Chapter
+	// TranscriptPath is the episode's transcript, written by transcribeWorker
+	TranscriptPath string
+}
+
+// Retrim describes an existing episode whose listening offset moved forward:
+// its processed audio is downloaded from storage and only trimmed
+type Retrim struct {
+	FileID string
+	Trim   time.Duration
+	// GUID keeps the episode's feed GUID, so players treat it as the same episode
+	GUID string
 }
 
 // StorageDeleter interface for dependency injection
@@ -32,82 +146,220 @@ type StorageDeleter interface {
 	DeleteFile(fileID string) error
 }
 
-// JobTracker interface for tracking job progress
-type JobTracker interface {
+// ProgressTracker records job and item progress. The queue is the single
+// source of truth for progress, so *queue.Queue is the production implementation.
+type ProgressTracker interface {
 	SetJobItems(ctx context.Context, jobID string, items []queue.JobItem) error
 	UpdateJobItem(ctx context.Context, jobID string, item queue.JobItem) error
+	SetJobFeedURL(ctx context.Context, jobID string, feedURL string) error
+	PublishEvent(ctx context.Context, userID string, event queue.Event) error
+}
+
+// SettingsProvider supplies per-user preferences such as time zone
+type SettingsProvider interface {
+	GetUserSettings(ctx context.Context, userID string) (*queue.UserSettings, error)
+}
+
+// FeedLocker serializes feed writes between concurrent jobs of the same user
+type FeedLocker interface {
+	LockFeed(ctx context.Context, userID string) (unlock func(), err error)
+}
+
+// RulesProvider supplies the playlist rules users select episodes with
+type RulesProvider interface {
+	GetPlaylistRules(ctx context.Context, userID string) (*queue.PlaylistRules, error)
+}
+
+// QuotaKeeper counts the work a job is about to do against its user's quotas
+type QuotaKeeper interface {
+	ReserveEncoding(ctx context.Context, userID, jobID string, episodes int, audio time.Duration) error
 }
 
-// StorageCreator function type for creating storage service
-type StorageCreator func(ctx context.Context, accessToken string) (storage.Storage, error)
+// JobStore is everything the processor needs from the queue
+type JobStore interface {
+	ProgressTracker
+	SettingsProvider
+	FeedLocker
+	QuotaKeeper
+	RulesProvider
+	Archiver
+	PlaylistRecorder
+}
+
+var _ JobStore = (*queue.Queue)(nil)
+
+// StorageCreator function type for creating storage service. It takes a token
+// source rather than a token because jobs can outlive a single access token.
+type StorageCreator func(ctx context.Context, tokenSource oauth2.TokenSource) (storage.Storage, error)
+
+// linkEpisodes wraps create so feeds link episodes through the server when
+// cfg.EpisodeBaseURL is set, see storage.EpisodeLinks
+func linkEpisodes(create StorageCreator, cfg config.ServerConfig) StorageCreator {
+	if cfg.EpisodeBaseURL == "" {
+		return create
+	}
+	links := storage.NewEpisodeLinks(cfg.EpisodeBaseURL, cfg.EpisodeSecret)
+	return func(ctx context.Context, tokenSource oauth2.TokenSource) (storage.Storage, error) {
+		s, err := create(ctx, tokenSource)
+		if err != nil {
+			return nil, err
+		}
+		return storage.WithEpisodeLinks(s, links), nil
+	}
+}
+
+// guardStorage wraps create so the storage it creates stops calling the
+// backend while it's down, see storage.Breaker. The storage of every user
+// shares one breaker, as an outage affects them all.
+func guardStorage(create StorageCreator, cfg config.StorageConfig) StorageCreator {
+	breaker := storage.NewBreaker(cfg.BreakerFailures, cfg.BreakerCooldown())
+	return func(ctx context.Context, tokenSource oauth2.TokenSource) (storage.Storage, error) {
+		s, err := create(ctx, tokenSource)
+		if err != nil {
+			return nil, err
+		}
+		return storage.WithBreaker(ctx, s, breaker), nil
+	}
+}
 
 // Processor handles the main processing logic
 type Processor struct {
 	state          *state.CobblepodStateManager
 	tokenProvider  auth.TokenProvider
 	storageCreator StorageCreator
-	queue          JobTracker
+	queue          JobStore
+	metadata       metadata.Provider
+	synthesizer    tts.Synthesizer
+	transcriber    transcribe.Transcriber
+	// audioFactory returns a fresh audio processor for each run, see SetAudioFactory
+	audioFactory func() audio.Processor
+	// worker holds the deployment's processing settings, see SetWorkerConfig
+	worker config.WorkerConfig
+	// folders are where users' feeds are kept in their storage
+	folders podcast.Folders
 }
 
-// NewProcessor creates a new processor with default dependencies
-func NewProcessor(ctx context.Context, q *queue.Queue) (*Processor, error) {
-	state, err := state.NewStateManager(ctx)
+// NewProcessor creates a new processor with default dependencies for the
+// deployment's settings
+func NewProcessor(ctx context.Context, q *queue.Queue, cfg *config.Config) (*Processor, error) {
+	state, err := state.NewStateManager(ctx, cfg.Valkey)
 	if err != nil {
-		slog.Error("Failed to connect to state", "error", err)
+		slog.ErrorContext(ctx, "Failed to connect to state", "error", err)
 		// Continue with nil state manager - we'll handle this in Run()
+	} else {
+		// Estimate new jobs' completion from the runs this processor records
+		q.SetEncodeRater(state)
+	}
+
+	provider, err := auth.NewProvider(cfg.Auth, q)
+	if err != nil {
+		return nil, err
 	}
 
 	return &Processor{
 		state:          state,
-		tokenProvider:  &auth.DefaultTokenProvider{},
-		storageCreator: storage.NewServiceWithToken,
+		tokenProvider:  provider,
+		storageCreator: guardStorage(linkEpisodes(storage.NewServiceWithTokenSource, cfg.Server), cfg.Storage),
 		queue:          q,
+		metadata:       metadata.NewRSSProvider(nil),
+		synthesizer:    tts.Configured(cfg.Worker),
+		transcriber:    transcribe.Configured(cfg.Worker),
+		worker:         cfg.Worker,
+		folders:        podcast.NewFolders(cfg.Storage.DriveFolder),
 	}, nil
 }
 
-// NewProcessorWithDependencies creates a new processor with injected dependencies for testing
+// NewProcessorWithDependencies creates a new processor with injected
+// dependencies, keeping users' feeds in folders
 func NewProcessorWithDependencies(
 	state *state.CobblepodStateManager,
 	tokenProvider auth.TokenProvider,
 	storageCreator StorageCreator,
-	q JobTracker,
+	q JobStore,
+	folders podcast.Folders,
 ) *Processor {
 	return &Processor{
 		state:          state,
 		tokenProvider:  tokenProvider,
 		storageCreator: storageCreator,
 		queue:          q,
+		worker:         config.Defaults().Worker,
+		folders:        folders,
+	}
+}
+
+// SetWorkerConfig replaces the processing settings, such as the FFmpeg binary
+// and the archive retention, the processor was created with
+func (p *Processor) SetWorkerConfig(cfg config.WorkerConfig) {
+	p.worker = cfg
+}
+
+// SetAudioFactory replaces how runs get their audio processor, e.g. with one
+// returning an audio.Fake; nil uses audio.Configured
+func (p *Processor) SetAudioFactory(factory func() audio.Processor) {
+	p.audioFactory = factory
+}
+
+// newAudio returns the audio processor for a run
+func (p *Processor) newAudio() audio.Processor {
+	if p.audioFactory != nil {
+		return p.audioFactory()
 	}
+	return audio.Configured(p.worker)
 }
 
-// Run executes the main processing logic for the given job
-func (p *Processor) Run(ctx context.Context, job *queue.Job) error {
+// Run executes the main processing logic for the given job. A job with a
+// LocalPath processes that M3U8 or backup file instead of looking for sources
+// in storage; episodes are still downloaded from their hosts and published to
+// the user's storage.
+func (p *Processor) Run(ctx context.Context, job *queue.Job) (runErr error) {
 	if job == nil {
 		return fmt.Errorf("job cannot be nil")
 	}
 
-	slog.Info("Processing job", "job_id", job.ID, "file_id", job.FileID, "user_id", job.UserID)
+	// Every line logged for the job carries its IDs, see logging.With
+	ctx = logging.With(ctx, "job_id", job.ID, "user_id", job.UserID)
+	slog.InfoContext(ctx, "Processing job", "file_id", job.FileID)
 
-	// Get Google access token for the user
-	googleToken, err := p.tokenProvider.GetGoogleAccessToken(ctx, job.UserID)
-	if err != nil {
-		return fmt.Errorf("failed to get Google access token for user %s: %w", job.UserID, err)
+	if job.LocalPath != "" {
+		return p.runLocal(ctx, job)
 	}
 
-	slog.Info("Successfully obtained Google access token for user", "user_id", job.UserID)
-
-	// Create storage service with user's Google token
-	userStorage, err := p.storageCreator(ctx, googleToken)
+	userStorage, err := p.connectStorage(ctx, job.UserID)
 	if err != nil {
-		return fmt.Errorf("failed to create storage service with user token: %w", err)
+		return err
 	}
 
 	// TODO: Stop processing M3U8 files
 	m3u8src := sources.NewM3U8Source(userStorage)
 	podcastAddictBackup := sources.NewPodcastAddictBackup(userStorage)
+	podcastAddictBackup.SetMetadataProvider(p.metadata)
+	rules, err := p.queue.GetPlaylistRules(ctx, job.UserID)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to load playlist rules, using the backup's playlist", "error", err)
+	}
+	podcastAddictBackup.SetRules(rules)
 
-	audioProcessor := audio.NewProcessor()
-	podcastProcessor := podcast.NewRSSProcessor("Playrun Addict Custom Feed", userStorage)
+	// Restore, retry and playlist URL jobs neither look at the storage sources
+	// nor move their change tracking state forward
+	if job.RestoreFileID != "" || job.RetryOf != "" || job.PlaylistURL != "" {
+		feed, err := p.loadFeed(ctx, job.UserID, userStorage)
+		if err != nil {
+			return err
+		}
+		switch {
+		case job.RestoreFileID != "":
+			// Restore jobs republish an archived episode and nothing else
+			return p.runRestore(ctx, job, feed)
+		case job.RetryOf != "":
+			// Retry jobs carry their items with them
+			slog.InfoContext(ctx, "Retrying failed items", "retry_of", job.RetryOf, "items", len(job.Items))
+			return p.processItems(ctx, job, job.Items, nil, feed)
+		default:
+			// Playlist URL jobs are enqueued when the poller saw the playlist change
+			return p.runPlaylistURL(ctx, job, feed, podcastAddictBackup)
+		}
+	}
 
 	// Use the stored state manager
 	stateManager := p.state
@@ -115,139 +367,454 @@ func (p *Processor) Run(ctx context.Context, job *queue.Job) error {
 
 	if stateManager != nil {
 		var err error
-		appState, err = stateManager.GetState()
+		appState, err = stateManager.GetState(ctx, job.UserID)
 		if err != nil {
-			slog.Error("Failed to get state", "error", err)
-			slog.Info("Assuming first run")
+			slog.ErrorContext(ctx, "Failed to get state", "error", err)
+			slog.InfoContext(ctx, "Assuming first run")
 			appState = &state.CobblepodState{}
 		} else {
-			slog.Debug("State loaded", "last_run", appState.LastRun.Format(time.RFC3339))
+			slog.DebugContext(ctx, "State loaded", "sources", len(appState.Sources))
 		}
 	} else {
-		slog.Info("State manager not available, assuming first run")
+		slog.InfoContext(ctx, "State manager not available, assuming first run")
 		appState = &state.CobblepodState{}
 	}
 
-	// Get RSS feed and extract episode mapping
-	rssFileID := podcastProcessor.GetRSSFeedID()
-	episodeMapping := make(map[string]podcast.ExistingEpisode)
-	if rssFileID != "" {
-		rssContent, err := userStorage.DownloadFile(rssFileID)
-		if err != nil {
-			slog.Error("Error downloading RSS feed", "error", err)
-		} else {
-			episodeMapping, err = podcastProcessor.ExtractEpisodeMapping(rssContent)
-			if err != nil {
-				slog.Error("Error extracting episode mapping", "error", err)
-			}
-		}
+	// Look at the sources' metadata before downloading anything
+	found, err := sourcecheck.Detect(ctx, userStorage, appState)
+	if err != nil {
+		return err
 	}
-
-	startTime := time.Now()
+	changes, m3u8File, backupFile := found.Changes, found.M3U8, found.Backup
+
+	// seen holds the file of each source kind this run used, which moves the
+	// kind's watermark once the run succeeds, and processedFile the one it
+	// handled, whose checksum is remembered too
+	seen := make(map[string]*sources.FileInfo)
+	var processedFile *sources.FileInfo
+	// processedPlaylist fingerprints the backup this run handled, see diffPlaylist
+	var processedPlaylist map[string]string
 	defer func() {
-		if stateManager != nil {
-			if err := stateManager.SaveState(&state.CobblepodState{LastRun: startTime}); err != nil {
-				slog.Error("Failed to save state", "error", err)
+		// Only move past these changes once they've been handled, so a failed run sees them again
+		var partial *PartialFailureError
+		if stateManager == nil || (runErr != nil && !errors.As(runErr, &partial)) {
+			return
+		}
+		// Another job of the user's may have saved state since this one loaded
+		// it, so the update applies to whatever is stored now
+		err := stateManager.UpdateState(context.WithoutCancel(ctx), job.UserID, func(newState *state.CobblepodState) {
+			if changes != nil {
+				newState.ChangesPageToken = changes.NextPageToken
+			}
+			for source, file := range seen {
+				cursor := *newState.Source(source)
+				if cursor.FileID != "" && cursor.ModifiedTime.After(file.ModifiedTime) {
+					continue // the other job handled a later file
+				}
+				cursor.FileID, cursor.ModifiedTime = file.File.ID, file.ModifiedTime
+				if file == processedFile && file.File.MD5 != "" {
+					cursor.Checksum = file.File.MD5
+				}
+				if file == processedFile && processedPlaylist != nil {
+					cursor.Playlist = processedPlaylist
+				}
+				newState.SetSource(source, &cursor)
 			}
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to save state", "error", err)
 		}
 	}()
 
-	// Check for new M3U8 file
-	m3u8File, err := m3u8src.GetLatest(ctx)
-	if err != nil {
-		return fmt.Errorf("error getting latest M3U8 file: %w", err)
-	}
-
-	newM3U8 := false
-	if m3u8File != nil && (appState.LastRun.IsZero() || m3u8File.ModifiedTime.After(appState.LastRun)) {
-		newM3U8 = true
+	if !found.Any() {
+		slog.DebugContext(ctx, "No new M3U8 or backup files found since last run")
+		return nil
 	}
-
-	// Check for new backup file
-	backupFile, err := podcastAddictBackup.GetLatest(ctx)
+	feed, err := p.loadFeed(ctx, job.UserID, userStorage)
 	if err != nil {
-		slog.Error("Error getting latest backup file", "error", err)
-	}
-
-	newBackup := false
-	if backupFile != nil && (appState.LastRun.IsZero() || backupFile.ModifiedTime.After(appState.LastRun)) {
-		newBackup = true
+		return err
 	}
 
 	// Determine processing mode
-	var entries []queue.JobItem
-	if newM3U8 {
-		slog.Info("Processing M3U8 file", "name", m3u8File.File.Name, "modified", m3u8File.ModifiedTime.Format(time.RFC3339))
+	var entries, carried []queue.JobItem
+	if found.NewM3U8 {
+		slog.InfoContext(ctx, "Processing M3U8 file", "name", m3u8File.File.Name, "modified", m3u8File.ModifiedTime.Format(time.RFC3339))
 
 		entries, err = m3u8src.Process(ctx, m3u8File)
 		if err != nil {
 			return fmt.Errorf("error processing M3U8 file: %w", err)
 		}
+		processedFile = m3u8File
+		seen[sourcecheck.M3U8] = m3u8File
+		// The backup only adds listening progress, so it's seen rather than processed
+		if backupFile != nil && backupFile.File != nil {
+			seen[sourcecheck.Backup] = backupFile
+		}
 
 		// Process M3U8 as before, including backup for offsets
 		podcastAddictBackup.AddListeningProgress(ctx, entries)
-	} else if newBackup {
-		slog.Info("Processing backup independently", "name", backupFile.FileName, "modified", backupFile.ModifiedTime.Format(time.RFC3339))
+		applyFeedOffsets(ctx, entries, feed.episodes)
+	} else {
+		slog.InfoContext(ctx, "Processing backup independently", "name", backupFile.FileName, "modified", backupFile.ModifiedTime.Format(time.RFC3339))
 
 		// Process backup independently
 		entries, err = podcastAddictBackup.Process(ctx, backupFile)
 		if err != nil {
 			return fmt.Errorf("error processing backup independently: %w", err)
 		}
-	} else {
-		slog.Debug("No new M3U8 or backup files found since last run")
+		processedFile = backupFile
+		seen[sourcecheck.Backup] = backupFile
+
+		// Only process what changed since the previous backup
+		previous := appState.Source(sourcecheck.Backup).Playlist
+		processedPlaylist = playlistFingerprint(entries)
+		if len(entries) > 0 {
+			entries, carried = p.diffPlaylist(ctx, entries, previous, feed)
+			if len(entries) == 0 && !playlistShrank(previous, processedPlaylist) {
+				slog.InfoContext(ctx, "Backup playlist is unchanged, skipping", "name", backupFile.FileName)
+				return nil
+			}
+		}
+	}
+	if len(entries) == 0 && len(carried) == 0 {
+		slog.InfoContext(ctx, "No entries found in M3U8 file")
 		return nil
 	}
+
+	return p.processItems(ctx, job, entries, carried, feed)
+}
+
+// runLocal processes the job's local source file. It bypasses change tracking,
+// so the same file can be processed again.
+func (p *Processor) runLocal(ctx context.Context, job *queue.Job) error {
+	entries, err := sources.ReadLocalFile(ctx, job.LocalPath, p.metadata)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", job.LocalPath, err)
+	}
 	if len(entries) == 0 {
-		slog.Info("No entries found in M3U8 file")
+		slog.InfoContext(ctx, "No entries found in local source", "path", job.LocalPath)
 		return nil
 	}
 
+	feed, err := p.openFeed(ctx, job.UserID)
+	if err != nil {
+		return err
+	}
+	// Playlists carry no offsets; backups hold the current ones
+	if !strings.EqualFold(filepath.Ext(job.LocalPath), ".backup") {
+		applyFeedOffsets(ctx, entries, feed.episodes)
+	}
+	return p.processItems(ctx, job, entries, nil, feed)
+}
+
+// userFeed is a user's storage, settings and published feed, as a run needs them
+type userFeed struct {
+	storage  storage.Storage
+	settings *queue.UserSettings
+	audio    audio.Processor
+	podcast  *podcast.RSSProcessor
+	// feedID and feedXML are the published feed, empty if there is none yet
+	feedID  string
+	feedXML string
+	// episodes maps the titles in the published feed to their episodes
+	episodes map[string]podcast.ExistingEpisode
+}
+
+// connectStorage returns the user's storage, authorized with their Google token
+func (p *Processor) connectStorage(ctx context.Context, userID string) (storage.Storage, error) {
+	// Get a refreshing Google token source for the user
+	tokenSource, err := p.tokenProvider.GoogleTokenSource(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Google access token for user %s: %w", userID, err)
+	}
+
+	slog.InfoContext(ctx, "Successfully obtained Google access token for user", "user_id", userID)
+
+	// Create storage service with user's Google token
+	userStorage, err := p.storageCreator(ctx, tokenSource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage service with user token: %w", err)
+	}
+	return userStorage, nil
+}
+
+// openFeed connects to the user's storage and loads their feed, see loadFeed
+func (p *Processor) openFeed(ctx context.Context, userID string) (*userFeed, error) {
+	userStorage, err := p.connectStorage(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return p.loadFeed(ctx, userID, userStorage)
+}
+
+// loadFeed points the user's storage at their feed folder and loads their
+// settings and the episodes of their published feed
+func (p *Processor) loadFeed(ctx context.Context, userID string, userStorage storage.Storage) (*userFeed, error) {
+	// Keep episodes and the feed together instead of loose in the Drive root
+	if err := userStorage.UseFolder(p.folders.Feed); err != nil {
+		return nil, fmt.Errorf("failed to prepare storage folder: %w", err)
+	}
+	userStorage.SetTags(map[string]string{storage.TagUser: userID, storage.TagFeed: p.folders.Feed})
+
+	settings, err := p.queue.GetUserSettings(ctx, userID)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to load user settings, using defaults", "error", err, "user_id", userID)
+		settings = &queue.UserSettings{}
+	}
+
+	audioProcessor := p.newAudio()
+	audioProcessor.SetOutputFormat(settings.Format())
+	audioProcessor.SetBitrate(settings.BitrateKbps)
+	audioProcessor.SetMono(settings.Mono)
+
+	feedTitle := defaultFeedTitle
+	if settings.FeedTitle != "" {
+		feedTitle = settings.FeedTitle
+	}
+	podcastProcessor := podcast.NewRSSProcessor(feedTitle, userStorage, p.folders)
+	podcastProcessor.SetLocation(settings.Location())
+
+	// Get RSS feed and extract episode mapping
+	rssFileID := podcastProcessor.GetRSSFeedID()
+	episodeMapping := make(map[string]podcast.ExistingEpisode)
+	var rssContent string
+	if rssFileID != "" {
+		rssContent, err = userStorage.DownloadFile(rssFileID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error downloading RSS feed", "error", err)
+		} else {
+			episodeMapping, err = podcastProcessor.ExtractEpisodeMapping(rssContent)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error extracting episode mapping", "error", err)
+			}
+		}
+	}
+
+	return &userFeed{
+		storage:  userStorage,
+		settings: settings,
+		audio:    audioProcessor,
+		podcast:  podcastProcessor,
+		feedID:   rssFileID,
+		feedXML:  rssContent,
+		episodes: episodeMapping,
+	}, nil
+}
+
+// processItems encodes and uploads the job's entries, publishes the feed with
+// them and the carried entries, and removes episodes that dropped out of it
+func (p *Processor) processItems(ctx context.Context, job *queue.Job, entries []queue.JobItem, carried []queue.JobItem, feed *userFeed) error {
+	settings, episodeMapping, userStorage := feed.settings, feed.episodes, feed.storage
+	// Fail early if the user's storage can't hold the output
+	if err := checkStorageQuota(ctx, userStorage, entries, settings, p.worker.MinFreeStorageBytes()); err != nil {
+		return err
+	}
+
+	// Carry over uploads from a previous attempt of this job so they aren't redone
+	entries = mergeUploadedItems(job.Items, entries)
+
+	// Count the new episodes against the user's quotas before any work starts
+	episodes, length := newAudio(entries, episodeMapping, settings)
+	if err := p.queue.ReserveEncoding(ctx, job.UserID, job.ID, episodes, length); err != nil {
+		return err
+	}
+
 	// Populate job items
 	if err := p.queue.SetJobItems(ctx, job.ID, entries); err != nil {
-		slog.Error("Failed to set job items", "error", err)
+		slog.ErrorContext(ctx, "Failed to set job items", "error", err)
 	}
 	job.Items = entries
 
-	reused, err := p.processEntries(ctx, episodeMapping, userStorage, audioProcessor, podcastProcessor, job)
+	return p.processEntries(ctx, settings, episodeMapping, userStorage, feed.audio, feed.podcast, job, carried)
+}
+
+// encoding returns the audio joined around episodes made with settings and the
+// tagged clips, and the whole encoding recorded with them, both of which reuse
+// must match. Episodes made by a fake audio processor are tagged as such.
+func (p *Processor) encoding(settings *queue.UserSettings, clips string, audioProcessor audio.Processor) (string, string) {
+	joined := clips
+	if p.preambles(settings) != nil {
+		joined = strings.TrimSpace(joined + " preamble")
+	}
+	encoding := strings.TrimSpace(settings.Encoding() + " " + joined)
+	if _, fake := audioProcessor.(*audio.Fake); fake {
+		encoding = strings.TrimSpace(encoding + " " + audio.FakeEncoding)
+	}
+	return joined, encoding
+}
+
+// mergeUploadedItems copies the ID, status and storage key of items that were already
+// uploaded by a previous attempt of the same job onto the matching new entries
+func mergeUploadedItems(previous, entries []queue.JobItem) []queue.JobItem {
+	uploaded := make(map[string]queue.JobItem)
+	for _, item := range previous {
+		if item.Status == queue.StatusCompleted && item.DriveFileID != "" {
+			uploaded[item.Title+"\x00"+item.SourceURL] = item
+		}
+	}
+	if len(uploaded) == 0 {
+		return entries
+	}
+
+	for i, entry := range entries {
+		prev, ok := uploaded[entry.Title+"\x00"+entry.SourceURL]
+		if !ok || prev.Duration != entry.Duration || prev.Offset != entry.Offset {
+			continue
+		}
+		entries[i].ID = prev.ID
+		entries[i].Status = prev.Status
+		entries[i].DriveFileID = prev.DriveFileID
+		entries[i].Progress = prev.Progress
+		entries[i].Speed = prev.Speed
+		entries[i].ContentType = prev.ContentType
+		entries[i].Cuts = prev.Cuts
+	}
+	return entries
+}
+
+// applyFeedOffsets gives entries without a listening offset the one the
+// published feed last recorded for them, so positions survive playlist updates
+// that come without a backup to read them from
+func applyFeedOffsets(ctx context.Context, entries []queue.JobItem, episodeMapping map[string]podcast.ExistingEpisode) {
+	for i, entry := range entries {
+		if entry.Offset != 0 {
+			continue
+		}
+		_, oldEp, ok := podcast.FindEpisode(episodeMapping, entry)
+		// A different length means a different source episode, which the offset doesn't fit
+		if !ok || oldEp.Offset <= 0 || oldEp.Listed() != entry.Duration || oldEp.Offset >= oldEp.OriginalDuration {
+			continue
+		}
+		slog.DebugContext(ctx, "Using listening offset from the feed", "title", entry.Title, "offset", oldEp.Offset)
+		entries[i].Offset = oldEp.Offset
+	}
+}
+
+// stageBuffer is how many finished tasks each pipeline stage may hold for the next
+const stageBuffer = 2
+
+// drain discards what's left of a pipeline stage's input
+func drain(tasks <-chan Task) {
+	for range tasks {
+	}
+}
+
+// sortEpisodes orders episodes by their position in the playlist, keeping
+// episodes without one, from items saved before positions were recorded, last
+func sortEpisodes(episodes []podcast.ProcessedEpisode) {
+	sort.SliceStable(episodes, func(i, j int) bool {
+		a, b := episodes[i].Position, episodes[j].Position
+		if (a == 0) != (b == 0) {
+			return b == 0
+		}
+		return a < b
+	})
+}
+
+// resumable reports whether an item was uploaded by an earlier attempt of the
+// job with the encoding the job would use now. Checkpoints written before the
+// encoding was recorded are trusted.
+func resumable(item queue.JobItem, speed float64, format audio.Format) bool {
+	if item.Status != queue.StatusCompleted || item.DriveFileID == "" {
+		return false
+	}
+	if item.Speed != 0 && item.Speed != speed {
+		return false
+	}
+	return item.ContentType == "" || item.ContentType == format.ContentType
+}
+
+// newAudio returns how many entries are neither in the published feed nor
+// uploaded by a previous attempt, and how much audio they hold
+func newAudio(entries []queue.JobItem, episodeMapping map[string]podcast.ExistingEpisode, settings *queue.UserSettings) (int, time.Duration) {
+	episodes, length := 0, time.Duration(0)
+	for _, item := range entries {
+		if resumable(item, settings.SpeedFor(item), settings.Format()) {
+			continue
+		}
+		if _, _, ok := podcast.FindEpisode(episodeMapping, item); ok {
+			continue
+		}
+		episodes++
+		length += item.Duration - item.Offset
+	}
+	return episodes, length
+}
+
+// checkStorageQuota verifies the storage backend has room for the processed episodes
+// plus minFree bytes to spare. Quota lookup failures are logged and ignored.
+func checkStorageQuota(ctx context.Context, storageService storage.Storage, entries []queue.JobItem, settings *queue.UserSettings, minFree int64) error {
+	quota, err := storageService.Quota()
 	if err != nil {
-		return err
+		slog.WarnContext(ctx, "Could not check storage quota, continuing", "error", err)
+		return nil
 	}
 
-	// Delete unused episodes from storage backend
-	p.deleteUnusedEpisodes(userStorage, episodeMapping, reused)
+	available := quota.Available()
+	if available < 0 {
+		return nil // Unlimited
+	}
+
+	required := minFree
+	for _, entry := range entries {
+		remaining := (entry.Duration - entry.Offset).Seconds() / settings.SpeedFor(entry)
+		if remaining > 0 {
+			required += int64(remaining * estimatedBytesPerSecond)
+		}
+	}
+
+	if available < required {
+		return fmt.Errorf("%w: %d MB available, about %d MB required", ErrInsufficientStorage, available/(1024*1024), required/(1024*1024))
+	}
 
+	slog.DebugContext(ctx, "Storage quota check passed", "available_bytes", available, "required_bytes", required)
 	return nil
 }
 
-// downloadWorker handles download requests
-func downloadWorker(ctx context.Context, processor *audio.Processor, tasks <-chan Task, results chan<- Task, q JobTracker, jobID string) {
+// downloadWorker handles download requests. Once the context is cancelled the
+// remaining tasks are passed on failed, so every stage drains.
+func downloadWorker(ctx context.Context, processor audio.Processor, storageService storage.Storage, tasks <-chan Task, results chan<- Task, q ProgressTracker, jobID string) {
 	defer close(results)
 	for task := range tasks {
+		itemCtx := logging.With(ctx, "item_id", task.Item.ID)
 		// Check if context was cancelled
 		select {
 		case <-ctx.Done():
 			task.Err = ctx.Err()
 			results <- task
-			return
+			continue
 		default:
 		}
 
 		// Update status
 		task.Item.Status = queue.StatusDownloading
-		if err := q.UpdateJobItem(ctx, jobID, task.Item); err != nil {
-			slog.Error("Failed to update job item status", "error", err)
+		if err := q.UpdateJobItem(itemCtx, jobID, task.Item); err != nil {
+			slog.ErrorContext(itemCtx, "Failed to update job item status", "error", err)
 		}
 
-		tempPath, err := processor.DownloadFile(task.Item.SourceURL)
+		var tempPath string
+		var err error
+		if task.Retrim != nil {
+			_, span := tracing.Start(itemCtx, "storage.download", attribute.String("item.id", task.Item.ID), attribute.String("file.id", task.Retrim.FileID))
+			tempPath, err = storageService.DownloadFileToTemp(task.Retrim.FileID)
+			tracing.End(span, err)
+		} else {
+			_, span := tracing.Start(itemCtx, "audio.download", attribute.String("item.id", task.Item.ID), attribute.String("source.url", task.Item.SourceURL))
+			tempPath, err = processor.DownloadFile(itemCtx, task.Item.SourceURL)
+			tracing.End(span, err)
+			if err == nil {
+				task.SourceDuration, task.Chapters = probeSource(itemCtx, processor, task.Item, tempPath)
+			}
+		}
 		task.TempPath = tempPath
 		task.Err = err
 
 		if err != nil {
+			slog.ErrorContext(itemCtx, "Download failed", "title", task.Item.Title, "error", err)
 			task.Item.Status = queue.StatusFailed
 			task.Item.Error = err.Error()
-			if err := q.UpdateJobItem(ctx, jobID, task.Item); err != nil {
-				slog.Error("Failed to update job item status", "error", err)
+			if err := q.UpdateJobItem(itemCtx, jobID, task.Item); err != nil {
+				slog.ErrorContext(itemCtx, "Failed to update job item status", "error", err)
 			}
 		}
 
@@ -255,44 +822,138 @@ func downloadWorker(ctx context.Context, processor *audio.Processor, tasks <-cha
 	}
 }
 
-// ffmpegWorker handles FFmpeg processing requests
-func ffmpegWorker(ctx context.Context, processor *audio.Processor, tasks <-chan Task, results chan<- Task, speed float64, q JobTracker, jobID string) {
+// probeSource returns the actual length and the chapters of a downloaded
+// source. The length is zero when it can't be read and the playlist's duration
+// has to do.
+func probeSource(ctx context.Context, processor audio.Processor, item queue.JobItem, path string) (time.Duration, []audio.Chapter) {
+	probe, err := processor.ProbeFile(path)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to probe downloaded audio, using the playlist duration", "title", item.Title, "error", err)
+		return 0, nil
+	}
+	if probe.Duration == 0 {
+		return 0, probe.Chapters
+	}
+	if diff := probe.Duration - item.Duration; diff > time.Second || diff < -time.Second {
+		slog.InfoContext(ctx, "Playlist duration differs from the audio", "title", item.Title, "listed", item.Duration, "actual", probe.Duration)
+	}
+	return probe.Duration, probe.Chapters
+}
+
+// cutLength is how much source audio cuts leave out
+func cutLength(cuts []queue.Cut) time.Duration {
+	var total time.Duration
+	for _, cut := range cuts {
+		total += cut.End - cut.Start
+	}
+	return total
+}
+
+// skipCuts returns the parts of a source after the item's offset its podcast's
+// skip rule leaves out, both in source time, as recorded on the job item, and
+// as segments of the audio FFmpeg reads from the offset on, with their total
+// length. A rule that would leave nothing of the episode cuts nothing.
+func skipCuts(ctx context.Context, rule *queue.SkipRule, item queue.JobItem, duration time.Duration, chapters []audio.Chapter) ([]queue.Cut, []audio.Segment, time.Duration) {
+	if rule == nil {
+		return nil, nil, 0
+	}
+	var cuts []queue.Cut
+	var segments []audio.Segment
+	var total time.Duration
+	for _, cut := range rule.Cuts(duration, chapters) {
+		start, end := max(cut.Start, item.Offset), cut.End
+		if duration > 0 {
+			end = min(end, duration)
+		}
+		if end <= start {
+			continue
+		}
+		cuts = append(cuts, queue.Cut{Start: start, End: end, Reason: cut.Reason})
+		segments = append(segments, audio.Segment{Start: start - item.Offset, End: end - item.Offset})
+		total += end - start
+	}
+	if duration > 0 && total >= duration-item.Offset {
+		slog.WarnContext(ctx, "Skip rule would cut the whole episode, keeping it all", "title", item.Title, "cut", total)
+		return nil, nil, 0
+	}
+	return cuts, segments, total
+}
+
+// ffmpegWorker handles FFmpeg processing requests. Tasks that already failed
+// are passed on untouched.
+func ffmpegWorker(ctx context.Context, processor audio.Processor, preambles *preambles, tasks <-chan Task, results chan<- Task, encoding string, q ProgressTracker, jobID string) {
 	fileCount := 0
 	defer func() {
-		slog.Info("FFmpeg worker completed", "processed_files", fileCount)
+		slog.InfoContext(ctx, "FFmpeg worker completed", "processed_files", fileCount)
 	}()
 
 	for task := range tasks {
+		itemCtx := logging.With(ctx, "item_id", task.Item.ID)
+		if task.Err != nil {
+			results <- task
+			continue
+		}
 		fileCount++
 		// Check if context was cancelled
 		select {
 		case <-ctx.Done():
 			task.Err = ctx.Err()
+			if err := os.Remove(task.TempPath); err != nil {
+				slog.WarnContext(itemCtx, "Failed to remove temp file", "path", task.TempPath, "error", err)
+			}
 			results <- task
-			return
+			continue
 		default:
 		}
 
-		// Update status
+		sourceDuration := task.Item.Duration
+		if task.SourceDuration > 0 {
+			sourceDuration = task.SourceDuration
+		}
+		var segments []audio.Segment
+		var cut time.Duration
+		if task.Retrim == nil {
+			task.Item.Cuts, segments, cut = skipCuts(ctx, task.Skip, task.Item, sourceDuration, task.Chapters)
+		}
+
+		// Update status, with the cuts so users can see what was left out
 		task.Item.Status = queue.StatusProcessing
-		if err := q.UpdateJobItem(ctx, jobID, task.Item); err != nil {
-			slog.Error("Failed to update job item status", "error", err)
+		if err := q.UpdateJobItem(itemCtx, jobID, task.Item); err != nil {
+			slog.ErrorContext(itemCtx, "Failed to update job item status", "error", err)
 		}
 
-		slog.Info("Processing audio", "title", task.Item.Title, "speed", speed)
-		outputPath, err := processor.ProcessAudio(task.TempPath, speed, task.Item.Offset)
+		var outputPath string
+		var err error
+		if task.Retrim != nil {
+			slog.InfoContext(itemCtx, "Trimming processed audio", "title", task.Item.Title, "trim", task.Retrim.Trim)
+			_, span := tracing.Start(itemCtx, "audio.trim", attribute.String("item.id", task.Item.ID), attribute.String("audio.trim", task.Retrim.Trim.String()))
+			outputPath, err = processor.TrimAudio(itemCtx, task.TempPath, task.Retrim.Trim)
+			tracing.End(span, err)
+		} else {
+			var preamble string
+			if preambles != nil {
+				preamble = preambles.create(itemCtx, task.Item)
+			}
+			slog.InfoContext(itemCtx, "Processing audio", "title", task.Item.Title, "speed", task.Speed)
+			_, span := tracing.Start(itemCtx, "audio.ffmpeg", attribute.String("item.id", task.Item.ID), attribute.Float64("audio.speed", task.Speed))
+			outputPath, err = processor.WithTrimSilence(task.TrimSilence).ProcessAudio(itemCtx, task.TempPath, preamble, task.Speed, task.Item.Offset, segments)
+			tracing.End(span, err)
+			if preambles != nil {
+				preambles.remove(ctx, preamble)
+			}
+		}
 		if err != nil {
-			slog.Error("Error processing audio", "title", task.Item.Title, "error", err)
+			slog.ErrorContext(itemCtx, "Error processing audio", "title", task.Item.Title, "error", err)
 			task.Err = err
 			task.Item.Status = queue.StatusFailed
 			task.Item.Error = err.Error()
-			if err := q.UpdateJobItem(ctx, jobID, task.Item); err != nil {
-				slog.Error("Failed to update job item status", "error", err)
+			if err := q.UpdateJobItem(itemCtx, jobID, task.Item); err != nil {
+				slog.ErrorContext(itemCtx, "Failed to update job item status", "error", err)
 			}
 
 			// Clean up temp file
 			if cleanupErr := os.Remove(task.TempPath); cleanupErr != nil {
-				slog.Warn("Failed to remove temp file", "path", task.TempPath, "error", cleanupErr)
+				slog.WarnContext(itemCtx, "Failed to remove temp file", "path", task.TempPath, "error", cleanupErr)
 			}
 			results <- task
 			continue
@@ -300,17 +961,31 @@ func ffmpegWorker(ctx context.Context, processor *audio.Processor, tasks <-chan
 
 		// Clean up input temp file
 		if err := os.Remove(task.TempPath); err != nil {
-			slog.Warn("Failed to remove temp file", "path", task.TempPath, "error", err)
+			slog.WarnContext(itemCtx, "Failed to remove temp file", "path", task.TempPath, "error", err)
 		}
 
-		newDuration := time.Duration(float64((task.Item.Duration - task.Item.Offset).Nanoseconds()) / speed)
+		newDuration := podcast.ProcessedDuration(sourceDuration, task.Item.Offset, cut, task.Speed)
 		result := podcast.ProcessedEpisode{
 			Title:            task.Item.Title,
-			OriginalDuration: task.Item.Duration,
+			OriginalURL:      task.Item.SourceURL,
+			SourceGUID:       task.Item.GUID,
+			OriginalDuration: sourceDuration,
 			NewDuration:      newDuration,
+			ListedDuration:   task.Item.Duration,
 			UUID:             task.Item.ID,
-			Speed:            speed,
+			Speed:            task.Speed,
 			TempFile:         outputPath,
+			ContentType:      audio.FormatForPath(outputPath).ContentType,
+			Position:         task.Item.Position,
+			Offset:           task.Item.Offset,
+			Encoding:         encoding,
+			Skipped:          cut,
+		}
+		if info, err := os.Stat(outputPath); err == nil {
+			result.Size = info.Size()
+		}
+		if task.Retrim != nil {
+			result.OriginalGUID = task.Retrim.GUID
 		}
 
 		task.Result = result
@@ -318,140 +993,370 @@ func ffmpegWorker(ctx context.Context, processor *audio.Processor, tasks <-chan
 	}
 }
 
-// uploadResults handles uploading processed audio files to storage backend
-func uploadResults(ctx context.Context, storageService storage.Storage, tasks []Task, q JobTracker, jobID string) ([]podcast.ProcessedEpisode, error) {
-	var results []podcast.ProcessedEpisode
-	for i, task := range tasks {
+// uploadWorker uploads episodes as their encodes finish, so the network works
+// while later episodes are still encoding. Failed tasks are passed on untouched.
+func uploadWorker(ctx context.Context, storageService storage.Storage, tasks <-chan Task, results chan<- Task, q ProgressTracker, jobID string) {
+	for task := range tasks {
+		itemCtx := logging.With(ctx, "item_id", task.Item.ID)
+		if task.Err != nil {
+			results <- task
+			continue
+		}
 		// Check if context was cancelled
 		select {
 		case <-ctx.Done():
-			slog.Info("Context cancelled, stopping upload")
-			return nil, ctx.Err()
-		default:
-		}
-
-		result := task.Result
-
-		// Skip upload for reused files that already have download_url
-		if downloadURL := result.DownloadURL; downloadURL != "" {
-			slog.Info("Skipping upload for reused file", "title", result.Title)
-			// Extract file_id from download_url for consistency
-			if fileID := storageService.ExtractFileIDFromURL(downloadURL); fileID != "" {
-				result.DriveFileID = fileID
+			task.Err = ctx.Err()
+			if err := os.Remove(task.Result.TempFile); err != nil {
+				slog.WarnContext(itemCtx, "Failed to remove temp file", "path", task.Result.TempFile, "error", err)
 			}
-			results = append(results, result)
+			removeTranscript(ctx, task)
+			results <- task
 			continue
+		default:
 		}
 
-		// Update status
-		task.Item.Status = queue.StatusUploading
-		if err := q.UpdateJobItem(ctx, jobID, task.Item); err != nil {
-			slog.Error("Failed to update job item status", "error", err)
+		task.Result, task.Err = uploadTask(itemCtx, storageService, task, q, jobID)
+		results <- task
+	}
+}
+
+// uploadTask uploads a processed episode to the storage backend and returns it
+// with its storage key. Episodes that already have a download URL (reused or
+// copied through) are returned without uploading. A failed upload is marked on
+// its job item.
+func uploadTask(ctx context.Context, storageService storage.Storage, task Task, q ProgressTracker, jobID string) (podcast.ProcessedEpisode, error) {
+	result := task.Result
+
+	// Skip upload for reused files that already have download_url
+	if downloadURL := result.DownloadURL; downloadURL != "" {
+		slog.InfoContext(ctx, "Skipping upload for reused file", "title", result.Title)
+		// Extract file_id from download_url for consistency
+		if fileID := storageService.ExtractFileIDFromURL(downloadURL); fileID != "" {
+			result.DriveFileID = fileID
 		}
+		return result, nil
+	}
 
-		slog.Info("Uploading to storage backend", "title", result.Title)
-		tempFile := result.TempFile
-		filename := fmt.Sprintf("%s.mp3", result.Title)
+	// Update status
+	task.Item.Status = queue.StatusUploading
+	if err := q.UpdateJobItem(ctx, jobID, task.Item); err != nil {
+		slog.ErrorContext(ctx, "Failed to update job item status", "error", err)
+	}
 
-		fileID, err := storageService.UploadFile(tempFile, filename, "audio/mpeg")
-		if err != nil {
-			task.Item.Status = queue.StatusFailed
-			task.Item.Error = err.Error()
-			q.UpdateJobItem(ctx, jobID, task.Item)
-			return nil, fmt.Errorf("failed to upload %s to storage backend: %w", result.Title, err)
-		}
+	slog.InfoContext(ctx, "Uploading to storage backend", "title", result.Title)
+	tempFile := result.TempFile
+	// Name and type the upload after what the encoder produced; Drive serves the
+	// download URL with this MIME type, which players rely on for playback
+	format := audio.FormatForPath(tempFile)
+	result.ContentType = format.ContentType
 
-		// Clean up temp file
-		if err := os.Remove(tempFile); err != nil {
-			slog.Warn("Failed to remove temp file", "path", tempFile, "error", err)
-		}
+	_, span := tracing.Start(ctx, "storage.upload", attribute.String("item.id", task.Item.ID), attribute.String("content.type", format.ContentType))
+	fileID, err := storageService.UploadFileWithProgress(tempFile, format.Filename(result.Title), format.ContentType, uploadProgressReporter(ctx, q, jobID, task.Item, time.Now))
+	tracing.End(span, err)
 
-		result.DriveFileID = fileID
-		results = append(results, result)
+	// Clean up temp file
+	if err := os.Remove(tempFile); err != nil {
+		slog.WarnContext(ctx, "Failed to remove temp file", "path", tempFile, "error", err)
+	}
 
-		// Update status
-		task.Item.Status = queue.StatusCompleted
+	if err != nil {
+		removeTranscript(ctx, task)
+		slog.ErrorContext(ctx, "Failed to upload to storage backend", "title", result.Title, "error", err)
+		task.Item.Status = queue.StatusFailed
+		task.Item.Error = err.Error()
 		if err := q.UpdateJobItem(ctx, jobID, task.Item); err != nil {
-			slog.Error("Failed to update job item status", "error", err)
+			slog.ErrorContext(ctx, "Failed to update job item status", "error", err)
 		}
-		tasks[i] = task // Update task in slice if needed
+		return result, err
 	}
 
-	return results, nil
+	result.DriveFileID = fileID
+	result.TranscriptURL = uploadTranscript(ctx, storageService, task)
+
+	// Update status, recording the storage key and encoding right away so a
+	// retry or a resumed job can skip this upload. The checkpoint is written
+	// even if the job is being interrupted, which is when it matters most.
+	task.Item.DriveFileID = fileID
+	task.Item.Speed = result.Speed
+	task.Item.ContentType = format.ContentType
+	task.Item.Status = queue.StatusCompleted
+	task.Item.Progress = 100
+	if err := q.UpdateJobItem(context.WithoutCancel(ctx), jobID, task.Item); err != nil {
+		slog.ErrorContext(ctx, "Failed to update job item status", "error", err)
+	}
+	return result, nil
 }
 
-// updateFeed creates and uploads the RSS XML feed and saves the application state
-func updateFeed(podcastProcessor *podcast.RSSProcessor, storageService storage.Storage, results []podcast.ProcessedEpisode) error {
+// uploadProgressInterval is the least time between upload progress writes,
+// each of which stores the whole job item
+const uploadProgressInterval = time.Second
+
+// uploadProgressReporter returns a storage.ProgressFunc that records upload progress
+// on the job item, writing at most once per uploadProgressInterval and when the
+// upload completes. now tells the time between writes.
+func uploadProgressReporter(ctx context.Context, q ProgressTracker, jobID string, item queue.JobItem, now func() time.Time) storage.ProgressFunc {
+	lastPercent := -1
+	var lastWrite time.Time
+	return func(uploaded, total int64) {
+		if total <= 0 {
+			return
+		}
+		percent := int(uploaded * 100 / total)
+		if percent == lastPercent || (percent < 100 && now().Sub(lastWrite) < uploadProgressInterval) {
+			return
+		}
+		lastPercent = percent
+		lastWrite = now()
+		item.Progress = percent
+		if err := q.UpdateJobItem(ctx, jobID, item); err != nil {
+			slog.ErrorContext(ctx, "Failed to update job item progress", "error", err)
+		}
+	}
+}
+
+// updateFeed creates and uploads the RSS XML feed, replacing the feed with
+// feedID unless it's empty, and returns its subscription URL
+func updateFeed(ctx context.Context, podcastProcessor *podcast.RSSProcessor, storageService storage.Storage, feedID string, results []podcast.ProcessedEpisode) (string, error) {
 	// Create and upload RSS XML
 	xmlFeed := podcastProcessor.CreateRSSXML(results)
-	rssFileID, err := storageService.UploadString(xmlFeed, "playrun_addict.xml", "application/rss+xml", podcastProcessor.GetRSSFeedID())
+	rssFileID, err := storageService.UploadString(xmlFeed, "playrun_addict.xml", "application/rss+xml", feedID)
 	if err != nil {
-		return fmt.Errorf("failed to upload RSS feed: %w", err)
+		return "", fmt.Errorf("failed to upload RSS feed: %w", err)
 	}
 
 	rssDownloadURL := storageService.GenerateDownloadURL(rssFileID)
-	slog.Info("RSS Feed created", "download_url", rssDownloadURL)
+	slog.InfoContext(ctx, "RSS Feed created", "download_url", rssDownloadURL)
 
-	return nil
+	return rssDownloadURL, nil
 }
 
 // deleteUnusedEpisodes removes episodes from storage backend that are no longer in the current playlist
-func (p *Processor) deleteUnusedEpisodes(storageService StorageDeleter, episodeMapping map[string]podcast.ExistingEpisode, reused map[string]podcast.ExistingEpisode) {
+func (p *Processor) deleteUnusedEpisodes(ctx context.Context, storageService StorageDeleter, episodeMapping map[string]podcast.ExistingEpisode, reused map[string]podcast.ExistingEpisode) {
 	// Delete episodes that are not reused
-	for title, episode := range episodeMapping {
-		if _, ok := reused[title]; ok {
+	for key, episode := range episodeMapping {
+		if _, ok := reused[key]; ok {
 			continue
 		}
+		if episode.TranscriptURL != "" {
+			if fileId := storageService.ExtractFileIDFromURL(episode.TranscriptURL); fileId != "" {
+				slog.InfoContext(ctx, "Deleting unused transcript from storage backend", "title", episode.Title, "file_id", fileId)
+				if err := storageService.DeleteFile(fileId); err != nil {
+					slog.ErrorContext(ctx, "Failed to delete file from storage backend", "file_id", fileId, "error", err)
+				}
+			}
+		}
 		fileId := storageService.ExtractFileIDFromURL(episode.DownloadURL)
 		if fileId == "" {
-			slog.Warn("Could not extract file ID from URL", "url", episode.DownloadURL)
+			slog.WarnContext(ctx, "Could not extract file ID from URL", "url", episode.DownloadURL)
 			continue
 		}
-		slog.Info("Deleting unused episode from storage backend", "title", title, "file_id", fileId)
+		slog.InfoContext(ctx, "Deleting unused episode from storage backend", "title", episode.Title, "file_id", fileId)
 		if err := storageService.DeleteFile(fileId); err != nil {
-			slog.Error("Failed to delete file from storage backend", "file_id", fileId, "error", err)
+			slog.ErrorContext(ctx, "Failed to delete file from storage backend", "file_id", fileId, "error", err)
 		}
 	}
 }
 
-// processEntries returns the reused episodes
-func (p *Processor) processEntries(ctx context.Context, episodeMapping map[string]podcast.ExistingEpisode, storageService storage.Storage, audioProcessor *audio.Processor, podcastProcessor *podcast.RSSProcessor, job *queue.Job) (map[string]podcast.ExistingEpisode, error) {
+// reusedEpisode returns the feed episode item is published as when its
+// processed file is reused
+func reusedEpisode(item queue.JobItem, oldEp podcast.ExistingEpisode, speed float64) podcast.ProcessedEpisode {
+	return podcast.ProcessedEpisode{
+		Title:            item.Title,
+		OriginalURL:      item.SourceURL,
+		SourceGUID:       item.GUID,
+		OriginalDuration: oldEp.OriginalDuration,
+		NewDuration:      oldEp.Duration,
+		ListedDuration:   item.Duration,
+		UUID:             item.ID,
+		Speed:            speed,
+		DownloadURL:      oldEp.DownloadURL,
+		OriginalGUID:     oldEp.OriginalGUID,
+		ContentType:      oldEp.ContentType,
+		Position:         item.Position,
+		Offset:           item.Offset,
+		Encoding:         oldEp.Encoding,
+		Size:             oldEp.Size,
+		Skipped:          oldEp.Skipped,
+		TranscriptURL:    oldEp.TranscriptURL,
+	}
+}
+
+// processEntries publishes the feed with every item that succeeded and every carried entry, see
+// diffPlaylist, and publishFeed; if some items failed the error is a *PartialFailureError.
+func (p *Processor) processEntries(ctx context.Context, settings *queue.UserSettings, episodeMapping map[string]podcast.ExistingEpisode, storageService storage.Storage, audioProcessor audio.Processor, podcastProcessor *podcast.RSSProcessor, job *queue.Job, carried []queue.JobItem) error {
 	// Process entries locally
 	var tasks []Task
+	started := time.Now()
+	// toEncode are the items sent to FFmpeg, whose audio the run's throughput
+	// is measured by; reused, re-trimmed and copied episodes cost next to nothing
+	var toEncode []queue.JobItem
+
+	format := settings.Format()
+	clips, removeClips := loadClips(ctx, storageService, p.folders, audioProcessor)
+	defer removeClips()
+	preambles := p.preambles(settings)
+	joined, encoding := p.encoding(settings, clips, audioProcessor)
+	failed := 0
+
+	// Episodes flow through a single downloader, the FFmpeg workers and the upload
+	// workers over small channels, so a long playlist neither buffers every task nor
+	// downloads far ahead of encoding: at most MaxDownloadsAhead downloaded
+	// sources wait for an FFmpeg worker. Failed tasks flow through every stage and
+	// are counted at the end.
+	dlRequests := make(chan Task)
+	dlResults := make(chan Task, p.worker.MaxDownloadsAhead)
+	encoded := make(chan Task, stageBuffer)
+	uploaded := make(chan Task, stageBuffer)
+	// Episodes are transcribed on their way to the upload workers when the user
+	// wants transcripts
+	toUpload := encoded
+	// A panic in a worker fails the job once the pipeline has drained; the dead
+	// worker's input is drained for it so the stages feeding it don't block
+	var guard panicGuard
+	go func() {
+		defer drain(dlRequests)
+		defer guard.recover()
+		downloadWorker(ctx, audioProcessor, storageService, dlRequests, dlResults, p.queue, job.ID)
+	}()
+	if transcriber := p.transcriberFor(settings); transcriber != nil {
+		transcribed := make(chan Task, stageBuffer)
+		toUpload = transcribed
+		go func() {
+			defer close(transcribed)
+			defer drain(encoded)
+			defer guard.recover()
+			transcribeWorker(ctx, transcriber, encoded, transcribed)
+		}()
+	}
+	var ffmpegWG, uploadWG sync.WaitGroup
+	for i := 0; i < config.MaxFFMPEGWorkers; i++ {
+		ffmpegWG.Add(1)
+		go func() {
+			defer ffmpegWG.Done()
+			defer drain(dlResults)
+			defer guard.recover()
+			ffmpegWorker(ctx, audioProcessor, preambles, dlResults, encoded, encoding, p.queue, job.ID)
+		}()
+	}
+	for i := 0; i < config.MaxUploadWorkers; i++ {
+		uploadWG.Add(1)
+		go func() {
+			defer uploadWG.Done()
+			defer drain(toUpload)
+			defer guard.recover()
+			uploadWorker(ctx, storageService, toUpload, uploaded, p.queue, job.ID)
+		}()
+	}
+	go func() {
+		ffmpegWG.Wait()
+		close(encoded)
+		uploadWG.Wait()
+		close(uploaded)
+	}()
 
-	// Start a single downloader worker with separate job and result channels
-	dlRequests := make(chan Task, len(job.Items))
-	dlResults := make(chan Task, len(job.Items))
-	go downloadWorker(ctx, audioProcessor, dlRequests, dlResults, p.queue, job.ID)
-
-	speed := config.DefaultSpeed
+	// Collect uploads while the first pass below is still enqueuing downloads
+	var uploadedResults []podcast.ProcessedEpisode
+	uploadFailed := 0
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		for task := range uploaded {
+			if task.Err != nil {
+				uploadFailed++
+				continue
+			}
+			uploadedResults = append(uploadedResults, task.Result)
+		}
+	}()
 
-	reused := make(map[string]podcast.ExistingEpisode)
-	// First pass: reuse check; enqueue downloads for the rest
+	playlist := append(slices.Clip(job.Items), carried...)
+	for _, item := range carried {
+		_, oldEp, _ := podcast.FindEpisode(episodeMapping, item)
+		tasks = append(tasks, Task{Item: item, Result: reusedEpisode(item, oldEp, settings.SpeedFor(item))})
+	}
+	// First pass: reuse and copy-through checks; enqueue downloads for the rest
 	for _, item := range job.Items {
 		title := item.Title
+		speed := settings.SpeedFor(item)
+		trimSilence := settings.TrimSilenceFor(item)
+		skip := settings.SkipRuleFor(item)
+
+		// Skip items a previous attempt of this job already uploaded
+		if resumable(item, speed, format) {
+			if exists, err := storageService.FileExists(item.DriveFileID); err == nil && exists {
+				slog.InfoContext(ctx, "Resuming from already uploaded episode", "title", title, "file_id", item.DriveFileID)
+				skipped := cutLength(item.Cuts)
+				newDuration := podcast.ProcessedDuration(item.Duration, item.Offset, skipped, speed)
+				tasks = append(tasks, Task{
+					Item: item,
+					Result: podcast.ProcessedEpisode{
+						Title:            title,
+						OriginalURL:      item.SourceURL,
+						SourceGUID:       item.GUID,
+						OriginalDuration: item.Duration,
+						NewDuration:      newDuration,
+						UUID:             item.ID,
+						Speed:            speed,
+						DownloadURL:      storageService.GenerateDownloadURL(item.DriveFileID),
+						DriveFileID:      item.DriveFileID,
+						ContentType:      format.ContentType,
+						Position:         item.Position,
+						Offset:           item.Offset,
+						Skipped:          skipped,
+					},
+				})
+				continue
+			}
+		}
 
 		// Reuse check
-		if oldEp, exists := episodeMapping[title]; exists {
-			if podcastProcessor.CanReuseEpisode(item, oldEp, speed) {
-				slog.Info("Reusing existing processed file", "title", title)
-				reused[title] = oldEp
-				result := podcast.ProcessedEpisode{
-					Title:            title,
-					OriginalDuration: item.Duration,
-					NewDuration:      oldEp.Duration,
-					UUID:             item.ID,
-					Speed:            speed,
-					DownloadURL:      oldEp.DownloadURL,
-					OriginalGUID:     oldEp.OriginalGUID,
-				}
+		if _, oldEp, exists := podcast.FindEpisode(episodeMapping, item); exists {
+			if sameFormat(oldEp, format) && oldEp.Encoding == encoding && podcastProcessor.CanReuseEpisode(item, oldEp, speed) {
+				slog.InfoContext(ctx, "Reusing existing processed file", "title", title)
+				result := reusedEpisode(item, oldEp, speed)
 
 				// Update status
 				item.Status = queue.StatusSkipped
 				if err := p.queue.UpdateJobItem(ctx, job.ID, item); err != nil {
-					slog.Error("Failed to update job item status", "error", err)
+					slog.ErrorContext(ctx, "Failed to update job item status", "error", err)
+				}
+
+				tasks = append(tasks, Task{
+					Item:   item,
+					Result: result,
+				})
+				continue
+			}
+
+			// When only the offset moved forward, cut the processed file instead of re-encoding
+			// the source. Trimmed silences make processed and source positions disagree, and
+			// cutting the start would cut an intro or preamble. Skip rules are applied to the source.
+			if sameFormat(oldEp, format) && oldEp.Encoding == encoding && !trimSilence && joined == "" && skip == nil {
+				if trim, ok := podcastProcessor.RetrimOffset(item, oldEp, speed); ok {
+					slog.InfoContext(ctx, "Enqueuing re-trim of existing processed file", "title", title, "trim", trim)
+					dlRequests <- Task{
+						Item: item,
+						Retrim: &Retrim{
+							FileID: storageService.ExtractFileIDFromURL(oldEp.DownloadURL),
+							Trim:   trim,
+							GUID:   oldEp.OriginalGUID,
+						},
+						SourceDuration: oldEp.OriginalDuration,
+						Speed:          speed,
+					}
+					continue
 				}
+			}
+		}
 
+		// Short, low bitrate sources are published as they are, unless clips or a preamble have to be
+		// joined on or parts skipped
+		if joined == "" && skip == nil {
+			if result, ok := copyThrough(ctx, p.worker, audioProcessor, item); ok {
+				item.Status = queue.StatusCompleted
+				if err := p.queue.UpdateJobItem(ctx, job.ID, item); err != nil {
+					slog.ErrorContext(ctx, "Failed to update job item status", "error", err)
+				}
 				tasks = append(tasks, Task{
 					Item:   item,
 					Result: result,
@@ -461,78 +1366,188 @@ func (p *Processor) processEntries(ctx context.Context, episodeMapping map[strin
 		}
 
 		// Send request and wait for response
-		slog.Info("Enqueuing download", "title", title, "url", item.SourceURL)
+		slog.InfoContext(ctx, "Enqueuing download", "title", title, "url", item.SourceURL)
+		toEncode = append(toEncode, item)
 		dlRequests <- Task{
-			Item: item,
+			Item:        item,
+			Speed:       speed,
+			TrimSilence: trimSilence,
+			Skip:        skip,
 		}
 	}
 	// all done sending jobs
 	close(dlRequests)
 
-	// Start FFmpeg worker
-	var wg sync.WaitGroup
-	ffmpegJobs := make(chan Task, len(job.Items))
-	ffmpegResults := make(chan Task, len(job.Items))
-	for i := 0; i < config.MaxFFMPEGWorkers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			ffmpegWorker(ctx, audioProcessor, ffmpegJobs, ffmpegResults, speed, p.queue, job.ID)
-		}()
+	// Reused and copied-through episodes need no upload
+	var results []podcast.ProcessedEpisode
+	for _, task := range tasks {
+		result, _ := uploadTask(ctx, storageService, task, p.queue, job.ID)
+		results = append(results, result)
+	}
+	<-collected
+	results = append(results, uploadedResults...)
+	failed += uploadFailed
+	if err := guard.Err(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		slog.InfoContext(ctx, "Context cancelled, stopping processing")
+		return err
 	}
 
-	for res := range dlResults {
-		// Check if context was cancelled
-		select {
-		case <-ctx.Done():
-			slog.Info("Context cancelled, stopping processing")
-			return nil, ctx.Err()
-		default:
+	if len(results) == 0 {
+		if failed > 0 {
+			return fmt.Errorf("all %d items failed", failed)
 		}
+		slog.InfoContext(ctx, "Skipping feed update since there are no audio entries")
+		return nil
+	}
+	slog.InfoContext(ctx, "Processing completed", "processed_files", len(results), "failed", failed)
 
-		// Process the result
-		if res.Err != nil {
-			slog.Error("Download failed", "error", res.Err)
-			// Add failed task to results so we don't lose it?
-			// Or just skip ffmpeg
-			continue
+	p.enrichEpisodes(ctx, playlist, results)
+	// Episodes finish encoding in any order; publish them in playlist order
+	sortEpisodes(results)
+
+	// The user's other jobs may be running too, so only one of them publishes
+	// the feed and cleans up after it at a time
+	unlock, err := p.queue.LockFeed(ctx, job.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to lock feed: %w", err)
+	}
+	feedURL, dropped, err := func() (string, int, error) {
+		// Release the lock even if the update panics
+		defer unlock()
+		return p.publishFeed(ctx, job.UserID, settings, episodeMapping, storageService, podcastProcessor, playlist, results)
+	}()
+	failed += dropped
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to update feed", "error", err)
+	} else {
+		if err := p.queue.SetJobFeedURL(ctx, job.ID, feedURL); err != nil {
+			slog.ErrorContext(ctx, "Failed to record feed URL", "error", err)
+		}
+		if err := p.queue.PublishEvent(ctx, job.UserID, queue.Event{Type: queue.EventFeedUpdated, JobID: job.ID}); err != nil {
+			slog.ErrorContext(ctx, "Failed to publish feed updated event", "error", err)
 		}
+	}
 
-		ffmpegJobs <- res
+	if failed > 0 {
+		return &PartialFailureError{Failed: failed, Total: len(job.Items)}
+	}
+	// Clean runs feed the rates later jobs' completion is estimated from
+	if p.state != nil {
+		if err := p.state.RecordEncode(ctx, queue.TotalAudio(toEncode), time.Since(started)); err != nil {
+			slog.WarnContext(ctx, "Failed to record encode throughput", "error", err)
+		}
 	}
-	close(ffmpegJobs)
-	wg.Wait()
-	close(ffmpegResults)
+	return nil
+}
 
-	// Collect FFmpeg results
-	var processedTasks []Task
-	for ffmpegRes := range ffmpegResults {
-		if ffmpegRes.Err != nil {
-			slog.Error("FFmpeg processing failed", "error", ffmpegRes.Err)
+// publishFeed publishes results, the processed episodes of the playlist, as
+// the user's feed, then archives or deletes the episodes that left it. Hold
+// the feed lock: another job of the user's may have published the feed since
+// loaded was read from it, so it starts over from the feed as published now.
+// Episodes the other job added are kept, and reused episodes it removed are
+// dropped from results and returned as the dropped count.
+func (p *Processor) publishFeed(ctx context.Context, userID string, settings *queue.UserSettings, loaded map[string]podcast.ExistingEpisode, storageService storage.Storage, podcastProcessor *podcast.RSSProcessor, playlist []queue.JobItem, results []podcast.ProcessedEpisode) (string, int, error) {
+	feedID, current, err := currentFeed(podcastProcessor, storageService)
+	if err != nil {
+		return "", 0, err
+	}
+	loadedFiles := episodeFiles(storageService, loaded)
+	currentFiles := episodeFiles(storageService, current)
+
+	var episodes []podcast.ProcessedEpisode
+	dropped := 0
+	for _, result := range results {
+		fileID := storageService.ExtractFileIDFromURL(result.DownloadURL)
+		if loadedFiles[fileID] && !currentFiles[fileID] {
+			slog.WarnContext(ctx, "Reused episode was removed from the feed by another job", "title", result.Title, "file_id", fileID)
+			dropped++
 			continue
 		}
-		processedTasks = append(processedTasks, ffmpegRes)
+		episodes = append(episodes, result)
 	}
 
-	// Combine reused and processed tasks
-	allTasks := append(tasks, processedTasks...)
+	// Of the episodes this job didn't know about, those it published too are
+	// replaced; the rest stay
+	replaced := make(map[string]bool, len(episodes))
+	for _, episode := range episodes {
+		item := queue.JobItem{Title: episode.Title, GUID: episode.SourceGUID, SourceURL: episode.OriginalURL}
+		if key, _, ok := podcast.FindEpisode(current, item); ok {
+			replaced[key] = true
+		}
+	}
+	known := make(map[string]podcast.ExistingEpisode, len(current))
+	var added []podcast.ProcessedEpisode
+	for key, episode := range current {
+		switch {
+		case loadedFiles[storageService.ExtractFileIDFromURL(episode.DownloadURL)]:
+			known[key] = episode
+		case !replaced[key]:
+			added = append(added, publishedEpisode(episode))
+		}
+	}
+	sort.Slice(added, func(i, j int) bool { return added[i].Title < added[j].Title })
+	// Episodes that left the playlist stay in the feed for the user's retention period
+	episodes = append(episodes, retainDroppedEpisodes(known, playlist, settings.Retention(), time.Now())...)
+	episodes = append(episodes, added...)
+
+	_, span := tracing.Start(ctx, "feed.update", attribute.Int("feed.episodes", len(episodes)))
+	feedURL, err := updateFeed(ctx, podcastProcessor, storageService, feedID, episodes)
+	tracing.End(span, err)
+	if err != nil {
+		return "", dropped, err
+	}
 
-	if len(allTasks) == 0 {
-		slog.Info("Skipping uploads since no audio entries successfully processed")
-		return reused, nil
+	// Archive or delete the episodes that dropped out of the feed
+	publishedFiles := make(map[string]bool, len(episodes))
+	for _, episode := range episodes {
+		publishedFiles[storageService.ExtractFileIDFromURL(episode.DownloadURL)] = true
+	}
+	kept := make(map[string]podcast.ExistingEpisode, len(current))
+	for key, episode := range current {
+		if publishedFiles[storageService.ExtractFileIDFromURL(episode.DownloadURL)] {
+			kept[key] = episode
+		}
 	}
-	slog.Info("Processing completed", "processed_files", len(allTasks))
+	if p.worker.ArchiveRetention() > 0 {
+		p.archiveUnusedEpisodes(ctx, userID, storageService, current, kept)
+	} else {
+		p.deleteUnusedEpisodes(ctx, storageService, current, kept)
+	}
+	return feedURL, dropped, nil
+}
 
-	// Upload processed files to storage backend
-	results, err := uploadResults(ctx, storageService, allTasks, p.queue, job.ID)
+// currentFeed returns the ID and episodes of the user's feed as published now,
+// or no ID if there is no feed yet. Unlike loadFeed it fails when the feed
+// can't be read, rather than publish over it.
+func currentFeed(podcastProcessor *podcast.RSSProcessor, storageService storage.Storage) (string, map[string]podcast.ExistingEpisode, error) {
+	feedID, err := podcastProcessor.FindRSSFeedID()
 	if err != nil {
-		return nil, err
+		return "", nil, fmt.Errorf("failed to find RSS feed: %w", err)
 	}
-
-	// Create and upload RSS XML feed and save state
-	if err := updateFeed(podcastProcessor, storageService, results); err != nil {
-		slog.Error("Failed to update feed", "error", err)
+	if feedID == "" {
+		return "", map[string]podcast.ExistingEpisode{}, nil
+	}
+	content, err := storageService.DownloadFile(feedID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to download RSS feed: %w", err)
+	}
+	episodes, err := podcastProcessor.ExtractEpisodeMapping(content)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read RSS feed: %w", err)
 	}
+	return feedID, episodes, nil
+}
 
-	return reused, nil
+// episodeFiles returns the storage file IDs of the episodes
+func episodeFiles(storageService StorageDeleter, episodes map[string]podcast.ExistingEpisode) map[string]bool {
+	files := make(map[string]bool, len(episodes))
+	for _, episode := range episodes {
+		if fileID := storageService.ExtractFileIDFromURL(episode.DownloadURL); fileID != "" {
+			files[fileID] = true
+		}
+	}
+	return files
 }
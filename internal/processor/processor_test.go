@@ -2,16 +2,35 @@ package processor
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"cobblepod/internal/audio"
 	"cobblepod/internal/auth"
+	"cobblepod/internal/config"
+	"cobblepod/internal/cron"
+	"cobblepod/internal/metadata"
 	"cobblepod/internal/podcast"
 	"cobblepod/internal/queue"
+	"cobblepod/internal/sources"
+	"cobblepod/internal/storage"
 	"cobblepod/internal/storage/mock"
 )
 
-// MockJobTracker is a mock implementation of the JobTracker interface
+// testFolders are the default deployment's feed folders
+var testFolders = podcast.NewFolders(config.DefaultDriveFolder)
+
+// MockJobTracker is a mock implementation of the JobStore interface
 type MockJobTracker struct{}
 
 func (m *MockJobTracker) SetJobItems(ctx context.Context, jobID string, items []queue.JobItem) error {
@@ -22,6 +41,50 @@ func (m *MockJobTracker) UpdateJobItem(ctx context.Context, jobID string, item q
 	return nil
 }
 
+func (m *MockJobTracker) SetJobFeedURL(ctx context.Context, jobID string, feedURL string) error {
+	return nil
+}
+
+func (m *MockJobTracker) PublishEvent(ctx context.Context, userID string, event queue.Event) error {
+	return nil
+}
+
+func (m *MockJobTracker) LockFeed(ctx context.Context, userID string) (func(), error) {
+	return func() {}, nil
+}
+
+func (m *MockJobTracker) GetUserSettings(ctx context.Context, userID string) (*queue.UserSettings, error) {
+	return &queue.UserSettings{}, nil
+}
+
+func (m *MockJobTracker) GetPlaylistRules(ctx context.Context, userID string) (*queue.PlaylistRules, error) {
+	return nil, nil
+}
+
+func (m *MockJobTracker) ArchiveEpisode(ctx context.Context, userID string, episode *queue.ArchivedEpisode) error {
+	return nil
+}
+
+func (m *MockJobTracker) GetArchivedEpisode(ctx context.Context, userID string, fileID string) (*queue.ArchivedEpisode, error) {
+	return nil, nil
+}
+
+func (m *MockJobTracker) ListArchivedEpisodes(ctx context.Context, userID string) ([]*queue.ArchivedEpisode, error) {
+	return nil, nil
+}
+
+func (m *MockJobTracker) RemoveArchivedEpisode(ctx context.Context, userID string, fileID string) error {
+	return nil
+}
+
+func (m *MockJobTracker) ReserveEncoding(ctx context.Context, userID, jobID string, episodes int, audio time.Duration) error {
+	return nil
+}
+
+func (m *MockJobTracker) RecordPlaylistFetch(ctx context.Context, userID string, playlist *queue.PlaylistURL) error {
+	return nil
+}
+
 // MockGDriveService is a mock implementation of the GDriveDeleter interface for testing
 type MockGDriveService struct {
 	deletedFiles []string
@@ -153,8 +216,8 @@ func TestDeleteUnusedEpisodes(t *testing.T) {
 			}
 
 			// Call the actual function using our mock
-			proc := NewProcessorWithDependencies(nil, &auth.MockTokenProvider{}, nil, &MockJobTracker{})
-			proc.deleteUnusedEpisodes(mockService, tt.episodeMapping, tt.reused)
+			proc := NewProcessorWithDependencies(nil, &auth.MockTokenProvider{}, nil, &MockJobTracker{}, testFolders)
+			proc.deleteUnusedEpisodes(context.Background(), mockService, tt.episodeMapping, tt.reused)
 
 			// Check results
 			deletedFiles := mockService.GetDeletedFiles()
@@ -189,8 +252,8 @@ func TestDeleteUnusedEpisodesEdgeCases(t *testing.T) {
 		mockService := NewMockGDriveService()
 
 		// This should not panic
-		proc := NewProcessorWithDependencies(nil, &auth.MockTokenProvider{}, nil, &MockJobTracker{})
-		proc.deleteUnusedEpisodes(mockService, nil, nil)
+		proc := NewProcessorWithDependencies(nil, &auth.MockTokenProvider{}, nil, &MockJobTracker{}, testFolders)
+		proc.deleteUnusedEpisodes(context.Background(), mockService, nil, nil)
 
 		deletedFiles := mockService.GetDeletedFiles()
 		if len(deletedFiles) != 0 {
@@ -206,8 +269,8 @@ func TestDeleteUnusedEpisodesEdgeCases(t *testing.T) {
 		}
 		reused := map[string]podcast.ExistingEpisode{}
 
-		proc := NewProcessorWithDependencies(nil, &auth.MockTokenProvider{}, nil, &MockJobTracker{})
-		proc.deleteUnusedEpisodes(mockService, episodeMapping, reused)
+		proc := NewProcessorWithDependencies(nil, &auth.MockTokenProvider{}, nil, &MockJobTracker{}, testFolders)
+		proc.deleteUnusedEpisodes(context.Background(), mockService, episodeMapping, reused)
 
 		deletedFiles := mockService.GetDeletedFiles()
 		if len(deletedFiles) != 0 {
@@ -226,8 +289,8 @@ func TestDeleteUnusedEpisodesEdgeCases(t *testing.T) {
 			"Episode 1": {DownloadURL: "https://drive.google.com/file/d/file1", OriginalGUID: "guid2"},
 		}
 
-		proc := NewProcessorWithDependencies(nil, &auth.MockTokenProvider{}, nil, &MockJobTracker{})
-		proc.deleteUnusedEpisodes(mockService, episodeMapping, reused)
+		proc := NewProcessorWithDependencies(nil, &auth.MockTokenProvider{}, nil, &MockJobTracker{}, testFolders)
+		proc.deleteUnusedEpisodes(context.Background(), mockService, episodeMapping, reused)
 
 		deletedFiles := mockService.GetDeletedFiles()
 		if len(deletedFiles) != 0 {
@@ -236,12 +299,123 @@ func TestDeleteUnusedEpisodesEdgeCases(t *testing.T) {
 	})
 }
 
+// archiveRecorder is a MockJobTracker that keeps the episodes archived
+type archiveRecorder struct {
+	MockJobTracker
+	archived []*queue.ArchivedEpisode
+}
+
+func (r *archiveRecorder) ArchiveEpisode(ctx context.Context, userID string, episode *queue.ArchivedEpisode) error {
+	r.archived = append(r.archived, episode)
+	return nil
+}
+
+func TestArchiveUnusedEpisodes(t *testing.T) {
+	storageService := mock.NewMockStorage()
+	storageService.ExtractFileIDFromURLFunc = func(url string) string {
+		return strings.TrimPrefix(url, "https://example.com/")
+	}
+	storageService.MoveFileFunc = func(fileID, folder string) error {
+		if fileID == "stuck" {
+			return errors.New("move failed")
+		}
+		return nil
+	}
+	episodeMapping := map[string]podcast.ExistingEpisode{
+		"kept":    {Title: "Kept", DownloadURL: "https://example.com/kept"},
+		"dropped": {Title: "Dropped", DownloadURL: "https://example.com/dropped", TranscriptURL: "https://example.com/dropped-vtt"},
+		"stuck":   {Title: "Stuck", DownloadURL: "https://example.com/stuck"},
+	}
+	reused := map[string]podcast.ExistingEpisode{"kept": episodeMapping["kept"]}
+
+	store := &archiveRecorder{}
+	proc := NewProcessorWithDependencies(nil, &auth.MockTokenProvider{}, nil, store, testFolders)
+	proc.archiveUnusedEpisodes(context.Background(), "user1", storageService, episodeMapping, reused)
+
+	if len(storageService.DeleteFileCalls) != 0 {
+		t.Errorf("Expected nothing deleted, got %v", storageService.DeleteFileCalls)
+	}
+	moved := map[string]string{}
+	for _, call := range storageService.MoveFileCalls {
+		moved[call.FileID] = call.Folder
+	}
+	if moved["dropped"] != testFolders.Archive || moved["dropped-vtt"] != testFolders.Archive {
+		t.Errorf("Expected the dropped episode and its transcript archived, got %v", moved)
+	}
+	if _, ok := moved["kept"]; ok {
+		t.Error("Expected the reused episode to stay in place")
+	}
+
+	if len(store.archived) != 1 || store.archived[0].FileID != "dropped" {
+		t.Fatalf("Expected only the moved episode recorded, got %v", store.archived)
+	}
+	var episode podcast.ExistingEpisode
+	if err := json.Unmarshal(store.archived[0].Episode, &episode); err != nil || episode.TranscriptURL != "https://example.com/dropped-vtt" {
+		t.Errorf("Expected the record to keep the feed episode, got %+v (%v)", episode, err)
+	}
+}
+
+// restoreRecorder is a MockJobTracker holding one archived episode
+type restoreRecorder struct {
+	MockJobTracker
+	record *queue.ArchivedEpisode
+}
+
+func (r *restoreRecorder) GetArchivedEpisode(ctx context.Context, userID string, fileID string) (*queue.ArchivedEpisode, error) {
+	return r.record, nil
+}
+
+func TestRunRestore(t *testing.T) {
+	data, _ := json.Marshal(podcast.ExistingEpisode{Title: "Restored", TranscriptURL: "https://example.com/restored-vtt"})
+	store := &restoreRecorder{record: &queue.ArchivedEpisode{FileID: "restored", Title: "Restored", Episode: data}}
+	proc := NewProcessorWithDependencies(nil, &auth.MockTokenProvider{}, nil, store, testFolders)
+	job := &queue.Job{ID: "job-1", UserID: "user1", RestoreFileID: "restored"}
+
+	newFeed := func(content string) (*userFeed, *mock.MockStorage) {
+		storageService := mock.NewMockStorage()
+		storageService.ExtractFileIDFromURLFunc = func(url string) string {
+			return strings.TrimPrefix(url, "https://example.com/")
+		}
+		storageService.GetFilesFiles = []*storage.FileMeta{{ID: "feed"}}
+		storageService.DownloadFileContent = content
+		return &userFeed{storage: storageService, podcast: podcast.NewRSSProcessor("Feed", storageService, testFolders)}, storageService
+	}
+
+	t.Run("already in the feed", func(t *testing.T) {
+		// Another job published the episode after this one opened the feed
+		feed, storageService := newFeed(`<rss><channel><item><title>Restored</title></item></channel></rss>`)
+		if err := proc.runRestore(context.Background(), job, feed); !errors.Is(err, ErrAlreadyInFeed) {
+			t.Errorf("Expected ErrAlreadyInFeed, got %v", err)
+		}
+		if len(storageService.MoveFileCalls) != 0 {
+			t.Errorf("Expected nothing moved, got %v", storageService.MoveFileCalls)
+		}
+	})
+
+	t.Run("feed update fails", func(t *testing.T) {
+		feed, storageService := newFeed(`<rss><channel></channel></rss>`)
+		storageService.UploadStringFunc = func(content, filename, mimeType, fileID string) (string, error) {
+			return "", errors.New("upload failed")
+		}
+		if err := proc.runRestore(context.Background(), job, feed); err == nil {
+			t.Fatal("Expected the failed feed update to fail the restore")
+		}
+		moved := map[string]string{}
+		for _, call := range storageService.MoveFileCalls {
+			moved[call.FileID] = call.Folder
+		}
+		if moved["restored"] != testFolders.Archive || moved["restored-vtt"] != testFolders.Archive {
+			t.Errorf("Expected the episode and its transcript moved back to the archive, got %v", storageService.MoveFileCalls)
+		}
+	})
+}
+
 func TestProcessor_Run_AuthFailure(t *testing.T) {
 	mockTokenProvider := &auth.MockTokenProvider{
 		Err: errors.New("auth failed"),
 	}
 
-	proc := NewProcessorWithDependencies(nil, mockTokenProvider, nil, &MockJobTracker{})
+	proc := NewProcessorWithDependencies(nil, mockTokenProvider, nil, &MockJobTracker{}, testFolders)
 
 	job := &queue.Job{
 		ID:     "job1",
@@ -260,15 +434,34 @@ func TestProcessor_Run_AuthFailure(t *testing.T) {
 	}
 }
 
+func TestProcessor_Run_LocalPath(t *testing.T) {
+	// The local source is read before connecting to storage
+	proc := NewProcessorWithDependencies(nil, &auth.MockTokenProvider{Err: errors.New("auth failed")}, nil, NewLocalStore(nil), testFolders)
+
+	job := &queue.Job{ID: "job1", UserID: "user1", LocalPath: filepath.Join(t.TempDir(), "missing.m3u8")}
+	err := proc.Run(context.Background(), job)
+	if err == nil || !strings.Contains(err.Error(), "missing.m3u8") {
+		t.Errorf("Expected an error reading the local file, got %v", err)
+	}
+
+	playlist := filepath.Join(t.TempDir(), "playlist.m3u8")
+	os.WriteFile(playlist, []byte("#EXTM3U\n#EXTINF:60,Episode 1\nhttps://example.com/ep1.mp3\n"), 0o600)
+	job.LocalPath = playlist
+	err = proc.Run(context.Background(), job)
+	if err == nil || err.Error() != "failed to get Google access token for user user1: auth failed" {
+		t.Errorf("Expected the parsed playlist to be published to the user's storage, got %v", err)
+	}
+}
+
 func TestProcessor_Run_StorageCreationFailure(t *testing.T) {
 	mockTokenProvider := &auth.MockTokenProvider{
 		Token: "valid-token",
 	}
 
 	expectedErr := errors.New("storage creation failed")
-	mockStorageCreator := mock.NewMockStorageCreator(nil, expectedErr)
+	mockStorageCreator := mock.NewMockTokenSourceStorageCreator(nil, expectedErr)
 
-	proc := NewProcessorWithDependencies(nil, mockTokenProvider, mockStorageCreator, &MockJobTracker{})
+	proc := NewProcessorWithDependencies(nil, mockTokenProvider, mockStorageCreator, &MockJobTracker{}, testFolders)
 
 	job := &queue.Job{
 		ID:     "job1",
@@ -286,3 +479,1059 @@ func TestProcessor_Run_StorageCreationFailure(t *testing.T) {
 		t.Errorf("Expected error %q, got %q", expectedErrorMsg, err.Error())
 	}
 }
+
+func TestCheckStorageQuota(t *testing.T) {
+	entries := []queue.JobItem{
+		{Title: "Episode 1", Duration: 60 * time.Minute},
+		{Title: "Episode 2", Duration: 30 * time.Minute, Offset: 10 * time.Minute},
+	}
+
+	tests := []struct {
+		name        string
+		quota       *storage.QuotaInfo
+		quotaErr    error
+		expectedErr error
+	}{
+		{
+			name:  "unlimited storage",
+			quota: &storage.QuotaInfo{Used: 10, Total: 0},
+		},
+		{
+			name:  "plenty of space",
+			quota: &storage.QuotaInfo{Used: 0, Total: 100 * 1024 * 1024 * 1024},
+		},
+		{
+			name:        "nearly full",
+			quota:       &storage.QuotaInfo{Used: 15*1024*1024*1024 - 1024, Total: 15 * 1024 * 1024 * 1024},
+			expectedErr: ErrInsufficientStorage,
+		},
+		{
+			name:     "quota lookup failure is ignored",
+			quotaErr: errors.New("api error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mock.NewMockStorage()
+			mockStorage.QuotaInfo = tt.quota
+			mockStorage.QuotaError = tt.quotaErr
+
+			err := checkStorageQuota(context.Background(), mockStorage, entries, &queue.UserSettings{Speed: 1.5}, config.Defaults().Worker.MinFreeStorageBytes())
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+			if mockStorage.QuotaCalls != 1 {
+				t.Errorf("Expected 1 Quota call, got %d", mockStorage.QuotaCalls)
+			}
+		})
+	}
+}
+
+func TestMergeUploadedItems(t *testing.T) {
+	previous := []queue.JobItem{
+		{ID: "old-1", Title: "Episode 1", SourceURL: "https://example.com/1.mp3", Duration: time.Hour, Status: queue.StatusCompleted, DriveFileID: "file1", Speed: 1.5, ContentType: "audio/mpeg"},
+		{ID: "old-2", Title: "Episode 2", SourceURL: "https://example.com/2.mp3", Duration: time.Hour, Status: queue.StatusFailed},
+		{ID: "old-3", Title: "Episode 3", SourceURL: "https://example.com/3.mp3", Duration: time.Hour, Status: queue.StatusCompleted, DriveFileID: "file3"},
+	}
+	entries := []queue.JobItem{
+		{ID: "new-1", Title: "Episode 1", SourceURL: "https://example.com/1.mp3", Duration: time.Hour, Status: queue.StatusPending},
+		{ID: "new-2", Title: "Episode 2", SourceURL: "https://example.com/2.mp3", Duration: time.Hour, Status: queue.StatusPending},
+		{ID: "new-3", Title: "Episode 3", SourceURL: "https://example.com/3.mp3", Duration: time.Hour, Offset: time.Minute, Status: queue.StatusPending},
+	}
+
+	merged := mergeUploadedItems(previous, entries)
+
+	if merged[0].ID != "old-1" || merged[0].DriveFileID != "file1" || merged[0].Status != queue.StatusCompleted || merged[0].Speed != 1.5 {
+		t.Errorf("Expected uploaded item to be carried over, got %+v", merged[0])
+	}
+	if merged[1].ID != "new-2" || merged[1].DriveFileID != "" {
+		t.Errorf("Expected failed item to be left alone, got %+v", merged[1])
+	}
+	if merged[2].ID != "new-3" || merged[2].DriveFileID != "" {
+		t.Errorf("Expected item with a changed offset to be reprocessed, got %+v", merged[2])
+	}
+}
+
+// checkpointTracker records the job items written with a live context
+type checkpointTracker struct {
+	MockJobTracker
+	items []queue.JobItem
+}
+
+func (m *checkpointTracker) UpdateJobItem(ctx context.Context, jobID string, item queue.JobItem) error {
+	if ctx.Err() == nil {
+		m.items = append(m.items, item)
+	}
+	return nil
+}
+
+func TestUploadProgressReporter(t *testing.T) {
+	tracker := &checkpointTracker{}
+	clock := time.Unix(1700000000, 0)
+	now := func() time.Time { return clock }
+	report := uploadProgressReporter(context.Background(), tracker, "job-1", queue.JobItem{ID: "1"}, now)
+
+	// A fast upload writes its first progress and its completion, not every step
+	report(0, 0)
+	for uploaded := int64(1); uploaded <= 1000; uploaded++ {
+		report(uploaded, 1000)
+	}
+	if len(tracker.items) != 2 || tracker.items[0].Progress != 0 || tracker.items[1].Progress != 100 {
+		t.Fatalf("Expected progress written at 0%% and 100%%, got %+v", tracker.items)
+	}
+
+	// Once the interval has passed, the next change is written
+	tracker.items = nil
+	report = uploadProgressReporter(context.Background(), tracker, "job-1", queue.JobItem{ID: "2"}, now)
+	report(100, 1000)
+	report(200, 1000)
+	clock = clock.Add(uploadProgressInterval)
+	report(300, 1000)
+	if len(tracker.items) != 2 || tracker.items[1].Progress != 30 {
+		t.Errorf("Expected progress written at 10%% and 30%%, got %+v", tracker.items)
+	}
+}
+
+func TestUploadTaskCheckpointsWhenInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockStorage := mock.NewMockStorage()
+	mockStorage.UploadFileWithProgressFunc = func(filePath, filename, mimeType string, progress storage.ProgressFunc) (string, error) {
+		cancel() // the drain deadline passes while the upload is in flight
+		return "id-" + filename, nil
+	}
+	tracker := &checkpointTracker{}
+
+	task := Task{Item: queue.JobItem{ID: "1", Title: "Episode"}, Result: podcast.ProcessedEpisode{Title: "Episode", Speed: 1.5, TempFile: t.TempDir() + "/episode.m4a"}}
+	if _, err := uploadTask(ctx, mockStorage, task, tracker, "job-1"); err != nil {
+		t.Fatalf("uploadTask() unexpected error: %v", err)
+	}
+
+	if len(tracker.items) == 0 {
+		t.Fatal("Expected the completed upload to be checkpointed")
+	}
+	checkpoint := tracker.items[len(tracker.items)-1]
+	if checkpoint.Status != queue.StatusCompleted || checkpoint.DriveFileID != "id-Episode.m4a" {
+		t.Errorf("Unexpected checkpoint %+v", checkpoint)
+	}
+	if checkpoint.Speed != 1.5 || checkpoint.ContentType != "audio/mp4" {
+		t.Errorf("Expected checkpoint to record the encoding, got speed %v and %q", checkpoint.Speed, checkpoint.ContentType)
+	}
+}
+
+func TestResumable(t *testing.T) {
+	m4a, _ := audio.LookupFormat("m4a")
+	uploaded := queue.JobItem{Status: queue.StatusCompleted, DriveFileID: "file1", Speed: 1.5, ContentType: "audio/mpeg"}
+
+	if !resumable(uploaded, 1.5, audio.FormatMP3) {
+		t.Error("Expected upload with matching encoding to be resumed")
+	}
+	if resumable(uploaded, 2.0, audio.FormatMP3) {
+		t.Error("Expected upload at another speed to be redone")
+	}
+	if resumable(uploaded, 1.5, m4a) {
+		t.Error("Expected upload in another format to be redone")
+	}
+	if !resumable(queue.JobItem{Status: queue.StatusCompleted, DriveFileID: "file1"}, 2.0, m4a) {
+		t.Error("Expected checkpoint without recorded encoding to be trusted")
+	}
+	if resumable(queue.JobItem{Status: queue.StatusUploading, DriveFileID: "file1"}, 1.5, audio.FormatMP3) {
+		t.Error("Expected unfinished upload not to be resumed")
+	}
+}
+
+func TestUploadWorkerContinuesPastFailures(t *testing.T) {
+	mockStorage := mock.NewMockStorage()
+	mockStorage.UploadFileWithProgressFunc = func(filePath, filename, mimeType string, progress storage.ProgressFunc) (string, error) {
+		if filename == "Bad.mp3" {
+			return "", errors.New("upload failed")
+		}
+		return "id-" + filename, nil
+	}
+
+	tasks := make(chan Task, 3)
+	tasks <- Task{Item: queue.JobItem{ID: "1", Title: "Good"}, Result: podcast.ProcessedEpisode{Title: "Good", TempFile: t.TempDir() + "/good.mp3"}}
+	tasks <- Task{Item: queue.JobItem{ID: "2", Title: "Bad"}, Result: podcast.ProcessedEpisode{Title: "Bad", TempFile: t.TempDir() + "/bad.mp3"}}
+	// An episode that failed to encode is passed on without an upload
+	tasks <- Task{Item: queue.JobItem{ID: "3", Title: "Broken"}, Err: errors.New("ffmpeg failed")}
+	close(tasks)
+	results := make(chan Task, 3)
+	uploadWorker(context.Background(), mockStorage, tasks, results, &MockJobTracker{}, "job-1")
+	close(results)
+
+	var uploaded []string
+	failed := 0
+	for task := range results {
+		if task.Err != nil {
+			failed++
+			continue
+		}
+		uploaded = append(uploaded, task.Result.DriveFileID)
+	}
+	if failed != 2 {
+		t.Errorf("Expected 2 failed tasks, got %d", failed)
+	}
+	if len(uploaded) != 1 || uploaded[0] != "id-Good.mp3" {
+		t.Errorf("Expected only the good episode to be uploaded, got %v", uploaded)
+	}
+}
+
+func TestDownloadAndEncodeWithFakeAudio(t *testing.T) {
+	fake := audio.NewFake(3)
+	fake.EncodeFailureRate = 0.5
+	items := make([]queue.JobItem, 20)
+	for i := range items {
+		items[i] = queue.JobItem{ID: fmt.Sprint(i), Title: fmt.Sprintf("Episode %d", i), SourceURL: fmt.Sprintf("https://example.com/%d.mp3", i), Duration: 10 * time.Minute}
+	}
+
+	run := func() map[string]bool {
+		tasks := make(chan Task, len(items))
+		for _, item := range items {
+			tasks <- Task{Item: item, Speed: 2}
+		}
+		close(tasks)
+		downloaded := make(chan Task, len(items))
+		downloadWorker(context.Background(), fake, mock.NewMockStorage(), tasks, downloaded, &MockJobTracker{}, "job-1")
+		encoded := make(chan Task, len(items))
+		ffmpegWorker(context.Background(), fake, nil, downloaded, encoded, "", &MockJobTracker{}, "job-1")
+		close(encoded)
+
+		succeeded := map[string]bool{}
+		for task := range encoded {
+			if task.Err != nil {
+				if !errors.Is(task.Err, audio.ErrSimulated) {
+					t.Errorf("Expected only simulated failures, got %v", task.Err)
+				}
+				continue
+			}
+			os.Remove(task.Result.TempFile)
+			if task.Result.NewDuration != 5*time.Minute {
+				t.Errorf("Expected the listed duration at double speed, got %s", task.Result.NewDuration)
+			}
+			succeeded[task.Item.ID] = true
+		}
+		return succeeded
+	}
+
+	first := run()
+	if len(first) == 0 || len(first) == len(items) {
+		t.Errorf("Expected some episodes to fail, got %d of %d succeeding", len(first), len(items))
+	}
+	if second := run(); !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected the same episodes to fail again, got %v and %v", first, second)
+	}
+}
+
+// stubTranscriber writes a transcript of every episode except those named in fail
+type stubTranscriber struct {
+	dir  string
+	fail string
+}
+
+func (s stubTranscriber) Transcribe(ctx context.Context, audioPath string) (string, error) {
+	if filepath.Base(audioPath) == s.fail {
+		return "", errors.New("transcription failed")
+	}
+	path := filepath.Join(s.dir, filepath.Base(audioPath)+".vtt")
+	return path, os.WriteFile(path, []byte("WEBVTT\n"), 0o644)
+}
+
+func TestTranscribeWorker(t *testing.T) {
+	dir := t.TempDir()
+	tasks := make(chan Task, 4)
+	tasks <- Task{Item: queue.JobItem{ID: "1"}, Result: podcast.ProcessedEpisode{Title: "Good", TempFile: dir + "/good.mp3"}}
+	tasks <- Task{Item: queue.JobItem{ID: "2"}, Result: podcast.ProcessedEpisode{Title: "Bad", TempFile: dir + "/bad.mp3"}}
+	tasks <- Task{Item: queue.JobItem{ID: "3"}, Result: podcast.ProcessedEpisode{Title: "Reused", DownloadURL: "https://example.com/reused"}}
+	tasks <- Task{Item: queue.JobItem{ID: "4"}, Err: errors.New("ffmpeg failed")}
+	close(tasks)
+	results := make(chan Task, 4)
+	transcribeWorker(context.Background(), stubTranscriber{dir: dir, fail: "bad.mp3"}, tasks, results)
+	close(results)
+
+	transcripts := map[string]string{}
+	for task := range results {
+		transcripts[task.Item.ID] = task.TranscriptPath
+		if task.Item.ID == "2" && task.Err != nil {
+			t.Errorf("Expected a failed transcription not to fail the episode, got %v", task.Err)
+		}
+	}
+	want := map[string]string{"1": dir + "/good.mp3.vtt", "2": "", "3": "", "4": ""}
+	if !reflect.DeepEqual(transcripts, want) {
+		t.Errorf("Expected transcripts %v, got %v", want, transcripts)
+	}
+}
+
+func TestUploadTaskUploadsTranscript(t *testing.T) {
+	dir := t.TempDir()
+	transcript := dir + "/episode.vtt"
+	if err := os.WriteFile(transcript, []byte("WEBVTT\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mockStorage := mock.NewMockStorage()
+	var transcriptErr error
+	mockStorage.UploadFileFunc = func(filePath, filename, mimeType string) (string, error) {
+		if filename == "Episode.vtt" {
+			return "transcript-file", transcriptErr
+		}
+		return "episode-file", nil
+	}
+
+	task := Task{Item: queue.JobItem{ID: "1", Title: "Episode"}, Result: podcast.ProcessedEpisode{Title: "Episode", TempFile: dir + "/episode.mp3"}, TranscriptPath: transcript}
+	result, err := uploadTask(context.Background(), mockStorage, task, &MockJobTracker{}, "job-1")
+	if err != nil {
+		t.Fatalf("uploadTask() unexpected error: %v", err)
+	}
+	if calls := mockStorage.UploadFileCalls; len(calls) != 2 || calls[1].Filename != "Episode.vtt" || calls[1].MimeType != "text/vtt" {
+		t.Errorf("Expected the transcript to be uploaded, got %+v", mockStorage.UploadFileCalls)
+	}
+	if result.TranscriptURL != "https://mock-download-url.com/transcript-file" {
+		t.Errorf("Expected the transcript's download URL, got %q", result.TranscriptURL)
+	}
+	if _, err := os.Stat(transcript); !os.IsNotExist(err) {
+		t.Errorf("Expected the transcript temp file to be removed, got %v", err)
+	}
+
+	// An episode is published without its transcript if the transcript's upload fails
+	if err := os.WriteFile(transcript, []byte("WEBVTT\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	transcriptErr = errors.New("upload failed")
+	result, err = uploadTask(context.Background(), mockStorage, task, &MockJobTracker{}, "job-1")
+	if err != nil {
+		t.Fatalf("uploadTask() unexpected error: %v", err)
+	}
+	if result.TranscriptURL != "" {
+		t.Errorf("Expected no transcript, got %q", result.TranscriptURL)
+	}
+}
+
+func TestPartialFailureError(t *testing.T) {
+	var err error = &PartialFailureError{Failed: 2, Total: 5}
+
+	var partial *PartialFailureError
+	if !errors.As(err, &partial) {
+		t.Fatal("Expected errors.As to match PartialFailureError")
+	}
+	if err.Error() != "2 of 5 items failed" {
+		t.Errorf("Unexpected error message: %s", err.Error())
+	}
+}
+
+func TestRecover(t *testing.T) {
+	run := func() (err error) {
+		defer Recover(&err)
+		var items []queue.JobItem
+		_ = items[3]
+		return nil
+	}
+
+	var panicked *PanicError
+	if err := run(); !errors.As(err, &panicked) {
+		t.Fatalf("Expected a PanicError, got %v", err)
+	}
+	reason := panicked.FailReason()
+	if !strings.HasPrefix(reason, "job processing panicked: runtime error: index out of range") {
+		t.Errorf("Unexpected fail reason: %s", reason)
+	}
+	if !strings.Contains(reason, "TestRecover") {
+		t.Errorf("Expected the stack trace in the fail reason, got: %s", reason)
+	}
+
+	panicked.Stack = make([]byte, 2*maxPanicStack)
+	if len(panicked.FailReason()) > len(panicked.Error())+1+maxPanicStack {
+		t.Error("Expected the stack trace to be truncated")
+	}
+}
+
+func TestPanicGuard(t *testing.T) {
+	var guard panicGuard
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer guard.recover()
+			panic("malformed backup")
+		}()
+	}
+	wg.Wait()
+
+	var panicked *PanicError
+	if !errors.As(guard.Err(), &panicked) || panicked.Value != "malformed backup" {
+		t.Errorf("Expected the goroutine panic to be recorded, got %v", guard.Err())
+	}
+}
+
+func TestRepairFeedPermissions(t *testing.T) {
+	mockStorage := mock.NewMockStorage()
+	mockStorage.GetFilesFiles = []*storage.FileMeta{{ID: "feed-file"}}
+	mockStorage.DownloadFileContent = podcast.NewRSSProcessor("Test", mockStorage, testFolders).CreateRSSXML([]podcast.ProcessedEpisode{
+		{Title: "Episode 1", DownloadURL: "https://example.com/ep1"},
+		{Title: "Episode 2", DownloadURL: "https://example.com/ep2"},
+	})
+	mockStorage.ExtractFileIDFromURLFunc = func(url string) string {
+		return url[len("https://example.com/"):]
+	}
+	mockStorage.EnsurePublicFunc = func(fileID string) (bool, error) {
+		return fileID == "ep2", nil
+	}
+
+	proc := NewProcessorWithDependencies(nil, &auth.MockTokenProvider{Token: "valid-token"}, mock.NewMockTokenSourceStorageCreator(mockStorage, nil), &MockJobTracker{}, testFolders)
+
+	repaired, err := proc.RepairFeedPermissions(context.Background(), "user1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if repaired != 1 {
+		t.Errorf("Expected 1 file repaired, got %d", repaired)
+	}
+	if len(mockStorage.EnsurePublicCalls) != 3 {
+		t.Errorf("Expected the feed and both enclosures to be checked, got %v", mockStorage.EnsurePublicCalls)
+	}
+}
+
+// stubMetadataProvider returns canned episodes keyed by GUID
+type stubMetadataProvider map[string]*metadata.Episode
+
+func (s stubMetadataProvider) Lookup(ctx context.Context, query metadata.Query) (*metadata.Episode, error) {
+	if query.GUID == "broken" {
+		return nil, errors.New("feed unavailable")
+	}
+	return s[query.GUID], nil
+}
+
+func TestEnrichEpisodes(t *testing.T) {
+	pubDate := time.Date(2025, 6, 3, 9, 0, 0, 0, time.UTC)
+	proc := NewProcessorWithDependencies(nil, &auth.MockTokenProvider{}, nil, &MockJobTracker{}, testFolders)
+	proc.SetMetadataProvider(stubMetadataProvider{
+		"guid-1": {PubDate: pubDate, Description: "Hill repeats", Image: "https://example.com/ep1.jpg"},
+	})
+
+	items := []queue.JobItem{
+		{ID: "item-1", FeedURL: "https://example.com/feed", GUID: "guid-1"},
+		{ID: "item-2", FeedURL: "https://example.com/feed", GUID: "unknown"},
+		{ID: "item-3", FeedURL: "https://example.com/feed", GUID: "broken"},
+		{ID: "item-4"}, // no show feed known
+	}
+	episodes := []podcast.ProcessedEpisode{{UUID: "item-1"}, {UUID: "item-2"}, {UUID: "item-3"}, {UUID: "item-4"}}
+
+	proc.enrichEpisodes(context.Background(), items, episodes)
+
+	if !episodes[0].PubDate.Equal(pubDate) || episodes[0].Description != "Hill repeats" || episodes[0].Image != "https://example.com/ep1.jpg" {
+		t.Errorf("Expected first episode to be enriched, got %+v", episodes[0])
+	}
+	for _, episode := range episodes[1:] {
+		if !episode.PubDate.IsZero() || episode.Description != "" || episode.Image != "" {
+			t.Errorf("Expected episode %s to be left alone, got %+v", episode.UUID, episode)
+		}
+	}
+}
+
+// stubProber returns a fixed probe result
+type stubProber struct {
+	probe *audio.SourceProbe
+	err   error
+}
+
+func (s stubProber) ProbeSource(ctx context.Context, url string) (*audio.SourceProbe, error) {
+	return s.probe, s.err
+}
+
+func TestCopyThrough(t *testing.T) {
+	cfg := config.Defaults().Worker
+	cfg.CopyThroughMaxSeconds, cfg.CopyThroughMaxKbps = 600, 64
+
+	short := queue.JobItem{ID: "item-1", Title: "Bonus", SourceURL: "https://example.com/bonus.mp3", Duration: 5 * time.Minute}
+	lowBitrate := &audio.SourceProbe{Size: 1200000, ContentType: "audio/mpeg"} // 32kbps over five minutes
+	highBitrate := &audio.SourceProbe{Size: 4800000, ContentType: "audio/mpeg"}
+
+	tests := []struct {
+		name     string
+		item     queue.JobItem
+		prober   stubProber
+		expected bool
+	}{
+		{name: "short low bitrate source", item: short, prober: stubProber{probe: lowBitrate}, expected: true},
+		{name: "high bitrate source", item: short, prober: stubProber{probe: highBitrate}},
+		{name: "unknown size", item: short, prober: stubProber{probe: &audio.SourceProbe{}}},
+		{name: "probe failure", item: short, prober: stubProber{err: errors.New("timeout")}},
+		{name: "too long", item: queue.JobItem{ID: "item-2", Duration: time.Hour}, prober: stubProber{probe: lowBitrate}},
+		{name: "needs trimming", item: queue.JobItem{ID: "item-3", Duration: 5 * time.Minute, Offset: time.Minute}, prober: stubProber{probe: lowBitrate}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := copyThrough(context.Background(), cfg, tt.prober, tt.item)
+			if ok != tt.expected {
+				t.Fatalf("copyThrough() = %v, want %v", ok, tt.expected)
+			}
+			if !ok {
+				return
+			}
+			if result.DownloadURL != tt.item.SourceURL || result.Speed != 1 || result.NewDuration != tt.item.Duration || result.ContentType != "audio/mpeg" {
+				t.Errorf("Unexpected copy-through episode: %+v", result)
+			}
+		})
+	}
+}
+
+func TestRetainDroppedEpisodes(t *testing.T) {
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	episodeMapping := map[string]podcast.ExistingEpisode{
+		"title:Current":      {Title: "Current", DownloadURL: "https://example.com/current"},
+		"guid:just-dropped":  {Title: "Just dropped", SourceGUID: "just-dropped", DownloadURL: "https://example.com/just-dropped"},
+		"title:Recent":       {Title: "Recent", DownloadURL: "https://example.com/recent", DroppedAt: now.Add(-24 * time.Hour)},
+		"title:Expired":      {Title: "Expired", DownloadURL: "https://example.com/expired", DroppedAt: now.Add(-8 * 24 * time.Hour)},
+		"guid:other-current": {Title: "Current", SourceGUID: "other-current", DownloadURL: "https://example.com/other-current"},
+	}
+	// The playlist has an episode titled like one in the feed, but of another show
+	items := []queue.JobItem{{Title: "Current"}, {Title: "Current", GUID: "other-current"}}
+
+	t.Run("Disabled", func(t *testing.T) {
+		if retained := retainDroppedEpisodes(episodeMapping, items, 0, now); len(retained) != 0 {
+			t.Errorf("Expected nothing retained, got %v", retained)
+		}
+	})
+
+	t.Run("Within retention", func(t *testing.T) {
+		retained := retainDroppedEpisodes(episodeMapping, items, 7*24*time.Hour, now)
+
+		if len(retained) != 2 || retained[0].Title != "Just dropped" || retained[1].Title != "Recent" || retained[0].SourceGUID != "just-dropped" {
+			t.Fatalf("Unexpected retained episodes: %+v", retained)
+		}
+		if !retained[0].DroppedAt.Equal(now) {
+			t.Errorf("Expected newly dropped episode to be stamped %v, got %v", now, retained[0].DroppedAt)
+		}
+		if !retained[1].DroppedAt.Equal(now.Add(-24 * time.Hour)) {
+			t.Errorf("Expected drop time to be kept, got %v", retained[1].DroppedAt)
+		}
+	})
+}
+
+func TestNewAudio(t *testing.T) {
+	format := audio.FormatMP3
+	episodeMapping := map[string]podcast.ExistingEpisode{
+		"title:Published": {Title: "Published", DownloadURL: "https://example.com/published"},
+	}
+	entries := []queue.JobItem{
+		{Title: "Published", Duration: time.Hour},
+		{Title: "Uploaded", Duration: time.Hour, Status: queue.StatusCompleted, DriveFileID: "file-1", Speed: 1.5, ContentType: format.ContentType},
+		{Title: "New", Duration: time.Hour, Offset: 20 * time.Minute},
+		{Title: "Also new", Duration: 30 * time.Minute},
+	}
+
+	episodes, length := newAudio(entries, episodeMapping, &queue.UserSettings{Speed: 1.5, OutputFormat: format.Extension})
+	if episodes != 2 {
+		t.Errorf("Expected 2 new episodes, got %d", episodes)
+	}
+	if length != 70*time.Minute {
+		t.Errorf("Expected 70m of new audio, got %v", length)
+	}
+}
+
+func TestSortEpisodes(t *testing.T) {
+	episodes := []podcast.ProcessedEpisode{
+		{Title: "Legacy", Position: 0},
+		{Title: "Third", Position: 3},
+		{Title: "First", Position: 1},
+		{Title: "Second", Position: 2},
+	}
+	sortEpisodes(episodes)
+
+	for i, want := range []string{"First", "Second", "Third", "Legacy"} {
+		if episodes[i].Title != want {
+			t.Errorf("episodes[%d] = %s, want %s", i, episodes[i].Title, want)
+		}
+	}
+}
+
+func TestApplyFeedOffsets(t *testing.T) {
+	episodeMapping := map[string]podcast.ExistingEpisode{
+		podcast.EpisodeKey("", "", "Listening"): {Title: "Listening", OriginalDuration: time.Hour, Offset: 20 * time.Minute},
+		podcast.EpisodeKey("", "", "Backed up"): {Title: "Backed up", OriginalDuration: time.Hour, Offset: 20 * time.Minute},
+		podcast.EpisodeKey("", "", "Re-issued"): {Title: "Re-issued", OriginalDuration: time.Hour, Offset: 20 * time.Minute},
+		podcast.EpisodeKey("", "", "Unstarted"): {Title: "Unstarted", OriginalDuration: time.Hour},
+	}
+	entries := []queue.JobItem{
+		{Title: "Listening", Duration: time.Hour},
+		{Title: "Backed up", Duration: time.Hour, Offset: 30 * time.Minute},
+		{Title: "Re-issued", Duration: 2 * time.Hour},
+		{Title: "Unstarted", Duration: time.Hour},
+		{Title: "New", Duration: time.Hour},
+	}
+	applyFeedOffsets(context.Background(), entries, episodeMapping)
+
+	for i, want := range []time.Duration{20 * time.Minute, 30 * time.Minute, 0, 0, 0} {
+		if entries[i].Offset != want {
+			t.Errorf("%s offset = %v, want %v", entries[i].Title, entries[i].Offset, want)
+		}
+	}
+}
+
+func TestSameFormat(t *testing.T) {
+	m4a, _ := audio.LookupFormat("m4a")
+	if !sameFormat(podcast.ExistingEpisode{}, audio.FormatMP3) {
+		t.Error("Expected episode without content type to be MP3")
+	}
+	if sameFormat(podcast.ExistingEpisode{}, m4a) {
+		t.Error("Expected MP3 episode not to match m4a")
+	}
+	if !sameFormat(podcast.ExistingEpisode{ContentType: "audio/mp4"}, m4a) {
+		t.Error("Expected audio/mp4 episode to match m4a")
+	}
+}
+
+// mockFileID extracts the file ID from a MockStorage download URL
+func mockFileID(url string) string {
+	if fileID, ok := strings.CutPrefix(url, "https://mock-download-url.com/"); ok {
+		return fileID
+	}
+	return ""
+}
+
+func TestRebuildFeed(t *testing.T) {
+	mockStorage := mock.NewMockStorage()
+	mockStorage.GetFilesFiles = []*storage.FileMeta{{ID: "feed-file"}}
+	mockStorage.DownloadFileContent = podcast.NewRSSProcessor("Test", mockStorage, testFolders).CreateRSSXML([]podcast.ProcessedEpisode{
+		{Title: "Episode 1", DriveFileID: "ep1"},
+		{Title: "Episode 2", DriveFileID: "ep2"},
+		{Title: "Copied", DownloadURL: "https://example.com/copied.mp3"},
+	})
+	mockStorage.ExtractFileIDFromURLFunc = mockFileID
+	mockStorage.FileExistsFunc = func(fileID string) (bool, error) {
+		return fileID == "ep1", nil
+	}
+	mockStorage.UploadStringID = "feed-file"
+
+	proc := NewProcessorWithDependencies(nil, &auth.MockTokenProvider{Token: "valid-token"}, mock.NewMockTokenSourceStorageCreator(mockStorage, nil), NewLocalStore(nil), testFolders)
+
+	feedURL, dropped, err := proc.RebuildFeed(context.Background(), "user1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if dropped != 1 {
+		t.Errorf("Expected the episode with a missing file to be dropped, got %d", dropped)
+	}
+	if feedURL != "https://mock-download-url.com/feed-file" {
+		t.Errorf("Unexpected feed URL %q", feedURL)
+	}
+	if len(mockStorage.UploadStringCalls) != 1 || mockStorage.UploadStringCalls[0].FileID != "feed-file" {
+		t.Fatalf("Expected the feed to be updated in place, got %v", mockStorage.UploadStringCalls)
+	}
+	content := mockStorage.UploadStringCalls[0].Content
+	if strings.Contains(content, "Episode 2") || !strings.Contains(content, "Episode 1") || !strings.Contains(content, "Copied") {
+		t.Errorf("Unexpected rebuilt feed: %s", content)
+	}
+}
+
+func TestPublishFeed(t *testing.T) {
+	mockStorage := mock.NewMockStorage()
+	mockStorage.ExtractFileIDFromURLFunc = mockFileID
+	mockStorage.UploadStringID = "feed-file"
+	podcastProcessor := podcast.NewRSSProcessor("Test", mockStorage, testFolders)
+	episode := func(title, fileID string) podcast.ProcessedEpisode {
+		return podcast.ProcessedEpisode{Title: title, DownloadURL: "https://mock-download-url.com/" + fileID}
+	}
+
+	// The job loaded the feed before another job replaced Removed with Added,
+	// and republished Replaced
+	loaded, err := podcastProcessor.ExtractEpisodeMapping(podcastProcessor.CreateRSSXML([]podcast.ProcessedEpisode{
+		episode("Kept", "kept"), episode("Removed", "removed"),
+	}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	mockStorage.GetFilesFiles = []*storage.FileMeta{{ID: "feed-file"}}
+	mockStorage.DownloadFileContent = podcastProcessor.CreateRSSXML([]podcast.ProcessedEpisode{
+		episode("Kept", "kept"), episode("Added", "added"), episode("Replaced", "replaced-old"),
+	})
+	results := []podcast.ProcessedEpisode{episode("Kept", "kept"), episode("Removed", "removed"), episode("Replaced", "replaced-new")}
+
+	proc := NewProcessorWithDependencies(nil, &auth.MockTokenProvider{Token: "valid-token"}, mock.NewMockTokenSourceStorageCreator(mockStorage, nil), NewLocalStore(nil), testFolders)
+	// Without an archive, dropped episodes are deleted
+	cfg := config.Defaults().Worker
+	cfg.ArchiveRetentionDays = 0
+	proc.SetWorkerConfig(cfg)
+	feedURL, dropped, err := proc.publishFeed(context.Background(), "user1", &queue.UserSettings{}, loaded, mockStorage, podcastProcessor, nil, results)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if feedURL != "https://mock-download-url.com/feed-file" {
+		t.Errorf("Unexpected feed URL %q", feedURL)
+	}
+	if dropped != 1 {
+		t.Errorf("Expected the episode the other job removed to be dropped, got %d", dropped)
+	}
+	if len(mockStorage.UploadStringCalls) != 1 || mockStorage.UploadStringCalls[0].FileID != "feed-file" {
+		t.Fatalf("Expected the current feed to be updated in place, got %v", mockStorage.UploadStringCalls)
+	}
+	content := mockStorage.UploadStringCalls[0].Content
+	for _, want := range []string{"Kept", "Added", "replaced-new"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected the feed to contain %q: %s", want, content)
+		}
+	}
+	if strings.Contains(content, "Removed") || strings.Contains(content, "replaced-old") {
+		t.Errorf("Unexpected episodes in the feed: %s", content)
+	}
+	if !reflect.DeepEqual(mockStorage.DeleteFileCalls, []string{"replaced-old"}) {
+		t.Errorf("Expected only the replaced file to be deleted, got %v", mockStorage.DeleteFileCalls)
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+	mockStorage := mock.NewMockStorage()
+	mockStorage.GetFilesFunc = func(query storage.Query) ([]*storage.FileMeta, error) {
+		if query.ExactName != "" {
+			return []*storage.FileMeta{{ID: "feed-file"}}, nil
+		}
+		return []*storage.FileMeta{
+			{ID: "feed-file", Name: "playrun_addict.xml", ModifiedTime: old},
+			{ID: "ep1", Name: "Episode 1.mp3", ModifiedTime: old},
+			{ID: "ep1-transcript", Name: "Episode 1.vtt", ModifiedTime: old},
+			{ID: "orphan", Name: "Orphan.m4a", ModifiedTime: old},
+			{ID: "orphan-transcript", Name: "Orphan.vtt", ModifiedTime: old},
+			{ID: "uploading", Name: "Uploading.mp3", ModifiedTime: time.Now()},
+			{ID: "notes", Name: "notes.txt", ModifiedTime: old},
+		}, nil
+	}
+	mockStorage.DownloadFileContent = podcast.NewRSSProcessor("Test", mockStorage, testFolders).CreateRSSXML([]podcast.ProcessedEpisode{
+		{Title: "Episode 1", DriveFileID: "ep1", TranscriptURL: "https://mock-download-url.com/ep1-transcript"},
+	})
+	mockStorage.ExtractFileIDFromURLFunc = mockFileID
+
+	proc := NewProcessorWithDependencies(nil, &auth.MockTokenProvider{Token: "valid-token"}, mock.NewMockTokenSourceStorageCreator(mockStorage, nil), NewLocalStore(nil), testFolders)
+
+	garbage, err := proc.CollectGarbage(context.Background(), "user1", 24*time.Hour, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(garbage) != 2 || garbage[0].ID != "orphan" || garbage[1].ID != "orphan-transcript" {
+		t.Errorf("Expected only the orphaned episode and transcript, got %v", garbage)
+	}
+	if len(mockStorage.DeleteFileCalls) != 0 {
+		t.Errorf("Expected a dry run not to delete, got %v", mockStorage.DeleteFileCalls)
+	}
+
+	if _, err := proc.CollectGarbage(context.Background(), "user1", 24*time.Hour, false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(mockStorage.DeleteFileCalls, []string{"orphan", "orphan-transcript"}) {
+		t.Errorf("Expected the orphaned episode and transcript to be deleted, got %v", mockStorage.DeleteFileCalls)
+	}
+}
+
+func TestCollectGarbageWithoutFeed(t *testing.T) {
+	mockStorage := mock.NewMockStorage()
+	proc := NewProcessorWithDependencies(nil, &auth.MockTokenProvider{Token: "valid-token"}, mock.NewMockTokenSourceStorageCreator(mockStorage, nil), NewLocalStore(nil), testFolders)
+
+	if _, err := proc.CollectGarbage(context.Background(), "user1", 0, false); !errors.Is(err, ErrNoFeed) {
+		t.Errorf("Expected ErrNoFeed, got %v", err)
+	}
+	if len(mockStorage.DeleteFileCalls) != 0 {
+		t.Errorf("Expected nothing to be deleted, got %v", mockStorage.DeleteFileCalls)
+	}
+}
+
+func TestLoadClips(t *testing.T) {
+	mockStorage := mock.NewMockStorage()
+	mockStorage.GetFilesFunc = func(query storage.Query) ([]*storage.FileMeta, error) {
+		if query.ExactName == podcast.ClipOutro.Filename() {
+			return []*storage.FileMeta{{ID: "outro-file", MD5: "abc123"}}, nil
+		}
+		return nil, nil
+	}
+	clipPath := filepath.Join(t.TempDir(), "outro")
+	if err := os.WriteFile(clipPath, []byte("audio"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mockStorage.DownloadFileToTempPath = clipPath
+
+	audioProcessor := audio.NewFFmpeg(config.Defaults().Worker)
+	tag, cleanup := loadClips(context.Background(), mockStorage, testFolders, audioProcessor)
+	if tag != "outro:abc123" {
+		t.Errorf("Expected the tag to name the outro's content, got %q", tag)
+	}
+	if len(mockStorage.DownloadFileToTempCalls) != 1 || mockStorage.DownloadFileToTempCalls[0] != "outro-file" {
+		t.Errorf("Expected only the outro to be downloaded, got %v", mockStorage.DownloadFileToTempCalls)
+	}
+
+	cleanup()
+	if _, err := os.Stat(clipPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the downloaded clip to be removed, got %v", err)
+	}
+}
+
+func TestDiffPlaylist(t *testing.T) {
+	settings := &queue.UserSettings{SpeedProfiles: []queue.SpeedProfile{{Podcast: "https://example.com/news.xml", Speed: 2}}}
+	speed, format := settings.PlaybackSpeed(), settings.Format()
+	published := podcast.ExistingEpisode{Speed: speed, ContentType: format.ContentType, DownloadURL: "https://example.com/file"}
+	feed := &userFeed{
+		storage:  mock.NewMockStorage(),
+		settings: settings,
+		episodes: map[string]podcast.ExistingEpisode{
+			"guid:same":      published,
+			"guid:moved":     published,
+			"guid:re-speed":  {Speed: 2, ContentType: format.ContentType},
+			"guid:forgotten": published,
+			"guid:profiled":  {Speed: 2, ContentType: format.ContentType, DownloadURL: "https://example.com/news"},
+		},
+	}
+	previous := playlistFingerprint([]queue.JobItem{
+		{GUID: "same", Duration: time.Hour},
+		{GUID: "moved", Duration: time.Hour},
+		{GUID: "re-speed", Duration: time.Hour},
+		{GUID: "dropped", Duration: time.Hour},
+		{GUID: "profiled", Duration: time.Hour},
+	})
+	entries := []queue.JobItem{
+		{GUID: "same", Duration: time.Hour},
+		{GUID: "moved", Duration: time.Hour, Offset: 10 * time.Minute},
+		{GUID: "re-speed", Duration: time.Hour},
+		{GUID: "forgotten", Duration: time.Hour},
+		{GUID: "new", Duration: time.Hour},
+		{GUID: "profiled", Duration: time.Hour, FeedURL: "https://example.com/news.xml"},
+	}
+
+	p := &Processor{}
+	changed, unchanged := p.diffPlaylist(context.Background(), entries, previous, feed)
+	if len(unchanged) != 2 || unchanged[0].GUID != "same" || unchanged[1].GUID != "profiled" {
+		t.Errorf("Expected only the unchanged published entries to be carried, got %+v", unchanged)
+	}
+	if len(changed) != 4 {
+		t.Errorf("Expected 4 entries to process, got %+v", changed)
+	}
+	if !playlistShrank(previous, playlistFingerprint(entries)) {
+		t.Error("Expected the dropped entry to count as a change")
+	}
+
+	// Without a previous backup everything is processed
+	if changed, unchanged := p.diffPlaylist(context.Background(), entries, nil, feed); len(changed) != len(entries) || len(unchanged) != 0 {
+		t.Errorf("Expected every entry to be processed, got %d changed and %d unchanged", len(changed), len(unchanged))
+	}
+}
+
+func TestSkipCuts(t *testing.T) {
+	rule := &queue.SkipRule{Podcast: "The Daily", SkipStartSeconds: 90, SkipEndSeconds: 60, Chapters: []string{"Ads"}}
+	chapters := []audio.Chapter{{Title: "Ads", Start: 20 * time.Minute, End: 22 * time.Minute}}
+	item := queue.JobItem{Title: "The Daily - Monday", Offset: 5 * time.Minute}
+
+	// The start was already listened to; cut segments are measured from the offset
+	cuts, segments, total := skipCuts(context.Background(), rule, item, time.Hour, chapters)
+	wantCuts := []queue.Cut{
+		{Start: 20 * time.Minute, End: 22 * time.Minute, Reason: "Ads"},
+		{Start: 59 * time.Minute, End: time.Hour, Reason: "end"},
+	}
+	if !reflect.DeepEqual(cuts, wantCuts) {
+		t.Errorf("Expected cuts %+v, got %+v", wantCuts, cuts)
+	}
+	wantSegments := []audio.Segment{{Start: 15 * time.Minute, End: 17 * time.Minute}, {Start: 54 * time.Minute, End: 55 * time.Minute}}
+	if !reflect.DeepEqual(segments, wantSegments) {
+		t.Errorf("Expected segments %+v, got %+v", wantSegments, segments)
+	}
+	if total != 3*time.Minute {
+		t.Errorf("Expected 3m cut, got %v", total)
+	}
+
+	// A cut the offset falls into only records what's left of it
+	cuts, _, total = skipCuts(context.Background(), rule, queue.JobItem{Offset: time.Minute}, time.Hour, nil)
+	if len(cuts) != 2 || cuts[0] != (queue.Cut{Start: time.Minute, End: 90 * time.Second, Reason: "start"}) || total != 90*time.Second {
+		t.Errorf("Expected the start cut to begin at the offset, got %+v totalling %v", cuts, total)
+	}
+	if got := cutLength(cuts); got != total {
+		t.Errorf("Expected the recorded cuts to last %v, got %v", total, got)
+	}
+
+	// A rule that would leave nothing cuts nothing
+	if cuts, segments, total := skipCuts(context.Background(), rule, queue.JobItem{}, time.Minute, nil); cuts != nil || segments != nil || total != 0 {
+		t.Errorf("Expected no cuts of a short episode, got %+v", cuts)
+	}
+	if cuts, _, _ := skipCuts(context.Background(), nil, item, time.Hour, chapters); cuts != nil {
+		t.Errorf("Expected no cuts without a rule, got %+v", cuts)
+	}
+}
+
+func TestPreambleText(t *testing.T) {
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		show      string
+		published time.Time
+		want      string
+	}{
+		{"show and date", "The Daily", time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC), "The Daily: Tariffs, originally published March 3rd, at 1.6x"},
+		{"earlier year", "The Daily", time.Date(2024, 12, 11, 9, 0, 0, 0, time.UTC), "The Daily: Tariffs, originally published December 11th, 2024, at 1.6x"},
+		{"unknown show and date", "", time.Time{}, "Tariffs, at 1.6x"},
+		{"ordinal", "", time.Date(2025, 5, 22, 9, 0, 0, 0, time.UTC), "Tariffs, originally published May 22nd, at 1.6x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := preambleText(tt.show, "Tariffs", tt.published, now, 1.6); got != tt.want {
+				t.Errorf("preambleText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// playlistURLStore is an in-memory PlaylistURLStore
+type playlistURLStore struct {
+	playlists map[string]*queue.PlaylistURL
+	settings  map[string]*queue.UserSettings
+	jobs      []*queue.Job
+}
+
+func (s *playlistURLStore) GetPlaylistURLUsers(ctx context.Context) ([]string, error) {
+	var users []string
+	for userID := range s.playlists {
+		users = append(users, userID)
+	}
+	return users, nil
+}
+
+func (s *playlistURLStore) GetPlaylistURL(ctx context.Context, userID string) (*queue.PlaylistURL, error) {
+	return s.playlists[userID], nil
+}
+
+func (s *playlistURLStore) RecordPlaylistFetch(ctx context.Context, userID string, playlist *queue.PlaylistURL) error {
+	recorded := *playlist
+	recorded.JobID = s.playlists[userID].JobID
+	s.playlists[userID] = &recorded
+	return nil
+}
+
+func (s *playlistURLStore) SetPlaylistJob(ctx context.Context, userID string, rawURL string, jobID string) error {
+	s.playlists[userID].JobID = jobID
+	return nil
+}
+
+func (s *playlistURLStore) GetJob(ctx context.Context, jobID string) (*queue.Job, error) {
+	for _, job := range s.jobs {
+		if job.ID == jobID {
+			return job, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *playlistURLStore) GetUserSettings(ctx context.Context, userID string) (*queue.UserSettings, error) {
+	if settings, ok := s.settings[userID]; ok {
+		return settings, nil
+	}
+	return &queue.UserSettings{}, nil
+}
+
+func (s *playlistURLStore) Enqueue(ctx context.Context, job *queue.Job) error {
+	job.Status = queue.JobStatusQueued
+	s.jobs = append(s.jobs, job)
+	return nil
+}
+
+// complete finishes the store's jobs as the processor would, recording the
+// playlist they fetched
+func (s *playlistURLStore) complete(t *testing.T, client *http.Client) {
+	t.Helper()
+	for userID, playlist := range s.playlists {
+		remote, err := sources.FetchM3U8(context.Background(), client, playlist.URL, "", "")
+		if err != nil {
+			t.Fatalf("Failed to fetch playlist: %v", err)
+		}
+		s.RecordPlaylistFetch(context.Background(), userID, &queue.PlaylistURL{
+			URL:          playlist.URL,
+			ETag:         remote.ETag,
+			LastModified: remote.LastModified,
+			Checksum:     remote.Checksum(),
+		})
+	}
+	for _, job := range s.jobs {
+		job.Status = queue.JobStatusCompleted
+	}
+}
+
+func TestPollPlaylistURLs(t *testing.T) {
+	content := "#EXTM3U\n#EXTINF:60,Episode\nhttps://example.com/ep.mp3\n"
+	etag := `"v1"`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if etag != "" && r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	playlistURL := server.URL + "/commute.m3u8"
+	store := &playlistURLStore{playlists: map[string]*queue.PlaylistURL{"user-1": {URL: playlistURL}}}
+	now := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	schedule, err := cron.Parse(config.Defaults().Worker.PollSchedule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A newly registered playlist is processed
+	if enqueued := PollPlaylistURLs(ctx, store, server.Client(), now, schedule); enqueued != 1 {
+		t.Fatalf("Expected 1 job for a new playlist, got %d", enqueued)
+	}
+	job := store.jobs[0]
+	if job.UserID != "user-1" || job.PlaylistURL != playlistURL || job.Filename != "commute.m3u8" {
+		t.Errorf("Unexpected job %+v", job)
+	}
+	if got := store.playlists["user-1"]; got.JobID != job.ID || got.Checksum != "" {
+		t.Errorf("Expected the job to be recorded but not the fetch, got %+v", got)
+	}
+
+	// Nothing more is enqueued while the job waits, and a failed job is retried
+	if enqueued := PollPlaylistURLs(ctx, store, server.Client(), now, schedule); enqueued != 0 {
+		t.Errorf("Expected no job while the last one is queued, got %d", enqueued)
+	}
+	job.Status = queue.JobStatusFailed
+	if enqueued := PollPlaylistURLs(ctx, store, server.Client(), now, schedule); enqueued != 1 {
+		t.Errorf("Expected the playlist to be processed again after its job failed, got %d", enqueued)
+	}
+	store.complete(t, server.Client())
+	if got := store.playlists["user-1"]; got.ETag != etag || got.Checksum == "" {
+		t.Errorf("Expected the processed fetch to be recorded, got %+v", got)
+	}
+
+	// The server says it's unchanged
+	if enqueued := PollPlaylistURLs(ctx, store, server.Client(), now, schedule); enqueued != 0 {
+		t.Errorf("Expected no job for an unmodified playlist, got %d", enqueued)
+	}
+
+	// Without validators, the same content is recognized by its checksum
+	etag = ""
+	if enqueued := PollPlaylistURLs(ctx, store, server.Client(), now, schedule); enqueued != 0 {
+		t.Errorf("Expected no job for unchanged content, got %d", enqueued)
+	}
+
+	content += "#EXTINF:60,Another\nhttps://example.com/another.mp3\n"
+	if enqueued := PollPlaylistURLs(ctx, store, server.Client(), now, schedule); enqueued != 1 {
+		t.Errorf("Expected a job for a changed playlist, got %d", enqueued)
+	}
+	store.complete(t, server.Client())
+
+	// Users with their own schedule are only polled when it says so
+	content += "#EXTINF:60,Third\nhttps://example.com/third.mp3\n"
+	store.settings = map[string]*queue.UserSettings{"user-1": {PollSchedule: "0 6-23 * * *"}}
+	if enqueued := PollPlaylistURLs(ctx, store, server.Client(), now, schedule); enqueued != 0 {
+		t.Errorf("Expected no poll outside the user's schedule, got %d", enqueued)
+	}
+	if enqueued := PollPlaylistURLs(ctx, store, server.Client(), now.Add(6*time.Hour), schedule); enqueued != 1 {
+		t.Errorf("Expected a poll on the user's schedule, got %d", enqueued)
+	}
+}
+
+func TestEncodingTagsFakeAudio(t *testing.T) {
+	proc := NewProcessorWithDependencies(nil, &auth.MockTokenProvider{}, nil, &MockJobTracker{}, testFolders)
+	settings := &queue.UserSettings{}
+
+	_, real := proc.encoding(settings, "", audio.NewFFmpeg(config.Defaults().Worker))
+	_, fake := proc.encoding(settings, "", audio.NewFake(0))
+	if fake == real || !strings.Contains(fake, audio.FakeEncoding) {
+		t.Errorf("Expected fake audio tagged in the encoding, got %q and real %q", fake, real)
+	}
+}
@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"sort"
+	"time"
+
+	"cobblepod/internal/audio"
+	"cobblepod/internal/podcast"
+	"cobblepod/internal/queue"
+)
+
+// retainDroppedEpisodes returns the feed episodes that are no longer in the
+// playlist but still within the retention period, ordered by title. An
+// episode's drop time is recorded in the feed the first time it is retained.
+func retainDroppedEpisodes(episodeMapping map[string]podcast.ExistingEpisode, items []queue.JobItem, retention time.Duration, now time.Time) []podcast.ProcessedEpisode {
+	if retention <= 0 {
+		return nil
+	}
+
+	inPlaylist := make(map[string]bool, len(items))
+	for _, item := range items {
+		if key, _, ok := podcast.FindEpisode(episodeMapping, item); ok {
+			inPlaylist[key] = true
+		}
+	}
+
+	var retained []podcast.ProcessedEpisode
+	for key, episode := range episodeMapping {
+		if inPlaylist[key] {
+			continue
+		}
+		droppedAt := episode.DroppedAt
+		if droppedAt.IsZero() {
+			droppedAt = now
+		}
+		if now.Sub(droppedAt) >= retention {
+			continue
+		}
+		published := publishedEpisode(episode)
+		published.DroppedAt = droppedAt
+		retained = append(retained, published)
+	}
+	sort.Slice(retained, func(i, j int) bool { return retained[i].Title < retained[j].Title })
+	return retained
+}
+
+// publishedEpisode returns a feed episode as it is republished without
+// processing it again
+func publishedEpisode(episode podcast.ExistingEpisode) podcast.ProcessedEpisode {
+	return podcast.ProcessedEpisode{
+		Title:            episode.Title,
+		OriginalURL:      episode.SourceURL,
+		SourceGUID:       episode.SourceGUID,
+		OriginalDuration: episode.OriginalDuration,
+		NewDuration:      episode.Duration,
+		ListedDuration:   episode.ListedDuration,
+		DownloadURL:      episode.DownloadURL,
+		OriginalGUID:     episode.OriginalGUID,
+		ContentType:      episode.ContentType,
+		DroppedAt:        episode.DroppedAt,
+		Speed:            episode.Speed,
+		Offset:           episode.Offset,
+		Encoding:         episode.Encoding,
+		Size:             episode.Size,
+		Skipped:          episode.Skipped,
+		TranscriptURL:    episode.TranscriptURL,
+	}
+}
+
+// sameFormat reports whether an existing episode was encoded to format. Episodes
+// without a recorded content type predate configurable formats and are MP3.
+func sameFormat(episode podcast.ExistingEpisode, format audio.Format) bool {
+	contentType := episode.ContentType
+	if contentType == "" {
+		contentType = audio.FormatMP3.ContentType
+	}
+	return contentType == format.ContentType
+}
@@ -0,0 +1,80 @@
+package processor
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"cobblepod/internal/queue"
+	"cobblepod/internal/storage"
+	"cobblepod/internal/tracing"
+	"cobblepod/internal/transcribe"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// SetTranscriber replaces the transcriber used for episode transcripts; nil
+// disables them
+func (p *Processor) SetTranscriber(transcriber transcribe.Transcriber) {
+	p.transcriber = transcriber
+}
+
+// transcriberFor returns the job's transcriber, or nil when the user hasn't
+// asked for transcripts or the deployment can't transcribe
+func (p *Processor) transcriberFor(settings *queue.UserSettings) transcribe.Transcriber {
+	if !settings.Transcripts {
+		return nil
+	}
+	return p.transcriber
+}
+
+// transcribeWorker transcribes episodes between encoding and upload. Episodes
+// that weren't encoded by this job (reused or copied through) pass untouched.
+// Transcripts are best effort: an episode whose transcription fails is
+// published without one.
+func transcribeWorker(ctx context.Context, transcriber transcribe.Transcriber, tasks <-chan Task, results chan<- Task) {
+	for task := range tasks {
+		if task.Err != nil || task.Result.DownloadURL != "" || task.Result.TempFile == "" || ctx.Err() != nil {
+			results <- task
+			continue
+		}
+
+		slog.InfoContext(ctx, "Transcribing episode", "title", task.Result.Title)
+		_, span := tracing.Start(ctx, "transcribe", attribute.String("item.id", task.Item.ID))
+		path, err := transcriber.Transcribe(ctx, task.Result.TempFile)
+		tracing.End(span, err)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to transcribe episode, publishing it without a transcript", "title", task.Result.Title, "error", err)
+		} else {
+			task.TranscriptPath = path
+		}
+		results <- task
+	}
+}
+
+// uploadTranscript uploads the task's transcript, if it has one, and returns
+// its download URL; "" means the episode is published without one. The
+// transcript's temp file is removed either way.
+func uploadTranscript(ctx context.Context, storageService storage.Storage, task Task) string {
+	if task.TranscriptPath == "" {
+		return ""
+	}
+	defer removeTranscript(ctx, task)
+
+	fileID, err := storageService.UploadFile(task.TranscriptPath, task.Result.Title+"."+transcribe.Extension, transcribe.ContentType)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to upload transcript, publishing the episode without one", "title", task.Result.Title, "error", err)
+		return ""
+	}
+	return storageService.GenerateDownloadURL(fileID)
+}
+
+// removeTranscript removes the task's transcript temp file, if it has one
+func removeTranscript(ctx context.Context, task Task) {
+	if task.TranscriptPath == "" {
+		return
+	}
+	if err := os.Remove(task.TranscriptPath); err != nil {
+		slog.WarnContext(ctx, "Failed to remove temp file", "path", task.TranscriptPath, "error", err)
+	}
+}
@@ -0,0 +1,168 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// QueueStats counts jobs by state across all users
+type QueueStats struct {
+	Waiting      int64 `json:"waiting"`
+	Priority     int64 `json:"priority"`
+	Scheduled    int64 `json:"scheduled"`
+	Running      int64 `json:"running"`
+	RunningUsers int64 `json:"running_users"`
+	Succeeded    int64 `json:"succeeded"`
+	Failed       int64 `json:"failed"`
+	DeadLettered int64 `json:"dead_lettered"`
+}
+
+// UserLock describes what is holding a user's jobs back: their running slot
+// count and their feed lock. Stuck is set when the slot count no longer matches
+// the jobs actually running, or the feed is locked with no job running to hold it.
+type UserLock struct {
+	UserID         string `json:"user_id"`
+	RunningSlots   int64  `json:"running_slots"`
+	RunningJobs    int64  `json:"running_jobs"`
+	FeedLocked     bool   `json:"feed_locked"`
+	FeedLockExpiry int64  `json:"feed_lock_expiry,omitempty"` // Seconds until the feed lock expires
+	Stuck          bool   `json:"stuck"`
+}
+
+// GetQueueStats returns global job counts for every queue and set
+func (q *Queue) GetQueueStats(ctx context.Context) (*QueueStats, error) {
+	if q.client == nil {
+		return nil, fmt.Errorf("queue is not connected")
+	}
+
+	pipe := q.client.Pipeline()
+	waiting := pipe.ZCard(ctx, q.config.WaitingQueue)
+	var priority *redis.IntCmd
+	if q.config.PriorityQueue != "" {
+		priority = pipe.ZCard(ctx, q.config.PriorityQueue)
+	}
+	scheduled := pipe.ZCard(ctx, q.config.ScheduledSet)
+	running := pipe.SCard(ctx, q.config.RunningQueue)
+	runningUsers := pipe.HLen(ctx, q.config.RunningUsersKey)
+	succeeded := pipe.SCard(ctx, q.config.SuccessSet)
+	failed := pipe.SCard(ctx, q.config.FailedSet)
+	deadLettered := pipe.SCard(ctx, q.config.DeadLetterSet)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get queue stats: %w", err)
+	}
+
+	stats := &QueueStats{
+		Waiting:      waiting.Val(),
+		Scheduled:    scheduled.Val(),
+		Running:      running.Val(),
+		RunningUsers: runningUsers.Val(),
+		Succeeded:    succeeded.Val(),
+		Failed:       failed.Val(),
+		DeadLettered: deadLettered.Val(),
+	}
+	if priority != nil {
+		stats.Priority = priority.Val()
+	}
+	return stats, nil
+}
+
+// GetAllRunningJobs returns the running jobs of every user
+func (q *Queue) GetAllRunningJobs(ctx context.Context) ([]*Job, error) {
+	if q.client == nil {
+		return nil, fmt.Errorf("queue is not connected")
+	}
+
+	jobIDs, err := q.client.SMembers(ctx, q.config.RunningQueue).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get running jobs: %w", err)
+	}
+	return q.getJobsFromIDs(ctx, jobIDs)
+}
+
+// GetUserLocks returns every user holding a running slot or a feed lock, stuck
+// ones first. Feed locks are looked up for users with running slots and feed
+// owners, so no keyspace scan is needed.
+func (q *Queue) GetUserLocks(ctx context.Context) ([]*UserLock, error) {
+	if q.client == nil {
+		return nil, fmt.Errorf("queue is not connected")
+	}
+
+	slots, err := q.client.HGetAll(ctx, q.config.RunningUsersKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get running users: %w", err)
+	}
+	owners, err := q.client.SMembers(ctx, q.feedOwnersKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get feed owners: %w", err)
+	}
+
+	userIDs := make([]string, 0, len(slots)+len(owners))
+	for userID := range slots {
+		userIDs = append(userIDs, userID)
+	}
+	for _, userID := range owners {
+		if _, ok := slots[userID]; !ok {
+			userIDs = append(userIDs, userID)
+		}
+	}
+
+	pipe := q.client.Pipeline()
+	runningCmds := make([]*redis.IntCmd, len(userIDs))
+	ttlCmds := make([]*redis.DurationCmd, len(userIDs))
+	for i, userID := range userIDs {
+		runningCmds[i] = pipe.SCard(ctx, q.userRunningKey(userID))
+		ttlCmds[i] = pipe.PTTL(ctx, q.feedLockKey(userID))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get user locks: %w", err)
+	}
+
+	locks := make([]*UserLock, 0, len(userIDs))
+	for i, userID := range userIDs {
+		lock := &UserLock{UserID: userID, RunningJobs: runningCmds[i].Val()}
+		lock.RunningSlots, _ = strconv.ParseInt(slots[userID], 10, 64)
+		// PTTL is negative when the lock isn't held
+		if ttl := ttlCmds[i].Val(); ttl > 0 {
+			lock.FeedLocked = true
+			lock.FeedLockExpiry = int64(ttl.Round(time.Second) / time.Second)
+		}
+		if lock.RunningSlots == 0 && !lock.FeedLocked {
+			continue
+		}
+		lock.Stuck = lock.RunningSlots != lock.RunningJobs || (lock.FeedLocked && lock.RunningJobs == 0)
+		locks = append(locks, lock)
+	}
+
+	sort.Slice(locks, func(i, j int) bool {
+		if locks[i].Stuck != locks[j].Stuck {
+			return locks[i].Stuck
+		}
+		return locks[i].UserID < locks[j].UserID
+	})
+	return locks, nil
+}
+
+// ReleaseUserLock force-releases a user's feed lock and resets their running
+// slot count to the jobs actually running. Jobs still running are unaffected.
+func (q *Queue) ReleaseUserLock(ctx context.Context, userID string) error {
+	if userID == "" {
+		return ErrUserIDRequired
+	}
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+
+	if err := q.client.Del(ctx, q.feedLockKey(userID)).Err(); err != nil {
+		return fmt.Errorf("failed to release feed lock: %w", err)
+	}
+	keys := []string{q.config.RunningUsersKey, q.userRunningKey(userID)}
+	if err := syncUserSlots.Run(ctx, q.client, keys, userID).Err(); err != nil {
+		return fmt.Errorf("failed to sync running slots: %w", err)
+	}
+	return nil
+}
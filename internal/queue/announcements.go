@@ -0,0 +1,130 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// ErrInvalidAnnouncement is returned when an announcement is missing required fields
+var ErrInvalidAnnouncement = errors.New("invalid announcement")
+
+// AnnouncementSeverity controls how prominently an announcement is shown
+type AnnouncementSeverity string
+
+const (
+	SeverityInfo     AnnouncementSeverity = "info"
+	SeverityWarning  AnnouncementSeverity = "warning"
+	SeverityCritical AnnouncementSeverity = "critical"
+)
+
+// Announcement is a deployment-wide message shown to every user until it expires
+type Announcement struct {
+	ID        string               `json:"id"`
+	Message   string               `json:"message"`
+	Severity  AnnouncementSeverity `json:"severity"`
+	CreatedAt time.Time            `json:"created_at"`
+	ExpiresAt time.Time            `json:"expires_at"`
+}
+
+// Validate checks that the announcement can be stored
+func (a *Announcement) Validate() error {
+	if a.Message == "" {
+		return fmt.Errorf("%w: message is required", ErrInvalidAnnouncement)
+	}
+	switch a.Severity {
+	case SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		return fmt.Errorf("%w: unknown severity %q", ErrInvalidAnnouncement, a.Severity)
+	}
+	if a.ExpiresAt.IsZero() {
+		return fmt.Errorf("%w: expires_at is required", ErrInvalidAnnouncement)
+	}
+	return nil
+}
+
+// announcementsKey returns the Redis hash key for announcements (ID -> JSON)
+func (q *Queue) announcementsKey() string {
+	return fmt.Sprintf("%s:announcements", q.config.KeyPrefix)
+}
+
+// GetAnnouncements returns unexpired announcements, oldest first. Expired
+// announcements are removed as they are found.
+func (q *Queue) GetAnnouncements(ctx context.Context) ([]*Announcement, error) {
+	if q.client == nil {
+		return nil, fmt.Errorf("queue is not connected")
+	}
+
+	entries, err := q.client.HGetAll(ctx, q.announcementsKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get announcements: %w", err)
+	}
+
+	now := time.Now()
+	announcements := make([]*Announcement, 0, len(entries))
+	var expired []string
+	for id, data := range entries {
+		var announcement Announcement
+		if err := json.Unmarshal([]byte(data), &announcement); err != nil {
+			slog.ErrorContext(ctx, "Failed to unmarshal announcement", "error", err, "id", id)
+			continue
+		}
+		if !announcement.ExpiresAt.After(now) {
+			expired = append(expired, id)
+			continue
+		}
+		announcements = append(announcements, &announcement)
+	}
+
+	if len(expired) > 0 {
+		if err := q.client.HDel(ctx, q.announcementsKey(), expired...).Err(); err != nil {
+			slog.WarnContext(ctx, "Failed to remove expired announcements", "error", err)
+		}
+	}
+
+	sort.Slice(announcements, func(i, j int) bool {
+		return announcements[i].CreatedAt.Before(announcements[j].CreatedAt)
+	})
+
+	return announcements, nil
+}
+
+// SaveAnnouncement validates and stores an announcement, replacing any with the same ID
+func (q *Queue) SaveAnnouncement(ctx context.Context, announcement *Announcement) error {
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+	if announcement.ID == "" {
+		return fmt.Errorf("%w: id is required", ErrInvalidAnnouncement)
+	}
+	if err := announcement.Validate(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(announcement)
+	if err != nil {
+		return fmt.Errorf("failed to marshal announcement: %w", err)
+	}
+
+	if err := q.client.HSet(ctx, q.announcementsKey(), announcement.ID, data).Err(); err != nil {
+		return fmt.Errorf("failed to save announcement: %w", err)
+	}
+	return nil
+}
+
+// DeleteAnnouncement removes an announcement. It reports whether one was removed.
+func (q *Queue) DeleteAnnouncement(ctx context.Context, id string) (bool, error) {
+	if q.client == nil {
+		return false, fmt.Errorf("queue is not connected")
+	}
+
+	removed, err := q.client.HDel(ctx, q.announcementsKey(), id).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete announcement: %w", err)
+	}
+	return removed > 0, nil
+}
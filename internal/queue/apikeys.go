@@ -0,0 +1,199 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// APIKeyPrefix starts every API key, telling them apart from JWTs
+	APIKeyPrefix = "cpk_"
+	// MaxAPIKeysPerUser caps how many keys a user may hold at once
+	MaxAPIKeysPerUser = 10
+	// MaxAPIKeyNameLength bounds the label given to a key
+	MaxAPIKeyNameLength = 100
+	// apiKeyHintLength is how much of a key is kept to help users recognize it
+	apiKeyHintLength = len(APIKeyPrefix) + 6
+)
+
+var (
+	// ErrTooManyAPIKeys is returned when a user already holds MaxAPIKeysPerUser keys
+	ErrTooManyAPIKeys = errors.New("too many API keys")
+	// ErrInvalidAPIKeyName is returned when a key's name is empty or too long
+	ErrInvalidAPIKeyName = errors.New("invalid API key name")
+)
+
+// APIKey describes a key without its secret, which is only shown once at creation
+type APIKey struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Hint      string    `json:"hint"` // The first characters of the key
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// storedAPIKey is an APIKey as kept in the user's key hash
+type storedAPIKey struct {
+	APIKey
+	Hash string `json:"hash"`
+}
+
+// userAPIKeysKey returns the Redis hash of a user's keys (key ID -> JSON)
+func (q *Queue) userAPIKeysKey(userID string) string {
+	return fmt.Sprintf("%s:user:%s:api-keys", q.config.KeyPrefix, userID)
+}
+
+// apiKeyLookupKey returns the Redis key mapping a key's hash to its user
+func (q *Queue) apiKeyLookupKey(hash string) string {
+	return fmt.Sprintf("%s:api-key:%s", q.config.KeyPrefix, hash)
+}
+
+// hashAPIKey returns the hex SHA-256 of a key; only hashes are stored
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// addAPIKey stores a key and its lookup unless the user already holds the
+// maximum number of keys, returning 0 in that case
+var addAPIKey = redis.NewScript(`
+if redis.call("HLEN", KEYS[1]) >= tonumber(ARGV[4]) then
+	return 0
+end
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+redis.call("SET", KEYS[2], ARGV[3])
+return 1
+`)
+
+// CreateAPIKey issues a new key for the user and returns it with its secret
+func (q *Queue) CreateAPIKey(ctx context.Context, userID string, name string) (*APIKey, string, error) {
+	if userID == "" {
+		return nil, "", ErrUserIDRequired
+	}
+	if q.client == nil {
+		return nil, "", fmt.Errorf("queue is not connected")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > MaxAPIKeyNameLength {
+		return nil, "", fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidAPIKeyName, MaxAPIKeyNameLength)
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	secret := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(random)
+
+	key := storedAPIKey{
+		APIKey: APIKey{
+			ID:        uuid.New().String(),
+			Name:      name,
+			Hint:      secret[:apiKeyHintLength],
+			CreatedAt: time.Now(),
+		},
+		Hash: hashAPIKey(secret),
+	}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal API key: %w", err)
+	}
+
+	// Count and add in one step so concurrent requests can't exceed the cap
+	keys := []string{q.userAPIKeysKey(userID), q.apiKeyLookupKey(key.Hash)}
+	added, err := addAPIKey.Run(ctx, q.client, keys, key.ID, data, userID, MaxAPIKeysPerUser).Int()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to save API key: %w", err)
+	}
+	if added == 0 {
+		return nil, "", fmt.Errorf("%w: at most %d keys are allowed", ErrTooManyAPIKeys, MaxAPIKeysPerUser)
+	}
+	return &key.APIKey, secret, nil
+}
+
+// ListAPIKeys returns the user's keys, oldest first
+func (q *Queue) ListAPIKeys(ctx context.Context, userID string) ([]*APIKey, error) {
+	if userID == "" {
+		return nil, ErrUserIDRequired
+	}
+	if q.client == nil {
+		return nil, fmt.Errorf("queue is not connected")
+	}
+
+	entries, err := q.client.HGetAll(ctx, q.userAPIKeysKey(userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API keys: %w", err)
+	}
+
+	keys := make([]*APIKey, 0, len(entries))
+	for id, data := range entries {
+		var key storedAPIKey
+		if err := json.Unmarshal([]byte(data), &key); err != nil {
+			slog.ErrorContext(ctx, "Failed to unmarshal API key", "error", err, "id", id)
+			continue
+		}
+		keys = append(keys, &key.APIKey)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// RevokeAPIKey deletes one of the user's keys. It reports whether one was removed.
+func (q *Queue) RevokeAPIKey(ctx context.Context, userID string, keyID string) (bool, error) {
+	if userID == "" {
+		return false, ErrUserIDRequired
+	}
+	if q.client == nil {
+		return false, fmt.Errorf("queue is not connected")
+	}
+
+	data, err := q.client.HGet(ctx, q.userAPIKeysKey(userID), keyID).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get API key: %w", err)
+	}
+	var key storedAPIKey
+	if err := json.Unmarshal([]byte(data), &key); err != nil {
+		return false, fmt.Errorf("failed to unmarshal API key: %w", err)
+	}
+
+	pipe := q.client.TxPipeline()
+	pipe.Del(ctx, q.apiKeyLookupKey(key.Hash))
+	pipe.HDel(ctx, q.userAPIKeysKey(userID), keyID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	return true, nil
+}
+
+// LookupAPIKey returns the user a key belongs to, or an empty string if the key
+// is unknown or revoked
+func (q *Queue) LookupAPIKey(ctx context.Context, secret string) (string, error) {
+	if q.client == nil {
+		return "", fmt.Errorf("queue is not connected")
+	}
+
+	userID, err := q.client.Get(ctx, q.apiKeyLookupKey(hashAPIKey(secret))).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up API key: %w", err)
+	}
+	return userID, nil
+}
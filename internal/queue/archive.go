@@ -0,0 +1,115 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ArchivedEpisode records an episode whose file was moved to the archive folder
+// when it dropped out of the feed, so it can be restored until it is purged
+type ArchivedEpisode struct {
+	FileID     string    `json:"file_id"`
+	Title      string    `json:"title"`
+	ArchivedAt time.Time `json:"archived_at"`
+	// Episode is the feed episode as the processor recorded it, so restoring
+	// republishes it unchanged
+	Episode json.RawMessage `json:"episode" swaggertype:"object"`
+}
+
+// archiveKey returns the Redis hash of a user's archived episodes by file ID
+func (q *Queue) archiveKey(userID string) string {
+	return fmt.Sprintf("%s:user:%s:archive", q.config.KeyPrefix, userID)
+}
+
+// ArchiveEpisode records an archived episode, replacing any record of its file
+func (q *Queue) ArchiveEpisode(ctx context.Context, userID string, episode *ArchivedEpisode) error {
+	if userID == "" {
+		return ErrUserIDRequired
+	}
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+	if episode == nil || episode.FileID == "" {
+		return fmt.Errorf("archived episode needs a file ID")
+	}
+
+	data, err := json.Marshal(episode)
+	if err != nil {
+		return fmt.Errorf("failed to marshal archived episode: %w", err)
+	}
+	if err := q.client.HSet(ctx, q.archiveKey(userID), episode.FileID, data).Err(); err != nil {
+		return fmt.Errorf("failed to archive episode: %w", err)
+	}
+	return nil
+}
+
+// GetArchivedEpisode returns the archived episode of a file, or nil if the
+// file isn't archived
+func (q *Queue) GetArchivedEpisode(ctx context.Context, userID string, fileID string) (*ArchivedEpisode, error) {
+	if userID == "" {
+		return nil, ErrUserIDRequired
+	}
+	if q.client == nil {
+		return nil, fmt.Errorf("queue is not connected")
+	}
+
+	data, err := q.client.HGet(ctx, q.archiveKey(userID), fileID).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archived episode: %w", err)
+	}
+	var episode ArchivedEpisode
+	if err := json.Unmarshal([]byte(data), &episode); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal archived episode: %w", err)
+	}
+	return &episode, nil
+}
+
+// ListArchivedEpisodes returns the user's archived episodes, most recently
+// archived first
+func (q *Queue) ListArchivedEpisodes(ctx context.Context, userID string) ([]*ArchivedEpisode, error) {
+	if userID == "" {
+		return nil, ErrUserIDRequired
+	}
+	if q.client == nil {
+		return nil, fmt.Errorf("queue is not connected")
+	}
+
+	records, err := q.client.HVals(ctx, q.archiveKey(userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archived episodes: %w", err)
+	}
+	episodes := make([]*ArchivedEpisode, 0, len(records))
+	for _, data := range records {
+		var episode ArchivedEpisode
+		if err := json.Unmarshal([]byte(data), &episode); err != nil {
+			continue
+		}
+		episodes = append(episodes, &episode)
+	}
+	sort.Slice(episodes, func(i, j int) bool { return episodes[i].ArchivedAt.After(episodes[j].ArchivedAt) })
+	return episodes, nil
+}
+
+// RemoveArchivedEpisode forgets an archived episode once its file was restored
+// or deleted
+func (q *Queue) RemoveArchivedEpisode(ctx context.Context, userID string, fileID string) error {
+	if userID == "" {
+		return ErrUserIDRequired
+	}
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+
+	if err := q.client.HDel(ctx, q.archiveKey(userID), fileID).Err(); err != nil {
+		return fmt.Errorf("failed to remove archived episode: %w", err)
+	}
+	return nil
+}
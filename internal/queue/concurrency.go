@@ -0,0 +1,114 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// UserBusyDelay is how long a job waits before trying again for a running slot
+// when all of its user's slots are taken
+const UserBusyDelay = 10 * time.Second
+
+// startUserJob takes a running slot for a job if the user has one free and moves
+// the job from the user's waiting set to their running set. Starting a job that
+// already holds a slot is a no-op, so a retried StartJob never takes two.
+var startUserJob = redis.NewScript(`
+if redis.call("SISMEMBER", KEYS[3], ARGV[2]) == 1 then
+	return 1
+end
+local running = tonumber(redis.call("HGET", KEYS[1], ARGV[1]) or "0")
+if running >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call("HINCRBY", KEYS[1], ARGV[1], 1)
+redis.call("SREM", KEYS[2], ARGV[2])
+redis.call("SADD", KEYS[3], ARGV[2])
+redis.call("SADD", KEYS[4], ARGV[2])
+redis.call("HSET", KEYS[5], "status", ARGV[4])
+return 1
+`)
+
+// releaseUserSlot gives a job's running slot back, but only if the job still
+// holds one, so completing or failing a job twice never frees someone else's slot
+var releaseUserSlot = redis.NewScript(`
+if redis.call("SREM", KEYS[1], ARGV[2]) == 0 then
+	return 0
+end
+if redis.call("HINCRBY", KEYS[2], ARGV[1], -1) <= 0 then
+	redis.call("HDEL", KEYS[2], ARGV[1])
+end
+return 1
+`)
+
+// syncUserSlots resets a user's running count to the size of their running set
+var syncUserSlots = redis.NewScript(`
+local running = redis.call("SCARD", KEYS[2])
+if running == 0 then
+	return redis.call("HDEL", KEYS[1], ARGV[1])
+end
+redis.call("HSET", KEYS[1], ARGV[1], running)
+return 0
+`)
+
+// DeferJob hands a dequeued job back because it couldn't start, usually because
+// its user has no free running slot. The job stays queued and is retried after
+// delay; the deferral does not count as an attempt.
+func (q *Queue) DeferJob(ctx context.Context, job *Job, delay time.Duration) error {
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+
+	runAt := time.Now().Add(delay)
+	pipe := q.client.Pipeline()
+	pipe.SRem(ctx, q.config.RunningQueue, job.ID)
+	pipe.Del(ctx, q.heartbeatKey(job.ID))
+	pipe.HIncrBy(ctx, q.jobKey(job.ID), "attempts", -1)
+	pipe.ZAdd(ctx, q.config.ScheduledSet, redis.Z{Score: float64(runAt.Unix()), Member: job.ID})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to defer job: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Job couldn't start, deferring it", "job_id", job.ID, "user_id", job.UserID, "run_at", runAt)
+	return nil
+}
+
+// ReleaseJob hands a running job back to its waiting queue for a worker that is
+// shutting down mid-job. The attempt isn't counted against the job, and items
+// already uploaded keep their storage keys, so the next worker picks up where
+// this one stopped.
+func (q *Queue) ReleaseJob(ctx context.Context, job *Job) error {
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+
+	// requeueStalledJob only takes jobs without a live heartbeat
+	if err := q.client.Del(ctx, q.heartbeatKey(job.ID)).Err(); err != nil {
+		return fmt.Errorf("failed to clear heartbeat: %w", err)
+	}
+	keys := []string{
+		q.config.RunningQueue,
+		q.heartbeatKey(job.ID),
+		q.config.RunningUsersKey,
+		q.jobKey(job.ID),
+		q.waitingQueueFor(job.Priority),
+		q.userRunningKey(job.UserID),
+		q.userWaitingKey(job.UserID),
+	}
+	// The attempt is uncounted along with the move, so a job recovered by
+	// another worker's stall check in between keeps it
+	moved, err := requeueStalledJob.Run(ctx, q.client, keys, job.ID, job.UserID, JobStatusQueued, -1).Int()
+	if err != nil {
+		return fmt.Errorf("failed to release job %s: %w", job.ID, err)
+	}
+	if moved == 0 {
+		// Already recovered by another worker's stall check
+		return nil
+	}
+
+	slog.InfoContext(ctx, "Released job back to the queue", "job_id", job.ID, "user_id", job.UserID)
+	return nil
+}
@@ -0,0 +1,74 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrJobNotDeadLettered is returned when requeueing a job that isn't in the dead-letter set
+var ErrJobNotDeadLettered = errors.New("job is not in the dead-letter set")
+
+// DeadLetterJob fails a job for a system-side reason (lock acquisition, panic)
+// and keeps it in the dead-letter set so it can be re-driven with RequeueFailed
+// once the root cause is fixed
+func (q *Queue) DeadLetterJob(ctx context.Context, job *Job, reason string) error {
+	return q.failJob(ctx, job, reason, true)
+}
+
+// RequeueFailed moves a dead-lettered job back into the waiting queue. Items keep
+// their state so already uploaded episodes are skipped on the next attempt.
+func (q *Queue) RequeueFailed(ctx context.Context, jobID string) (*Job, error) {
+	if q.client == nil {
+		return nil, fmt.Errorf("queue is not connected")
+	}
+
+	isDead, err := q.client.SIsMember(ctx, q.config.DeadLetterSet, jobID).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check dead-letter set: %w", err)
+	}
+	if !isDead {
+		return nil, ErrJobNotDeadLettered
+	}
+
+	job, err := q.GetJob(ctx, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	if job == nil {
+		// The job data expired; drop the dangling dead-letter entry
+		q.client.SRem(ctx, q.config.DeadLetterSet, jobID)
+		return nil, ErrJobNotDeadLettered
+	}
+
+	job.Status = JobStatusQueued
+	job.FailReason = ""
+	job.Attempts = 0
+
+	pipe := q.client.Pipeline()
+	pipe.SRem(ctx, q.config.DeadLetterSet, jobID)
+	pipe.SRem(ctx, q.config.FailedSet, jobID)
+	pipe.HSet(ctx, q.jobKey(jobID), map[string]interface{}{
+		"status":      job.Status,
+		"fail_reason": "",
+		"attempts":    0,
+	})
+	pipe.Persist(ctx, q.jobKey(jobID))
+	pipe.Persist(ctx, q.jobItemsKey(jobID))
+	pipe.ZRem(ctx, q.config.CleanupSet, fmt.Sprintf("%s:%s", job.UserID, jobID))
+	if job.UserID != "" {
+		pipe.SMove(ctx, q.userFailedKey(job.UserID), q.userWaitingKey(job.UserID), jobID)
+	}
+	pipe.ZAdd(ctx, q.waitingQueueFor(job.Priority), redis.Z{Score: float64(job.FairScore), Member: jobID})
+	q.publishEvent(ctx, pipe, job.UserID, Event{Type: EventJobCreated, JobID: jobID, Status: job.Status})
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to requeue job: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Dead-lettered job requeued", "job_id", jobID, "user_id", job.UserID)
+	return job, nil
+}
@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// downloadStatsRetention keeps an episode's download counts this long after
+	// its last download, so counts of deleted episodes eventually go away
+	downloadStatsRetention = 90 * 24 * time.Hour
+	// maxUserAgentLength bounds the client names counted per episode
+	maxUserAgentLength = 64
+)
+
+// EpisodeDownloads counts the downloads of an episode through its feed link
+type EpisodeDownloads struct {
+	Total int64 `json:"total"`
+	// UserAgents counts downloads by the product name of the client, e.g. "AntennaPod"
+	UserAgents map[string]int64 `json:"user_agents"`
+}
+
+// episodeDownloadsKey returns the Redis hash counting downloads of a stored episode
+func (q *Queue) episodeDownloadsKey(fileID string) string {
+	return fmt.Sprintf("%s:episode:%s:downloads", q.config.KeyPrefix, fileID)
+}
+
+// userAgentProduct reduces a User-Agent header to the client's product name,
+// so counts group by app rather than by app version and platform
+func userAgentProduct(userAgent string) string {
+	product, _, _ := strings.Cut(strings.TrimSpace(userAgent), "/")
+	product, _, _ = strings.Cut(product, " ")
+	if product == "" {
+		return "unknown"
+	}
+	if len(product) > maxUserAgentLength {
+		product = product[:maxUserAgentLength]
+	}
+	return product
+}
+
+// RecordEpisodeDownload counts a download of a stored episode by a client
+func (q *Queue) RecordEpisodeDownload(ctx context.Context, fileID string, userAgent string) error {
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+	key := q.episodeDownloadsKey(fileID)
+	pipe := q.client.Pipeline()
+	pipe.HIncrBy(ctx, key, "total", 1)
+	pipe.HIncrBy(ctx, key, "ua:"+userAgentProduct(userAgent), 1)
+	pipe.Expire(ctx, key, downloadStatsRetention)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record episode download: %w", err)
+	}
+	return nil
+}
+
+// GetEpisodeDownloads returns the download counts of the given episodes.
+// Episodes that were never downloaded have zero counts.
+func (q *Queue) GetEpisodeDownloads(ctx context.Context, fileIDs []string) (map[string]*EpisodeDownloads, error) {
+	if q.client == nil {
+		return nil, fmt.Errorf("queue is not connected")
+	}
+
+	pipe := q.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(fileIDs))
+	for i, fileID := range fileIDs {
+		cmds[i] = pipe.HGetAll(ctx, q.episodeDownloadsKey(fileID))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get episode downloads: %w", err)
+	}
+
+	downloads := make(map[string]*EpisodeDownloads, len(fileIDs))
+	for i, fileID := range fileIDs {
+		counts := &EpisodeDownloads{UserAgents: map[string]int64{}}
+		for field, value := range cmds[i].Val() {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			if agent, ok := strings.CutPrefix(field, "ua:"); ok {
+				counts.UserAgents[agent] = n
+			} else if field == "total" {
+				counts.Total = n
+			}
+		}
+		downloads[fileID] = counts
+	}
+	return downloads, nil
+}
@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// EncodeRater reports how long recent runs took to process a minute of source
+// audio and how long a run takes on average, see state.EncodeStats. Both are
+// zero before any run has been recorded.
+type EncodeRater interface {
+	EncodeRates(ctx context.Context) (perMinute, perRun time.Duration, err error)
+}
+
+// SetEncodeRater sets where Enqueue and SetJobItems get the rates they estimate
+// jobs' completion from; without one jobs get no estimate
+func (q *Queue) SetEncodeRater(rater EncodeRater) {
+	q.rater = rater
+}
+
+// queuePosition is what a job waits behind when it's enqueued
+type queuePosition struct {
+	waiting  int64 // jobs waiting, across users
+	running  int64 // jobs running, one per busy worker
+	userJobs int64 // the user's own waiting and running jobs
+}
+
+// rounds returns how many average runs pass before a job behind pos starts:
+// the waiting jobs shared among the busy workers, or the rounds the user's own
+// jobs take maxPerUser at a time, whichever is longer
+func (pos queuePosition) rounds(maxPerUser int) float64 {
+	rounds := float64(pos.waiting) / float64(max(pos.running, 1))
+	if maxPerUser > 0 {
+		rounds = max(rounds, float64(pos.userJobs/int64(maxPerUser)))
+	}
+	return rounds
+}
+
+// estimateCompletion returns when a job should finish if it starts after
+// rounds of average runs: its audio at the recorded rate, or another average
+// run while its items aren't known. It returns zero without recorded rates.
+func estimateCompletion(now time.Time, rounds float64, audio, perMinute, perRun time.Duration) time.Time {
+	if perMinute <= 0 || perRun <= 0 {
+		return time.Time{}
+	}
+	wait := time.Duration(rounds * float64(perRun))
+	if audio > 0 {
+		wait += time.Duration(float64(audio) / float64(time.Minute) * float64(perMinute))
+	} else {
+		wait += perRun
+	}
+	return now.Add(wait).Truncate(time.Second)
+}
+
+// TotalAudio returns how much source audio items still need encoded, from
+// their offsets on. Reused items and those a previous attempt finished are
+// left out, as they take next to no time. Completion estimates and the rates
+// behind them measure jobs by it.
+func TotalAudio(items []JobItem) time.Duration {
+	var audio time.Duration
+	for _, item := range items {
+		if item.Status == StatusSkipped || item.Status == StatusCompleted {
+			continue
+		}
+		audio += item.Duration - item.Offset
+	}
+	return audio
+}
+
+// queuePosition returns what a job of the user's enqueued now waits behind
+func (q *Queue) queuePosition(ctx context.Context, userID string) (queuePosition, error) {
+	var pos queuePosition
+	waiting, err := q.QueueLength(ctx)
+	if err != nil {
+		return pos, err
+	}
+	pipe := q.client.Pipeline()
+	running := pipe.SCard(ctx, q.config.RunningQueue)
+	userWaiting := pipe.SCard(ctx, q.userWaitingKey(userID))
+	userRunning := pipe.SCard(ctx, q.userRunningKey(userID))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return pos, fmt.Errorf("failed to get running jobs: %w", err)
+	}
+	pos.waiting, pos.running = waiting, running.Val()
+	pos.userJobs = userWaiting.Val() + userRunning.Val()
+	return pos, nil
+}
+
+// estimate returns when a job should finish behind pos, or zero when it can't
+// tell. Failures only cost the estimate.
+func (q *Queue) estimate(ctx context.Context, pos queuePosition, items []JobItem) time.Time {
+	if q.rater == nil {
+		return time.Time{}
+	}
+	perMinute, perRun, err := q.rater.EncodeRates(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to get encode rates", "error", err)
+		return time.Time{}
+	}
+	return estimateCompletion(time.Now(), pos.rounds(q.worker.MaxJobsPerUser), TotalAudio(items), perMinute, perRun)
+}
@@ -0,0 +1,121 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// EventType identifies the kind of user-scoped event
+type EventType string
+
+const (
+	EventJobCreated  EventType = "job.created"
+	EventJobStarted  EventType = "job.started"
+	EventJobFinished EventType = "job.finished"
+	EventItemUpdated EventType = "item.updated"
+	EventFeedUpdated EventType = "feed.updated"
+)
+
+// Event is an aggregate, user-scoped notification published over Redis pub/sub
+type Event struct {
+	Type      EventType `json:"type"`
+	JobID     string    `json:"job_id,omitempty"`
+	ItemID    string    `json:"item_id,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Message   string    `json:"message,omitempty"` // Why an item or job failed
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// userEventsKey returns the Redis pub/sub channel for a user's events
+func (q *Queue) userEventsKey(userID string) string {
+	return fmt.Sprintf("%s:user:%s:events", q.config.KeyPrefix, userID)
+}
+
+// publishEvent queues an event publish on the given pipeline, and records job
+// events on the job's timeline
+func (q *Queue) publishEvent(ctx context.Context, pipe redis.Pipeliner, userID string, event Event) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to marshal event", "error", err, "type", event.Type)
+		return
+	}
+	if event.JobID != "" {
+		q.recordEvent(ctx, pipe, event.JobID, payload)
+	}
+	if userID != "" {
+		pipe.Publish(ctx, q.userEventsKey(userID), payload)
+	}
+}
+
+// PublishEvent publishes an event to the user's event channel
+func (q *Queue) PublishEvent(ctx context.Context, userID string, event Event) error {
+	if userID == "" {
+		return ErrUserIDRequired
+	}
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+
+	pipe := q.client.Pipeline()
+	q.publishEvent(ctx, pipe, userID, event)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	return nil
+}
+
+// SubscribeEvents subscribes to the user's event channel. The returned channel is
+// closed when ctx is cancelled or the subscription ends.
+func (q *Queue) SubscribeEvents(ctx context.Context, userID string) (<-chan Event, error) {
+	if userID == "" {
+		return nil, ErrUserIDRequired
+	}
+	if q.client == nil {
+		return nil, fmt.Errorf("queue is not connected")
+	}
+
+	sub := q.client.Subscribe(ctx, q.userEventsKey(userID))
+	// Wait for the subscription to be confirmed so no events are missed
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, fmt.Errorf("failed to subscribe to events: %w", err)
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer sub.Close()
+
+		messages := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				var event Event
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					slog.ErrorContext(ctx, "Failed to unmarshal event", "error", err)
+					continue
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
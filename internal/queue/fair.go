@@ -0,0 +1,163 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// FairShareUnit is the virtual time a user is charged for each job they enqueue
+// and each episode their jobs process, divided by their weight. Waiting jobs
+// are served in order of their virtual start time, so a user who just ran a
+// 300-episode backfill queues behind users who have had less of the workers.
+const FairShareUnit = time.Minute
+
+// ErrInvalidWeight is returned by SetUserWeight for weights that aren't positive
+var ErrInvalidWeight = errors.New("weight must be positive")
+
+// fairStart returns the virtual start time of a user's next job and advances
+// their virtual clock past it: the later of now and the end of their previous
+// jobs, so idle users start at now and busy ones queue behind their own work.
+var fairStart = redis.NewScript(`
+local weight = tonumber(redis.call("HGET", KEYS[2], ARGV[1]) or "1")
+local start = math.max(tonumber(ARGV[2]), tonumber(redis.call("HGET", KEYS[1], ARGV[1]) or "0"))
+redis.call("HSET", KEYS[1], ARGV[1], math.floor(start + tonumber(ARGV[3]) / weight))
+return math.floor(start)
+`)
+
+// fairRefund winds a user's virtual clock back to the start fairStart returned
+// (ARGV[2]), unless another job advanced it since
+var fairRefund = redis.NewScript(`
+local weight = tonumber(redis.call("HGET", KEYS[2], ARGV[1]) or "1")
+local charged = math.floor(tonumber(ARGV[2]) + tonumber(ARGV[3]) / weight)
+if tonumber(redis.call("HGET", KEYS[1], ARGV[1]) or "0") == charged then
+	redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+end
+return 1
+`)
+
+// fairCharge advances a user's virtual clock by the work their job did
+var fairCharge = redis.NewScript(`
+local weight = tonumber(redis.call("HGET", KEYS[2], ARGV[1]) or "1")
+local clock = math.max(tonumber(ARGV[2]), tonumber(redis.call("HGET", KEYS[1], ARGV[1]) or "0"))
+redis.call("HSET", KEYS[1], ARGV[1], math.floor(clock + tonumber(ARGV[3]) / weight))
+return 1
+`)
+
+// migrateWaitingList converts a waiting list left by an older release into a
+// sorted set, keeping its order ahead of every job enqueued since
+var migrateWaitingList = redis.NewScript(`
+if redis.call("TYPE", KEYS[1]).ok ~= "list" then
+	return 0
+end
+local ids = redis.call("LRANGE", KEYS[1], 0, -1)
+redis.call("DEL", KEYS[1])
+for i, id in ipairs(ids) do
+	redis.call("ZADD", KEYS[1], #ids - i, id)
+end
+return #ids
+`)
+
+// fairClockKey returns the Redis hash of each user's virtual clock, in Unix milliseconds
+func (q *Queue) fairClockKey() string {
+	return fmt.Sprintf("%s:fair:clock", q.config.KeyPrefix)
+}
+
+// fairWeightsKey returns the Redis hash of users' fair share weights
+func (q *Queue) fairWeightsKey() string {
+	return fmt.Sprintf("%s:fair:weights", q.config.KeyPrefix)
+}
+
+// fairScore charges a user one FairShareUnit for a new job and returns the
+// job's virtual start time. Its episodes are charged once it has run, see
+// chargeEpisodes, since most jobs only learn their episodes on the worker.
+func (q *Queue) fairScore(ctx context.Context, job *Job) (int64, error) {
+	keys := []string{q.fairClockKey(), q.fairWeightsKey()}
+	score, err := fairStart.Run(ctx, q.client, keys, job.UserID, time.Now().UnixMilli(), FairShareUnit.Milliseconds()).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get fair share score: %w", err)
+	}
+	return score, nil
+}
+
+// refundFairScore gives back the FairShareUnit fairScore charged for a job
+// that failed to be stored
+func (q *Queue) refundFairScore(ctx context.Context, job *Job, score int64) error {
+	keys := []string{q.fairClockKey(), q.fairWeightsKey()}
+	if err := fairRefund.Run(ctx, q.client, keys, job.UserID, score, FairShareUnit.Milliseconds()).Err(); err != nil {
+		return fmt.Errorf("failed to refund fair share score: %w", err)
+	}
+	return nil
+}
+
+// chargeEpisodes queues advancing a user's virtual clock by the episodes a
+// finished job processed
+func (q *Queue) chargeEpisodes(ctx context.Context, pipe redis.Pipeliner, userID string, episodes int) {
+	if episodes <= 0 {
+		return
+	}
+	cost := FairShareUnit * time.Duration(episodes)
+	keys := []string{q.fairClockKey(), q.fairWeightsKey()}
+	fairCharge.Eval(ctx, pipe, keys, userID, time.Now().UnixMilli(), cost.Milliseconds())
+}
+
+// SetUserWeight sets a user's share of the workers relative to other users.
+// A user with weight 2 is charged half as much per job as one with the default
+// weight of 1, so their waiting jobs come up twice as often.
+func (q *Queue) SetUserWeight(ctx context.Context, userID string, weight float64) error {
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+	if weight <= 0 {
+		return ErrInvalidWeight
+	}
+	if weight == 1 {
+		if err := q.client.HDel(ctx, q.fairWeightsKey(), userID).Err(); err != nil {
+			return fmt.Errorf("failed to reset user weight: %w", err)
+		}
+		return nil
+	}
+	if err := q.client.HSet(ctx, q.fairWeightsKey(), userID, strconv.FormatFloat(weight, 'f', -1, 64)).Err(); err != nil {
+		return fmt.Errorf("failed to set user weight: %w", err)
+	}
+	return nil
+}
+
+// GetUserWeight returns a user's fair share weight, 1 unless an admin changed it
+func (q *Queue) GetUserWeight(ctx context.Context, userID string) (float64, error) {
+	if q.client == nil {
+		return 0, fmt.Errorf("queue is not connected")
+	}
+	weight, err := q.client.HGet(ctx, q.fairWeightsKey(), userID).Float64()
+	if err == redis.Nil {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get user weight: %w", err)
+	}
+	return weight, nil
+}
+
+// MigrateWaitingQueues converts waiting lists left by a release that served
+// jobs first come, first served into the sorted sets Dequeue now reads. Jobs
+// already waiting keep their order and run before any enqueued after them.
+func (q *Queue) MigrateWaitingQueues(ctx context.Context) error {
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+	for _, key := range q.waitingQueues() {
+		moved, err := migrateWaitingList.Run(ctx, q.client, []string{key}).Int()
+		if err != nil {
+			return fmt.Errorf("failed to migrate waiting queue %s: %w", key, err)
+		}
+		if moved > 0 {
+			slog.InfoContext(ctx, "Migrated waiting queue to fair scheduling", "key", key, "jobs", moved)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// A feed slug is the secret in the URL podcast apps fetch a user's feed from.
+// Apps can't send credentials, so anyone holding the slug can read the feed;
+// RotateFeedSlug replaces a leaked one.
+
+// userFeedSlugKey returns the Redis key holding a user's feed slug
+func (q *Queue) userFeedSlugKey(userID string) string {
+	return fmt.Sprintf("%s:user:%s:feed-slug", q.config.KeyPrefix, userID)
+}
+
+// feedSlugLookupKey returns the Redis key mapping a feed slug to its user
+func (q *Queue) feedSlugLookupKey(slug string) string {
+	return fmt.Sprintf("%s:feed-slug:%s", q.config.KeyPrefix, slug)
+}
+
+// createFeedSlug stores ARGV[1] as the user's slug (KEYS[1]) and maps it back
+// to the user (KEYS[2]) unless they have a slug already. Returns the user's
+// slug, so of two racing requests the first one wins.
+var createFeedSlug = redis.NewScript(`
+local existing = redis.call("GET", KEYS[1])
+if existing then
+	return existing
+end
+redis.call("SET", KEYS[1], ARGV[1])
+redis.call("SET", KEYS[2], ARGV[2])
+return ARGV[1]
+`)
+
+// rotateFeedSlugAttempts bounds how often RotateFeedSlug retries when the
+// user's slug changes under it
+const rotateFeedSlugAttempts = 3
+
+// newFeedSlug returns a random, URL safe slug
+func newFeedSlug() (string, error) {
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate feed slug: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+// GetFeedSlug returns the user's feed slug, creating one the first time
+func (q *Queue) GetFeedSlug(ctx context.Context, userID string) (string, error) {
+	if userID == "" {
+		return "", ErrUserIDRequired
+	}
+	if q.client == nil {
+		return "", fmt.Errorf("queue is not connected")
+	}
+
+	slug, err := q.client.Get(ctx, q.userFeedSlugKey(userID)).Result()
+	if err == nil {
+		return slug, nil
+	}
+	if err != redis.Nil {
+		return "", fmt.Errorf("failed to get feed slug: %w", err)
+	}
+
+	slug, err = newFeedSlug()
+	if err != nil {
+		return "", err
+	}
+	keys := []string{q.userFeedSlugKey(userID), q.feedSlugLookupKey(slug)}
+	slug, err = createFeedSlug.Run(ctx, q.client, keys, slug, userID).Text()
+	if err != nil {
+		return "", fmt.Errorf("failed to save feed slug: %w", err)
+	}
+	return slug, nil
+}
+
+// RotateFeedSlug replaces the user's feed slug, so the old feed URL stops working
+func (q *Queue) RotateFeedSlug(ctx context.Context, userID string) (string, error) {
+	if userID == "" {
+		return "", ErrUserIDRequired
+	}
+	if q.client == nil {
+		return "", fmt.Errorf("queue is not connected")
+	}
+
+	slug, err := newFeedSlug()
+	if err != nil {
+		return "", err
+	}
+
+	// The old slug's mapping is only dropped if the user's slug is still the
+	// one read, so a racing rotation or creation never leaves a slug working
+	userKey := q.userFeedSlugKey(userID)
+	rotate := func(tx *redis.Tx) error {
+		old, err := tx.Get(ctx, userKey).Result()
+		if err != nil && err != redis.Nil {
+			return fmt.Errorf("failed to get feed slug: %w", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, userKey, slug, 0)
+			pipe.Set(ctx, q.feedSlugLookupKey(slug), userID, 0)
+			if old != "" {
+				pipe.Del(ctx, q.feedSlugLookupKey(old))
+			}
+			return nil
+		})
+		return err
+	}
+	for range rotateFeedSlugAttempts {
+		err := q.client.Watch(ctx, rotate, userKey)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to rotate feed slug: %w", err)
+		}
+		return slug, nil
+	}
+	return "", fmt.Errorf("failed to rotate feed slug: too many concurrent changes")
+}
+
+// LookupFeedSlug returns the user a feed slug belongs to, or "" if it is unknown
+func (q *Queue) LookupFeedSlug(ctx context.Context, slug string) (string, error) {
+	if q.client == nil {
+		return "", fmt.Errorf("queue is not connected")
+	}
+	if slug == "" {
+		return "", nil
+	}
+	userID, err := q.client.Get(ctx, q.feedSlugLookupKey(slug)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up feed slug: %w", err)
+	}
+	return userID, nil
+}
@@ -0,0 +1,97 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// FeedLockTTL bounds how long a crashed worker can keep a feed locked
+	FeedLockTTL = 2 * time.Minute
+	// feedLockPollInterval is how often LockFeed retries a held lock
+	feedLockPollInterval = 250 * time.Millisecond
+)
+
+// compareAndDelete deletes a key only if it still holds the caller's value, so
+// a feed lock that expired and was taken by another job is left alone
+var compareAndDelete = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// feedOwnersKey returns the Redis set key of users who have published a feed
+func (q *Queue) feedOwnersKey() string {
+	return fmt.Sprintf("%s:feed-owners", q.config.KeyPrefix)
+}
+
+// AddFeedOwner records that a user has a feed, so maintenance tasks can find it
+func (q *Queue) AddFeedOwner(ctx context.Context, userID string) error {
+	if userID == "" {
+		return ErrUserIDRequired
+	}
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+	if err := q.client.SAdd(ctx, q.feedOwnersKey(), userID).Err(); err != nil {
+		return fmt.Errorf("failed to add feed owner: %w", err)
+	}
+	return nil
+}
+
+// GetFeedOwners returns every user that has published a feed
+func (q *Queue) GetFeedOwners(ctx context.Context) ([]string, error) {
+	if q.client == nil {
+		return nil, fmt.Errorf("queue is not connected")
+	}
+	owners, err := q.client.SMembers(ctx, q.feedOwnersKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get feed owners: %w", err)
+	}
+	return owners, nil
+}
+
+// feedLockKey returns the Redis key that is held while a user's feed is written
+func (q *Queue) feedLockKey(userID string) string {
+	return fmt.Sprintf("%s:user:%s:feed-lock", q.config.KeyPrefix, userID)
+}
+
+// LockFeed blocks until it holds the lock on a user's feed, so concurrent jobs
+// for the same user write their RSS updates one at a time. Call unlock once the
+// feed is written.
+func (q *Queue) LockFeed(ctx context.Context, userID string) (unlock func(), err error) {
+	if userID == "" {
+		return nil, ErrUserIDRequired
+	}
+	if q.client == nil {
+		return nil, fmt.Errorf("queue is not connected")
+	}
+
+	key := q.feedLockKey(userID)
+	token := uuid.New().String()
+	for {
+		acquired, err := q.client.SetNX(ctx, key, token, FeedLockTTL).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock feed: %w", err)
+		}
+		if acquired {
+			break
+		}
+		if err := waitForRetry(ctx, feedLockPollInterval); err != nil {
+			return nil, err
+		}
+	}
+
+	return func() {
+		// Release even if the job's context was cancelled meanwhile
+		if err := compareAndDelete.Run(context.WithoutCancel(ctx), q.client, []string{key}, token).Err(); err != nil {
+			slog.ErrorContext(ctx, "Failed to release feed lock", "error", err, "user_id", userID)
+		}
+	}, nil
+}
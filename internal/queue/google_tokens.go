@@ -0,0 +1,63 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"cobblepod/internal/auth"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/oauth2"
+)
+
+var _ auth.GoogleTokenStore = (*Queue)(nil)
+
+// googleTokenKey returns the Redis key holding the Google tokens of a user who
+// signed in with the google auth provider
+func (q *Queue) googleTokenKey(userID string) string {
+	return fmt.Sprintf("%s:user:%s:google_token", q.config.KeyPrefix, userID)
+}
+
+// GetGoogleToken returns the user's stored Google tokens, or auth.ErrNoGoogleToken
+func (q *Queue) GetGoogleToken(ctx context.Context, userID string) (*oauth2.Token, error) {
+	if userID == "" {
+		return nil, ErrUserIDRequired
+	}
+	if q.client == nil {
+		return nil, fmt.Errorf("queue is not connected")
+	}
+
+	data, err := q.client.Get(ctx, q.googleTokenKey(userID)).Bytes()
+	if err == redis.Nil {
+		return nil, fmt.Errorf("%w: %s", auth.ErrNoGoogleToken, userID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get google token: %w", err)
+	}
+
+	var token oauth2.Token
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal google token: %w", err)
+	}
+	return &token, nil
+}
+
+// SaveGoogleToken stores the user's Google tokens
+func (q *Queue) SaveGoogleToken(ctx context.Context, userID string, token *oauth2.Token) error {
+	if userID == "" {
+		return ErrUserIDRequired
+	}
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+
+	data, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to marshal google token: %w", err)
+	}
+	if err := q.client.Set(ctx, q.googleTokenKey(userID), data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save google token: %w", err)
+	}
+	return nil
+}
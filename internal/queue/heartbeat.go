@@ -0,0 +1,164 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// HeartbeatInterval is how often a worker refreshes the heartbeat of the job it runs
+	HeartbeatInterval = 30 * time.Second
+	// HeartbeatTimeout is how long a job may go without a heartbeat before it is
+	// considered orphaned by a crashed worker
+	HeartbeatTimeout = 2 * time.Minute
+	// MaxJobAttempts is how many times a job is handed to a worker before a stall
+	// dead-letters it instead of requeueing it
+	MaxJobAttempts = 3
+)
+
+// heartbeatKey returns the Redis key that exists while a worker is alive on a job
+func (q *Queue) heartbeatKey(jobID string) string {
+	return fmt.Sprintf("%s:job:%s:heartbeat", q.config.KeyPrefix, jobID)
+}
+
+// Heartbeat marks a job as actively being worked on for another HeartbeatTimeout
+func (q *Queue) Heartbeat(ctx context.Context, jobID string) error {
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+	if err := q.client.Set(ctx, q.heartbeatKey(jobID), time.Now().Unix(), HeartbeatTimeout).Err(); err != nil {
+		return fmt.Errorf("failed to write heartbeat: %w", err)
+	}
+	return nil
+}
+
+// StartHeartbeat refreshes a job's heartbeat every HeartbeatInterval until the
+// returned stop function is called or ctx is cancelled
+func (q *Queue) StartHeartbeat(ctx context.Context, jobID string) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			if err := q.Heartbeat(ctx, jobID); err != nil && ctx.Err() == nil {
+				slog.WarnContext(ctx, "Failed to refresh job heartbeat", "error", err, "job_id", jobID)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// requeueStalledJob returns a claimed job whose heartbeat expired to its waiting
+// set, keeping its fair share score, and adds ARGV[4] to its attempts. The SREM claims the job so concurrent reapers never requeue it twice.
+var requeueStalledJob = redis.NewScript(`
+if redis.call("EXISTS", KEYS[2]) == 1 then
+	return 0
+end
+if redis.call("SREM", KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+if redis.call("SMOVE", KEYS[6], KEYS[7], ARGV[1]) == 1 then
+	if redis.call("HINCRBY", KEYS[3], ARGV[2], -1) <= 0 then
+		redis.call("HDEL", KEYS[3], ARGV[2])
+	end
+end
+redis.call("HSET", KEYS[4], "status", ARGV[3])
+if tonumber(ARGV[4]) ~= 0 then
+	redis.call("HINCRBY", KEYS[4], "attempts", ARGV[4])
+end
+redis.call("ZADD", KEYS[5], redis.call("HGET", KEYS[4], "fair_score") or 0, ARGV[1])
+return 1
+`)
+
+// RecoverStalledJobs finds claimed jobs whose worker stopped sending heartbeats,
+// returns them to the waiting queue (or dead-letters them after MaxJobAttempts)
+// and resyncs users' running slot counts. It returns the number of jobs recovered.
+func (q *Queue) RecoverStalledJobs(ctx context.Context) (int, error) {
+	if q.client == nil {
+		return 0, fmt.Errorf("queue is not connected")
+	}
+
+	jobIDs, err := q.client.SMembers(ctx, q.config.RunningQueue).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get running jobs: %w", err)
+	}
+
+	recovered := 0
+	for _, jobID := range jobIDs {
+		alive, err := q.client.Exists(ctx, q.heartbeatKey(jobID)).Result()
+		if err != nil {
+			return recovered, fmt.Errorf("failed to check heartbeat: %w", err)
+		}
+		if alive == 1 {
+			continue
+		}
+
+		job, err := q.GetJob(ctx, jobID)
+		if err != nil {
+			return recovered, fmt.Errorf("failed to get stalled job: %w", err)
+		}
+		if job == nil {
+			// The job data is gone; just drop the dangling entry
+			q.client.SRem(ctx, q.config.RunningQueue, jobID)
+			continue
+		}
+
+		if job.Attempts >= MaxJobAttempts {
+			slog.ErrorContext(ctx, "Stalled job exceeded max attempts, dead-lettering", "job_id", jobID, "attempts", job.Attempts)
+			if err := q.DeadLetterJob(ctx, job, "Worker stopped responding"); err != nil {
+				return recovered, err
+			}
+			recovered++
+			continue
+		}
+
+		keys := []string{
+			q.config.RunningQueue,
+			q.heartbeatKey(jobID),
+			q.config.RunningUsersKey,
+			q.jobKey(jobID),
+			q.waitingQueueFor(job.Priority),
+			q.userRunningKey(job.UserID),
+			q.userWaitingKey(job.UserID),
+		}
+		moved, err := requeueStalledJob.Run(ctx, q.client, keys, jobID, job.UserID, JobStatusQueued, 0).Int()
+		if err != nil {
+			return recovered, fmt.Errorf("failed to requeue stalled job %s: %w", jobID, err)
+		}
+		if moved == 1 {
+			slog.WarnContext(ctx, "Requeued stalled job", "job_id", jobID, "user_id", job.UserID, "attempts", job.Attempts)
+			recovered++
+		}
+	}
+
+	// Resync slot counts with the running sets they track, so a count that
+	// drifted (e.g. a write lost in a failover) can't lock a user out for good
+	users, err := q.client.HKeys(ctx, q.config.RunningUsersKey).Result()
+	if err != nil {
+		return recovered, fmt.Errorf("failed to get running users: %w", err)
+	}
+	for _, userID := range users {
+		keys := []string{q.config.RunningUsersKey, q.userRunningKey(userID)}
+		if err := syncUserSlots.Run(ctx, q.client, keys, userID).Err(); err != nil {
+			return recovered, fmt.Errorf("failed to sync running slots: %w", err)
+		}
+	}
+
+	return recovered, nil
+}
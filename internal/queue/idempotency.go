@@ -0,0 +1,70 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// IdempotencyTTL is how long a submission key maps to the job it created
+	IdempotencyTTL = 24 * time.Hour
+	// MaxIdempotencyKeyLength bounds client supplied keys
+	MaxIdempotencyKeyLength = 255
+)
+
+// idempotencyKey returns the Redis key mapping a user's submission key to a job ID
+func (q *Queue) idempotencyKey(userID string, key string) string {
+	return fmt.Sprintf("%s:user:%s:idempotency:%s", q.config.KeyPrefix, userID, key)
+}
+
+// claimIdempotencyKey sets a submission key to a job ID unless it's already
+// set, returning the job ID it was already set to, or false
+var claimIdempotencyKey = redis.NewScript(`
+local existing = redis.call("GET", KEYS[1])
+if existing then
+	return existing
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+return false
+`)
+
+// ClaimIdempotencyKey records that key submits jobID. If the key was already
+// claimed, nothing changes and the job ID it was claimed for is returned
+// instead; otherwise the returned ID is empty. The claim lasts IdempotencyTTL,
+// or until the job fails, see Job.IdempotencyKey.
+func (q *Queue) ClaimIdempotencyKey(ctx context.Context, userID string, key string, jobID string) (string, error) {
+	if userID == "" {
+		return "", ErrUserIDRequired
+	}
+	if q.client == nil {
+		return "", fmt.Errorf("queue is not connected")
+	}
+
+	existing, err := claimIdempotencyKey.Run(ctx, q.client, []string{q.idempotencyKey(userID, key)}, jobID, IdempotencyTTL.Milliseconds()).Text()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+	return existing, nil
+}
+
+// ReleaseIdempotencyKey forgets a key claimed for jobID, so a submission that
+// failed before its job was queued can be retried. Keys of queued jobs are
+// released when the job fails.
+func (q *Queue) ReleaseIdempotencyKey(ctx context.Context, userID string, key string, jobID string) error {
+	if userID == "" {
+		return ErrUserIDRequired
+	}
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+	if err := compareAndDelete.Run(ctx, q.client, []string{q.idempotencyKey(userID, key)}, jobID).Err(); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
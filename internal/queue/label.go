@@ -0,0 +1,32 @@
+package queue
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxLabelLength is the longest label a user can attach to a job, in characters
+const MaxLabelLength = 100
+
+// ErrInvalidLabel is returned when a job label is too long
+var ErrInvalidLabel = errors.New("invalid job label")
+
+// NormalizeLabel trims surrounding whitespace from a user supplied job label
+// and checks it fits within MaxLabelLength. An empty label is allowed.
+func NormalizeLabel(label string) (string, error) {
+	label = strings.TrimSpace(label)
+	if utf8.RuneCountInString(label) > MaxLabelLength {
+		return "", ErrInvalidLabel
+	}
+	return label, nil
+}
+
+// MatchesLabel reports whether the job's label contains filter, ignoring case.
+// An empty filter matches every job.
+func (j *Job) MatchesLabel(filter string) bool {
+	if filter == "" {
+		return true
+	}
+	return strings.Contains(strings.ToLower(j.Label), strings.ToLower(filter))
+}
@@ -0,0 +1,233 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// DefaultJobPageSize is how many jobs ListUserJobs returns when no limit is given
+	DefaultJobPageSize = 50
+	// MaxJobPageSize caps the limit a caller may ask for
+	MaxJobPageSize = 200
+)
+
+// Job states ListUserJobs can filter on; each maps to one or more user sets
+const (
+	JobStateAll       = ""
+	JobStateActive    = "active" // waiting or running
+	JobStateCompleted = "completed"
+	JobStateFailed    = "failed"
+	JobStatePartial   = "partial" // completed with failed items
+)
+
+// JobListOptions selects and pages the jobs returned by ListUserJobs
+type JobListOptions struct {
+	State     string    // one of the JobState constants
+	Label     string    // see Job.MatchesLabel
+	Query     string    // see Job.MatchesSearch
+	Since     time.Time // only jobs created at or after this time
+	Until     time.Time // only jobs created at or before this time
+	Ascending bool      // oldest first; newest first by default
+	Limit     int       // DefaultJobPageSize when zero, capped at MaxJobPageSize
+	Offset    int
+}
+
+// userJobIndexKey returns the Redis sorted set of a user's job IDs scored by creation time
+func (q *Queue) userJobIndexKey(userID string) string {
+	return fmt.Sprintf("%s:user:%s:jobs:created", q.config.KeyPrefix, userID)
+}
+
+// indexJob queues adding a job to its user's creation time index
+func (q *Queue) indexJob(ctx context.Context, pipe redis.Pipeliner, job *Job) {
+	pipe.ZAdd(ctx, q.userJobIndexKey(job.UserID), redis.Z{Score: float64(job.CreatedAt.UnixMilli()), Member: job.ID})
+}
+
+// stateKeys returns the user sets that make up a job state
+func (q *Queue) stateKeys(userID string, state string) ([]string, error) {
+	switch state {
+	case JobStateAll:
+		return []string{q.userWaitingKey(userID), q.userRunningKey(userID), q.userSuccessKey(userID), q.userFailedKey(userID)}, nil
+	case JobStateActive:
+		return []string{q.userWaitingKey(userID), q.userRunningKey(userID)}, nil
+	case JobStateCompleted:
+		return []string{q.userSuccessKey(userID)}, nil
+	case JobStateFailed:
+		return []string{q.userFailedKey(userID)}, nil
+	case JobStatePartial:
+		return []string{q.userPartialKey(userID)}, nil
+	}
+	return nil, fmt.Errorf("unknown job state %q", state)
+}
+
+// ListUserJobs returns one page of a user's jobs ordered by creation time, and
+// the number of jobs matching the options across all pages. Only the jobs on
+// the page are loaded.
+func (q *Queue) ListUserJobs(ctx context.Context, userID string, opts JobListOptions) ([]*Job, int, error) {
+	if userID == "" {
+		return nil, 0, ErrUserIDRequired
+	}
+	if q.client == nil {
+		return nil, 0, fmt.Errorf("queue is not connected")
+	}
+	keys, err := q.stateKeys(userID, opts.State)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := q.backfillJobIndex(ctx, userID); err != nil {
+		return nil, 0, err
+	}
+
+	span := &redis.ZRangeBy{Min: "-inf", Max: "+inf"}
+	if !opts.Since.IsZero() {
+		span.Min = strconv.FormatInt(opts.Since.UnixMilli(), 10)
+	}
+	if !opts.Until.IsZero() {
+		span.Max = strconv.FormatInt(opts.Until.UnixMilli(), 10)
+	}
+	var jobIDs []string
+	if opts.Ascending {
+		jobIDs, err = q.client.ZRangeByScore(ctx, q.userJobIndexKey(userID), span).Result()
+	} else {
+		jobIDs, err = q.client.ZRevRangeByScore(ctx, q.userJobIndexKey(userID), span).Result()
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get job index: %w", err)
+	}
+
+	if jobIDs, err = q.filterByState(ctx, jobIDs, keys); err != nil {
+		return nil, 0, err
+	}
+	if jobIDs, err = q.filterByLabel(ctx, jobIDs, opts.Label); err != nil {
+		return nil, 0, err
+	}
+	if jobIDs, err = q.filterBySearch(ctx, jobIDs, opts.Query); err != nil {
+		return nil, 0, err
+	}
+
+	total := len(jobIDs)
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultJobPageSize
+	}
+	limit = min(limit, MaxJobPageSize)
+	start := min(max(opts.Offset, 0), total)
+	end := min(start+limit, total)
+
+	jobs, err := q.getJobsFromIDs(ctx, jobIDs[start:end])
+	if err != nil {
+		return nil, 0, err
+	}
+	return jobs, total, nil
+}
+
+// filterByState keeps the job IDs that are a member of any of the given sets
+func (q *Queue) filterByState(ctx context.Context, jobIDs []string, keys []string) ([]string, error) {
+	if len(jobIDs) == 0 {
+		return jobIDs, nil
+	}
+
+	members := make([]interface{}, len(jobIDs))
+	for i, id := range jobIDs {
+		members[i] = id
+	}
+	pipe := q.client.Pipeline()
+	cmds := make([]*redis.BoolSliceCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.SMIsMember(ctx, key, members...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to check job states: %w", err)
+	}
+
+	filtered := make([]string, 0, len(jobIDs))
+	for i, id := range jobIDs {
+		for _, cmd := range cmds {
+			if cmd.Val()[i] {
+				filtered = append(filtered, id)
+				break
+			}
+		}
+	}
+	return filtered, nil
+}
+
+// filterByLabel keeps the job IDs whose label matches, reading only the label field
+func (q *Queue) filterByLabel(ctx context.Context, jobIDs []string, label string) ([]string, error) {
+	if label == "" || len(jobIDs) == 0 {
+		return jobIDs, nil
+	}
+
+	pipe := q.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(jobIDs))
+	for i, id := range jobIDs {
+		cmds[i] = pipe.HGet(ctx, q.jobKey(id), "label")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get job labels: %w", err)
+	}
+
+	filtered := make([]string, 0, len(jobIDs))
+	for i, id := range jobIDs {
+		job := Job{Label: cmds[i].Val()}
+		if job.MatchesLabel(label) {
+			filtered = append(filtered, id)
+		}
+	}
+	return filtered, nil
+}
+
+// backfillJobIndex adds jobs created before the index existed, so they stay
+// listable until they expire
+func (q *Queue) backfillJobIndex(ctx context.Context, userID string) error {
+	keys, _ := q.stateKeys(userID, JobStateAll)
+	jobIDs, err := q.client.SUnion(ctx, keys...).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get user jobs: %w", err)
+	}
+	if len(jobIDs) == 0 {
+		return nil
+	}
+
+	scores, err := q.client.ZMScore(ctx, q.userJobIndexKey(userID), jobIDs...).Result()
+	if err != nil {
+		return fmt.Errorf("failed to check job index: %w", err)
+	}
+	var missing []string
+	for i, id := range jobIDs {
+		// ZMSCORE reports members without a score as 0
+		if scores[i] == 0 {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	pipe := q.client.Pipeline()
+	created := make([]*redis.StringCmd, len(missing))
+	for i, id := range missing {
+		created[i] = pipe.HGet(ctx, q.jobKey(id), "created_at")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return fmt.Errorf("failed to get job creation times: %w", err)
+	}
+
+	pipe = q.client.Pipeline()
+	for i, id := range missing {
+		createdAt, err := time.Parse(time.RFC3339Nano, created[i].Val())
+		if err != nil {
+			continue // job data already expired
+		}
+		q.indexJob(ctx, pipe, &Job{ID: id, UserID: userID, CreatedAt: createdAt})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to backfill job index: %w", err)
+	}
+	return nil
+}
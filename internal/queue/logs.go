@@ -0,0 +1,85 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"cobblepod/internal/logging"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// jobLogMaxLen caps the log lines kept for a job, keeping the most recent
+const jobLogMaxLen = 1000
+
+// jobLogKey returns the Redis stream holding a job's log lines
+func (q *Queue) jobLogKey(jobID string) string {
+	return fmt.Sprintf("%s:job:%s:logs", q.config.KeyPrefix, jobID)
+}
+
+// appendJobLog adds ARGV[1] to the job's log stream, KEYS[1]. While the job,
+// KEYS[2], is unfinished the log outlives the line by ARGV[3] seconds; once it
+// finished the log expires with it, keeping the retention completeJob set.
+var appendJobLog = redis.NewScript(`
+redis.call("XADD", KEYS[1], "MAXLEN", "~", ARGV[2], "*", "line", ARGV[1])
+local status = redis.call("HGET", KEYS[2], "status")
+for i = 4, #ARGV do
+	if status == ARGV[i] then
+		local ttl = redis.call("PTTL", KEYS[2])
+		if ttl > 0 then
+			redis.call("PEXPIRE", KEYS[1], ttl)
+			return 1
+		end
+	end
+end
+redis.call("EXPIRE", KEYS[1], ARGV[3])
+return 1
+`)
+
+// AppendJobLog appends a line to a job's log, making the queue a
+// logging.Sink. Like the timeline, the log outlives its last line by the
+// deployment's job retention; once the job finished, lines that arrive late
+// keep its user's retention.
+// It doesn't log, as its lines would be captured in turn.
+func (q *Queue) AppendJobLog(ctx context.Context, jobID string, line logging.Line) error {
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+
+	payload, err := json.Marshal(line)
+	if err != nil {
+		return fmt.Errorf("failed to marshal log line: %w", err)
+	}
+
+	keys := []string{q.jobLogKey(jobID), q.jobKey(jobID)}
+	args := []interface{}{payload, jobLogMaxLen, int64(q.worker.JobRetention().Seconds()),
+		JobStatusCompleted, JobStatusCompletedWithErrors, JobStatusFailed}
+	if err := appendJobLog.Run(ctx, q.client, keys, args...).Err(); err != nil {
+		return fmt.Errorf("failed to append job log: %w", err)
+	}
+	return nil
+}
+
+// JobLogs returns the log lines kept for a job, oldest first
+func (q *Queue) JobLogs(ctx context.Context, jobID string) ([]logging.Line, error) {
+	if q.client == nil {
+		return nil, fmt.Errorf("queue is not connected")
+	}
+
+	entries, err := q.client.XRange(ctx, q.jobLogKey(jobID), "-", "+").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job logs: %w", err)
+	}
+
+	lines := make([]logging.Line, 0, len(entries))
+	for _, entry := range entries {
+		payload, _ := entry.Values["line"].(string)
+		var line logging.Line
+		if err := json.Unmarshal([]byte(payload), &line); err != nil {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
@@ -4,36 +4,43 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
+	"cobblepod/internal/config"
 	"cobblepod/internal/queue"
 )
 
 // MockQueue is a mock implementation of the Queue for testing
 type MockQueue struct {
 	mu           sync.RWMutex
-	runningUsers map[string]string // UserID -> JobID
-	runningJobs  map[string]bool   // JobID -> bool
+	runningUsers map[string]map[string]bool // UserID -> running JobIDs
+	runningJobs  map[string]bool            // JobID -> bool
 	waitingJobs  []*queue.Job
+	scheduled    []*queue.Job
 	failedJobs   []*queue.Job
+	deadLetter   map[string]bool // JobID -> bool
+	// MaxJobsPerUser is how many of a user's jobs StartJob lets run at once
+	MaxJobsPerUser int
 }
 
-// NewMockQueue creates a new mock queue
+// NewMockQueue creates a new mock queue with the default running slots
 func NewMockQueue() *MockQueue {
 	return &MockQueue{
-		runningUsers: make(map[string]string),
-		runningJobs:  make(map[string]bool),
-		waitingJobs:  make([]*queue.Job, 0),
-		failedJobs:   make([]*queue.Job, 0),
+		MaxJobsPerUser: config.Defaults().Worker.MaxJobsPerUser,
+		runningUsers:   make(map[string]map[string]bool),
+		runningJobs:    make(map[string]bool),
+		waitingJobs:    make([]*queue.Job, 0),
+		failedJobs:     make([]*queue.Job, 0),
+		deadLetter:     make(map[string]bool),
 	}
 }
 
-// IsUserRunning checks if a user already has a job running
+// IsUserRunning checks if a user has at least one job running
 func (m *MockQueue) IsUserRunning(ctx context.Context, userID string) (bool, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	_, exists := m.runningUsers[userID]
-	return exists, nil
+	return len(m.runningUsers[userID]) > 0, nil
 }
 
 // Enqueue adds a job to the queue
@@ -45,7 +52,40 @@ func (m *MockQueue) Enqueue(ctx context.Context, job *queue.Job) error {
 	return nil
 }
 
-// Dequeue removes and returns a job from the queue
+// EnqueueAt holds a job back until PromoteDueJobs finds it due
+func (m *MockQueue) EnqueueAt(ctx context.Context, job *queue.Job, runAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job.Status = queue.JobStatusScheduled
+	job.RunAt = runAt
+	m.scheduled = append(m.scheduled, job)
+	return nil
+}
+
+// PromoteDueJobs moves due scheduled jobs into the waiting queue
+func (m *MockQueue) PromoteDueJobs(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	remaining := m.scheduled[:0]
+	promoted := 0
+	for _, job := range m.scheduled {
+		if job.RunAt.After(now) {
+			remaining = append(remaining, job)
+			continue
+		}
+		job.Status = queue.JobStatusQueued
+		m.waitingJobs = append(m.waitingJobs, job)
+		promoted++
+	}
+	m.scheduled = remaining
+	return promoted, nil
+}
+
+// Dequeue removes and returns a job from the queue: the oldest interactive job
+// if there is one, otherwise the oldest background job
 func (m *MockQueue) Dequeue(ctx context.Context) (*queue.Job, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -54,36 +94,75 @@ func (m *MockQueue) Dequeue(ctx context.Context) (*queue.Job, error) {
 		return nil, nil
 	}
 
-	// Pop from the front (FIFO) - Redis uses LPUSH/BRPOP which gives FIFO behavior
-	job := m.waitingJobs[0]
-	m.waitingJobs = m.waitingJobs[1:]
+	// Jobs are taken first in, first out, which matches the real queue's fair
+	// share order as long as every job belongs to the same user
+	next := 0
+	for i, job := range m.waitingJobs {
+		if job.Priority == queue.PriorityInteractive {
+			next = i
+			break
+		}
+	}
+	job := m.waitingJobs[next]
+	m.waitingJobs = append(m.waitingJobs[:next], m.waitingJobs[next+1:]...)
 	return job, nil
 }
 
-// StartJob marks a user as having a running job
+// StartJob takes one of the user's running slots, returning false when all are taken
 func (m *MockQueue) StartJob(ctx context.Context, userID string, jobID string) (bool, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, exists := m.runningUsers[userID]; exists {
+	jobs := m.runningUsers[userID]
+	if jobs[jobID] {
+		return true, nil
+	}
+	if len(jobs) >= m.MaxJobsPerUser {
 		return false, nil
 	}
 
-	m.runningUsers[userID] = jobID
+	if jobs == nil {
+		jobs = make(map[string]bool)
+		m.runningUsers[userID] = jobs
+	}
+	jobs[jobID] = true
 	m.runningJobs[jobID] = true
 	return true, nil
 }
 
-// CompleteJob marks a job as complete and removes user from running set
-func (m *MockQueue) CompleteJob(ctx context.Context, userID string, jobID string) error {
+// DeferJob schedules a job that could not get a running slot to be retried after delay
+func (m *MockQueue) DeferJob(ctx context.Context, job *queue.Job, delay time.Duration) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	delete(m.runningUsers, userID)
+	job.RunAt = time.Now().Add(delay)
+	m.scheduled = append(m.scheduled, job)
+	return nil
+}
+
+// releaseSlot frees the running slot held by a job; callers hold m.mu
+func (m *MockQueue) releaseSlot(userID string, jobID string) {
 	delete(m.runningJobs, jobID)
+	delete(m.runningUsers[userID], jobID)
+	if len(m.runningUsers[userID]) == 0 {
+		delete(m.runningUsers, userID)
+	}
+}
+
+// CompleteJob marks a job as complete and frees its running slot
+func (m *MockQueue) CompleteJob(ctx context.Context, userID string, jobID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.releaseSlot(userID, jobID)
 	return nil
 }
 
+// CompleteJobWithErrors marks a job as complete with failed items and frees its running slot
+func (m *MockQueue) CompleteJobWithErrors(ctx context.Context, userID string, jobID string, failedItems int) error {
+	return m.CompleteJob(ctx, userID, jobID)
+}
+
 // FailJob adds a job to the failed queue with a reason
 func (m *MockQueue) FailJob(ctx context.Context, job *queue.Job, reason string) error {
 	m.mu.Lock()
@@ -91,10 +170,45 @@ func (m *MockQueue) FailJob(ctx context.Context, job *queue.Job, reason string)
 
 	job.FailReason = reason
 	m.failedJobs = append(m.failedJobs, job)
-	delete(m.runningJobs, job.ID)
+	m.releaseSlot(job.UserID, job.ID)
+	return nil
+}
+
+// DeadLetterJob fails a job and marks it for RequeueFailed
+func (m *MockQueue) DeadLetterJob(ctx context.Context, job *queue.Job, reason string) error {
+	if err := m.FailJob(ctx, job, reason); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deadLetter[job.ID] = true
 	return nil
 }
 
+// RequeueFailed moves a dead-lettered job back into the waiting queue
+func (m *MockQueue) RequeueFailed(ctx context.Context, jobID string) (*queue.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.deadLetter[jobID] {
+		return nil, queue.ErrJobNotDeadLettered
+	}
+	for i, job := range m.failedJobs {
+		if job.ID != jobID {
+			continue
+		}
+		m.failedJobs = append(m.failedJobs[:i], m.failedJobs[i+1:]...)
+		delete(m.deadLetter, jobID)
+		job.Status = queue.JobStatusQueued
+		job.FailReason = ""
+		m.waitingJobs = append(m.waitingJobs, job)
+		return job, nil
+	}
+	delete(m.deadLetter, jobID)
+	return nil, queue.ErrJobNotDeadLettered
+}
+
 // QueueLength returns the number of jobs in the queue
 func (m *MockQueue) QueueLength(ctx context.Context) (int64, error) {
 	m.mu.RLock()
@@ -160,7 +274,7 @@ func (m *MockQueue) SetUserRunning(userID string, running bool) {
 	defer m.mu.Unlock()
 
 	if running {
-		m.runningUsers[userID] = "mock-job-id"
+		m.runningUsers[userID] = map[string]bool{"mock-job-id": true}
 	} else {
 		delete(m.runningUsers, userID)
 	}
@@ -191,20 +305,28 @@ func (m *MockQueue) Clear() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.runningUsers = make(map[string]string)
+	m.runningUsers = make(map[string]map[string]bool)
 	m.runningJobs = make(map[string]bool)
 	m.waitingJobs = make([]*queue.Job, 0)
+	m.scheduled = nil
 	m.failedJobs = make([]*queue.Job, 0)
+	m.deadLetter = make(map[string]bool)
 }
 
 // Compile-time check that MockQueue implements the same interface as Queue
 var _ interface {
 	IsUserRunning(ctx context.Context, userID string) (bool, error)
 	Enqueue(ctx context.Context, job *queue.Job) error
+	EnqueueAt(ctx context.Context, job *queue.Job, runAt time.Time) error
+	PromoteDueJobs(ctx context.Context) (int, error)
 	Dequeue(ctx context.Context) (*queue.Job, error)
 	StartJob(ctx context.Context, userID string, jobID string) (bool, error)
+	DeferJob(ctx context.Context, job *queue.Job, delay time.Duration) error
 	CompleteJob(ctx context.Context, userID string, jobID string) error
+	CompleteJobWithErrors(ctx context.Context, userID string, jobID string, failedItems int) error
 	FailJob(ctx context.Context, job *queue.Job, reason string) error
+	DeadLetterJob(ctx context.Context, job *queue.Job, reason string) error
+	RequeueFailed(ctx context.Context, jobID string) (*queue.Job, error)
 	CleanupExpiredJobs(ctx context.Context) error
 	QueueLength(ctx context.Context) (int64, error)
 	GetJob(ctx context.Context, jobID string) (*queue.Job, error)
@@ -276,6 +398,13 @@ func (m *MockQueueWithErrors) CompleteJob(ctx context.Context, userID string, jo
 	return m.MockQueue.CompleteJob(ctx, userID, jobID)
 }
 
+func (m *MockQueueWithErrors) CompleteJobWithErrors(ctx context.Context, userID string, jobID string, failedItems int) error {
+	if m.errorMode == ErrorOnCompleteJob {
+		return fmt.Errorf("mock error: CompleteJobWithErrors failed")
+	}
+	return m.MockQueue.CompleteJobWithErrors(ctx, userID, jobID, failedItems)
+}
+
 func (m *MockQueueWithErrors) FailJob(ctx context.Context, job *queue.Job, reason string) error {
 	if m.errorMode == ErrorOnFailJob {
 		return fmt.Errorf("mock error: FailJob failed")
@@ -2,6 +2,8 @@ package mock
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
@@ -60,13 +62,40 @@ func TestMockQueue_StartJob(t *testing.T) {
 		t.Error("First StartJob should return true")
 	}
 
-	// Second call should fail (user already running)
+	// Starting the same job again doesn't take another slot
 	added, err = mockQueue.StartJob(ctx, "user1", "job1")
 	if err != nil {
 		t.Fatalf("StartJob() unexpected error: %v", err)
 	}
+	if !added {
+		t.Error("Restarting a running job should return true")
+	}
+
+	// Other jobs may run until the user's slots are used up
+	for i := 2; i <= mockQueue.MaxJobsPerUser; i++ {
+		added, err = mockQueue.StartJob(ctx, "user1", fmt.Sprintf("job%d", i))
+		if err != nil {
+			t.Fatalf("StartJob() unexpected error: %v", err)
+		}
+		if !added {
+			t.Errorf("StartJob for job%d should return true", i)
+		}
+	}
+	added, err = mockQueue.StartJob(ctx, "user1", "job-over-limit")
+	if err != nil {
+		t.Fatalf("StartJob() unexpected error: %v", err)
+	}
 	if added {
-		t.Error("Second StartJob should return false")
+		t.Error("StartJob beyond the user's slots should return false")
+	}
+
+	// Another user is unaffected
+	added, err = mockQueue.StartJob(ctx, "user2", "job-other")
+	if err != nil {
+		t.Fatalf("StartJob() unexpected error: %v", err)
+	}
+	if !added {
+		t.Error("StartJob for another user should return true")
 	}
 
 	// Verify user is running
@@ -108,6 +137,32 @@ func TestMockQueue_CompleteJob(t *testing.T) {
 	}
 }
 
+func TestMockQueue_FailJobReleasesOwnLock(t *testing.T) {
+	ctx := context.Background()
+	mockQueue := NewMockQueue()
+
+	if _, err := mockQueue.StartJob(ctx, "user1", "job1"); err != nil {
+		t.Fatalf("StartJob() unexpected error: %v", err)
+	}
+
+	// Failing a different job must not release job1's lock
+	if err := mockQueue.FailJob(ctx, &queue.Job{ID: "job2", UserID: "user1"}, "conflict"); err != nil {
+		t.Fatalf("FailJob() unexpected error: %v", err)
+	}
+	isRunning, _ := mockQueue.IsUserRunning(ctx, "user1")
+	if !isRunning {
+		t.Error("Expected user1 to still be running after unrelated job failed")
+	}
+
+	if err := mockQueue.FailJob(ctx, &queue.Job{ID: "job1", UserID: "user1"}, "boom"); err != nil {
+		t.Fatalf("FailJob() unexpected error: %v", err)
+	}
+	isRunning, _ = mockQueue.IsUserRunning(ctx, "user1")
+	if isRunning {
+		t.Error("Expected user1 not to be running after its job failed")
+	}
+}
+
 func TestMockQueue_EnqueueDequeue(t *testing.T) {
 	ctx := context.Background()
 	mockQueue := NewMockQueue()
@@ -215,6 +270,92 @@ func TestMockQueue_EnqueueDequeue(t *testing.T) {
 	}
 }
 
+func TestMockQueue_DequeuePrefersInteractive(t *testing.T) {
+	ctx := context.Background()
+	mockQueue := NewMockQueue()
+
+	for _, job := range []*queue.Job{
+		{ID: "background", UserID: "user1"},
+		{ID: "interactive", UserID: "user2", Priority: queue.PriorityInteractive},
+	} {
+		if err := mockQueue.Enqueue(ctx, job); err != nil {
+			t.Fatalf("Enqueue() unexpected error: %v", err)
+		}
+	}
+
+	for _, want := range []string{"interactive", "background"} {
+		job, err := mockQueue.Dequeue(ctx)
+		if err != nil {
+			t.Fatalf("Dequeue() unexpected error: %v", err)
+		}
+		if job == nil || job.ID != want {
+			t.Errorf("Expected job %s, got %v", want, job)
+		}
+	}
+}
+
+func TestMockQueue_EnqueueAt(t *testing.T) {
+	ctx := context.Background()
+	mockQueue := NewMockQueue()
+
+	due := &queue.Job{ID: "due", UserID: "user1"}
+	later := &queue.Job{ID: "later", UserID: "user2"}
+	if err := mockQueue.EnqueueAt(ctx, due, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("EnqueueAt() unexpected error: %v", err)
+	}
+	if err := mockQueue.EnqueueAt(ctx, later, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("EnqueueAt() unexpected error: %v", err)
+	}
+
+	promoted, err := mockQueue.PromoteDueJobs(ctx)
+	if err != nil {
+		t.Fatalf("PromoteDueJobs() unexpected error: %v", err)
+	}
+	if promoted != 1 {
+		t.Errorf("Expected 1 job promoted, got %d", promoted)
+	}
+
+	waiting := mockQueue.GetWaitingJobs()
+	if len(waiting) != 1 || waiting[0].ID != "due" {
+		t.Errorf("Expected only the due job to be waiting, got %v", waiting)
+	}
+	if waiting[0].Status != queue.JobStatusQueued {
+		t.Errorf("Expected promoted job status %s, got %s", queue.JobStatusQueued, waiting[0].Status)
+	}
+}
+
+func TestMockQueue_RequeueFailed(t *testing.T) {
+	ctx := context.Background()
+	mockQueue := NewMockQueue()
+
+	plain := &queue.Job{ID: "plain", UserID: "user1"}
+	dead := &queue.Job{ID: "dead", UserID: "user2"}
+	if err := mockQueue.FailJob(ctx, plain, "bad backup"); err != nil {
+		t.Fatalf("FailJob() unexpected error: %v", err)
+	}
+	if err := mockQueue.DeadLetterJob(ctx, dead, "Failed to acquire user lock"); err != nil {
+		t.Fatalf("DeadLetterJob() unexpected error: %v", err)
+	}
+
+	if _, err := mockQueue.RequeueFailed(ctx, "plain"); !errors.Is(err, queue.ErrJobNotDeadLettered) {
+		t.Errorf("Expected ErrJobNotDeadLettered for a regular failure, got %v", err)
+	}
+
+	job, err := mockQueue.RequeueFailed(ctx, "dead")
+	if err != nil {
+		t.Fatalf("RequeueFailed() unexpected error: %v", err)
+	}
+	if job.Status != queue.JobStatusQueued || job.FailReason != "" {
+		t.Errorf("Expected requeued job to be queued without a fail reason, got %+v", job)
+	}
+	if waiting := mockQueue.GetWaitingJobs(); len(waiting) != 1 || waiting[0].ID != "dead" {
+		t.Errorf("Expected requeued job to be waiting, got %v", waiting)
+	}
+	if failed := mockQueue.GetFailedJobs(); len(failed) != 1 || failed[0].ID != "plain" {
+		t.Errorf("Expected only the regular failure to remain failed, got %v", failed)
+	}
+}
+
 func TestMockQueue_FailJob(t *testing.T) {
 	ctx := context.Background()
 	mockQueue := NewMockQueue()
@@ -0,0 +1,131 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// MaxRuleEpisodesPerShow caps how many episodes of each show rules may select
+	MaxRuleEpisodesPerShow = 50
+	// MaxRuleMinutes caps the total length rules may select, a week of audio
+	MaxRuleMinutes = 7 * 24 * 60
+)
+
+// PlaylistOrder is the order episodes selected by rules are published in
+type PlaylistOrder string
+
+const (
+	OrderNewest   PlaylistOrder = "newest"
+	OrderOldest   PlaylistOrder = "oldest"
+	OrderShortest PlaylistOrder = "shortest"
+	OrderLongest  PlaylistOrder = "longest"
+)
+
+var playlistOrders = []PlaylistOrder{OrderNewest, OrderOldest, OrderShortest, OrderLongest}
+
+// PlaylistRules select the episodes of a user's feed from the subscriptions in
+// their backup, instead of the playlist they maintain in Podcast Addict, e.g.
+// "the 3 newest unplayed episodes of each show, shortest first, at most 4 hours"
+type PlaylistRules struct {
+	// EpisodesPerShow takes this many of the newest episodes of each show
+	EpisodesPerShow int `json:"episodes_per_show"`
+	// UnplayedOnly leaves out episodes marked as played
+	UnplayedOnly bool `json:"unplayed_only"`
+	// Shows limits the selection to these shows, by name; empty means every subscribed show
+	Shows []string `json:"shows,omitempty"`
+	// Order is how the selected episodes are ordered; empty means newest first
+	Order PlaylistOrder `json:"order,omitempty"`
+	// MaxMinutes stops adding episodes once their remaining audio would exceed
+	// this many minutes; zero means no limit
+	MaxMinutes int `json:"max_minutes,omitempty"`
+}
+
+// MaxDuration returns the most audio the rules select, zero for no limit
+func (r PlaylistRules) MaxDuration() time.Duration {
+	return time.Duration(r.MaxMinutes) * time.Minute
+}
+
+// Validate checks that the rules can be applied
+func (r PlaylistRules) Validate() error {
+	if r.EpisodesPerShow < 1 || r.EpisodesPerShow > MaxRuleEpisodesPerShow {
+		return fmt.Errorf("%w: episodes_per_show must be between 1 and %d", ErrInvalidSettings, MaxRuleEpisodesPerShow)
+	}
+	if r.Order != "" && !slices.Contains(playlistOrders, r.Order) {
+		return fmt.Errorf("%w: unknown order %q", ErrInvalidSettings, r.Order)
+	}
+	if r.MaxMinutes < 0 || r.MaxMinutes > MaxRuleMinutes {
+		return fmt.Errorf("%w: max_minutes must be between 0 and %d", ErrInvalidSettings, MaxRuleMinutes)
+	}
+	return nil
+}
+
+// playlistRulesKey returns the Redis key holding a user's playlist rules
+func (q *Queue) playlistRulesKey(userID string) string {
+	return fmt.Sprintf("%s:user:%s:playlist-rules", q.config.KeyPrefix, userID)
+}
+
+// GetPlaylistRules returns the user's playlist rules, or nil if they use their
+// backup's playlist
+func (q *Queue) GetPlaylistRules(ctx context.Context, userID string) (*PlaylistRules, error) {
+	if userID == "" {
+		return nil, ErrUserIDRequired
+	}
+	if q.client == nil {
+		return nil, fmt.Errorf("queue is not connected")
+	}
+
+	data, err := q.client.Get(ctx, q.playlistRulesKey(userID)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get playlist rules: %w", err)
+	}
+	var rules PlaylistRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to decode playlist rules: %w", err)
+	}
+	return &rules, nil
+}
+
+// SavePlaylistRules validates and stores the user's playlist rules
+func (q *Queue) SavePlaylistRules(ctx context.Context, userID string, rules *PlaylistRules) error {
+	if userID == "" {
+		return ErrUserIDRequired
+	}
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+	if err := rules.Validate(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return fmt.Errorf("failed to encode playlist rules: %w", err)
+	}
+	if err := q.client.Set(ctx, q.playlistRulesKey(userID), data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save playlist rules: %w", err)
+	}
+	return nil
+}
+
+// DeletePlaylistRules goes back to using the user's backup playlist
+func (q *Queue) DeletePlaylistRules(ctx context.Context, userID string) error {
+	if userID == "" {
+		return ErrUserIDRequired
+	}
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+	if err := q.client.Del(ctx, q.playlistRulesKey(userID)).Err(); err != nil {
+		return fmt.Errorf("failed to delete playlist rules: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"cobblepod/internal/safehttp"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// MaxPlaylistURLLength bounds the playlist URL a user can register
+const MaxPlaylistURLLength = 2048
+
+// PlaylistURL is an M3U8 playlist a user publishes over HTTP, e.g. exported by
+// another app. The worker polls it and processes it whenever it changes.
+type PlaylistURL struct {
+	URL string `json:"url" redis:"url"`
+	// ETag and LastModified are the validators of the last fetch, sent back so
+	// an unchanged playlist isn't downloaded again
+	ETag         string `json:"-" redis:"etag"`
+	LastModified string `json:"-" redis:"last_modified"`
+	// Checksum is the SHA-256 of the last playlist processed, which tells
+	// unchanged playlists apart on servers that don't send validators
+	Checksum string `json:"-" redis:"checksum"`
+	// JobID is the job enqueued for the playlist's last change. The fetch is
+	// only recorded once a job processed it, so polls don't enqueue another
+	// while this one is waiting or running.
+	JobID string `json:"-" redis:"job_id"`
+}
+
+// Validate checks that the playlist can be fetched
+func (p PlaylistURL) Validate() error {
+	if len(p.URL) > MaxPlaylistURLLength {
+		return fmt.Errorf("%w: url must be at most %d characters", ErrInvalidSettings, MaxPlaylistURLLength)
+	}
+	u, err := url.Parse(p.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("%w: url must be an http or https URL", ErrInvalidSettings)
+	}
+	if err := safehttp.CheckHost(u.Hostname()); err != nil {
+		return fmt.Errorf("%w: url %v", ErrInvalidSettings, err)
+	}
+	return nil
+}
+
+// playlistURLKey returns the Redis hash holding a user's playlist URL
+func (q *Queue) playlistURLKey(userID string) string {
+	return fmt.Sprintf("%s:user:%s:playlist-url", q.config.KeyPrefix, userID)
+}
+
+// playlistURLUsersKey returns the Redis set of users with a playlist URL
+func (q *Queue) playlistURLUsersKey() string {
+	return fmt.Sprintf("%s:playlist-url-users", q.config.KeyPrefix)
+}
+
+// GetPlaylistURL returns the user's playlist URL, or nil if they haven't
+// registered one
+func (q *Queue) GetPlaylistURL(ctx context.Context, userID string) (*PlaylistURL, error) {
+	if userID == "" {
+		return nil, ErrUserIDRequired
+	}
+	if q.client == nil {
+		return nil, fmt.Errorf("queue is not connected")
+	}
+
+	var playlist PlaylistURL
+	if err := q.client.HGetAll(ctx, q.playlistURLKey(userID)).Scan(&playlist); err != nil {
+		return nil, fmt.Errorf("failed to get playlist URL: %w", err)
+	}
+	if playlist.URL == "" {
+		return nil, nil
+	}
+	return &playlist, nil
+}
+
+// SavePlaylistURL validates and registers the user's playlist URL. Registering
+// a URL forgets what was fetched before, so the next poll processes it.
+func (q *Queue) SavePlaylistURL(ctx context.Context, userID string, rawURL string) error {
+	if userID == "" {
+		return ErrUserIDRequired
+	}
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+	if err := (PlaylistURL{URL: rawURL}).Validate(); err != nil {
+		return err
+	}
+
+	pipe := q.client.TxPipeline()
+	pipe.Del(ctx, q.playlistURLKey(userID))
+	pipe.HSet(ctx, q.playlistURLKey(userID), "url", rawURL)
+	pipe.SAdd(ctx, q.playlistURLUsersKey(), userID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save playlist URL: %w", err)
+	}
+	return nil
+}
+
+// DeletePlaylistURL stops polling the user's playlist URL
+func (q *Queue) DeletePlaylistURL(ctx context.Context, userID string) error {
+	if userID == "" {
+		return ErrUserIDRequired
+	}
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+
+	pipe := q.client.TxPipeline()
+	pipe.Del(ctx, q.playlistURLKey(userID))
+	pipe.SRem(ctx, q.playlistURLUsersKey(), userID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to delete playlist URL: %w", err)
+	}
+	return nil
+}
+
+// GetPlaylistURLUsers returns the users with a playlist URL to poll
+func (q *Queue) GetPlaylistURLUsers(ctx context.Context) ([]string, error) {
+	if q.client == nil {
+		return nil, fmt.Errorf("queue is not connected")
+	}
+	users, err := q.client.SMembers(ctx, q.playlistURLUsersKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get playlist URL users: %w", err)
+	}
+	return users, nil
+}
+
+// recordPlaylistFetch stores the validators and checksum of a fetch, unless the
+// user registered another URL since the fetch began
+var recordPlaylistFetch = redis.NewScript(`
+if redis.call("HGET", KEYS[1], "url") ~= ARGV[1] then
+	return 0
+end
+redis.call("HSET", KEYS[1], "etag", ARGV[2], "last_modified", ARGV[3], "checksum", ARGV[4])
+return 1
+`)
+
+// RecordPlaylistFetch remembers the fetch of the user's playlist URL that was
+// last processed, or that polling found unchanged, see PlaylistURL
+func (q *Queue) RecordPlaylistFetch(ctx context.Context, userID string, playlist *PlaylistURL) error {
+	if userID == "" {
+		return ErrUserIDRequired
+	}
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+	keys := []string{q.playlistURLKey(userID)}
+	if err := recordPlaylistFetch.Run(ctx, q.client, keys, playlist.URL, playlist.ETag, playlist.LastModified, playlist.Checksum).Err(); err != nil {
+		return fmt.Errorf("failed to record playlist fetch: %w", err)
+	}
+	return nil
+}
+
+// setPlaylistJob stores the job enqueued for a playlist, unless the user
+// registered another URL since it was fetched
+var setPlaylistJob = redis.NewScript(`
+if redis.call("HGET", KEYS[1], "url") ~= ARGV[1] then
+	return 0
+end
+redis.call("HSET", KEYS[1], "job_id", ARGV[2])
+return 1
+`)
+
+// SetPlaylistJob records the job enqueued for a change of the user's playlist
+// URL, see PlaylistURL
+func (q *Queue) SetPlaylistJob(ctx context.Context, userID string, rawURL string, jobID string) error {
+	if userID == "" {
+		return ErrUserIDRequired
+	}
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+	keys := []string{q.playlistURLKey(userID)}
+	if err := setPlaylistJob.Run(ctx, q.client, keys, rawURL, jobID).Err(); err != nil {
+		return fmt.Errorf("failed to set playlist job: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Job hash fields holding the item counters, matching the Job struct tags
+const (
+	countTotal      = "total_items"
+	countCompleted  = "completed"
+	countFailed     = "failed"
+	countSkipped    = "skipped"
+	countInProgress = "in_progress"
+)
+
+// counterFields maps item statuses to the job counter they are tallied in.
+// Pending items are only part of the total.
+var counterFields = map[JobItemStatus]string{
+	StatusDownloading: countInProgress,
+	StatusProcessing:  countInProgress,
+	StatusUploading:   countInProgress,
+	StatusCompleted:   countCompleted,
+	StatusSkipped:     countSkipped,
+	StatusFailed:      countFailed,
+}
+
+// updateJobItem stores an item, moves it between the job's counters and, when
+// its status changed, records and publishes the transition (ARGV[3]) the way
+// publishEvent does, all in one step. Concurrent item updates therefore never
+// leave the summary out of step with the items, and subscribers see
+// transitions in the order they were stored. ARGV[4] is the key prefix,
+// ARGV[5] and ARGV[6] the timeline's TTL in seconds and length, and ARGV[7:]
+// status/counter pairs from counterFields. Returns whether the status changed.
+var updateJobItem = redis.NewScript(`
+local counters = {}
+for i = 7, #ARGV, 2 do
+	counters[ARGV[i]] = ARGV[i + 1]
+end
+local old = redis.call("HGET", KEYS[1], ARGV[1])
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+local oldStatus = old and cjson.decode(old)["status"]
+local newStatus = cjson.decode(ARGV[2])["status"]
+local from = oldStatus and counters[oldStatus]
+local to = counters[newStatus]
+if not old then
+	redis.call("HINCRBY", KEYS[2], "total_items", 1)
+end
+if from ~= to then
+	if from then
+		redis.call("HINCRBY", KEYS[2], from, -1)
+	end
+	if to then
+		redis.call("HINCRBY", KEYS[2], to, 1)
+	end
+end
+if oldStatus == newStatus then
+	return 0
+end
+redis.call("XADD", KEYS[3], "MAXLEN", "~", ARGV[6], "*", "event", ARGV[3])
+redis.call("EXPIRE", KEYS[3], ARGV[5])
+local userID = redis.call("HGET", KEYS[2], "user_id")
+if userID and userID ~= "" then
+	-- userEventsKey
+	redis.call("PUBLISH", ARGV[4] .. ":user:" .. userID .. ":events", ARGV[3])
+end
+return 1
+`)
+
+// CountItems recomputes the job's item counters from its items
+func (j *Job) CountItems() {
+	j.TotalItems = len(j.Items)
+	j.Completed, j.Failed, j.Skipped, j.InProgress = 0, 0, 0, 0
+	for _, item := range j.Items {
+		switch counterFields[item.Status] {
+		case countCompleted:
+			j.Completed++
+		case countFailed:
+			j.Failed++
+		case countSkipped:
+			j.Skipped++
+		case countInProgress:
+			j.InProgress++
+		}
+	}
+}
+
+// itemCounts returns the job hash fields for the counters of items
+func itemCounts(items []JobItem) map[string]interface{} {
+	job := Job{Items: items}
+	job.CountItems()
+	return map[string]interface{}{
+		countTotal:      job.TotalItems,
+		countCompleted:  job.Completed,
+		countFailed:     job.Failed,
+		countSkipped:    job.Skipped,
+		countInProgress: job.InProgress,
+	}
+}
+
+// finishedItems returns how many of a job's items completed and failed
+func (q *Queue) finishedItems(ctx context.Context, jobID string) (completed int, failed int, err error) {
+	var counts struct {
+		Completed int `redis:"completed"`
+		Failed    int `redis:"failed"`
+	}
+	if err := q.client.HMGet(ctx, q.jobKey(jobID), countCompleted, countFailed).Scan(&counts); err != nil {
+		return 0, 0, fmt.Errorf("failed to get item counts: %w", err)
+	}
+	return counts.Completed, counts.Failed, nil
+}
+
+// SetJobItems replaces all items for a running job, resets its counters and
+// re-estimates its completion from its items
+func (q *Queue) SetJobItems(ctx context.Context, jobID string, items []JobItem) error {
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+
+	pipe := q.client.TxPipeline()
+	pipe.Del(ctx, q.jobItemsKey(jobID)) // Clear existing items
+
+	for _, item := range items {
+		itemJSON, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("failed to marshal item: %w", err)
+		}
+		pipe.HSet(ctx, q.jobItemsKey(jobID), item.ID, itemJSON)
+	}
+	pipe.HSet(ctx, q.jobKey(jobID), itemCounts(items))
+	q.indexJobSearch(ctx, pipe, jobID, items)
+	// The running job's playlist is known now, so its estimate can use it
+	if eta := q.estimate(ctx, queuePosition{}, items); !eta.IsZero() {
+		pipe.HSet(ctx, q.jobKey(jobID), "estimated_completion", eta)
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SetJobFeedURL records the URL of the feed a job published
+func (q *Queue) SetJobFeedURL(ctx context.Context, jobID string, feedURL string) error {
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+	if err := q.client.HSet(ctx, q.jobKey(jobID), "feed_url", feedURL).Err(); err != nil {
+		return fmt.Errorf("failed to set job feed URL: %w", err)
+	}
+	return nil
+}
+
+// UpdateJobItem updates a single item in a job and its job's counters, and
+// publishes the item's status changes, atomically
+func (q *Queue) UpdateJobItem(ctx context.Context, jobID string, item JobItem) error {
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+
+	itemJSON, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to marshal item: %w", err)
+	}
+	event, err := json.Marshal(Event{Type: EventItemUpdated, JobID: jobID, ItemID: item.ID, Status: string(item.Status), Message: item.Error, Timestamp: time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	args := []interface{}{item.ID, itemJSON, event, q.config.KeyPrefix, int64(q.worker.JobRetention().Seconds()), jobTimelineMaxLen}
+	for status, field := range counterFields {
+		args = append(args, string(status), field)
+	}
+	keys := []string{q.jobItemsKey(jobID), q.jobKey(jobID), q.jobTimelineKey(jobID)}
+	if err := updateJobItem.Run(ctx, q.client, keys, args...).Err(); err != nil {
+		return fmt.Errorf("failed to update job item: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrJobsRunning is returned by PurgeUserHistory while one of the user's jobs
+// is running, since the worker would write the job back as it finished
+var ErrJobsRunning = errors.New("jobs are running")
+
+// purgeAttempts bounds how often PurgeUserHistory retries when the user's jobs
+// change under it
+const purgeAttempts = 3
+
+// PurgeUserHistory deletes every job a user has, waiting or finished, along
+// with its items and timeline, and the user's archive records, and returns how
+// many jobs it deleted. The user's settings, tokens and API keys are kept;
+// their stored episodes are the caller's to delete.
+func (q *Queue) PurgeUserHistory(ctx context.Context, userID string) (int, error) {
+	if userID == "" {
+		return 0, ErrUserIDRequired
+	}
+	if q.client == nil {
+		return 0, fmt.Errorf("queue is not connected")
+	}
+
+	if err := q.backfillJobIndex(ctx, userID); err != nil {
+		return 0, err
+	}
+
+	// The deletes only go through if no job started and none was enqueued
+	// since the check, so a worker never writes a purged job back
+	var jobIDs []string
+	purge := func(tx *redis.Tx) error {
+		running, err := tx.SCard(ctx, q.userRunningKey(userID)).Result()
+		if err != nil {
+			return fmt.Errorf("failed to check running jobs: %w", err)
+		}
+		if running > 0 {
+			return ErrJobsRunning
+		}
+		jobIDs, err = tx.ZRange(ctx, q.userJobIndexKey(userID), 0, -1).Result()
+		if err != nil {
+			return fmt.Errorf("failed to get user jobs: %w", err)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, jobID := range jobIDs {
+				for _, key := range q.waitingQueues() {
+					pipe.ZRem(ctx, key, jobID)
+				}
+				pipe.ZRem(ctx, q.config.ScheduledSet, jobID)
+				pipe.SRem(ctx, q.config.SuccessSet, jobID)
+				pipe.SRem(ctx, q.config.FailedSet, jobID)
+				pipe.SRem(ctx, q.config.DeadLetterSet, jobID)
+				pipe.ZRem(ctx, q.config.CleanupSet, fmt.Sprintf("%s:%s", userID, jobID))
+				pipe.Del(ctx, q.jobKey(jobID), q.jobItemsKey(jobID), q.jobTimelineKey(jobID), q.jobLogKey(jobID), q.heartbeatKey(jobID))
+			}
+			pipe.Del(ctx,
+				q.userJobIndexKey(userID),
+				q.userJobsKey(userID),
+				q.userWaitingKey(userID),
+				q.userSuccessKey(userID),
+				q.userFailedKey(userID),
+				q.userPartialKey(userID),
+				q.archiveKey(userID),
+			)
+			pipe.SRem(ctx, q.feedOwnersKey(), userID)
+			return nil
+		})
+		return err
+	}
+	for range purgeAttempts {
+		err := q.client.Watch(ctx, purge, q.userRunningKey(userID), q.userJobIndexKey(userID))
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if errors.Is(err, ErrJobsRunning) {
+			return 0, err
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge user history: %w", err)
+		}
+		slog.InfoContext(ctx, "Purged user history", "user_id", userID, "jobs", len(jobIDs))
+		return len(jobIDs), nil
+	}
+	return 0, fmt.Errorf("failed to purge user history: too many concurrent changes")
+}
@@ -7,12 +7,14 @@ import (
 	"log/slog"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
-	"cobblepod/internal/config"
-
 	"errors"
 
+	"cobblepod/internal/config"
+	"cobblepod/internal/tracing"
+
 	"github.com/redis/go-redis/v9"
 )
 
@@ -22,9 +24,15 @@ var (
 )
 
 const (
-	// WaitingQueue is the Redis list key for job queue (stores IDs)
+	// WaitingQueue is the Redis sorted set key for job IDs waiting to run, scored by fair share
 	WaitingQueue = "cobblepod:waiting"
-	// RunningUsersKey is the Redis hash key for users with running jobs (UserID -> JobID)
+	// PriorityQueue is the Redis sorted set key for interactive jobs, drained before WaitingQueue
+	PriorityQueue = "cobblepod:waiting:priority"
+	// ScheduledSet is the Redis sorted set key for delayed job IDs, scored by run time
+	ScheduledSet = "cobblepod:scheduled"
+	// DeadLetterSet is the Redis set key for job IDs that failed for system reasons
+	DeadLetterSet = "cobblepod:dead-letter"
+	// RunningUsersKey is the Redis hash key for users with running jobs (UserID -> running job count)
 	RunningUsersKey = "cobblepod:running-users"
 	// RunningQueue is the Redis set key for running job IDs
 	RunningQueue = "cobblepod:running"
@@ -34,19 +42,20 @@ const (
 	FailedSet = "cobblepod:failed"
 	// CleanupSet is the Redis sorted set key for expiration tracking
 	CleanupSet = "cobblepod:cleanup"
-	// BlockTimeout is how long BRPOP will wait for a job
+	// BlockTimeout is how long BZPOPMIN will wait for a job
 	BlockTimeout = 5 * time.Second
-	// JobRetention is how long jobs are kept
-	JobRetention = 7 * 24 * time.Hour
 )
 
 // QueueConfig holds the Redis keys configuration
 type QueueConfig struct {
 	WaitingQueue    string
+	PriorityQueue   string
+	ScheduledSet    string
 	RunningUsersKey string
 	RunningQueue    string
 	SuccessSet      string
 	FailedSet       string
+	DeadLetterSet   string
 	CleanupSet      string
 	KeyPrefix       string
 }
@@ -55,15 +64,52 @@ type QueueConfig struct {
 func DefaultConfig() QueueConfig {
 	return QueueConfig{
 		WaitingQueue:    WaitingQueue,
+		PriorityQueue:   PriorityQueue,
+		ScheduledSet:    ScheduledSet,
 		RunningUsersKey: RunningUsersKey,
 		RunningQueue:    RunningQueue,
 		SuccessSet:      SuccessSet,
 		FailedSet:       FailedSet,
+		DeadLetterSet:   DeadLetterSet,
 		CleanupSet:      CleanupSet,
 		KeyPrefix:       "cobblepod",
 	}
 }
 
+// ClusterConfig returns a configuration whose keys all share the "{cobblepod}"
+// hash tag, so multi-key commands (SMOVE, scripts) stay on one cluster slot
+func ClusterConfig() QueueConfig {
+	prefix := "{cobblepod}"
+	return QueueConfig{
+		WaitingQueue:    prefix + ":waiting",
+		PriorityQueue:   prefix + ":waiting:priority",
+		ScheduledSet:    prefix + ":scheduled",
+		RunningUsersKey: prefix + ":running-users",
+		RunningQueue:    prefix + ":running",
+		SuccessSet:      prefix + ":success",
+		FailedSet:       prefix + ":failed",
+		DeadLetterSet:   prefix + ":dead-letter",
+		CleanupSet:      prefix + ":cleanup",
+		KeyPrefix:       prefix,
+	}
+}
+
+// Job statuses
+const (
+	JobStatusScheduled           = "scheduled" // waiting for its run time, see EnqueueAt
+	JobStatusQueued              = "queued"
+	JobStatusRunning             = "running"
+	JobStatusCompleted           = "completed"
+	JobStatusCompletedWithErrors = "completed_with_errors" // feed published, some items failed
+	JobStatusFailed              = "failed"
+)
+
+// Job priorities
+const (
+	PriorityBackground  = ""            // poll or notification triggered jobs
+	PriorityInteractive = "interactive" // explicit uploads through the HTTP API
+)
+
 // JobItemStatus represents the state of a single item
 type JobItemStatus string
 
@@ -84,68 +130,142 @@ type JobItem struct {
 	Status    JobItemStatus `json:"status"`
 	SourceURL string        `json:"source_url"`
 	Error     string        `json:"error,omitempty"`
-	Duration  time.Duration `json:"duration" swaggertype:"integer"`
-	Offset    time.Duration `json:"offset,omitempty" swaggertype:"integer"`
+	// Duration is the source's length as the playlist listed it and Offset the
+	// listening position to process it from; like every duration stored in
+	// Valkey, they are JSON nanoseconds
+	Duration time.Duration `json:"duration" swaggertype:"integer"`
+	Offset   time.Duration `json:"offset,omitempty" swaggertype:"integer"`
+	Progress int           `json:"progress,omitempty"` // Upload progress percentage while uploading
+	// DriveFileID is the storage key of the uploaded episode, persisted as soon as the upload succeeds
+	DriveFileID string `json:"drive_file_id,omitempty"`
+	// Speed and ContentType the upload was encoded with, so a resumed job only
+	// keeps uploads that match the user's current settings
+	Speed       float64 `json:"speed,omitempty"`
+	ContentType string  `json:"content_type,omitempty"`
+	// GUID and FeedURL identify the episode in its show's feed, when the source knows them
+	GUID    string `json:"guid,omitempty"`
+	FeedURL string `json:"feed_url,omitempty"`
+	// Position is the item's 1-based place in the source playlist; zero for
+	// items saved before positions were recorded
+	Position int `json:"position,omitempty"`
+	// Cuts are the parts of the source the user's skip rules left out
+	Cuts []Cut `json:"cuts,omitempty"`
+}
+
+// Cut is a part of an episode's source audio left out while processing it
+type Cut struct {
+	Start  time.Duration `json:"start" swaggertype:"integer"`
+	End    time.Duration `json:"end" swaggertype:"integer"`
+	Reason string        `json:"reason"` // "start", "end" or the title of the chapter
 }
 
 // Job represents a backup processing job
 type Job struct {
-	ID         string    `json:"id" redis:"id"`
-	FileID     string    `json:"file_id" redis:"file_id"`
-	UserID     string    `json:"user_id,omitempty" redis:"user_id"`
-	Filename   string    `json:"filename,omitempty" redis:"filename"`
-	CreatedAt  time.Time `json:"created_at" redis:"created_at"`
-	FailReason string    `json:"fail_reason,omitempty" redis:"fail_reason"` // Set when job fails
-	Status     string    `json:"status" redis:"status"`                     // queued, running, completed, failed
-	Items      []JobItem `json:"items" redis:"-"`                           // Items are stored in a separate hash
+	ID          string    `json:"id" redis:"id"`
+	FileID      string    `json:"file_id" redis:"file_id"`
+	UserID      string    `json:"user_id,omitempty" redis:"user_id"`
+	Filename    string    `json:"filename,omitempty" redis:"filename"`
+	Label       string    `json:"label,omitempty" redis:"label"` // User supplied note shown in listings
+	CreatedAt   time.Time `json:"created_at" redis:"created_at"`
+	FailReason  string    `json:"fail_reason,omitempty" redis:"fail_reason"`   // Set when job fails
+	FailedItems int       `json:"failed_items,omitempty" redis:"failed_items"` // Set when job completes with errors
+	Attempts    int       `json:"attempts,omitempty" redis:"attempts"`         // Times a worker has picked up the job
+	RetryOf     string    `json:"retry_of,omitempty" redis:"retry_of"`         // Job whose failed items this job retries
+	Status      string    `json:"status" redis:"status"`                       // scheduled, queued, running, completed, completed_with_errors, failed
+	RunAt       time.Time `json:"run_at,omitzero" redis:"run_at"`              // When a scheduled job becomes due
+	Priority    string    `json:"priority,omitempty" redis:"priority"`         // interactive jobs are dequeued first
+	TraceParent string    `json:"-" redis:"trace_parent"`                      // W3C trace context of the request that created the job
+	FeedURL     string    `json:"feed_url,omitempty" redis:"feed_url"`         // Subscription URL of the feed the job published
+	FairScore   int64     `json:"-" redis:"fair_score"`                        // Virtual start time ordering the job among waiting jobs, see FairShareUnit
+	Items       []JobItem `json:"items" redis:"-"`                             // Items are stored in a separate hash
+	LocalPath   string    `json:"-" redis:"-"`                                 // Source file on the local disk, for command line runs
+	PlaylistURL string    `json:"playlist_url,omitempty" redis:"playlist_url"` // External M3U8 playlist the job processes, see PlaylistURL
+	// RestoreFileID is the archived episode the job puts back in the feed, see ArchivedEpisode
+	RestoreFileID string `json:"restore_file_id,omitempty" redis:"restore_file_id"`
+	// IdempotencyKey is the submission key claimed for the job, see
+	// ClaimIdempotencyKey. It's released if the job fails, so resubmitting
+	// the same backup queues a new job rather than return the failed one.
+	IdempotencyKey string `json:"-" redis:"idempotency_key"`
+	// EstimatedCompletion is when the job should finish, from the jobs ahead of
+	// it and how fast recent runs went; zero when there's no history to go on
+	EstimatedCompletion time.Time `json:"estimated_completion,omitzero" redis:"estimated_completion"`
+	// Item counters, kept up to date as items change so listings needn't count Items
+	TotalItems int `json:"total_items" redis:"total_items"`
+	Completed  int `json:"completed" redis:"completed"`
+	Failed     int `json:"failed" redis:"failed"`
+	Skipped    int `json:"skipped" redis:"skipped"`
+	InProgress int `json:"in_progress" redis:"in_progress"`
 }
 
 // Queue manages the Redis job queue
 type Queue struct {
-	client *redis.Client
+	client redis.UniversalClient
 	config QueueConfig
+	// dequeueFailures counts consecutive failed BZPOPMINs to drive backoff
+	dequeueFailures atomic.Int64
+	// rater supplies the rates completion estimates use, see SetEncodeRater
+	rater EncodeRater
+	// worker holds the running slot, quota and retention settings
+	worker config.WorkerConfig
 }
 
-// NewQueue creates a new queue connection
-func NewQueue(ctx context.Context) (*Queue, error) {
-	addr := fmt.Sprintf("%s:%d", config.ValkeyHost, config.ValkeyPort)
-	slog.Debug("Connecting to Redis queue", "addr", addr)
+// NewQueue creates a new queue connection from the deployment's settings
+func NewQueue(ctx context.Context, cfg *config.Config) (*Queue, error) {
+	opts := RedisOptions(cfg.Valkey)
+	slog.DebugContext(ctx, "Connecting to Redis queue", "addrs", opts.Addrs, "master_name", opts.MasterName)
 
-	client := redis.NewClient(&redis.Options{
-		Addr:     addr,
-		Password: "", // Add to config if needed
-		DB:       0,
-	})
+	client := redis.NewUniversalClient(opts)
 
 	// Test the connection
 	_, err := client.Ping(ctx).Result()
 	if err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
-	slog.Info("Redis queue initialized", "addr", addr)
-	return &Queue{
+	queueConfig := DefaultConfig()
+	if isClusterMode(opts) {
+		queueConfig = ClusterConfig()
+	}
+
+	q := &Queue{
 		client: client,
-		config: DefaultConfig(),
-	}, nil
+		config: queueConfig,
+		worker: cfg.Worker,
+	}
+	if err := q.MigrateWaitingQueues(ctx); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Redis queue initialized", "addrs", opts.Addrs, "master_name", opts.MasterName, "cluster", isClusterMode(opts))
+	return q, nil
 }
 
 // NewQueueWithClient creates a queue with an existing Redis client (for testing)
-func NewQueueWithClient(client *redis.Client) *Queue {
+func NewQueueWithClient(client redis.UniversalClient) *Queue {
 	return &Queue{
 		client: client,
 		config: DefaultConfig(),
+		worker: config.Defaults().Worker,
 	}
 }
 
 // NewQueueWithConfig creates a queue with custom configuration (for testing)
-func NewQueueWithConfig(client *redis.Client, config QueueConfig) *Queue {
+func NewQueueWithConfig(client redis.UniversalClient, keys QueueConfig) *Queue {
 	return &Queue{
 		client: client,
-		config: config,
+		config: keys,
+		worker: config.Defaults().Worker,
 	}
 }
 
+// SetWorkerConfig replaces the running slot, quota and retention settings the
+// queue was created with
+func (q *Queue) SetWorkerConfig(cfg config.WorkerConfig) {
+	q.worker = cfg
+}
+
 // jobKey returns the Redis key for a job
 func (q *Queue) jobKey(jobID string) string {
 	return fmt.Sprintf("%s:job:%s", q.config.KeyPrefix, jobID)
@@ -178,13 +298,19 @@ func (q *Queue) userFailedKey(userID string) string {
 	return fmt.Sprintf("%s:user:%s:failed", q.config.KeyPrefix, userID)
 }
 
-// IsUserRunning checks if a user already has a running job
+// userPartialKey holds the subset of a user's completed jobs that finished
+// with failed items
+func (q *Queue) userPartialKey(userID string) string {
+	return fmt.Sprintf("%s:user:%s:partial", q.config.KeyPrefix, userID)
+}
+
+// IsUserRunning checks if a user has at least one running job
 func (q *Queue) IsUserRunning(ctx context.Context, userID string) (bool, error) {
 	if q.client == nil {
 		return false, fmt.Errorf("queue is not connected")
 	}
 
-	// Check if user exists in running hash
+	// Users leave the running hash when their last running job finishes
 	exists, err := q.client.HExists(ctx, q.config.RunningUsersKey, userID).Result()
 	if err != nil {
 		return false, fmt.Errorf("failed to check running users: %w", err)
@@ -193,202 +319,376 @@ func (q *Queue) IsUserRunning(ctx context.Context, userID string) (bool, error)
 	return exists, nil
 }
 
-// Enqueue adds a job to the queue
+// Enqueue adds a job to the queue. It returns ErrQuotaExceeded if the job
+// would take its user past their daily quotas.
 func (q *Queue) Enqueue(ctx context.Context, job *Job) error {
 	if q.client == nil {
 		return fmt.Errorf("queue is not connected")
 	}
+	if err := q.reserveJobQuota(ctx, job); err != nil {
+		return err
+	}
 
-	job.Status = "queued"
+	job.Status = JobStatusQueued
 	if job.CreatedAt.IsZero() {
 		job.CreatedAt = time.Now()
 	}
 
+	score, err := q.fairScore(ctx, job)
+	if err != nil {
+		q.releaseEnqueueCharges(ctx, job, nil)
+		return err
+	}
+	job.FairScore = score
+
+	// Estimate completion behind the jobs already waiting and the user's own
+	if q.rater != nil {
+		pos, err := q.queuePosition(ctx, job.UserID)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to get queue position for estimate", "error", err)
+		}
+		job.EstimatedCompletion = q.estimate(ctx, pos, job.Items)
+	}
+
 	pipe := q.client.Pipeline()
 
-	// 1. Store job data in Hash
+	// 1-3. Store the job, its items and the user's waiting entry
+	if err := q.storeJob(ctx, pipe, job); err != nil {
+		q.releaseEnqueueCharges(ctx, job, &score)
+		return err
+	}
+
+	// 4. Add ID to the Waiting Queue for its priority, in fair share order
+	pipe.ZAdd(ctx, q.waitingQueueFor(job.Priority), redis.Z{Score: float64(score), Member: job.ID})
+
+	// 5. Notify the user's event stream
+	q.publishEvent(ctx, pipe, job.UserID, Event{Type: EventJobCreated, JobID: job.ID, Status: job.Status})
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		q.releaseEnqueueCharges(ctx, job, &score)
+		return fmt.Errorf("failed to enqueue job: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Job enqueued", "job_id", job.ID, "file_id", job.FileID, "priority", job.Priority)
+	return nil
+}
+
+// releaseEnqueueCharges gives back what Enqueue charged a user for a job it
+// failed to store: its daily quota and, when score is set, the fair share
+// unit that got it that score
+func (q *Queue) releaseEnqueueCharges(ctx context.Context, job *Job, score *int64) {
+	ctx = context.WithoutCancel(ctx)
+	if err := q.releaseJobQuota(ctx, job); err != nil {
+		slog.WarnContext(ctx, "Failed to release quota of unstored job", "error", err, "job_id", job.ID)
+	}
+	if score == nil {
+		return
+	}
+	if err := q.refundFairScore(ctx, job, *score); err != nil {
+		slog.WarnContext(ctx, "Failed to refund fair share of unstored job", "error", err, "job_id", job.ID)
+	}
+}
+
+// storeJob queues the writes that record a new job on the pipeline: the job hash,
+// its items and the user's waiting set
+func (q *Queue) storeJob(ctx context.Context, pipe redis.Pipeliner, job *Job) error {
+	// Carry the caller's trace to the worker
+	if job.TraceParent == "" {
+		job.TraceParent = tracing.TraceParent(ctx)
+	}
+
+	// Store job data in Hash
+	job.CountItems()
 	pipe.HSet(ctx, q.jobKey(job.ID), job)
+	q.indexJobSearch(ctx, pipe, job.ID, job.Items)
 
-	// 2. Store items if any
-	if len(job.Items) > 0 {
-		for _, item := range job.Items {
-			itemJSON, err := json.Marshal(item)
-			if err != nil {
-				return fmt.Errorf("failed to marshal item: %w", err)
-			}
-			pipe.HSet(ctx, q.jobItemsKey(job.ID), item.ID, itemJSON)
+	// Store items if any
+	for _, item := range job.Items {
+		itemJSON, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("failed to marshal item: %w", err)
 		}
+		pipe.HSet(ctx, q.jobItemsKey(job.ID), item.ID, itemJSON)
 	}
 
-	// 3. Add to User's Waiting Set
+	// Add to User's Waiting Set and job index
 	if job.UserID != "" {
 		pipe.SAdd(ctx, q.userWaitingKey(job.UserID), job.ID)
+		q.indexJob(ctx, pipe, job)
 	}
+	return nil
+}
 
-	// 4. Push ID to Waiting Queue
-	pipe.LPush(ctx, q.config.WaitingQueue, job.ID)
-
-	_, err := pipe.Exec(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to enqueue job: %w", err)
+// waitingQueueFor returns the sorted set a job of the given priority waits in
+func (q *Queue) waitingQueueFor(priority string) string {
+	if priority == PriorityInteractive && q.config.PriorityQueue != "" {
+		return q.config.PriorityQueue
 	}
+	return q.config.WaitingQueue
+}
 
-	slog.Info("Job enqueued", "job_id", job.ID, "file_id", job.FileID)
-	return nil
+// waitingQueues returns the waiting sets in the order Dequeue drains them
+func (q *Queue) waitingQueues() []string {
+	if q.config.PriorityQueue == "" {
+		return []string{q.config.WaitingQueue}
+	}
+	return []string{q.config.PriorityQueue, q.config.WaitingQueue}
 }
 
-// Dequeue removes and returns a job from the queue
-// This blocks for up to BlockTimeout waiting for a job
+// Dequeue removes and returns a job from the queue, taking interactive jobs
+// before background ones and, within each, the job whose user has had the
+// least of the workers lately. This blocks for up to BlockTimeout waiting for a job
 func (q *Queue) Dequeue(ctx context.Context) (*Job, error) {
 	if q.client == nil {
 		return nil, fmt.Errorf("queue is not connected")
 	}
 
-	// Pop from right of list (BRPOP = blocking pop from end of queue)
-	// Returns [key, value] where value is the job ID
-	result, err := q.client.BRPop(ctx, BlockTimeout, q.config.WaitingQueue).Result()
+	// Pop the lowest fair share score (BZPOPMIN = blocking pop of the minimum)
+	// BZPOPMIN checks its keys in order, so the priority set always wins
+	result, err := q.client.BZPopMin(ctx, BlockTimeout, q.waitingQueues()...).Result()
 	if err != nil {
 		// redis.Nil means timeout (no job available)
 		if err == redis.Nil {
+			q.dequeueFailures.Store(0)
 			return nil, nil
 		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		// Redis is unreachable or failing over; back off so the worker loop doesn't
+		// spin, while the client reconnects to the new primary on the next attempt
+		failures := q.dequeueFailures.Add(1)
+		backoff := Jitter(dequeueBackoff(failures))
+		slog.WarnContext(ctx, "Dequeue failed, backing off", "error", err, "failures", failures, "backoff", backoff)
+		if waitErr := waitForRetry(ctx, backoff); waitErr != nil {
+			return nil, waitErr
+		}
 		return nil, fmt.Errorf("failed to dequeue job: %w", err)
 	}
+	q.dequeueFailures.Store(0)
 
-	if len(result) < 2 {
-		return nil, fmt.Errorf("invalid BRPOP result: %v", result)
+	jobID, ok := result.Member.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid BZPOPMIN result: %v", result)
 	}
+	// The job is off the waiting set now, so claim it even if ctx was cancelled
+	// meanwhile (e.g. a draining worker) rather than lose it
+	ctx = context.WithoutCancel(ctx)
 
-	jobID := result[1]
+	// Claim the job: until the worker finishes it, a missing heartbeat means the
+	// worker crashed and RecoverStalledJobs will hand the job to someone else
+	pipe := q.client.Pipeline()
+	pipe.SAdd(ctx, q.config.RunningQueue, jobID)
+	pipe.Set(ctx, q.heartbeatKey(jobID), time.Now().Unix(), HeartbeatTimeout)
+	pipe.HIncrBy(ctx, q.jobKey(jobID), "attempts", 1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.ErrorContext(ctx, "Failed to claim dequeued job", "error", err, "job_id", jobID)
+	}
 
-	return q.GetJob(ctx, jobID)
+	job, err := q.GetJob(ctx, jobID)
+	if err != nil || job == nil {
+		return job, err
+	}
+	// Changes notified from now on aren't seen by this job, so let them enqueue another
+	if err := q.ReleaseChangeJob(ctx, job.UserID, job.ID); err != nil && !errors.Is(err, ErrUserIDRequired) {
+		slog.WarnContext(ctx, "Failed to release change job", "error", err, "job_id", job.ID)
+	}
+	return job, nil
 }
 
-// StartJob marks a user as having a running job
-// Returns false if user already has a running job (conflict)
+// StartJob takes one of the user's MaxJobsPerUser running slots for a job.
+// Returns false if all of the user's slots are taken; the caller should DeferJob it.
 func (q *Queue) StartJob(ctx context.Context, userID string, jobID string) (bool, error) {
 	if q.client == nil {
 		return false, fmt.Errorf("queue is not connected")
 	}
 
-	// HSETNX returns true if field was set, false if it already existed
-	started, err := q.client.HSetNX(ctx, q.config.RunningUsersKey, userID, jobID).Result()
+	keys := []string{
+		q.config.RunningUsersKey,
+		q.userWaitingKey(userID),
+		q.userRunningKey(userID),
+		q.config.RunningQueue,
+		q.jobKey(jobID),
+	}
+	started, err := startUserJob.Run(ctx, q.client, keys, userID, jobID, q.worker.MaxJobsPerUser, JobStatusRunning).Int()
 	if err != nil {
 		return false, fmt.Errorf("failed to mark user as running: %w", err)
 	}
-
-	if started {
-		pipe := q.client.Pipeline()
-		// Update job status
-		pipe.HSet(ctx, q.jobKey(jobID), "status", "running")
-		// Add to running queue
-		pipe.SAdd(ctx, q.config.RunningQueue, jobID)
-		// Move from user waiting to user running
-		pipe.SMove(ctx, q.userWaitingKey(userID), q.userRunningKey(userID), jobID)
-		_, err := pipe.Exec(ctx)
-		if err != nil {
-			// If we fail here, we should probably try to undo the lock, but for now just log
-			slog.Error("Failed to update job status or add to running queue", "error", err, "job_id", jobID)
+	if started == 1 {
+		if err := q.PublishEvent(ctx, userID, Event{Type: EventJobStarted, JobID: jobID, Status: JobStatusRunning}); err != nil {
+			slog.ErrorContext(ctx, "Failed to publish job started event", "error", err, "job_id", jobID)
 		}
 	}
 
-	return started, nil
+	return started == 1, nil
 }
 
-// CompleteJob marks a job as complete and removes user from running set
+// CompleteJob marks a job as complete and frees its running slot. The
+// terminal status comes from the job's item outcomes: a job with failed items
+// finishes as completed_with_errors and records how many failed.
 func (q *Queue) CompleteJob(ctx context.Context, userID string, jobID string) error {
+	return q.completeJob(ctx, userID, jobID, 0)
+}
+
+// CompleteJobWithErrors marks a job whose feed was published but some of whose
+// items failed, and frees its running slot. failedItems is a floor for
+// callers that know of failures the item counters never saw.
+func (q *Queue) CompleteJobWithErrors(ctx context.Context, userID string, jobID string, failedItems int) error {
+	return q.completeJob(ctx, userID, jobID, max(failedItems, 1))
+}
+
+func (q *Queue) completeJob(ctx context.Context, userID string, jobID string, failedItems int) error {
 	if q.client == nil {
 		return fmt.Errorf("queue is not connected")
 	}
 
 	pipe := q.client.Pipeline()
 
-	// Remove user from running hash
-	pipe.HDel(ctx, q.config.RunningUsersKey, userID)
-
-	// Remove from running queue
 	if jobID != "" {
+		retention := q.jobRetention(ctx, userID)
+		completed, failed, err := q.finishedItems(ctx, jobID)
+		if err != nil {
+			return err
+		}
+		failedItems = max(failedItems, failed)
+		q.chargeEpisodes(ctx, pipe, userID, completed+failed)
+		status := JobStatusCompleted
+		if failedItems > 0 {
+			status = JobStatusCompletedWithErrors
+			pipe.SAdd(ctx, q.userPartialKey(userID), jobID)
+		}
+
+		// Give the user's running slot back; this also drops the job from the user's running set
+		releaseUserSlot.Eval(ctx, pipe, []string{q.userRunningKey(userID), q.config.RunningUsersKey}, userID, jobID)
+
+		// Remove from running queue
 		pipe.SRem(ctx, q.config.RunningQueue, jobID)
-	}
 
-	// Update job status
-	if jobID != "" {
-		pipe.HSet(ctx, q.jobKey(jobID), "status", "completed")
-		pipe.Expire(ctx, q.jobKey(jobID), JobRetention)
-		pipe.Expire(ctx, q.jobItemsKey(jobID), JobRetention)
+		// Update job status
+		pipe.HSet(ctx, q.jobKey(jobID), map[string]interface{}{
+			"status":       status,
+			"failed_items": failedItems,
+		})
+		pipe.Expire(ctx, q.jobKey(jobID), retention)
+		pipe.Expire(ctx, q.jobItemsKey(jobID), retention)
+		pipe.Del(ctx, q.heartbeatKey(jobID))
 		pipe.SAdd(ctx, q.config.SuccessSet, jobID)
-		// Move from user running to user success
-		pipe.SMove(ctx, q.userRunningKey(userID), q.userSuccessKey(userID), jobID)
+		pipe.SAdd(ctx, q.userSuccessKey(userID), jobID)
 		// Add to cleanup queue
 		pipe.ZAdd(ctx, q.config.CleanupSet, redis.Z{
-			Score:  float64(time.Now().Add(JobRetention).Unix()),
+			Score:  float64(time.Now().Add(retention).Unix()),
 			Member: fmt.Sprintf("%s:%s", userID, jobID),
 		})
+		q.publishEvent(ctx, pipe, userID, Event{Type: EventJobFinished, JobID: jobID, Status: status})
+		pipe.Expire(ctx, q.jobTimelineKey(jobID), retention)
+		pipe.Expire(ctx, q.jobLogKey(jobID), retention)
 	}
 
-	_, err := pipe.Exec(ctx)
-	if err != nil {
+	if _, err := pipe.Exec(ctx); err != nil {
 		return fmt.Errorf("failed to complete job: %w", err)
 	}
 
 	return nil
 }
 
+// jobRetention returns how long a user's finished jobs are kept. A user whose
+// settings can't be read gets the deployment's rather than a failed job.
+func (q *Queue) jobRetention(ctx context.Context, userID string) time.Duration {
+	settings, err := q.GetUserSettings(ctx, userID)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to get job retention, using the default", "error", err, "user_id", userID)
+		return q.worker.JobRetention()
+	}
+	return settings.JobRetention(q.worker.JobRetention())
+}
+
 // FailJob adds a job to the failed queue with a reason
 func (q *Queue) FailJob(ctx context.Context, job *Job, reason string) error {
+	return q.failJob(ctx, job, reason, false)
+}
+
+// failJob records a failed job; dead-lettered jobs are also kept in the
+// dead-letter set so an operator can requeue them with RequeueFailed
+func (q *Queue) failJob(ctx context.Context, job *Job, reason string, deadLetter bool) error {
 	if q.client == nil {
 		return fmt.Errorf("queue is not connected")
 	}
 
+	completed, failed, err := q.finishedItems(ctx, job.ID)
+	if err != nil {
+		return err
+	}
+
+	retention := q.jobRetention(ctx, job.UserID)
 	pipe := q.client.Pipeline()
+	q.chargeEpisodes(ctx, pipe, job.UserID, completed+failed)
+	// The job's encoding didn't produce episodes, so its quota goes back
+	if err := q.refundEncoding(ctx, pipe, job.ID); err != nil {
+		return err
+	}
 
 	// Update job status and reason
 	pipe.HSet(ctx, q.jobKey(job.ID), map[string]interface{}{
-		"status":      "failed",
+		"status":      JobStatusFailed,
 		"fail_reason": reason,
 	})
 
 	// Push ID to failed set
 	pipe.SAdd(ctx, q.config.FailedSet, job.ID)
-	pipe.Expire(ctx, q.jobKey(job.ID), JobRetention)
-	pipe.Expire(ctx, q.jobItemsKey(job.ID), JobRetention)
+	if deadLetter {
+		pipe.SAdd(ctx, q.config.DeadLetterSet, job.ID)
+	}
+	pipe.Expire(ctx, q.jobKey(job.ID), retention)
+	pipe.Expire(ctx, q.jobItemsKey(job.ID), retention)
 
-	// Move from user running (or waiting) to user failed
-	// We try removing from both and adding to failed to be safe
-	pipe.SRem(ctx, q.userRunningKey(job.UserID), job.ID)
+	// Move from user running (or waiting) to user failed. Releasing the slot
+	// removes the job from the running set, and only if this job held one
+	releaseUserSlot.Eval(ctx, pipe, []string{q.userRunningKey(job.UserID), q.config.RunningUsersKey}, job.UserID, job.ID)
 	pipe.SRem(ctx, q.userWaitingKey(job.UserID), job.ID)
 	pipe.SAdd(ctx, q.userFailedKey(job.UserID), job.ID)
+	if job.IdempotencyKey != "" {
+		compareAndDelete.Eval(ctx, pipe, []string{q.idempotencyKey(job.UserID, job.IdempotencyKey)}, job.ID)
+	}
 
 	// Add to cleanup queue
 	pipe.ZAdd(ctx, q.config.CleanupSet, redis.Z{
-		Score:  float64(time.Now().Add(JobRetention).Unix()),
+		Score:  float64(time.Now().Add(retention).Unix()),
 		Member: fmt.Sprintf("%s:%s", job.UserID, job.ID),
 	})
 
 	// Remove from running queue (if it was there)
 	pipe.SRem(ctx, q.config.RunningQueue, job.ID)
+	pipe.Del(ctx, q.heartbeatKey(job.ID))
 
-	_, err := pipe.Exec(ctx)
-	if err != nil {
+	q.publishEvent(ctx, pipe, job.UserID, Event{Type: EventJobFinished, JobID: job.ID, Status: JobStatusFailed, Message: reason})
+	pipe.Expire(ctx, q.jobTimelineKey(job.ID), retention)
+	pipe.Expire(ctx, q.jobLogKey(job.ID), retention)
+
+	if _, err := pipe.Exec(ctx); err != nil {
 		return fmt.Errorf("failed to add job to failed queue: %w", err)
 	}
 
-	slog.Warn("Job failed", "job_id", job.ID, "user_id", job.UserID, "reason", reason)
+	slog.WarnContext(ctx, "Job failed", "job_id", job.ID, "user_id", job.UserID, "reason", reason, "dead_letter", deadLetter)
 	return nil
 }
 
-// QueueLength returns the number of jobs in the queue
+// QueueLength returns the number of jobs in the queue across all priorities
 func (q *Queue) QueueLength(ctx context.Context) (int64, error) {
 	if q.client == nil {
 		return 0, fmt.Errorf("queue is not connected")
 	}
 
-	length, err := q.client.LLen(ctx, q.config.WaitingQueue).Result()
-	if err != nil {
-		return 0, fmt.Errorf("failed to get queue length: %w", err)
+	var total int64
+	for _, key := range q.waitingQueues() {
+		length, err := q.client.ZCard(ctx, key).Result()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get queue length: %w", err)
+		}
+		total += length
 	}
 
-	return length, nil
+	return total, nil
 }
 
 // GetJob retrieves a job by ID
@@ -397,17 +697,27 @@ func (q *Queue) GetJob(ctx context.Context, jobID string) (*Job, error) {
 		return nil, fmt.Errorf("queue is not connected")
 	}
 
+	pipe := q.client.Pipeline()
+	jobCmd := pipe.HGetAll(ctx, q.jobKey(jobID))
+	itemsCmd := pipe.HGetAll(ctx, q.jobItemsKey(jobID))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to fetch job: %w", err)
+	}
+	return hydrateJob(jobCmd, itemsCmd)
+}
+
+// hydrateJob builds a job from the replies to HGETALL on its hash and its items
+// hash, returning nil if the job doesn't exist
+func hydrateJob(jobCmd, itemsCmd *redis.MapStringStringCmd) (*Job, error) {
 	var job Job
-	err := q.client.HGetAll(ctx, q.jobKey(jobID)).Scan(&job)
-	if err != nil {
+	if err := jobCmd.Scan(&job); err != nil {
 		return nil, err
 	}
 	if job.ID == "" {
 		return nil, nil // Not found
 	}
 
-	// Fetch items
-	itemsMap, err := q.client.HGetAll(ctx, q.jobItemsKey(jobID)).Result()
+	itemsMap, err := itemsCmd.Result()
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch job items: %w", err)
 	}
@@ -421,14 +731,27 @@ func (q *Queue) GetJob(ctx context.Context, jobID string) (*Job, error) {
 		job.Items = append(job.Items, item)
 	}
 
-	// Sort items by Title to be deterministic
-	sort.Slice(job.Items, func(i, j int) bool {
-		return job.Items[i].Title < job.Items[j].Title
-	})
+	// Keep the playlist order; items without a position sort by title after them
+	SortItems(job.Items)
 
 	return &job, nil
 }
 
+// SortItems orders items by their position in the playlist. Items without a
+// position, saved before positions were recorded, follow in title order.
+func SortItems(items []JobItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if (a.Position == 0) != (b.Position == 0) {
+			return b.Position == 0
+		}
+		if a.Position != b.Position {
+			return a.Position < b.Position
+		}
+		return a.Title < b.Title
+	})
+}
+
 // GetUserJobs retrieves all jobs for a user
 func (q *Queue) GetUserJobs(ctx context.Context, userID string) ([]*Job, error) {
 	if q.client == nil {
@@ -450,7 +773,7 @@ func (q *Queue) GetUserJobs(ctx context.Context, userID string) ([]*Job, error)
 	for _, id := range jobIDs {
 		job, err := q.GetJob(ctx, id)
 		if err != nil {
-			slog.Error("Failed to fetch job", "job_id", id, "error", err)
+			slog.ErrorContext(ctx, "Failed to fetch job", "job_id", id, "error", err)
 			continue
 		}
 		if job != nil {
@@ -460,6 +783,14 @@ func (q *Queue) GetUserJobs(ctx context.Context, userID string) ([]*Job, error)
 	return jobs, nil
 }
 
+// Ping checks that Redis answers
+func (q *Queue) Ping(ctx context.Context) error {
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+	return q.client.Ping(ctx).Err()
+}
+
 // Close closes the queue connection
 func (q *Queue) Close() error {
 	if q.client != nil {
@@ -474,6 +805,13 @@ func (q *Queue) CleanupExpiredJobs(ctx context.Context) error {
 		return fmt.Errorf("queue is not connected")
 	}
 
+	// Hand jobs orphaned by crashed workers back to the queue first
+	if recovered, err := q.RecoverStalledJobs(ctx); err != nil {
+		slog.ErrorContext(ctx, "Failed to recover stalled jobs", "error", err)
+	} else if recovered > 0 {
+		slog.InfoContext(ctx, "Recovered stalled jobs", "count", recovered)
+	}
+
 	// Get expired items
 	now := float64(time.Now().Unix())
 	items, err := q.client.ZRangeByScore(ctx, q.config.CleanupSet, &redis.ZRangeBy{
@@ -488,7 +826,7 @@ func (q *Queue) CleanupExpiredJobs(ctx context.Context) error {
 		return nil
 	}
 
-	slog.Info("Cleaning up expired jobs", "count", len(items))
+	slog.InfoContext(ctx, "Cleaning up expired jobs", "count", len(items))
 
 	// Process in batches of 100 to avoid blocking
 	batchSize := 100
@@ -512,66 +850,51 @@ func (q *Queue) CleanupExpiredJobs(ctx context.Context) error {
 
 			pipe.SRem(ctx, q.config.SuccessSet, jobID)
 			pipe.SRem(ctx, q.config.FailedSet, jobID)
+			pipe.SRem(ctx, q.config.DeadLetterSet, jobID)
 			// Remove from all possible user sets
 			pipe.SRem(ctx, q.userWaitingKey(userID), jobID)
 			pipe.SRem(ctx, q.userRunningKey(userID), jobID)
 			pipe.SRem(ctx, q.userSuccessKey(userID), jobID)
 			pipe.SRem(ctx, q.userFailedKey(userID), jobID)
+			pipe.SRem(ctx, q.userPartialKey(userID), jobID)
+			pipe.ZRem(ctx, q.userJobIndexKey(userID), jobID)
 			pipe.ZRem(ctx, q.config.CleanupSet, item)
 			pipe.Del(ctx, q.jobKey(jobID))
 			pipe.Del(ctx, q.jobItemsKey(jobID))
+			pipe.Del(ctx, q.jobTimelineKey(jobID))
+			pipe.Del(ctx, q.jobLogKey(jobID))
 		}
 		_, err := pipe.Exec(ctx)
 		if err != nil {
-			slog.Error("Failed to cleanup batch", "error", err)
+			slog.ErrorContext(ctx, "Failed to cleanup batch", "error", err)
 		}
 	}
 
 	return nil
 }
 
-// SetJobItems replaces all items for a job
-func (q *Queue) SetJobItems(ctx context.Context, jobID string, items []JobItem) error {
-	if q.client == nil {
-		return fmt.Errorf("queue is not connected")
+// getJobsFromIDs retrieves multiple jobs by their IDs
+func (q *Queue) getJobsFromIDs(ctx context.Context, jobIDs []string) ([]*Job, error) {
+	if len(jobIDs) == 0 {
+		return nil, nil
 	}
 
+	// Fetch every job and its items in a single round trip
 	pipe := q.client.Pipeline()
-	pipe.Del(ctx, q.jobItemsKey(jobID)) // Clear existing items
-
-	for _, item := range items {
-		itemJSON, err := json.Marshal(item)
-		if err != nil {
-			return fmt.Errorf("failed to marshal item: %w", err)
-		}
-		pipe.HSet(ctx, q.jobItemsKey(jobID), item.ID, itemJSON)
-	}
-
-	_, err := pipe.Exec(ctx)
-	return err
-}
-
-// UpdateJobItem updates a single item in a job
-func (q *Queue) UpdateJobItem(ctx context.Context, jobID string, item JobItem) error {
-	if q.client == nil {
-		return fmt.Errorf("queue is not connected")
+	jobCmds := make([]*redis.MapStringStringCmd, len(jobIDs))
+	itemsCmds := make([]*redis.MapStringStringCmd, len(jobIDs))
+	for i, id := range jobIDs {
+		jobCmds[i] = pipe.HGetAll(ctx, q.jobKey(id))
+		itemsCmds[i] = pipe.HGetAll(ctx, q.jobItemsKey(id))
 	}
+	// Failures are reported per command below, so one bad job doesn't hide the rest
+	_, _ = pipe.Exec(ctx)
 
-	itemJSON, err := json.Marshal(item)
-	if err != nil {
-		return fmt.Errorf("failed to marshal item: %w", err)
-	}
-
-	return q.client.HSet(ctx, q.jobItemsKey(jobID), item.ID, itemJSON).Err()
-}
-
-// getJobsFromIDs retrieves multiple jobs by their IDs
-func (q *Queue) getJobsFromIDs(ctx context.Context, jobIDs []string) ([]*Job, error) {
 	var jobs []*Job
-	for _, id := range jobIDs {
-		job, err := q.GetJob(ctx, id)
+	for i, id := range jobIDs {
+		job, err := hydrateJob(jobCmds[i], itemsCmds[i])
 		if err != nil {
-			slog.Error("Failed to fetch job", "job_id", id, "error", err)
+			slog.ErrorContext(ctx, "Failed to fetch job", "job_id", id, "error", err)
 			continue
 		}
 		if job != nil {
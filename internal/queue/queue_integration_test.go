@@ -5,16 +5,39 @@ package queue
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"cobblepod/internal/auth"
+	"cobblepod/internal/config"
+	"cobblepod/internal/logging"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/oauth2"
 )
 
+// testConfig returns the settings the environment points the tests at
+func testConfig(t *testing.T) *config.Config {
+	cfg, err := config.FromEnv()
+	if err != nil {
+		t.Fatalf("Invalid test settings: %v", err)
+	}
+	return cfg
+}
+
 func setupTestQueue(t *testing.T) *Queue {
 	ctx := context.Background()
 
 	// Create a temporary client to check connection
-	tempQ, err := NewQueue(ctx)
+	tempQ, err := NewQueue(ctx, testConfig(t))
 	if err != nil {
 		t.Skipf("Skipping test: Redis not available: %v", err)
 		return nil
@@ -23,16 +46,30 @@ func setupTestQueue(t *testing.T) *Queue {
 
 	// Use unique keys for testing to avoid interference from background workers
 	suffix := time.Now().UnixNano()
-	config := DefaultConfig()
-	config.KeyPrefix = fmt.Sprintf("test:%d", suffix)
-	config.WaitingQueue = fmt.Sprintf("%s:waiting", config.KeyPrefix)
-	config.RunningUsersKey = fmt.Sprintf("%s:running-users", config.KeyPrefix)
-	config.RunningQueue = fmt.Sprintf("%s:running", config.KeyPrefix)
-	config.SuccessSet = fmt.Sprintf("%s:success", config.KeyPrefix)
-	config.FailedSet = fmt.Sprintf("%s:failed", config.KeyPrefix)
-	config.CleanupSet = fmt.Sprintf("%s:cleanup", config.KeyPrefix)
+	keys := DefaultConfig()
+	keys.KeyPrefix = fmt.Sprintf("test:%d", suffix)
+	keys.WaitingQueue = fmt.Sprintf("%s:waiting", keys.KeyPrefix)
+	keys.PriorityQueue = fmt.Sprintf("%s:waiting:priority", keys.KeyPrefix)
+	keys.ScheduledSet = fmt.Sprintf("%s:scheduled", keys.KeyPrefix)
+	keys.RunningUsersKey = fmt.Sprintf("%s:running-users", keys.KeyPrefix)
+	keys.RunningQueue = fmt.Sprintf("%s:running", keys.KeyPrefix)
+	keys.SuccessSet = fmt.Sprintf("%s:success", keys.KeyPrefix)
+	keys.FailedSet = fmt.Sprintf("%s:failed", keys.KeyPrefix)
+	keys.DeadLetterSet = fmt.Sprintf("%s:dead-letter", keys.KeyPrefix)
+	keys.CleanupSet = fmt.Sprintf("%s:cleanup", keys.KeyPrefix)
+
+	return NewQueueWithConfig(client, keys)
+}
 
-	return NewQueueWithConfig(client, config)
+// replicas returns n queues over their own connections and q's keys, as
+// separate worker replicas would use
+func replicas(t *testing.T, q *Queue, n int) []*Queue {
+	queues := make([]*Queue, n)
+	for i := range queues {
+		queues[i] = NewQueueWithConfig(redis.NewUniversalClient(RedisOptions(testConfig(t).Valkey)), q.config)
+		t.Cleanup(func() { queues[i].Close() })
+	}
+	return queues
 }
 
 // Integration test - only runs when Redis is available
@@ -88,6 +125,93 @@ func TestQueueEnqueueDequeue(t *testing.T) {
 	}
 }
 
+func TestQueueDequeuesInteractiveFirst(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	jobs := []*Job{
+		{ID: "background-1", UserID: "priority-user-1", CreatedAt: time.Now()},
+		{ID: "background-2", UserID: "priority-user-2", CreatedAt: time.Now()},
+		{ID: "interactive-1", UserID: "priority-user-3", CreatedAt: time.Now(), Priority: PriorityInteractive},
+	}
+	for _, job := range jobs {
+		if err := q.Enqueue(ctx, job); err != nil {
+			t.Fatalf("Failed to enqueue job: %v", err)
+		}
+	}
+
+	length, err := q.QueueLength(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get queue length: %v", err)
+	}
+	if length != 3 {
+		t.Errorf("Expected queue length 3, got %d", length)
+	}
+
+	for _, want := range []string{"interactive-1", "background-1", "background-2"} {
+		job, err := q.Dequeue(ctx)
+		if err != nil {
+			t.Fatalf("Failed to dequeue job: %v", err)
+		}
+		if job == nil || job.ID != want {
+			t.Fatalf("Expected job %s, got %v", want, job)
+		}
+	}
+}
+
+func TestQueueEnqueueAt(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	job := &Job{ID: "scheduled-job", UserID: "scheduled-user", CreatedAt: time.Now()}
+	if err := q.EnqueueAt(ctx, job, time.Now().Add(2*time.Second)); err != nil {
+		t.Fatalf("Failed to schedule job: %v", err)
+	}
+
+	// Not due yet
+	promoted, err := q.PromoteDueJobs(ctx)
+	if err != nil {
+		t.Fatalf("Failed to promote jobs: %v", err)
+	}
+	if promoted != 0 {
+		t.Errorf("Expected no jobs promoted before run time, got %d", promoted)
+	}
+	if length, _ := q.QueueLength(ctx); length != 0 {
+		t.Errorf("Expected empty waiting queue, got %d", length)
+	}
+
+	time.Sleep(3 * time.Second)
+
+	promoted, err = q.PromoteDueJobs(ctx)
+	if err != nil {
+		t.Fatalf("Failed to promote jobs: %v", err)
+	}
+	if promoted != 1 {
+		t.Fatalf("Expected 1 job promoted, got %d", promoted)
+	}
+
+	dequeued, err := q.Dequeue(ctx)
+	if err != nil {
+		t.Fatalf("Failed to dequeue job: %v", err)
+	}
+	if dequeued == nil || dequeued.ID != job.ID {
+		t.Fatalf("Expected job %s, got %v", job.ID, dequeued)
+	}
+	if dequeued.Status != JobStatusQueued {
+		t.Errorf("Expected status %s, got %s", JobStatusQueued, dequeued.Status)
+	}
+}
+
 func TestQueueLifecycle(t *testing.T) {
 	ctx := context.Background()
 
@@ -221,4 +345,1627 @@ func TestQueueLifecycle(t *testing.T) {
 	if len(running) != 0 {
 		t.Errorf("Expected running queue to be empty, got %v", running)
 	}
+
+	// Failing the job must release the running slot it held
+	isRunning, err := q.IsUserRunning(ctx, userID)
+	if err != nil {
+		t.Fatalf("Failed to check running user: %v", err)
+	}
+	if isRunning {
+		t.Error("Expected running slot to be released after job failed")
+	}
+}
+
+func TestQueueCompleteJobWithErrors(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	jobID := "partial-test-job"
+	userID := "partial-test-user"
+	job := &Job{
+		ID:        jobID,
+		FileID:    "file-789",
+		UserID:    userID,
+		CreatedAt: time.Now(),
+	}
+
+	if err := q.Enqueue(ctx, job); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if _, err := q.StartJob(ctx, userID, jobID); err != nil {
+		t.Fatalf("Failed to start job: %v", err)
+	}
+
+	if err := q.CompleteJobWithErrors(ctx, userID, jobID, 2); err != nil {
+		t.Fatalf("Failed to complete job with errors: %v", err)
+	}
+
+	completed, err := q.GetCompletedJobs(ctx, userID)
+	if err != nil {
+		t.Fatalf("Failed to get completed jobs: %v", err)
+	}
+	if len(completed) != 1 || completed[0].ID != jobID {
+		t.Fatalf("Expected job in completed queue, got %v", completed)
+	}
+	if completed[0].Status != JobStatusCompletedWithErrors {
+		t.Errorf("Expected status %s, got %s", JobStatusCompletedWithErrors, completed[0].Status)
+	}
+	if completed[0].FailedItems != 2 {
+		t.Errorf("Expected 2 failed items, got %d", completed[0].FailedItems)
+	}
+}
+
+func TestQueueCompleteJobDerivesStatusFromItems(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	jobID := "derived-status-job"
+	userID := "derived-status-user"
+	job := &Job{
+		ID:        jobID,
+		FileID:    "file-790",
+		UserID:    userID,
+		CreatedAt: time.Now(),
+	}
+
+	if err := q.Enqueue(ctx, job); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if _, err := q.StartJob(ctx, userID, jobID); err != nil {
+		t.Fatalf("Failed to start job: %v", err)
+	}
+	if err := q.SetJobItems(ctx, jobID, []JobItem{{ID: "a", Status: StatusPending}, {ID: "b", Status: StatusPending}}); err != nil {
+		t.Fatalf("Failed to set job items: %v", err)
+	}
+	if err := q.UpdateJobItem(ctx, jobID, JobItem{ID: "a", Status: StatusCompleted}); err != nil {
+		t.Fatalf("Failed to update job item: %v", err)
+	}
+	if err := q.UpdateJobItem(ctx, jobID, JobItem{ID: "b", Status: StatusFailed, Error: "download failed"}); err != nil {
+		t.Fatalf("Failed to update job item: %v", err)
+	}
+
+	if err := q.CompleteJob(ctx, userID, jobID); err != nil {
+		t.Fatalf("Failed to complete job: %v", err)
+	}
+
+	got, err := q.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if got.Status != JobStatusCompletedWithErrors {
+		t.Errorf("Expected status %s, got %s", JobStatusCompletedWithErrors, got.Status)
+	}
+	if got.FailedItems != 1 {
+		t.Errorf("Expected 1 failed item, got %d", got.FailedItems)
+	}
+
+	partial, _, err := q.ListUserJobs(ctx, userID, JobListOptions{State: JobStatePartial})
+	if err != nil {
+		t.Fatalf("Failed to list partial jobs: %v", err)
+	}
+	if len(partial) != 1 || partial[0].ID != jobID {
+		t.Errorf("Expected job in partial list, got %v", partial)
+	}
+}
+
+func TestQueueFairDequeue(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	// A user with a backlog doesn't hold up a user who enqueues after them
+	for _, id := range []string{"busy-1", "busy-2", "busy-3"} {
+		if err := q.Enqueue(ctx, &Job{ID: id, UserID: "busy-user"}); err != nil {
+			t.Fatalf("Failed to enqueue job: %v", err)
+		}
+	}
+	if err := q.Enqueue(ctx, &Job{ID: "light-1", UserID: "light-user"}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	var order []string
+	for range 4 {
+		job, err := q.Dequeue(ctx)
+		if err != nil || job == nil {
+			t.Fatalf("Failed to dequeue job: %v", err)
+		}
+		order = append(order, job.ID)
+	}
+	if got := strings.Join(order, ","); got != "busy-1,light-1,busy-2,busy-3" {
+		t.Errorf("Unexpected dequeue order %s", got)
+	}
+}
+
+func TestQueueJobsPerDayQuota(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	cfg := config.Defaults().Worker
+	cfg.MaxJobsPerDay = 1
+	q.SetWorkerConfig(cfg)
+
+	if err := q.Enqueue(ctx, &Job{ID: "quota-1", UserID: "quota-user"}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if err := q.Enqueue(ctx, &Job{ID: "quota-2", UserID: "quota-user"}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded, got %v", err)
+	}
+	if err := q.Enqueue(ctx, &Job{ID: "quota-3", UserID: "other-user"}); err != nil {
+		t.Errorf("Expected another user's job to be accepted, got %v", err)
+	}
+}
+
+func TestQueueEncodingReservation(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	cfg := config.Defaults().Worker
+	cfg.MaxMinutesPerDay = 60
+	q.SetWorkerConfig(cfg)
+
+	job := &Job{ID: "reserve-1", UserID: "reserve-user"}
+	if err := q.Enqueue(ctx, job); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if err := q.ReserveEncoding(ctx, job.UserID, job.ID, 1, 40*time.Minute); err != nil {
+		t.Fatalf("Failed to reserve encoding: %v", err)
+	}
+	// A resumed run reserves the same audio again without being charged twice
+	if err := q.ReserveEncoding(ctx, job.UserID, job.ID, 1, 40*time.Minute); err != nil {
+		t.Errorf("Expected a resumed job's reservation to be kept, got %v", err)
+	}
+	if err := q.ReserveEncoding(ctx, job.UserID, "reserve-2", 1, 30*time.Minute); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded, got %v", err)
+	}
+
+	// Failing the job gives its audio back
+	if err := q.FailJob(ctx, job, "boom"); err != nil {
+		t.Fatalf("Failed to fail job: %v", err)
+	}
+	if err := q.ReserveEncoding(ctx, job.UserID, "reserve-2", 1, 30*time.Minute); err != nil {
+		t.Errorf("Expected the failed job's quota to be refunded, got %v", err)
+	}
+}
+
+func TestQueueJobItemCounters(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	job := &Job{ID: "counters-job", UserID: "counters-user", CreatedAt: time.Now()}
+	if err := q.Enqueue(ctx, job); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	items := []JobItem{
+		{ID: "a", Status: StatusPending},
+		{ID: "b", Status: StatusPending},
+		{ID: "c", Status: StatusSkipped},
+	}
+	if err := q.SetJobItems(ctx, job.ID, items); err != nil {
+		t.Fatalf("Failed to set job items: %v", err)
+	}
+
+	updates := []JobItem{
+		{ID: "a", Status: StatusDownloading},
+		{ID: "a", Status: StatusProcessing},
+		{ID: "b", Status: StatusDownloading},
+		{ID: "a", Status: StatusCompleted},
+		{ID: "b", Status: StatusFailed},
+		{ID: "d", Status: StatusUploading}, // added mid-run
+	}
+	for _, item := range updates {
+		if err := q.UpdateJobItem(ctx, job.ID, item); err != nil {
+			t.Fatalf("Failed to update item: %v", err)
+		}
+	}
+
+	got, err := q.GetJob(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if got.TotalItems != 4 || got.Completed != 1 || got.Failed != 1 || got.Skipped != 1 || got.InProgress != 1 {
+		t.Errorf("Unexpected counters: total=%d completed=%d failed=%d skipped=%d in_progress=%d",
+			got.TotalItems, got.Completed, got.Failed, got.Skipped, got.InProgress)
+	}
+}
+
+func TestQueuePublishesItemTransitions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	job := &Job{ID: "events-job", UserID: "events-user", CreatedAt: time.Now()}
+	if err := q.Enqueue(ctx, job); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if err := q.SetJobItems(ctx, job.ID, []JobItem{{ID: "a", Status: StatusPending}}); err != nil {
+		t.Fatalf("Failed to set job items: %v", err)
+	}
+
+	events, err := q.SubscribeEvents(ctx, job.UserID)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	// Only the first update changes the status; progress within a status is not published
+	for _, item := range []JobItem{{ID: "a", Status: StatusDownloading}, {ID: "a", Status: StatusDownloading}, {ID: "a", Status: StatusCompleted}} {
+		if err := q.UpdateJobItem(ctx, job.ID, item); err != nil {
+			t.Fatalf("Failed to update item: %v", err)
+		}
+	}
+
+	for _, want := range []JobItemStatus{StatusDownloading, StatusCompleted} {
+		select {
+		case event := <-events:
+			if event.Type != EventItemUpdated || event.ItemID != "a" || event.Status != string(want) {
+				t.Errorf("Expected item.updated to %s, got %+v", want, event)
+			}
+		case <-ctx.Done():
+			t.Fatalf("Timed out waiting for %s event", want)
+		}
+	}
+}
+
+func TestQueueConcurrentItemUpdates(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	const itemCount = 20
+	job := &Job{ID: "concurrent-job", UserID: "concurrent-user", CreatedAt: time.Now()}
+	if err := q.Enqueue(ctx, job); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	items := make([]JobItem, itemCount)
+	for i := range items {
+		items[i] = JobItem{ID: fmt.Sprintf("item-%d", i), Status: StatusPending}
+	}
+	if err := q.SetJobItems(ctx, job.ID, items); err != nil {
+		t.Fatalf("Failed to set job items: %v", err)
+	}
+	events, err := q.SubscribeEvents(ctx, job.UserID)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	// Each item goes through the worker pipeline on its own goroutine
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			final := StatusCompleted
+			if i%4 == 0 {
+				final = StatusFailed
+			}
+			for _, status := range []JobItemStatus{StatusDownloading, StatusProcessing, StatusUploading, final} {
+				item.Status = status
+				if err := q.UpdateJobItem(ctx, job.ID, item); err != nil {
+					t.Errorf("Failed to update item: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := q.GetJob(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if got.TotalItems != itemCount || got.Completed != 15 || got.Failed != 5 || got.InProgress != 0 {
+		t.Errorf("Unexpected counters: total=%d completed=%d failed=%d in_progress=%d",
+			got.TotalItems, got.Completed, got.Failed, got.InProgress)
+	}
+
+	// Every transition is published, and each item's in the order it was stored
+	stage := map[string]int{string(StatusDownloading): 0, string(StatusProcessing): 1, string(StatusUploading): 2, string(StatusCompleted): 3, string(StatusFailed): 3}
+	seen := map[string]int{}
+	for i := range itemCount * 4 {
+		select {
+		case event := <-events:
+			if stage[event.Status] != seen[event.ItemID] {
+				t.Errorf("Item %s published %s out of order", event.ItemID, event.Status)
+			}
+			seen[event.ItemID]++
+		case <-ctx.Done():
+			t.Fatalf("Timed out after %d item events", i)
+		}
+	}
+	timeline, err := q.JobTimeline(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("JobTimeline failed: %v", err)
+	}
+	if len(timeline) != 1+itemCount*4 {
+		t.Errorf("Expected %d timeline events, got %d", 1+itemCount*4, len(timeline))
+	}
+}
+
+func TestQueueJobTimeline(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	job := &Job{ID: "timeline-job", UserID: "timeline-user", CreatedAt: time.Now()}
+	if err := q.Enqueue(ctx, job); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if err := q.SetJobItems(ctx, job.ID, []JobItem{{ID: "a", Status: StatusPending}}); err != nil {
+		t.Fatalf("Failed to set job items: %v", err)
+	}
+	if err := q.UpdateJobItem(ctx, job.ID, JobItem{ID: "a", Status: StatusFailed, Error: "FFmpeg error"}); err != nil {
+		t.Fatalf("Failed to update item: %v", err)
+	}
+	if err := q.FailJob(ctx, job, "all 1 items failed"); err != nil {
+		t.Fatalf("Failed to fail job: %v", err)
+	}
+
+	events, err := q.JobTimeline(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("JobTimeline failed: %v", err)
+	}
+	wantTypes := []EventType{EventJobCreated, EventItemUpdated, EventJobFinished}
+	if len(events) != len(wantTypes) {
+		t.Fatalf("Expected %d events, got %+v", len(wantTypes), events)
+	}
+	for i, want := range wantTypes {
+		if events[i].Type != want {
+			t.Errorf("Event %d: expected %s, got %s", i, want, events[i].Type)
+		}
+	}
+	if events[1].Message != "FFmpeg error" || events[2].Message != "all 1 items failed" {
+		t.Errorf("Expected failure messages on the timeline, got %+v", events)
+	}
+}
+
+func TestQueueJobLogs(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	jobID := "logs-job"
+	for _, message := range []string{"Processing job", "Job completed successfully"} {
+		line := logging.Line{Time: time.Now(), Level: "INFO", Message: message, Attrs: map[string]string{"file_id": "file-1"}}
+		if err := q.AppendJobLog(ctx, jobID, line); err != nil {
+			t.Fatalf("AppendJobLog failed: %v", err)
+		}
+	}
+
+	lines, err := q.JobLogs(ctx, jobID)
+	if err != nil {
+		t.Fatalf("JobLogs failed: %v", err)
+	}
+	if len(lines) != 2 || lines[0].Message != "Processing job" || lines[1].Message != "Job completed successfully" {
+		t.Fatalf("Expected both lines in order, got %+v", lines)
+	}
+	if lines[0].Attrs["file_id"] != "file-1" {
+		t.Errorf("Expected the line's attributes, got %+v", lines[0].Attrs)
+	}
+
+	// A line captured after the job finished keeps the retention it set
+	if err := q.client.HSet(ctx, q.jobKey(jobID), "status", JobStatusCompleted).Err(); err != nil {
+		t.Fatal(err)
+	}
+	q.client.Expire(ctx, q.jobKey(jobID), time.Hour)
+	q.client.Expire(ctx, q.jobLogKey(jobID), time.Hour)
+	line := logging.Line{Time: time.Now(), Level: "INFO", Message: "Late line"}
+	if err := q.AppendJobLog(ctx, jobID, line); err != nil {
+		t.Fatalf("AppendJobLog failed: %v", err)
+	}
+	if ttl := q.client.TTL(ctx, q.jobLogKey(jobID)).Val(); ttl > time.Hour {
+		t.Errorf("Expected the finished job's retention to be kept, got %v", ttl)
+	}
+}
+
+func TestQueueIdempotencyKeys(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	userID := "idempotent-user"
+	existing, err := q.ClaimIdempotencyKey(ctx, userID, "key:upload-1", "job-1")
+	if err != nil || existing != "" {
+		t.Fatalf("Expected first claim to succeed, got %q, %v", existing, err)
+	}
+	existing, err = q.ClaimIdempotencyKey(ctx, userID, "key:upload-1", "job-2")
+	if err != nil || existing != "job-1" {
+		t.Fatalf("Expected repeat claim to return job-1, got %q, %v", existing, err)
+	}
+	if existing, _ := q.ClaimIdempotencyKey(ctx, "other-user", "key:upload-1", "job-3"); existing != "" {
+		t.Errorf("Expected keys to be scoped per user, got %q", existing)
+	}
+
+	// Only the claimant can release a key
+	if err := q.ReleaseIdempotencyKey(ctx, userID, "key:upload-1", "job-2"); err != nil {
+		t.Fatalf("Failed to release key: %v", err)
+	}
+	if existing, _ := q.ClaimIdempotencyKey(ctx, userID, "key:upload-1", "job-2"); existing != "job-1" {
+		t.Errorf("Expected key to still belong to job-1, got %q", existing)
+	}
+	if err := q.ReleaseIdempotencyKey(ctx, userID, "key:upload-1", "job-1"); err != nil {
+		t.Fatalf("Failed to release key: %v", err)
+	}
+	if existing, _ := q.ClaimIdempotencyKey(ctx, userID, "key:upload-1", "job-2"); existing != "" {
+		t.Errorf("Expected released key to be claimable, got %q", existing)
+	}
+
+	// A failed job releases its key
+	job := &Job{ID: "job-2", UserID: userID, CreatedAt: time.Now(), IdempotencyKey: "key:upload-1"}
+	if err := q.Enqueue(ctx, job); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if err := q.FailJob(ctx, job, "boom"); err != nil {
+		t.Fatalf("Failed to fail job: %v", err)
+	}
+	if existing, _ := q.ClaimIdempotencyKey(ctx, userID, "key:upload-1", "job-3"); existing != "" {
+		t.Errorf("Expected the failed job's key to be claimable, got %q", existing)
+	}
+}
+
+func TestQueueChangeJob(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	userID := "change-user"
+	if claimed, err := q.ClaimChangeJob(ctx, userID, "change-1", time.Now()); err != nil || !claimed {
+		t.Fatalf("Expected first claim to succeed, got %v, %v", claimed, err)
+	}
+	if err := q.Enqueue(ctx, &Job{ID: "change-1", UserID: userID}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+
+	// Further notifications find the waiting job
+	if claimed, _ := q.ClaimChangeJob(ctx, userID, "change-2", time.Now()); claimed {
+		t.Error("Expected a second change job to be refused while the first waits")
+	}
+
+	// Once it is dequeued, a change needs a follow-up job
+	job, err := q.Dequeue(ctx)
+	if err != nil || job == nil || job.ID != "change-1" {
+		t.Fatalf("Expected change-1 dequeued, got %+v, %v", job, err)
+	}
+	if claimed, _ := q.ClaimChangeJob(ctx, userID, "change-2", time.Now()); !claimed {
+		t.Error("Expected a follow-up change job once the first started")
+	}
+}
+
+func TestQueueListUserJobs(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	userID := "list-user"
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i := 0; i < 5; i++ {
+		job := &Job{
+			ID:        fmt.Sprintf("list-job-%d", i),
+			UserID:    userID,
+			Filename:  fmt.Sprintf("backup-%d.backup", i),
+			Label:     fmt.Sprintf("run %d", i),
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		}
+		if err := q.Enqueue(ctx, job); err != nil {
+			t.Fatalf("Failed to enqueue job: %v", err)
+		}
+	}
+	if err := q.FailJob(ctx, &Job{ID: "list-job-1", UserID: userID}, "boom"); err != nil {
+		t.Fatalf("Failed to fail job: %v", err)
+	}
+	if err := q.SetJobItems(ctx, "list-job-3", []JobItem{{ID: "item-1", Title: "Hard Fork: AI Week"}}); err != nil {
+		t.Fatalf("Failed to set job items: %v", err)
+	}
+
+	ids := func(jobs []*Job) []string {
+		var out []string
+		for _, job := range jobs {
+			out = append(out, job.ID)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name      string
+		opts      JobListOptions
+		wantIDs   []string
+		wantTotal int
+	}{
+		{"newest first", JobListOptions{Limit: 2}, []string{"list-job-4", "list-job-3"}, 5},
+		{"second page", JobListOptions{Limit: 2, Offset: 2}, []string{"list-job-2", "list-job-1"}, 5},
+		{"oldest first", JobListOptions{Ascending: true, Limit: 1}, []string{"list-job-0"}, 5},
+		{"active only", JobListOptions{State: JobStateActive, Ascending: true}, []string{"list-job-0", "list-job-2", "list-job-3", "list-job-4"}, 4},
+		{"failed only", JobListOptions{State: JobStateFailed}, []string{"list-job-1"}, 1},
+		{"date range", JobListOptions{Since: base.Add(time.Minute), Until: base.Add(2 * time.Minute), Ascending: true}, []string{"list-job-1", "list-job-2"}, 2},
+		{"label", JobListOptions{Label: "RUN 3"}, []string{"list-job-3"}, 1},
+		{"search episode title", JobListOptions{Query: "hard FORK"}, []string{"list-job-3"}, 1},
+		{"search filename", JobListOptions{Query: "backup-2"}, []string{"list-job-2"}, 1},
+		{"search status", JobListOptions{Query: "fail"}, []string{"list-job-1"}, 1},
+		{"past the end", JobListOptions{Offset: 10}, nil, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs, total, err := q.ListUserJobs(ctx, userID, tt.opts)
+			if err != nil {
+				t.Fatalf("Failed to list jobs: %v", err)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("Expected total %d, got %d", tt.wantTotal, total)
+			}
+			if got := ids(jobs); fmt.Sprint(got) != fmt.Sprint(tt.wantIDs) {
+				t.Errorf("Expected jobs %v, got %v", tt.wantIDs, got)
+			}
+		})
+	}
+}
+
+func TestQueueGetJobsFromIDs(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	for i := 0; i < 3; i++ {
+		job := &Job{ID: fmt.Sprintf("batch-job-%d", i), UserID: "batch-user", CreatedAt: time.Now()}
+		if err := q.Enqueue(ctx, job); err != nil {
+			t.Fatalf("Failed to enqueue job: %v", err)
+		}
+		items := []JobItem{{ID: "b", Title: "Second"}, {ID: "a", Title: "First"}}
+		if err := q.SetJobItems(ctx, job.ID, items); err != nil {
+			t.Fatalf("Failed to set job items: %v", err)
+		}
+	}
+
+	jobs, err := q.getJobsFromIDs(ctx, []string{"batch-job-2", "missing-job", "batch-job-0"})
+	if err != nil {
+		t.Fatalf("getJobsFromIDs failed: %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != "batch-job-2" || jobs[1].ID != "batch-job-0" {
+		t.Fatalf("Expected the two existing jobs in order, got %v", jobs)
+	}
+	for _, job := range jobs {
+		if len(job.Items) != 2 || job.Items[0].Title != "First" || job.TotalItems != 2 {
+			t.Errorf("Expected job %s to be hydrated with sorted items, got %+v", job.ID, job)
+		}
+	}
+}
+
+func TestQueueRequeueFailed(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	userID := "dead-letter-user"
+	job := &Job{ID: "dead-letter-job", FileID: "file-dl", UserID: userID, CreatedAt: time.Now()}
+	if err := q.Enqueue(ctx, job); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if _, err := q.Dequeue(ctx); err != nil {
+		t.Fatalf("Failed to dequeue job: %v", err)
+	}
+	if err := q.DeadLetterJob(ctx, job, "Failed to acquire user lock"); err != nil {
+		t.Fatalf("Failed to dead-letter job: %v", err)
+	}
+
+	requeued, err := q.RequeueFailed(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("Failed to requeue job: %v", err)
+	}
+	if requeued.Status != JobStatusQueued || requeued.FailReason != "" {
+		t.Errorf("Expected requeued job to be queued without a fail reason, got %+v", requeued)
+	}
+
+	failed, err := q.GetFailedJobs(ctx, userID)
+	if err != nil {
+		t.Fatalf("Failed to get failed jobs: %v", err)
+	}
+	if len(failed) != 0 {
+		t.Errorf("Expected no failed jobs after requeue, got %v", failed)
+	}
+
+	dequeued, err := q.Dequeue(ctx)
+	if err != nil {
+		t.Fatalf("Failed to dequeue job: %v", err)
+	}
+	if dequeued == nil || dequeued.ID != job.ID {
+		t.Fatalf("Expected requeued job to be dequeued, got %v", dequeued)
+	}
+
+	// A second requeue finds nothing to re-drive
+	if _, err := q.RequeueFailed(ctx, job.ID); !errors.Is(err, ErrJobNotDeadLettered) {
+		t.Errorf("Expected ErrJobNotDeadLettered, got %v", err)
+	}
+}
+
+func TestQueueReleaseJob(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	userID := "draining-user"
+	job := &Job{ID: "draining-job", FileID: "file-draining", UserID: userID, CreatedAt: time.Now()}
+	if err := q.Enqueue(ctx, job); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if _, err := q.Dequeue(ctx); err != nil {
+		t.Fatalf("Failed to dequeue job: %v", err)
+	}
+	if _, err := q.StartJob(ctx, userID, job.ID); err != nil {
+		t.Fatalf("Failed to start job: %v", err)
+	}
+
+	// The worker shuts down mid-job while its heartbeat is still alive
+	if err := q.ReleaseJob(ctx, job); err != nil {
+		t.Fatalf("Failed to release job: %v", err)
+	}
+	if isRunning, _ := q.IsUserRunning(ctx, userID); isRunning {
+		t.Error("Expected released job's running slot to be freed")
+	}
+
+	dequeued, err := q.Dequeue(ctx)
+	if err != nil {
+		t.Fatalf("Failed to dequeue job: %v", err)
+	}
+	if dequeued == nil || dequeued.ID != job.ID {
+		t.Fatalf("Expected released job to be dequeued again, got %v", dequeued)
+	}
+	if dequeued.Attempts != 1 {
+		t.Errorf("Expected the interrupted attempt not to count, got %d attempts", dequeued.Attempts)
+	}
+}
+
+func TestQueueRecoverStalledJobs(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	userID := "stalled-user"
+	job := &Job{ID: "stalled-job", FileID: "file-stalled", UserID: userID, CreatedAt: time.Now()}
+	if err := q.Enqueue(ctx, job); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	if _, err := q.Dequeue(ctx); err != nil {
+		t.Fatalf("Failed to dequeue job: %v", err)
+	}
+	if _, err := q.StartJob(ctx, userID, job.ID); err != nil {
+		t.Fatalf("Failed to start job: %v", err)
+	}
+
+	// A live worker keeps the job
+	recovered, err := q.RecoverStalledJobs(ctx)
+	if err != nil {
+		t.Fatalf("Failed to recover stalled jobs: %v", err)
+	}
+	if recovered != 0 {
+		t.Errorf("Expected no jobs recovered while heartbeat is alive, got %d", recovered)
+	}
+
+	// Simulate the worker crashing
+	if err := q.client.Del(ctx, q.heartbeatKey(job.ID)).Err(); err != nil {
+		t.Fatalf("Failed to delete heartbeat: %v", err)
+	}
+
+	recovered, err = q.RecoverStalledJobs(ctx)
+	if err != nil {
+		t.Fatalf("Failed to recover stalled jobs: %v", err)
+	}
+	if recovered != 1 {
+		t.Fatalf("Expected 1 job recovered, got %d", recovered)
+	}
+
+	isRunning, err := q.IsUserRunning(ctx, userID)
+	if err != nil {
+		t.Fatalf("Failed to check running user: %v", err)
+	}
+	if isRunning {
+		t.Error("Expected stalled job's running slot to be released")
+	}
+
+	dequeued, err := q.Dequeue(ctx)
+	if err != nil {
+		t.Fatalf("Failed to dequeue job: %v", err)
+	}
+	if dequeued == nil || dequeued.ID != job.ID {
+		t.Fatalf("Expected stalled job to be dequeued again, got %v", dequeued)
+	}
+	if dequeued.Attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", dequeued.Attempts)
+	}
+}
+
+func TestQueueStartJobLimitsRunningSlots(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	userID := "slots-user"
+	var jobs []*Job
+	for i := 0; i <= q.worker.MaxJobsPerUser; i++ {
+		job := &Job{ID: fmt.Sprintf("slots-job-%d", i), UserID: userID, CreatedAt: time.Now()}
+		if err := q.Enqueue(ctx, job); err != nil {
+			t.Fatalf("Failed to enqueue job: %v", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	for i, job := range jobs {
+		started, err := q.StartJob(ctx, userID, job.ID)
+		if err != nil {
+			t.Fatalf("Failed to start job: %v", err)
+		}
+		if want := i < q.worker.MaxJobsPerUser; started != want {
+			t.Errorf("StartJob(%s) = %v, want %v", job.ID, started, want)
+		}
+	}
+
+	// Finishing a job frees its slot for the one that was turned away
+	if err := q.CompleteJob(ctx, userID, jobs[0].ID); err != nil {
+		t.Fatalf("Failed to complete job: %v", err)
+	}
+	last := jobs[len(jobs)-1]
+	started, err := q.StartJob(ctx, userID, last.ID)
+	if err != nil {
+		t.Fatalf("Failed to start job: %v", err)
+	}
+	if !started {
+		t.Error("Expected StartJob to succeed once a slot was freed")
+	}
+
+	// Completing the same job twice must not free a second slot
+	if err := q.CompleteJob(ctx, userID, jobs[0].ID); err != nil {
+		t.Fatalf("Failed to complete job: %v", err)
+	}
+	count, err := q.client.HGet(ctx, q.config.RunningUsersKey, userID).Int()
+	if err != nil {
+		t.Fatalf("Failed to read running count: %v", err)
+	}
+	if count != q.worker.MaxJobsPerUser {
+		t.Errorf("Expected %d running slots taken, got %d", q.worker.MaxJobsPerUser, count)
+	}
+}
+
+func TestQueueLockFeed(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	unlock, err := q.LockFeed(ctx, "feed-lock-user")
+	if err != nil {
+		t.Fatalf("Failed to lock feed: %v", err)
+	}
+
+	// A second writer waits for the lock
+	waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if _, err := q.LockFeed(waitCtx, "feed-lock-user"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected second lock to wait until the deadline, got %v", err)
+	}
+
+	// Other users' feeds are independent
+	unlockOther, err := q.LockFeed(ctx, "feed-lock-other-user")
+	if err != nil {
+		t.Fatalf("Failed to lock another user's feed: %v", err)
+	}
+	unlockOther()
+
+	unlock()
+	unlock, err = q.LockFeed(ctx, "feed-lock-user")
+	if err != nil {
+		t.Fatalf("Failed to lock feed after release: %v", err)
+	}
+	unlock()
+}
+
+func TestQueueReplicasShareQueue(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	const users, jobsPerUser = 3, 4
+	for u := 0; u < users; u++ {
+		for j := 0; j < jobsPerUser; j++ {
+			job := &Job{ID: fmt.Sprintf("replica-job-%d-%d", u, j), UserID: fmt.Sprintf("replica-user-%d", u)}
+			if err := q.Enqueue(ctx, job); err != nil {
+				t.Fatalf("Failed to enqueue job: %v", err)
+			}
+		}
+	}
+
+	// Every replica races for every job; each job must be handed out once and
+	// either started or deferred, never failed
+	var mu sync.Mutex
+	dequeued := make(map[string]bool)
+	started := make(map[string]int)
+	var wg sync.WaitGroup
+	for _, replica := range replicas(t, q, 6) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job, err := replica.Dequeue(ctx)
+				if err != nil || job == nil {
+					return
+				}
+				ok, err := replica.StartJob(ctx, job.UserID, job.ID)
+				if err != nil {
+					t.Errorf("Failed to start job: %v", err)
+				}
+				if !ok {
+					if err := replica.DeferJob(ctx, job, time.Hour); err != nil {
+						t.Errorf("Failed to defer job: %v", err)
+					}
+				}
+
+				mu.Lock()
+				if dequeued[job.ID] {
+					t.Errorf("Job %s was dequeued twice", job.ID)
+				}
+				dequeued[job.ID] = true
+				if ok {
+					started[job.UserID]++
+				}
+				done := len(dequeued) == users*jobsPerUser
+				mu.Unlock()
+				if done {
+					cancel()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(dequeued) != users*jobsPerUser {
+		t.Fatalf("Expected %d jobs dequeued, got %d", users*jobsPerUser, len(dequeued))
+	}
+	for userID, count := range started {
+		if count != q.worker.MaxJobsPerUser {
+			t.Errorf("Expected %d of %s's jobs to start, got %d", q.worker.MaxJobsPerUser, userID, count)
+		}
+	}
+	ctx = context.Background()
+	deferred, err := q.client.ZCard(ctx, q.config.ScheduledSet).Result()
+	if err != nil {
+		t.Fatalf("Failed to count deferred jobs: %v", err)
+	}
+	if want := int64(users * (jobsPerUser - q.worker.MaxJobsPerUser)); deferred != want {
+		t.Errorf("Expected %d deferred jobs, got %d", want, deferred)
+	}
+	if failed, _ := q.client.SCard(ctx, q.config.FailedSet).Result(); failed != 0 {
+		t.Errorf("Expected no failed jobs, got %d", failed)
+	}
+}
+
+func TestQueueLockFeedUnderContention(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	var holders, most, writes atomic.Int64
+	var wg sync.WaitGroup
+	for _, replica := range replicas(t, q, 4) {
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				unlock, err := replica.LockFeed(ctx, "contended-feed-user")
+				if err != nil {
+					t.Errorf("Failed to lock feed: %v", err)
+					return
+				}
+				held := holders.Add(1)
+				for {
+					prev := most.Load()
+					if held <= prev || most.CompareAndSwap(prev, held) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				writes.Add(1)
+				holders.Add(-1)
+				unlock()
+			}()
+		}
+	}
+	wg.Wait()
+
+	if writes.Load() != 16 {
+		t.Errorf("Expected 16 feed writes, got %d", writes.Load())
+	}
+	if most.Load() != 1 {
+		t.Errorf("Expected one feed lock holder at a time, saw %d", most.Load())
+	}
+}
+
+func TestQueueClaimPeriodicTask(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	var claims atomic.Int64
+	var wg sync.WaitGroup
+	for _, replica := range replicas(t, q, 8) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			claimed, err := replica.ClaimPeriodicTask(ctx, "cleanup", time.Hour)
+			if err != nil {
+				t.Errorf("Failed to claim task: %v", err)
+			}
+			if claimed {
+				claims.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if claims.Load() != 1 {
+		t.Errorf("Expected exactly one replica to claim the task, got %d", claims.Load())
+	}
+}
+
+func TestQueuePurgeUserHistory(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	userID := "purge-user"
+	for _, id := range []string{"purge-job-1", "purge-job-2"} {
+		if err := q.Enqueue(ctx, &Job{ID: id, FileID: "file-" + id, UserID: userID, CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("Failed to enqueue job: %v", err)
+		}
+	}
+	if _, err := q.StartJob(ctx, userID, "purge-job-1"); err != nil {
+		t.Fatalf("Failed to start job: %v", err)
+	}
+
+	if _, err := q.PurgeUserHistory(ctx, userID); !errors.Is(err, ErrJobsRunning) {
+		t.Fatalf("Expected ErrJobsRunning while a job runs, got %v", err)
+	}
+
+	if err := q.CompleteJob(ctx, userID, "purge-job-1"); err != nil {
+		t.Fatalf("Failed to complete job: %v", err)
+	}
+	purged, err := q.PurgeUserHistory(ctx, userID)
+	if err != nil {
+		t.Fatalf("Failed to purge history: %v", err)
+	}
+	if purged != 2 {
+		t.Errorf("Expected 2 jobs purged, got %d", purged)
+	}
+
+	for _, id := range []string{"purge-job-1", "purge-job-2"} {
+		if job, err := q.GetJob(ctx, id); err != nil || job != nil {
+			t.Errorf("Expected job %s to be deleted, got %v (%v)", id, job, err)
+		}
+	}
+	if length, err := q.QueueLength(ctx); err != nil || length != 0 {
+		t.Errorf("Expected an empty queue, got %d (%v)", length, err)
+	}
+	jobs, _, err := q.ListUserJobs(ctx, userID, JobListOptions{State: JobStateAll})
+	if err != nil {
+		t.Fatalf("Failed to list jobs: %v", err)
+	}
+	if len(jobs) != 0 {
+		t.Errorf("Expected no jobs listed, got %d", len(jobs))
+	}
+}
+
+func TestQueueFeedSlugs(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	userID := "feed-slug-user"
+	slug, err := q.GetFeedSlug(ctx, userID)
+	if err != nil {
+		t.Fatalf("Failed to get feed slug: %v", err)
+	}
+	if again, err := q.GetFeedSlug(ctx, userID); err != nil || again != slug {
+		t.Errorf("Expected the same slug %q, got %q (%v)", slug, again, err)
+	}
+	if owner, err := q.LookupFeedSlug(ctx, slug); err != nil || owner != userID {
+		t.Errorf("Expected slug to belong to %s, got %q (%v)", userID, owner, err)
+	}
+
+	rotated, err := q.RotateFeedSlug(ctx, userID)
+	if err != nil {
+		t.Fatalf("Failed to rotate feed slug: %v", err)
+	}
+	if rotated == slug {
+		t.Error("Expected a new slug after rotating")
+	}
+	if owner, err := q.LookupFeedSlug(ctx, slug); err != nil || owner != "" {
+		t.Errorf("Expected the old slug to stop working, got %q (%v)", owner, err)
+	}
+	if owner, err := q.LookupFeedSlug(ctx, rotated); err != nil || owner != userID {
+		t.Errorf("Expected rotated slug to belong to %s, got %q (%v)", userID, owner, err)
+	}
+
+	again, err := q.RotateFeedSlug(ctx, userID)
+	if err != nil {
+		t.Fatalf("Failed to rotate feed slug again: %v", err)
+	}
+	if owner, err := q.LookupFeedSlug(ctx, rotated); err != nil || owner != "" {
+		t.Errorf("Expected the rotated slug to stop working, got %q (%v)", owner, err)
+	}
+	if current, err := q.GetFeedSlug(ctx, userID); err != nil || current != again {
+		t.Errorf("Expected the latest slug %q, got %q (%v)", again, current, err)
+	}
+}
+
+func TestQueueEpisodeDownloads(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	for _, userAgent := range []string{"AntennaPod/3.4.0", "AntennaPod/3.5.0", "Overcast/3.0"} {
+		if err := q.RecordEpisodeDownload(ctx, "episode-1", userAgent); err != nil {
+			t.Fatalf("Failed to record download: %v", err)
+		}
+	}
+
+	downloads, err := q.GetEpisodeDownloads(ctx, []string{"episode-1", "episode-2"})
+	if err != nil {
+		t.Fatalf("Failed to get downloads: %v", err)
+	}
+	if got := downloads["episode-1"]; got.Total != 3 || got.UserAgents["AntennaPod"] != 2 || got.UserAgents["Overcast"] != 1 {
+		t.Errorf("Unexpected downloads of episode-1: %+v", got)
+	}
+	if got := downloads["episode-2"]; got == nil || got.Total != 0 || len(got.UserAgents) != 0 {
+		t.Errorf("Expected no downloads of episode-2, got %+v", got)
+	}
+}
+
+func TestQueuePlaylistRules(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	userID := "playlist-rules-user"
+	if rules, err := q.GetPlaylistRules(ctx, userID); err != nil || rules != nil {
+		t.Fatalf("Expected no rules, got %+v (%v)", rules, err)
+	}
+
+	rules := &PlaylistRules{EpisodesPerShow: 3, UnplayedOnly: true, Shows: []string{"The Daily"}, Order: OrderShortest, MaxMinutes: 240}
+	if err := q.SavePlaylistRules(ctx, userID, rules); err != nil {
+		t.Fatalf("Failed to save rules: %v", err)
+	}
+	saved, err := q.GetPlaylistRules(ctx, userID)
+	if err != nil || !reflect.DeepEqual(saved, rules) {
+		t.Errorf("Expected saved rules %+v, got %+v (%v)", rules, saved, err)
+	}
+
+	if err := q.SavePlaylistRules(ctx, userID, &PlaylistRules{}); !errors.Is(err, ErrInvalidSettings) {
+		t.Errorf("Expected ErrInvalidSettings, got %v", err)
+	}
+
+	if err := q.DeletePlaylistRules(ctx, userID); err != nil {
+		t.Fatalf("Failed to delete rules: %v", err)
+	}
+	if rules, err := q.GetPlaylistRules(ctx, userID); err != nil || rules != nil {
+		t.Errorf("Expected rules to be gone, got %+v (%v)", rules, err)
+	}
+}
+
+func TestQueuePlaylistURL(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	userID := "playlist-url-user"
+	if playlist, err := q.GetPlaylistURL(ctx, userID); err != nil || playlist != nil {
+		t.Fatalf("Expected no playlist URL, got %+v (%v)", playlist, err)
+	}
+	for _, rawURL := range []string{"ftp://example.com/commute.m3u8", "http://169.254.169.254/latest/meta-data/", "http://localhost:8080/commute.m3u8"} {
+		if err := q.SavePlaylistURL(ctx, userID, rawURL); !errors.Is(err, ErrInvalidSettings) {
+			t.Errorf("Expected ErrInvalidSettings for %s, got %v", rawURL, err)
+		}
+	}
+
+	playlistURL := "https://example.com/commute.m3u8"
+	if err := q.SavePlaylistURL(ctx, userID, playlistURL); err != nil {
+		t.Fatalf("Failed to save playlist URL: %v", err)
+	}
+	users, err := q.GetPlaylistURLUsers(ctx)
+	if err != nil || !slices.Contains(users, userID) {
+		t.Errorf("Expected %s among playlist URL users, got %v (%v)", userID, users, err)
+	}
+
+	if err := q.SetPlaylistJob(ctx, userID, playlistURL, "job-1"); err != nil {
+		t.Fatalf("Failed to set playlist job: %v", err)
+	}
+	fetched := &PlaylistURL{URL: playlistURL, ETag: `"v1"`, LastModified: "Mon, 02 Jun 2025 10:00:00 GMT", Checksum: "abc", JobID: "job-1"}
+	if err := q.RecordPlaylistFetch(ctx, userID, fetched); err != nil {
+		t.Fatalf("Failed to record playlist fetch: %v", err)
+	}
+	if playlist, err := q.GetPlaylistURL(ctx, userID); err != nil || !reflect.DeepEqual(playlist, fetched) {
+		t.Errorf("Expected %+v, got %+v (%v)", fetched, playlist, err)
+	}
+
+	// A fetch of a URL replaced in the meantime isn't recorded against the new one
+	if err := q.SavePlaylistURL(ctx, userID, "https://example.com/other.m3u8"); err != nil {
+		t.Fatalf("Failed to save playlist URL: %v", err)
+	}
+	if err := q.RecordPlaylistFetch(ctx, userID, fetched); err != nil {
+		t.Fatalf("Failed to record playlist fetch: %v", err)
+	}
+	if err := q.SetPlaylistJob(ctx, userID, playlistURL, "job-2"); err != nil {
+		t.Fatalf("Failed to set playlist job: %v", err)
+	}
+	if playlist, err := q.GetPlaylistURL(ctx, userID); err != nil || playlist.ETag != "" || playlist.Checksum != "" || playlist.JobID != "" {
+		t.Errorf("Expected the replaced URL's fetch to be ignored, got %+v (%v)", playlist, err)
+	}
+
+	if err := q.DeletePlaylistURL(ctx, userID); err != nil {
+		t.Fatalf("Failed to delete playlist URL: %v", err)
+	}
+	if playlist, err := q.GetPlaylistURL(ctx, userID); err != nil || playlist != nil {
+		t.Errorf("Expected the playlist URL to be gone, got %+v (%v)", playlist, err)
+	}
+	if users, _ := q.GetPlaylistURLUsers(ctx); slices.Contains(users, userID) {
+		t.Errorf("Expected %s to no longer be polled", userID)
+	}
+}
+
+func TestQueueAnnouncements(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	active := &Announcement{
+		ID:        "active",
+		Message:   "Maintenance tonight",
+		Severity:  SeverityWarning,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	expired := &Announcement{
+		ID:        "expired",
+		Message:   "Old news",
+		Severity:  SeverityInfo,
+		CreatedAt: time.Now().Add(-2 * time.Hour),
+		ExpiresAt: time.Now().Add(-time.Hour),
+	}
+	for _, a := range []*Announcement{active, expired} {
+		if err := q.SaveAnnouncement(ctx, a); err != nil {
+			t.Fatalf("Failed to save announcement: %v", err)
+		}
+	}
+
+	announcements, err := q.GetAnnouncements(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get announcements: %v", err)
+	}
+	if len(announcements) != 1 || announcements[0].ID != "active" {
+		t.Errorf("Expected only the active announcement, got %v", announcements)
+	}
+
+	removed, err := q.DeleteAnnouncement(ctx, "active")
+	if err != nil {
+		t.Fatalf("Failed to delete announcement: %v", err)
+	}
+	if !removed {
+		t.Error("Expected announcement to be removed")
+	}
+}
+
+func TestQueueGoogleTokens(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	if _, err := q.GetGoogleToken(ctx, "google-token-user"); !errors.Is(err, auth.ErrNoGoogleToken) {
+		t.Errorf("Expected ErrNoGoogleToken before sign in, got %v", err)
+	}
+
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	token := &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: expiry}
+	if err := q.SaveGoogleToken(ctx, "google-token-user", token); err != nil {
+		t.Fatalf("Failed to save google token: %v", err)
+	}
+	defer q.client.Del(ctx, q.googleTokenKey("google-token-user"))
+
+	stored, err := q.GetGoogleToken(ctx, "google-token-user")
+	if err != nil {
+		t.Fatalf("Failed to get google token: %v", err)
+	}
+	if stored.AccessToken != "access" || stored.RefreshToken != "refresh" || !stored.Expiry.Equal(expiry) {
+		t.Errorf("Unexpected stored token: %+v", stored)
+	}
+}
+
+func TestQueueAPIKeys(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	key, secret, err := q.CreateAPIKey(ctx, "api-key-user", "nightly cron")
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+	if !strings.HasPrefix(secret, APIKeyPrefix) || !strings.HasPrefix(secret, key.Hint) {
+		t.Errorf("Unexpected key %q with hint %q", secret, key.Hint)
+	}
+
+	userID, err := q.LookupAPIKey(ctx, secret)
+	if err != nil || userID != "api-key-user" {
+		t.Errorf("Expected key to belong to api-key-user, got %q (%v)", userID, err)
+	}
+	if userID, _ := q.LookupAPIKey(ctx, APIKeyPrefix+"unknown"); userID != "" {
+		t.Errorf("Expected unknown key not to resolve, got %q", userID)
+	}
+
+	keys, err := q.ListAPIKeys(ctx, "api-key-user")
+	if err != nil {
+		t.Fatalf("Failed to list API keys: %v", err)
+	}
+	if len(keys) != 1 || keys[0].ID != key.ID || keys[0].Name != "nightly cron" {
+		t.Errorf("Unexpected keys: %+v", keys)
+	}
+
+	// Keys are revoked only by their owner
+	if removed, _ := q.RevokeAPIKey(ctx, "other-user", key.ID); removed {
+		t.Error("Expected another user not to revoke the key")
+	}
+	removed, err := q.RevokeAPIKey(ctx, "api-key-user", key.ID)
+	if err != nil || !removed {
+		t.Fatalf("Failed to revoke API key: %v", err)
+	}
+	if userID, _ := q.LookupAPIKey(ctx, secret); userID != "" {
+		t.Errorf("Expected revoked key not to resolve, got %q", userID)
+	}
+
+	for i := 0; i < MaxAPIKeysPerUser; i++ {
+		if _, _, err := q.CreateAPIKey(ctx, "api-key-user", fmt.Sprintf("key %d", i)); err != nil {
+			t.Fatalf("Failed to create API key %d: %v", i, err)
+		}
+	}
+	if _, _, err := q.CreateAPIKey(ctx, "api-key-user", "one too many"); !errors.Is(err, ErrTooManyAPIKeys) {
+		t.Errorf("Expected ErrTooManyAPIKeys, got %v", err)
+	}
+}
+
+func TestQueueUserSettings(t *testing.T) {
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	ctx := context.Background()
+	userID := "settings-user"
+
+	settings, err := q.GetUserSettings(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetUserSettings failed: %v", err)
+	}
+	if !reflect.DeepEqual(settings, &UserSettings{}) {
+		t.Errorf("Expected default settings, got %+v", settings)
+	}
+
+	want := &UserSettings{
+		TimeZone:          "America/Toronto",
+		Speed:             1.8,
+		TrimSilence:       true,
+		OutputFormat:      "m4a",
+		Transcripts:       true,
+		RetentionDays:     3,
+		FeedTitle:         "Commute",
+		NotificationEmail: "me@example.com",
+		WebhookURL:        "https://example.com/hook",
+		TelegramChatID:    "@cobblepod",
+		NtfyTopic:         "cobblepod",
+		NotifyEvents:      "job.failed",
+	}
+	if err := q.SaveUserSettings(ctx, userID, want); err != nil {
+		t.Fatalf("SaveUserSettings failed: %v", err)
+	}
+	got, err := q.GetUserSettings(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetUserSettings failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
+
+func TestQueueUserSettingsFields(t *testing.T) {
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	ctx := context.Background()
+	trim := true
+	tests := []struct {
+		name     string
+		settings UserSettings
+	}{
+		{name: "encoding", settings: UserSettings{BitrateKbps: 64, Mono: true}},
+		{name: "job retention", settings: UserSettings{JobRetentionDays: 30}},
+		{name: "speed profiles", settings: UserSettings{SpeedProfiles: []SpeedProfile{
+			{Podcast: "The Daily", Speed: 2},
+			{Podcast: "https://example.com/news.xml", TrimSilence: &trim},
+		}}},
+		{name: "skip rules", settings: UserSettings{SkipRules: []SkipRule{{Podcast: "The Daily", SkipStartSeconds: 90, Chapters: []string{"Ads"}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := "settings-" + strings.ReplaceAll(tt.name, " ", "-")
+			if err := q.SaveUserSettings(ctx, userID, &tt.settings); err != nil {
+				t.Fatalf("SaveUserSettings failed: %v", err)
+			}
+			got, err := q.GetUserSettings(ctx, userID)
+			if err != nil {
+				t.Fatalf("GetUserSettings failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, &tt.settings) {
+				t.Errorf("Expected %+v, got %+v", tt.settings, got)
+			}
+		})
+	}
+}
+
+func TestQueueAdminIntrospection(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	if err := q.Enqueue(ctx, &Job{ID: "admin-job", UserID: "admin-user"}); err != nil {
+		t.Fatalf("Failed to add job: %v", err)
+	}
+	stats, err := q.GetQueueStats(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get queue stats: %v", err)
+	}
+	if stats.Waiting+stats.Priority != 1 || stats.Running != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// A slot count that drifted from the running set and a feed lock with no job behind it
+	q.client.HSet(ctx, q.config.RunningUsersKey, "stuck-user", 2)
+	if _, err := q.LockFeed(ctx, "stuck-user"); err != nil {
+		t.Fatalf("Failed to lock feed: %v", err)
+	}
+	locks, err := q.GetUserLocks(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get user locks: %v", err)
+	}
+	if len(locks) != 1 || locks[0].UserID != "stuck-user" || !locks[0].Stuck || !locks[0].FeedLocked || locks[0].RunningSlots != 2 {
+		t.Fatalf("Unexpected locks: %+v", locks)
+	}
+
+	if err := q.ReleaseUserLock(ctx, "stuck-user"); err != nil {
+		t.Fatalf("Failed to release user lock: %v", err)
+	}
+	if locks, _ := q.GetUserLocks(ctx); len(locks) != 0 {
+		t.Errorf("Expected no locks after release, got %+v", locks)
+	}
+
+	running, err := q.GetAllRunningJobs(ctx)
+	if err != nil || len(running) != 0 {
+		t.Errorf("Expected no running jobs, got %d (%v)", len(running), err)
+	}
+}
+
+// Runs against a sentinel deployment (VALKEY_ADDRS pointing at the sentinels and
+// VALKEY_MASTER_NAME set) and forces a primary switch mid-test
+func TestQueueSurvivesSentinelFailover(t *testing.T) {
+	masterName := testConfig(t).Valkey.MasterName
+	if masterName == "" {
+		t.Skip("Skipping test: VALKEY_MASTER_NAME not set, no sentinel deployment")
+	}
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	sentinel := redis.NewSentinelClient(&redis.Options{Addr: RedisOptions(testConfig(t).Valkey).Addrs[0]})
+	defer sentinel.Close()
+	if err := sentinel.Failover(ctx, masterName).Err(); err != nil {
+		t.Fatalf("Failed to trigger failover: %v", err)
+	}
+
+	job := &Job{
+		ID:        "failover-test-job",
+		FileID:    "file-failover",
+		UserID:    "failover-test-user",
+		CreatedAt: time.Now(),
+	}
+
+	// Both operations should recover once the new primary is promoted
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		err := q.Enqueue(ctx, job)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Enqueue did not recover after failover: %v", err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	for {
+		dequeued, err := q.Dequeue(ctx)
+		if err == nil && dequeued != nil && dequeued.ID == job.ID {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Dequeue did not recover after failover: %v", err)
+		}
+	}
+}
+
+func TestQueueArchive(t *testing.T) {
+	ctx := context.Background()
+
+	q := setupTestQueue(t)
+	if q == nil {
+		return
+	}
+	defer q.Close()
+
+	userID := "archive-user"
+	defer q.client.Del(ctx, q.archiveKey(userID))
+
+	older := &ArchivedEpisode{FileID: "file-1", Title: "Older", ArchivedAt: time.Now().Add(-time.Hour).UTC(), Episode: json.RawMessage(`{"title":"Older"}`)}
+	newer := &ArchivedEpisode{FileID: "file-2", Title: "Newer", ArchivedAt: time.Now().UTC(), Episode: json.RawMessage(`{"title":"Newer"}`)}
+	for _, episode := range []*ArchivedEpisode{older, newer} {
+		if err := q.ArchiveEpisode(ctx, userID, episode); err != nil {
+			t.Fatalf("Failed to archive episode: %v", err)
+		}
+	}
+
+	episodes, err := q.ListArchivedEpisodes(ctx, userID)
+	if err != nil {
+		t.Fatalf("Failed to list archived episodes: %v", err)
+	}
+	if len(episodes) != 2 || episodes[0].FileID != "file-2" || episodes[1].FileID != "file-1" {
+		t.Errorf("Expected the newest episode first, got %+v", episodes)
+	}
+
+	episode, err := q.GetArchivedEpisode(ctx, userID, "file-1")
+	if err != nil || episode == nil || episode.Title != "Older" || string(episode.Episode) != `{"title":"Older"}` {
+		t.Errorf("Expected the older episode, got %+v (%v)", episode, err)
+	}
+
+	if err := q.RemoveArchivedEpisode(ctx, userID, "file-1"); err != nil {
+		t.Fatalf("Failed to remove archived episode: %v", err)
+	}
+	if episode, err := q.GetArchivedEpisode(ctx, userID, "file-1"); err != nil || episode != nil {
+		t.Errorf("Expected the episode to be forgotten, got %+v (%v)", episode, err)
+	}
 }
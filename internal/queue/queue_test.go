@@ -1,8 +1,18 @@
 package queue
 
 import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	"cobblepod/internal/audio"
+	"cobblepod/internal/config"
+	"cobblepod/internal/cron"
+
+	"github.com/redis/go-redis/v9"
 )
 
 func TestJobMarshaling(t *testing.T) {
@@ -32,3 +42,631 @@ func TestQueueConstants(t *testing.T) {
 		t.Error("BlockTimeout should not be zero")
 	}
 }
+
+func TestNormalizeLabel(t *testing.T) {
+	tests := []struct {
+		name     string
+		label    string
+		expected string
+		wantErr  bool
+	}{
+		{name: "empty", label: "", expected: ""},
+		{name: "trimmed", label: "  pre-marathon playlist ", expected: "pre-marathon playlist"},
+		{name: "max length", label: strings.Repeat("é", MaxLabelLength), expected: strings.Repeat("é", MaxLabelLength)},
+		{name: "too long", label: strings.Repeat("a", MaxLabelLength+1), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeLabel(tt.label)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidLabel) {
+					t.Errorf("Expected ErrInvalidLabel, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected label %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestJobMatchesLabel(t *testing.T) {
+	job := &Job{Label: "Pre-Marathon Playlist"}
+
+	for filter, want := range map[string]bool{
+		"":              true,
+		"marathon":      true,
+		"PRE-MARATHON":  true,
+		"recovery week": false,
+	} {
+		if got := job.MatchesLabel(filter); got != want {
+			t.Errorf("MatchesLabel(%q) = %v, want %v", filter, got, want)
+		}
+	}
+}
+
+func TestJobMatchesSearch(t *testing.T) {
+	job := &Job{
+		Filename: "PodcastAddict_2025-06-01.backup",
+		Status:   JobStatusCompletedWithErrors,
+		Items:    []JobItem{{Title: "The Daily: Hard Fork Crossover"}, {Title: "Planet Money"}},
+	}
+
+	for query, want := range map[string]bool{
+		"":                  true,
+		"hard fork":         true,
+		"FORK daily":        true,
+		"2025-06-01":        true,
+		"completed":         true,
+		"money backup":      true,
+		"failed":            false,
+		"hard fork planets": false,
+	} {
+		if got := job.MatchesSearch(query); got != want {
+			t.Errorf("MatchesSearch(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
+
+func TestJobCountItems(t *testing.T) {
+	job := &Job{Items: []JobItem{
+		{ID: "1", Status: StatusPending},
+		{ID: "2", Status: StatusDownloading},
+		{ID: "3", Status: StatusUploading},
+		{ID: "4", Status: StatusCompleted},
+		{ID: "5", Status: StatusSkipped},
+		{ID: "6", Status: StatusFailed},
+		{ID: "7", Status: StatusCompleted},
+	}}
+	job.CountItems()
+
+	if job.TotalItems != 7 || job.Completed != 2 || job.Failed != 1 || job.Skipped != 1 || job.InProgress != 2 {
+		t.Errorf("Unexpected counters: total=%d completed=%d failed=%d skipped=%d in_progress=%d",
+			job.TotalItems, job.Completed, job.Failed, job.Skipped, job.InProgress)
+	}
+}
+
+func TestSortItems(t *testing.T) {
+	items := []JobItem{
+		{Title: "Legacy B"},
+		{Title: "Zebra", Position: 2},
+		{Title: "Legacy A"},
+		{Title: "Aardvark", Position: 3},
+		{Title: "Middle", Position: 1},
+	}
+	SortItems(items)
+
+	var titles []string
+	for _, item := range items {
+		titles = append(titles, item.Title)
+	}
+	if got, want := strings.Join(titles, ","), "Middle,Zebra,Aardvark,Legacy A,Legacy B"; got != want {
+		t.Errorf("SortItems() order = %s, want %s", got, want)
+	}
+}
+
+func TestEstimateCompletion(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	items := []JobItem{
+		{Duration: 40 * time.Minute},
+		{Duration: 30 * time.Minute, Offset: 10 * time.Minute},
+		{Duration: 20 * time.Minute, Status: StatusSkipped},
+		{Duration: 20 * time.Minute, Status: StatusCompleted},
+	}
+	if got := TotalAudio(items); got != time.Hour {
+		t.Fatalf("TotalAudio() = %v, want 1h", got)
+	}
+
+	tests := []struct {
+		name      string
+		pos       queuePosition
+		audio     time.Duration
+		perMinute time.Duration
+		want      time.Duration
+	}{
+		{"no history", queuePosition{waiting: 2}, time.Hour, 0, 0},
+		{"audio at the rate", queuePosition{}, time.Hour, 5 * time.Second, 5 * time.Minute},
+		{"behind waiting jobs", queuePosition{waiting: 2, running: 1}, time.Hour, 5 * time.Second, 9 * time.Minute},
+		{"shared among workers", queuePosition{waiting: 4, running: 4}, time.Hour, 5 * time.Second, 7 * time.Minute},
+		{"behind the user's own jobs", queuePosition{waiting: 2, running: 4, userJobs: 4}, time.Hour, 5 * time.Second, 9 * time.Minute},
+		{"items not known yet", queuePosition{waiting: 2}, 0, 5 * time.Second, 6 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := estimateCompletion(now, tt.pos.rounds(2), tt.audio, tt.perMinute, 2*time.Minute)
+			if tt.want == 0 {
+				if !got.IsZero() {
+					t.Errorf("estimateCompletion() = %v, want zero", got)
+				}
+				return
+			}
+			if got.Sub(now) != tt.want {
+				t.Errorf("estimateCompletion() = %v from now, want %v", got.Sub(now), tt.want)
+			}
+		})
+	}
+}
+
+func TestUserSettingsLocation(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeZone string
+		expected string
+		valid    bool
+	}{
+		{name: "unset defaults to UTC", timeZone: "", expected: "UTC", valid: true},
+		{name: "known zone", timeZone: "America/Toronto", expected: "America/Toronto", valid: true},
+		{name: "unknown zone falls back to UTC", timeZone: "Mars/Olympus", expected: "UTC", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := UserSettings{TimeZone: tt.timeZone}
+			if got := settings.Location().String(); got != tt.expected {
+				t.Errorf("Expected location %s, got %s", tt.expected, got)
+			}
+			if err := settings.Validate(); (err == nil) != tt.valid {
+				t.Errorf("Validate() error = %v, valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestUserSettingsEncoding(t *testing.T) {
+	tests := []struct {
+		settings UserSettings
+		expected string
+	}{
+		{settings: UserSettings{}, expected: ""},
+		{settings: UserSettings{BitrateKbps: 64}, expected: "64k"},
+		{settings: UserSettings{Mono: true}, expected: "mono"},
+		{settings: UserSettings{BitrateKbps: 64, Mono: true}, expected: "64k mono"},
+	}
+	for _, tt := range tests {
+		if got := tt.settings.Encoding(); got != tt.expected {
+			t.Errorf("Encoding() = %q, want %q", got, tt.expected)
+		}
+	}
+}
+
+func TestUserSettingsValidate(t *testing.T) {
+	trim := true
+	tests := []struct {
+		name     string
+		settings UserSettings
+		valid    bool
+	}{
+		{name: "defaults", settings: UserSettings{}, valid: true},
+		{name: "all set", settings: UserSettings{Speed: 1.25, TrimSilence: true, OutputFormat: "m4a", RetentionDays: 7, FeedTitle: "My feed"}, valid: true},
+		{name: "speed too slow", settings: UserSettings{Speed: 0.25}},
+		{name: "speed too fast", settings: UserSettings{Speed: 3}},
+		{name: "unknown format", settings: UserSettings{OutputFormat: "wav"}},
+		{name: "voice encoding", settings: UserSettings{OutputFormat: "opus", BitrateKbps: 64, Mono: true}, valid: true},
+		{name: "bitrate too low", settings: UserSettings{BitrateKbps: 8}},
+		{name: "bitrate too high", settings: UserSettings{BitrateKbps: 512}},
+		{name: "negative retention", settings: UserSettings{RetentionDays: -1}},
+		{name: "retention too long", settings: UserSettings{RetentionDays: MaxRetentionDays + 1}},
+		{name: "job retention", settings: UserSettings{JobRetentionDays: 30}, valid: true},
+		{name: "job retention too long", settings: UserSettings{JobRetentionDays: MaxJobRetentionDays + 1}},
+		{name: "feed title too long", settings: UserSettings{FeedTitle: strings.Repeat("a", MaxFeedTitleLength+1)}},
+		{name: "notification email", settings: UserSettings{NotificationEmail: "runner@example.com"}, valid: true},
+		{name: "invalid notification email", settings: UserSettings{NotificationEmail: "runner"}},
+		{name: "notification email with name", settings: UserSettings{NotificationEmail: "Runner <runner@example.com>"}},
+		{name: "notification channels", settings: UserSettings{WebhookURL: "https://example.com/hook", TelegramChatID: "-1001234", NtfyTopic: "my_runs", NotifyEvents: "job.failed, feed.updated"}, valid: true},
+		{name: "telegram channel name", settings: UserSettings{TelegramChatID: "@cobblepod_runs"}, valid: true},
+		{name: "webhook without scheme", settings: UserSettings{WebhookURL: "example.com/hook"}},
+		{name: "webhook not http", settings: UserSettings{WebhookURL: "file:///etc/passwd"}},
+		{name: "webhook over http", settings: UserSettings{WebhookURL: "http://example.com/hook"}},
+		{name: "webhook to metadata server", settings: UserSettings{WebhookURL: "https://169.254.169.254/latest/meta-data/"}},
+		{name: "webhook to localhost", settings: UserSettings{WebhookURL: "https://localhost:8080/hook"}},
+		{name: "invalid telegram chat", settings: UserSettings{TelegramChatID: "my chat"}},
+		{name: "invalid ntfy topic", settings: UserSettings{NtfyTopic: "../admin"}},
+		{name: "unknown notify event", settings: UserSettings{NotifyEvents: "job.completed,job.started"}},
+		{name: "poll schedule", settings: UserSettings{PollSchedule: "*/15 6-23 * * *"}, valid: true},
+		{name: "invalid poll schedule", settings: UserSettings{PollSchedule: "every 15 minutes"}},
+		{name: "speed profiles", settings: UserSettings{SpeedProfiles: []SpeedProfile{{Podcast: "The Daily", Speed: 2}, {Podcast: "https://example.com/fiction.xml", Speed: 1}}}, valid: true},
+		{name: "speed profile without podcast", settings: UserSettings{SpeedProfiles: []SpeedProfile{{Podcast: " ", Speed: 2}}}},
+		{name: "speed profile too fast", settings: UserSettings{SpeedProfiles: []SpeedProfile{{Podcast: "The Daily", Speed: 3}}}},
+		{name: "speed profile without speed", settings: UserSettings{SpeedProfiles: []SpeedProfile{{Podcast: "The Daily"}}}},
+		{name: "speed profile trimming silence only", settings: UserSettings{SpeedProfiles: []SpeedProfile{{Podcast: "The Daily", TrimSilence: &trim}}}, valid: true},
+		{name: "duplicate speed profile", settings: UserSettings{SpeedProfiles: []SpeedProfile{{Podcast: "The Daily", Speed: 2}, {Podcast: "the daily", Speed: 1.5}}}},
+		{name: "skip rules", settings: UserSettings{SkipRules: []SkipRule{{Podcast: "The Daily", SkipStartSeconds: 90}, {Podcast: "Interviews", Chapters: []string{"Ads"}}}}, valid: true},
+		{name: "skip rule without podcast", settings: UserSettings{SkipRules: []SkipRule{{SkipStartSeconds: 90}}}},
+		{name: "skip rule skipping nothing", settings: UserSettings{SkipRules: []SkipRule{{Podcast: "The Daily"}}}},
+		{name: "skip rule too long", settings: UserSettings{SkipRules: []SkipRule{{Podcast: "The Daily", SkipEndSeconds: MaxSkipSeconds + 1}}}},
+		{name: "skip rule negative", settings: UserSettings{SkipRules: []SkipRule{{Podcast: "The Daily", SkipStartSeconds: -1}}}},
+		{name: "skipped chapter without title", settings: UserSettings{SkipRules: []SkipRule{{Podcast: "The Daily", Chapters: []string{""}}}}},
+		{name: "duplicate skip rule", settings: UserSettings{SkipRules: []SkipRule{{Podcast: "The Daily", SkipStartSeconds: 90}, {Podcast: "THE DAILY", SkipEndSeconds: 30}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.settings.Validate()
+			if (err == nil) != tt.valid {
+				t.Errorf("Validate() error = %v, valid %v", err, tt.valid)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidSettings) {
+				t.Errorf("Expected ErrInvalidSettings, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUserSettingsPolls(t *testing.T) {
+	deployment, err := cron.Parse("*/15 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 03:30 in Toronto
+	at := time.Date(2025, time.January, 1, 8, 30, 0, 0, time.UTC)
+	if !(UserSettings{}).Polls(at, deployment) || (UserSettings{}).Polls(at.Add(time.Minute), deployment) {
+		t.Error("Expected the deployment's schedule without one of the user's own")
+	}
+	daytime := UserSettings{TimeZone: "America/Toronto", PollSchedule: "*/15 6-23 * * *"}
+	if daytime.Polls(at, deployment) {
+		t.Error("Expected no poll overnight in the user's time zone")
+	}
+	if !daytime.Polls(at.Add(3*time.Hour), deployment) {
+		t.Error("Expected a poll at 06:30 in the user's time zone")
+	}
+	if !(UserSettings{}).Polls(at.Add(time.Minute), nil) {
+		t.Error("Expected every minute without any schedule")
+	}
+}
+
+func TestUserSettingsNextPoll(t *testing.T) {
+	// 03:30 in Toronto
+	at := time.Date(2025, time.January, 1, 8, 30, 0, 0, time.UTC)
+	if got := (UserSettings{}).NextPoll(at); !got.Equal(at) {
+		t.Errorf("NextPoll() = %v, want now without a schedule", got)
+	}
+	daytime := UserSettings{TimeZone: "America/Toronto", PollSchedule: "*/15 6-23 * * *"}
+	if got, want := daytime.NextPoll(at), at.Add(150*time.Minute); !got.Equal(want) {
+		t.Errorf("NextPoll() = %v, want 06:00 in the user's time zone (%v)", got, want)
+	}
+	if got := daytime.NextPoll(at.Add(3 * time.Hour)); !got.Equal(at.Add(3 * time.Hour)) {
+		t.Errorf("NextPoll() = %v, want now within the schedule", got)
+	}
+}
+
+func TestUserSettingsSpeedFor(t *testing.T) {
+	settings := UserSettings{
+		Speed: 1.5,
+		SpeedProfiles: []SpeedProfile{
+			{Podcast: "The Daily", Speed: 2},
+			{Podcast: "https://example.com/fiction.xml", Speed: 1},
+		},
+	}
+	tests := []struct {
+		name     string
+		item     JobItem
+		expected float64
+	}{
+		{name: "show name", item: JobItem{Title: "The Daily - Monday"}, expected: 2},
+		{name: "show name ignores case", item: JobItem{Title: "the daily - Tuesday"}, expected: 2},
+		{name: "feed URL", item: JobItem{Title: "Chapter 1", FeedURL: "https://example.com/fiction.xml"}, expected: 1},
+		{name: "show name prefix of another show", item: JobItem{Title: "The Daily Show - Monday"}, expected: 1.5},
+		{name: "no profile", item: JobItem{Title: "Interviews - Guest"}, expected: 1.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := settings.SpeedFor(tt.item); got != tt.expected {
+				t.Errorf("SpeedFor() = %g, want %g", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUserSettingsTrimSilenceFor(t *testing.T) {
+	trim, keep := true, false
+	settings := UserSettings{
+		Speed:       1.5,
+		TrimSilence: true,
+		SpeedProfiles: []SpeedProfile{
+			{Podcast: "The Daily", Speed: 2, TrimSilence: &trim},
+			{Podcast: "https://example.com/fiction.xml", TrimSilence: &keep},
+			{Podcast: "Interviews", Speed: 1.25},
+		},
+	}
+	tests := []struct {
+		name  string
+		item  JobItem
+		trim  bool
+		speed float64
+	}{
+		{name: "profile trims", item: JobItem{Title: "The Daily - Monday"}, trim: true, speed: 2},
+		{name: "profile keeps silences at the user's speed", item: JobItem{Title: "Chapter 1", FeedURL: "https://example.com/fiction.xml"}, trim: false, speed: 1.5},
+		{name: "profile without filters", item: JobItem{Title: "Interviews - Guest"}, trim: true, speed: 1.25},
+		{name: "no profile", item: JobItem{Title: "Other - Episode"}, trim: true, speed: 1.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := settings.TrimSilenceFor(tt.item); got != tt.trim {
+				t.Errorf("TrimSilenceFor() = %v, want %v", got, tt.trim)
+			}
+			if got := settings.SpeedFor(tt.item); got != tt.speed {
+				t.Errorf("SpeedFor() = %g, want %g", got, tt.speed)
+			}
+		})
+	}
+}
+
+func TestSkipRuleCuts(t *testing.T) {
+	chapters := []audio.Chapter{
+		{Title: "Intro", Start: 0, End: 2 * time.Minute},
+		{Title: "Ads", Start: 20 * time.Minute, End: 22 * time.Minute},
+		{Title: "Interview", Start: 22 * time.Minute, End: 58 * time.Minute},
+		{Title: "ads", Start: 58 * time.Minute, End: time.Hour},
+	}
+	rule := SkipRule{Podcast: "The Daily", SkipStartSeconds: 90, SkipEndSeconds: 60, Chapters: []string{"Ads", "Intro"}}
+
+	got := rule.Cuts(time.Hour, chapters)
+	want := []Cut{
+		{Start: 0, End: 2 * time.Minute, Reason: "start, Intro"},
+		{Start: 20 * time.Minute, End: 22 * time.Minute, Reason: "Ads"},
+		{Start: 58 * time.Minute, End: time.Hour, Reason: "ads, end"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Cuts() = %+v, want %+v", got, want)
+	}
+
+	// The end can't be found without the length
+	if got := (SkipRule{SkipEndSeconds: 60}).Cuts(0, nil); len(got) != 0 {
+		t.Errorf("Expected no cuts without a duration, got %+v", got)
+	}
+
+	settings := UserSettings{SkipRules: []SkipRule{rule}}
+	if settings.SkipRuleFor(JobItem{Title: "The Daily - Monday"}) == nil {
+		t.Error("Expected the show's skip rule")
+	}
+	if settings.SkipRuleFor(JobItem{Title: "Fiction - Chapter 1"}) != nil {
+		t.Error("Expected no skip rule for another show")
+	}
+}
+
+func TestPlaylistRulesValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules PlaylistRules
+		valid bool
+	}{
+		{name: "newest of each show", rules: PlaylistRules{EpisodesPerShow: 3}, valid: true},
+		{name: "all set", rules: PlaylistRules{EpisodesPerShow: 3, UnplayedOnly: true, Shows: []string{"The Daily"}, Order: OrderShortest, MaxMinutes: 240}, valid: true},
+		{name: "no episodes", rules: PlaylistRules{}},
+		{name: "too many episodes", rules: PlaylistRules{EpisodesPerShow: MaxRuleEpisodesPerShow + 1}},
+		{name: "unknown order", rules: PlaylistRules{EpisodesPerShow: 3, Order: "random"}},
+		{name: "negative limit", rules: PlaylistRules{EpisodesPerShow: 3, MaxMinutes: -1}},
+		{name: "limit too long", rules: PlaylistRules{EpisodesPerShow: 3, MaxMinutes: MaxRuleMinutes + 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rules.Validate()
+			if (err == nil) != tt.valid {
+				t.Errorf("Validate() error = %v, valid %v", err, tt.valid)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidSettings) {
+				t.Errorf("Expected ErrInvalidSettings, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUserSettingsDefaults(t *testing.T) {
+	var settings UserSettings
+	if settings.PlaybackSpeed() != config.DefaultSpeed {
+		t.Errorf("Expected default speed %v, got %v", config.DefaultSpeed, settings.PlaybackSpeed())
+	}
+	if settings.Format() != audio.FormatMP3 {
+		t.Errorf("Expected mp3, got %+v", settings.Format())
+	}
+	if settings.Retention() != 0 {
+		t.Errorf("Expected no retention, got %v", settings.Retention())
+	}
+
+	if got := settings.NotificationRecipient("ops@example.com"); got != "ops@example.com" {
+		t.Errorf("Expected the deployment notification email, got %q", got)
+	}
+
+	settings = UserSettings{Speed: 1.2, OutputFormat: "opus", RetentionDays: 2}
+	if settings.PlaybackSpeed() != 1.2 || settings.Format().Extension != "opus" || settings.Retention() != 48*time.Hour {
+		t.Errorf("Unexpected effective settings: %v %+v %v", settings.PlaybackSpeed(), settings.Format(), settings.Retention())
+	}
+
+	if !settings.Notifies(NotifyJobCompleted) || !settings.Notifies(NotifyJobFailed) || settings.Notifies(NotifyFeedUpdated) {
+		t.Error("Expected job events only by default")
+	}
+
+	settings = UserSettings{NotificationEmail: "runner@example.com"}
+	if got := settings.NotificationRecipient("ops@example.com"); got != "runner@example.com" {
+		t.Errorf("Expected the user's notification email, got %q", got)
+	}
+
+	settings = UserSettings{NotifyEvents: "feed.updated"}
+	if settings.Notifies(NotifyJobCompleted) || !settings.Notifies(NotifyFeedUpdated) {
+		t.Errorf("Expected feed updates only, got %q", settings.NotifyEvents)
+	}
+}
+
+func TestAnnouncementValidate(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name         string
+		announcement Announcement
+		valid        bool
+	}{
+		{name: "valid", announcement: Announcement{Message: "Maintenance", Severity: SeverityWarning, ExpiresAt: expires}, valid: true},
+		{name: "missing message", announcement: Announcement{Severity: SeverityInfo, ExpiresAt: expires}},
+		{name: "unknown severity", announcement: Announcement{Message: "Maintenance", Severity: "urgent", ExpiresAt: expires}},
+		{name: "missing expiry", announcement: Announcement{Message: "Maintenance", Severity: SeverityCritical}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.announcement.Validate(); (err == nil) != tt.valid {
+				t.Errorf("Validate() error = %v, valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestDequeueBackoff(t *testing.T) {
+	tests := []struct {
+		failures int64
+		expected time.Duration
+	}{
+		{failures: 1, expected: minDequeueBackoff},
+		{failures: 2, expected: 2 * minDequeueBackoff},
+		{failures: 4, expected: 8 * minDequeueBackoff},
+		{failures: 100, expected: maxDequeueBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := dequeueBackoff(tt.failures); got != tt.expected {
+			t.Errorf("dequeueBackoff(%d) = %v, want %v", tt.failures, got, tt.expected)
+		}
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for range 100 {
+		if got := Jitter(maxDequeueBackoff); got < maxDequeueBackoff/2 || got > maxDequeueBackoff {
+			t.Fatalf("Jitter(%v) = %v, want between half and all of it", maxDequeueBackoff, got)
+		}
+	}
+}
+
+func TestRedisOptionsModes(t *testing.T) {
+	tests := []struct {
+		name        string
+		addrs       []string
+		masterName  string
+		clusterMode bool
+		wantCluster bool
+	}{
+		{name: "single node", addrs: nil, wantCluster: false},
+		{name: "sentinel", addrs: []string{"s1:26379", "s2:26379"}, masterName: "mymaster", wantCluster: false},
+		{name: "cluster nodes", addrs: []string{"n1:6379", "n2:6379"}, wantCluster: true},
+		{name: "cluster endpoint", addrs: []string{"cfg:6379"}, clusterMode: true, wantCluster: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Defaults().Valkey
+			cfg.Addrs, cfg.MasterName, cfg.ClusterMode = tt.addrs, tt.masterName, tt.clusterMode
+			opts := RedisOptions(cfg)
+			if len(opts.Addrs) == 0 {
+				t.Fatal("Expected at least one address")
+			}
+			if got := isClusterMode(opts); got != tt.wantCluster {
+				t.Errorf("isClusterMode() = %v, want %v", got, tt.wantCluster)
+			}
+		})
+	}
+}
+
+func TestWaitingQueueFor(t *testing.T) {
+	q := NewQueueWithConfig(nil, DefaultConfig())
+
+	if got := q.waitingQueueFor(PriorityInteractive); got != PriorityQueue {
+		t.Errorf("Expected interactive jobs in %s, got %s", PriorityQueue, got)
+	}
+	if got := q.waitingQueueFor(PriorityBackground); got != WaitingQueue {
+		t.Errorf("Expected background jobs in %s, got %s", WaitingQueue, got)
+	}
+	if got := q.waitingQueues(); len(got) != 2 || got[0] != PriorityQueue {
+		t.Errorf("Expected priority queue to be drained first, got %v", got)
+	}
+
+	// Configs without a priority list fall back to a single queue
+	cfg := DefaultConfig()
+	cfg.PriorityQueue = ""
+	q = NewQueueWithConfig(nil, cfg)
+	if got := q.waitingQueueFor(PriorityInteractive); got != WaitingQueue {
+		t.Errorf("Expected fallback to %s, got %s", WaitingQueue, got)
+	}
+}
+
+func TestClusterConfigSharesHashTag(t *testing.T) {
+	cfg := ClusterConfig()
+	for _, key := range []string{cfg.WaitingQueue, cfg.PriorityQueue, cfg.ScheduledSet, cfg.RunningUsersKey, cfg.RunningQueue, cfg.SuccessSet, cfg.FailedSet, cfg.DeadLetterSet, cfg.CleanupSet} {
+		if !strings.HasPrefix(key, "{cobblepod}:") {
+			t.Errorf("Expected key %q to share the {cobblepod} hash tag", key)
+		}
+	}
+}
+
+func TestPendingItems(t *testing.T) {
+	items := []JobItem{
+		{ID: "a", Status: StatusCompleted},
+		{ID: "b", Status: StatusPending},
+		{ID: "c"},
+		{ID: "d", Status: StatusFailed},
+	}
+	if got := pendingItems(items); got != 2 {
+		t.Errorf("Expected 2 pending items, got %d", got)
+	}
+}
+
+func TestReserveEncodingEpisodesPerJob(t *testing.T) {
+	q := NewQueueWithConfig(nil, DefaultConfig())
+	cfg := config.Defaults().Worker
+	cfg.MaxEpisodesPerJob = 10
+	q.SetWorkerConfig(cfg)
+	ctx := context.Background()
+
+	if err := q.ReserveEncoding(ctx, "user-1", "job-1", 10, 0); err != nil {
+		t.Errorf("Expected 10 episodes to fit, got %v", err)
+	}
+	if err := q.ReserveEncoding(ctx, "user-1", "job-1", 11, 0); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded, got %v", err)
+	}
+}
+
+func TestDequeueBacksOffWhenRedisUnavailable(t *testing.T) {
+	// Nothing listens on port 1, so every BZPOPMIN fails fast
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer client.Close()
+	q := NewQueueWithClient(client)
+	ctx := context.Background()
+
+	start := time.Now()
+	if _, err := q.Dequeue(ctx); err == nil {
+		t.Fatal("Expected dequeue to fail while Redis is unavailable")
+	}
+	if elapsed := time.Since(start); elapsed < minDequeueBackoff {
+		t.Errorf("Expected dequeue to back off at least %v, returned after %v", minDequeueBackoff, elapsed)
+	}
+	if failures := q.dequeueFailures.Load(); failures != 1 {
+		t.Errorf("Expected 1 recorded failure, got %d", failures)
+	}
+
+	// A cancelled context ends the backoff early and is returned as-is
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := q.Dequeue(cancelled); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestUserAgentProduct(t *testing.T) {
+	tests := map[string]string{
+		"AntennaPod/3.4.0":                    "AntennaPod",
+		"Overcast/3.0 (+http://overcast.fm/)": "Overcast",
+		"Pocket Casts":                        "Pocket",
+		"  ":                                  "unknown",
+		strings.Repeat("x", 100):              strings.Repeat("x", maxUserAgentLength),
+	}
+	for userAgent, want := range tests {
+		if got := userAgentProduct(userAgent); got != want {
+			t.Errorf("userAgentProduct(%q) = %q, want %q", userAgent, got, want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// quotaRetention keeps a day's usage around until the day is over everywhere
+const quotaRetention = 48 * time.Hour
+
+// ErrQuotaExceeded is returned when a job would take its user past one of the
+// configured quotas
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// reserveJob counts a new job against the user's day if they have jobs and
+// processing time left. Returns 1 when out of jobs, 2 when out of time.
+var reserveJob = redis.NewScript(`
+local jobs = tonumber(redis.call("HGET", KEYS[1], "jobs") or "0")
+if tonumber(ARGV[1]) > 0 and jobs >= tonumber(ARGV[1]) then
+	return 1
+end
+local seconds = tonumber(redis.call("HGET", KEYS[1], "seconds") or "0")
+if tonumber(ARGV[2]) > 0 and seconds >= tonumber(ARGV[2]) then
+	return 2
+end
+redis.call("HINCRBY", KEYS[1], "jobs", 1)
+redis.call("EXPIRE", KEYS[1], ARGV[3])
+return 0
+`)
+
+// releaseJob uncounts a job reserveJob counted that was never stored
+var releaseJob = redis.NewScript(`
+if tonumber(redis.call("HGET", KEYS[1], "jobs") or "0") > 0 then
+	redis.call("HINCRBY", KEYS[1], "jobs", -1)
+end
+return 1
+`)
+
+// reserveEncoding counts a job's audio against the user's day if it fits in
+// what is left. The job's hash (KEYS[2]) records what it reserved, and in
+// which day's usage, so running it again only counts audio beyond that.
+var reserveEncoding = redis.NewScript(`
+local held = tonumber(redis.call("HGET", KEYS[2], "reserved_seconds") or "0")
+local charge = tonumber(ARGV[1]) - held
+if charge <= 0 then
+	return -1
+end
+local seconds = tonumber(redis.call("HGET", KEYS[1], "seconds") or "0")
+if seconds + charge > tonumber(ARGV[2]) then
+	return seconds
+end
+redis.call("HINCRBY", KEYS[1], "seconds", charge)
+redis.call("EXPIRE", KEYS[1], ARGV[3])
+redis.call("HSET", KEYS[2], "reserved_seconds", ARGV[1], "reserved_usage", KEYS[1])
+return -1
+`)
+
+// refundEncoding gives back the audio a job reserved, if it still holds
+// ARGV[1] seconds of KEYS[1]
+var refundEncoding = redis.NewScript(`
+if redis.call("HGET", KEYS[2], "reserved_seconds") ~= ARGV[1] then
+	return 0
+end
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	local seconds = tonumber(redis.call("HGET", KEYS[1], "seconds") or "0")
+	redis.call("HSET", KEYS[1], "seconds", math.max(seconds - tonumber(ARGV[1]), 0))
+end
+redis.call("HDEL", KEYS[2], "reserved_seconds", "reserved_usage")
+return 1
+`)
+
+// userUsageKey returns the Redis hash of what a user used of their quotas on a UTC day
+func (q *Queue) userUsageKey(userID string, day time.Time) string {
+	return fmt.Sprintf("%s:user:%s:usage:%s", q.config.KeyPrefix, userID, day.UTC().Format(time.DateOnly))
+}
+
+// reserveJobQuota counts a new job against its user's daily quotas, or returns
+// ErrQuotaExceeded if it doesn't fit. Only jobs that already know their items,
+// like retries, are checked against MaxEpisodesPerJob here; the rest
+// are checked when a worker lists their episodes, see ReserveEncoding.
+func (q *Queue) reserveJobQuota(ctx context.Context, job *Job) error {
+	if episodes := pendingItems(job.Items); q.worker.MaxEpisodesPerJob > 0 && episodes > q.worker.MaxEpisodesPerJob {
+		return fmt.Errorf("%w: the job has %d episodes to process, more than the %d allowed per job", ErrQuotaExceeded, episodes, q.worker.MaxEpisodesPerJob)
+	}
+	if q.worker.MaxJobsPerDay <= 0 && q.worker.MaxEncodedPerDay() <= 0 {
+		return nil
+	}
+
+	keys := []string{q.userUsageKey(job.UserID, time.Now())}
+	args := []interface{}{q.worker.MaxJobsPerDay, int64(q.worker.MaxEncodedPerDay().Seconds()), int64(quotaRetention.Seconds())}
+	result, err := reserveJob.Run(ctx, q.client, keys, args...).Int()
+	if err != nil {
+		return fmt.Errorf("failed to check quota: %w", err)
+	}
+	switch result {
+	case 1:
+		return fmt.Errorf("%w: at most %d jobs may be queued per day", ErrQuotaExceeded, q.worker.MaxJobsPerDay)
+	case 2:
+		return fmt.Errorf("%w: the daily %s of processing is used up", ErrQuotaExceeded, q.worker.MaxEncodedPerDay())
+	}
+	return nil
+}
+
+// releaseJobQuota uncounts a job reserveJobQuota counted, for jobs that failed
+// to be stored
+func (q *Queue) releaseJobQuota(ctx context.Context, job *Job) error {
+	if q.worker.MaxJobsPerDay <= 0 && q.worker.MaxEncodedPerDay() <= 0 {
+		return nil
+	}
+	if err := releaseJob.Run(ctx, q.client, []string{q.userUsageKey(job.UserID, time.Now())}).Err(); err != nil {
+		return fmt.Errorf("failed to release quota: %w", err)
+	}
+	return nil
+}
+
+// pendingItems counts the items a job has yet to process
+func pendingItems(items []JobItem) int {
+	pending := 0
+	for _, item := range items {
+		if item.Status == StatusPending || item.Status == "" {
+			pending++
+		}
+	}
+	return pending
+}
+
+// ReserveEncoding counts the new episodes a job is about to process against
+// its user's quotas, or returns ErrQuotaExceeded if they don't fit. The
+// reservation belongs to the job: when a resumed or retried run of it reserves
+// again, only audio beyond what it already holds is counted, and FailJob
+// gives it back.
+func (q *Queue) ReserveEncoding(ctx context.Context, userID, jobID string, episodes int, audio time.Duration) error {
+	if q.worker.MaxEpisodesPerJob > 0 && episodes > q.worker.MaxEpisodesPerJob {
+		return fmt.Errorf("%w: the job has %d new episodes, more than the %d allowed per job", ErrQuotaExceeded, episodes, q.worker.MaxEpisodesPerJob)
+	}
+	if q.worker.MaxEncodedPerDay() <= 0 || audio <= 0 {
+		return nil
+	}
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+
+	keys := []string{q.userUsageKey(userID, time.Now()), q.jobKey(jobID)}
+	args := []interface{}{int64(audio.Seconds()), int64(q.worker.MaxEncodedPerDay().Seconds()), int64(quotaRetention.Seconds())}
+	used, err := reserveEncoding.Run(ctx, q.client, keys, args...).Int64()
+	if err != nil {
+		return fmt.Errorf("failed to check quota: %w", err)
+	}
+	if used >= 0 {
+		left := max(q.worker.MaxEncodedPerDay()-time.Duration(used)*time.Second, 0)
+		return fmt.Errorf("%w: the job has %s of new audio but only %s of today's processing is left", ErrQuotaExceeded, audio.Round(time.Minute), left.Round(time.Minute))
+	}
+	return nil
+}
+
+// refundEncoding queues giving back the audio a failed job reserved with
+// ReserveEncoding on the pipeline
+func (q *Queue) refundEncoding(ctx context.Context, pipe redis.Pipeliner, jobID string) error {
+	reserved, err := q.client.HMGet(ctx, q.jobKey(jobID), "reserved_seconds", "reserved_usage").Result()
+	if err != nil {
+		return fmt.Errorf("failed to get quota reservation: %w", err)
+	}
+	seconds, _ := reserved[0].(string)
+	usageKey, _ := reserved[1].(string)
+	if seconds == "" || usageKey == "" {
+		return nil
+	}
+	refundEncoding.Eval(ctx, pipe, []string{usageKey, q.jobKey(jobID)}, seconds)
+	return nil
+}
@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"cobblepod/internal/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// minDequeueBackoff is the wait after the first failed BZPOPMIN
+	minDequeueBackoff = 100 * time.Millisecond
+	// maxDequeueBackoff caps the wait between BZPOPMIN attempts while Redis is unavailable
+	maxDequeueBackoff = 10 * time.Second
+)
+
+// RedisOptions builds client options from cfg. A single address gives a plain
+// client, a master name gives a sentinel-backed failover client, and several
+// addresses (or cluster mode) give a cluster client.
+func RedisOptions(cfg config.ValkeyConfig) *redis.UniversalOptions {
+	addrs := cfg.Addrs
+	if len(addrs) == 0 {
+		addrs = []string{fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)}
+	}
+
+	return &redis.UniversalOptions{
+		Addrs:         addrs,
+		MasterName:    cfg.MasterName,
+		IsClusterMode: cfg.ClusterMode,
+		Password:      cfg.Password,
+		// Ride out a primary switch instead of surfacing every dropped connection
+		MaxRetries:      5,
+		MinRetryBackoff: 100 * time.Millisecond,
+		MaxRetryBackoff: 2 * time.Second,
+	}
+}
+
+// isClusterMode reports whether the options select a Redis Cluster client
+func isClusterMode(opts *redis.UniversalOptions) bool {
+	return opts.MasterName == "" && (len(opts.Addrs) > 1 || opts.IsClusterMode)
+}
+
+// dequeueBackoff returns how long to wait after the given number of consecutive
+// BZPOPMIN failures, doubling from minDequeueBackoff up to maxDequeueBackoff
+func dequeueBackoff(failures int64) time.Duration {
+	backoff := minDequeueBackoff
+	for i := int64(1); i < failures && backoff < maxDequeueBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxDequeueBackoff {
+		backoff = maxDequeueBackoff
+	}
+	return backoff
+}
+
+// Jitter returns a random duration between d/2 and d, so replicas backing off
+// from the same outage don't retry in lockstep
+func Jitter(d time.Duration) time.Duration {
+	if d < 2 {
+		return d
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// waitForRetry sleeps for d or until ctx is cancelled
+func waitForRetry(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
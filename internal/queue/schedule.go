@@ -0,0 +1,123 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// ScheduleInterval is how often RunScheduler looks for due jobs
+	ScheduleInterval = 15 * time.Second
+	// promoteBatchSize caps how many due jobs are moved per pass
+	promoteBatchSize = 100
+)
+
+// promoteScheduledJob moves a due job from the scheduled set into a waiting set.
+// A deferred job keeps the fair share score it was enqueued with; a job
+// scheduled with EnqueueAt joins at ARGV[3], now. The ZREM makes the move
+// atomic, so concurrent schedulers never promote a job twice.
+var promoteScheduledJob = redis.NewScript(`
+if redis.call("ZREM", KEYS[1], ARGV[1]) == 1 then
+	redis.call("ZADD", KEYS[2], redis.call("HGET", KEYS[3], "fair_score") or ARGV[3], ARGV[1])
+	redis.call("HSET", KEYS[3], "status", ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// EnqueueAt stores a job that should not be processed before runAt. The job is
+// visible as waiting (with status scheduled) until PromoteDueJobs moves it into
+// the waiting queue. Like Enqueue, it checks the user's quotas.
+func (q *Queue) EnqueueAt(ctx context.Context, job *Job, runAt time.Time) error {
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+	if !runAt.After(time.Now()) {
+		return q.Enqueue(ctx, job)
+	}
+	if err := q.reserveJobQuota(ctx, job); err != nil {
+		return err
+	}
+
+	job.Status = JobStatusScheduled
+	job.RunAt = runAt
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now()
+	}
+
+	pipe := q.client.Pipeline()
+	if err := q.storeJob(ctx, pipe, job); err != nil {
+		q.releaseEnqueueCharges(ctx, job, nil)
+		return err
+	}
+	pipe.ZAdd(ctx, q.config.ScheduledSet, redis.Z{Score: float64(runAt.Unix()), Member: job.ID})
+	q.publishEvent(ctx, pipe, job.UserID, Event{Type: EventJobCreated, JobID: job.ID, Status: job.Status})
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		q.releaseEnqueueCharges(ctx, job, nil)
+		return fmt.Errorf("failed to schedule job: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Job scheduled", "job_id", job.ID, "file_id", job.FileID, "run_at", runAt)
+	return nil
+}
+
+// PromoteDueJobs moves scheduled jobs whose run time has passed into the waiting
+// queue and returns how many were promoted
+func (q *Queue) PromoteDueJobs(ctx context.Context) (int, error) {
+	if q.client == nil {
+		return 0, fmt.Errorf("queue is not connected")
+	}
+
+	jobIDs, err := q.client.ZRangeByScore(ctx, q.config.ScheduledSet, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().Unix(), 10),
+		Count: promoteBatchSize,
+	}).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get due jobs: %w", err)
+	}
+
+	promoted := 0
+	for _, jobID := range jobIDs {
+		priority, err := q.client.HGet(ctx, q.jobKey(jobID), "priority").Result()
+		if err != nil && err != redis.Nil {
+			return promoted, fmt.Errorf("failed to get job priority: %w", err)
+		}
+
+		keys := []string{q.config.ScheduledSet, q.waitingQueueFor(priority), q.jobKey(jobID)}
+		moved, err := promoteScheduledJob.Run(ctx, q.client, keys, jobID, JobStatusQueued, time.Now().UnixMilli()).Int()
+		if err != nil {
+			return promoted, fmt.Errorf("failed to promote job %s: %w", jobID, err)
+		}
+		if moved == 1 {
+			slog.InfoContext(ctx, "Scheduled job is due", "job_id", jobID)
+			promoted++
+		}
+	}
+
+	return promoted, nil
+}
+
+// RunScheduler promotes due jobs every interval until ctx is cancelled
+func (q *Queue) RunScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := q.PromoteDueJobs(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Failed to promote scheduled jobs", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// searchTitlesField is the job hash field indexing the titles of the job's
+// episodes for search, lowercased and one per line. Reading it spares a
+// search from loading every job's items.
+const searchTitlesField = "search_titles"
+
+// searchTitles returns the search index entry of items
+func searchTitles(items []JobItem) string {
+	titles := make([]string, len(items))
+	for i, item := range items {
+		titles[i] = strings.ToLower(item.Title)
+	}
+	return strings.Join(titles, "\n")
+}
+
+// indexJobSearch queues updating a job's search index entry
+func (q *Queue) indexJobSearch(ctx context.Context, pipe redis.Pipeliner, jobID string, items []JobItem) {
+	pipe.HSet(ctx, q.jobKey(jobID), searchTitlesField, searchTitles(items))
+}
+
+// MatchesSearch reports whether every word of query appears in the job's
+// filename or one of its episode titles, or starts its status, ignoring case.
+// An empty query matches every job.
+func (j *Job) MatchesSearch(query string) bool {
+	return matchesSearch(searchTerms(query), strings.ToLower(j.Filename), j.Status, searchTitles(j.Items))
+}
+
+// searchTerms splits a search query into lowercase words
+func searchTerms(query string) []string {
+	return strings.Fields(strings.ToLower(query))
+}
+
+// matchesSearch reports whether every term matches the lowercased filename,
+// titles or the status
+func matchesSearch(terms []string, filename, status, titles string) bool {
+	for _, term := range terms {
+		if !strings.Contains(filename, term) && !strings.Contains(titles, term) && !strings.HasPrefix(status, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterBySearch keeps the job IDs matching query, see Job.MatchesSearch. Jobs
+// created before the search index existed are indexed as they're searched.
+func (q *Queue) filterBySearch(ctx context.Context, jobIDs []string, query string) ([]string, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 || len(jobIDs) == 0 {
+		return jobIDs, nil
+	}
+
+	pipe := q.client.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(jobIDs))
+	for i, id := range jobIDs {
+		cmds[i] = pipe.HMGet(ctx, q.jobKey(id), "filename", "status", searchTitlesField)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get job search index: %w", err)
+	}
+
+	filtered := make([]string, 0, len(jobIDs))
+	for i, id := range jobIDs {
+		fields := cmds[i].Val()
+		filename, _ := fields[0].(string)
+		status, exists := fields[1].(string)
+		if !exists {
+			continue // expired
+		}
+		titles, indexed := fields[2].(string)
+		if !indexed {
+			var err error
+			if titles, err = q.backfillJobSearch(ctx, id); err != nil {
+				return nil, err
+			}
+		}
+		if matchesSearch(terms, strings.ToLower(filename), status, titles) {
+			filtered = append(filtered, id)
+		}
+	}
+	return filtered, nil
+}
+
+// indexExistingJob sets a field of a job hash unless the job expired, which
+// would leave a stray hash behind
+var indexExistingJob = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+return 1
+`)
+
+// backfillJobSearch indexes a job created before the search index existed and
+// returns its entry
+func (q *Queue) backfillJobSearch(ctx context.Context, jobID string) (string, error) {
+	itemsJSON, err := q.client.HVals(ctx, q.jobItemsKey(jobID)).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to get job items: %w", err)
+	}
+	items := make([]JobItem, 0, len(itemsJSON))
+	for _, itemJSON := range itemsJSON {
+		var item JobItem
+		if err := json.Unmarshal([]byte(itemJSON), &item); err == nil {
+			items = append(items, item)
+		}
+	}
+
+	titles := searchTitles(items)
+	if err := indexExistingJob.Run(ctx, q.client, []string{q.jobKey(jobID)}, searchTitlesField, titles).Err(); err != nil {
+		return "", fmt.Errorf("failed to index job: %w", err)
+	}
+	return titles, nil
+}
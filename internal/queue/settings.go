@@ -0,0 +1,539 @@
+package queue
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"cobblepod/internal/audio"
+	"cobblepod/internal/config"
+	"cobblepod/internal/cron"
+	"cobblepod/internal/safehttp"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// MaxRetentionDays caps how long dropped episodes may stay in a feed
+	MaxRetentionDays = 365
+	// MaxJobRetentionDays caps how long a user may keep their job history
+	MaxJobRetentionDays = 365
+	// MaxFeedTitleLength caps the length of a custom feed title
+	MaxFeedTitleLength = 200
+	// MinBitrateKbps and MaxBitrateKbps bound the output bitrate users may choose
+	MinBitrateKbps = 16
+	MaxBitrateKbps = 320
+	// MaxSpeedProfiles caps how many podcasts a user may give their own speed
+	MaxSpeedProfiles = 100
+	// MaxSkipRules caps how many podcasts a user may skip parts of, and
+	// MaxSkipChapters the chapter titles each rule may cut
+	MaxSkipRules    = 100
+	MaxSkipChapters = 20
+	// MaxSkipSeconds caps how much a skip rule may cut from the start or end
+	MaxSkipSeconds = 3600
+)
+
+var (
+	// ErrInvalidTimeZone is returned when a settings update names an unknown IANA time zone
+	ErrInvalidTimeZone = errors.New("invalid time zone")
+	// ErrInvalidSettings is returned when any other setting is out of range
+	ErrInvalidSettings = errors.New("invalid settings")
+)
+
+// UserSettings holds per-user preferences. Zero values mean the deployment default.
+type UserSettings struct {
+	// TimeZone is an IANA zone name (e.g. "America/Toronto"); empty means UTC
+	TimeZone string `json:"time_zone" redis:"time_zone"`
+	// Speed is the playback speed episodes are processed at; zero means config.DefaultSpeed
+	Speed float64 `json:"speed" redis:"speed"`
+	// TrimSilence removes long silences while processing
+	TrimSilence bool `json:"trim_silence" redis:"trim_silence"`
+	// OutputFormat is the extension episodes are encoded to (e.g. "m4a"); empty means mp3
+	OutputFormat string `json:"output_format" redis:"output_format"`
+	// BitrateKbps is the audio bitrate episodes are encoded at; zero leaves it to the encoder
+	BitrateKbps int `json:"bitrate_kbps" redis:"bitrate_kbps"`
+	// Mono downmixes episodes to a single channel, which suits speech
+	Mono bool `json:"mono" redis:"mono"`
+	// SpokenPreamble starts every episode with a synthesized announcement of its
+	// show, title and publish date, when the deployment has TTS configured
+	SpokenPreamble bool `json:"spoken_preamble" redis:"spoken_preamble"`
+	// Transcripts publishes a transcript with every processed episode, when the
+	// deployment has transcription configured
+	Transcripts bool `json:"transcripts" redis:"transcripts"`
+	// RetentionDays keeps episodes that left the playlist in the feed for this many
+	// days; zero removes them on the next run
+	RetentionDays int `json:"retention_days" redis:"retention_days"`
+	// FeedTitle replaces the default channel title of the feed
+	FeedTitle string `json:"feed_title" redis:"feed_title"`
+	// JobRetentionDays keeps finished jobs in the history for this many days;
+	// zero means the deployment's job retention
+	JobRetentionDays int `json:"job_retention_days" redis:"job_retention_days"`
+	// NotificationEmail receives a summary when a job finishes; empty means the
+	// deployment's notification email
+	NotificationEmail string `json:"notification_email" redis:"notification_email"`
+	// WebhookURL is an https URL receiving a JSON POST for every notification
+	WebhookURL string `json:"webhook_url" redis:"webhook_url"`
+	// TelegramChatID is the chat the deployment's Telegram bot messages
+	TelegramChatID string `json:"telegram_chat_id" redis:"telegram_chat_id"`
+	// NtfyTopic is the topic on the deployment's ntfy server notifications are published to
+	NtfyTopic string `json:"ntfy_topic" redis:"ntfy_topic"`
+	// NotifyEvents lists the events to notify on, comma separated; empty means
+	// job.completed and job.failed
+	NotifyEvents string `json:"notify_events" redis:"notify_events"`
+	// PollSchedule is a cron expression of when the user's playlist URL is
+	// polled and their storage changes are picked up, in their time zone (e.g.
+	// "*/15 6-23 * * *"); empty means the deployment's poll schedule for the
+	// playlist URL and storage changes as they come
+	PollSchedule string `json:"poll_schedule" redis:"poll_schedule"`
+	// SpeedProfiles process some podcasts at their own speed and silence
+	// trimming instead of Speed and TrimSilence; stored as JSON in the
+	// speed_profiles field
+	SpeedProfiles []SpeedProfile `json:"speed_profiles,omitempty" redis:"-"`
+	// SkipRules cut parts of some podcasts' episodes, such as recurring intros
+	// or ad chapters; stored as JSON in the skip_rules field
+	SkipRules []SkipRule `json:"skip_rules,omitempty" redis:"-"`
+
+	// pollSchedule is PollSchedule parsed when the settings were loaded, so
+	// polling every minute doesn't parse it again
+	pollSchedule *cron.Schedule
+}
+
+// SpeedProfile processes a podcast's episodes at their own speed and filters,
+// e.g. news at 2x with silences trimmed and fiction at 1x untouched
+type SpeedProfile struct {
+	// Podcast is the show's name, as it starts episode titles ("<show> - <episode>"),
+	// or its feed URL
+	Podcast string `json:"podcast"`
+	// Speed is the podcast's speed; zero means the user's Speed
+	Speed float64 `json:"speed,omitempty"`
+	// TrimSilence, when set, replaces the user's TrimSilence for the podcast
+	TrimSilence *bool `json:"trim_silence,omitempty"`
+}
+
+// SkipRule cuts parts of a podcast's episodes while processing them
+type SkipRule struct {
+	// Podcast is the show's name or feed URL, as for SpeedProfile
+	Podcast string `json:"podcast"`
+	// SkipStartSeconds and SkipEndSeconds cut this much from the start and end
+	// of every episode, e.g. a recurring intro or sponsor read
+	SkipStartSeconds int `json:"skip_start_seconds,omitempty"`
+	SkipEndSeconds   int `json:"skip_end_seconds,omitempty"`
+	// Chapters cuts the chapters with these titles, ignoring case, e.g. "Ads"
+	Chapters []string `json:"chapters,omitempty"`
+}
+
+// isEpisodeOf reports whether item is an episode of podcast, given by feed URL
+// or by the show name that starts episode titles ("<show> - <episode>")
+func isEpisodeOf(item JobItem, podcast string) bool {
+	if item.FeedURL != "" && item.FeedURL == podcast {
+		return true
+	}
+	show := strings.TrimSpace(podcast)
+	return len(item.Title) > len(show) && strings.EqualFold(item.Title[:len(show)], show) && strings.HasPrefix(item.Title[len(show):], " - ")
+}
+
+// Cuts returns the parts of an episode of the given length and chapters the
+// rule leaves out, in order, with overlapping parts merged
+func (r SkipRule) Cuts(duration time.Duration, chapters []audio.Chapter) []Cut {
+	var cuts []Cut
+	if r.SkipStartSeconds > 0 {
+		cuts = append(cuts, Cut{Start: 0, End: time.Duration(r.SkipStartSeconds) * time.Second, Reason: "start"})
+	}
+	if r.SkipEndSeconds > 0 && duration > 0 {
+		cuts = append(cuts, Cut{Start: max(duration-time.Duration(r.SkipEndSeconds)*time.Second, 0), End: duration, Reason: "end"})
+	}
+	for _, chapter := range chapters {
+		if slices.ContainsFunc(r.Chapters, func(title string) bool { return strings.EqualFold(strings.TrimSpace(title), chapter.Title) }) {
+			cuts = append(cuts, Cut{Start: chapter.Start, End: chapter.End, Reason: chapter.Title})
+		}
+	}
+	slices.SortFunc(cuts, func(a, b Cut) int { return cmp.Compare(a.Start, b.Start) })
+
+	var merged []Cut
+	for _, cut := range cuts {
+		if n := len(merged); n > 0 && cut.Start <= merged[n-1].End {
+			merged[n-1].End = max(merged[n-1].End, cut.End)
+			merged[n-1].Reason += ", " + cut.Reason
+			continue
+		}
+		merged = append(merged, cut)
+	}
+	return merged
+}
+
+// NotificationEvent is something a user can be notified about
+type NotificationEvent string
+
+const (
+	NotifyJobCompleted NotificationEvent = "job.completed" // including completed with errors
+	NotifyJobFailed    NotificationEvent = "job.failed"
+	NotifyFeedUpdated  NotificationEvent = "feed.updated"
+)
+
+var notificationEvents = []NotificationEvent{NotifyJobCompleted, NotifyJobFailed, NotifyFeedUpdated}
+
+var (
+	// telegramChatIDPattern matches numeric chat IDs and public @channel names
+	telegramChatIDPattern = regexp.MustCompile(`^(-?[0-9]+|@[A-Za-z0-9_]{5,32})$`)
+	// ntfyTopicPattern matches the topic names ntfy accepts
+	ntfyTopicPattern = regexp.MustCompile(`^[-_A-Za-z0-9]{1,64}$`)
+)
+
+// PlaybackSpeed returns the speed to process episodes at
+func (s UserSettings) PlaybackSpeed() float64 {
+	if s.Speed == 0 {
+		return config.DefaultSpeed
+	}
+	return s.Speed
+}
+
+// profileFor returns the speed profile of item's podcast, or nil when the
+// user has none for it
+func (s UserSettings) profileFor(item JobItem) *SpeedProfile {
+	for i, profile := range s.SpeedProfiles {
+		if isEpisodeOf(item, profile.Podcast) {
+			return &s.SpeedProfiles[i]
+		}
+	}
+	return nil
+}
+
+// SpeedFor returns the speed to process item at: its podcast's profile's, if
+// it sets one, otherwise PlaybackSpeed
+func (s UserSettings) SpeedFor(item JobItem) float64 {
+	if profile := s.profileFor(item); profile != nil && profile.Speed != 0 {
+		return profile.Speed
+	}
+	return s.PlaybackSpeed()
+}
+
+// TrimSilenceFor reports whether long silences are removed from item: as its
+// podcast's profile says, if it does, otherwise as TrimSilence says
+func (s UserSettings) TrimSilenceFor(item JobItem) bool {
+	if profile := s.profileFor(item); profile != nil && profile.TrimSilence != nil {
+		return *profile.TrimSilence
+	}
+	return s.TrimSilence
+}
+
+// SkipRuleFor returns the skip rule of item's podcast, or nil when the user
+// doesn't skip any of it
+func (s UserSettings) SkipRuleFor(item JobItem) *SkipRule {
+	for i, rule := range s.SkipRules {
+		if isEpisodeOf(item, rule.Podcast) {
+			return &s.SkipRules[i]
+		}
+	}
+	return nil
+}
+
+// Format returns the format to encode episodes to
+func (s UserSettings) Format() audio.Format {
+	if format, ok := audio.LookupFormat(s.OutputFormat); ok {
+		return format
+	}
+	return audio.FormatMP3
+}
+
+// Encoding describes the bitrate and channels episodes are encoded with, such
+// as "64k mono"; empty means the encoder's defaults
+func (s UserSettings) Encoding() string {
+	var parts []string
+	if s.BitrateKbps > 0 {
+		parts = append(parts, strconv.Itoa(s.BitrateKbps)+"k")
+	}
+	if s.Mono {
+		parts = append(parts, "mono")
+	}
+	return strings.Join(parts, " ")
+}
+
+// Retention returns how long dropped episodes stay in the feed
+func (s UserSettings) Retention() time.Duration {
+	return time.Duration(s.RetentionDays) * 24 * time.Hour
+}
+
+// JobRetention returns how long the user's finished jobs are kept, fallback
+// unless they chose otherwise
+func (s UserSettings) JobRetention(fallback time.Duration) time.Duration {
+	if s.JobRetentionDays > 0 {
+		return time.Duration(s.JobRetentionDays) * 24 * time.Hour
+	}
+	return fallback
+}
+
+// NotificationRecipient returns where job summaries are sent, fallback unless
+// the user set their own address, or "" for nowhere
+func (s UserSettings) NotificationRecipient(fallback string) string {
+	if s.NotificationEmail != "" {
+		return s.NotificationEmail
+	}
+	return fallback
+}
+
+// Notifies reports whether the user wants to be notified about event
+func (s UserSettings) Notifies(event NotificationEvent) bool {
+	if strings.TrimSpace(s.NotifyEvents) == "" {
+		return event == NotifyJobCompleted || event == NotifyJobFailed
+	}
+	for _, e := range strings.Split(s.NotifyEvents, ",") {
+		if NotificationEvent(strings.TrimSpace(e)) == event {
+			return true
+		}
+	}
+	return false
+}
+
+// schedule returns the user's own PollSchedule parsed, or nil without one
+func (s UserSettings) schedule() *cron.Schedule {
+	if s.pollSchedule != nil || s.PollSchedule == "" {
+		return s.pollSchedule
+	}
+	schedule, err := cron.Parse(s.PollSchedule)
+	if err != nil {
+		// Validate keeps these from being saved; poll at no time rather than always
+		return &cron.Schedule{}
+	}
+	return schedule
+}
+
+// Polls reports whether the user's playlist URL is polled in the minute t
+// falls in, by their PollSchedule (or fallback) in their time zone. A nil
+// fallback polls users without a schedule every minute.
+func (s UserSettings) Polls(t time.Time, fallback *cron.Schedule) bool {
+	schedule := cmp.Or(s.schedule(), fallback)
+	if schedule == nil {
+		return true
+	}
+	return schedule.Matches(t.In(s.Location()))
+}
+
+// NextPoll returns when the user's own PollSchedule next includes, from the
+// minute t falls in on: t itself when it does or they have none
+func (s UserSettings) NextPoll(t time.Time) time.Time {
+	schedule := s.schedule()
+	if schedule == nil {
+		return t
+	}
+	next := schedule.Next(t.In(s.Location()))
+	if next.IsZero() || next.Equal(t.Truncate(time.Minute)) {
+		return t
+	}
+	return next
+}
+
+// Location returns the user's time zone, falling back to UTC when unset or unknown
+func (s UserSettings) Location() *time.Location {
+	if s.TimeZone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Validate checks that the settings can be applied
+func (s UserSettings) Validate() error {
+	if s.TimeZone != "" {
+		if _, err := time.LoadLocation(s.TimeZone); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidTimeZone, s.TimeZone)
+		}
+	}
+	if s.Speed != 0 && (s.Speed < config.MinSpeed || s.Speed > config.MaxSpeed) {
+		return fmt.Errorf("%w: speed must be between %g and %g", ErrInvalidSettings, config.MinSpeed, config.MaxSpeed)
+	}
+	if s.OutputFormat != "" {
+		if _, ok := audio.LookupFormat(s.OutputFormat); !ok {
+			return fmt.Errorf("%w: unsupported output format %q", ErrInvalidSettings, s.OutputFormat)
+		}
+	}
+	if s.BitrateKbps != 0 && (s.BitrateKbps < MinBitrateKbps || s.BitrateKbps > MaxBitrateKbps) {
+		return fmt.Errorf("%w: bitrate_kbps must be between %d and %d", ErrInvalidSettings, MinBitrateKbps, MaxBitrateKbps)
+	}
+	if s.RetentionDays < 0 || s.RetentionDays > MaxRetentionDays {
+		return fmt.Errorf("%w: retention_days must be between 0 and %d", ErrInvalidSettings, MaxRetentionDays)
+	}
+	if s.JobRetentionDays < 0 || s.JobRetentionDays > MaxJobRetentionDays {
+		return fmt.Errorf("%w: job_retention_days must be between 0 and %d", ErrInvalidSettings, MaxJobRetentionDays)
+	}
+	if len(s.FeedTitle) > MaxFeedTitleLength {
+		return fmt.Errorf("%w: feed_title is longer than %d characters", ErrInvalidSettings, MaxFeedTitleLength)
+	}
+	if s.NotificationEmail != "" {
+		if addr, err := mail.ParseAddress(s.NotificationEmail); err != nil || addr.Address != s.NotificationEmail {
+			return fmt.Errorf("%w: notification_email is not a valid email address", ErrInvalidSettings)
+		}
+	}
+	if s.WebhookURL != "" {
+		if err := safehttp.CheckURL(s.WebhookURL); err != nil {
+			return fmt.Errorf("%w: webhook_url %v", ErrInvalidSettings, err)
+		}
+	}
+	if s.TelegramChatID != "" && !telegramChatIDPattern.MatchString(s.TelegramChatID) {
+		return fmt.Errorf("%w: telegram_chat_id must be a chat ID or @channel", ErrInvalidSettings)
+	}
+	if s.NtfyTopic != "" && !ntfyTopicPattern.MatchString(s.NtfyTopic) {
+		return fmt.Errorf("%w: ntfy_topic may only contain letters, digits, - and _", ErrInvalidSettings)
+	}
+	if s.PollSchedule != "" {
+		if _, err := cron.Parse(s.PollSchedule); err != nil {
+			return fmt.Errorf("%w: poll_schedule: %v", ErrInvalidSettings, err)
+		}
+	}
+	for _, event := range strings.Split(s.NotifyEvents, ",") {
+		if event = strings.TrimSpace(event); event != "" && !slices.Contains(notificationEvents, NotificationEvent(event)) {
+			return fmt.Errorf("%w: unknown notify event %q", ErrInvalidSettings, event)
+		}
+	}
+	if len(s.SpeedProfiles) > MaxSpeedProfiles {
+		return fmt.Errorf("%w: at most %d speed_profiles", ErrInvalidSettings, MaxSpeedProfiles)
+	}
+	for i, profile := range s.SpeedProfiles {
+		if strings.TrimSpace(profile.Podcast) == "" {
+			return fmt.Errorf("%w: speed_profiles need a podcast name or feed URL", ErrInvalidSettings)
+		}
+		if profile.Speed == 0 && profile.TrimSilence == nil {
+			return fmt.Errorf("%w: speed profile of %q needs a speed or trim_silence", ErrInvalidSettings, profile.Podcast)
+		}
+		if profile.Speed != 0 && (profile.Speed < config.MinSpeed || profile.Speed > config.MaxSpeed) {
+			return fmt.Errorf("%w: speed of %q must be between %g and %g", ErrInvalidSettings, profile.Podcast, config.MinSpeed, config.MaxSpeed)
+		}
+		for _, other := range s.SpeedProfiles[:i] {
+			if strings.EqualFold(strings.TrimSpace(other.Podcast), strings.TrimSpace(profile.Podcast)) {
+				return fmt.Errorf("%w: %q has more than one speed profile", ErrInvalidSettings, profile.Podcast)
+			}
+		}
+	}
+	if len(s.SkipRules) > MaxSkipRules {
+		return fmt.Errorf("%w: at most %d skip_rules", ErrInvalidSettings, MaxSkipRules)
+	}
+	for i, rule := range s.SkipRules {
+		if err := rule.validate(); err != nil {
+			return err
+		}
+		for _, other := range s.SkipRules[:i] {
+			if strings.EqualFold(strings.TrimSpace(other.Podcast), strings.TrimSpace(rule.Podcast)) {
+				return fmt.Errorf("%w: %q has more than one skip rule", ErrInvalidSettings, rule.Podcast)
+			}
+		}
+	}
+	return nil
+}
+
+// validate checks that a skip rule names a podcast and cuts something
+func (r SkipRule) validate() error {
+	if strings.TrimSpace(r.Podcast) == "" {
+		return fmt.Errorf("%w: skip_rules need a podcast name or feed URL", ErrInvalidSettings)
+	}
+	if r.SkipStartSeconds < 0 || r.SkipStartSeconds > MaxSkipSeconds || r.SkipEndSeconds < 0 || r.SkipEndSeconds > MaxSkipSeconds {
+		return fmt.Errorf("%w: skip_start_seconds and skip_end_seconds of %q must be between 0 and %d", ErrInvalidSettings, r.Podcast, MaxSkipSeconds)
+	}
+	if len(r.Chapters) > MaxSkipChapters {
+		return fmt.Errorf("%w: %q may skip at most %d chapters", ErrInvalidSettings, r.Podcast, MaxSkipChapters)
+	}
+	for _, title := range r.Chapters {
+		if strings.TrimSpace(title) == "" {
+			return fmt.Errorf("%w: skipped chapters of %q need a title", ErrInvalidSettings, r.Podcast)
+		}
+	}
+	if r.SkipStartSeconds == 0 && r.SkipEndSeconds == 0 && len(r.Chapters) == 0 {
+		return fmt.Errorf("%w: the skip rule of %q doesn't skip anything", ErrInvalidSettings, r.Podcast)
+	}
+	return nil
+}
+
+// userSettingsKey returns the Redis hash key for a user's settings
+func (q *Queue) userSettingsKey(userID string) string {
+	return fmt.Sprintf("%s:user:%s:settings", q.config.KeyPrefix, userID)
+}
+
+// GetUserSettings returns the user's settings, or defaults if none have been saved
+func (q *Queue) GetUserSettings(ctx context.Context, userID string) (*UserSettings, error) {
+	if userID == "" {
+		return nil, ErrUserIDRequired
+	}
+	if q.client == nil {
+		return nil, fmt.Errorf("queue is not connected")
+	}
+
+	var settings UserSettings
+	cmd := q.client.HGetAll(ctx, q.userSettingsKey(userID))
+	if err := cmd.Scan(&settings); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get user settings: %w", err)
+	}
+	if profiles := cmd.Val()["speed_profiles"]; profiles != "" {
+		if err := json.Unmarshal([]byte(profiles), &settings.SpeedProfiles); err != nil {
+			return nil, fmt.Errorf("failed to decode speed profiles: %w", err)
+		}
+	}
+	if rules := cmd.Val()["skip_rules"]; rules != "" {
+		if err := json.Unmarshal([]byte(rules), &settings.SkipRules); err != nil {
+			return nil, fmt.Errorf("failed to decode skip rules: %w", err)
+		}
+	}
+	settings.pollSchedule = settings.schedule()
+	return &settings, nil
+}
+
+// SaveUserSettings validates and stores the user's settings
+func (q *Queue) SaveUserSettings(ctx context.Context, userID string, settings *UserSettings) error {
+	if userID == "" {
+		return ErrUserIDRequired
+	}
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	fields := map[string]interface{}{
+		"time_zone":       settings.TimeZone,
+		"speed":           strconv.FormatFloat(settings.Speed, 'f', -1, 64),
+		"trim_silence":    settings.TrimSilence,
+		"output_format":   settings.OutputFormat,
+		"bitrate_kbps":    settings.BitrateKbps,
+		"mono":            settings.Mono,
+		"spoken_preamble": settings.SpokenPreamble,
+		"transcripts":     settings.Transcripts,
+		"retention_days":  settings.RetentionDays,
+		"feed_title":      settings.FeedTitle,
+
+		"job_retention_days": settings.JobRetentionDays,
+		"notification_email": settings.NotificationEmail,
+		"webhook_url":        settings.WebhookURL,
+		"telegram_chat_id":   settings.TelegramChatID,
+		"ntfy_topic":         settings.NtfyTopic,
+		"notify_events":      settings.NotifyEvents,
+		"speed_profiles":     "",
+		"skip_rules":         "",
+	}
+	if len(settings.SpeedProfiles) > 0 {
+		profiles, err := json.Marshal(settings.SpeedProfiles)
+		if err != nil {
+			return fmt.Errorf("failed to encode speed profiles: %w", err)
+		}
+		fields["speed_profiles"] = string(profiles)
+	}
+	if len(settings.SkipRules) > 0 {
+		rules, err := json.Marshal(settings.SkipRules)
+		if err != nil {
+			return fmt.Errorf("failed to encode skip rules: %w", err)
+		}
+		fields["skip_rules"] = string(rules)
+	}
+	if err := q.client.HSet(ctx, q.userSettingsKey(userID), fields).Err(); err != nil {
+		return fmt.Errorf("failed to save user settings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// taskClaimKey returns the Redis key held by the replica running a periodic task
+func (q *Queue) taskClaimKey(task string) string {
+	return fmt.Sprintf("%s:task:%s", q.config.KeyPrefix, task)
+}
+
+// ClaimPeriodicTask reports whether this replica should run a task that every
+// worker replica schedules each period, such as cleanup. The first replica to
+// claim the task in a period gets it; the claim lapses shortly before the
+// period ends so the next period's claim isn't lost to clock drift.
+func (q *Queue) ClaimPeriodicTask(ctx context.Context, task string, period time.Duration) (bool, error) {
+	if q.client == nil {
+		return false, fmt.Errorf("queue is not connected")
+	}
+	holder, _ := os.Hostname()
+	claimed, err := q.client.SetNX(ctx, q.taskClaimKey(task), holder, period*9/10).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim task %s: %w", task, err)
+	}
+	return claimed, nil
+}
@@ -0,0 +1,57 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// jobTimelineMaxLen caps a job's timeline; a job with a few hundred items
+// produces several events per item
+const jobTimelineMaxLen = 5000
+
+// jobTimelineKey returns the Redis stream holding a job's event history
+func (q *Queue) jobTimelineKey(jobID string) string {
+	return fmt.Sprintf("%s:job:%s:timeline", q.config.KeyPrefix, jobID)
+}
+
+// recordEvent queues appending an already marshalled event to its job's
+// timeline. The timeline outlives the job's last event by the deployment's job retention,
+// and jobs that finish reset it to their user's retention.
+func (q *Queue) recordEvent(ctx context.Context, pipe redis.Pipeliner, jobID string, payload []byte) {
+	key := q.jobTimelineKey(jobID)
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: key,
+		MaxLen: jobTimelineMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{"event": payload},
+	})
+	pipe.Expire(ctx, key, q.worker.JobRetention())
+}
+
+// JobTimeline returns every event recorded for a job, oldest first
+func (q *Queue) JobTimeline(ctx context.Context, jobID string) ([]Event, error) {
+	if q.client == nil {
+		return nil, fmt.Errorf("queue is not connected")
+	}
+
+	entries, err := q.client.XRange(ctx, q.jobTimelineKey(jobID), "-", "+").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job timeline: %w", err)
+	}
+
+	events := make([]Event, 0, len(entries))
+	for _, entry := range entries {
+		payload, _ := entry.Values["event"].(string)
+		var event Event
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			slog.ErrorContext(ctx, "Failed to unmarshal timeline event", "error", err, "job_id", jobID)
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
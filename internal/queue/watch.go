@@ -0,0 +1,117 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// WatchChannel maps a storage push notification channel to the user it watches
+type WatchChannel struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	Token      string    `json:"token"` // Shared secret echoed back on every notification
+	ResourceID string    `json:"resource_id"`
+	Expiration time.Time `json:"expiration"`
+}
+
+// ChangeJobTTL bounds how long a change job claimed with ClaimChangeJob keeps
+// further notifications from enqueueing another once it's due, in case it's
+// never dequeued
+const ChangeJobTTL = time.Hour
+
+// watchChannelKey returns the Redis key for a watch channel
+func (q *Queue) watchChannelKey(channelID string) string {
+	return fmt.Sprintf("%s:watch:%s", q.config.KeyPrefix, channelID)
+}
+
+// SaveWatchChannel stores a channel until it expires
+func (q *Queue) SaveWatchChannel(ctx context.Context, channel *WatchChannel) error {
+	if channel.UserID == "" {
+		return ErrUserIDRequired
+	}
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+
+	data, err := json.Marshal(channel)
+	if err != nil {
+		return fmt.Errorf("failed to marshal watch channel: %w", err)
+	}
+
+	ttl := time.Until(channel.Expiration)
+	if channel.Expiration.IsZero() || ttl <= 0 {
+		ttl = q.worker.JobRetention()
+	}
+	if err := q.client.Set(ctx, q.watchChannelKey(channel.ID), data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save watch channel: %w", err)
+	}
+	return nil
+}
+
+// GetWatchChannel returns the channel with the given ID, or nil if it is unknown or expired
+func (q *Queue) GetWatchChannel(ctx context.Context, channelID string) (*WatchChannel, error) {
+	if q.client == nil {
+		return nil, fmt.Errorf("queue is not connected")
+	}
+
+	data, err := q.client.Get(ctx, q.watchChannelKey(channelID)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get watch channel: %w", err)
+	}
+
+	var channel WatchChannel
+	if err := json.Unmarshal([]byte(data), &channel); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal watch channel: %w", err)
+	}
+	return &channel, nil
+}
+
+// changeJobKey returns the Redis key holding the ID of the user's change job
+// that hasn't started yet
+func (q *Queue) changeJobKey(userID string) string {
+	return fmt.Sprintf("%s:user:%s:change-job", q.config.KeyPrefix, userID)
+}
+
+// ClaimChangeJob records jobID as the job that will pick up the user's storage
+// changes, unless another such job is still waiting to start. A burst of
+// notifications thus enqueues a single job, while a notification arriving
+// after that job started enqueues a follow-up. It reports whether jobID was
+// claimed; the claim is released when the job is dequeued. A job that runs at
+// runAt holds its claim until then.
+func (q *Queue) ClaimChangeJob(ctx context.Context, userID string, jobID string, runAt time.Time) (bool, error) {
+	if userID == "" {
+		return false, ErrUserIDRequired
+	}
+	if q.client == nil {
+		return false, fmt.Errorf("queue is not connected")
+	}
+
+	ttl := ChangeJobTTL + max(time.Until(runAt), 0)
+	claimed, err := q.client.SetNX(ctx, q.changeJobKey(userID), jobID, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim change job: %w", err)
+	}
+	return claimed, nil
+}
+
+// ReleaseChangeJob drops the claim ClaimChangeJob made for jobID, so the next
+// notification can enqueue a job
+func (q *Queue) ReleaseChangeJob(ctx context.Context, userID string, jobID string) error {
+	if userID == "" {
+		return ErrUserIDRequired
+	}
+	if q.client == nil {
+		return fmt.Errorf("queue is not connected")
+	}
+	if err := compareAndDelete.Run(ctx, q.client, []string{q.changeJobKey(userID)}, jobID).Err(); err != nil {
+		return fmt.Errorf("failed to release change job: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Package safehttp makes requests to URLs users supply, such as notification
+// webhooks and playlists, without letting them reach the deployment's own
+// network: loopback, private ranges, link-local addresses (which include cloud
+// metadata servers) and the like.
+package safehttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrNonPublicAddress is returned for hosts that aren't on the public internet
+var ErrNonPublicAddress = errors.New("address is not public")
+
+// dialTimeout bounds connecting to a host, within the client's own timeout
+const dialTimeout = 10 * time.Second
+
+// reserved are ranges netip doesn't classify that are still not public
+var reserved = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "This network"
+	netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // Benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // Reserved
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, which maps onto IPv4 addresses
+}
+
+// IsPublic reports whether ip is a unicast address on the public internet
+func IsPublic(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range reserved {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewClient returns a client that only connects to public addresses. The check
+// runs on the address each connection dials, after DNS resolution, so a name
+// resolving to an internal address is refused too, as are redirects to one.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: dialTimeout, Control: control}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would connect on the client's behalf, past the check
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// control refuses to connect to non-public addresses
+func control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !IsPublic(ip) {
+		return fmt.Errorf("%w: %s", ErrNonPublicAddress, ip)
+	}
+	return nil
+}
+
+// CheckURL validates a user supplied URL before it's saved: it must be https
+// and must not name a host that is obviously internal. Names are only resolved
+// when connecting, see NewClient.
+func CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return errors.New("must be an https URL")
+	}
+	return CheckHost(u.Hostname())
+}
+
+// CheckHost refuses a URL's host when it is obviously internal, for URLs that
+// may use another scheme than CheckURL allows
+func CheckHost(host string) error {
+	host = strings.ToLower(host)
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".internal") {
+		return fmt.Errorf("%w: %s", ErrNonPublicAddress, host)
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !IsPublic(ip) {
+		return fmt.Errorf("%w: %s", ErrNonPublicAddress, host)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package safehttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{ip: "8.8.8.8", public: true},
+		{ip: "2606:4700:4700::1111", public: true},
+		{ip: "127.0.0.1"},
+		{ip: "10.1.2.3"},
+		{ip: "172.16.0.1"},
+		{ip: "192.168.1.1"},
+		{ip: "169.254.169.254"},
+		{ip: "100.64.0.1"},
+		{ip: "0.0.0.0"},
+		{ip: "::1"},
+		{ip: "fd00:ec2::254"},
+		{ip: "fe80::1"},
+		{ip: "::ffff:127.0.0.1"},
+	}
+	for _, tt := range tests {
+		if got := IsPublic(netip.MustParseAddr(tt.ip)); got != tt.public {
+			t.Errorf("IsPublic(%s) = %v, want %v", tt.ip, got, tt.public)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{url: "https://example.com/hook", valid: true},
+		{url: "https://203.0.113.10:8443/hook", valid: true},
+		{url: "http://example.com/hook"},
+		{url: "example.com/hook"},
+		{url: "file:///etc/passwd"},
+		{url: "https://localhost/hook"},
+		{url: "https://metadata.google.internal/computeMetadata/v1/"},
+		{url: "https://169.254.169.254/latest/meta-data/"},
+		{url: "https://[::1]/hook"},
+		{url: "https://10.0.0.5/hook"},
+	}
+	for _, tt := range tests {
+		if err := CheckURL(tt.url); (err == nil) != tt.valid {
+			t.Errorf("CheckURL(%q) = %v, want valid %v", tt.url, err, tt.valid)
+		}
+	}
+}
+
+func TestNewClientRefusesLoopback(t *testing.T) {
+	requested := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+	}))
+	defer server.Close()
+
+	_, err := NewClient(5 * time.Second).Get(server.URL)
+	if !errors.Is(err, ErrNonPublicAddress) {
+		t.Errorf("Expected ErrNonPublicAddress, got %v", err)
+	}
+	if requested {
+		t.Error("Expected the request not to reach the server")
+	}
+}
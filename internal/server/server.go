@@ -5,10 +5,17 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
+	"cobblepod/internal/auth"
+	"cobblepod/internal/config"
 	"cobblepod/internal/endpoints"
+	"cobblepod/internal/health"
+	"cobblepod/internal/metrics"
 	"cobblepod/internal/queue"
+	"cobblepod/internal/sourcecheck"
+	"cobblepod/internal/state"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,12 +23,14 @@ import (
 // Server wraps the HTTP server
 type Server struct {
 	httpServer *http.Server
-	router     *gin.Engine
-	queue      *queue.Queue
+	// adminServer serves probes and metrics on the internal admin port
+	adminServer *http.Server
+	router      *gin.Engine
+	queue       *queue.Queue
 }
 
-// NewServer creates a new HTTP server instance
-func NewServer(port string) (*Server, error) {
+// NewServer creates a new HTTP server instance for the loaded settings
+func NewServer(cfg *config.Config) (*Server, error) {
 	// Set Gin mode based on environment
 	if os.Getenv("GIN_MODE") == "" {
 		gin.SetMode(gin.ReleaseMode)
@@ -29,26 +38,61 @@ func NewServer(port string) (*Server, error) {
 
 	// Initialize queue
 	ctx := context.Background()
-	jobQueue, err := queue.NewQueue(ctx)
+	jobQueue, err := queue.NewQueue(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
 
+	provider, err := auth.NewProvider(cfg.Auth, jobQueue)
+	if err != nil {
+		return nil, err
+	}
+
+	// Estimate new jobs' completion from the runs workers record, and skip
+	// Drive notifications that would create jobs with nothing to do
+	var sourceChecker endpoints.SourceChecker
+	if stateManager, err := state.NewStateManager(ctx, cfg.Valkey); err != nil {
+		slog.Warn("Failed to connect to state, jobs won't get completion estimates", "error", err)
+	} else {
+		jobQueue.SetEncodeRater(stateManager)
+		sourceChecker = sourcecheck.NewChecker(provider, stateManager, cfg.Storage)
+	}
+
 	router := gin.New()
 
+	// Kubernetes probes, registered ahead of the middleware so polling them
+	// isn't logged or traced
+	checker := health.NewChecker()
+	checker.AddReadiness("redis", jobQueue.Ping)
+	checker.AddReadiness("storage", health.Reachable(&http.Client{Timeout: health.CheckTimeout}, cfg.Storage.HealthURL))
+	router.GET("/healthz", gin.WrapF(checker.Live))
+	router.GET("/readyz", gin.WrapF(checker.Ready))
+
+	// Prometheus metrics stay off the public API, on the admin port along with
+	// the probes
+	adminMux := http.NewServeMux()
+	checker.Register(adminMux)
+	adminMux.Handle("GET /metrics", metrics.Handler())
+	adminServer := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.Server.AdminPort),
+		Handler:           adminMux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
 	// Add essential middleware
-	router.Use(gin.Logger())
+	router.Use(endpoints.RequestLogger(cfg.Server))
 	router.Use(gin.Recovery())
+	router.Use(endpoints.TracingMiddleware())
 
 	// Add CORS middleware for frontend communication
 	router.Use(corsMiddleware())
 
 	// Setup all routes with dependencies
-	endpoints.SetupRoutes(router, jobQueue)
+	endpoints.SetupRoutes(router, cfg, jobQueue, provider, sourceChecker)
 
 	// Create HTTP server
 	httpServer := &http.Server{
-		Addr:         ":" + port,
+		Addr:         ":" + strconv.Itoa(cfg.Server.Port),
 		Handler:      router,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
@@ -56,14 +100,21 @@ func NewServer(port string) (*Server, error) {
 	}
 
 	return &Server{
-		httpServer: httpServer,
-		router:     router,
-		queue:      jobQueue,
+		httpServer:  httpServer,
+		adminServer: adminServer,
+		router:      router,
+		queue:       jobQueue,
 	}, nil
 }
 
-// Start starts the HTTP server
+// Start starts the HTTP server, and the admin server in the background
 func (s *Server) Start() error {
+	go func() {
+		slog.Info("Starting admin server", "address", s.adminServer.Addr)
+		if err := s.adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("Admin server failed", "error", err)
+		}
+	}()
 	slog.Info("Starting HTTP server", "address", s.httpServer.Addr)
 	return s.httpServer.ListenAndServe()
 }
@@ -79,6 +130,9 @@ func (s *Server) Shutdown(ctx context.Context) error {
 		}
 	}
 
+	if err := s.adminServer.Shutdown(ctx); err != nil {
+		slog.Error("Failed to shut down admin server", "error", err)
+	}
 	return s.httpServer.Shutdown(ctx)
 }
 
@@ -87,7 +141,7 @@ func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*") // In production, specify your frontend domain
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key")
 		c.Header("Access-Control-Max-Age", "86400")
 
 		if c.Request.Method == "OPTIONS" {
@@ -0,0 +1,197 @@
+// Package sourcecheck tells whether a user's storage holds M3U8 or backup
+// files their runs haven't processed yet, reading only metadata. Workers use
+// it before downloading anything and the server before turning a storage
+// notification into a job.
+package sourcecheck
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"cobblepod/internal/auth"
+	"cobblepod/internal/config"
+	"cobblepod/internal/sources"
+	"cobblepod/internal/state"
+	"cobblepod/internal/storage"
+
+	"golang.org/x/oauth2"
+)
+
+// Source kinds, used to key the checksum of the last processed file
+const (
+	M3U8   = "m3u8"
+	Backup = "backup"
+)
+
+// Found is what a look at the metadata of a user's storage found: the storage
+// changes since their last run and the latest file of each source kind
+type Found struct {
+	Changes *storage.ChangeSet
+	M3U8    *sources.FileInfo
+	Backup  *sources.FileInfo
+	// NewM3U8 and NewBackup report whether those files still need processing
+	NewM3U8   bool
+	NewBackup bool
+}
+
+// Any reports whether a source file needs processing
+func (f *Found) Any() bool {
+	return f.NewM3U8 || f.NewBackup
+}
+
+// Detect compares the latest source files in the user's storage with what
+// their runs processed, reading only metadata: the storage change list and the
+// files' IDs, modified times and checksums
+func Detect(ctx context.Context, userStorage storage.Storage, appState *state.CobblepodState) (*Found, error) {
+	found := &Found{}
+
+	// Ask storage which files changed since this user's last run
+	pageToken := appState.ChangesPageToken
+	changes, err := userStorage.GetChanges(pageToken)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to list storage changes, falling back to watermarks", "error", err)
+		changes = nil
+	}
+	found.Changes = changes
+
+	// Check for new M3U8 file
+	found.M3U8, err = sources.NewM3U8Source(userStorage).GetLatest(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting latest M3U8 file: %w", err)
+	}
+	found.NewM3U8 = fileChanged(found.M3U8, changes, pageToken, appState.Source(M3U8)) &&
+		!sameContent(ctx, found.M3U8, appState.Source(M3U8).Checksum)
+
+	// Check for new backup file
+	found.Backup, err = sources.NewPodcastAddictBackup(userStorage).GetLatest(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting latest backup file", "error", err)
+	}
+	found.NewBackup = fileChanged(found.Backup, changes, pageToken, appState.Source(Backup)) &&
+		!sameContent(ctx, found.Backup, appState.Source(Backup).Checksum)
+
+	return found, nil
+}
+
+// sameContent reports whether a source file is byte-identical to the last one
+// processed, which catches re-uploads and copies whose modified time was reset
+func sameContent(ctx context.Context, file *sources.FileInfo, lastChecksum string) bool {
+	if file == nil || file.File == nil || file.File.MD5 == "" || lastChecksum == "" {
+		return false
+	}
+	if file.File.MD5 == lastChecksum {
+		slog.InfoContext(ctx, "Source file is identical to the last one processed, skipping", "name", file.File.Name, "md5", file.File.MD5)
+		return true
+	}
+	return false
+}
+
+// fileChanged reports whether a source file is new since the last run. Once a
+// page token is stored it relies on the storage change set; on the first run, or
+// if listing changes failed, it falls back to the source kind's watermark.
+func fileChanged(file *sources.FileInfo, changes *storage.ChangeSet, pageToken string, cursor *state.SourceCursor) bool {
+	if file == nil || file.File == nil {
+		return false
+	}
+	if changes != nil && pageToken != "" {
+		return changes.Contains(file.File.ID)
+	}
+	return cursor.Changed(file.File.ID, file.ModifiedTime)
+}
+
+// resultTTL is how long Checker answers for a user from its last look. Drive
+// sends notifications in bursts, which shouldn't each cost a look.
+const resultTTL = 5 * time.Second
+
+// result is a user's last answer from Checker
+type result struct {
+	changed bool
+	expires time.Time
+}
+
+// Checker looks at users' storage for source files their runs haven't
+// processed, so a notification that nothing relevant changed doesn't create a
+// job that does nothing
+type Checker struct {
+	tokenProvider auth.TokenProvider
+	state         *state.CobblepodStateManager
+	create        func(ctx context.Context, tokenSource oauth2.TokenSource) (storage.Storage, error)
+
+	mu      sync.Mutex
+	results map[string]result
+}
+
+// NewChecker creates a checker reading users' run state from sm, whose storage
+// calls stop while cfg's breaker is open
+func NewChecker(tokenProvider auth.TokenProvider, sm *state.CobblepodStateManager, cfg config.StorageConfig) *Checker {
+	breaker := storage.NewBreaker(cfg.BreakerFailures, cfg.BreakerCooldown())
+	return &Checker{
+		tokenProvider: tokenProvider,
+		state:         sm,
+		create: func(ctx context.Context, tokenSource oauth2.TokenSource) (storage.Storage, error) {
+			s, err := storage.NewServiceWithTokenSource(ctx, tokenSource)
+			if err != nil {
+				return nil, err
+			}
+			return storage.WithBreaker(ctx, s, breaker), nil
+		},
+		results: make(map[string]result),
+	}
+}
+
+// SourcesChanged reports whether the user's storage holds an M3U8 or backup
+// file that a job would process, answering from the last look for a few
+// seconds after it. It doesn't advance the user's change tracking; the job
+// does that.
+func (c *Checker) SourcesChanged(ctx context.Context, userID string) (bool, error) {
+	if changed, ok := c.cached(userID); ok {
+		return changed, nil
+	}
+
+	appState, err := c.state.GetState(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	tokenSource, err := c.tokenProvider.GoogleTokenSource(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get Google access token for user %s: %w", userID, err)
+	}
+	userStorage, err := c.create(ctx, tokenSource)
+	if err != nil {
+		return false, fmt.Errorf("failed to create storage service with user token: %w", err)
+	}
+	found, err := Detect(ctx, userStorage, appState)
+	if err != nil {
+		return false, err
+	}
+
+	c.remember(userID, found.Any())
+	return found.Any(), nil
+}
+
+// cached returns the user's last answer while it's fresh
+func (c *Checker) cached(userID string) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	last, ok := c.results[userID]
+	if !ok || time.Now().After(last.expires) {
+		return false, false
+	}
+	return last.changed, true
+}
+
+// remember stores the user's answer, dropping the ones that went stale
+func (c *Checker) remember(userID string, changed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for id, last := range c.results {
+		if now.After(last.expires) {
+			delete(c.results, id)
+		}
+	}
+	c.results[userID] = result{changed: changed, expires: now.Add(resultTTL)}
+}
@@ -0,0 +1,133 @@
+package sourcecheck
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cobblepod/internal/sources"
+	"cobblepod/internal/state"
+	"cobblepod/internal/storage"
+	"cobblepod/internal/storage/mock"
+)
+
+func TestFileChanged(t *testing.T) {
+	modified := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	file := &sources.FileInfo{
+		File:         &storage.FileMeta{ID: "backup-1"},
+		ModifiedTime: modified,
+	}
+	changed := &storage.ChangeSet{Files: []*storage.FileMeta{{ID: "backup-1"}}}
+	unchanged := &storage.ChangeSet{Files: []*storage.FileMeta{{ID: "other"}}}
+	seen := &state.SourceCursor{FileID: "backup-1", ModifiedTime: modified}
+
+	tests := []struct {
+		name      string
+		file      *sources.FileInfo
+		changes   *storage.ChangeSet
+		pageToken string
+		cursor    *state.SourceCursor
+		expected  bool
+	}{
+		{name: "no file", file: nil, changes: changed, pageToken: "token", cursor: &state.SourceCursor{}, expected: false},
+		{name: "listed in changes despite matching the watermark", file: file, changes: changed, pageToken: "token", cursor: seen, expected: true},
+		{name: "not listed in changes", file: file, changes: unchanged, pageToken: "token", cursor: &state.SourceCursor{}, expected: false},
+		{name: "first run falls back to the empty watermark", file: file, changes: unchanged, pageToken: "", cursor: &state.SourceCursor{}, expected: true},
+		{name: "changes unavailable and already seen", file: file, changes: nil, pageToken: "token", cursor: seen, expected: false},
+		{name: "changes unavailable and another file", file: file, changes: nil, pageToken: "token", cursor: &state.SourceCursor{FileID: "backup-0", ModifiedTime: modified.Add(time.Hour)}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fileChanged(tt.file, tt.changes, tt.pageToken, tt.cursor); got != tt.expected {
+				t.Errorf("fileChanged() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSameContent(t *testing.T) {
+	file := &sources.FileInfo{File: &storage.FileMeta{ID: "backup-2", Name: "copy.backup", MD5: "abc123"}}
+
+	tests := []struct {
+		name         string
+		file         *sources.FileInfo
+		lastChecksum string
+		expected     bool
+	}{
+		{name: "no file", file: nil, lastChecksum: "abc123", expected: false},
+		{name: "identical re-upload", file: file, lastChecksum: "abc123", expected: true},
+		{name: "different content", file: file, lastChecksum: "def456", expected: false},
+		{name: "nothing processed yet", file: file, lastChecksum: "", expected: false},
+		{name: "backend without checksums", file: &sources.FileInfo{File: &storage.FileMeta{ID: "backup-3"}}, lastChecksum: "abc123", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameContent(context.Background(), tt.file, tt.lastChecksum); got != tt.expected {
+				t.Errorf("sameContent() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDetectSources(t *testing.T) {
+	modified := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	mockStorage := mock.NewMockStorage()
+	mockStorage.GetFilesFunc = func(query storage.Query) ([]*storage.FileMeta, error) {
+		if query.Extension == sources.BackupQuery.Extension {
+			return []*storage.FileMeta{{ID: "backup-1", Name: "PodcastAddict.backup", ModifiedTime: modified}}, nil
+		}
+		return nil, nil
+	}
+	mockStorage.GetMostRecentFileFunc = func(files []*storage.FileMeta) *storage.FileMeta { return files[0] }
+	// Only the worker's own upload changed
+	mockStorage.GetChangesResult = &storage.ChangeSet{Files: []*storage.FileMeta{{ID: "episode"}}, NextPageToken: "next"}
+
+	seen := &state.CobblepodState{}
+	seen.SetSource(Backup, &state.SourceCursor{FileID: "backup-1", ModifiedTime: modified})
+	tests := []struct {
+		name     string
+		state    *state.CobblepodState
+		expected bool
+	}{
+		{name: "nothing processed yet", state: &state.CobblepodState{}, expected: true},
+		{name: "backup already seen", state: seen, expected: false},
+		{name: "changes exclude the backup", state: &state.CobblepodState{ChangesPageToken: "token"}, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := Detect(context.Background(), mockStorage, tt.state)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if found.Any() != tt.expected || found.NewM3U8 {
+				t.Errorf("Detect() new m3u8=%v backup=%v, want backup=%v", found.NewM3U8, found.NewBackup, tt.expected)
+			}
+		})
+	}
+	if len(mockStorage.DownloadFileCalls) != 0 || len(mockStorage.DownloadFileToTempCalls) != 0 {
+		t.Error("Expected only metadata to be read")
+	}
+}
+
+func TestCheckerRemembersResults(t *testing.T) {
+	c := &Checker{results: make(map[string]result)}
+	if _, ok := c.cached("user-1"); ok {
+		t.Fatal("Expected nothing cached yet")
+	}
+	c.remember("user-1", true)
+	if changed, ok := c.cached("user-1"); !ok || !changed {
+		t.Errorf("Expected the last answer, got %v (cached %v)", changed, ok)
+	}
+
+	// Stale answers are neither used nor kept
+	c.results["user-2"] = result{changed: true, expires: time.Now().Add(-time.Second)}
+	if _, ok := c.cached("user-2"); ok {
+		t.Error("Expected a stale answer to be ignored")
+	}
+	c.remember("user-3", false)
+	if _, ok := c.results["user-2"]; ok {
+		t.Error("Expected the stale answer to be dropped")
+	}
+}
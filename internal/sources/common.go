@@ -4,22 +4,19 @@ import (
 	"cobblepod/internal/storage"
 	"context"
 	"fmt"
-	"log/slog"
 	"time"
-
-	"google.golang.org/api/drive/v3"
 )
 
 // FileInfo contains metadata about a file (M3U8, backup, etc.)
 type FileInfo struct {
-	File         *drive.File
+	File         *storage.FileMeta
 	FileName     string
 	ModifiedTime time.Time
 }
 
 // GetLatestFile is a common function to get the most recent file matching a query
-func GetLatestFile(ctx context.Context, drive storage.Storage, query string, fileTypeName string) (*FileInfo, error) {
-	files, err := drive.GetFiles(query, true)
+func GetLatestFile(ctx context.Context, drive storage.Storage, query storage.Query, fileTypeName string) (*FileInfo, error) {
+	files, err := drive.GetFiles(query.MostRecent())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get %s files: %w", fileTypeName, err)
 	}
@@ -33,16 +30,9 @@ func GetLatestFile(ctx context.Context, drive storage.Storage, query string, fil
 		return nil, nil
 	}
 
-	// Parse the modified time
-	modifiedTime, err := time.Parse(time.RFC3339, mostRecentFile.ModifiedTime)
-	if err != nil {
-		slog.Warn("Couldn't parse modified time", "file", mostRecentFile.Name, "error", err)
-		modifiedTime = time.Time{} // Zero time as fallback
-	}
-
 	return &FileInfo{
 		File:         mostRecentFile,
-		ModifiedTime: modifiedTime,
+		ModifiedTime: mostRecentFile.ModifiedTime,
 		FileName:     mostRecentFile.Name,
 	}, nil
 }
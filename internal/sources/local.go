@@ -0,0 +1,31 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"cobblepod/internal/metadata"
+	"cobblepod/internal/queue"
+)
+
+// ReadLocalFile returns the episodes of an M3U8 playlist or Podcast Addict
+// backup on the local disk, picking the source by file extension. Episode URLs
+// are left as the file has them, so they are downloaded straight from the host;
+// provider finds those a backup lacks, see PodcastAddictBackup.SetMetadataProvider.
+func ReadLocalFile(ctx context.Context, path string, provider metadata.Provider) ([]queue.JobItem, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".m3u", ".m3u8":
+		return NewM3U8Source(nil).ProcessFile(path)
+	case ".backup":
+		if err := ValidateBackup(path); err != nil {
+			return nil, err
+		}
+		backup := NewPodcastAddictBackup(nil)
+		backup.SetMetadataProvider(provider)
+		return backup.ProcessFile(ctx, path)
+	default:
+		return nil, fmt.Errorf("unsupported source file %q: expected .m3u8 or .backup", filepath.Base(path))
+	}
+}
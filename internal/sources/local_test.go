@@ -0,0 +1,44 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadLocalFile(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	playlist := filepath.Join(dir, "playlist.M3U8")
+	os.WriteFile(playlist, []byte("#EXTM3U\n#EXTINF:90,Episode 1\nhttps://example.com/ep1.mp3\n"), 0o600)
+
+	entries, err := ReadLocalFile(ctx, playlist, nil)
+	if err != nil {
+		t.Fatalf("ReadLocalFile() error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].SourceURL != "https://example.com/ep1.mp3" || entries[0].Duration != 90*time.Second || entries[0].Position != 1 {
+		t.Errorf("Unexpected entries %+v", entries)
+	}
+
+	empty := filepath.Join(dir, "empty.m3u")
+	os.WriteFile(empty, []byte("#EXTM3U\n"), 0o600)
+	if _, err := ReadLocalFile(ctx, empty, nil); err == nil {
+		t.Error("Expected an error for a playlist without audio")
+	}
+
+	if _, err := ReadLocalFile(ctx, filepath.Join(dir, "missing.m3u8"), nil); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+
+	backup := writeBackup(t, map[string][]byte{"podcastAddict.db": sqliteDB(t, "podcasts")})
+	if _, err := ReadLocalFile(ctx, backup, nil); !errors.Is(err, ErrInvalidBackup) {
+		t.Errorf("Expected ErrInvalidBackup, got %v", err)
+	}
+
+	if _, err := ReadLocalFile(ctx, filepath.Join(dir, "notes.txt"), nil); err == nil {
+		t.Error("Expected an error for an unsupported file")
+	}
+}
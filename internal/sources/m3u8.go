@@ -1,13 +1,18 @@
 package sources
 
 import (
-	"cobblepod/internal/config"
 	"cobblepod/internal/queue"
 	"cobblepod/internal/storage"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"log/slog"
-	"regexp"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,6 +21,9 @@ import (
 	"github.com/google/uuid"
 )
 
+// M3UQuery is the query used to search for M3U files in storage
+var M3UQuery = storage.Query{Extension: ".m3u"}
+
 type M3U8Source struct {
 	drive          storage.Storage
 	mutex          sync.RWMutex
@@ -32,12 +40,12 @@ func NewM3U8Source(driveService storage.Storage) *M3U8Source {
 
 // GetLatest checks for the most recent M3U8 file and returns metadata
 func (m *M3U8Source) GetLatest(ctx context.Context) (*FileInfo, error) {
-	return GetLatestFile(ctx, m.drive, config.M3UQuery, "M3U8")
+	return GetLatestFile(ctx, m.drive, M3UQuery, "M3U8")
 }
 
 // Process downloads and parses the M3U8 file
 func (m *M3U8Source) Process(ctx context.Context, fileInfo *FileInfo) ([]queue.JobItem, error) {
-	fileID := fileInfo.File.Id
+	fileID := fileInfo.File.ID
 
 	// Mark as processed
 	m.mutex.Lock()
@@ -50,7 +58,110 @@ func (m *M3U8Source) Process(ctx context.Context, fileInfo *FileInfo) ([]queue.J
 		return nil, fmt.Errorf("failed to download M3U8 file: %w", err)
 	}
 
-	audioEntries := m.parseM3U8(m3u8Content)
+	return m.entries(m3u8Content, nil)
+}
+
+// ProcessFile parses an M3U8 file on the local disk
+func (m *M3U8Source) ProcessFile(path string) ([]queue.JobItem, error) {
+	m3u8Content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read M3U8 file: %w", err)
+	}
+	return m.entries(string(m3u8Content), nil)
+}
+
+// maxRemoteM3U8Size bounds the playlists fetched over HTTP
+const maxRemoteM3U8Size = 4 << 20
+
+// RemoteM3U8 is an M3U8 playlist fetched over HTTP
+type RemoteM3U8 struct {
+	URL     string
+	Content string
+	// ETag and LastModified are the response's validators, for the next
+	// conditional request
+	ETag         string
+	LastModified string
+}
+
+// FetchM3U8 downloads an M3U8 playlist published at rawURL. When etag or
+// lastModified are set the request is conditional, and an unchanged playlist
+// returns nil.
+func FetchM3U8(ctx context.Context, client *http.Client, rawURL, etag, lastModified string) (*RemoteM3U8, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create playlist request: %w", err)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch playlist: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("failed to fetch playlist: HTTP %d", resp.StatusCode)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteM3U8Size+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read playlist: %w", err)
+	}
+	if len(content) > maxRemoteM3U8Size {
+		return nil, fmt.Errorf("playlist is larger than %d bytes", maxRemoteM3U8Size)
+	}
+	return &RemoteM3U8{
+		URL:          rawURL,
+		Content:      string(content),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// Checksum returns the SHA-256 of the playlist, which tells whether it changed
+// when the server sends no validators
+func (r *RemoteM3U8) Checksum() string {
+	sum := sha256.Sum256([]byte(r.Content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Entries parses the playlist; relative URLs are resolved against its location
+func (r *RemoteM3U8) Entries() ([]queue.JobItem, error) {
+	base, err := url.Parse(r.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid playlist URL: %w", err)
+	}
+	return NewM3U8Source(nil).entries(r.Content, base)
+}
+
+// entries parses M3U8 content published at base, failing if it lists no
+// audio. Drive-hosted playlists have no location (a nil base), so entries with
+// relative URLs are left out.
+func (m *M3U8Source) entries(content string, base *url.URL) ([]queue.JobItem, error) {
+	playlist := ParseM3U8(content, base)
+	if playlist.Segmented || len(playlist.Variants) > 0 {
+		return nil, fmt.Errorf("M3U8 playlist is an HLS stream, not a list of episodes")
+	}
+
+	audioEntries := make([]queue.JobItem, 0, len(playlist.Entries))
+	for _, entry := range playlist.Entries {
+		audioEntries = append(audioEntries, queue.JobItem{
+			Title:     entry.Title,
+			Duration:  entry.Duration,
+			SourceURL: entry.URL,
+			ID:        uuid.New().String(),
+			Status:    queue.StatusPending,
+			Position:  len(audioEntries) + 1,
+		})
+	}
 	if len(audioEntries) == 0 {
 		return nil, fmt.Errorf("no audio files found in M3U8 playlist")
 	}
@@ -59,40 +170,167 @@ func (m *M3U8Source) Process(ctx context.Context, fileInfo *FileInfo) ([]queue.J
 	return audioEntries, nil
 }
 
-// parseM3U8 parses M3U8 content and extracts audio entries
-func (m *M3U8Source) parseM3U8(content string) []queue.JobItem {
-	lines := strings.Split(strings.TrimSpace(content), "\n")
-	var entries []queue.JobItem
-
-	for i := 0; i < len(lines); i++ {
-		line := strings.TrimSpace(lines[i])
-		if strings.HasPrefix(line, "#EXTINF:") {
-			re := regexp.MustCompile(`^#EXTINF:([0-9.]+),(.+)$`)
-			matches := re.FindStringSubmatch(line)
-			if len(matches) == 3 {
-				durationSeconds, err := strconv.ParseFloat(matches[1], 64)
-				if err != nil {
-					continue
-				}
-				title := strings.TrimSpace(matches[2])
-
-				if i+1 < len(lines) {
-					url := strings.TrimSpace(lines[i+1])
-					if url != "" && !strings.HasPrefix(url, "#") {
-						entries = append(entries, queue.JobItem{
-							Title:     title,
-							Duration:  time.Duration(durationSeconds * float64(time.Second)),
-							SourceURL: url,
-							ID:        uuid.New().String(),
-							Status:    queue.StatusPending,
-						})
-						i++ // Skip the URL line
-						continue
-					}
+// Playlist is a parsed M3U8 playlist
+type Playlist struct {
+	// Entries are the playlist's media, in order
+	Entries []PlaylistEntry
+	// Variants are the streams of an HLS master playlist
+	Variants []Variant
+	// Segmented is set for HLS media playlists, whose entries are the segments
+	// of one stream rather than episodes
+	Segmented bool
+}
+
+// PlaylistEntry is a media entry of a playlist
+type PlaylistEntry struct {
+	URL   string
+	Title string
+	// Duration is zero when the playlist doesn't know it (#EXTINF:-1)
+	Duration time.Duration
+	// Attributes are the #EXTINF attributes, such as tvg-logo and group-title
+	Attributes map[string]string
+}
+
+// Variant is a stream an HLS master playlist offers (#EXT-X-STREAM-INF)
+type Variant struct {
+	URL       string
+	Bandwidth int
+	Codecs    string
+	// Attributes are all of the variant's attributes, such as RESOLUTION
+	Attributes map[string]string
+}
+
+// ParseM3U8 parses an M3U or M3U8 playlist. Relative URLs are resolved against
+// base, the playlist's own location; without one, their entries are left out.
+// Entries without a title are titled after their file name. Tags the
+// parser doesn't use, such as most #EXT-X-* tags, are skipped.
+func ParseM3U8(content string, base *url.URL) *Playlist {
+	playlist := &Playlist{}
+	var info *PlaylistEntry
+	var variant *Variant
+
+	content = strings.TrimPrefix(content, "\uFEFF")
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info = parseExtInf(strings.TrimPrefix(line, "#EXTINF:"))
+			variant = nil
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			attributes := parseAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"), ',')
+			variant = &Variant{Codecs: attributes["CODECS"], Attributes: attributes}
+			variant.Bandwidth, _ = strconv.Atoi(attributes["BANDWIDTH"])
+			info = nil
+		case strings.HasPrefix(line, "#EXT-X-TARGETDURATION:"), strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			playlist.Segmented = true
+		case strings.HasPrefix(line, "#"):
+			// Comments and tags the parser doesn't use
+		default:
+			location, ok := resolveURL(line, base)
+			switch {
+			case !ok:
+				slog.Warn("Skipping playlist entry without a usable URL", "url", line)
+			case variant != nil:
+				variant.URL = location
+				playlist.Variants = append(playlist.Variants, *variant)
+			case info != nil:
+				info.URL = location
+				if info.Title == "" {
+					info.Title = titleFromURL(location)
 				}
+				playlist.Entries = append(playlist.Entries, *info)
+			default:
+				playlist.Entries = append(playlist.Entries, PlaylistEntry{URL: location, Title: titleFromURL(location)})
 			}
+			info, variant = nil, nil
+		}
+	}
+	return playlist
+}
+
+// parseExtInf parses what follows "#EXTINF:", a duration in seconds, optional
+// attributes and, after the first comma outside quotes, the title
+func parseExtInf(value string) *PlaylistEntry {
+	head, title := value, ""
+	quoted := false
+	for i := 0; i < len(value); i++ {
+		if value[i] == '"' {
+			quoted = !quoted
+		} else if value[i] == ',' && !quoted {
+			head, title = value[:i], strings.TrimSpace(value[i+1:])
+			break
+		}
+	}
+
+	entry := &PlaylistEntry{Title: title}
+	duration, attributes, _ := strings.Cut(strings.TrimSpace(head), " ")
+	if seconds, err := strconv.ParseFloat(duration, 64); err == nil && seconds > 0 {
+		entry.Duration = time.Duration(seconds * float64(time.Second))
+	}
+	if attributes = strings.TrimSpace(attributes); attributes != "" {
+		entry.Attributes = parseAttributes(attributes, ' ')
+	}
+	return entry
+}
+
+// parseAttributes parses an attribute list like `tvg-id="1" group-title="News"`
+// whose attributes are separated by separator. Quoted values may hold the
+// separator.
+func parseAttributes(list string, separator byte) map[string]string {
+	attributes := map[string]string{}
+	for list != "" {
+		list = strings.TrimLeft(list, string(separator)+" ")
+		key, rest, ok := strings.Cut(list, "=")
+		if !ok {
+			break
+		}
+		key = strings.TrimSpace(key)
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else if end := strings.IndexByte(rest, separator); end >= 0 {
+			value, rest = rest[:end], rest[end:]
+		} else {
+			value, rest = rest, ""
+		}
+		if key != "" {
+			attributes[key] = value
+		}
+		list = rest
+	}
+	return attributes
+}
+
+// resolveURL resolves a playlist URL against the playlist's location,
+// reporting whether the result is an http or https URL episodes can be
+// downloaded from
+func resolveURL(raw string, base *url.URL) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	if !u.IsAbs() {
+		if base == nil {
+			return "", false
 		}
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", false
 	}
+	return u.String(), true
+}
 
-	return entries
+// titleFromURL names an entry the playlist didn't title after its file
+func titleFromURL(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		return location
+	}
+	return strings.TrimSuffix(name, path.Ext(name))
 }
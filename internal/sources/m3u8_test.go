@@ -0,0 +1,142 @@
+package sources
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseM3U8(t *testing.T) {
+	base, _ := url.Parse("https://example.com/playlists/commute.m3u8")
+
+	tests := []struct {
+		name     string
+		content  string
+		base     *url.URL
+		expected *Playlist
+	}{
+		{
+			name:    "simple entries",
+			content: "#EXTM3U\n#EXTINF:90,Episode 1\nhttps://example.com/ep1.mp3\n",
+			expected: &Playlist{Entries: []PlaylistEntry{
+				{URL: "https://example.com/ep1.mp3", Title: "Episode 1", Duration: 90 * time.Second},
+			}},
+		},
+		{
+			name:    "fractional durations, commas in titles and CRLF line endings",
+			content: "\uFEFF#EXTM3U\r\n#EXTINF:1834.5,Show - Episode 1, Part 2\r\nhttps://example.com/ep1.mp3\r\n",
+			expected: &Playlist{Entries: []PlaylistEntry{
+				{URL: "https://example.com/ep1.mp3", Title: "Show - Episode 1, Part 2", Duration: 1834500 * time.Millisecond},
+			}},
+		},
+		{
+			name:    "attributes and unknown durations",
+			content: "#EXTM3U\n#EXTINF:-1 tvg-id=\"news\" tvg-logo=\"https://example.com/logo.png\" group-title=\"News, daily\",The Daily\nhttps://example.com/daily.mp3\n",
+			expected: &Playlist{Entries: []PlaylistEntry{{
+				URL:        "https://example.com/daily.mp3",
+				Title:      "The Daily",
+				Attributes: map[string]string{"tvg-id": "news", "tvg-logo": "https://example.com/logo.png", "group-title": "News, daily"},
+			}}},
+		},
+		{
+			name:    "relative URLs resolved against the playlist",
+			content: "#EXTINF:60,Local\nepisodes/local.mp3\n#EXTINF:60,Root\n/root.mp3\n",
+			base:    base,
+			expected: &Playlist{Entries: []PlaylistEntry{
+				{URL: "https://example.com/playlists/episodes/local.mp3", Title: "Local", Duration: time.Minute},
+				{URL: "https://example.com/root.mp3", Title: "Root", Duration: time.Minute},
+			}},
+		},
+		{
+			name:    "relative URLs without a location and unsupported schemes",
+			content: "#EXTINF:60,Local\nepisodes/local.mp3\n#EXTINF:60,File\nfile:///music/file.mp3\n#EXTINF:60,Remote\nhttps://example.com/remote.mp3\n",
+			expected: &Playlist{Entries: []PlaylistEntry{
+				{URL: "https://example.com/remote.mp3", Title: "Remote", Duration: time.Minute},
+			}},
+		},
+		{
+			name:    "entries without #EXTINF and unused tags",
+			content: "#EXTM3U\n#PLAYLIST:Commute\n#EXT-X-VERSION:3\nhttps://example.com/episodes/Bonus%20Episode.mp3\n",
+			expected: &Playlist{Entries: []PlaylistEntry{
+				{URL: "https://example.com/episodes/Bonus%20Episode.mp3", Title: "Bonus Episode"},
+			}},
+		},
+		{
+			name:    "HLS master playlist",
+			content: "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=64000,CODECS=\"mp4a.40.2,mp4a.40.5\"\naudio/low.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=128000\naudio/high.m3u8\n",
+			base:    base,
+			expected: &Playlist{Variants: []Variant{
+				{URL: "https://example.com/playlists/audio/low.m3u8", Bandwidth: 64000, Codecs: "mp4a.40.2,mp4a.40.5", Attributes: map[string]string{"BANDWIDTH": "64000", "CODECS": "mp4a.40.2,mp4a.40.5"}},
+				{URL: "https://example.com/playlists/audio/high.m3u8", Bandwidth: 128000, Attributes: map[string]string{"BANDWIDTH": "128000"}},
+			}},
+		},
+		{
+			name:    "HLS media playlist",
+			content: "#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXT-X-MEDIA-SEQUENCE:0\n#EXTINF:9.98,\nhttps://example.com/segment0.aac\n#EXT-X-ENDLIST\n",
+			expected: &Playlist{Segmented: true, Entries: []PlaylistEntry{
+				{URL: "https://example.com/segment0.aac", Title: "segment0", Duration: 9980 * time.Millisecond},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseM3U8(tt.content, tt.base); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ParseM3U8() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestM3U8Entries(t *testing.T) {
+	source := NewM3U8Source(nil)
+
+	entries, err := source.entries("#EXTM3U\n#EXTINF:-1 group-title=\"News\",The Daily\nhttps://example.com/daily.mp3\n#EXTINF:12.5,Short\nhttps://example.com/short.mp3\n", nil)
+	if err != nil {
+		t.Fatalf("entries() error: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Title != "The Daily" || entries[1].Duration != 12500*time.Millisecond || entries[1].Position != 2 {
+		t.Errorf("Unexpected entries %+v", entries)
+	}
+
+	if _, err := source.entries("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=64000\nhttps://example.com/low.m3u8\n", nil); err == nil {
+		t.Error("Expected an error for an HLS stream")
+	}
+}
+
+func TestFetchM3U8(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("#EXTM3U\n#EXTINF:60,Local\nepisodes/local.mp3\n"))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	playlist, err := FetchM3U8(ctx, server.Client(), server.URL+"/playlists/commute.m3u8", "", "")
+	if err != nil || playlist == nil {
+		t.Fatalf("FetchM3U8() = %v, %v", playlist, err)
+	}
+	if playlist.ETag != `"v1"` || playlist.Checksum() == "" {
+		t.Errorf("Unexpected playlist %+v", playlist)
+	}
+	entries, err := playlist.Entries()
+	if err != nil {
+		t.Fatalf("Entries() error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].SourceURL != server.URL+"/playlists/episodes/local.mp3" {
+		t.Errorf("Relative entry not resolved against the playlist: %+v", entries)
+	}
+
+	// An unchanged playlist isn't downloaded again
+	if playlist, err := FetchM3U8(ctx, server.Client(), server.URL+"/playlists/commute.m3u8", `"v1"`, ""); err != nil || playlist != nil {
+		t.Errorf("FetchM3U8() with a current ETag = %v, %v, want nil", playlist, err)
+	}
+}
@@ -5,6 +5,7 @@ package sources
 
 import (
 	"archive/zip"
+	"cobblepod/internal/metadata"
 	"cobblepod/internal/queue"
 	"cobblepod/internal/storage"
 	"context"
@@ -15,6 +16,7 @@ import (
 	"log/slog"
 	"net/url"
 	"os"
+	"path"
 	"strings"
 	"time"
 
@@ -29,9 +31,22 @@ type ListeningProgress struct {
 	Offset  time.Duration
 }
 
+// BackupFolder returns the storage folder uploaded backups are placed in,
+// under the deployment's top-level folder driveFolder
+func BackupFolder(driveFolder string) string {
+	return path.Join(driveFolder, "backups")
+}
+
+// BackupQuery is the query used to search for Podcast Addict backups in storage.
+// It isn't scoped to BackupFolder because Podcast Addict can also export
+// backups straight into the user's Drive.
+var BackupQuery = storage.Query{NamePattern: "PodcastAddict", Extension: ".backup"}
+
 // PodcastAddictBackup handles extraction of listening progress from Podcast Addict backups.
 type PodcastAddictBackup struct {
-	drive storage.Storage
+	drive    storage.Storage
+	metadata metadata.Provider
+	rules    *queue.PlaylistRules
 }
 
 // NewPodcastAddictBackup constructs a new handler.
@@ -39,10 +54,22 @@ func NewPodcastAddictBackup(drive storage.Storage) *PodcastAddictBackup {
 	return &PodcastAddictBackup{drive: drive}
 }
 
+// SetMetadataProvider sets the provider that finds the audio of episodes the
+// backup has no download URL for in their show's feed; without one they are
+// left out
+func (p *PodcastAddictBackup) SetMetadataProvider(provider metadata.Provider) {
+	p.metadata = provider
+}
+
+// SetRules makes Process select episodes of the backup's subscriptions with
+// rules instead of reading its playlist; nil reads the playlist
+func (p *PodcastAddictBackup) SetRules(rules *queue.PlaylistRules) {
+	p.rules = rules
+}
+
 // GetLatest checks for the most recent backup file and returns metadata
 func (p *PodcastAddictBackup) GetLatest(ctx context.Context) (*FileInfo, error) {
-	query := "name contains 'PodcastAddict' and name contains '.backup' and trashed = false"
-	return GetLatestFile(ctx, p.drive, query, "backup")
+	return GetLatestFile(ctx, p.drive, BackupQuery, "backup")
 }
 
 // AddListeningProgress locates the most recent backup and will (later) augment entries with offsets.
@@ -52,8 +79,7 @@ func (p *PodcastAddictBackup) AddListeningProgress(ctx context.Context, entries
 		return nil, errors.New("drive service is nil")
 	}
 
-	query := "name contains 'PodcastAddict' and name contains '.backup' and trashed = false"
-	files, err := p.drive.GetFiles(query, true)
+	files, err := p.drive.GetFiles(BackupQuery.MostRecent())
 	if err != nil {
 		return nil, fmt.Errorf("querying backup files: %w", err)
 	}
@@ -62,15 +88,15 @@ func (p *PodcastAddictBackup) AddListeningProgress(ctx context.Context, entries
 	}
 
 	latest := files[0]
-	slog.Info("Found PodcastAddict backup candidate", "name", latest.Name, "modified", latest.ModifiedTime)
+	slog.InfoContext(ctx, "Found PodcastAddict backup candidate", "name", latest.Name, "modified", latest.ModifiedTime)
 
-	backup, err := p.drive.DownloadFileToTemp(latest.Id)
+	backup, err := p.drive.DownloadFileToTemp(latest.ID)
 	if err != nil {
 		return nil, fmt.Errorf("downloading backup file: %w", err)
 	}
 	defer os.Remove(backup)
 
-	db, err := p.extractBackupDB(backup)
+	db, err := extractBackupDB(backup)
 	if err != nil {
 		return nil, fmt.Errorf("extracting backup archive: %w", err)
 	}
@@ -98,37 +124,77 @@ func (p *PodcastAddictBackup) Process(ctx context.Context, backupFile *FileInfo)
 		return nil, errors.New("no backup file provided")
 	}
 
-	slog.Info("Processing PodcastAddict backup", "name", backupFile.FileName, "modified", backupFile.ModifiedTime)
+	slog.InfoContext(ctx, "Processing PodcastAddict backup", "name", backupFile.FileName, "modified", backupFile.ModifiedTime)
 
-	backup, err := p.drive.DownloadFileToTemp(backupFile.File.Id)
+	backup, err := p.drive.DownloadFileToTemp(backupFile.File.ID)
 	if err != nil {
 		return nil, fmt.Errorf("downloading backup file: %w", err)
 	}
 	defer os.Remove(backup)
 
-	db, err := p.extractBackupDB(backup)
+	return p.ProcessFile(ctx, backup)
+}
+
+// ProcessFile returns all episodes of a backup file on the local disk, as
+// Process does for one in storage
+func (p *PodcastAddictBackup) ProcessFile(ctx context.Context, backupPath string) ([]queue.JobItem, error) {
+	db, err := extractBackupDB(backupPath)
 	if err != nil {
 		return nil, fmt.Errorf("extracting backup archive: %w", err)
 	}
 	defer os.Remove(db)
 
-	results, err := p.queryAllEpisodes(db)
+	if p.rules != nil {
+		episodes, err := p.querySubscribedEpisodes(db)
+		if err != nil {
+			return nil, fmt.Errorf("querying subscribed episodes: %w", err)
+		}
+		results, names := applyRules(episodes, p.rules)
+		return p.resolveAudioURLs(ctx, results, names), nil
+	}
+
+	results, names, err := p.queryAllEpisodes(db)
 	if err != nil {
 		return nil, fmt.Errorf("querying all episodes: %w", err)
 	}
 
-	return results, nil
+	return p.resolveAudioURLs(ctx, results, names), nil
+}
+
+// resolveAudioURLs looks up the enclosure of each entry without a source URL in
+// its show's feed, by the episode's name in names, and leaves out entries whose
+// audio can't be found, as there is nothing to download for them
+func (p *PodcastAddictBackup) resolveAudioURLs(ctx context.Context, entries []queue.JobItem, names []string) []queue.JobItem {
+	resolved := entries[:0]
+	for i, entry := range entries {
+		if entry.SourceURL == "" && p.metadata != nil && entry.FeedURL != "" {
+			episode, err := p.metadata.Lookup(ctx, metadata.Query{FeedURL: entry.FeedURL, GUID: entry.GUID, Title: names[i]})
+			if err != nil {
+				slog.WarnContext(ctx, "Failed to look up episode audio in its show's feed", "error", err, "title", entry.Title, "feed_url", entry.FeedURL)
+			} else if episode != nil {
+				entry.SourceURL = episode.AudioURL
+			}
+		}
+		if entry.SourceURL == "" {
+			slog.WarnContext(ctx, "Leaving out backup episode without an audio URL", "title", entry.Title)
+			continue
+		}
+		resolved = append(resolved, entry)
+	}
+	return resolved
 }
 
 // queryAllEpisodes opens the SQLite database at dbPath and returns all episodes
-// without the position_to_resume > 0 filter for independent backup processing.
-func (p *PodcastAddictBackup) queryAllEpisodes(dbPath string) ([]queue.JobItem, error) {
+// without the position_to_resume > 0 filter for independent backup processing,
+// along with each episode's own name. Episodes Podcast Addict has no download
+// URL for have an empty SourceURL.
+func (p *PodcastAddictBackup) queryAllEpisodes(dbPath string) ([]queue.JobItem, []string, error) {
 	// Open read-only using a proper file URI to avoid accidental writes.
 	u := &url.URL{Scheme: "file", Path: dbPath, RawQuery: "mode=ro&_busy_timeout=5000"}
 	dsn := u.String()
 	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
-		return nil, fmt.Errorf("open sqlite: %w", err)
+		return nil, nil, fmt.Errorf("open sqlite: %w", err)
 	}
 	defer db.Close()
 
@@ -136,10 +202,12 @@ func (p *PodcastAddictBackup) queryAllEpisodes(dbPath string) ([]queue.JobItem,
 	const q = `
 		SELECT 
 			p.name as podcast,
-			e.download_url as url,
+			COALESCE(e.download_url, '') as url,
 			e.position_to_resume as offset,
 			e.duration_ms as duration,
-			e.name as episode
+			e.name as episode,
+			COALESCE(e.guid, '') as guid,
+			COALESCE(p.feed_url, '') as feed_url
 		FROM episodes e
 		JOIN podcasts p ON p._id = e.podcast_id
 		JOIN ordered_list o ON o.id = e._id
@@ -149,35 +217,38 @@ func (p *PodcastAddictBackup) queryAllEpisodes(dbPath string) ([]queue.JobItem,
 
 	rows, err := db.Query(q)
 	if err != nil {
-		return nil, fmt.Errorf("query: %w", err)
+		return nil, nil, fmt.Errorf("query: %w", err)
 	}
 	defer rows.Close()
 
 	results := make([]queue.JobItem, 0, 64)
+	var names []string
 	for rows.Next() {
 		var ae queue.JobItem
 		var podcast string
 		var episode string
 		var offsetMs, durationMs int64
-		if err := rows.Scan(&podcast, &ae.SourceURL, &offsetMs, &durationMs, &episode); err != nil {
-			return nil, fmt.Errorf("scan: %w", err)
+		if err := rows.Scan(&podcast, &ae.SourceURL, &offsetMs, &durationMs, &episode, &ae.GUID, &ae.FeedURL); err != nil {
+			return nil, nil, fmt.Errorf("scan: %w", err)
 		}
 		ae.Title = fmt.Sprintf("%s - %s", podcast, episode)
 		ae.ID = uuid.New().String()
 		ae.Offset = time.Duration(offsetMs) * time.Millisecond
 		ae.Duration = time.Duration(durationMs) * time.Millisecond
 		ae.Status = queue.StatusPending
+		ae.Position = len(results) + 1
 		results = append(results, ae)
+		names = append(names, episode)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows: %w", err)
+		return nil, nil, fmt.Errorf("rows: %w", err)
 	}
-	return results, nil
+	return results, names, nil
 }
 
 // extractBackupDB creates extracts the ZIP-formatted
 // Podcast Addict backup at backupPath database.
-func (p *PodcastAddictBackup) extractBackupDB(backupPath string) (string, error) {
+func extractBackupDB(backupPath string) (string, error) {
 	r, err := zip.OpenReader(backupPath)
 	if err != nil {
 		return "", fmt.Errorf("opening zip: %w", err)
@@ -0,0 +1,95 @@
+package sources
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cobblepod/internal/metadata"
+)
+
+// stubProvider returns the episode stored under the queried title
+type stubProvider map[string]*metadata.Episode
+
+func (s stubProvider) Lookup(ctx context.Context, query metadata.Query) (*metadata.Episode, error) {
+	return s[query.Title], nil
+}
+
+// backupDB creates a Podcast Addict database with a playlist of the given
+// episodes and returns its content. An empty URL is stored as NULL.
+func backupDB(t *testing.T, episodes ...[2]string) []byte {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "podcastAddict.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	statements := []string{
+		`CREATE TABLE podcasts (_id INTEGER PRIMARY KEY, name TEXT, feed_url TEXT)`,
+		`CREATE TABLE episodes (_id INTEGER PRIMARY KEY, podcast_id INTEGER, name TEXT, download_url TEXT, guid TEXT, position_to_resume INTEGER, duration_ms INTEGER)`,
+		`CREATE TABLE ordered_list (id INTEGER, type INTEGER, rank INTEGER)`,
+		`INSERT INTO podcasts VALUES (1, 'Trail Talk', 'https://example.com/feed.xml')`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("Failed to set up database: %v", err)
+		}
+	}
+	for i, episode := range episodes {
+		var downloadURL any
+		if episode[1] != "" {
+			downloadURL = episode[1]
+		}
+		if _, err := db.Exec(`INSERT INTO episodes VALUES (?, 1, ?, ?, NULL, 0, 60000)`, i+1, episode[0], downloadURL); err != nil {
+			t.Fatalf("Failed to add episode: %v", err)
+		}
+		if _, err := db.Exec(`INSERT INTO ordered_list VALUES (?, 1, ?)`, i+1, i); err != nil {
+			t.Fatalf("Failed to add episode to the playlist: %v", err)
+		}
+	}
+	db.Close()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read database: %v", err)
+	}
+	return content
+}
+
+func TestProcessFileResolvesMissingURLs(t *testing.T) {
+	backup := writeBackup(t, map[string][]byte{"podcastAddict.db": backupDB(t,
+		[2]string{"Episode 1", "https://cdn.example.com/ep1.mp3"},
+		[2]string{"Episode 2", ""},
+		[2]string{"Episode 3", ""},
+	)})
+	ctx := context.Background()
+
+	source := NewPodcastAddictBackup(nil)
+	source.SetMetadataProvider(stubProvider{"Episode 2": {AudioURL: "https://cdn.example.com/ep2.mp3"}})
+	entries, err := source.ProcessFile(ctx, backup)
+	if err != nil {
+		t.Fatalf("ProcessFile() error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected the episode without audio to be left out, got %+v", entries)
+	}
+	if entries[0].SourceURL != "https://cdn.example.com/ep1.mp3" || entries[0].FeedURL != "https://example.com/feed.xml" {
+		t.Errorf("Unexpected first entry %+v", entries[0])
+	}
+	if entries[1].SourceURL != "https://cdn.example.com/ep2.mp3" || entries[1].Title != "Trail Talk - Episode 2" || entries[1].Position != 2 {
+		t.Errorf("Expected the second entry's audio from the show's feed, got %+v", entries[1])
+	}
+
+	// Without a provider only episodes with a download URL are kept
+	entries, err = NewPodcastAddictBackup(nil).ProcessFile(ctx, backup)
+	if err != nil {
+		t.Fatalf("ProcessFile() error: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the episode with a download URL, got %+v", entries)
+	}
+}
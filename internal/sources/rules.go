@@ -0,0 +1,127 @@
+package sources
+
+import (
+	"cmp"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"cobblepod/internal/queue"
+
+	"github.com/google/uuid"
+)
+
+// subscribedEpisode is an episode of a subscribed show, as playlist rules see it
+type subscribedEpisode struct {
+	item   queue.JobItem
+	name   string // The episode's own title, without the show's
+	show   string
+	played bool
+}
+
+// remaining returns how much of the episode is left to listen to
+func (e subscribedEpisode) remaining() time.Duration {
+	return e.item.Duration - e.item.Offset
+}
+
+// querySubscribedEpisodes opens the SQLite database at dbPath and returns the
+// episodes of every show subscribed to, newest first
+func (p *PodcastAddictBackup) querySubscribedEpisodes(dbPath string) ([]subscribedEpisode, error) {
+	u := &url.URL{Scheme: "file", Path: dbPath, RawQuery: "mode=ro&_busy_timeout=5000"}
+	db, err := sql.Open("sqlite", u.String())
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+	defer db.Close()
+
+	const q = `
+		SELECT
+			p.name as podcast,
+			COALESCE(e.download_url, '') as url,
+			e.position_to_resume as offset,
+			e.duration_ms as duration,
+			e.name as episode,
+			COALESCE(e.guid, '') as guid,
+			COALESCE(p.feed_url, '') as feed_url,
+			COALESCE(e.seen_status, 0) as seen
+		FROM episodes e
+		JOIN podcasts p ON p._id = e.podcast_id
+		WHERE p.subscribed_status = 1
+		ORDER BY e.publication_date DESC
+	`
+
+	rows, err := db.Query(q)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
+
+	var episodes []subscribedEpisode
+	for rows.Next() {
+		var e subscribedEpisode
+		var offsetMs, durationMs, seen int64
+		if err := rows.Scan(&e.show, &e.item.SourceURL, &offsetMs, &durationMs, &e.name, &e.item.GUID, &e.item.FeedURL, &seen); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		e.item.Title = fmt.Sprintf("%s - %s", e.show, e.name)
+		e.item.Offset = time.Duration(offsetMs) * time.Millisecond
+		e.item.Duration = time.Duration(durationMs) * time.Millisecond
+		e.played = seen != 0
+		episodes = append(episodes, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return episodes, nil
+}
+
+// applyRules selects and orders episodes, given newest first, by rules. It
+// returns the playlist entries and each one's episode name.
+func applyRules(episodes []subscribedEpisode, rules *queue.PlaylistRules) ([]queue.JobItem, []string) {
+	perShow := make(map[string]int)
+	var selected []subscribedEpisode
+	for _, e := range episodes {
+		if rules.UnplayedOnly && e.played {
+			continue
+		}
+		if len(rules.Shows) > 0 && !slices.ContainsFunc(rules.Shows, func(show string) bool { return strings.EqualFold(show, e.show) }) {
+			continue
+		}
+		if perShow[e.show] >= rules.EpisodesPerShow {
+			continue
+		}
+		perShow[e.show]++
+		selected = append(selected, e)
+	}
+
+	switch rules.Order {
+	case queue.OrderOldest:
+		slices.Reverse(selected)
+	case queue.OrderShortest:
+		slices.SortStableFunc(selected, func(a, b subscribedEpisode) int { return cmp.Compare(a.remaining(), b.remaining()) })
+	case queue.OrderLongest:
+		slices.SortStableFunc(selected, func(a, b subscribedEpisode) int { return cmp.Compare(b.remaining(), a.remaining()) })
+	}
+
+	// Episodes that don't fit what's left of the limit are skipped, so a shorter one further on may still fit
+	limit := rules.MaxDuration()
+	var total time.Duration
+	var items []queue.JobItem
+	var names []string
+	for _, e := range selected {
+		if limit > 0 && total+e.remaining() > limit {
+			continue
+		}
+		total += e.remaining()
+		item := e.item
+		item.ID = uuid.New().String()
+		item.Status = queue.StatusPending
+		item.Position = len(items) + 1
+		items = append(items, item)
+		names = append(names, e.name)
+	}
+	return items, names
+}
@@ -0,0 +1,115 @@
+package sources
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cobblepod/internal/queue"
+)
+
+func TestApplyRules(t *testing.T) {
+	episode := func(show, name string, length time.Duration, played bool) subscribedEpisode {
+		return subscribedEpisode{
+			item:   queue.JobItem{Title: show + " - " + name, SourceURL: "https://example.com/" + name, Duration: length},
+			name:   name,
+			show:   show,
+			played: played,
+		}
+	}
+	// Newest first, as querySubscribedEpisodes returns them
+	episodes := []subscribedEpisode{
+		episode("Daily", "d1", 30*time.Minute, false),
+		episode("Long Talk", "l1", 3*time.Hour, false),
+		episode("Daily", "d2", 25*time.Minute, true),
+		episode("Daily", "d3", 20*time.Minute, false),
+		episode("Long Talk", "l2", 2*time.Hour, false),
+		episode("Daily", "d4", 35*time.Minute, false),
+	}
+	titles := func(items []queue.JobItem) []string {
+		var titles []string
+		for _, item := range items {
+			titles = append(titles, item.Title)
+		}
+		return titles
+	}
+
+	tests := []struct {
+		name  string
+		rules queue.PlaylistRules
+		want  []string
+	}{
+		{name: "newest of each show", rules: queue.PlaylistRules{EpisodesPerShow: 1}, want: []string{"Daily - d1", "Long Talk - l1"}},
+		{name: "unplayed only", rules: queue.PlaylistRules{EpisodesPerShow: 2, UnplayedOnly: true}, want: []string{"Daily - d1", "Long Talk - l1", "Daily - d3", "Long Talk - l2"}},
+		{name: "some shows", rules: queue.PlaylistRules{EpisodesPerShow: 2, Shows: []string{"daily"}}, want: []string{"Daily - d1", "Daily - d2"}},
+		{name: "oldest first", rules: queue.PlaylistRules{EpisodesPerShow: 1, Order: queue.OrderOldest}, want: []string{"Long Talk - l1", "Daily - d1"}},
+		{name: "shortest first within a limit", rules: queue.PlaylistRules{EpisodesPerShow: 3, UnplayedOnly: true, Order: queue.OrderShortest, MaxMinutes: 240}, want: []string{"Daily - d3", "Daily - d1", "Daily - d4", "Long Talk - l2"}},
+		{name: "longer episodes are skipped for ones that fit", rules: queue.PlaylistRules{EpisodesPerShow: 1, MaxMinutes: 60}, want: []string{"Daily - d1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, names := applyRules(episodes, &tt.rules)
+			got := titles(items)
+			if len(got) != len(tt.want) {
+				t.Fatalf("applyRules() = %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Fatalf("applyRules() = %v, want %v", got, tt.want)
+				}
+				if items[i].Position != i+1 || items[i].ID == "" || items[i].Status != queue.StatusPending {
+					t.Errorf("Unexpected entry %+v", items[i])
+				}
+			}
+			if len(names) != len(items) {
+				t.Errorf("Expected a name for every entry, got %v", names)
+			}
+		})
+	}
+}
+
+func TestProcessFileWithRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "podcastAddict.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	statements := []string{
+		`CREATE TABLE podcasts (_id INTEGER PRIMARY KEY, name TEXT, feed_url TEXT, subscribed_status INTEGER)`,
+		`CREATE TABLE episodes (_id INTEGER PRIMARY KEY, podcast_id INTEGER, name TEXT, download_url TEXT, guid TEXT, position_to_resume INTEGER, duration_ms INTEGER, seen_status INTEGER, publication_date INTEGER)`,
+		`CREATE TABLE ordered_list (id INTEGER, type INTEGER, rank INTEGER)`,
+		`INSERT INTO podcasts VALUES (1, 'Daily', 'https://example.com/daily.xml', 1), (2, 'Unsubscribed', 'https://example.com/old.xml', 0)`,
+		`INSERT INTO episodes VALUES
+			(1, 1, 'Older', 'https://example.com/older.mp3', 'g1', 0, 1800000, 0, 1000),
+			(2, 1, 'Newer', 'https://example.com/newer.mp3', 'g2', 600000, 1800000, 0, 2000),
+			(3, 2, 'Gone', 'https://example.com/gone.mp3', 'g3', 0, 1800000, 0, 3000)`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("Failed to set up database: %v", err)
+		}
+	}
+	db.Close()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read database: %v", err)
+	}
+	backup := writeBackup(t, map[string][]byte{"podcastAddict.db": content})
+
+	source := NewPodcastAddictBackup(nil)
+	source.SetRules(&queue.PlaylistRules{EpisodesPerShow: 1})
+	entries, err := source.ProcessFile(context.Background(), backup)
+	if err != nil {
+		t.Fatalf("ProcessFile() error: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected the newest episode of the subscribed show, got %+v", entries)
+	}
+	if entries[0].Title != "Daily - Newer" || entries[0].GUID != "g2" || entries[0].Offset != 10*time.Minute || entries[0].FeedURL != "https://example.com/daily.xml" {
+		t.Errorf("Unexpected entry %+v", entries[0])
+	}
+}
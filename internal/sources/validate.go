@@ -0,0 +1,71 @@
+package sources
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+)
+
+// ErrInvalidBackup is returned by ValidateBackup for files that aren't a
+// readable Podcast Addict backup
+var ErrInvalidBackup = errors.New("invalid Podcast Addict backup")
+
+// sqliteHeader starts every SQLite 3 database file
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+// requiredBackupTables are the tables the backup queries read
+var requiredBackupTables = []string{"podcasts", "episodes", "ordered_list"}
+
+// ValidateBackup checks that the file at backupPath is a ZIP holding a SQLite
+// database with the tables processing needs, so a corrupt upload is rejected
+// before a job is created. Problems with the file wrap ErrInvalidBackup.
+func ValidateBackup(backupPath string) error {
+	dbPath, err := extractBackupDB(backupPath)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	defer os.Remove(dbPath)
+
+	if err := checkSQLiteHeader(dbPath); err != nil {
+		return err
+	}
+
+	u := &url.URL{Scheme: "file", Path: dbPath, RawQuery: "mode=ro&_busy_timeout=5000"}
+	db, err := sql.Open("sqlite", u.String())
+	if err != nil {
+		return fmt.Errorf("open sqlite: %w", err)
+	}
+	defer db.Close()
+
+	for _, table := range requiredBackupTables {
+		var name string
+		err := db.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&name)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: missing %s table", ErrInvalidBackup, table)
+		}
+		if err != nil {
+			// The header was fine, so a failing read means the database is damaged
+			return fmt.Errorf("%w: reading database: %v", ErrInvalidBackup, err)
+		}
+	}
+	return nil
+}
+
+// checkSQLiteHeader reports files that aren't SQLite databases at all
+func checkSQLiteHeader(dbPath string) error {
+	file, err := os.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("opening extracted database: %w", err)
+	}
+	defer file.Close()
+
+	header := make([]byte, len(sqliteHeader))
+	if _, err := io.ReadFull(file, header); err != nil || !bytes.Equal(header, sqliteHeader) {
+		return fmt.Errorf("%w: database is not a SQLite file", ErrInvalidBackup)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package sources
+
+import (
+	"archive/zip"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeBackup zips the given entries into a backup file and returns its path
+func writeBackup(t *testing.T, entries map[string][]byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.backup")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create backup: %v", err)
+	}
+	defer file.Close()
+
+	w := zip.NewWriter(file)
+	for name, content := range entries {
+		entry, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to add %s: %v", name, err)
+		}
+		entry.Write(content)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to write backup: %v", err)
+	}
+	return path
+}
+
+// sqliteDB creates a database with the given tables and returns its content
+func sqliteDB(t *testing.T, tables ...string) []byte {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "podcastAddict.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	for _, table := range tables {
+		if _, err := db.Exec("CREATE TABLE " + table + " (_id INTEGER PRIMARY KEY)"); err != nil {
+			t.Fatalf("Failed to create %s: %v", table, err)
+		}
+	}
+	db.Close()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read database: %v", err)
+	}
+	return content
+}
+
+func TestValidateBackup(t *testing.T) {
+	notZip := filepath.Join(t.TempDir(), "not-a-zip.backup")
+	os.WriteFile(notZip, []byte("definitely not a zip"), 0o600)
+
+	tests := []struct {
+		name    string
+		path    string
+		invalid bool
+	}{
+		{name: "valid backup", path: writeBackup(t, map[string][]byte{"podcastAddict.db": sqliteDB(t, "podcasts", "episodes", "ordered_list")})},
+		{name: "not a zip", path: notZip, invalid: true},
+		{name: "no database", path: writeBackup(t, map[string][]byte{"preferences.xml": []byte("<map/>")}), invalid: true},
+		{name: "database is not sqlite", path: writeBackup(t, map[string][]byte{"podcastAddict.db": []byte("garbage")}), invalid: true},
+		{name: "missing tables", path: writeBackup(t, map[string][]byte{"podcastAddict.db": sqliteDB(t, "podcasts")}), invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateBackup(tt.path)
+			if tt.invalid != (err != nil) {
+				t.Fatalf("ValidateBackup() error = %v, want invalid %v", err, tt.invalid)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidBackup) {
+				t.Errorf("Expected ErrInvalidBackup, got %v", err)
+			}
+		})
+	}
+}
@@ -3,32 +3,99 @@ package state
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
 
 	"cobblepod/internal/config"
+	"cobblepod/internal/queue"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// CobblepodState is what a user's runs remember between jobs
 type CobblepodState struct {
+	// ChangesPageToken is the user's storage change page token
+	ChangesPageToken string `json:",omitempty"`
+	// Sources holds a cursor for each source kind (e.g. "backup") the user's runs
+	// have processed
+	Sources map[string]*SourceCursor `json:",omitempty"`
+	// LastRun is when the shared state's last run started, see legacyState;
+	// it is the watermark of source kinds without a cursor
+	LastRun time.Time `json:",omitzero"`
+}
+
+// SourceCursor records the last file of a source kind a user's runs saw and
+// the last they processed
+type SourceCursor struct {
+	// FileID and ModifiedTime are the watermark: the storage ID and modified
+	// time, as storage reported it, of the last file seen
+	FileID       string    `json:",omitempty"`
+	ModifiedTime time.Time `json:",omitzero"`
+	// Checksum is the content checksum of the last file processed
+	Checksum string `json:",omitempty"`
+	// Playlist fingerprints the file's playlist: each entry's listed duration
+	// and offset by episode key, so the next file only processes what changed
+	Playlist map[string]string `json:",omitempty"`
+}
+
+// Changed reports whether a file is past the watermark: another file, or the
+// same one modified since it was seen. Both times come from storage, so the
+// workers' clocks don't matter. A cursor without a file, carried over from
+// LastRun, only has a time to compare.
+func (c *SourceCursor) Changed(fileID string, modified time.Time) bool {
+	if c.FileID == "" {
+		return modified.After(c.ModifiedTime)
+	}
+	return fileID != c.FileID || modified.After(c.ModifiedTime)
+}
+
+// Source returns the cursor for a source kind, which only holds LastRun if
+// the user's runs haven't processed that kind yet
+func (s *CobblepodState) Source(source string) *SourceCursor {
+	if cursor, ok := s.Sources[source]; ok {
+		return cursor
+	}
+	return &SourceCursor{ModifiedTime: s.LastRun}
+}
+
+// SetSource replaces the cursor for a source kind
+func (s *CobblepodState) SetSource(source string, cursor *SourceCursor) {
+	if s.Sources == nil {
+		s.Sources = make(map[string]*SourceCursor)
+	}
+	s.Sources[source] = cursor
+}
+
+// legacyStateKey is the state every run shared before state was kept per
+// user; users without their own state start from it
+const legacyStateKey = "state"
+
+// legacyState is the state stored under legacyStateKey: when the last run
+// started, after which files hadn't been processed yet
+type legacyState struct {
 	LastRun time.Time
 }
 
+// stateUpdateAttempts is how many times UpdateState retries an update that
+// raced another
+const stateUpdateAttempts = 10
+
+// stateKey returns the key holding a user's state
+func stateKey(userID string) string {
+	return "state:user:" + userID
+}
+
 type CobblepodStateManager struct {
-	client *redis.Client
+	client redis.UniversalClient
 }
 
 // NewStateManager creates a new state connection using pure Go redis client
-func NewStateManager(ctx context.Context) (*CobblepodStateManager, error) {
-	addr := fmt.Sprintf("%s:%d", config.ValkeyHost, config.ValkeyPort)
-	slog.Debug("Connecting to Valkey", "addr", addr)
-	client := redis.NewClient(&redis.Options{
-		Addr:     addr,
-		Password: "", // Add to config if needed
-		DB:       0,
-	})
+func NewStateManager(ctx context.Context, cfg config.ValkeyConfig) (*CobblepodStateManager, error) {
+	opts := queue.RedisOptions(cfg)
+	slog.DebugContext(ctx, "Connecting to Valkey", "addrs", opts.Addrs, "master_name", opts.MasterName)
+	client := redis.NewUniversalClient(opts)
 
 	sm := &CobblepodStateManager{client: client}
 
@@ -42,37 +109,84 @@ func NewStateManager(ctx context.Context) (*CobblepodStateManager, error) {
 	return sm, nil
 }
 
-func (sm *CobblepodStateManager) GetState() (*CobblepodState, error) {
+// GetState returns a user's state, which is empty before their first run
+func (sm *CobblepodStateManager) GetState(ctx context.Context, userID string) (*CobblepodState, error) {
 	if sm.client == nil {
 		return nil, fmt.Errorf("state manager is not connected")
 	}
+	return getState(ctx, sm.client, userID)
+}
 
-	stateStr, err := sm.client.Get(context.Background(), "state").Result()
+// getState reads a user's state through c, falling back to the shared state
+func getState(ctx context.Context, c redis.Cmdable, userID string) (*CobblepodState, error) {
+	stateStr, err := c.Get(ctx, stateKey(userID)).Result()
+	if err == redis.Nil {
+		return legacyUserState(ctx, c)
+	}
 	if err != nil {
-		slog.Error("Error getting state", "error", err)
-		return &CobblepodState{LastRun: time.Unix(0, 0)}, err
+		return nil, fmt.Errorf("failed to get state: %w", err)
 	}
 
 	var state CobblepodState
 	if err := json.Unmarshal([]byte(stateStr), &state); err != nil {
-		slog.Error("Error unmarshalling state", "error", err)
-		return nil, err
+		return nil, fmt.Errorf("failed to unmarshal state: %w", err)
 	}
 	return &state, nil
 }
 
-func (sm *CobblepodStateManager) SaveState(state *CobblepodState) error {
-	if sm.client == nil {
-		return fmt.Errorf("state manager is not connected")
+// legacyUserState returns the state a user starts from: the shared state's
+// last run, or an empty state when there is none
+func legacyUserState(ctx context.Context, c redis.Cmdable) (*CobblepodState, error) {
+	stateStr, err := c.Get(ctx, legacyStateKey).Result()
+	if err == redis.Nil {
+		return &CobblepodState{}, nil
 	}
-	stateJSON, err := json.Marshal(state)
 	if err != nil {
-		return fmt.Errorf("failed to marshal state: %w", err)
+		return nil, fmt.Errorf("failed to get state: %w", err)
 	}
 
-	err = sm.client.Set(context.Background(), "state", stateJSON, 0).Err()
-	if err != nil {
-		return fmt.Errorf("failed to save state: %w", err)
+	var legacy legacyState
+	if err := json.Unmarshal([]byte(stateStr), &legacy); err != nil {
+		slog.WarnContext(ctx, "Ignoring unreadable shared state", "error", err)
+		return &CobblepodState{}, nil
+	}
+	return &CobblepodState{LastRun: legacy.LastRun}, nil
+}
+
+// UpdateState applies update to a user's current state and stores the result.
+// Jobs of the same user may run at once; an update racing another's is
+// retried on the state the other stored, so neither is lost.
+func (sm *CobblepodStateManager) UpdateState(ctx context.Context, userID string, update func(*CobblepodState)) error {
+	if sm.client == nil {
+		return fmt.Errorf("state manager is not connected")
+	}
+
+	key := stateKey(userID)
+	apply := func(tx *redis.Tx) error {
+		state, err := getState(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		update(state)
+		stateJSON, err := json.Marshal(state)
+		if err != nil {
+			return fmt.Errorf("failed to marshal state: %w", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, stateJSON, 0)
+			return nil
+		})
+		return err
+	}
+	for range stateUpdateAttempts {
+		err := sm.client.Watch(ctx, apply, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save state: %w", err)
+		}
+		return nil
 	}
-	return nil
+	return fmt.Errorf("failed to save state: too many concurrent updates")
 }
@@ -0,0 +1,53 @@
+//go:build integration
+// +build integration
+
+package state
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"cobblepod/internal/config"
+)
+
+func TestUpdateStateConcurrently(t *testing.T) {
+	ctx := context.Background()
+	cfg, err := config.FromEnv()
+	if err != nil {
+		t.Fatalf("Invalid test settings: %v", err)
+	}
+	sm, err := NewStateManager(ctx, cfg.Valkey)
+	if err != nil {
+		t.Skipf("Skipping test: Redis not available: %v", err)
+	}
+	userID := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	defer sm.client.Del(ctx, stateKey(userID))
+
+	// Jobs of the same user each move their own source kind's cursor
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			source := fmt.Sprintf("source-%d", i)
+			err := sm.UpdateState(ctx, userID, func(state *CobblepodState) {
+				state.SetSource(source, &SourceCursor{FileID: source})
+			})
+			if err != nil {
+				t.Errorf("UpdateState failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	state, err := sm.GetState(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetState failed: %v", err)
+	}
+	if len(state.Sources) != 10 {
+		t.Errorf("Expected every job's cursor to be kept, got %d", len(state.Sources))
+	}
+}
@@ -0,0 +1,59 @@
+package state
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestLegacyLastRun(t *testing.T) {
+	// The shared state as the last release stored it
+	var legacy legacyState
+	if err := json.Unmarshal([]byte(`{"LastRun":"2024-05-01T12:00:00Z"}`), &legacy); err != nil {
+		t.Fatalf("Failed to read the shared state: %v", err)
+	}
+	lastRun := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// Until a kind has its own cursor, files modified since the last run are new
+	state := &CobblepodState{LastRun: legacy.LastRun}
+	if state.Source("backup").Changed("backup-1", lastRun.Add(-time.Minute)) {
+		t.Error("Expected a file modified before the last run to be processed already")
+	}
+	if !state.Source("m3u8").Changed("m3u8-1", lastRun.Add(time.Minute)) {
+		t.Error("Expected a file modified after the last run to be new")
+	}
+
+	state.SetSource("backup", &SourceCursor{FileID: "backup-2", ModifiedTime: lastRun.Add(time.Hour)})
+	if got := state.Source("backup"); got.FileID != "backup-2" {
+		t.Errorf("Source() = %+v, want the kind's own cursor", got)
+	}
+	if got := (&CobblepodState{}).Source("backup"); got.Checksum != "" || got.Playlist != nil || !got.ModifiedTime.IsZero() {
+		t.Errorf("Source() of an empty state = %+v, want an empty cursor", got)
+	}
+}
+
+func TestSourceCursorChanged(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cursor := &SourceCursor{FileID: "backup-1", ModifiedTime: modified}
+
+	tests := []struct {
+		name     string
+		fileID   string
+		modified time.Time
+		expected bool
+	}{
+		{name: "same file", fileID: "backup-1", modified: modified, expected: false},
+		{name: "same file modified since", fileID: "backup-1", modified: modified.Add(time.Second), expected: true},
+		{name: "another file modified earlier", fileID: "backup-2", modified: modified.Add(-time.Hour), expected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cursor.Changed(tt.fileID, tt.modified); got != tt.expected {
+				t.Errorf("Changed() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+	if !(&SourceCursor{}).Changed("backup-1", modified) {
+		t.Error("Expected any file to be past an empty watermark")
+	}
+}
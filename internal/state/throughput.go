@@ -0,0 +1,99 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// encodeStatsKey is the hash of the running totals behind EncodeStats. It's
+// kept apart from the state blob so concurrent workers can add to it.
+const encodeStatsKey = "state:encode"
+
+// encodeHistory is how many runs the totals cover before they're halved, so
+// the rates follow changes in hardware and load instead of averaging them away
+const encodeHistory = 100
+
+// recordEncode adds a run to the totals and halves them once they cover more
+// than ARGV[3] runs
+var recordEncode = redis.NewScript(`
+local audio = redis.call("HINCRBY", KEYS[1], "audio_ms", ARGV[1])
+local took = redis.call("HINCRBY", KEYS[1], "took_ms", ARGV[2])
+local runs = redis.call("HINCRBY", KEYS[1], "runs", 1)
+if runs > tonumber(ARGV[3]) then
+	redis.call("HSET", KEYS[1], "audio_ms", math.floor(audio / 2), "took_ms", math.floor(took / 2), "runs", math.floor(runs / 2))
+end
+return runs
+`)
+
+// EncodeStats are running totals of the audio recent runs encoded and how long
+// they took, downloads and uploads included
+type EncodeStats struct {
+	Audio time.Duration
+	Took  time.Duration
+	Runs  int64
+}
+
+// PerMinute returns how long a minute of source audio takes to process, or
+// zero before any run has been recorded
+func (s EncodeStats) PerMinute() time.Duration {
+	if s.Audio <= 0 {
+		return 0
+	}
+	return time.Duration(float64(s.Took) * float64(time.Minute) / float64(s.Audio))
+}
+
+// PerRun returns how long a run takes on average, or zero before any run has
+// been recorded
+func (s EncodeStats) PerRun() time.Duration {
+	if s.Runs <= 0 {
+		return 0
+	}
+	return s.Took / time.Duration(s.Runs)
+}
+
+// RecordEncode adds a run that processed audio worth of source in took
+func (sm *CobblepodStateManager) RecordEncode(ctx context.Context, audio, took time.Duration) error {
+	if sm.client == nil {
+		return fmt.Errorf("state manager is not connected")
+	}
+	if audio <= 0 {
+		return nil
+	}
+	err := recordEncode.Run(ctx, sm.client, []string{encodeStatsKey}, audio.Milliseconds(), took.Milliseconds(), encodeHistory).Err()
+	if err != nil {
+		return fmt.Errorf("failed to record encode: %w", err)
+	}
+	return nil
+}
+
+// EncodeStats returns the totals RecordEncode keeps
+func (sm *CobblepodStateManager) EncodeStats(ctx context.Context) (EncodeStats, error) {
+	if sm.client == nil {
+		return EncodeStats{}, fmt.Errorf("state manager is not connected")
+	}
+	var totals struct {
+		AudioMS int64 `redis:"audio_ms"`
+		TookMS  int64 `redis:"took_ms"`
+		Runs    int64 `redis:"runs"`
+	}
+	if err := sm.client.HGetAll(ctx, encodeStatsKey).Scan(&totals); err != nil {
+		return EncodeStats{}, fmt.Errorf("failed to get encode stats: %w", err)
+	}
+	return EncodeStats{
+		Audio: time.Duration(totals.AudioMS) * time.Millisecond,
+		Took:  time.Duration(totals.TookMS) * time.Millisecond,
+		Runs:  totals.Runs,
+	}, nil
+}
+
+// EncodeRates implements queue.EncodeRater
+func (sm *CobblepodStateManager) EncodeRates(ctx context.Context) (perMinute, perRun time.Duration, err error) {
+	stats, err := sm.EncodeStats(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	return stats.PerMinute(), stats.PerRun(), nil
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// ErrCircuitOpen is returned instead of calling the backend while a Breaker is
+// open
+var ErrCircuitOpen = errors.New("storage is unavailable, circuit open")
+
+// Breaker stops calls to a backend that keeps failing. After threshold
+// consecutive upstream failures (server errors and network errors, not
+// answers such as a missing file, nor calls the caller cancelled) it opens: calls fail with ErrCircuitOpen
+// until cooldown passes, then one call at a time is let through per cooldown
+// to probe the backend, and the first success closes it again.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// NewBreaker returns a breaker that opens after threshold consecutive failures
+// for cooldown; a threshold of zero never opens
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow returns ErrCircuitOpen if a call may not go through now
+func (b *Breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return nil
+	}
+	now := time.Now()
+	if now.Before(b.openUntil) {
+		return ErrCircuitOpen
+	}
+	// Let this call probe the backend and hold the others off another cooldown
+	b.openUntil = now.Add(b.cooldown)
+	return nil
+}
+
+// record counts the outcome of a call that went through. A call whose ctx ended
+// says nothing about the backend, so it's neither a failure nor a success.
+func (b *Breaker) record(ctx context.Context, err error) {
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !isUpstreamFailure(err) {
+		if b.threshold > 0 && b.failures >= b.threshold {
+			slog.Info("Storage circuit closed")
+		}
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.threshold > 0 && b.failures >= b.threshold {
+		if b.failures == b.threshold {
+			slog.Warn("Storage circuit opened", "error", err, "failures", b.failures, "cooldown", b.cooldown)
+		}
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// do runs call, made on behalf of ctx, through the breaker
+func (b *Breaker) do(ctx context.Context, call func() error) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+	err := call()
+	b.record(ctx, err)
+	return err
+}
+
+// guarded runs call through s's breaker, returning its result
+func guarded[T any](s *guardedStorage, call func() (T, error)) (T, error) {
+	var result T
+	err := s.breaker.do(s.ctx, func() error {
+		var err error
+		result, err = call()
+		return err
+	})
+	return result, err
+}
+
+// isUpstreamFailure reports whether err means the backend is unavailable
+// rather than that it refused or couldn't find something
+func isUpstreamFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// guardedStorage calls its backend through a Breaker
+type guardedStorage struct {
+	Storage
+	ctx     context.Context
+	breaker *Breaker
+}
+
+// WithBreaker returns s, created with ctx, with its backend calls made through
+// breaker, which may be shared by the storage of many users; a nil breaker
+// returns s. Calls failing once ctx is done aren't counted against the backend.
+func WithBreaker(ctx context.Context, s Storage, breaker *Breaker) Storage {
+	if breaker == nil {
+		return s
+	}
+	return &guardedStorage{Storage: s, ctx: ctx, breaker: breaker}
+}
+
+// The methods below call the backend through the breaker; the rest, which
+// don't, are passed through
+
+func (s *guardedStorage) GetFiles(query Query) ([]*FileMeta, error) {
+	return guarded(s, func() ([]*FileMeta, error) { return s.Storage.GetFiles(query) })
+}
+
+func (s *guardedStorage) FileExists(fileID string) (bool, error) {
+	return guarded(s, func() (bool, error) { return s.Storage.FileExists(fileID) })
+}
+
+func (s *guardedStorage) DeleteFile(fileID string) error {
+	return s.breaker.do(s.ctx, func() error { return s.Storage.DeleteFile(fileID) })
+}
+
+func (s *guardedStorage) MoveFile(fileID, folder string) error {
+	return s.breaker.do(s.ctx, func() error { return s.Storage.MoveFile(fileID, folder) })
+}
+
+func (s *guardedStorage) GetFileMeta(fileID string) (*FileMeta, error) {
+	return guarded(s, func() (*FileMeta, error) { return s.Storage.GetFileMeta(fileID) })
+}
+
+func (s *guardedStorage) Quota() (*QuotaInfo, error) {
+	return guarded(s, s.Storage.Quota)
+}
+
+func (s *guardedStorage) GetChanges(pageToken string) (*ChangeSet, error) {
+	return guarded(s, func() (*ChangeSet, error) { return s.Storage.GetChanges(pageToken) })
+}
+
+func (s *guardedStorage) WatchChanges(channelID, address, token string) (*WatchInfo, error) {
+	return guarded(s, func() (*WatchInfo, error) { return s.Storage.WatchChanges(channelID, address, token) })
+}
+
+func (s *guardedStorage) EnsurePublic(fileID string) (bool, error) {
+	return guarded(s, func() (bool, error) { return s.Storage.EnsurePublic(fileID) })
+}
+
+func (s *guardedStorage) UseFolder(path string) error {
+	return s.breaker.do(s.ctx, func() error { return s.Storage.UseFolder(path) })
+}
+
+func (s *guardedStorage) DownloadFile(fileID string) (string, error) {
+	return guarded(s, func() (string, error) { return s.Storage.DownloadFile(fileID) })
+}
+
+func (s *guardedStorage) DownloadFileToTemp(fileID string) (string, error) {
+	return guarded(s, func() (string, error) { return s.Storage.DownloadFileToTemp(fileID) })
+}
+
+func (s *guardedStorage) UploadFile(filePath, filename, mimeType string) (string, error) {
+	return guarded(s, func() (string, error) { return s.Storage.UploadFile(filePath, filename, mimeType) })
+}
+
+func (s *guardedStorage) UploadFileWithProgress(filePath, filename, mimeType string, progress ProgressFunc) (string, error) {
+	return guarded(s, func() (string, error) {
+		return s.Storage.UploadFileWithProgress(filePath, filename, mimeType, progress)
+	})
+}
+
+func (s *guardedStorage) UploadString(content, filename, mimeType, fileID string) (string, error) {
+	return guarded(s, func() (string, error) { return s.Storage.UploadString(content, filename, mimeType, fileID) })
+}
+
+func (s *guardedStorage) CreateUploadSession(filename, mimeType string, size int64, origin string) (string, error) {
+	return guarded(s, func() (string, error) { return s.Storage.CreateUploadSession(filename, mimeType, size, origin) })
+}
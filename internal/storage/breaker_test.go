@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestBreaker(t *testing.T) {
+	unavailable := fmt.Errorf("failed to list files: %w", &googleapi.Error{Code: 503})
+	notFound := fmt.Errorf("failed to get file: %w", &googleapi.Error{Code: 404})
+	ctx := context.Background()
+	breaker := NewBreaker(3, 20*time.Millisecond)
+	calls := 0
+	call := func(err error) func() error {
+		return func() error {
+			calls++
+			return err
+		}
+	}
+
+	// Answers such as a missing file don't count
+	breaker.do(ctx, call(unavailable))
+	breaker.do(ctx, call(unavailable))
+	breaker.do(ctx, call(notFound))
+	breaker.do(ctx, call(unavailable))
+	breaker.do(ctx, call(unavailable))
+	if err := breaker.do(ctx, call(nil)); err != nil {
+		t.Fatalf("Expected the breaker to stay closed, got %v", err)
+	}
+
+	for range 3 {
+		breaker.do(ctx, call(unavailable))
+	}
+	calls = 0
+	if err := breaker.do(ctx, call(nil)); !errors.Is(err, ErrCircuitOpen) || calls != 0 {
+		t.Fatalf("Expected the open breaker to refuse the call, got %v after %d calls", err, calls)
+	}
+
+	// After the cooldown one call probes the backend; a failure reopens it
+	time.Sleep(25 * time.Millisecond)
+	if err := breaker.do(ctx, call(unavailable)); !errors.Is(err, unavailable) || calls != 1 {
+		t.Fatalf("Expected a probe, got %v after %d calls", err, calls)
+	}
+	if err := breaker.do(ctx, call(nil)); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected the failed probe to reopen the breaker, got %v", err)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	breaker.do(ctx, call(nil))
+	if err := breaker.do(ctx, call(nil)); err != nil {
+		t.Errorf("Expected a successful probe to close the breaker, got %v", err)
+	}
+}
+
+func TestBreakerIgnoresCancelledCalls(t *testing.T) {
+	unavailable := &googleapi.Error{Code: 503}
+	breaker := NewBreaker(2, time.Minute)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	interrupted := &url.Error{Op: "Get", URL: "https://www.googleapis.com/drive/v3/files", Err: context.Canceled}
+
+	// A call the caller gave up on neither counts as a failure nor resets them
+	breaker.do(context.Background(), func() error { return unavailable })
+	breaker.do(cancelled, func() error { return interrupted })
+	breaker.do(cancelled, func() error { return interrupted })
+	if err := breaker.do(context.Background(), func() error { return nil }); err != nil {
+		t.Fatalf("Expected cancelled calls not to open the breaker, got %v", err)
+	}
+
+	breaker.do(context.Background(), func() error { return unavailable })
+	breaker.do(cancelled, func() error { return interrupted })
+	breaker.do(context.Background(), func() error { return unavailable })
+	if err := breaker.do(context.Background(), func() error { return nil }); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected a cancelled call not to reset the failures, got %v", err)
+	}
+}
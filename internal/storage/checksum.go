@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned when the checksum reported by a storage backend
+// doesn't match the local file that was uploaded
+var ErrChecksumMismatch = errors.New("uploaded file checksum mismatch")
+
+// Checksums holds hex-encoded digests of a local file. SHA256 is the canonical
+// digest; MD5 is kept for backends that only report an MD5 (e.g. Drive md5Checksum).
+type Checksums struct {
+	SHA256 string
+	MD5    string
+}
+
+// FileChecksums computes the digests of a file in a single pass
+func FileChecksums(filePath string) (Checksums, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return Checksums{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	sha := sha256.New()
+	md := md5.New()
+	if _, err := io.Copy(io.MultiWriter(sha, md), file); err != nil {
+		return Checksums{}, fmt.Errorf("failed to hash file: %w", err)
+	}
+
+	return Checksums{
+		SHA256: hex.EncodeToString(sha.Sum(nil)),
+		MD5:    hex.EncodeToString(md.Sum(nil)),
+	}, nil
+}
+
+// Verify compares the local digests with those reported by the backend,
+// preferring SHA-256 when the backend provides it. Empty remote values are
+// treated as unavailable; if the backend reports neither, Verify returns nil.
+func (c Checksums) Verify(remoteMD5, remoteSHA256 string) error {
+	if remoteSHA256 != "" {
+		if !strings.EqualFold(c.SHA256, remoteSHA256) {
+			return fmt.Errorf("%w: sha256 %s, backend reported %s", ErrChecksumMismatch, c.SHA256, remoteSHA256)
+		}
+		return nil
+	}
+	if remoteMD5 != "" && !strings.EqualFold(c.MD5, remoteMD5) {
+		return fmt.Errorf("%w: md5 %s, backend reported %s", ErrChecksumMismatch, c.MD5, remoteMD5)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileChecksums(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "episode.mp3")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	sums, err := FileChecksums(path)
+	if err != nil {
+		t.Fatalf("FileChecksums() unexpected error: %v", err)
+	}
+	if sums.SHA256 != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("Unexpected SHA256: %s", sums.SHA256)
+	}
+	if sums.MD5 != "5d41402abc4b2a76b9719d911017c592" {
+		t.Errorf("Unexpected MD5: %s", sums.MD5)
+	}
+}
+
+func TestChecksumsVerify(t *testing.T) {
+	sums := Checksums{SHA256: "abc123", MD5: "def456"}
+
+	tests := []struct {
+		name         string
+		remoteMD5    string
+		remoteSHA256 string
+		wantMismatch bool
+	}{
+		{name: "sha256 match", remoteSHA256: "ABC123"},
+		{name: "sha256 mismatch", remoteSHA256: "000000", wantMismatch: true},
+		{name: "sha256 preferred over md5", remoteMD5: "000000", remoteSHA256: "abc123"},
+		{name: "md5 match", remoteMD5: "def456"},
+		{name: "md5 mismatch", remoteMD5: "000000", wantMismatch: true},
+		{name: "no remote checksum", wantMismatch: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := sums.Verify(tt.remoteMD5, tt.remoteSHA256)
+			if got := errors.Is(err, ErrChecksumMismatch); got != tt.wantMismatch {
+				t.Errorf("Verify() error = %v, wantMismatch %v", err, tt.wantMismatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEpisodeLinks(t *testing.T) {
+	links := NewEpisodeLinks("https://cobblepod.example.com/", "0123456789abcdef")
+
+	link := links.URL("file-1_A")
+	if !strings.HasPrefix(link, "https://cobblepod.example.com/api/episodes/file-1_A/audio?token=") {
+		t.Errorf("Unexpected link: %s", link)
+	}
+	if got := links.FileID(link); got != "file-1_A" {
+		t.Errorf("FileID() = %q, want file-1_A", got)
+	}
+	if !links.Verify("file-1_A", links.Token("file-1_A")) {
+		t.Error("Expected the link's own token to verify")
+	}
+	if links.Verify("file-2", links.Token("file-1_A")) {
+		t.Error("Expected a token to only verify its own file")
+	}
+	if other := NewEpisodeLinks("https://cobblepod.example.com", "another secret!!"); other.Verify("file-1_A", links.Token("file-1_A")) {
+		t.Error("Expected a token to only verify with its own secret")
+	}
+	if got := links.FileID(DriveDownloadURL("file-1_A")); got != "" {
+		t.Errorf("FileID() of a storage URL = %q, want none", got)
+	}
+}
+
+func TestWithEpisodeLinks(t *testing.T) {
+	links := NewEpisodeLinks("https://cobblepod.example.com", "0123456789abcdef")
+	s := WithEpisodeLinks(&GDrive{}, links)
+
+	if got := s.GenerateDownloadURL("file-1"); got != links.URL("file-1") {
+		t.Errorf("GenerateDownloadURL() = %q, want the server link", got)
+	}
+	// Feeds written before episodes were linked through the server still work
+	for _, link := range []string{links.URL("file-1"), DriveDownloadURL("file-1")} {
+		if got := s.ExtractFileIDFromURL(link); got != "file-1" {
+			t.Errorf("ExtractFileIDFromURL(%q) = %q, want file-1", link, got)
+		}
+	}
+}
@@ -5,13 +5,16 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"net/http"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
 	"golang.org/x/oauth2"
 	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 )
 
@@ -20,6 +23,12 @@ type GDrive struct {
 	drive *drive.Service
 	// For multi-user scenarios, store context needed to create per-user clients
 	ctx context.Context
+	// uploadFolderID is the parent for new files; empty means the Drive root
+	uploadFolderID string
+	// client is authorized as the user, for requests made outside the Drive library
+	client *http.Client
+	// tags label new files, stored as Drive appProperties
+	tags map[string]string
 }
 
 // NewServiceWithToken creates a new Google Drive service using an OAuth2 token
@@ -35,8 +44,15 @@ func NewServiceWithToken(ctx context.Context, accessToken string) (Storage, erro
 		TokenType:   "Bearer",
 	}
 
-	// Create OAuth2 token source
-	tokenSource := oauth2.StaticTokenSource(token)
+	return NewServiceWithTokenSource(ctx, oauth2.StaticTokenSource(token))
+}
+
+// NewServiceWithTokenSource creates a Google Drive service that takes its tokens
+// from tokenSource, so a refreshing source keeps long-running work authorized
+func NewServiceWithTokenSource(ctx context.Context, tokenSource oauth2.TokenSource) (Storage, error) {
+	if tokenSource == nil {
+		return nil, fmt.Errorf("token source is required")
+	}
 
 	// Create Drive service with the token
 	service, err := drive.NewService(ctx, option.WithTokenSource(tokenSource))
@@ -44,8 +60,8 @@ func NewServiceWithToken(ctx context.Context, accessToken string) (Storage, erro
 		return nil, fmt.Errorf("failed to create Drive service with token: %w", err)
 	}
 
-	slog.Info("Google Drive service initialized with OAuth token")
-	return &GDrive{drive: service, ctx: ctx}, nil
+	slog.InfoContext(ctx, "Google Drive service initialized with OAuth token")
+	return &GDrive{drive: service, ctx: ctx, client: oauth2.NewClient(ctx, tokenSource)}, nil
 }
 
 func NewServiceWithClient(client *drive.Service) Storage {
@@ -54,6 +70,12 @@ func NewServiceWithClient(client *drive.Service) Storage {
 
 // GenerateDownloadURL converts a Google Drive file ID to a direct download URL
 func (s *GDrive) GenerateDownloadURL(driveID string) string {
+	return DriveDownloadURL(driveID)
+}
+
+// DriveDownloadURL returns the direct download URL of a public Google Drive
+// file; it needs no credentials, so the server can redirect episodes to it
+func DriveDownloadURL(driveID string) string {
 	return fmt.Sprintf("https://drive.usercontent.google.com/download?id=%s&export=download&authuser=0&confirm=t", driveID)
 }
 
@@ -67,44 +89,259 @@ func (s *GDrive) ExtractFileIDFromURL(url string) string {
 	return ""
 }
 
+// driveFileFields is the Drive field selector for file listings
+const driveFileFields = "nextPageToken, files(id, name, modifiedTime, size, mimeType, md5Checksum, appProperties)"
+
+// driveFolderMIME is the MIME type Drive uses for folders
+const driveFolderMIME = "application/vnd.google-apps.folder"
+
+// driveQuery compiles a Query into Drive's search syntax. folderID is the
+// resolved ID of query.Folder, or empty when the query isn't scoped to a folder.
+func driveQuery(query Query, folderID string) string {
+	var clauses []string
+
+	if query.ExactName != "" {
+		clauses = append(clauses, fmt.Sprintf("name = '%s'", escapeDriveString(query.ExactName)))
+	}
+	if query.NamePattern != "" {
+		clauses = append(clauses, fmt.Sprintf("name contains '%s'", escapeDriveString(query.NamePattern)))
+	}
+	if query.Extension != "" {
+		clauses = append(clauses, fmt.Sprintf("name contains '%s'", escapeDriveString(query.Extension)))
+	}
+	if folderID != "" {
+		clauses = append(clauses, fmt.Sprintf("'%s' in parents", escapeDriveString(folderID)))
+	}
+	keys := make([]string, 0, len(query.Tags))
+	for key := range query.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		clauses = append(clauses, fmt.Sprintf("appProperties has { key='%s' and value='%s' }", escapeDriveString(key), escapeDriveString(query.Tags[key])))
+	}
+	clauses = append(clauses, fmt.Sprintf("trashed = %t", query.Trashed))
+
+	return strings.Join(clauses, " and ")
+}
+
+// escapeDriveString escapes a value for use inside a quoted Drive query string
+func escapeDriveString(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return strings.ReplaceAll(value, "'", `\'`)
+}
+
 // GetFiles searches for files matching the given query
-func (s *GDrive) GetFiles(query string, mostRecent bool) ([]*drive.File, error) {
-	call := s.drive.Files.List().Q(query).Fields("files(id, name, modifiedTime)")
+func (s *GDrive) GetFiles(query Query) ([]*FileMeta, error) {
+	var folderID string
+	if query.Folder != "" {
+		var err error
+		folderID, err = s.resolveFolder(query.Folder, false)
+		if err != nil {
+			return nil, err
+		}
+		if folderID == "" {
+			// The folder doesn't exist yet, so nothing can be in it
+			return []*FileMeta{}, nil
+		}
+	}
+
+	call := s.drive.Files.List().Q(driveQuery(query, folderID)).Fields(driveFileFields)
 
-	if mostRecent {
-		call = call.OrderBy("modifiedTime desc").PageSize(1)
+	if query.SortByModified {
+		call = call.OrderBy("modifiedTime desc")
+	}
+	files := []*FileMeta{}
+	if query.Limit > 0 {
+		result, err := call.PageSize(int64(query.Limit)).Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files: %w", err)
+		}
+		for _, file := range result.Files {
+			files = append(files, toFileMeta(file))
+		}
+		return files, nil
 	}
 
-	result, err := call.Do()
+	// Without a limit every page is read, so large folders are listed completely
+	err := call.Pages(s.context(), func(result *drive.FileList) error {
+		for _, file := range result.Files {
+			files = append(files, toFileMeta(file))
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list files: %w", err)
 	}
+	return files, nil
+}
+
+// driveChangeFields is the Drive field selector for change listings
+const driveChangeFields = "nextPageToken, newStartPageToken, changes(removed, fileId, file(id, name, modifiedTime, size, mimeType, md5Checksum, trashed))"
 
-	return result.Files, nil
+// GetChanges lists files changed since pageToken using the Drive Changes API
+func (s *GDrive) GetChanges(pageToken string) (*ChangeSet, error) {
+	if pageToken == "" {
+		start, err := s.drive.Changes.GetStartPageToken().Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get start page token: %w", err)
+		}
+		return &ChangeSet{Files: []*FileMeta{}, NextPageToken: start.StartPageToken}, nil
+	}
+
+	changes := &ChangeSet{Files: []*FileMeta{}}
+	for pageToken != "" {
+		result, err := s.drive.Changes.List(pageToken).Fields(driveChangeFields).Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list changes: %w", err)
+		}
+
+		for _, change := range result.Changes {
+			if change.Removed || change.File == nil || change.File.Trashed {
+				continue
+			}
+			changes.Files = append(changes.Files, toFileMeta(change.File))
+		}
+
+		// newStartPageToken is only set on the last page
+		if result.NewStartPageToken != "" {
+			changes.NextPageToken = result.NewStartPageToken
+		}
+		pageToken = result.NextPageToken
+	}
+
+	return changes, nil
 }
 
-// GetMostRecentFile gets the most recently modified file from a list
-func (s *GDrive) GetMostRecentFile(files []*drive.File) *drive.File {
-	if len(files) == 0 {
-		return nil
+// WatchChanges registers a Drive push notification channel for changes to the user's Drive
+func (s *GDrive) WatchChanges(channelID, address, token string) (*WatchInfo, error) {
+	start, err := s.drive.Changes.GetStartPageToken().Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get start page token: %w", err)
 	}
 
-	var mostRecent *drive.File
-	var mostRecentTime time.Time
+	channel, err := s.drive.Changes.Watch(start.StartPageToken, &drive.Channel{
+		Id:      channelID,
+		Type:    "web_hook",
+		Address: address,
+		Token:   token,
+	}).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch changes: %w", err)
+	}
 
-	for _, file := range files {
-		if file.ModifiedTime == "" {
+	info := &WatchInfo{ResourceID: channel.ResourceId}
+	if channel.Expiration > 0 {
+		info.Expiration = time.UnixMilli(channel.Expiration)
+	}
+	return info, nil
+}
+
+// SetTags labels files created or replaced from now on with tags, as Drive appProperties
+func (s *GDrive) SetTags(tags map[string]string) {
+	s.tags = make(map[string]string, len(tags))
+	for key, value := range tags {
+		s.tags[key] = value
+	}
+}
+
+// context returns the context Drive calls made outside a single request run under
+func (s *GDrive) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// UseFolder creates the folder hierarchy if needed and uploads new files into it
+func (s *GDrive) UseFolder(path string) error {
+	folderID, err := s.resolveFolder(path, true)
+	if err != nil {
+		return err
+	}
+	s.uploadFolderID = folderID
+	return nil
+}
+
+// resolveFolder walks a slash-separated folder path from the Drive root and
+// returns the ID of the last folder. Missing folders are created when create is
+// set; otherwise an empty ID is returned for a path that doesn't exist.
+func (s *GDrive) resolveFolder(path string, create bool) (string, error) {
+	parentID := "root"
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		if name == "" {
+			continue
+		}
+
+		q := fmt.Sprintf("name = '%s' and mimeType = '%s' and '%s' in parents and trashed = false",
+			escapeDriveString(name), driveFolderMIME, escapeDriveString(parentID))
+		result, err := s.drive.Files.List().Q(q).Fields("files(id)").PageSize(1).Do()
+		if err != nil {
+			return "", fmt.Errorf("failed to look up folder %s: %w", name, err)
+		}
+		if len(result.Files) > 0 {
+			parentID = result.Files[0].Id
 			continue
 		}
+		if !create {
+			return "", nil
+		}
 
+		folder, err := s.drive.Files.Create(&drive.File{
+			Name:     name,
+			MimeType: driveFolderMIME,
+			Parents:  []string{parentID},
+		}).Fields("id").Do()
+		if err != nil {
+			return "", fmt.Errorf("failed to create folder %s: %w", name, err)
+		}
+		slog.InfoContext(s.ctx, "Created Drive folder", "name", name, "id", folder.Id)
+		parentID = folder.Id
+	}
+	return parentID, nil
+}
+
+// parents returns the parent list for newly created files
+func (s *GDrive) parents() []string {
+	if s.uploadFolderID == "" {
+		return nil
+	}
+	return []string{s.uploadFolderID}
+}
+
+// toFileMeta converts a Drive file into a provider-neutral FileMeta
+func toFileMeta(file *drive.File) *FileMeta {
+	meta := &FileMeta{
+		ID:   file.Id,
+		Name: file.Name,
+		Size: file.Size,
+		MIME: file.MimeType,
+		MD5:  file.Md5Checksum,
+		Tags: file.AppProperties,
+	}
+
+	if file.ModifiedTime != "" {
 		modifiedTime, err := time.Parse(time.RFC3339, file.ModifiedTime)
 		if err != nil {
 			slog.Warn("Could not parse modifiedTime", "time", file.ModifiedTime, "file", file.Name, "error", err)
+		} else {
+			meta.ModifiedTime = modifiedTime
+		}
+	}
+
+	return meta
+}
+
+// GetMostRecentFile gets the most recently modified file from a list
+func (s *GDrive) GetMostRecentFile(files []*FileMeta) *FileMeta {
+	var mostRecent *FileMeta
+
+	for _, file := range files {
+		if file == nil || file.ModifiedTime.IsZero() {
 			continue
 		}
 
-		if mostRecent == nil || modifiedTime.After(mostRecentTime) {
-			mostRecentTime = modifiedTime
+		if mostRecent == nil || file.ModifiedTime.After(mostRecent.ModifiedTime) {
 			mostRecent = file
 		}
 	}
@@ -149,6 +386,47 @@ func (s *GDrive) DeleteFile(fileID string) error {
 	return nil
 }
 
+// MoveFile moves a file from its current folders into the folder path
+func (s *GDrive) MoveFile(fileID, folder string) error {
+	if fileID == "" {
+		return fmt.Errorf("file ID is empty")
+	}
+	folderID, err := s.resolveFolder(folder, true)
+	if err != nil {
+		return err
+	}
+
+	file, err := s.drive.Files.Get(fileID).Fields("parents").Do()
+	if err != nil {
+		return fmt.Errorf("failed to get file %s: %w", fileID, err)
+	}
+	_, err = s.drive.Files.Update(fileID, &drive.File{}).
+		AddParents(folderID).
+		RemoveParents(strings.Join(file.Parents, ",")).
+		Fields("id").
+		Do()
+	if err != nil {
+		return fmt.Errorf("failed to move file %s: %w", fileID, err)
+	}
+	return nil
+}
+
+// Quota returns the storage usage and limit of the user's Drive
+func (s *GDrive) Quota() (*QuotaInfo, error) {
+	about, err := s.drive.About.Get().Fields("storageQuota(limit, usage)").Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage quota: %w", err)
+	}
+	if about.StorageQuota == nil {
+		return &QuotaInfo{}, nil
+	}
+
+	return &QuotaInfo{
+		Used:  about.StorageQuota.Usage,
+		Total: about.StorageQuota.Limit,
+	}, nil
+}
+
 // DownloadFile downloads a file and returns its content as a string
 func (s *GDrive) DownloadFile(fileID string) (string, error) {
 	resp, err := s.drive.Files.Get(fileID).Download()
@@ -187,8 +465,29 @@ func (s *GDrive) DownloadFileToTemp(fileID string) (string, error) {
 	return tmpFile.Name(), nil
 }
 
+const (
+	// uploadChunkSize is the chunk size for resumable uploads; each chunk is retried independently
+	uploadChunkSize = 8 * 1024 * 1024
+	// uploadChunkRetryDeadline bounds how long a single chunk is retried before the upload fails
+	uploadChunkRetryDeadline = 2 * time.Minute
+)
+
 // UploadFile uploads a file to Google Drive
 func (s *GDrive) UploadFile(filePath, filename, mimeType string) (string, error) {
+	return s.UploadFileWithProgress(filePath, filename, mimeType, nil)
+}
+
+// UploadFileWithProgress uploads a file to Google Drive using a resumable upload session.
+// The file is sent in chunks so a network failure only retries the current chunk
+// rather than restarting the whole upload. progress may be nil. The checksum Drive
+// reports for the stored file is verified against the local file, and a corrupted
+// upload is deleted and reported as ErrChecksumMismatch.
+func (s *GDrive) UploadFileWithProgress(filePath, filename, mimeType string, progress ProgressFunc) (string, error) {
+	sums, err := FileChecksums(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to checksum file: %w", err)
+	}
+
 	file, err := os.Open(filePath)
 	if err != nil {
 		return "", fmt.Errorf("failed to open file: %w", err)
@@ -196,16 +495,40 @@ func (s *GDrive) UploadFile(filePath, filename, mimeType string) (string, error)
 	defer file.Close()
 
 	fileMetadata := &drive.File{
-		Name: filename,
+		Name:          filename,
+		MimeType:      mimeType,
+		Parents:       s.parents(),
+		AppProperties: s.tags,
+	}
+
+	call := s.drive.Files.Create(fileMetadata).
+		Media(file, googleapi.ChunkSize(uploadChunkSize), googleapi.ChunkRetryDeadline(uploadChunkRetryDeadline)).
+		Fields("id, md5Checksum, sha256Checksum")
+
+	if progress != nil {
+		var total int64
+		if info, err := file.Stat(); err == nil {
+			total = info.Size()
+		}
+		call = call.ProgressUpdater(func(current, _ int64) {
+			progress(current, total)
+		})
 	}
 
 	// Create the file with content
-	createdFile, err := s.drive.Files.Create(fileMetadata).Media(file).Fields("id").Do()
+	createdFile, err := call.Do()
 	if err != nil {
 		return "", fmt.Errorf("failed to create file: %w", err)
 	}
 
-	slog.Info("File uploaded successfully", "filename", filename, "id", createdFile.Id)
+	if err := sums.Verify(createdFile.Md5Checksum, createdFile.Sha256Checksum); err != nil {
+		if delErr := s.DeleteFile(createdFile.Id); delErr != nil {
+			slog.ErrorContext(s.ctx, "Failed to delete corrupted upload", "filename", filename, "id", createdFile.Id, "error", delErr)
+		}
+		return "", fmt.Errorf("failed to verify upload of %s: %w", filename, err)
+	}
+
+	slog.InfoContext(s.ctx, "File uploaded successfully", "filename", filename, "id", createdFile.Id, "sha256", sums.SHA256)
 
 	// Set permissions
 	if err := s.setFilePermissions(createdFile.Id, filename); err != nil {
@@ -218,7 +541,8 @@ func (s *GDrive) UploadFile(filePath, filename, mimeType string) (string, error)
 // UploadString uploads a string as a file to Google Drive
 func (s *GDrive) UploadString(content, filename, mimeType, fileID string) (string, error) {
 	fileMetadata := &drive.File{
-		Name: filename,
+		Name:          filename,
+		AppProperties: s.tags,
 	}
 
 	reader := strings.NewReader(content)
@@ -231,6 +555,7 @@ func (s *GDrive) UploadString(content, filename, mimeType, fileID string) (strin
 		file, err = s.drive.Files.Update(fileID, fileMetadata).Media(reader).Fields("id").Do()
 	} else {
 		// Create new file
+		fileMetadata.Parents = s.parents()
 		file, err = s.drive.Files.Create(fileMetadata).Media(reader).Fields("id").Do()
 	}
 
@@ -246,6 +571,30 @@ func (s *GDrive) UploadString(content, filename, mimeType, fileID string) (strin
 	return file.Id, nil
 }
 
+// EnsurePublic restores the "anyone with the link" reader permission if Drive
+// dropped it, e.g. after a sharing policy change
+func (s *GDrive) EnsurePublic(fileID string) (bool, error) {
+	permissions, err := s.drive.Permissions.List(fileID).Fields("permissions(type, role)").Do()
+	if err != nil {
+		return false, fmt.Errorf("failed to list permissions: %w", err)
+	}
+	for _, permission := range permissions.Permissions {
+		if permission.Type == "anyone" && (permission.Role == "reader" || permission.Role == "writer") {
+			return false, nil
+		}
+	}
+
+	// Only repairs need the name, for the log line
+	file, err := s.drive.Files.Get(fileID).Fields("name").Do()
+	if err != nil {
+		return false, fmt.Errorf("failed to get file: %w", err)
+	}
+	if err := s.setFilePermissions(fileID, file.Name); err != nil {
+		return false, fmt.Errorf("failed to set permissions: %w", err)
+	}
+	return true, nil
+}
+
 // setFilePermissions sets file permissions to be readable by anyone with the link
 func (s *GDrive) setFilePermissions(fileID, filename string) error {
 	permission := &drive.Permission{
@@ -253,7 +602,7 @@ func (s *GDrive) setFilePermissions(fileID, filename string) error {
 		Role: "reader",
 	}
 
-	slog.Info("Setting permissions", "filename", filename, "id", fileID)
+	slog.InfoContext(s.ctx, "Setting permissions", "filename", filename, "id", fileID)
 	_, err := s.drive.Permissions.Create(fileID, permission).Do()
 	return err
 }
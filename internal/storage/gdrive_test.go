@@ -45,7 +45,7 @@ func TestGetFiles(t *testing.T) {
 				ModifiedTime: "2025-09-06T11:00:00.000Z",
 			},
 		},
-	}, "fields=files%28id%2C+name%2C+modifiedTime%29")
+	}, "fields=nextPageToken%2C+files%28id%2C+name%2C+modifiedTime%2C+size%2C+mimeType%2C+md5Checksum%2C+appProperties%29")
 	defer mockServer.Close()
 
 	// Create a Drive service that uses our mock server
@@ -59,7 +59,7 @@ func TestGetFiles(t *testing.T) {
 	service := &GDrive{drive: driveService}
 
 	// Test the GetFiles method
-	files, err := service.GetFiles("name contains 'test'", false)
+	files, err := service.GetFiles(Query{NamePattern: "test"})
 	if err != nil {
 		t.Fatalf("GetFiles failed: %v", err)
 	}
@@ -91,7 +91,7 @@ func TestGetFilesMostRecent(t *testing.T) {
 				ModifiedTime: "2025-09-06T12:00:00.000Z",
 			},
 		},
-	}, "fields=files%28id%2C+name%2C+modifiedTime%29")
+	}, "fields=nextPageToken%2C+files%28id%2C+name%2C+modifiedTime%2C+size%2C+mimeType%2C+md5Checksum%2C+appProperties%29")
 	defer mockServer.Close()
 
 	// Create a Drive service that uses our mock server
@@ -105,7 +105,7 @@ func TestGetFilesMostRecent(t *testing.T) {
 	service := &GDrive{drive: driveService}
 
 	// Test the GetFiles method with mostRecent=true
-	files, err := service.GetFiles("name contains 'latest'", true)
+	files, err := service.GetFiles(Query{NamePattern: "latest"}.MostRecent())
 	if err != nil {
 		t.Fatalf("GetFiles failed: %v", err)
 	}
@@ -121,3 +121,88 @@ func TestGetFilesMostRecent(t *testing.T) {
 
 	t.Log("GetFiles mostRecent test passed - Fields call with additional parameters was successfully mocked")
 }
+
+func TestDriveQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    Query
+		folderID string
+		expected string
+	}{
+		{
+			name:     "extension only",
+			query:    Query{Extension: ".m3u"},
+			expected: "name contains '.m3u' and trashed = false",
+		},
+		{
+			name:     "exact name",
+			query:    Query{ExactName: "playrun_addict.xml"},
+			expected: "name = 'playrun_addict.xml' and trashed = false",
+		},
+		{
+			name:     "pattern and extension",
+			query:    Query{NamePattern: "PodcastAddict", Extension: ".backup"},
+			expected: "name contains 'PodcastAddict' and name contains '.backup' and trashed = false",
+		},
+		{
+			name:     "trashed with quotes escaped",
+			query:    Query{ExactName: "it's.xml", Trashed: true},
+			expected: "name = 'it\\'s.xml' and trashed = true",
+		},
+		{
+			name:     "scoped to folder",
+			query:    Query{ExactName: "playrun_addict.xml", Folder: "cobblepod/playrun_addict"},
+			folderID: "folder-123",
+			expected: "name = 'playrun_addict.xml' and 'folder-123' in parents and trashed = false",
+		},
+		{
+			name:     "tags",
+			query:    Query{Tags: map[string]string{TagUser: "auth0|1", TagFeed: "cobblepod/playrun_addict"}},
+			expected: "appProperties has { key='cobblepod_feed' and value='cobblepod/playrun_addict' } and appProperties has { key='cobblepod_user' and value='auth0|1' } and trashed = false",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := driveQuery(tt.query, tt.folderID); got != tt.expected {
+				t.Errorf("Expected query %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCreateUploadSession(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/upload/drive/v3/files" || r.URL.Query().Get("uploadType") != "resumable" {
+			t.Errorf("Unexpected session request: %s %s", r.Method, r.URL.String())
+		}
+		if r.Header.Get("X-Upload-Content-Length") != "1024" || r.Header.Get("Origin") != "https://app.example.com" {
+			t.Errorf("Unexpected session headers: %v", r.Header)
+		}
+		var metadata struct {
+			Name    string   `json:"name"`
+			Parents []string `json:"parents"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&metadata); err != nil || metadata.Name != "podcast.backup" || len(metadata.Parents) != 1 || metadata.Parents[0] != "folder-1" {
+			t.Errorf("Unexpected session metadata: %+v (%v)", metadata, err)
+		}
+		w.Header().Set("Location", "https://upload.example.com/session-1")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	ctx := context.Background()
+	driveService, err := drive.NewService(ctx, option.WithoutAuthentication(), option.WithEndpoint(mockServer.URL))
+	if err != nil {
+		t.Fatalf("Failed to create drive service: %v", err)
+	}
+	service := &GDrive{drive: driveService, ctx: ctx, uploadFolderID: "folder-1"}
+
+	uploadURL, err := service.CreateUploadSession("podcast.backup", "application/octet-stream", 1024, "https://app.example.com")
+	if err != nil {
+		t.Fatalf("CreateUploadSession failed: %v", err)
+	}
+	if uploadURL != "https://upload.example.com/session-1" {
+		t.Errorf("Expected the session URI from Location, got %q", uploadURL)
+	}
+}
@@ -1,9 +1,5 @@
 package storage
 
-import (
-	"google.golang.org/api/drive/v3"
-)
-
 // Storage defines the interface for cloud storage operations.
 // This interface abstracts cloud storage functionality to allow for
 // different storage backend implementations while maintaining the same API.
@@ -13,14 +9,42 @@ type Storage interface {
 	// File management operations
 	GenerateDownloadURL(driveID string) string
 	ExtractFileIDFromURL(url string) string
-	GetFiles(query string, mostRecent bool) ([]*drive.File, error)
-	GetMostRecentFile(files []*drive.File) *drive.File
+	GetFiles(query Query) ([]*FileMeta, error)
+	GetMostRecentFile(files []*FileMeta) *FileMeta
 	FileExists(fileID string) (bool, error)
 	DeleteFile(fileID string) error
+	// MoveFile moves a file into the slash-separated folder path, creating the
+	// folders if needed. The file keeps its ID, so links to it keep working.
+	MoveFile(fileID, folder string) error
+	// GetFileMeta returns a file's metadata, or nil if it doesn't exist or is trashed
+	GetFileMeta(fileID string) (*FileMeta, error)
+	Quota() (*QuotaInfo, error)
+	// GetChanges lists files changed since pageToken. An empty pageToken returns
+	// no files and a token marking the current point in the change history.
+	GetChanges(pageToken string) (*ChangeSet, error)
+	// WatchChanges asks the backend to POST change notifications to address,
+	// echoing channelID and token so the receiver can authenticate them
+	WatchChanges(channelID, address, token string) (*WatchInfo, error)
+	// EnsurePublic makes sure the file is readable by anyone with its download URL,
+	// restoring the public permission if it was dropped. repaired reports whether
+	// anything had to change.
+	EnsurePublic(fileID string) (repaired bool, err error)
+	// UseFolder creates the slash-separated folder path if needed and makes it
+	// the destination for subsequently created files
+	UseFolder(path string) error
+	// SetTags labels subsequently created or replaced files with tags, see Query.Tags
+	SetTags(tags map[string]string)
 
 	// File content operations
 	DownloadFile(fileID string) (string, error)
 	DownloadFileToTemp(fileID string) (string, error)
 	UploadFile(filePath, filename, mimeType string) (string, error)
+	UploadFileWithProgress(filePath, filename, mimeType string, progress ProgressFunc) (string, error)
 	UploadString(content, filename, mimeType, fileID string) (string, error)
+	// CreateUploadSession starts a resumable upload into the current folder that
+	// the caller completes by sending the content to the returned session URI
+	CreateUploadSession(filename, mimeType string, size int64, origin string) (string, error)
 }
+
+// ProgressFunc receives upload progress as bytes sent out of the total file size
+type ProgressFunc func(uploaded, total int64)
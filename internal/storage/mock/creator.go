@@ -4,6 +4,8 @@ import (
 	"context"
 
 	"cobblepod/internal/storage"
+
+	"golang.org/x/oauth2"
 )
 
 // NewMockStorageCreator returns a function that matches the StorageCreator signature
@@ -13,3 +15,11 @@ func NewMockStorageCreator(s storage.Storage, err error) func(context.Context, s
 		return s, err
 	}
 }
+
+// NewMockTokenSourceStorageCreator is NewMockStorageCreator for creators that
+// take a token source instead of an access token
+func NewMockTokenSourceStorageCreator(s storage.Storage, err error) func(context.Context, oauth2.TokenSource) (storage.Storage, error) {
+	return func(ctx context.Context, tokenSource oauth2.TokenSource) (storage.Storage, error) {
+		return s, err
+	}
+}
@@ -1,7 +1,7 @@
 package mock
 
 import (
-	"google.golang.org/api/drive/v3"
+	"cobblepod/internal/storage"
 )
 
 // MockStorage is a test implementation of the Storage interface that allows
@@ -15,13 +15,13 @@ type MockStorage struct {
 	ExtractFileIDFromURLFunc func(url string) string
 
 	// GetFiles mock configuration
-	GetFilesFunc  func(query string, mostRecent bool) ([]*drive.File, error)
+	GetFilesFunc  func(query storage.Query) ([]*storage.FileMeta, error)
 	GetFilesError error
-	GetFilesFiles []*drive.File
+	GetFilesFiles []*storage.FileMeta
 
 	// GetMostRecentFile mock configuration
-	GetMostRecentFileFunc func(files []*drive.File) *drive.File
-	GetMostRecentFileFile *drive.File
+	GetMostRecentFileFunc func(files []*storage.FileMeta) *storage.FileMeta
+	GetMostRecentFileFile *storage.FileMeta
 
 	// FileExists mock configuration
 	FileExistsFunc   func(fileID string) (bool, error)
@@ -32,6 +32,39 @@ type MockStorage struct {
 	DeleteFileFunc  func(fileID string) error
 	DeleteFileError error
 
+	// MoveFile mock configuration
+	MoveFileFunc  func(fileID, folder string) error
+	MoveFileError error
+
+	// GetFileMeta mock configuration
+	GetFileMetaFunc   func(fileID string) (*storage.FileMeta, error)
+	GetFileMetaResult *storage.FileMeta
+	GetFileMetaError  error
+
+	// Quota mock configuration
+	QuotaFunc  func() (*storage.QuotaInfo, error)
+	QuotaInfo  *storage.QuotaInfo
+	QuotaError error
+
+	// GetChanges mock configuration
+	GetChangesFunc   func(pageToken string) (*storage.ChangeSet, error)
+	GetChangesResult *storage.ChangeSet
+	GetChangesError  error
+
+	// WatchChanges mock configuration
+	WatchChangesFunc   func(channelID, address, token string) (*storage.WatchInfo, error)
+	WatchChangesResult *storage.WatchInfo
+	WatchChangesError  error
+
+	// EnsurePublic mock configuration
+	EnsurePublicFunc     func(fileID string) (bool, error)
+	EnsurePublicRepaired bool
+	EnsurePublicError    error
+
+	// UseFolder mock configuration
+	UseFolderFunc  func(path string) error
+	UseFolderError error
+
 	// DownloadFile mock configuration
 	DownloadFileFunc    func(fileID string) (string, error)
 	DownloadFileContent string
@@ -47,28 +80,55 @@ type MockStorage struct {
 	UploadFileID    string
 	UploadFileError error
 
+	// UploadFileWithProgress mock configuration (falls back to UploadFile behaviour)
+	UploadFileWithProgressFunc func(filePath, filename, mimeType string, progress storage.ProgressFunc) (string, error)
+
 	// UploadString mock configuration
 	UploadStringFunc  func(content, filename, mimeType, fileID string) (string, error)
 	UploadStringID    string
 	UploadStringError error
 
+	// CreateUploadSession mock configuration
+	CreateUploadSessionFunc  func(filename, mimeType string, size int64, origin string) (string, error)
+	CreateUploadSessionURI   string
+	CreateUploadSessionError error
+
 	// Call tracking for verification
 	GenerateDownloadURLCalls  []string
 	ExtractFileIDFromURLCalls []string
 	GetFilesCalls             []GetFilesCall
-	GetMostRecentFileCalls    [][]*drive.File
+	GetMostRecentFileCalls    [][]*storage.FileMeta
 	FileExistsCalls           []string
 	DeleteFileCalls           []string
+	MoveFileCalls             []MoveFileCall
+	GetFileMetaCalls          []string
+	QuotaCalls                int
+	GetChangesCalls           []string
+	WatchChangesCalls         []WatchChangesCall
+	EnsurePublicCalls         []string
+	UseFolderCalls            []string
+	SetTagsCalls              []map[string]string
 	DownloadFileCalls         []string
 	DownloadFileToTempCalls   []string
 	UploadFileCalls           []UploadFileCall
 	UploadStringCalls         []UploadStringCall
+	CreateUploadSessionCalls  []CreateUploadSessionCall
 }
 
 // Call tracking structs
 type GetFilesCall struct {
-	Query      string
-	MostRecent bool
+	Query storage.Query
+}
+
+type MoveFileCall struct {
+	FileID string
+	Folder string
+}
+
+type WatchChangesCall struct {
+	ChannelID string
+	Address   string
+	Token     string
 }
 
 type UploadFileCall struct {
@@ -84,19 +144,33 @@ type UploadStringCall struct {
 	FileID   string
 }
 
+type CreateUploadSessionCall struct {
+	Filename string
+	MimeType string
+	Size     int64
+	Origin   string
+}
+
 // NewMockStorage creates a new MockStorage with reasonable defaults.
 func NewMockStorage() *MockStorage {
 	return &MockStorage{
 		GenerateDownloadURLCalls:  make([]string, 0),
 		ExtractFileIDFromURLCalls: make([]string, 0),
 		GetFilesCalls:             make([]GetFilesCall, 0),
-		GetMostRecentFileCalls:    make([][]*drive.File, 0),
+		GetChangesCalls:           make([]string, 0),
+		WatchChangesCalls:         make([]WatchChangesCall, 0),
+		EnsurePublicCalls:         make([]string, 0),
+		UseFolderCalls:            make([]string, 0),
+		SetTagsCalls:              make([]map[string]string, 0),
+		GetMostRecentFileCalls:    make([][]*storage.FileMeta, 0),
 		FileExistsCalls:           make([]string, 0),
 		DeleteFileCalls:           make([]string, 0),
+		GetFileMetaCalls:          make([]string, 0),
 		DownloadFileCalls:         make([]string, 0),
 		DownloadFileToTempCalls:   make([]string, 0),
 		UploadFileCalls:           make([]UploadFileCall, 0),
 		UploadStringCalls:         make([]UploadStringCall, 0),
+		CreateUploadSessionCalls:  make([]CreateUploadSessionCall, 0),
 	}
 }
 
@@ -119,13 +193,12 @@ func (m *MockStorage) ExtractFileIDFromURL(url string) string {
 }
 
 // GetFiles implements Storage interface
-func (m *MockStorage) GetFiles(query string, mostRecent bool) ([]*drive.File, error) {
+func (m *MockStorage) GetFiles(query storage.Query) ([]*storage.FileMeta, error) {
 	m.GetFilesCalls = append(m.GetFilesCalls, GetFilesCall{
-		Query:      query,
-		MostRecent: mostRecent,
+		Query: query,
 	})
 	if m.GetFilesFunc != nil {
-		return m.GetFilesFunc(query, mostRecent)
+		return m.GetFilesFunc(query)
 	}
 	if m.GetFilesError != nil {
 		return nil, m.GetFilesError
@@ -134,7 +207,7 @@ func (m *MockStorage) GetFiles(query string, mostRecent bool) ([]*drive.File, er
 }
 
 // GetMostRecentFile implements Storage interface
-func (m *MockStorage) GetMostRecentFile(files []*drive.File) *drive.File {
+func (m *MockStorage) GetMostRecentFile(files []*storage.FileMeta) *storage.FileMeta {
 	m.GetMostRecentFileCalls = append(m.GetMostRecentFileCalls, files)
 	if m.GetMostRecentFileFunc != nil {
 		return m.GetMostRecentFileFunc(files)
@@ -160,6 +233,96 @@ func (m *MockStorage) DeleteFile(fileID string) error {
 	return m.DeleteFileError
 }
 
+// MoveFile implements Storage interface
+func (m *MockStorage) MoveFile(fileID, folder string) error {
+	m.MoveFileCalls = append(m.MoveFileCalls, MoveFileCall{FileID: fileID, Folder: folder})
+	if m.MoveFileFunc != nil {
+		return m.MoveFileFunc(fileID, folder)
+	}
+	return m.MoveFileError
+}
+
+// GetFileMeta implements Storage interface
+func (m *MockStorage) GetFileMeta(fileID string) (*storage.FileMeta, error) {
+	m.GetFileMetaCalls = append(m.GetFileMetaCalls, fileID)
+	if m.GetFileMetaFunc != nil {
+		return m.GetFileMetaFunc(fileID)
+	}
+	return m.GetFileMetaResult, m.GetFileMetaError
+}
+
+// Quota implements Storage interface
+func (m *MockStorage) Quota() (*storage.QuotaInfo, error) {
+	m.QuotaCalls++
+	if m.QuotaFunc != nil {
+		return m.QuotaFunc()
+	}
+	if m.QuotaError != nil {
+		return nil, m.QuotaError
+	}
+	if m.QuotaInfo == nil {
+		return &storage.QuotaInfo{}, nil
+	}
+	return m.QuotaInfo, nil
+}
+
+// GetChanges implements Storage interface
+func (m *MockStorage) GetChanges(pageToken string) (*storage.ChangeSet, error) {
+	m.GetChangesCalls = append(m.GetChangesCalls, pageToken)
+	if m.GetChangesFunc != nil {
+		return m.GetChangesFunc(pageToken)
+	}
+	if m.GetChangesError != nil {
+		return nil, m.GetChangesError
+	}
+	if m.GetChangesResult == nil {
+		return &storage.ChangeSet{}, nil
+	}
+	return m.GetChangesResult, nil
+}
+
+// WatchChanges implements Storage interface
+func (m *MockStorage) WatchChanges(channelID, address, token string) (*storage.WatchInfo, error) {
+	m.WatchChangesCalls = append(m.WatchChangesCalls, WatchChangesCall{
+		ChannelID: channelID,
+		Address:   address,
+		Token:     token,
+	})
+	if m.WatchChangesFunc != nil {
+		return m.WatchChangesFunc(channelID, address, token)
+	}
+	if m.WatchChangesError != nil {
+		return nil, m.WatchChangesError
+	}
+	if m.WatchChangesResult == nil {
+		return &storage.WatchInfo{}, nil
+	}
+	return m.WatchChangesResult, nil
+}
+
+// EnsurePublic implements Storage interface
+func (m *MockStorage) EnsurePublic(fileID string) (bool, error) {
+	m.EnsurePublicCalls = append(m.EnsurePublicCalls, fileID)
+	if m.EnsurePublicFunc != nil {
+		return m.EnsurePublicFunc(fileID)
+	}
+	return m.EnsurePublicRepaired, m.EnsurePublicError
+}
+
+// UseFolder implements Storage interface
+func (m *MockStorage) UseFolder(path string) error {
+	m.UseFolderCalls = append(m.UseFolderCalls, path)
+	if m.UseFolderFunc != nil {
+		return m.UseFolderFunc(path)
+	}
+	return m.UseFolderError
+}
+
+// SetTags implements Storage interface
+func (m *MockStorage) SetTags(tags map[string]string) {
+	m.SetTagsCalls = append(m.SetTagsCalls, tags)
+}
+
 // DownloadFile implements Storage interface
 func (m *MockStorage) DownloadFile(fileID string) (string, error) {
 	m.DownloadFileCalls = append(m.DownloadFileCalls, fileID)
@@ -191,6 +354,19 @@ func (m *MockStorage) UploadFile(filePath, filename, mimeType string) (string, e
 	return m.UploadFileID, m.UploadFileError
 }
 
+// UploadFileWithProgress implements Storage interface
+func (m *MockStorage) UploadFileWithProgress(filePath, filename, mimeType string, progress storage.ProgressFunc) (string, error) {
+	if m.UploadFileWithProgressFunc != nil {
+		m.UploadFileCalls = append(m.UploadFileCalls, UploadFileCall{
+			FilePath: filePath,
+			Filename: filename,
+			MimeType: mimeType,
+		})
+		return m.UploadFileWithProgressFunc(filePath, filename, mimeType, progress)
+	}
+	return m.UploadFile(filePath, filename, mimeType)
+}
+
 // UploadString implements Storage interface
 func (m *MockStorage) UploadString(content, filename, mimeType, fileID string) (string, error) {
 	m.UploadStringCalls = append(m.UploadStringCalls, UploadStringCall{
@@ -205,6 +381,20 @@ func (m *MockStorage) UploadString(content, filename, mimeType, fileID string) (
 	return m.UploadStringID, m.UploadStringError
 }
 
+// CreateUploadSession implements Storage interface
+func (m *MockStorage) CreateUploadSession(filename, mimeType string, size int64, origin string) (string, error) {
+	m.CreateUploadSessionCalls = append(m.CreateUploadSessionCalls, CreateUploadSessionCall{
+		Filename: filename,
+		MimeType: mimeType,
+		Size:     size,
+		Origin:   origin,
+	})
+	if m.CreateUploadSessionFunc != nil {
+		return m.CreateUploadSessionFunc(filename, mimeType, size, origin)
+	}
+	return m.CreateUploadSessionURI, m.CreateUploadSessionError
+}
+
 // Reset clears all call tracking and resets the mock to default state.
 func (m *MockStorage) Reset() {
 	// Clear function overrides
@@ -214,10 +404,18 @@ func (m *MockStorage) Reset() {
 	m.GetMostRecentFileFunc = nil
 	m.FileExistsFunc = nil
 	m.DeleteFileFunc = nil
+	m.GetFileMetaFunc = nil
+	m.QuotaFunc = nil
+	m.GetChangesFunc = nil
+	m.WatchChangesFunc = nil
+	m.EnsurePublicFunc = nil
+	m.UseFolderFunc = nil
 	m.DownloadFileFunc = nil
 	m.DownloadFileToTempFunc = nil
 	m.UploadFileFunc = nil
+	m.UploadFileWithProgressFunc = nil
 	m.UploadStringFunc = nil
+	m.CreateUploadSessionFunc = nil
 
 	// Clear simple return values
 	m.GetFilesError = nil
@@ -226,6 +424,17 @@ func (m *MockStorage) Reset() {
 	m.FileExistsResult = false
 	m.FileExistsError = nil
 	m.DeleteFileError = nil
+	m.GetFileMetaResult = nil
+	m.GetFileMetaError = nil
+	m.QuotaInfo = nil
+	m.QuotaError = nil
+	m.GetChangesResult = nil
+	m.GetChangesError = nil
+	m.WatchChangesResult = nil
+	m.WatchChangesError = nil
+	m.EnsurePublicRepaired = false
+	m.EnsurePublicError = nil
+	m.UseFolderError = nil
 	m.DownloadFileContent = ""
 	m.DownloadFileError = nil
 	m.DownloadFileToTempPath = ""
@@ -234,18 +443,28 @@ func (m *MockStorage) Reset() {
 	m.UploadFileError = nil
 	m.UploadStringID = ""
 	m.UploadStringError = nil
+	m.CreateUploadSessionURI = ""
+	m.CreateUploadSessionError = nil
 
 	// Clear call tracking
 	m.GenerateDownloadURLCalls = make([]string, 0)
 	m.ExtractFileIDFromURLCalls = make([]string, 0)
 	m.GetFilesCalls = make([]GetFilesCall, 0)
-	m.GetMostRecentFileCalls = make([][]*drive.File, 0)
+	m.GetMostRecentFileCalls = make([][]*storage.FileMeta, 0)
 	m.FileExistsCalls = make([]string, 0)
 	m.DeleteFileCalls = make([]string, 0)
+	m.GetFileMetaCalls = make([]string, 0)
+	m.QuotaCalls = 0
+	m.GetChangesCalls = make([]string, 0)
+	m.WatchChangesCalls = make([]WatchChangesCall, 0)
+	m.EnsurePublicCalls = make([]string, 0)
+	m.UseFolderCalls = make([]string, 0)
+	m.SetTagsCalls = make([]map[string]string, 0)
 	m.DownloadFileCalls = make([]string, 0)
 	m.DownloadFileToTempCalls = make([]string, 0)
 	m.UploadFileCalls = make([]UploadFileCall, 0)
 	m.UploadStringCalls = make([]UploadStringCall, 0)
+	m.CreateUploadSessionCalls = make([]CreateUploadSessionCall, 0)
 }
 
 // CallCount returns the number of calls made to each method for verification.
@@ -257,9 +476,17 @@ func (m *MockStorage) CallCount() map[string]int {
 		"GetMostRecentFile":    len(m.GetMostRecentFileCalls),
 		"FileExists":           len(m.FileExistsCalls),
 		"DeleteFile":           len(m.DeleteFileCalls),
+		"GetFileMeta":          len(m.GetFileMetaCalls),
+		"Quota":                m.QuotaCalls,
+		"GetChanges":           len(m.GetChangesCalls),
+		"WatchChanges":         len(m.WatchChangesCalls),
+		"EnsurePublic":         len(m.EnsurePublicCalls),
+		"UseFolder":            len(m.UseFolderCalls),
+		"SetTags":              len(m.SetTagsCalls),
 		"DownloadFile":         len(m.DownloadFileCalls),
 		"DownloadFileToTemp":   len(m.DownloadFileToTempCalls),
 		"UploadFile":           len(m.UploadFileCalls),
 		"UploadString":         len(m.UploadStringCalls),
+		"CreateUploadSession":  len(m.CreateUploadSessionCalls),
 	}
 }
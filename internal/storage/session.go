@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"google.golang.org/api/googleapi"
+)
+
+// CreateUploadSession starts a Drive resumable upload into the current folder
+// and returns the session URI. The client PUTs the file content to the URI
+// itself, so it never passes through this server; Drive answers the final PUT
+// with the created file's ID. origin is the browser origin that will upload,
+// which Drive needs to allow the cross-origin requests.
+func (s *GDrive) CreateUploadSession(filename, mimeType string, size int64, origin string) (string, error) {
+	file := map[string]interface{}{
+		"name":     filename,
+		"mimeType": mimeType,
+		"parents":  s.parents(),
+	}
+	if len(s.tags) > 0 {
+		file["appProperties"] = s.tags
+	}
+	metadata, err := json.Marshal(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal file metadata: %w", err)
+	}
+
+	url := googleapi.ResolveRelative(s.drive.BasePath, "/upload/drive/v3/files") + "?uploadType=resumable&fields=id,name"
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, url, bytes.NewReader(metadata))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("X-Upload-Content-Type", mimeType)
+	if size > 0 {
+		req.Header.Set("X-Upload-Content-Length", strconv.FormatInt(size, 10))
+	}
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+
+	resp, err := s.httpClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to create upload session: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to create upload session: HTTP %d", resp.StatusCode)
+	}
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", fmt.Errorf("failed to create upload session: no session URI returned")
+	}
+	return location, nil
+}
+
+// GetFileMeta returns a file's metadata, or nil if it doesn't exist
+func (s *GDrive) GetFileMeta(fileID string) (*FileMeta, error) {
+	if fileID == "" {
+		return nil, fmt.Errorf("file ID is empty")
+	}
+
+	file, err := s.drive.Files.Get(fileID).Fields("id, name, size, mimeType, modifiedTime, md5Checksum, trashed").Do()
+	if err != nil {
+		if apiErr, ok := err.(*googleapi.Error); ok && apiErr.Code == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get file %s: %w", fileID, err)
+	}
+	if file.Trashed {
+		return nil, nil
+	}
+	return toFileMeta(file), nil
+}
+
+// httpClient returns the client authorized as the user, for Drive calls the
+// API library doesn't cover
+func (s *GDrive) httpClient() *http.Client {
+	if s.client == nil {
+		return http.DefaultClient
+	}
+	return s.client
+}
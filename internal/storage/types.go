@@ -4,30 +4,99 @@ import (
 	"time"
 )
 
-// FileInfo represents a file in any storage backend
-type FileInfo struct {
+// FileMeta describes a file in any storage backend without exposing
+// provider-specific types (e.g. drive.File) to callers.
+type FileMeta struct {
 	ID           string    `json:"id"`
 	Name         string    `json:"name"`
 	ModifiedTime time.Time `json:"modified_time"`
 	Size         int64     `json:"size,omitempty"`
-	MimeType     string    `json:"mime_type,omitempty"`
-}
-
-// CommonStorage defines the interface for cloud storage operations using common types.
-// This interface abstracts cloud storage functionality to allow for
-// different storage backend implementations while maintaining the same API.
-type CommonStorage interface {
-	// File management operations
-	GenerateDownloadURL(fileID string) string
-	ExtractFileIDFromURL(url string) string
-	GetFiles(query string, mostRecent bool) ([]*FileInfo, error)
-	GetMostRecentFile(files []*FileInfo) *FileInfo
-	FileExists(fileID string) (bool, error)
-	DeleteFile(fileID string) error
-
-	// File content operations
-	DownloadFile(fileID string) (string, error)
-	DownloadFileToTemp(fileID string) (string, error)
-	UploadFile(filePath, filename, mimeType string) (string, error)
-	UploadString(content, filename, mimeType, fileID string) (string, error)
+	MIME         string    `json:"mime_type,omitempty"`
+	// MD5 is the content checksum reported by the backend, empty if it has none
+	MD5 string `json:"md5_checksum,omitempty"`
+	// Tags are the labels the file was created with, see Storage.SetTags
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// Tags cobblepod labels the files it creates with, so a user's usage can be
+// added up wherever the files live
+const (
+	// TagUser holds the ID of the user the file was stored for
+	TagUser = "cobblepod_user"
+	// TagFeed holds the folder of the feed the file is published in, and is only
+	// set on episodes and the feed itself
+	TagFeed = "cobblepod_feed"
+)
+
+// Query describes a file search independently of any backend's query syntax.
+// Each backend compiles it into its native form (e.g. a Drive "q" string).
+type Query struct {
+	// NamePattern matches files whose name contains this substring
+	NamePattern string
+	// Extension matches files whose name contains this extension (e.g. ".m3u")
+	Extension string
+	// ExactName matches files with exactly this name
+	ExactName string
+	// Trashed selects trashed files instead of live ones
+	Trashed bool
+	// SortByModified orders results newest first
+	SortByModified bool
+	// Limit caps the number of results (0 means no limit)
+	Limit int
+	// Folder limits results to files directly inside this slash-separated
+	// folder path (e.g. "cobblepod/playrun_addict"); empty searches everywhere
+	Folder string
+	// Tags matches files carrying every one of these tags
+	Tags map[string]string
+}
+
+// MostRecent returns a copy of the query that selects only the newest matching file
+func (q Query) MostRecent() Query {
+	q.SortByModified = true
+	q.Limit = 1
+	return q
+}
+
+// ChangeSet lists files added or modified since a change page token was issued
+type ChangeSet struct {
+	// Files holds changed files that still exist; removals and trashed files are omitted
+	Files []*FileMeta
+	// NextPageToken is the token to pass on the next call to see later changes
+	NextPageToken string
+}
+
+// Contains reports whether the file with the given ID changed
+func (c *ChangeSet) Contains(fileID string) bool {
+	for _, file := range c.Files {
+		if file.ID == fileID {
+			return true
+		}
+	}
+	return false
+}
+
+// WatchInfo describes a push notification channel registered with a backend
+type WatchInfo struct {
+	// ResourceID identifies the watched resource, needed to stop the channel
+	ResourceID string
+	// Expiration is when the backend stops sending notifications
+	Expiration time.Time
+}
+
+// QuotaInfo reports storage usage for the authenticated account. Total is zero
+// when the backend has no limit.
+type QuotaInfo struct {
+	Used  int64 `json:"used"`
+	Total int64 `json:"total"`
+}
+
+// Available returns the remaining bytes, or -1 when storage is unlimited
+func (q QuotaInfo) Available() int64 {
+	if q.Total <= 0 {
+		return -1
+	}
+	if q.Used >= q.Total {
+		return 0
+	}
+	return q.Total - q.Used
 }
@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans installs a tracer provider that keeps finished spans in memory
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	original := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(original) })
+	return recorder
+}
+
+func TestTraceParentRoundTrip(t *testing.T) {
+	recorder := recordSpans(t)
+
+	assert.Empty(t, TraceParent(context.Background()))
+
+	ctx, span := Start(context.Background(), "POST /api/backup/upload")
+	traceParent := TraceParent(ctx)
+	assert.NotEmpty(t, traceParent)
+	span.End()
+
+	// The worker continues the same trace from the traceparent stored on the job
+	_, child := Start(WithTraceParent(context.Background(), traceParent), "job.run")
+	child.End()
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, spans[0].SpanContext().TraceID(), spans[1].SpanContext().TraceID())
+		assert.Equal(t, spans[0].SpanContext().SpanID(), spans[1].Parent().SpanID())
+	}
+}
+
+func TestWithTraceParentIgnoresGarbage(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, ctx, WithTraceParent(ctx, ""))
+	assert.False(t, trace.SpanContextFromContext(WithTraceParent(ctx, "not-a-traceparent")).IsValid())
+}
+
+func TestFromRequest(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	spanContext := trace.SpanContextFromContext(FromRequest(req))
+	assert.True(t, spanContext.IsRemote())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spanContext.TraceID().String())
+}
+
+func TestEndRecordsError(t *testing.T) {
+	recorder := recordSpans(t)
+
+	_, span := Start(context.Background(), "ok")
+	End(span, nil)
+	_, span = Start(context.Background(), "failed")
+	End(span, errors.New("ffmpeg exited with status 1"))
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, codes.Unset, spans[0].Status().Code)
+		assert.Equal(t, codes.Error, spans[1].Status().Code)
+		assert.Equal(t, "ffmpeg exited with status 1", spans[1].Status().Description)
+	}
+}
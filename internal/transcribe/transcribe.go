@@ -0,0 +1,215 @@
+// Package transcribe turns processed episodes into WebVTT transcripts, which
+// feeds publish as <podcast:transcript> so podcast apps can show them.
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cobblepod/internal/config"
+)
+
+const (
+	// ContentType and Extension describe the transcripts transcribers write
+	ContentType = "text/vtt"
+	Extension   = "vtt"
+)
+
+// requestTimeout bounds a transcription request; long episodes take a while
+const requestTimeout = 30 * time.Minute
+
+// Transcriber writes a transcript of an audio file to a WebVTT file. The
+// caller removes the file.
+type Transcriber interface {
+	Transcribe(ctx context.Context, audioPath string) (path string, err error)
+}
+
+// Command transcribes by running a local program, such as whisper.cpp. {input}
+// in its arguments is replaced with the audio file and {output} with the
+// transcript it must write. whisper.cpp appends the extension to the name it
+// is given, so {output}.vtt is read too.
+type Command struct {
+	args []string
+}
+
+var _ Transcriber = (*Command)(nil)
+
+// NewCommand parses a command line like
+// "whisper-cli -m ggml-base.en.bin -f {input} --output-vtt --output-file {output}".
+// Arguments are split on whitespace; quoting isn't supported.
+func NewCommand(command string) (*Command, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.New("empty transcription command")
+	}
+	if !strings.Contains(command, "{input}") || !strings.Contains(command, "{output}") {
+		return nil, errors.New("transcription command must read {input} and write to {output}")
+	}
+	return &Command{args: args}, nil
+}
+
+// Transcribe runs the command and returns the transcript it wrote
+func (c *Command) Transcribe(ctx context.Context, audioPath string) (string, error) {
+	outputFile, err := os.CreateTemp("", "cobblepod_transcript_*."+Extension)
+	if err != nil {
+		return "", fmt.Errorf("failed to create transcript temp file: %w", err)
+	}
+	outputPath := outputFile.Name()
+	outputFile.Close()
+
+	args := make([]string, len(c.args))
+	for i, arg := range c.args {
+		arg = strings.ReplaceAll(arg, "{input}", audioPath)
+		args[i] = strings.ReplaceAll(arg, "{output}", outputPath)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(outputPath)
+		os.Remove(outputPath + "." + Extension)
+		return "", fmt.Errorf("transcription command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	// Prefer the file with the extension appended, which the command wrote
+	// instead of the empty one created above
+	if info, err := os.Stat(outputPath + "." + Extension); err == nil && info.Size() > 0 {
+		os.Remove(outputPath)
+		return outputPath + "." + Extension, nil
+	}
+	if info, err := os.Stat(outputPath); err != nil || info.Size() == 0 {
+		os.Remove(outputPath)
+		return "", errors.New("transcription command wrote no transcript")
+	}
+	return outputPath, nil
+}
+
+// HTTP transcribes by posting the audio to an OpenAI compatible
+// /v1/audio/transcriptions endpoint, such as OpenAI's or a self-hosted
+// faster-whisper server
+type HTTP struct {
+	url    string
+	apiKey string
+	model  string
+	client *http.Client
+}
+
+var _ Transcriber = (*HTTP)(nil)
+
+// NewHTTP returns a transcriber posting to url with model, authenticated with
+// apiKey when it isn't empty
+func NewHTTP(url, apiKey, model string) *HTTP {
+	return &HTTP{url: url, apiKey: apiKey, model: model, client: &http.Client{Timeout: requestTimeout}}
+}
+
+// Transcribe uploads the audio and writes the WebVTT transcript returned
+func (h *HTTP) Transcribe(ctx context.Context, audioPath string) (string, error) {
+	body, contentType, err := transcriptionRequest(audioPath, h.model)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create transcription request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if h.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+h.apiKey)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to transcribe: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("failed to transcribe: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	outputFile, err := os.CreateTemp("", "cobblepod_transcript_*."+Extension)
+	if err != nil {
+		return "", fmt.Errorf("failed to create transcript temp file: %w", err)
+	}
+	defer outputFile.Close()
+	written, err := io.Copy(outputFile, resp.Body)
+	if err == nil && written == 0 {
+		err = errors.New("empty transcript")
+	}
+	if err != nil {
+		os.Remove(outputFile.Name())
+		return "", fmt.Errorf("failed to write transcript: %w", err)
+	}
+	return outputFile.Name(), nil
+}
+
+// transcriptionRequest streams the multipart form asking for a WebVTT
+// transcript of the audio file, so episodes aren't read into memory
+func transcriptionRequest(audioPath, model string) (io.ReadCloser, string, error) {
+	audio, err := os.Open(audioPath)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open audio: %w", err)
+	}
+
+	reader, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		defer audio.Close()
+		writer.CloseWithError(writeForm(form, audio, filepath.Base(audioPath), model))
+	}()
+	return reader, form.FormDataContentType(), nil
+}
+
+// writeForm writes the audio and the request's fields to form and closes it
+func writeForm(form *multipart.Writer, audio io.Reader, filename, model string) error {
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, audio); err != nil {
+		return fmt.Errorf("failed to read audio: %w", err)
+	}
+	if err := form.WriteField("model", model); err != nil {
+		return err
+	}
+	if err := form.WriteField("response_format", Extension); err != nil {
+		return err
+	}
+	return form.Close()
+}
+
+// Configured returns the transcriber set by cfg's transcribe command or URL,
+// or nil when transcripts are off
+func Configured(cfg config.WorkerConfig) Transcriber {
+	switch {
+	case cfg.TranscribeCommand != "":
+		command, err := NewCommand(cfg.TranscribeCommand)
+		if err != nil {
+			slog.Warn("Ignoring invalid transcription command", "error", err)
+			return nil
+		}
+		return command
+	case cfg.TranscribeURL != "":
+		return NewHTTP(cfg.TranscribeURL, cfg.TranscribeAPIKey, cfg.TranscribeModel)
+	default:
+		return nil
+	}
+}
+
+// Enabled reports whether cfg lets the deployment transcribe episodes
+func Enabled(cfg config.WorkerConfig) bool {
+	return cfg.TranscribeCommand != "" || cfg.TranscribeURL != ""
+}
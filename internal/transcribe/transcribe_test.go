@@ -0,0 +1,113 @@
+package transcribe
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeAudio writes a stand-in audio file for transcribers to read
+func writeAudio(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "episode.mp3")
+	if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewCommand(t *testing.T) {
+	if _, err := NewCommand(""); err == nil {
+		t.Error("NewCommand(\"\") expected an error")
+	}
+	if _, err := NewCommand("whisper-cli -f {input}"); err == nil {
+		t.Error("NewCommand() without {output} expected an error")
+	}
+	if _, err := NewCommand("whisper-cli --output-file {output}"); err == nil {
+		t.Error("NewCommand() without {input} expected an error")
+	}
+	if _, err := NewCommand("whisper-cli -f {input} --output-vtt --output-file {output}"); err != nil {
+		t.Errorf("NewCommand() unexpected error: %v", err)
+	}
+}
+
+func TestCommandTranscribe(t *testing.T) {
+	audioPath := writeAudio(t)
+
+	// cp stands in for a transcriber writing to the path it is given
+	command, err := NewCommand("cp {input} {output}")
+	if err != nil {
+		t.Fatalf("NewCommand() unexpected error: %v", err)
+	}
+	path, err := command.Transcribe(context.Background(), audioPath)
+	if err != nil {
+		t.Fatalf("Transcribe() unexpected error: %v", err)
+	}
+	defer os.Remove(path)
+	if data, _ := os.ReadFile(path); string(data) != "audio" {
+		t.Errorf("Transcribe() wrote %q", data)
+	}
+
+	// Like whisper.cpp, a command may append the extension to {output}
+	command, _ = NewCommand("cp {input} {output}.vtt")
+	path, err = command.Transcribe(context.Background(), audioPath)
+	if err != nil {
+		t.Fatalf("Transcribe() unexpected error: %v", err)
+	}
+	defer os.Remove(path)
+	if data, _ := os.ReadFile(path); string(data) != "audio" {
+		t.Errorf("Transcribe() with an appended extension wrote %q", data)
+	}
+
+	// A command that writes nothing fails and leaves no file behind
+	command, _ = NewCommand("true {input} {output}")
+	if path, err := command.Transcribe(context.Background(), audioPath); err == nil {
+		os.Remove(path)
+		t.Error("Transcribe() expected an error for an empty output")
+	}
+}
+
+func TestHTTPTranscribe(t *testing.T) {
+	audioPath := writeAudio(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			http.Error(w, "unauthorized: "+got, http.StatusUnauthorized)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		if data, _ := io.ReadAll(file); string(data) != "audio" {
+			http.Error(w, "unexpected audio", http.StatusBadRequest)
+			return
+		}
+		if r.FormValue("model") != "whisper-1" || r.FormValue("response_format") != "vtt" {
+			http.Error(w, "unexpected fields", http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, "WEBVTT\n\n00:00.000 --> 00:01.000\nHello\n")
+	}))
+	defer server.Close()
+
+	path, err := NewHTTP(server.URL, "secret", "whisper-1").Transcribe(context.Background(), audioPath)
+	if err != nil {
+		t.Fatalf("Transcribe() unexpected error: %v", err)
+	}
+	defer os.Remove(path)
+	if data, _ := os.ReadFile(path); string(data) != "WEBVTT\n\n00:00.000 --> 00:01.000\nHello\n" {
+		t.Errorf("Transcribe() wrote %q", data)
+	}
+
+	// Errors from the endpoint are reported with its response
+	if _, err := NewHTTP(server.URL, "wrong", "whisper-1").Transcribe(context.Background(), audioPath); err == nil {
+		t.Error("Transcribe() expected an error for a rejected request")
+	}
+}
@@ -0,0 +1,87 @@
+// Package tts turns short texts into speech, for the spoken preambles joined
+// before processed episodes.
+package tts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+
+	"cobblepod/internal/config"
+)
+
+// Synthesizer speaks text into an audio file. The caller removes the file.
+type Synthesizer interface {
+	Synthesize(ctx context.Context, text string) (path string, err error)
+}
+
+// Command synthesizes speech by running a local program, such as piper or
+// espeak-ng. The text is written to its stdin and {output} in its arguments is
+// replaced with the WAV file it must write.
+type Command struct {
+	args []string
+}
+
+var _ Synthesizer = (*Command)(nil)
+
+// NewCommand parses a command line like "piper --model amy --output_file {output}".
+// Arguments are split on whitespace; quoting isn't supported.
+func NewCommand(command string) (*Command, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.New("empty TTS command")
+	}
+	if !strings.Contains(command, "{output}") {
+		return nil, errors.New("TTS command must write to {output}")
+	}
+	return &Command{args: args}, nil
+}
+
+// Configured returns the synthesizer set by cfg's TTS command, or nil when
+// spoken preambles are off
+func Configured(cfg config.WorkerConfig) Synthesizer {
+	if cfg.TTSCommand == "" {
+		return nil
+	}
+	command, err := NewCommand(cfg.TTSCommand)
+	if err != nil {
+		slog.Warn("Ignoring invalid TTS command", "error", err)
+		return nil
+	}
+	return command
+}
+
+// Synthesize runs the command and returns the WAV file it wrote
+func (c *Command) Synthesize(ctx context.Context, text string) (string, error) {
+	outputFile, err := os.CreateTemp("", "cobblepod_tts_*.wav")
+	if err != nil {
+		return "", fmt.Errorf("failed to create output temp file: %w", err)
+	}
+	outputPath := outputFile.Name()
+	outputFile.Close()
+
+	args := make([]string, len(c.args))
+	for i, arg := range c.args {
+		args[i] = strings.ReplaceAll(arg, "{output}", outputPath)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(outputPath)
+		return "", fmt.Errorf("TTS command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	if info, err := os.Stat(outputPath); err != nil || info.Size() == 0 {
+		os.Remove(outputPath)
+		return "", errors.New("TTS command wrote no audio")
+	}
+	return outputPath, nil
+}
@@ -0,0 +1,43 @@
+package tts
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestNewCommand(t *testing.T) {
+	if _, err := NewCommand(""); err == nil {
+		t.Error("NewCommand(\"\") expected an error")
+	}
+	if _, err := NewCommand("espeak-ng --stdin"); err == nil {
+		t.Error("NewCommand() without {output} expected an error")
+	}
+	if _, err := NewCommand("piper --output_file {output}"); err != nil {
+		t.Errorf("NewCommand() unexpected error: %v", err)
+	}
+}
+
+func TestCommandSynthesize(t *testing.T) {
+	// tee copies the text it is given into the output file
+	command, err := NewCommand("tee {output}")
+	if err != nil {
+		t.Fatalf("NewCommand() unexpected error: %v", err)
+	}
+
+	path, err := command.Synthesize(context.Background(), "Trail Talk, at 1.5x")
+	if err != nil {
+		t.Fatalf("Synthesize() unexpected error: %v", err)
+	}
+	defer os.Remove(path)
+	if data, _ := os.ReadFile(path); string(data) != "Trail Talk, at 1.5x" {
+		t.Errorf("Synthesize() wrote %q", data)
+	}
+
+	// A command that writes nothing fails and leaves no file behind
+	command, _ = NewCommand("true {output}")
+	if path, err := command.Synthesize(context.Background(), "text"); err == nil {
+		os.Remove(path)
+		t.Error("Synthesize() expected an error for an empty output")
+	}
+}
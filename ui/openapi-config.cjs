@@ -0,0 +1,11 @@
+// Generates typed RTK Query endpoints for the API from the server's Swagger
+// spec; run `make api-client` after changing a handler's annotations
+/** @type {import('@rtk-query/codegen-openapi').ConfigFile} */
+module.exports = {
+  schemaFile: '../docs/swagger.json',
+  apiFile: './src/services/backupApi.ts',
+  apiImport: 'backupApi',
+  outputFile: './src/services/cobblepodApi.ts',
+  exportName: 'cobblepodApi',
+  hooks: true,
+};